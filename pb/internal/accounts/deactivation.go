@@ -0,0 +1,154 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocketbase/internal/payment"
+)
+
+var (
+	ErrAlreadyDeactivated = errors.New("account is already deactivated")
+	ErrNotDeactivated     = errors.New("account is not deactivated")
+)
+
+// RetentionDays returns how long a deactivated account is kept before it is purged
+// Set via ACCOUNT_DEACTIVATION_RETENTION_DAYS (default 30)
+func RetentionDays() int {
+	days := 30
+	if v := os.Getenv("ACCOUNT_DEACTIVATION_RETENTION_DAYS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+	return days
+}
+
+// IsDeactivated reports whether a user account has been soft-deleted
+func IsDeactivated(user *core.Record) bool {
+	return !user.GetDateTime("deactivated_at").IsZero()
+}
+
+// PurgeAt returns when a deactivated account will be permanently deleted
+func PurgeAt(user *core.Record) time.Time {
+	return user.GetDateTime("deactivated_at").Time().AddDate(0, 0, RetentionDays())
+}
+
+// Deactivate soft-deletes a user: sessions are revoked, logins and API keys stop working
+// (the users auth rule and API key validation check deactivated_at) and billing is paused.
+// All data is kept until the retention window expires.
+func Deactivate(app core.App, paymentService *payment.Service, user *core.Record, reason string) error {
+	if IsDeactivated(user) {
+		return ErrAlreadyDeactivated
+	}
+
+	if sub := activeStripeSubscription(app, user.Id); sub != nil {
+		if paymentService == nil {
+			return fmt.Errorf("payment service not available to pause the subscription")
+		}
+		if _, err := paymentService.PauseSubscription(sub.GetString("provider_subscription_id"), time.Time{}); err != nil {
+			return err
+		}
+		user.Set("subscription_paused", true)
+	}
+
+	user.Set("deactivated_at", types.NowDateTime())
+	user.Set("deactivation_reason", reason)
+	user.RefreshTokenKey() // invalidates every issued auth token
+
+	if err := app.Save(user); err != nil {
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	log.Printf("[ACCOUNTS] Deactivated user %s (%s) - purge scheduled for %s",
+		user.Id, reason, PurgeAt(user).Format(time.RFC3339))
+	return nil
+}
+
+// Restore reactivates a soft-deleted user and resumes a paused subscription
+func Restore(app core.App, paymentService *payment.Service, user *core.Record) error {
+	if !IsDeactivated(user) {
+		return ErrNotDeactivated
+	}
+
+	if user.GetBool("subscription_paused") {
+		if sub := activeStripeSubscription(app, user.Id); sub != nil {
+			if paymentService == nil {
+				return fmt.Errorf("payment service not available to resume the subscription")
+			}
+			if _, err := paymentService.ResumeSubscription(sub.GetString("provider_subscription_id")); err != nil {
+				return err
+			}
+		}
+		user.Set("subscription_paused", false)
+	}
+
+	user.Set("deactivated_at", "")
+	user.Set("deactivation_reason", "")
+
+	if err := app.Save(user); err != nil {
+		return fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	log.Printf("[ACCOUNTS] Restored user %s", user.Id)
+	return nil
+}
+
+// PurgeExpired permanently deletes accounts deactivated longer than the retention window
+// Paid subscriptions are cancelled first; user-owned records are removed by cascade delete
+func PurgeExpired(app core.App) {
+	cutoff := types.NowDateTime().AddDate(0, 0, -RetentionDays())
+	users, err := app.FindRecordsByFilter("users", "deactivated_at != '' && deactivated_at < {:cutoff}", "", 0, 0, map[string]any{
+		"cutoff": cutoff.String(),
+	})
+	if err != nil {
+		log.Printf("[ACCOUNTS] ERROR: Failed to find expired deactivated accounts: %v", err)
+		return
+	}
+	if len(users) == 0 {
+		return
+	}
+
+	paymentService, paymentErr := payment.NewStripeService()
+
+	purged := 0
+	for _, user := range users {
+		if sub := activeStripeSubscription(app, user.Id); sub != nil {
+			if paymentErr != nil {
+				log.Printf("[ACCOUNTS] ERROR: Skipping purge of %s - payment service unavailable to cancel subscription: %v", user.Id, paymentErr)
+				continue
+			}
+			if _, err := paymentService.CancelSubscription(sub.GetString("provider_subscription_id"), false); err != nil {
+				log.Printf("[ACCOUNTS] ERROR: Skipping purge of %s - failed to cancel subscription: %v", user.Id, err)
+				continue
+			}
+		}
+
+		if err := app.Delete(user); err != nil {
+			log.Printf("[ACCOUNTS] ERROR: Failed to purge user %s: %v", user.Id, err)
+			continue
+		}
+		purged++
+	}
+
+	log.Printf("[ACCOUNTS] Purged %d of %d expired deactivated accounts", purged, len(users))
+}
+
+// activeStripeSubscription returns the user's billable Stripe subscription, if any
+func activeStripeSubscription(app core.App, userID string) *core.Record {
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions",
+		"user_id = {:user} && payment_provider = 'stripe' && provider_subscription_id != '' && status != 'cancelled'",
+		map[string]any{"user": userID},
+	)
+	if err != nil {
+		return nil
+	}
+	return sub
+}
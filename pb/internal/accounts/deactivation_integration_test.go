@@ -0,0 +1,36 @@
+//go:build integration
+
+package accounts_test
+
+import (
+	"net/http"
+	"testing"
+
+	"pocketbase/internal/testharness"
+)
+
+func TestUsersCannotChangeTheirDeactivation(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "active@test.com")
+	token := testharness.AuthToken(t, user)
+	server := testharness.Serve(t, app)
+
+	for _, body := range []map[string]any{
+		{"deactivated_at": "2026-01-01 00:00:00.000Z"},
+		{"deactivated_at": ""},
+		{"deactivation_reason": "self"},
+		{"subscription_paused": true},
+	} {
+		if response := server.Do(t, http.MethodPatch, "/api/collections/users/records/"+user.Id, token, body); response.Code == http.StatusOK {
+			t.Errorf("PATCH %v = 200, want it rejected", body)
+		}
+	}
+
+	reloaded, err := app.FindRecordById("users", user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.GetDateTime("deactivated_at").IsZero() || reloaded.GetString("deactivation_reason") != "" || reloaded.GetBool("subscription_paused") {
+		t.Error("user changed their deactivation fields")
+	}
+}
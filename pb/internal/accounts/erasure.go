@@ -0,0 +1,164 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocketbase/internal/payment"
+)
+
+// ReasonErasureRequest is the deactivation reason recorded for GDPR erasure requests
+const ReasonErasureRequest = "erasure_request"
+
+var ErrErasureRequested = errors.New("account deletion has already been requested")
+
+// ownedCollection is a collection of user-owned records and the field naming the owner
+type ownedCollection struct {
+	Collection string
+	Field      string
+	Filter     string // extra condition, e.g. to keep rows that belong to an organization
+}
+
+// erasedCollections are purged as soon as the user asks for their account to be deleted.
+// Billing records (subscriptions, their history, customers, invoices) are kept until the account
+// itself is purged, and usage charged to an organization's pool stays with the organization
+var erasedCollections = []ownedCollection{
+	{Collection: "processed_files", Field: "user_id"},
+	{Collection: "processed_file_summaries", Field: "user_id"},
+	{Collection: "reprocess_grants", Field: "user_id"},
+	{Collection: "transcription_jobs", Field: "user_id"},
+	{Collection: "audio_upload_sessions", Field: "user_id"},
+	{Collection: "processing_jobs", Field: "user_id"},
+	{Collection: "api_key_fingerprints", Field: "user_id"},
+	{Collection: "api_key_alerts", Field: "user_id"},
+	{Collection: "delegated_tokens", Field: "user_id"},
+	{Collection: "file_uploads", Field: "user"},
+	{Collection: "monthly_usage", Field: "user_id", Filter: "organization_id = ''"},
+	{Collection: "ai_usage_logs", Field: "user_id"},
+	{Collection: "communications", Field: "user_id"},
+	{Collection: "sent_emails", Field: "user_id"},
+	{Collection: "banner_dismissals", Field: "user_id"},
+	{Collection: "user_otps", Field: "user_id"},
+	{Collection: "moderation_flags", Field: "user_id"},
+}
+
+// RequestErasure handles a user's request to delete their account: the paid subscription is
+// cancelled immediately, API keys are revoked, transcripts and usage are purged, and the account
+// is deactivated so it's deleted for good once the retention window (the cooling-off period) ends
+func RequestErasure(app core.App, paymentService *payment.Service, user *core.Record) (int, error) {
+	if user.GetString("deactivation_reason") == ReasonErasureRequest {
+		return 0, ErrErasureRequested
+	}
+
+	if sub := activeStripeSubscription(app, user.Id); sub != nil {
+		if paymentService == nil {
+			return 0, fmt.Errorf("payment service not available to cancel the subscription")
+		}
+		if _, err := paymentService.CancelSubscription(sub.GetString("provider_subscription_id"), false); err != nil {
+			return 0, fmt.Errorf("failed to cancel subscription: %w", err)
+		}
+		// Mark it cancelled straight away so the purge doesn't try to cancel it again
+		sub.Set("status", "cancelled")
+		sub.Set("canceled_at", types.NowDateTime())
+		if err := app.Save(sub); err != nil {
+			log.Printf("[ACCOUNTS] WARNING: Failed to mark subscription %s cancelled: %v", sub.Id, err)
+		}
+	}
+
+	revoked, err := deleteOwned(app, ownedCollection{Collection: "api_keys", Field: "user_id"}, user.Id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+
+	purged := 0
+	for _, owned := range erasedCollections {
+		deleted, err := deleteOwned(app, owned, user.Id)
+		purged += deleted
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge %s: %w", owned.Collection, err)
+		}
+	}
+
+	if !IsDeactivated(user) {
+		user.Set("deactivated_at", types.NowDateTime())
+	}
+	user.Set("deactivation_reason", ReasonErasureRequest)
+	user.Set("subscription_paused", false)
+	user.RefreshTokenKey() // invalidates every issued auth token
+
+	if err := app.Save(user); err != nil {
+		return purged, fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	log.Printf("[ACCOUNTS] Erasure requested by user %s - revoked %d API keys, purged %d records, deletion scheduled for %s",
+		user.Id, revoked, purged, PurgeAt(user).Format(time.RFC3339))
+	return purged, nil
+}
+
+// deleteOwned deletes the user's records in a collection, returning how many were removed
+// Collections missing from the schema are skipped
+func deleteOwned(app core.App, owned ownedCollection, userID string) (int, error) {
+	if _, err := app.FindCollectionByNameOrId(owned.Collection); err != nil {
+		return 0, nil
+	}
+
+	filter := owned.Field + " = {:user}"
+	if owned.Filter != "" {
+		filter += " && " + owned.Filter
+	}
+	records, err := app.FindRecordsByFilter(owned.Collection, filter, "", 0, 0, map[string]any{"user": userID})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, record := range records {
+		if err := app.Delete(record); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// DeleteAccountHandler lets the signed-in user request deletion of their account. The body must
+// repeat the account email so a stray request can't wipe someone's data
+// POST /api/account/delete
+func DeleteAccountHandler(e *core.RequestEvent, app core.App, paymentService *payment.Service) error {
+	user := e.Auth
+	if user == nil || user.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	data := struct {
+		ConfirmEmail string `json:"confirm_email"`
+	}{}
+	if err := e.BindBody(&data); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if !strings.EqualFold(strings.TrimSpace(data.ConfirmEmail), user.Email()) {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "confirm_email must match your account email"})
+	}
+
+	purged, err := RequestErasure(app, paymentService, user)
+	if errors.Is(err, ErrErasureRequested) {
+		return e.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		log.Printf("[ACCOUNTS] ERROR: Erasure request for user %s failed: %v", user.Id, err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete account"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"message":        "Your account has been scheduled for deletion",
+		"purged_records": purged,
+		"purge_at":       PurgeAt(user).UTC().Format(time.RFC3339),
+	})
+}
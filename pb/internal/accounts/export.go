@@ -0,0 +1,215 @@
+package accounts
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// exportPageSize is how many records are loaded at a time while streaming an export
+const exportPageSize = 500
+
+// exportedCollections are the user-owned collections included in a data export. One-time codes
+// are left out entirely; secrets in the remaining records are dropped by exportFields
+var exportedCollections = []ownedCollection{
+	{Collection: "business_profiles", Field: "user_id"},
+	{Collection: "api_keys", Field: "user_id"},
+	{Collection: "passkeys", Field: "user"},
+	{Collection: "processed_files", Field: "user_id"},
+	{Collection: "processed_file_summaries", Field: "user_id"},
+	{Collection: "transcription_jobs", Field: "user_id"},
+	{Collection: "audio_upload_sessions", Field: "user_id"},
+	{Collection: "processing_jobs", Field: "user_id"},
+	{Collection: "api_key_fingerprints", Field: "user_id"},
+	{Collection: "api_key_alerts", Field: "user_id"},
+	{Collection: "file_uploads", Field: "user"},
+	{Collection: "monthly_usage", Field: "user_id"},
+	{Collection: "ai_usage_logs", Field: "user_id"},
+	{Collection: "payment_customers", Field: "user_id"},
+	{Collection: "current_user_subscriptions", Field: "user_id"},
+	{Collection: "subscription_history", Field: "user_id"},
+	{Collection: "checkout_sessions", Field: "user_id"},
+	{Collection: "organizations", Field: "owner_id"},
+	{Collection: "organization_members", Field: "user_id"},
+	{Collection: "communications", Field: "user_id"},
+	{Collection: "banner_dismissals", Field: "user_id"},
+	{Collection: "webhook_endpoints", Field: "user_id"},
+	{Collection: "webhook_deliveries", Field: "user_id"},
+	{Collection: "moderation_flags", Field: "user_id"},
+}
+
+// secretFields are never written to an export
+var secretFields = map[string]bool{
+	"password":       true,
+	"tokenKey":       true,
+	"key_hash":       true,
+	"signing_secret": true,
+	"api_key_hash":   true,
+	"credentials":    true,
+	"otp_code":       true,
+	"secret":         true,
+}
+
+// exportFields returns a record's data without secrets
+func exportFields(record *core.Record) map[string]any {
+	fields := record.FieldsData()
+	for name := range fields {
+		if secretFields[name] {
+			delete(fields, name)
+		}
+	}
+	return fields
+}
+
+// eachOwned calls fn for every record the user owns in a collection, a page at a time
+// Collections missing from the schema are skipped
+func eachOwned(app core.App, owned ownedCollection, userID string, fn func(*core.Record) error) error {
+	if _, err := app.FindCollectionByNameOrId(owned.Collection); err != nil {
+		return nil
+	}
+
+	for offset := 0; ; offset += exportPageSize {
+		records, err := app.FindRecordsByFilter(owned.Collection, owned.Field+" = {:user}", "id", exportPageSize, offset,
+			map[string]any{"user": userID})
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if len(records) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+// writeCollection streams the user's records in a collection as a JSON array
+func writeCollection(w io.Writer, app core.App, owned ownedCollection, userID string) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	first := true
+	err := eachOwned(app, owned, userID, func(record *core.Record) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		data, err := json.Marshal(exportFields(record))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// writeJSONExport streams the whole export as one JSON document
+func writeJSONExport(w io.Writer, app core.App, user *core.Record, exportedAt time.Time) error {
+	header, err := json.Marshal(map[string]any{"exported_at": exportedAt, "user": exportFields(user)})
+	if err != nil {
+		return err
+	}
+	// Reopen the header object so the collections can be streamed into it
+	if _, err := w.Write(header[:len(header)-1]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"collections":{`); err != nil {
+		return err
+	}
+	for i, owned := range exportedCollections {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%q:", owned.Collection); err != nil {
+			return err
+		}
+		if err := writeCollection(w, app, owned, user.Id); err != nil {
+			return fmt.Errorf("%s: %w", owned.Collection, err)
+		}
+	}
+	_, err = io.WriteString(w, "}}")
+	return err
+}
+
+// writeZIPExport streams the export as a ZIP archive with one JSON file per collection
+func writeZIPExport(w io.Writer, app core.App, user *core.Record, exportedAt time.Time) error {
+	archive := zip.NewWriter(w)
+
+	userFile, err := archive.CreateHeader(&zip.FileHeader{Name: "user.json", Method: zip.Deflate, Modified: exportedAt})
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(userFile).Encode(map[string]any{"exported_at": exportedAt, "user": exportFields(user)}); err != nil {
+		return err
+	}
+
+	for _, owned := range exportedCollections {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: owned.Collection + ".json", Method: zip.Deflate, Modified: exportedAt})
+		if err != nil {
+			return err
+		}
+		if err := writeCollection(file, app, owned, user.Id); err != nil {
+			return fmt.Errorf("%s: %w", owned.Collection, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+// ExportHandler streams a copy of everything stored about the signed-in user
+// GET /api/account/export?format=zip|json (default zip)
+func ExportHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil || user.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	format := e.Request.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if format != "zip" && format != "json" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "format must be zip or json"})
+	}
+
+	exportedAt := time.Now().UTC()
+	filename := fmt.Sprintf("account-export-%s.%s", exportedAt.Format("20060102"), format)
+	contentType := "application/zip"
+	write := writeZIPExport
+	if format == "json" {
+		contentType = "application/json"
+		write = writeJSONExport
+	}
+
+	e.Response.Header().Set("Content-Type", contentType)
+	e.Response.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	e.Response.Header().Set("Cache-Control", "no-store")
+	e.Response.WriteHeader(http.StatusOK)
+
+	// The status is already sent, so a failure part way through can only be logged; the
+	// truncated download won't parse and the user can retry
+	if err := write(e.Response, app, user, exportedAt); err != nil {
+		log.Printf("[ACCOUNTS] ERROR: Data export for user %s failed: %v", user.Id, err)
+		return nil
+	}
+
+	log.Printf("[ACCOUNTS] Data export (%s) downloaded by user %s", format, user.Id)
+	return nil
+}
@@ -0,0 +1,29 @@
+package accounts
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestExportFieldsDropsSecrets(t *testing.T) {
+	collection := core.NewBaseCollection("api_keys")
+	for _, name := range []string{"name", "key_prefix", "key_hash", "signing_secret"} {
+		collection.Fields.Add(&core.TextField{Name: name})
+	}
+	record := core.NewRecord(collection)
+	record.Set("name", "CI")
+	record.Set("key_prefix", "rk_live_ab")
+	record.Set("key_hash", "deadbeef")
+	record.Set("signing_secret", "whsec")
+
+	fields := exportFields(record)
+	if fields["name"] != "CI" || fields["key_prefix"] != "rk_live_ab" {
+		t.Errorf("expected regular fields to be exported, got %v", fields)
+	}
+	for _, name := range []string{"key_hash", "signing_secret"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("%s should not be exported", name)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package accounts
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/payment"
+)
+
+// SoftDeleteRequest turns user delete requests into deactivations so accounts can be restored
+// Superusers can still hard delete with ?hard=true.
+func SoftDeleteRequest(e *core.RecordRequestEvent) error {
+	if e.HasSuperuserAuth() && e.Request.URL.Query().Get("hard") == "true" {
+		return e.Next()
+	}
+
+	paymentService, err := payment.NewStripeService()
+	if err != nil {
+		paymentService = nil
+	}
+
+	reason := "user_request"
+	if e.HasSuperuserAuth() {
+		reason = "admin_delete"
+	}
+
+	if err := Deactivate(e.App, paymentService, e.Record, reason); err != nil && err != ErrAlreadyDeactivated {
+		log.Printf("[ACCOUNTS] ERROR: Failed to deactivate user %s: %v", e.Record.Id, err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete account"})
+	}
+
+	return e.NoContent(http.StatusNoContent)
+}
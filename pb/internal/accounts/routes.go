@@ -0,0 +1,24 @@
+package accounts
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/payment"
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the self-service account routes
+func RegisterRoutes(r *routes.Registry, paymentService *payment.Service) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/account/delete", Handler: func(e *core.RequestEvent) error {
+			return DeleteAccountHandler(e, app, paymentService)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/account/export", Handler: func(e *core.RequestEvent) error {
+			return ExportHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,74 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// ErrSandboxOwner is returned when a sandbox account tries to create a sandbox of its own
+var ErrSandboxOwner = errors.New("sandbox accounts cannot own a sandbox")
+
+// SandboxLimits caps what a sandbox tenant can do with its fake providers
+type SandboxLimits struct {
+	DailyTranscriptions int   `json:"daily_transcriptions"`
+	MaxAudioBytes       int64 `json:"max_audio_bytes"`
+}
+
+// SandboxLimitsFromEnv reads SANDBOX_DAILY_TRANSCRIPTIONS (default 50) and SANDBOX_MAX_AUDIO_MB (default 10)
+func SandboxLimitsFromEnv() SandboxLimits {
+	limits := SandboxLimits{DailyTranscriptions: 50, MaxAudioBytes: 10 << 20}
+	if v, err := strconv.Atoi(os.Getenv("SANDBOX_DAILY_TRANSCRIPTIONS")); err == nil && v > 0 {
+		limits.DailyTranscriptions = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SANDBOX_MAX_AUDIO_MB")); err == nil && v > 0 {
+		limits.MaxAudioBytes = int64(v) << 20
+	}
+	return limits
+}
+
+// IsSandbox reports whether a user is a sandbox tenant - its AI requests are served by
+// fake providers and its checkouts activate plans without charging anyone. Tenants are told
+// apart by sandbox_owner, which only EnsureSandboxTenant sets (the users rules reject it)
+func IsSandbox(user *core.Record) bool {
+	return user.GetString("sandbox_owner") != ""
+}
+
+// FindSandboxTenant returns the sandbox account owned by a user
+func FindSandboxTenant(app core.App, owner *core.Record) (*core.Record, error) {
+	return app.FindFirstRecordByFilter("users", "sandbox_owner = {:owner}", map[string]any{"owner": owner.Id})
+}
+
+// EnsureSandboxTenant returns the owner's sandbox account, creating it on first use
+// The account is a separate user so its files, usage and subscriptions never mix with the owner's.
+// It has a random password nobody knows - partners reach it only through sandbox API keys
+func EnsureSandboxTenant(app core.App, owner *core.Record) (*core.Record, bool, error) {
+	if IsSandbox(owner) {
+		return nil, false, ErrSandboxOwner
+	}
+	if tenant, err := FindSandboxTenant(app, owner); err == nil {
+		return tenant, false, nil
+	}
+
+	collection, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to find users collection: %w", err)
+	}
+
+	tenant := core.NewRecord(collection)
+	tenant.SetEmail(fmt.Sprintf("sandbox-%s@sandbox.invalid", owner.Id))
+	tenant.SetPassword(security.RandomString(32))
+	tenant.SetVerified(true)
+	tenant.Set("name", fmt.Sprintf("Sandbox (%s)", owner.GetString("email")))
+	tenant.Set("sandbox", true)
+	tenant.Set("sandbox_owner", owner.Id)
+
+	if err := app.Save(tenant); err != nil {
+		return nil, false, fmt.Errorf("failed to create sandbox account: %w", err)
+	}
+	return tenant, true, nil
+}
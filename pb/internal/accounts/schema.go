@@ -0,0 +1,22 @@
+package accounts
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the user fields soft deletion and sandbox tenants read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "accounts",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "users",
+				Fields: []schemacheck.Field{
+					{Name: "deactivated_at", Type: "date"},
+					{Name: "deactivation_reason", Type: "text"},
+					{Name: "subscription_paused", Type: "bool"},
+					{Name: "sandbox", Type: "bool"},
+					{Name: "sandbox_owner", Type: "relation"},
+				},
+			},
+		},
+	}
+}
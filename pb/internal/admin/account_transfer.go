@@ -0,0 +1,274 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/payment"
+)
+
+// AccountTransferRequest represents an admin request to move one user's data to another
+type AccountTransferRequest struct {
+	FromUserID          string `json:"from_user_id"`
+	ToUserID            string `json:"to_user_id"`
+	IncludeSubscription bool   `json:"include_subscription"`
+	DryRun              bool   `json:"dry_run"`
+}
+
+// AccountTransferResult describes what a transfer moved (or would move in dry-run mode)
+type AccountTransferResult struct {
+	FromUserID             string   `json:"from_user_id"`
+	ToUserID               string   `json:"to_user_id"`
+	DryRun                 bool     `json:"dry_run"`
+	ProcessedFilesMoved    int      `json:"processed_files_moved"`
+	ArchivedMonthsMoved    int      `json:"archived_months_moved"`
+	UsageMonthsMoved       []string `json:"usage_months_moved"`
+	UsageMonthsMerged      []string `json:"usage_months_merged"`
+	HoursMoved             float64  `json:"hours_moved"`
+	SubscriptionMoved      bool     `json:"subscription_moved"`
+	PaymentCustomerMoved   bool     `json:"payment_customer_moved"`
+	SubscriptionSkipReason string   `json:"subscription_skip_reason,omitempty"`
+}
+
+// TransferAccountHandler moves processed-file history, monthly usage and optionally the paid
+// subscription from one user to another (superusers only). Every run, including dry runs, is audited.
+func TransferAccountHandler(e *core.RequestEvent, app core.App, paymentService *payment.Service) error {
+	var req AccountTransferRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.FromUserID == "" || req.ToUserID == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "from_user_id and to_user_id are required"})
+	}
+	if req.FromUserID == req.ToUserID {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Cannot transfer an account to itself"})
+	}
+
+	for _, userID := range []string{req.FromUserID, req.ToUserID} {
+		if _, err := app.FindRecordById("users", userID); err != nil {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("User %s not found", userID)})
+		}
+	}
+
+	var result *AccountTransferResult
+	var err error
+	if req.DryRun {
+		result, err = transferAccount(app, nil, req, false)
+	} else {
+		err = app.RunInTransaction(func(txApp core.App) error {
+			var txErr error
+			result, txErr = transferAccount(txApp, paymentService, req, true)
+			return txErr
+		})
+	}
+	if err != nil {
+		recordAudit(app, e, "account_transfer_failed", req.DryRun, map[string]interface{}{
+			"request": req,
+			"error":   err.Error(),
+		})
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Transfer failed: %v", err)})
+	}
+
+	recordAudit(app, e, "account_transfer", req.DryRun, map[string]interface{}{
+		"request": req,
+		"result":  result,
+	})
+
+	return e.JSON(http.StatusOK, result)
+}
+
+// transferAccount plans the transfer and applies it when apply is true
+func transferAccount(app core.App, paymentService *payment.Service, req AccountTransferRequest, apply bool) (*AccountTransferResult, error) {
+	result := &AccountTransferResult{
+		FromUserID:        req.FromUserID,
+		ToUserID:          req.ToUserID,
+		DryRun:            !apply,
+		UsageMonthsMoved:  []string{},
+		UsageMonthsMerged: []string{},
+	}
+
+	// Processed file history
+	files, err := app.FindRecordsByFilter("processed_files", "user_id = {:user}", "", 0, 0, map[string]any{"user": req.FromUserID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load processed files: %w", err)
+	}
+	for _, file := range files {
+		if apply {
+			file.Set("user_id", req.ToUserID)
+			if err := app.Save(file); err != nil {
+				return nil, fmt.Errorf("failed to move processed file %s: %w", file.Id, err)
+			}
+		}
+		result.ProcessedFilesMoved++
+	}
+
+	// Archived processed_files summaries written by the retention job
+	archives, err := app.FindRecordsByFilter("processed_file_summaries", "user_id = {:user}", "", 0, 0, map[string]any{"user": req.FromUserID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load archived summaries: %w", err)
+	}
+	for _, archive := range archives {
+		if apply {
+			if err := moveArchivedSummary(app, archive, req.ToUserID); err != nil {
+				return nil, err
+			}
+		}
+		result.ArchivedMonthsMoved++
+	}
+
+	// Monthly usage - merged into the target's row when both users have usage for the same month
+	// (and the same organization pool, for hours charged to one)
+	usageRecords, err := app.FindRecordsByFilter("monthly_usage", "user_id = {:user}", "+year_month", 0, 0, map[string]any{"user": req.FromUserID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load monthly usage: %w", err)
+	}
+	for _, usage := range usageRecords {
+		yearMonth := usage.GetString("year_month")
+		result.HoursMoved += usage.GetFloat("hours_used")
+
+		// A bound empty string doesn't match an unset relation, so personal usage compares to ''
+		orgFilter := "organization_id = ''"
+		if usage.GetString("organization_id") != "" {
+			orgFilter = "organization_id = {:org}"
+		}
+		target, err := app.FindFirstRecordByFilter("monthly_usage", "user_id = {:user} && "+orgFilter+" && year_month = {:month}", map[string]any{
+			"user":  req.ToUserID,
+			"org":   usage.GetString("organization_id"),
+			"month": yearMonth,
+		})
+		if err != nil {
+			result.UsageMonthsMoved = append(result.UsageMonthsMoved, yearMonth)
+			if apply {
+				usage.Set("user_id", req.ToUserID)
+				if err := app.Save(usage); err != nil {
+					return nil, fmt.Errorf("failed to move usage for %s: %w", yearMonth, err)
+				}
+			}
+			continue
+		}
+
+		result.UsageMonthsMerged = append(result.UsageMonthsMerged, yearMonth)
+		if apply {
+			target.Set("hours_used", target.GetFloat("hours_used")+usage.GetFloat("hours_used"))
+			target.Set("files_processed", target.GetInt("files_processed")+usage.GetInt("files_processed"))
+			target.Set("tokens_used", target.GetInt("tokens_used")+usage.GetInt("tokens_used"))
+			if usage.GetDateTime("last_processing_date").After(target.GetDateTime("last_processing_date")) {
+				target.Set("last_processing_date", usage.GetDateTime("last_processing_date"))
+			}
+			if err := app.Save(target); err != nil {
+				return nil, fmt.Errorf("failed to merge usage for %s: %w", yearMonth, err)
+			}
+			if err := app.Delete(usage); err != nil {
+				return nil, fmt.Errorf("failed to delete merged usage for %s: %w", yearMonth, err)
+			}
+		}
+	}
+
+	if req.IncludeSubscription {
+		if err := transferSubscription(app, paymentService, req, result, apply); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// moveArchivedSummary reassigns an archived month, adding it to the target's summary if one exists
+func moveArchivedSummary(app core.App, archive *core.Record, toUserID string) error {
+	target, err := app.FindFirstRecordByFilter("processed_file_summaries", "user_id = {:user} && year_month = {:month}", map[string]any{
+		"user":  toUserID,
+		"month": archive.GetString("year_month"),
+	})
+	if err != nil {
+		archive.Set("user_id", toUserID)
+		if err := app.Save(archive); err != nil {
+			return fmt.Errorf("failed to move archived summary %s: %w", archive.GetString("year_month"), err)
+		}
+		return nil
+	}
+
+	for _, field := range []string{"total_files", "total_duration_seconds", "total_file_size_bytes", "total_processing_time_ms",
+		"completed_count", "processing_count", "failed_count"} {
+		target.Set(field, target.GetFloat(field)+archive.GetFloat(field))
+	}
+	if err := app.Save(target); err != nil {
+		return fmt.Errorf("failed to merge archived summary %s: %w", archive.GetString("year_month"), err)
+	}
+	if err := app.Delete(archive); err != nil {
+		return fmt.Errorf("failed to delete merged archived summary %s: %w", archive.GetString("year_month"), err)
+	}
+	return nil
+}
+
+// transferSubscription moves a paid subscription and its payment customer to the target user
+// The payment customer must move too, otherwise provider webhooks would keep resolving to the old user.
+// So does the Stripe customer's metadata.user_id, which reconciliation checks the mapping against;
+// it's updated last, so a Stripe failure rolls the transfer back
+func transferSubscription(app core.App, paymentService *payment.Service, req AccountTransferRequest, result *AccountTransferResult, apply bool) error {
+	sourceSub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": req.FromUserID})
+	if err != nil {
+		result.SubscriptionSkipReason = "source user has no subscription"
+		return nil
+	}
+	if !isPaidSubscription(app, sourceSub) {
+		result.SubscriptionSkipReason = "source user is on the free plan"
+		return nil
+	}
+
+	targetSub, targetErr := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": req.ToUserID})
+	if targetErr == nil && isPaidSubscription(app, targetSub) {
+		result.SubscriptionSkipReason = "target user already has a paid subscription"
+		return nil
+	}
+
+	if _, err := app.FindFirstRecordByFilter("payment_customers", "user_id = {:user}", map[string]any{"user": req.ToUserID}); err == nil {
+		result.SubscriptionSkipReason = "target user already has a payment customer"
+		return nil
+	}
+	sourceCustomer, customerErr := app.FindFirstRecordByFilter("payment_customers", "user_id = {:user}", map[string]any{"user": req.FromUserID})
+
+	result.SubscriptionMoved = true
+	result.PaymentCustomerMoved = customerErr == nil
+	if !apply {
+		return nil
+	}
+
+	// The target's free subscription is replaced (user_id is unique on current_user_subscriptions)
+	if targetErr == nil {
+		if err := app.Delete(targetSub); err != nil {
+			return fmt.Errorf("failed to remove target's free subscription: %w", err)
+		}
+	}
+
+	sourceSub.Set("user_id", req.ToUserID)
+	if err := app.Save(sourceSub); err != nil {
+		return fmt.Errorf("failed to move subscription: %w", err)
+	}
+
+	if customerErr == nil {
+		sourceCustomer.Set("user_id", req.ToUserID)
+		if err := app.Save(sourceCustomer); err != nil {
+			return fmt.Errorf("failed to move payment customer: %w", err)
+		}
+		if paymentService == nil {
+			return fmt.Errorf("payment service not available to update the Stripe customer")
+		}
+		customerID := sourceCustomer.GetString("provider_customer_id")
+		if err := paymentService.UpdateCustomerMetadata(customerID, map[string]string{"user_id": req.ToUserID}); err != nil {
+			return fmt.Errorf("failed to update Stripe customer %s: %w", customerID, err)
+		}
+	}
+
+	return nil
+}
+
+// isPaidSubscription reports whether a subscription record is on a paid plan
+func isPaidSubscription(app core.App, sub *core.Record) bool {
+	plan, err := app.FindRecordById("subscription_plans", sub.GetString("plan_id"))
+	if err != nil {
+		return false
+	}
+	return plan.GetInt("price_cents") > 0
+}
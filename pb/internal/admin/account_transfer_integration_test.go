@@ -0,0 +1,143 @@
+//go:build integration
+
+package admin_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocketbase/internal/payment"
+	"pocketbase/internal/testharness"
+)
+
+// seedRecord saves a record in collection with fields
+func seedRecord(t *testing.T, app core.App, collection string, fields map[string]any) *core.Record {
+	t.Helper()
+	c, err := app.FindCollectionByNameOrId(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := core.NewRecord(c)
+	record.Load(fields)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// seedTransfer gives the source user a file, two months of usage (one the target also has) and
+// a paid Stripe subscription
+func seedTransfer(t *testing.T, app core.App, plans map[string]*core.Record) (from, to *core.Record) {
+	t.Helper()
+	from = subscribe(t, app, "from@test.com", plans["pro"], "sub_from")
+	fromUser, err := app.FindRecordById("users", from.GetString("user_id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	to = testharness.CreateUser(t, app, "to@test.com")
+	now := types.NowDateTime()
+
+	seedRecord(t, app, "processed_files", map[string]any{"user_id": fromUser.Id, "filename": "a.mp3", "status": "completed"})
+	seedRecord(t, app, "monthly_usage", map[string]any{"user_id": fromUser.Id, "year_month": "2026-08", "hours_used": 2.0, "files_processed": 3, "last_processing_date": now})
+	seedRecord(t, app, "monthly_usage", map[string]any{"user_id": fromUser.Id, "year_month": "2026-09", "hours_used": 1.5, "files_processed": 1, "last_processing_date": now})
+	seedRecord(t, app, "monthly_usage", map[string]any{"user_id": to.Id, "year_month": "2026-09", "hours_used": 0.5, "files_processed": 1, "last_processing_date": now})
+	seedRecord(t, app, "payment_customers", map[string]any{"user_id": fromUser.Id, "provider_customer_id": "cus_from"})
+	return fromUser, to
+}
+
+func TestAccountTransferDryRun(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	token := testharness.AuthToken(t, testharness.CreateSuperuser(t, app, "ops@test.com"))
+	provider := &fakeProvider{}
+	server := serveAdmin(t, app, payment.NewService(provider, payment.Config{}))
+	from, to := seedTransfer(t, app, plans)
+
+	request := map[string]any{"from_user_id": from.Id, "to_user_id": to.Id, "include_subscription": true, "dry_run": true}
+	server.Do(t, http.MethodPost, "/api/admin/accounts/transfer", "", request).Expect(t, http.StatusUnauthorized)
+	result := server.Do(t, http.MethodPost, "/api/admin/accounts/transfer", token, request).Expect(t, http.StatusOK).JSON(t)
+
+	if result["processed_files_moved"] != 1.0 || result["hours_moved"] != 3.5 ||
+		result["subscription_moved"] != true || result["payment_customer_moved"] != true {
+		t.Errorf("result = %v", result)
+	}
+	if moved, _ := result["usage_months_moved"].([]any); len(moved) != 1 || moved[0] != "2026-08" {
+		t.Errorf("usage_months_moved = %v", result["usage_months_moved"])
+	}
+	if merged, _ := result["usage_months_merged"].([]any); len(merged) != 1 || merged[0] != "2026-09" {
+		t.Errorf("usage_months_merged = %v", result["usage_months_merged"])
+	}
+
+	// Nothing moved, locally or in Stripe
+	for _, collection := range []string{"processed_files", "current_user_subscriptions", "payment_customers"} {
+		if _, err := app.FindFirstRecordByFilter(collection, "user_id = {:user}", map[string]any{"user": from.Id}); err != nil {
+			t.Errorf("%s moved on a dry run: %v", collection, err)
+		}
+	}
+	if usage, _ := app.FindRecordsByFilter("monthly_usage", "user_id = {:user}", "", 0, 0, map[string]any{"user": from.Id}); len(usage) != 2 {
+		t.Errorf("source usage months = %d, want 2", len(usage))
+	}
+	if len(provider.metadata) != 0 {
+		t.Errorf("dry run updated Stripe: %v", provider.metadata)
+	}
+	if _, err := app.FindFirstRecordByFilter("admin_audit_logs", "action = 'account_transfer' && dry_run = true"); err != nil {
+		t.Errorf("dry run not audited: %v", err)
+	}
+}
+
+func TestAccountTransferApply(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	token := testharness.AuthToken(t, testharness.CreateSuperuser(t, app, "ops@test.com"))
+	provider := &fakeProvider{}
+	server := serveAdmin(t, app, payment.NewService(provider, payment.Config{}))
+	from, to := seedTransfer(t, app, plans)
+
+	request := map[string]any{"from_user_id": from.Id, "to_user_id": to.Id, "include_subscription": true}
+	server.Do(t, http.MethodPost, "/api/admin/accounts/transfer", token, request).Expect(t, http.StatusOK)
+
+	for _, collection := range []string{"processed_files", "monthly_usage", "current_user_subscriptions", "payment_customers"} {
+		if _, err := app.FindFirstRecordByFilter(collection, "user_id = {:user}", map[string]any{"user": from.Id}); err == nil {
+			t.Errorf("%s left on the source user", collection)
+		}
+	}
+	merged, err := app.FindFirstRecordByFilter("monthly_usage", "user_id = {:user} && year_month = '2026-09'", map[string]any{"user": to.Id})
+	if err != nil || merged.GetFloat("hours_used") != 2.0 || merged.GetInt("files_processed") != 2 {
+		t.Errorf("merged usage = %v, %v", merged, err)
+	}
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": to.Id})
+	if err != nil || sub.GetString("plan_id") != plans["pro"].Id {
+		t.Errorf("target subscription = %v, %v", sub, err)
+	}
+
+	// The Stripe customer points at the new owner, so reconciliation doesn't flag it
+	if got := provider.metadata["cus_from"]["user_id"]; got != to.Id {
+		t.Errorf("Stripe customer user_id = %q, want %q", got, to.Id)
+	}
+	if _, err := app.FindFirstRecordByFilter("admin_audit_logs", "action = 'account_transfer' && dry_run = false"); err != nil {
+		t.Errorf("transfer not audited: %v", err)
+	}
+}
+
+func TestAccountTransferRollsBackWhenStripeFails(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	token := testharness.AuthToken(t, testharness.CreateSuperuser(t, app, "ops@test.com"))
+	server := serveAdmin(t, app, payment.NewService(&fakeProvider{down: true}, payment.Config{}))
+	from, to := seedTransfer(t, app, plans)
+
+	request := map[string]any{"from_user_id": from.Id, "to_user_id": to.Id, "include_subscription": true}
+	server.Do(t, http.MethodPost, "/api/admin/accounts/transfer", token, request).Expect(t, http.StatusInternalServerError)
+
+	for _, collection := range []string{"processed_files", "current_user_subscriptions", "payment_customers"} {
+		if _, err := app.FindFirstRecordByFilter(collection, "user_id = {:user}", map[string]any{"user": from.Id}); err != nil {
+			t.Errorf("%s moved despite the failed transfer: %v", collection, err)
+		}
+	}
+	if _, err := app.FindFirstRecordByFilter("admin_audit_logs", "action = 'account_transfer_failed'"); err != nil {
+		t.Errorf("failure not audited: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package admin
+
+import (
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// recordAudit persists an admin action to admin_audit_logs
+// Failures are logged but never block the admin operation itself
+func recordAudit(app core.App, e *core.RequestEvent, action string, dryRun bool, details map[string]interface{}) {
+	recordAuditAs(app, e.Auth, action, dryRun, details)
+}
+
+// recordAuditAs persists an action taken by actor, for work that outlives the admin's request
+func recordAuditAs(app core.App, actor *core.Record, action string, dryRun bool, details map[string]interface{}) {
+	collection, err := app.FindCollectionByNameOrId("admin_audit_logs")
+	if err != nil {
+		log.Printf("[ADMIN] Failed to find admin_audit_logs collection: %v", err)
+		return
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("action", action)
+	record.Set("dry_run", dryRun)
+	record.Set("details", details)
+	if actor != nil {
+		record.Set("actor_id", actor.Id)
+		record.Set("actor_email", actor.GetString("email"))
+	}
+
+	if err := app.Save(record); err != nil {
+		log.Printf("[ADMIN] Failed to save audit log for %s: %v", action, err)
+		return
+	}
+
+	log.Printf("[ADMIN] Audit: %s by %s (dry_run=%v)", action, record.GetString("actor_email"), dryRun)
+}
@@ -0,0 +1,173 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/payment"
+	"pocketbase/internal/subscription"
+)
+
+// CustomPlanRequest represents the payload for building a custom enterprise plan
+type CustomPlanRequest struct {
+	Name            string   `json:"name"`
+	UserID          string   `json:"user_id"`
+	PriceCents      int64    `json:"price_cents"`
+	Currency        string   `json:"currency"`
+	BillingInterval string   `json:"billing_interval"`
+	HoursPerMonth   float64  `json:"hours_per_month"`
+	SeatCount       int      `json:"seat_count"`
+	BillingMethod   string   `json:"billing_method"`
+	Features        []string `json:"features"`
+	AlignToMonth    bool     `json:"align_billing_to_month_start"`
+}
+
+// Validate checks the custom plan request and fills in defaults
+func (r *CustomPlanRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.UserID == "" {
+		return fmt.Errorf("user_id is required")
+	}
+	if r.PriceCents < 0 {
+		return fmt.Errorf("price_cents cannot be negative")
+	}
+	if r.HoursPerMonth <= 0 {
+		return fmt.Errorf("hours_per_month must be greater than 0")
+	}
+
+	if r.Currency == "" {
+		r.Currency = "usd"
+	}
+	if r.BillingInterval == "" {
+		r.BillingInterval = "month"
+	}
+	if r.BillingInterval != "month" && r.BillingInterval != "year" {
+		return fmt.Errorf("billing_interval must be 'month' or 'year'")
+	}
+	if r.SeatCount == 0 {
+		r.SeatCount = 1
+	}
+	if r.SeatCount < 0 {
+		return fmt.Errorf("seat_count must be positive")
+	}
+	if r.BillingMethod == "" {
+		r.BillingMethod = "stripe"
+	}
+	if r.BillingMethod != "stripe" && r.BillingMethod != "invoice" {
+		return fmt.Errorf("billing_method must be 'stripe' or 'invoice'")
+	}
+	if r.BillingMethod == "stripe" && r.PriceCents == 0 {
+		return fmt.Errorf("stripe-billed custom plans must have a price")
+	}
+
+	return nil
+}
+
+// CreateCustomPlanHandler builds a custom plan for a single enterprise account (superusers only)
+func CreateCustomPlanHandler(e *core.RequestEvent, app core.App) error {
+	var req CustomPlanRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if _, err := app.FindRecordById("users", req.UserID); err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	collection, err := app.FindCollectionByNameOrId("subscription_plans")
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to find subscription_plans collection"})
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("name", req.Name)
+	record.Set("price_cents", req.PriceCents)
+	record.Set("currency", req.Currency)
+	record.Set("billing_interval", req.BillingInterval)
+	record.Set("hours_per_month", req.HoursPerMonth)
+	record.Set("features", req.Features)
+	record.Set("is_active", true)
+	record.Set("is_custom", true)
+	record.Set("assigned_user_id", req.UserID)
+	record.Set("seat_count", req.SeatCount)
+	record.Set("billing_method", req.BillingMethod)
+	record.Set("payment_provider", req.BillingMethod)
+	record.Set("align_billing_to_month_start", req.AlignToMonth)
+
+	// Stripe-billed plans need their own product and price so the user can go through checkout
+	if req.BillingMethod == "stripe" {
+		stripeKey := os.Getenv("STRIPE_SECRET_KEY")
+		if stripeKey == "" {
+			return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+		}
+
+		result, err := payment.NewStripeSetup(stripeKey).CreateProductAndPrice(req.Name, req.PriceCents, req.BillingInterval)
+		if err != nil {
+			return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create Stripe price: %v", err)})
+		}
+		record.Set("provider_price_id", result.PriceID)
+		record.Set("provider_product_id", result.ProductID)
+	}
+
+	if err := app.Save(record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save custom plan: %v", err)})
+	}
+
+	log.Printf("[ADMIN] Created custom plan %s (%s) for user %s", record.Id, req.Name, req.UserID)
+
+	return e.JSON(http.StatusCreated, record)
+}
+
+// ListCustomPlansHandler returns all custom enterprise plans (superusers only)
+func ListCustomPlansHandler(e *core.RequestEvent, app core.App) error {
+	records, err := app.FindRecordsByFilter("subscription_plans", "is_custom = true", "-created", 0, 0)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list custom plans"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{"plans": records})
+}
+
+// AssignCustomPlanHandler activates an invoice-billed custom plan for its assigned user (superusers only)
+func AssignCustomPlanHandler(e *core.RequestEvent, app core.App, subscriptionService subscription.Service) error {
+	planID := e.Request.PathValue("id")
+
+	var req struct {
+		PeriodMonths int `json:"period_months"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.PeriodMonths <= 0 {
+		req.PeriodMonths = 12
+	}
+
+	plan, err := app.FindRecordById("subscription_plans", planID)
+	if err != nil || !plan.GetBool("is_custom") {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Custom plan not found"})
+	}
+
+	if plan.GetString("billing_method") != "invoice" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Stripe-billed plans are activated through checkout"})
+	}
+
+	periodEnd := time.Now().AddDate(0, req.PeriodMonths, 0)
+	record, err := subscriptionService.AssignPlan(plan.GetString("assigned_user_id"), plan.Id, periodEnd, "invoice")
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to assign plan: %v", err)})
+	}
+
+	return e.JSON(http.StatusOK, record)
+}
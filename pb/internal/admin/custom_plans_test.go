@@ -0,0 +1,50 @@
+package admin
+
+import "testing"
+
+func TestCustomPlanRequestValidate(t *testing.T) {
+	valid := func() CustomPlanRequest {
+		return CustomPlanRequest{Name: " Acme ", UserID: "u1", PriceCents: 50000, HoursPerMonth: 200}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(r *CustomPlanRequest)
+		wantErr bool
+		want    CustomPlanRequest
+	}{
+		{"defaults", func(r *CustomPlanRequest) {}, false,
+			CustomPlanRequest{Name: "Acme", Currency: "usd", BillingInterval: "month", SeatCount: 1, BillingMethod: "stripe"}},
+		{"explicit", func(r *CustomPlanRequest) {
+			r.Currency, r.BillingInterval, r.SeatCount, r.BillingMethod = "eur", "year", 25, "invoice"
+		}, false, CustomPlanRequest{Name: "Acme", Currency: "eur", BillingInterval: "year", SeatCount: 25, BillingMethod: "invoice"}},
+		{"free invoice plan", func(r *CustomPlanRequest) { r.PriceCents, r.BillingMethod = 0, "invoice" }, false,
+			CustomPlanRequest{Name: "Acme", Currency: "usd", BillingInterval: "month", SeatCount: 1, BillingMethod: "invoice"}},
+		{"blank name", func(r *CustomPlanRequest) { r.Name = "  " }, true, CustomPlanRequest{}},
+		{"missing user", func(r *CustomPlanRequest) { r.UserID = "" }, true, CustomPlanRequest{}},
+		{"negative price", func(r *CustomPlanRequest) { r.PriceCents = -1 }, true, CustomPlanRequest{}},
+		{"no hours", func(r *CustomPlanRequest) { r.HoursPerMonth = 0 }, true, CustomPlanRequest{}},
+		{"unknown interval", func(r *CustomPlanRequest) { r.BillingInterval = "week" }, true, CustomPlanRequest{}},
+		{"negative seats", func(r *CustomPlanRequest) { r.SeatCount = -3 }, true, CustomPlanRequest{}},
+		{"unknown billing method", func(r *CustomPlanRequest) { r.BillingMethod = "paypal" }, true, CustomPlanRequest{}},
+		{"free stripe plan", func(r *CustomPlanRequest) { r.PriceCents = 0 }, true, CustomPlanRequest{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			err := req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if req.Name != tt.want.Name || req.Currency != tt.want.Currency || req.BillingInterval != tt.want.BillingInterval ||
+				req.SeatCount != tt.want.SeatCount || req.BillingMethod != tt.want.BillingMethod {
+				t.Errorf("got %+v, want name %q, currency %q, interval %q, seats %d, method %q", req,
+					tt.want.Name, tt.want.Currency, tt.want.BillingInterval, tt.want.SeatCount, tt.want.BillingMethod)
+			}
+		})
+	}
+}
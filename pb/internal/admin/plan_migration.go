@@ -0,0 +1,422 @@
+package admin
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+	"pocketbase/internal/payment"
+	"pocketbase/internal/subscription"
+)
+
+// Plan migration effective modes
+const (
+	MigrationImmediate = "immediate"  // switch now, with Stripe prorations
+	MigrationPeriodEnd = "period_end" // keep the current price until the billing period ends
+)
+
+// Plan migration item outcomes
+const (
+	MigrationStatusMigrated  = "migrated"
+	MigrationStatusScheduled = "scheduled"
+	MigrationStatusPlanned   = "planned" // dry run
+	MigrationStatusSkipped   = "skipped"
+	MigrationStatusFailed    = "failed"
+)
+
+// Plan migration run states, kept in plan_migrations
+const (
+	MigrationRunRunning   = "running"
+	MigrationRunCompleted = "completed"
+	MigrationRunFailed    = "failed"
+)
+
+// PlanMigrationRequest represents an admin request to move a cohort of subscribers between plans
+type PlanMigrationRequest struct {
+	SourcePlanID   string `json:"source_plan_id"`
+	TargetPlanID   string `json:"target_plan_id"`
+	Mode           string `json:"mode"`
+	DryRun         bool   `json:"dry_run"`
+	BatchSize      int    `json:"batch_size"`
+	RequestsPerSec int    `json:"requests_per_second"`
+}
+
+// Validate checks the migration request and fills in defaults
+func (r *PlanMigrationRequest) Validate() error {
+	if r.SourcePlanID == "" || r.TargetPlanID == "" {
+		return fmt.Errorf("source_plan_id and target_plan_id are required")
+	}
+	if r.SourcePlanID == r.TargetPlanID {
+		return fmt.Errorf("source and target plans must differ")
+	}
+	if r.Mode == "" {
+		r.Mode = MigrationPeriodEnd
+	}
+	if r.Mode != MigrationImmediate && r.Mode != MigrationPeriodEnd {
+		return fmt.Errorf("mode must be '%s' or '%s'", MigrationImmediate, MigrationPeriodEnd)
+	}
+	if r.BatchSize <= 0 {
+		r.BatchSize = 25
+	}
+	if r.BatchSize > 100 {
+		r.BatchSize = 100
+	}
+	// Stay well below Stripe's API rate limit (25 req/s in test mode, 100 req/s live)
+	if r.RequestsPerSec <= 0 {
+		r.RequestsPerSec = 5
+	}
+	if r.RequestsPerSec > 20 {
+		r.RequestsPerSec = 20
+	}
+	return nil
+}
+
+// PlanMigrationItem records the outcome for one subscription
+type PlanMigrationItem struct {
+	SubscriptionID string `json:"subscription_id"`
+	UserID         string `json:"user_id"`
+	Status         string `json:"status"`
+	EffectiveAt    string `json:"effective_at,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// PlanMigrationReport summarizes a migration run
+type PlanMigrationReport struct {
+	SourcePlanID string              `json:"source_plan_id"`
+	TargetPlanID string              `json:"target_plan_id"`
+	Mode         string              `json:"mode"`
+	DryRun       bool                `json:"dry_run"`
+	Total        int                 `json:"total"`
+	Migrated     int                 `json:"migrated"`
+	Scheduled    int                 `json:"scheduled"`
+	Skipped      int                 `json:"skipped"`
+	Failed       int                 `json:"failed"`
+	Batches      int                 `json:"batches"`
+	StartedAt    time.Time           `json:"started_at"`
+	FinishedAt   time.Time           `json:"finished_at"`
+	Items        []PlanMigrationItem `json:"items"`
+}
+
+func (r *PlanMigrationReport) add(item PlanMigrationItem) {
+	r.Items = append(r.Items, item)
+	switch item.Status {
+	case MigrationStatusMigrated:
+		r.Migrated++
+	case MigrationStatusScheduled:
+		r.Scheduled++
+	case MigrationStatusSkipped:
+		r.Skipped++
+	case MigrationStatusFailed:
+		r.Failed++
+	}
+}
+
+// PlanMigration is a migration run as reported by the status endpoint
+type PlanMigration struct {
+	ID         string               `json:"id"`
+	Status     string               `json:"status"`
+	Error      string               `json:"error,omitempty"`
+	Report     *PlanMigrationReport `json:"report"`
+	StatusURL  string               `json:"status_url"`
+	ActorEmail string               `json:"actor_email,omitempty"`
+	Created    string               `json:"created"`
+	Updated    string               `json:"updated"`
+}
+
+// MigratePlansHandler moves every subscriber of one plan to another plan (superusers only)
+// The request is validated and the cohort loaded up front, then the run continues in the
+// background: it answers 202 with the run, whose progress and report are read back from
+// GET /api/admin/migrate-plans/{id}. Stripe subscriptions are updated in batches with a fixed
+// request rate; failures are reported per subscription and never abort the rest of the cohort.
+// Every run, including dry runs, is audited once it finishes.
+func MigratePlansHandler(e *core.RequestEvent, app core.App, paymentService *payment.Service) error {
+	var req PlanMigrationRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if !req.DryRun && paymentService == nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+	}
+
+	if _, err := app.FindRecordById("subscription_plans", req.SourcePlanID); err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Source plan not found"})
+	}
+	targetPlan, err := app.FindRecordById("subscription_plans", req.TargetPlanID)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Target plan not found"})
+	}
+	if targetPlan.GetBool("is_custom") {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Cannot migrate a cohort onto a custom plan"})
+	}
+	if targetPlan.GetString("provider_price_id") == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Target plan has no provider price"})
+	}
+	if !req.DryRun {
+		if running, err := app.FindFirstRecordByFilter("plan_migrations", "status = {:status} && dry_run = false && source_plan_id = {:plan}",
+			map[string]any{"status": MigrationRunRunning, "plan": req.SourcePlanID}); err == nil {
+			return e.JSON(http.StatusConflict, map[string]string{
+				"error": "A migration of this plan is already running",
+				"id":    running.Id,
+			})
+		}
+	}
+
+	// Load the whole cohort up front - immediate migrations change plan_id, which would shift paging offsets
+	subs, err := app.FindRecordsByFilter("current_user_subscriptions", "plan_id = {:plan}", "+created", 0, 0, map[string]any{
+		"plan": req.SourcePlanID,
+	})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load subscriptions"})
+	}
+
+	logger := logging.FromRequest(e)
+	run, err := startMigrationRun(app, req, len(subs), e.Auth)
+	if err != nil {
+		logger.Error("Failed to start plan migration", "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start plan migration"})
+	}
+	logger = logger.With("migration_id", run.Id, "source_plan_id", req.SourcePlanID, "target_plan_id", req.TargetPlanID)
+	logger.Info("Plan migration started", "mode", req.Mode, "dry_run", req.DryRun, "total", len(subs))
+
+	go runPlanMigration(app, paymentService, run, req, targetPlan, subs, e.Auth, logger)
+
+	return e.JSON(http.StatusAccepted, migrationResponse(run))
+}
+
+// GetPlanMigrationHandler returns a plan migration run with its report so far (superusers only)
+// GET /api/admin/migrate-plans/{id}
+func GetPlanMigrationHandler(e *core.RequestEvent, app core.App) error {
+	run, err := app.FindRecordById("plan_migrations", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan migration not found"})
+	}
+	return e.JSON(http.StatusOK, migrationResponse(run))
+}
+
+// startMigrationRun records a run as running, with an empty report for the cohort
+func startMigrationRun(app core.App, req PlanMigrationRequest, total int, actor *core.Record) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("plan_migrations")
+	if err != nil {
+		return nil, err
+	}
+	run := core.NewRecord(collection)
+	run.Set("source_plan_id", req.SourcePlanID)
+	run.Set("target_plan_id", req.TargetPlanID)
+	run.Set("mode", req.Mode)
+	run.Set("dry_run", req.DryRun)
+	run.Set("status", MigrationRunRunning)
+	run.Set("report", newMigrationReport(req, total))
+	if actor != nil {
+		run.Set("actor_id", actor.Id)
+		run.Set("actor_email", actor.GetString("email"))
+	}
+	return run, app.Save(run)
+}
+
+// runPlanMigration migrates the cohort, saving the report after every batch, then audits the
+// run and marks it completed
+func runPlanMigration(app core.App, paymentService *payment.Service, run *core.Record, req PlanMigrationRequest, targetPlan *core.Record, subs []*core.Record, actor *core.Record, logger *slog.Logger) {
+	report := migratePlans(app, logger, paymentService, req, targetPlan, subs, func(report *PlanMigrationReport) {
+		run.Set("report", report)
+		if err := app.Save(run); err != nil {
+			logger.Warn("Failed to save plan migration progress", "error", err)
+		}
+	})
+
+	recordAuditAs(app, actor, "plan_migration", req.DryRun, map[string]interface{}{
+		"migration_id": run.Id,
+		"request":      req,
+		"report": map[string]interface{}{
+			"total":     report.Total,
+			"migrated":  report.Migrated,
+			"scheduled": report.Scheduled,
+			"skipped":   report.Skipped,
+			"failed":    report.Failed,
+		},
+		// Per-user outcomes feed each user's plan change log
+		"items": report.Items,
+	})
+
+	run.Set("report", report)
+	run.Set("status", MigrationRunCompleted)
+	if err := app.Save(run); err != nil {
+		logger.Error("Failed to save plan migration report", "error", err)
+		return
+	}
+	logger.Info("Plan migration finished", "total", report.Total, "migrated", report.Migrated,
+		"scheduled", report.Scheduled, "skipped", report.Skipped, "failed", report.Failed)
+}
+
+// FailInterruptedMigrations fails the plan migrations a restart cut off. They aren't resumed:
+// their report lists the subscriptions already handled, and a new run picks up the ones still
+// on the source plan
+func FailInterruptedMigrations(app core.App) {
+	runs, err := app.FindRecordsByFilter("plan_migrations", "status = {:status}", "", 0, 0,
+		map[string]any{"status": MigrationRunRunning})
+	if err != nil {
+		slog.Error("Failed to load interrupted plan migrations", "error", err)
+		return
+	}
+	for _, run := range runs {
+		run.Set("status", MigrationRunFailed)
+		run.Set("error", "interrupted by a restart")
+		if err := app.Save(run); err != nil {
+			slog.Error("Failed to fail interrupted plan migration", "migration_id", run.Id, "error", err)
+			continue
+		}
+		slog.Warn("Plan migration interrupted by a restart", "migration_id", run.Id,
+			"source_plan_id", run.GetString("source_plan_id"), "target_plan_id", run.GetString("target_plan_id"))
+	}
+}
+
+func migrationResponse(run *core.Record) PlanMigration {
+	report := &PlanMigrationReport{}
+	_ = run.UnmarshalJSONField("report", report)
+	return PlanMigration{
+		ID:         run.Id,
+		Status:     run.GetString("status"),
+		Error:      run.GetString("error"),
+		Report:     report,
+		StatusURL:  "/api/admin/migrate-plans/" + run.Id,
+		ActorEmail: run.GetString("actor_email"),
+		Created:    run.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+		Updated:    run.GetDateTime("updated").Time().UTC().Format(time.RFC3339),
+	}
+}
+
+func newMigrationReport(req PlanMigrationRequest, total int) *PlanMigrationReport {
+	return &PlanMigrationReport{
+		SourcePlanID: req.SourcePlanID,
+		TargetPlanID: req.TargetPlanID,
+		Mode:         req.Mode,
+		DryRun:       req.DryRun,
+		Total:        total,
+		StartedAt:    time.Now().UTC(),
+		Items:        []PlanMigrationItem{},
+	}
+}
+
+// migratePlans processes the cohort in batches, pacing provider calls with a ticker. progress,
+// if set, is called with the report after each batch
+func migratePlans(app core.App, logger *slog.Logger, paymentService *payment.Service, req PlanMigrationRequest, targetPlan *core.Record, subs []*core.Record, progress func(*PlanMigrationReport)) *PlanMigrationReport {
+	report := newMigrationReport(req, len(subs))
+
+	limiter := time.NewTicker(time.Second / time.Duration(req.RequestsPerSec))
+	defer limiter.Stop()
+
+	for start := 0; start < len(subs); start += req.BatchSize {
+		end := start + req.BatchSize
+		if end > len(subs) {
+			end = len(subs)
+		}
+		report.Batches++
+
+		for _, sub := range subs[start:end] {
+			item := PlanMigrationItem{
+				SubscriptionID: sub.Id,
+				UserID:         sub.GetString("user_id"),
+			}
+
+			if reason := migrationSkipReason(sub); reason != "" {
+				item.Status = MigrationStatusSkipped
+				item.Reason = reason
+				report.add(item)
+				continue
+			}
+
+			if req.DryRun {
+				item.Status = MigrationStatusPlanned
+				item.EffectiveAt = effectiveAt(sub, req.Mode)
+				report.add(item)
+				continue
+			}
+
+			<-limiter.C
+			migrateSubscription(app, logger, paymentService, req.Mode, targetPlan, sub, &item)
+			report.add(item)
+		}
+
+		logger.Info("Plan migration batch done", "batch", report.Batches, "done", end, "total", len(subs))
+		if progress != nil {
+			progress(report)
+		}
+	}
+
+	report.FinishedAt = time.Now().UTC()
+	return report
+}
+
+// migrateSubscription updates one Stripe subscription and, for immediate changes, the local record
+func migrateSubscription(app core.App, logger *slog.Logger, paymentService *payment.Service, mode string, targetPlan *core.Record, sub *core.Record, item *PlanMigrationItem) {
+	providerSubID := sub.GetString("provider_subscription_id")
+	priceID := targetPlan.GetString("provider_price_id")
+
+	if mode == MigrationPeriodEnd {
+		if _, err := paymentService.SchedulePlanChange(providerSubID, priceID); err != nil {
+			item.Status = MigrationStatusFailed
+			item.Reason = err.Error()
+			return
+		}
+		// The local plan is synced by the subscription webhook when the new period starts
+		item.Status = MigrationStatusScheduled
+		item.EffectiveAt = effectiveAt(sub, mode)
+		return
+	}
+
+	if _, err := paymentService.ChangeSubscriptionPlan(providerSubID, priceID, "create_prorations"); err != nil {
+		item.Status = MigrationStatusFailed
+		item.Reason = err.Error()
+		return
+	}
+
+	// Keep the old plan in the user's history before switching the record over
+	if _, err := subscription.NewRepository(app).MoveSubscriptionToHistory(sub, subscription.HistoryParams{
+		Reason:      "plan_change",
+		InitiatedBy: subscription.InitiatedByAdmin,
+		NewPlanID:   targetPlan.Id,
+	}); err != nil {
+		logger.Warn("Failed to record plan migration in history", "subscription_id", sub.Id, "error", err)
+	}
+
+	sub.Set("plan_id", targetPlan.Id)
+	sub.Set("provider_price_id", priceID)
+	if err := app.Save(sub); err != nil {
+		// Stripe already changed - the webhook will eventually sync the local record
+		logger.Warn("Stripe migrated the subscription but the local update failed", "subscription_id", sub.Id, "error", err)
+	}
+
+	item.Status = MigrationStatusMigrated
+	item.EffectiveAt = effectiveAt(sub, mode)
+}
+
+// migrationSkipReason returns why a subscription can't be migrated through Stripe, if anything
+func migrationSkipReason(sub *core.Record) string {
+	if sub.GetString("payment_provider") != "stripe" {
+		return fmt.Sprintf("not billed through Stripe (%s)", sub.GetString("payment_provider"))
+	}
+	if sub.GetString("provider_subscription_id") == "" {
+		return "no provider subscription"
+	}
+	if sub.GetString("status") == "cancelled" {
+		return "subscription is cancelled"
+	}
+	if !sub.GetDateTime("canceled_at").IsZero() {
+		return "subscription is scheduled for cancellation"
+	}
+	return ""
+}
+
+func effectiveAt(sub *core.Record, mode string) string {
+	if mode == MigrationPeriodEnd {
+		return sub.GetDateTime("current_period_end").String()
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
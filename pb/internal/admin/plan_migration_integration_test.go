@@ -0,0 +1,199 @@
+//go:build integration
+
+package admin_test
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	"pocketbase/internal/admin"
+	"pocketbase/internal/payment"
+	"pocketbase/internal/routes"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+// fakeProvider records the plan changes and customer metadata updates admin tools ask Stripe for
+type fakeProvider struct {
+	payment.Provider
+	mu       sync.Mutex
+	changed  []string
+	failing  string // subscription whose change fails
+	metadata map[string]map[string]string
+	down     bool // metadata updates fail
+}
+
+func (p *fakeProvider) change(subscriptionID string) (*payment.Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if subscriptionID == p.failing {
+		return nil, errors.New("card declined")
+	}
+	p.changed = append(p.changed, subscriptionID)
+	return &payment.Subscription{ID: subscriptionID}, nil
+}
+
+func (p *fakeProvider) ChangeSubscriptionPlan(subscriptionID, _, _ string) (*payment.Subscription, error) {
+	return p.change(subscriptionID)
+}
+
+func (p *fakeProvider) SchedulePlanChange(subscriptionID, _ string) (*payment.Subscription, error) {
+	return p.change(subscriptionID)
+}
+
+func (p *fakeProvider) UpdateCustomerMetadata(customerID string, metadata map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.down {
+		return errors.New("stripe unavailable")
+	}
+	if p.metadata == nil {
+		p.metadata = map[string]map[string]string{}
+	}
+	p.metadata[customerID] = metadata
+	return nil
+}
+
+// subscribe puts a new user on plan, billed through Stripe as subscriptionID (none when empty)
+func subscribe(t *testing.T, app core.App, email string, plan *core.Record, subscriptionID string) *core.Record {
+	t.Helper()
+	user := testharness.CreateUser(t, app, email)
+	service := subscription.NewService(subscription.NewRepository(app))
+	sub, err := service.AssignPlan(user.Id, plan.Id, time.Now().AddDate(0, 1, 0), "stripe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub.Set("provider_subscription_id", subscriptionID)
+	if err := app.Save(sub); err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+// awaitMigration polls the status endpoint until the run is no longer running
+func awaitMigration(t *testing.T, server *testharness.Server, token, id string) map[string]any {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		run := server.Do(t, http.MethodGet, "/api/admin/migrate-plans/"+id, token, nil).Expect(t, http.StatusOK).JSON(t)
+		if run["status"] != admin.MigrationRunRunning {
+			return run
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("migration %s still running: %v", id, run)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func serveAdmin(t *testing.T, app *tests.TestApp, paymentService *payment.Service) *testharness.Server {
+	t.Helper()
+	subscriptionService := subscription.NewService(subscription.NewRepository(app))
+	return testharness.Serve(t, app, func(r *routes.Registry) {
+		admin.RegisterRoutes(r, paymentService, subscriptionService)
+	})
+}
+
+func TestPlanMigrationDryRun(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	token := testharness.AuthToken(t, testharness.CreateSuperuser(t, app, "ops@test.com"))
+	server := serveAdmin(t, app, nil)
+
+	subscribe(t, app, "one@test.com", plans["basic"], "sub_one")
+	subscribe(t, app, "two@test.com", plans["basic"], "")
+
+	request := map[string]any{"source_plan_id": plans["basic"].Id, "target_plan_id": plans["pro"].Id, "dry_run": true}
+	server.Do(t, http.MethodPost, "/api/admin/migrate-plans", "", request).Expect(t, http.StatusUnauthorized)
+	started := server.Do(t, http.MethodPost, "/api/admin/migrate-plans", token, request).Expect(t, http.StatusAccepted).JSON(t)
+	id, _ := started["id"].(string)
+	if started["status_url"] != "/api/admin/migrate-plans/"+id {
+		t.Errorf("status_url = %v", started["status_url"])
+	}
+
+	run := awaitMigration(t, server, token, id)
+	report, _ := run["report"].(map[string]any)
+	if run["status"] != admin.MigrationRunCompleted || report["total"] != 2.0 || report["skipped"] != 1.0 || report["batches"] != 1.0 {
+		t.Fatalf("run = %v", run)
+	}
+	if items, _ := report["items"].([]any); len(items) != 2 || items[0].(map[string]any)["status"] != admin.MigrationStatusPlanned {
+		t.Errorf("items = %v", report["items"])
+	}
+	if _, err := app.FindFirstRecordByFilter("admin_audit_logs", "action = 'plan_migration' && dry_run = true"); err != nil {
+		t.Errorf("dry run not audited: %v", err)
+	}
+
+	// Live runs need the payment service
+	request["dry_run"] = false
+	server.Do(t, http.MethodPost, "/api/admin/migrate-plans", token, request).Expect(t, http.StatusServiceUnavailable)
+	server.Do(t, http.MethodGet, "/api/admin/migrate-plans/missing", token, nil).Expect(t, http.StatusNotFound)
+}
+
+func TestPlanMigrationImmediate(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	token := testharness.AuthToken(t, testharness.CreateSuperuser(t, app, "ops@test.com"))
+	provider := &fakeProvider{failing: "sub_declined"}
+	server := serveAdmin(t, app, payment.NewService(provider, payment.Config{}))
+
+	moved := subscribe(t, app, "moved@test.com", plans["basic"], "sub_moved")
+	declined := subscribe(t, app, "declined@test.com", plans["basic"], "sub_declined")
+
+	request := map[string]any{"source_plan_id": plans["basic"].Id, "target_plan_id": plans["pro"].Id, "mode": admin.MigrationImmediate, "requests_per_second": 20}
+	started := server.Do(t, http.MethodPost, "/api/admin/migrate-plans", token, request).Expect(t, http.StatusAccepted).JSON(t)
+	run := awaitMigration(t, server, token, started["id"].(string))
+
+	report, _ := run["report"].(map[string]any)
+	if report["migrated"] != 1.0 || report["failed"] != 1.0 {
+		t.Fatalf("report = %v", report)
+	}
+	provider.mu.Lock()
+	if len(provider.changed) != 1 || provider.changed[0] != "sub_moved" {
+		t.Errorf("provider changes = %v", provider.changed)
+	}
+	provider.mu.Unlock()
+
+	for sub, wantPlan := range map[*core.Record]string{moved: plans["pro"].Id, declined: plans["basic"].Id} {
+		reloaded, err := app.FindRecordById("current_user_subscriptions", sub.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reloaded.GetString("plan_id"); got != wantPlan {
+			t.Errorf("subscription %s on plan %s, want %s", sub.GetString("provider_subscription_id"), got, wantPlan)
+		}
+	}
+	if _, err := app.FindFirstRecordByFilter("admin_audit_logs", "action = 'plan_migration' && dry_run = false"); err != nil {
+		t.Errorf("migration not audited: %v", err)
+	}
+}
+
+func TestFailInterruptedMigrations(t *testing.T) {
+	app := testharness.New(t)
+	collection, err := app.FindCollectionByNameOrId("plan_migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := core.NewRecord(collection)
+	run.Set("source_plan_id", "a")
+	run.Set("target_plan_id", "b")
+	run.Set("status", admin.MigrationRunRunning)
+	if err := app.Save(run); err != nil {
+		t.Fatal(err)
+	}
+
+	admin.FailInterruptedMigrations(app)
+
+	reloaded, err := app.FindRecordById("plan_migrations", run.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.GetString("status") != admin.MigrationRunFailed || reloaded.GetString("error") == "" {
+		t.Errorf("interrupted run = %s (%s), want failed with a reason", reloaded.GetString("status"), reloaded.GetString("error"))
+	}
+}
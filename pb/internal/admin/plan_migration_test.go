@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestPlanMigrationRequestValidate(t *testing.T) {
+	tests := []struct {
+		name                string
+		req                 PlanMigrationRequest
+		wantErr             bool
+		wantMode            string
+		wantBatch, wantRate int
+	}{
+		{"defaults", PlanMigrationRequest{SourcePlanID: "a", TargetPlanID: "b"}, false, MigrationPeriodEnd, 25, 5},
+		{"immediate", PlanMigrationRequest{SourcePlanID: "a", TargetPlanID: "b", Mode: MigrationImmediate, BatchSize: 10, RequestsPerSec: 2}, false, MigrationImmediate, 10, 2},
+		{"clamped", PlanMigrationRequest{SourcePlanID: "a", TargetPlanID: "b", BatchSize: 500, RequestsPerSec: 100}, false, MigrationPeriodEnd, 100, 20},
+		{"missing source", PlanMigrationRequest{TargetPlanID: "b"}, true, "", 0, 0},
+		{"missing target", PlanMigrationRequest{SourcePlanID: "a"}, true, "", 0, 0},
+		{"same plan", PlanMigrationRequest{SourcePlanID: "a", TargetPlanID: "a"}, true, "", 0, 0},
+		{"unknown mode", PlanMigrationRequest{SourcePlanID: "a", TargetPlanID: "b", Mode: "tomorrow"}, true, "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.req.Mode != tt.wantMode || tt.req.BatchSize != tt.wantBatch || tt.req.RequestsPerSec != tt.wantRate {
+				t.Errorf("got mode %q, batch %d, rate %d; want %q, %d, %d",
+					tt.req.Mode, tt.req.BatchSize, tt.req.RequestsPerSec, tt.wantMode, tt.wantBatch, tt.wantRate)
+			}
+		})
+	}
+}
+
+func TestMigrationSkipReason(t *testing.T) {
+	collection := core.NewBaseCollection("current_user_subscriptions")
+	collection.Fields.Add(
+		&core.TextField{Name: "payment_provider"},
+		&core.TextField{Name: "provider_subscription_id"},
+		&core.TextField{Name: "status"},
+		&core.DateField{Name: "canceled_at"},
+	)
+	sub := func(provider, subscriptionID, status string, canceled bool) *core.Record {
+		record := core.NewRecord(collection)
+		record.Set("payment_provider", provider)
+		record.Set("provider_subscription_id", subscriptionID)
+		record.Set("status", status)
+		if canceled {
+			record.Set("canceled_at", time.Now())
+		}
+		return record
+	}
+
+	tests := map[string]struct {
+		sub  *core.Record
+		skip bool
+	}{
+		"active stripe":        {sub("stripe", "sub_1", "active", false), false},
+		"invoiced":             {sub("invoice", "", "active", false), true},
+		"no subscription id":   {sub("stripe", "", "active", false), true},
+		"cancelled":            {sub("stripe", "sub_1", "cancelled", false), true},
+		"cancelling at period": {sub("stripe", "sub_1", "active", true), true},
+	}
+	for name, tt := range tests {
+		if reason := migrationSkipReason(tt.sub); (reason != "") != tt.skip {
+			t.Errorf("%s: reason = %q, want skip %v", name, reason, tt.skip)
+		}
+	}
+}
+
+func TestPlanMigrationReportAdd(t *testing.T) {
+	report := &PlanMigrationReport{}
+	for _, status := range []string{MigrationStatusMigrated, MigrationStatusScheduled, MigrationStatusScheduled,
+		MigrationStatusSkipped, MigrationStatusFailed, MigrationStatusPlanned} {
+		report.add(PlanMigrationItem{Status: status})
+	}
+	if report.Migrated != 1 || report.Scheduled != 2 || report.Skipped != 1 || report.Failed != 1 || len(report.Items) != 6 {
+		t.Errorf("report = %+v", report)
+	}
+}
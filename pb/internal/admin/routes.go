@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/payment"
+	"pocketbase/internal/routes"
+	"pocketbase/internal/subscription"
+)
+
+// RegisterRoutes adds the admin routes (superusers only)
+func RegisterRoutes(r *routes.Registry, paymentService *payment.Service, subscriptionService subscription.Service) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/plans/custom", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return CreateCustomPlanHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/plans/custom", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ListCustomPlansHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/plans/custom/{id}/assign", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AssignCustomPlanHandler(e, app, subscriptionService)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/accounts/transfer", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return TransferAccountHandler(e, app, paymentService)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/migrate-plans", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return MigratePlansHandler(e, app, paymentService)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/migrate-plans/{id}", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return GetPlanMigrationHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/users/{id}/deactivate", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return DeactivateUserHandler(e, app, paymentService)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/users/{id}/restore", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return RestoreUserHandler(e, app, paymentService)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/subscriptions/{user}", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return GetSupportSubscriptionHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/subscriptions/{user}/history", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return SubscriptionHistoryHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/subscriptions/{user}/resync", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ResyncSubscriptionHandler(e, app, subscriptionService)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/subscriptions/{user}/extend", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ExtendSubscriptionHandler(e, app, subscriptionService)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/subscriptions/{user}/override", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return GrantPlanOverrideHandler(e, app, subscriptionService)
+		}},
+		routes.Route{Method: http.MethodDelete, Path: "/api/admin/subscriptions/{user}/override", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ClearPlanOverrideHandler(e, app, subscriptionService)
+		}},
+	)
+}
@@ -0,0 +1,43 @@
+package admin
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the admin handlers read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "admin",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "seat_count", Type: "number"},
+					{Name: "payment_provider", Type: "select", Values: []string{"stripe", "invoice"}},
+				},
+			},
+			{
+				Collection: "admin_audit_logs",
+				Fields: []schemacheck.Field{
+					{Name: "action", Type: "text"},
+					{Name: "actor_id", Type: "text"},
+					{Name: "actor_email", Type: "text"},
+					{Name: "details", Type: "json"},
+					{Name: "dry_run", Type: "bool"},
+				},
+			},
+			{
+				Collection: "plan_migrations",
+				Fields: []schemacheck.Field{
+					{Name: "source_plan_id", Type: "text"},
+					{Name: "target_plan_id", Type: "text"},
+					{Name: "mode", Type: "text"},
+					{Name: "dry_run", Type: "bool"},
+					{Name: "status", Type: "select", Values: []string{"running", "completed", "failed"}},
+					{Name: "report", Type: "json"},
+					{Name: "error", Type: "text"},
+					{Name: "actor_id", Type: "text"},
+					{Name: "actor_email", Type: "text"},
+				},
+			},
+		},
+	}
+}
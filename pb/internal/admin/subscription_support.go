@@ -0,0 +1,294 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// maxSupportDays caps how far support can extend a period or grant an override in one go
+const maxSupportDays = 365
+
+// SupportSubscription is a user's subscription as support staff see it
+type SupportSubscription struct {
+	UserID             string                       `json:"user_id"`
+	Email              string                       `json:"email"`
+	ProviderCustomerID string                       `json:"provider_customer_id,omitempty"`
+	Subscription       *core.Record                 `json:"subscription"`
+	Plan               *subscription.PlanRef        `json:"plan,omitempty"`
+	EffectivePlan      *subscription.PlanRef        `json:"effective_plan,omitempty"` // differs from plan while an override is active
+	Override           *subscription.PlanOverride   `json:"override,omitempty"`
+	Subscribed         bool                         `json:"subscribed"`
+	PaymentWarning     *subscription.PaymentWarning `json:"payment_warning,omitempty"`
+}
+
+// findSupportUser resolves the {user} path value, which can be a user id or an email
+func findSupportUser(app core.App, idOrEmail string) (*core.Record, error) {
+	if strings.Contains(idOrEmail, "@") {
+		return app.FindAuthRecordByEmail("users", idOrEmail)
+	}
+	return app.FindRecordById("users", idOrEmail)
+}
+
+func supportPlanRef(app core.App, planID string) *subscription.PlanRef {
+	if planID == "" {
+		return nil
+	}
+	ref := &subscription.PlanRef{ID: planID}
+	if plan, err := app.FindRecordById("subscription_plans", planID); err == nil {
+		ref.Name = plan.GetString("name")
+	}
+	return ref
+}
+
+func supportSubscription(app core.App, user *core.Record) SupportSubscription {
+	result := SupportSubscription{UserID: user.Id, Email: user.GetString("email")}
+	if customer, err := app.FindFirstRecordByFilter("payment_customers", "user_id = {:user}", map[string]any{"user": user.Id}); err == nil {
+		result.ProviderCustomerID = customer.GetString("provider_customer_id")
+	}
+
+	sub, err := subscription.NewRepository(app).FindSubscription(subscription.SubscriptionQuery{UserID: user.Id})
+	if err != nil {
+		return result
+	}
+
+	now := time.Now()
+	access := subscription.AccessForSubscription(sub, now)
+	result.Subscription = sub
+	result.Plan = supportPlanRef(app, sub.GetString("plan_id"))
+	result.EffectivePlan = supportPlanRef(app, subscription.EffectivePlanID(sub, now))
+	result.Override = subscription.ActivePlanOverride(sub, now)
+	result.Subscribed = access.Subscribed
+	result.PaymentWarning = access.Warning
+	return result
+}
+
+// supportAction loads the user for a support action and makes sure they have a subscription
+func supportAction(e *core.RequestEvent, app core.App) (*core.Record, bool, error) {
+	user, err := findSupportUser(app, e.Request.PathValue("user"))
+	if err != nil {
+		return nil, false, e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	if _, err := subscription.NewRepository(app).FindSubscription(subscription.SubscriptionQuery{UserID: user.Id}); err != nil {
+		return nil, false, e.JSON(http.StatusNotFound, map[string]string{"error": "User has no subscription"})
+	}
+	return user, true, nil
+}
+
+// supportError maps a subscription service error to a response
+func supportError(e *core.RequestEvent, action string, err error) error {
+	var validationErr subscription.ValidationError
+	if errors.As(err, &validationErr) {
+		message := validationErr.Message
+		if validationErr.Field != "" {
+			message = validationErr.Field + " " + message
+		}
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": message})
+	}
+	var ruleErr subscription.BusinessRuleError
+	if errors.As(err, &ruleErr) {
+		return e.JSON(http.StatusConflict, map[string]string{"error": ruleErr.Message})
+	}
+	return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to %s: %v", action, err)})
+}
+
+// GetSupportSubscriptionHandler looks up a user's subscription by user id or email (superusers only)
+// GET /api/admin/subscriptions/{user}
+func GetSupportSubscriptionHandler(e *core.RequestEvent, app core.App) error {
+	user, err := findSupportUser(app, e.Request.PathValue("user"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+	return e.JSON(http.StatusOK, supportSubscription(app, user))
+}
+
+// ResyncSubscriptionHandler overwrites the local subscription with Stripe's state (superusers only)
+// POST /api/admin/subscriptions/{user}/resync
+func ResyncSubscriptionHandler(e *core.RequestEvent, app core.App, subscriptionService subscription.Service) error {
+	user, ok, err := supportAction(e, app)
+	if !ok {
+		return err
+	}
+
+	before := supportSubscription(app, user)
+	if _, err := subscriptionService.ResyncFromProvider(user.Id); err != nil {
+		return supportError(e, "resync subscription", err)
+	}
+	after := supportSubscription(app, user)
+
+	recordAudit(app, e, "subscription_resynced", false, map[string]interface{}{
+		"user_id":       user.Id,
+		"plan_before":   before.Plan,
+		"plan_after":    after.Plan,
+		"status_before": statusOf(before.Subscription),
+		"status_after":  statusOf(after.Subscription),
+	})
+	return e.JSON(http.StatusOK, after)
+}
+
+// ExtendSubscriptionRequest is the payload for extending the current period
+type ExtendSubscriptionRequest struct {
+	Days   int    `json:"days"`
+	Reason string `json:"reason"`
+}
+
+// ExtendSubscriptionHandler extends the user's current period at no charge (superusers only)
+// POST /api/admin/subscriptions/{user}/extend
+func ExtendSubscriptionHandler(e *core.RequestEvent, app core.App, subscriptionService subscription.Service) error {
+	var req ExtendSubscriptionRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Days <= 0 || req.Days > maxSupportDays {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("days must be between 1 and %d", maxSupportDays)})
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "reason is required"})
+	}
+
+	user, ok, err := supportAction(e, app)
+	if !ok {
+		return err
+	}
+
+	before := supportSubscription(app, user)
+	periodEnd := before.Subscription.GetDateTime("current_period_end").Time()
+	until := periodEnd.AddDate(0, 0, req.Days)
+	if _, err := subscriptionService.ExtendPeriod(user.Id, until); err != nil {
+		return supportError(e, "extend subscription", err)
+	}
+
+	recordAudit(app, e, "subscription_period_extended", false, map[string]interface{}{
+		"user_id":           user.Id,
+		"days":              req.Days,
+		"reason":            req.Reason,
+		"period_end_before": periodEnd.UTC().Format(time.RFC3339),
+		"period_end_after":  until.UTC().Format(time.RFC3339),
+	})
+	return e.JSON(http.StatusOK, supportSubscription(app, user))
+}
+
+// PlanOverrideRequest is the payload for granting a temporary plan override
+type PlanOverrideRequest struct {
+	PlanID string `json:"plan_id"`
+	Days   int    `json:"days"`
+	Reason string `json:"reason"`
+}
+
+// GrantPlanOverrideHandler gives the user another plan's limits for a number of days, without
+// touching billing. Granting again replaces the current override (superusers only)
+// POST /api/admin/subscriptions/{user}/override
+func GrantPlanOverrideHandler(e *core.RequestEvent, app core.App, subscriptionService subscription.Service) error {
+	var req PlanOverrideRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.PlanID == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "plan_id is required"})
+	}
+	if req.Days <= 0 || req.Days > maxSupportDays {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("days must be between 1 and %d", maxSupportDays)})
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "reason is required"})
+	}
+
+	user, ok, err := supportAction(e, app)
+	if !ok {
+		return err
+	}
+
+	override := subscription.PlanOverride{PlanID: req.PlanID, ExpiresAt: time.Now().AddDate(0, 0, req.Days), Reason: req.Reason}
+	if _, err := subscriptionService.SetPlanOverride(user.Id, override); err != nil {
+		return supportError(e, "grant plan override", err)
+	}
+
+	recordAudit(app, e, "subscription_override_granted", false, map[string]interface{}{
+		"user_id":    user.Id,
+		"plan_id":    req.PlanID,
+		"expires_at": override.ExpiresAt.UTC().Format(time.RFC3339),
+		"reason":     req.Reason,
+	})
+	return e.JSON(http.StatusOK, supportSubscription(app, user))
+}
+
+// ClearPlanOverrideHandler ends the user's plan override early (superusers only)
+// DELETE /api/admin/subscriptions/{user}/override
+func ClearPlanOverrideHandler(e *core.RequestEvent, app core.App, subscriptionService subscription.Service) error {
+	user, ok, err := supportAction(e, app)
+	if !ok {
+		return err
+	}
+
+	before := supportSubscription(app, user)
+	if _, err := subscriptionService.SetPlanOverride(user.Id, subscription.PlanOverride{}); err != nil {
+		return supportError(e, "clear plan override", err)
+	}
+
+	recordAudit(app, e, "subscription_override_cleared", false, map[string]interface{}{
+		"user_id":  user.Id,
+		"override": before.Override,
+	})
+	return e.JSON(http.StatusOK, supportSubscription(app, user))
+}
+
+// SupportHistoryEntry is a support action taken on the user's subscription
+type SupportHistoryEntry struct {
+	Action     string                 `json:"action"`
+	ActorEmail string                 `json:"actor_email"`
+	Details    map[string]interface{} `json:"details"`
+	Created    string                 `json:"created"`
+}
+
+// SubscriptionHistoryHandler lists the user's plan changes and the support actions taken on
+// their subscription, newest first (superusers only)
+// GET /api/admin/subscriptions/{user}/history
+func SubscriptionHistoryHandler(e *core.RequestEvent, app core.App) error {
+	user, err := findSupportUser(app, e.Request.PathValue("user"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	changes, err := subscription.UserPlanChanges(app, user.Id)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load plan changes"})
+	}
+
+	// details is JSON text, so the user id match is checked again below
+	records, err := app.FindRecordsByFilter("admin_audit_logs",
+		"action ~ 'subscription_' && details ~ {:user}", "-created", 100, 0, map[string]any{"user": user.Id})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load support actions"})
+	}
+	actions := make([]SupportHistoryEntry, 0, len(records))
+	for _, record := range records {
+		var details map[string]interface{}
+		if err := record.UnmarshalJSONField("details", &details); err != nil || details["user_id"] != user.Id {
+			continue
+		}
+		actions = append(actions, SupportHistoryEntry{
+			Action:     record.GetString("action"),
+			ActorEmail: record.GetString("actor_email"),
+			Details:    details,
+			Created:    record.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+		})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"changes":         changes,
+		"support_actions": actions,
+	})
+}
+
+func statusOf(record *core.Record) string {
+	if record == nil {
+		return ""
+	}
+	return record.GetString("status")
+}
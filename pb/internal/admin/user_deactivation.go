@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/payment"
+)
+
+// DeactivateUserHandler soft-deletes a user account (superusers only)
+func DeactivateUserHandler(e *core.RequestEvent, app core.App, paymentService *payment.Service) error {
+	user, err := app.FindRecordById("users", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if e.Request.ContentLength > 0 {
+		if err := e.BindBody(&req); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "admin_deactivation"
+	}
+
+	if err := accounts.Deactivate(app, paymentService, user, req.Reason); err != nil {
+		if errors.Is(err, accounts.ErrAlreadyDeactivated) {
+			return e.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to deactivate account: %v", err)})
+	}
+
+	recordAudit(app, e, "user_deactivated", false, map[string]interface{}{
+		"user_id": user.Id,
+		"reason":  req.Reason,
+	})
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"user_id":             user.Id,
+		"deactivated_at":      user.GetDateTime("deactivated_at"),
+		"subscription_paused": user.GetBool("subscription_paused"),
+		"purge_at":            accounts.PurgeAt(user),
+	})
+}
+
+// RestoreUserHandler reactivates a soft-deleted user account before it is purged (superusers only)
+func RestoreUserHandler(e *core.RequestEvent, app core.App, paymentService *payment.Service) error {
+	user, err := app.FindRecordById("users", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	if err := accounts.Restore(app, paymentService, user); err != nil {
+		if errors.Is(err, accounts.ErrNotDeactivated) {
+			return e.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to restore account: %v", err)})
+	}
+
+	recordAudit(app, e, "user_restored", false, map[string]interface{}{
+		"user_id": user.Id,
+	})
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"user_id":  user.Id,
+		"restored": true,
+	})
+}
@@ -0,0 +1,388 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/apisign"
+	"pocketbase/internal/keyguard"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/secrets"
+)
+
+// lastUsedResolution limits last_used_at writes to one per key in this window
+const lastUsedResolution = 5 * time.Minute
+
+// APIKeyInfo is an API key as shown to its owner - the key itself is never returned again
+type APIKeyInfo struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	MaskedKey      string   `json:"masked_key"`
+	Active         bool     `json:"active"`
+	SigningEnabled bool     `json:"signing_enabled"`
+	SigningPreview string   `json:"signing_secret_preview,omitempty"` // masked, e.g. "rs-a…9xQ2"
+	OrganizationID string   `json:"organization_id,omitempty"`        // pool the key charges, if any
+	Current        bool     `json:"current"`                          // the key that authenticated this request
+	Created        string   `json:"created"`
+	LastUsedAt     string   `json:"last_used_at,omitempty"`
+	Scopes         []string `json:"scopes"`
+	FullAccess     bool     `json:"full_access"` // generated without scopes: every scope, including ones added later
+	keyguard.Restrictions
+}
+
+// createAPIKey stores a new key for a user and returns the plaintext key, which is not kept
+// Keys created for an organization charge its pooled hours instead of the user's, and keys
+// without scopes have full access
+func createAPIKey(app core.App, userID, name, organizationID string, scopes []string) (string, *core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("api_keys")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to find API keys collection: %w", err)
+	}
+
+	apiKey := generateAPIKey()
+	record := core.NewRecord(collection)
+	record.Set("key_hash", hashAPIKey(apiKey))
+	record.Set("key_prefix", apiKey[:8])
+	record.Set("user_id", userID)
+	record.Set("active", true)
+	record.Set("name", name)
+	record.Set("organization_id", organizationID)
+	record.Set("scopes", scopes)
+
+	if err := app.Save(record); err != nil {
+		return "", nil, fmt.Errorf("failed to save API key: %w", err)
+	}
+	return apiKey, record, nil
+}
+
+// markAPIKeyUsed records when a key (or delegated token grant) was last used, at most once per
+// lastUsedResolution. Written directly so frequent requests don't run record hooks or bump "updated"
+func markAPIKeyUsed(app core.App, key *core.Record, now time.Time) {
+	if last := key.GetDateTime("last_used_at"); !last.IsZero() && now.Sub(last.Time()) < lastUsedResolution {
+		return
+	}
+
+	_, err := app.DB().Update(key.Collection().Name,
+		dbx.Params{"last_used_at": now.UTC().Format("2006-01-02 15:04:05.000Z")},
+		dbx.HashExp{"id": key.Id},
+	).Execute()
+	if err != nil {
+		slog.Warn("Failed to update API key last_used_at", "key_id", key.Id, "error", err)
+	}
+}
+
+func maskAPIKey(key *core.Record) string {
+	if prefix := key.GetString("key_prefix"); prefix != "" {
+		return prefix + "..." // keys created before prefixes were stored have nothing to show
+	}
+	return "..."
+}
+
+// keyManager resolves who is managing keys: a logged-in user (web app) or an API key with scope
+// (desktop app). The second value is the id of the authenticating key, if any
+func keyManager(e *core.RequestEvent, app core.App, scope string) (*core.Record, string, error) {
+	user, key, err := keyManagerKey(e, app, scope)
+	if err != nil || key == nil {
+		return user, "", err
+	}
+	return user, key.Id, nil
+}
+
+// keyManagerKey is keyManager returning the authenticating key (or delegated token grant)
+// itself, nil for a logged-in user
+func keyManagerKey(e *core.RequestEvent, app core.App, scope string) (*core.Record, *core.Record, error) {
+	if e.Auth != nil && e.Auth.Collection().Name == "users" {
+		return e.Auth, nil, nil
+	}
+
+	if key := apisign.SignedKey(e); key != nil {
+		if err := checkScope(key, scope); err != nil {
+			return nil, nil, err
+		}
+		return apisign.SignedUser(e), key, nil
+	}
+
+	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
+	if apiKey == "" {
+		return nil, nil, errMissingAPIKey
+	}
+	key, user, err := lookupAPIKey(app, apiKey, e.Request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkScope(key, scope); err != nil {
+		return nil, nil, err
+	}
+	return user, key, nil
+}
+
+// ownedAPIKey loads one of the manager's keys by the {id} path parameter
+func ownedAPIKey(e *core.RequestEvent, app core.App, user *core.Record) (*core.Record, error) {
+	key, err := app.FindRecordById("api_keys", e.Request.PathValue("id"))
+	if err != nil || key.GetString("user_id") != user.Id {
+		return nil, errors.New("API key not found")
+	}
+	return key, nil
+}
+
+func keyManagerError(e *core.RequestEvent, err error) error {
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Authentication required"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	return e.JSON(401, map[string]string{"error": "Invalid API key"})
+}
+
+// ListAPIKeysHandler lists the user's API keys, masked
+// GET /api/api-keys
+func ListAPIKeysHandler(e *core.RequestEvent, app core.App) error {
+	user, currentKeyID, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	records, err := app.FindRecordsByFilter("api_keys", "user_id = {:user}", "-active,-created", 0, 0, map[string]any{"user": user.Id})
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load API keys"})
+	}
+
+	keys := make([]APIKeyInfo, 0, len(records))
+	for _, record := range records {
+		info := APIKeyInfo{
+			ID:             record.Id,
+			Name:           record.GetString("name"),
+			MaskedKey:      maskAPIKey(record),
+			Active:         record.GetBool("active"),
+			SigningEnabled: record.GetString("signing_secret") != "",
+			OrganizationID: record.GetString("organization_id"),
+			Current:        record.Id == currentKeyID,
+			Created:        record.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+			Scopes:         keyScopes(record),
+			FullAccess:     len(record.GetStringSlice("scopes")) == 0,
+			Restrictions:   keyguard.KeyRestrictions(record),
+		}
+		if info.SigningEnabled {
+			if secret, err := secrets.Open(record.GetString("signing_secret")); err == nil {
+				info.SigningPreview = secrets.Mask(secret)
+			}
+		}
+		if last := record.GetDateTime("last_used_at"); !last.IsZero() {
+			info.LastUsedAt = last.Time().UTC().Format(time.RFC3339)
+		}
+		keys = append(keys, info)
+	}
+
+	return e.JSON(200, map[string]any{"keys": keys})
+}
+
+// RenameAPIKeyHandler changes a key's display name
+// PATCH /api/api-keys/{id}
+func RenameAPIKeyHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request format"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		return e.JSON(400, map[string]string{"error": "name must be between 1 and 100 characters"})
+	}
+
+	key, err := ownedAPIKey(e, app, user)
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": err.Error()})
+	}
+
+	key.Set("name", req.Name)
+	if err := app.Save(key); err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to rename API key"})
+	}
+
+	return e.JSON(200, map[string]string{"id": key.Id, "name": req.Name})
+}
+
+// RevokeAPIKeyHandler deactivates a key - requests using it fail from then on
+// POST /api/api-keys/{id}/revoke
+func RevokeAPIKeyHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	key, err := ownedAPIKey(e, app, user)
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": err.Error()})
+	}
+
+	if key.GetBool("active") {
+		key.Set("active", false)
+		if err := app.Save(key); err != nil {
+			return e.JSON(500, map[string]string{"error": "Failed to revoke API key"})
+		}
+		logging.FromRequest(e).Info("API key revoked", "key_id", key.Id, "api_key", maskAPIKey(key))
+	}
+
+	return e.JSON(200, map[string]any{"id": key.Id, "active": false})
+}
+
+// RotateAPIKeyHandler replaces an active key with a new one under the same name
+// The new key is created and the old one revoked in one transaction, so there's never zero or two live keys
+// An API key can only rotate keys with no more scopes than its own, since the caller gets the new key
+// POST /api/api-keys/{id}/rotate
+func RotateAPIKeyHandler(e *core.RequestEvent, app core.App) error {
+	user, caller, err := keyManagerKey(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	key, err := ownedAPIKey(e, app, user)
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": err.Error()})
+	}
+	if caller != nil {
+		if err := checkScopes(caller, keyScopes(key)); err != nil {
+			_, err := scopeDeniedResponse(e, err)
+			return err
+		}
+	}
+	if !key.GetBool("active") {
+		return e.JSON(409, map[string]string{"error": "Revoked keys can't be rotated - generate a new key instead"})
+	}
+
+	var apiKey string
+	var replacement *core.Record
+	err = app.RunInTransaction(func(txApp core.App) error {
+		var err error
+		apiKey, replacement, err = createAPIKey(txApp, user.Id, key.GetString("name"), key.GetString("organization_id"), key.GetStringSlice("scopes"))
+		if err != nil {
+			return err
+		}
+		// The replacement has the old key's scopes and is limited to the same places
+		restrictions := keyguard.KeyRestrictions(key)
+		replacement.Set("allowed_cidrs", restrictions.AllowedCIDRs)
+		replacement.Set("allowed_countries", restrictions.AllowedCountries)
+		if err := txApp.Save(replacement); err != nil {
+			return err
+		}
+		key.Set("active", false)
+		return txApp.Save(key)
+	})
+	if err != nil {
+		logging.FromRequest(e).Error("API key rotation failed", "key_id", key.Id, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to rotate API key"})
+	}
+
+	logging.FromRequest(e).Info("API key rotated", "key_id", key.Id, "replacement_id", replacement.Id)
+
+	// Signing secrets belong to the old key; signed callers need to enable signing on the new one
+	return e.JSON(200, map[string]any{
+		"id":            replacement.Id,
+		"api_key":       apiKey,
+		"replaced_id":   key.Id,
+		"signing_reset": key.GetString("signing_secret") != "",
+		"masked_key":    maskAPIKey(replacement),
+		"name":          replacement.GetString("name"),
+		"scopes":        keyScopes(replacement),
+	})
+}
+
+// SetAPIKeyRestrictionsHandler limits where a key can be used from: IP addresses or CIDR
+// ranges, and countries (ISO codes, resolved by the CDN). Empty lists lift the restriction
+// A key can't restrict itself out of the request making the change
+// PUT /api/api-keys/{id}/restrictions
+func SetAPIKeyRestrictionsHandler(e *core.RequestEvent, app core.App) error {
+	user, currentKeyID, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	var req keyguard.Restrictions
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request format"})
+	}
+	if err := req.Normalize(); err != nil {
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	key, err := ownedAPIKey(e, app, user)
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": err.Error()})
+	}
+	if key.Id == currentKeyID {
+		if err := req.Allow(e.RealIP(), keyguard.Country(e.Request)); err != nil {
+			return e.JSON(409, map[string]string{"error": "These restrictions would lock out the key making this request: " + err.Error()})
+		}
+	}
+
+	key.Set("allowed_cidrs", req.AllowedCIDRs)
+	key.Set("allowed_countries", req.AllowedCountries)
+	if err := app.Save(key); err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to save API key restrictions"})
+	}
+	logging.FromRequest(e).Info("API key restrictions changed", "key_id", key.Id,
+		"allowed_cidrs", len(req.AllowedCIDRs), "allowed_countries", req.AllowedCountries)
+
+	return e.JSON(200, map[string]any{"id": key.Id, "allowed_cidrs": req.AllowedCIDRs, "allowed_countries": req.AllowedCountries})
+}
+
+// APIKeyActivityHandler lists where a key has been used from (most recent first) and the alerts
+// raised on it
+// GET /api/api-keys/{id}/activity
+func APIKeyActivityHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+	key, err := ownedAPIKey(e, app, user)
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": err.Error()})
+	}
+
+	fingerprints, err := app.FindRecordsByFilter("api_key_fingerprints", "key_id = {:key}", "-last_seen", 100, 0, map[string]any{"key": key.Id})
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load API key activity"})
+	}
+	alerts, err := app.FindRecordsByFilter("api_key_alerts", "key_id = {:key}", "-created", 50, 0, map[string]any{"key": key.Id})
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load API key alerts"})
+	}
+
+	seen := make([]map[string]any, 0, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		seen = append(seen, map[string]any{
+			"ip":         fingerprint.GetString("ip"),
+			"country":    fingerprint.GetString("country"),
+			"user_agent": fingerprint.GetString("user_agent"),
+			"first_seen": fingerprint.GetDateTime("first_seen").Time().UTC().Format(time.RFC3339),
+			"last_seen":  fingerprint.GetDateTime("last_seen").Time().UTC().Format(time.RFC3339),
+		})
+	}
+	raised := make([]map[string]any, 0, len(alerts))
+	for _, alert := range alerts {
+		raised = append(raised, map[string]any{
+			"id":              alert.Id,
+			"kind":            alert.GetString("kind"),
+			"ip":              alert.GetString("ip"),
+			"country":         alert.GetString("country"),
+			"known_countries": alert.Get("known_countries"),
+			"notified":        alert.GetBool("notified"),
+			"created":         alert.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+		})
+	}
+
+	return e.JSON(200, map[string]any{"id": key.Id, "fingerprints": seen, "alerts": raised})
+}
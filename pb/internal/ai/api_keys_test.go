@@ -0,0 +1,22 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestMaskAPIKey(t *testing.T) {
+	collection := core.NewBaseCollection("api_keys")
+	collection.Fields.Add(&core.TextField{Name: "key_prefix"})
+
+	key := core.NewRecord(collection)
+	if got := maskAPIKey(key); got != "..." {
+		t.Fatalf("key without prefix: got %q", got)
+	}
+
+	key.Set("key_prefix", "ra-1a2b3")
+	if got := maskAPIKey(key); got != "ra-1a2b3..." {
+		t.Fatalf("key with prefix: got %q", got)
+	}
+}
@@ -0,0 +1,778 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/audioprobe"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/moderation"
+	"pocketbase/internal/progress"
+	"pocketbase/internal/whisper"
+)
+
+// Audio upload session states
+const (
+	AudioSessionOpen         = "open"         // accepting chunks
+	AudioSessionTranscribing = "transcribing" // finalized, a transcription job owns it
+	AudioSessionCompleted    = "completed"
+	AudioSessionFailed       = "failed" // finalize again to retry the chunks that didn't transcribe
+	AudioSessionExpired      = "expired"
+)
+
+// Audio upload chunk states
+const (
+	ChunkReceived    = "received"
+	ChunkTranscribed = "transcribed"
+	ChunkFailed      = "failed"
+)
+
+// maxChunkBytes is Whisper's upload limit - every chunk is transcribed on its own
+const maxChunkBytes = 25 << 20
+
+// maxSessionChunks caps expected_chunks; 1000 chunks of 25MB is well past any recording we accept
+const maxSessionChunks = 1000
+
+// audioSessionTTL is how long a session accepts chunks before it expires
+const audioSessionTTL = 24 * time.Hour
+
+// AudioSession is an upload session as reported to its owner
+type AudioSession struct {
+	ID                      string  `json:"id"`
+	Status                  string  `json:"status"`
+	Filename                string  `json:"filename"`
+	ExpectedChunks          int     `json:"expected_chunks"`
+	Received                []int   `json:"received"`
+	Missing                 []int   `json:"missing"`
+	Transcribed             []int   `json:"transcribed"`
+	Failed                  []int   `json:"failed"`
+	OriginalFileSizeBytes   int64   `json:"original_file_size_bytes,omitempty"`
+	OriginalDurationSeconds float64 `json:"original_duration_seconds,omitempty"`
+	JobID                   string  `json:"job_id,omitempty"`
+	StatusURL               string  `json:"status_url,omitempty"`
+	Error                   string  `json:"error,omitempty"`
+	ExpiresAt               string  `json:"expires_at"`
+	Created                 string  `json:"created"`
+}
+
+// CreateAudioSessionRequest is the payload for opening an upload session
+type CreateAudioSessionRequest struct {
+	Filename                string  `json:"filename"`
+	ExpectedChunks          int     `json:"expected_chunks"`
+	Checksum                string  `json:"checksum"` // optional sha256 hex of the whole file, checked on finalize
+	OriginalFileSizeBytes   int64   `json:"original_file_size_bytes"`
+	OriginalDurationSeconds float64 `json:"original_duration_seconds"`
+}
+
+// Validate checks the request and normalizes the checksum
+func (r *CreateAudioSessionRequest) Validate() error {
+	r.Filename = strings.TrimSpace(r.Filename)
+	if r.Filename == "" {
+		return fmt.Errorf("filename is required")
+	}
+	if r.ExpectedChunks < 1 || r.ExpectedChunks > maxSessionChunks {
+		return fmt.Errorf("expected_chunks must be between 1 and %d", maxSessionChunks)
+	}
+	r.Checksum = strings.ToLower(strings.TrimSpace(r.Checksum))
+	if r.Checksum != "" && !isSHA256Hex(r.Checksum) {
+		return fmt.Errorf("checksum must be a sha256 hex digest")
+	}
+	if r.OriginalFileSizeBytes < 0 || r.OriginalDurationSeconds < 0 {
+		return fmt.Errorf("original_file_size_bytes and original_duration_seconds cannot be negative")
+	}
+	return nil
+}
+
+func isSHA256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// sessionUser authenticates the API key and loads one of the user's sessions
+func sessionUser(e *core.RequestEvent, app core.App) (*core.Record, *core.Record, error) {
+	user, _, err := authenticateAPIKey(e, app, ScopeAIAudio)
+	if errors.Is(err, errMissingAPIKey) {
+		return nil, nil, e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, respErr := scopeDeniedResponse(e, err); handled {
+		return nil, nil, respErr
+	}
+	if err != nil {
+		return nil, nil, e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+
+	session, err := app.FindRecordById("audio_upload_sessions", e.Request.PathValue("id"))
+	if err != nil || session.GetString("user_id") != user.Id {
+		return nil, nil, e.JSON(404, map[string]string{"error": "Upload session not found"})
+	}
+	status := session.GetString("status")
+	if (status == AudioSessionOpen || status == AudioSessionFailed) && time.Now().After(session.GetDateTime("expires_at").Time()) {
+		session.Set("status", AudioSessionExpired)
+		if err := app.Save(session); err != nil {
+			logging.FromRequest(e).Warn("Failed to expire audio session", "session_id", session.Id, "error", err)
+		}
+	}
+	return user, session, nil
+}
+
+// CreateAudioSessionHandler opens a chunked upload session
+// POST /api/ai/audio-sessions
+func CreateAudioSessionHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := authenticateAPIKey(e, app, ScopeAIAudio)
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+	if accounts.IsSandbox(user) {
+		return e.JSON(400, map[string]string{"error": "Upload sessions aren't available to sandbox tenants - send the file to /api/ai/process-audio"})
+	}
+
+	var req CreateAudioSessionRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request body"})
+	}
+	if err := req.Validate(); err != nil {
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	collection, err := app.FindCollectionByNameOrId("audio_upload_sessions")
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to find upload sessions collection"})
+	}
+
+	session := core.NewRecord(collection)
+	session.Set("user_id", user.Id)
+	session.Set("filename", req.Filename)
+	session.Set("expected_chunks", req.ExpectedChunks)
+	session.Set("checksum", req.Checksum)
+	session.Set("original_file_size_bytes", req.OriginalFileSizeBytes)
+	session.Set("original_duration_seconds", req.OriginalDurationSeconds)
+	session.Set("status", AudioSessionOpen)
+	session.Set("client_ip", getClientIP(e))
+	session.Set("expires_at", time.Now().Add(audioSessionTTL))
+	if err := app.Save(session); err != nil {
+		logging.FromRequest(e).Error("Failed to create audio session", "user_id", user.Id, "filename", req.Filename, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to create upload session"})
+	}
+
+	logging.FromRequest(e).Info("Created audio session",
+		"user_id", user.Id, "session_id", session.Id, "filename", req.Filename, "expected_chunks", req.ExpectedChunks)
+	return e.JSON(201, audioSessionView(app, session))
+}
+
+// GetAudioSessionHandler reports which chunks a session has and how far transcription got
+// GET /api/ai/audio-sessions/{id}
+func GetAudioSessionHandler(e *core.RequestEvent, app core.App) error {
+	_, session, err := sessionUser(e, app)
+	if session == nil {
+		return err
+	}
+	return e.JSON(200, audioSessionView(app, session))
+}
+
+// PutAudioChunkHandler stores one chunk; the body is the raw audio. Sending an index again
+// replaces it, so a chunk whose upload failed can simply be retried. An optional X-Chunk-SHA256
+// header is checked against the body, and X-Chunk-Start-Seconds places the chunk on the original
+// file's timeline when chunks overlap or skip silence
+// PUT /api/ai/audio-sessions/{id}/chunks/{index}
+func PutAudioChunkHandler(e *core.RequestEvent, app core.App) error {
+	user, session, err := sessionUser(e, app)
+	if session == nil {
+		return err
+	}
+	if status := session.GetString("status"); status != AudioSessionOpen {
+		return e.JSON(409, map[string]string{"error": "Upload session is " + status})
+	}
+
+	index, err := strconv.Atoi(e.Request.PathValue("index"))
+	if err != nil || index < 0 || index >= session.GetInt("expected_chunks") {
+		return e.JSON(400, map[string]string{"error": fmt.Sprintf("chunk index must be between 0 and %d", session.GetInt("expected_chunks")-1)})
+	}
+
+	startSeconds, err := parseChunkStart(e.Request.Header.Get("X-Chunk-Start-Seconds"))
+	if err != nil {
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	data, err := io.ReadAll(io.LimitReader(e.Request.Body, maxChunkBytes+1))
+	if err != nil {
+		return e.JSON(400, map[string]string{"error": "Failed to read chunk"})
+	}
+	if len(data) == 0 {
+		return e.JSON(400, map[string]string{"error": "Chunk is empty"})
+	}
+	if len(data) > maxChunkBytes {
+		return e.JSON(413, map[string]string{"error": fmt.Sprintf("Chunks are limited to %d MB", maxChunkBytes>>20)})
+	}
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	if expected := strings.ToLower(e.Request.Header.Get("X-Chunk-SHA256")); expected != "" && expected != digest {
+		return e.JSON(422, map[string]string{"error": "Chunk checksum mismatch", "sha256": digest})
+	}
+
+	// Each chunk is transcribed on its own, so each one has to be a complete audio file
+	durationSeconds, _, err := probeAudioDuration(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return unsupportedAudioResponse(e, err)
+	}
+
+	audio, err := filesystem.NewFileFromBytes(data, chunkFilename(session.GetString("filename"), index))
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to store chunk"})
+	}
+
+	chunk, err := app.FindFirstRecordByFilter("audio_upload_chunks", "session_id = {:session} && chunk_index = {:index}",
+		map[string]any{"session": session.Id, "index": index})
+	if err != nil {
+		collection, findErr := app.FindCollectionByNameOrId("audio_upload_chunks")
+		if findErr != nil {
+			return e.JSON(500, map[string]string{"error": "Failed to find upload chunks collection"})
+		}
+		chunk = core.NewRecord(collection)
+		chunk.Set("session_id", session.Id)
+		chunk.Set("chunk_index", index)
+	}
+	chunk.Set("audio", audio)
+	chunk.Set("size_bytes", len(data))
+	chunk.Set("duration_seconds", durationSeconds)
+	chunk.Set("start_seconds", startSeconds)
+	chunk.Set("sha256", digest)
+	chunk.Set("status", ChunkReceived)
+	chunk.Set("result", nil)
+	chunk.Set("error", "")
+	if err := app.Save(chunk); err != nil {
+		logging.FromRequest(e).Error("Failed to store audio chunk",
+			"user_id", user.Id, "session_id", session.Id, "chunk_index", index, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to store chunk"})
+	}
+
+	logging.FromRequest(e).Debug("Stored audio chunk",
+		"user_id", user.Id, "session_id", session.Id, "chunk_index", index, "size_kb", len(data)/1024)
+	return e.JSON(200, map[string]any{"chunk_index": index, "size_bytes": len(data), "sha256": digest})
+}
+
+// FinalizeAudioSessionHandler checks that every chunk arrived and matches the session checksum,
+// then queues the session for transcription. Finalizing a failed session retries only the chunks
+// that didn't transcribe
+// POST /api/ai/audio-sessions/{id}/finalize
+func FinalizeAudioSessionHandler(e *core.RequestEvent, app core.App) error {
+	user, session, err := sessionUser(e, app)
+	if session == nil {
+		return err
+	}
+	status := session.GetString("status")
+	if status != AudioSessionOpen && status != AudioSessionFailed {
+		return e.JSON(409, map[string]string{"error": "Upload session is " + status})
+	}
+
+	chunks, err := sessionChunks(app, session.Id)
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load chunks"})
+	}
+	if missing := missingChunks(session.GetInt("expected_chunks"), chunkIndices(chunks)); len(missing) > 0 {
+		return e.JSON(409, map[string]any{"error": "Upload session is missing chunks", "missing": missing})
+	}
+
+	var totalBytes int64
+	var probedSeconds float64
+	for _, chunk := range chunks {
+		totalBytes += int64(chunk.GetInt("size_bytes"))
+		probedSeconds += chunk.GetFloat("duration_seconds")
+	}
+
+	logger := logging.FromRequest(e).With("user_id", user.Id, "session_id", session.Id)
+	account := requestUsageAccount(e, app, user)
+	if status == AudioSessionOpen {
+		if expected := session.GetString("checksum"); expected != "" {
+			digest, err := sessionChecksum(app, chunks)
+			if err != nil {
+				return e.JSON(500, map[string]string{"error": "Failed to read chunks"})
+			}
+			if digest != expected {
+				logger.Warn("Audio session checksum mismatch", "expected", expected, "got", digest)
+				return e.JSON(422, map[string]string{"error": "Uploaded chunks don't match the session checksum", "sha256": digest})
+			}
+		}
+
+		// Usage is charged on the transcribed duration; the probed duration of the chunks (or the
+		// declared one, or a size-based estimate) is only used to refuse uploads that can't fit
+		// in the remaining allowance
+		durationSeconds := probedSeconds
+		if durationSeconds <= 0 {
+			durationSeconds = session.GetFloat("original_duration_seconds")
+		}
+		if durationSeconds <= 0 {
+			durationSeconds = audioprobe.EstimateDuration(totalBytes)
+		}
+		if err := validateUsageLimits(app, logger, account, durationSeconds/3600.0); err != nil {
+			return e.JSON(403, map[string]string{"error": err.Error(), "code": "USAGE_LIMIT_EXCEEDED"})
+		}
+	}
+
+	collection, err := app.FindCollectionByNameOrId("transcription_jobs")
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to find transcription jobs collection"})
+	}
+	job := core.NewRecord(collection)
+	job.Set("user_id", user.Id)
+	job.Set("status", JobQueued)
+	job.Set("session_id", session.Id)
+	job.Set("filename", session.GetString("filename"))
+	job.Set("file_size_bytes", totalBytes)
+	job.Set("base_filename", session.GetString("filename"))
+	job.Set("original_file_size_bytes", session.GetInt("original_file_size_bytes"))
+	job.Set("original_duration_seconds", session.GetFloat("original_duration_seconds"))
+	job.Set("client_ip", getClientIP(e))
+	job.Set("request_id", logging.RequestID(e.Request.Context()))
+	job.Set("organization_id", account.OrganizationID)
+	if err := app.Save(job); err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to queue transcription"})
+	}
+	jobProgress(app, job)
+
+	session.Set("status", AudioSessionTranscribing)
+	session.Set("job_id", job.Id)
+	session.Set("error", "")
+	if err := app.Save(session); err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to update upload session"})
+	}
+
+	logger.Info("Finalized audio session", "job_id", job.Id, "chunks", len(chunks), "size_kb", totalBytes/1024)
+	wakeTranscriptionWorkers()
+
+	return e.JSON(202, audioSessionView(app, session))
+}
+
+func audioSessionView(app core.App, session *core.Record) AudioSession {
+	view := AudioSession{
+		ID:                      session.Id,
+		Status:                  session.GetString("status"),
+		Filename:                session.GetString("filename"),
+		ExpectedChunks:          session.GetInt("expected_chunks"),
+		Received:                []int{},
+		Transcribed:             []int{},
+		Failed:                  []int{},
+		OriginalFileSizeBytes:   int64(session.GetInt("original_file_size_bytes")),
+		OriginalDurationSeconds: session.GetFloat("original_duration_seconds"),
+		JobID:                   session.GetString("job_id"),
+		Error:                   session.GetString("error"),
+		ExpiresAt:               session.GetDateTime("expires_at").Time().UTC().Format(time.RFC3339),
+		Created:                 session.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+	}
+	if view.JobID != "" {
+		view.StatusURL = "/api/ai/jobs/" + view.JobID
+	}
+
+	chunks, err := sessionChunks(app, session.Id)
+	if err != nil {
+		slog.Warn("Failed to load audio session chunks", "session_id", session.Id, "error", err)
+	}
+	for _, chunk := range chunks {
+		index := chunk.GetInt("chunk_index")
+		view.Received = append(view.Received, index)
+		switch chunk.GetString("status") {
+		case ChunkTranscribed:
+			view.Transcribed = append(view.Transcribed, index)
+		case ChunkFailed:
+			view.Failed = append(view.Failed, index)
+		}
+	}
+	view.Missing = missingChunks(view.ExpectedChunks, view.Received)
+	if view.Status == AudioSessionCompleted {
+		// Chunk records are removed once the merged transcript is stored
+		view.Missing = []int{}
+	}
+	return view
+}
+
+// sessionChunks returns the session's chunk records in index order
+func sessionChunks(app core.App, sessionID string) ([]*core.Record, error) {
+	return app.FindRecordsByFilter("audio_upload_chunks", "session_id = {:session}", "chunk_index", 0, 0,
+		map[string]any{"session": sessionID})
+}
+
+func chunkIndices(chunks []*core.Record) []int {
+	indices := make([]int, 0, len(chunks))
+	for _, chunk := range chunks {
+		indices = append(indices, chunk.GetInt("chunk_index"))
+	}
+	return indices
+}
+
+// missingChunks lists the indices below expected that haven't been received
+func missingChunks(expected int, received []int) []int {
+	have := make(map[int]bool, len(received))
+	for _, index := range received {
+		have[index] = true
+	}
+	missing := []int{}
+	for index := 0; index < expected; index++ {
+		if !have[index] {
+			missing = append(missing, index)
+		}
+	}
+	return missing
+}
+
+// chunkFilename names a stored chunk after the session file, keeping the extension Whisper uses
+// to detect the format
+func chunkFilename(filename string, index int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s.part%04d%s", strings.TrimSuffix(filename, ext), index, ext)
+}
+
+// sessionChecksum hashes the chunks in index order, i.e. the original file
+func sessionChecksum(app core.App, chunks []*core.Record) (string, error) {
+	fsys, err := app.NewFilesystem()
+	if err != nil {
+		return "", err
+	}
+	defer fsys.Close()
+
+	sum := sha256.New()
+	for _, chunk := range chunks {
+		if err := copyChunk(fsys, chunk, sum); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+func copyChunk(fsys *filesystem.System, chunk *core.Record, sum hash.Hash) error {
+	reader, err := fsys.GetReader(chunk.BaseFilesPath() + "/" + chunk.GetString("audio"))
+	if err != nil {
+		return fmt.Errorf("chunk %d is missing: %w", chunk.GetInt("chunk_index"), err)
+	}
+	defer reader.Close()
+	_, err = io.Copy(sum, reader)
+	return err
+}
+
+// mergeChunkResults joins per-chunk transcripts into one, shifting each chunk's timestamps
+// to its offset in the original file (see chunkOffsets). durations are the chunks' lengths
+func mergeChunkResults(results []*whisper.Result, offsets, durations []float64) *whisper.Result {
+	merged := &whisper.Result{}
+	var transcripts []string
+	for i, result := range results {
+		if text := strings.TrimSpace(result.Transcript); text != "" {
+			transcripts = append(transcripts, text)
+		}
+		if merged.Language == "" {
+			merged.Language = result.Language
+		}
+
+		// Shift a copy - the stored chunk result keeps its own timing
+		shifted := *result
+		shifted.Words = append([]whisper.Word(nil), result.Words...)
+		shifted.Segments = append([]whisper.Segment(nil), result.Segments...)
+		shiftResult(&shifted, offsets[i])
+
+		merged.Words = append(merged.Words, shifted.Words...)
+		for _, segment := range shifted.Segments {
+			segment.ID = len(merged.Segments)
+			merged.Segments = append(merged.Segments, segment)
+		}
+		merged.Duration = max(merged.Duration, offsets[i]+durations[i])
+	}
+	merged.Transcript = strings.Join(transcripts, " ")
+	return merged
+}
+
+// processSession transcribes the chunks of a finalized session concurrently and joins them in
+// chunk order. Each chunk's transcript is stored as it completes, so a retry after a failure
+// only sends the remaining chunks
+func (w *transcriptionWorkers) processSession(job *core.Record) {
+	startTime := time.Now()
+	userID := job.GetString("user_id")
+
+	session, err := w.app.FindRecordById("audio_upload_sessions", job.GetString("session_id"))
+	if err != nil {
+		w.fail(job, "upload session no longer exists")
+		return
+	}
+	chunks, err := sessionChunks(w.app, session.Id)
+	if err != nil || len(missingChunks(session.GetInt("expected_chunks"), chunkIndices(chunks))) > 0 {
+		w.fail(job, "upload session chunks are missing")
+		return
+	}
+
+	logger := jobLogger(job).With("session_id", session.Id)
+	logger.Info("Processing audio session", "chunks", len(chunks), "attempt", job.GetInt("attempts"))
+
+	// Results are kept in chunk order whatever order the chunks finish in
+	results := make([]*whisper.Result, len(chunks))
+	var pending []int
+	var totalBytes int64
+	for i, chunk := range chunks {
+		totalBytes += int64(chunk.GetInt("size_bytes"))
+		if chunk.GetString("status") == ChunkTranscribed {
+			var result whisper.Result
+			if err := chunk.UnmarshalJSONField("result", &result); err == nil {
+				results[i] = &result
+				continue
+			}
+		}
+		pending = append(pending, i)
+	}
+
+	// Progress is the share of chunks transcribed
+	tracker := jobProgress(w.app, job)
+	var doneMu sync.Mutex
+	done := len(chunks) - len(pending)
+	tracker.Update(progress.StageTranscribing, done*100/len(chunks))
+
+	failed, err := runChunks(w.ctx, pending, sessionChunkConcurrency(), func(ctx context.Context, i int) error {
+		chunk := chunks[i]
+		result, err := w.transcribeChunk(ctx, chunk)
+		if err != nil {
+			return err
+		}
+
+		chunk.Set("status", ChunkTranscribed)
+		chunk.Set("result", result)
+		chunk.Set("error", "")
+		if err := w.app.Save(chunk); err != nil {
+			logger.Error("Failed to save chunk", "chunk_index", chunk.GetInt("chunk_index"), "error", err)
+		}
+		results[i] = result
+
+		doneMu.Lock()
+		done++
+		tracker.Update(progress.StageTranscribing, done*100/len(chunks))
+		doneMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		if w.ctx.Err() != nil {
+			logger.Info("Audio session interrupted by shutdown")
+			return
+		}
+		if w.retryLater(job, err) {
+			return
+		}
+		chunk := chunks[failed]
+		chunk.Set("status", ChunkFailed)
+		chunk.Set("error", err.Error())
+		if saveErr := w.app.Save(chunk); saveErr != nil {
+			logger.Error("Failed to save chunk", "chunk_index", chunk.GetInt("chunk_index"), "error", saveErr)
+		}
+		logger.Error("Chunk transcription failed", "chunk_index", chunk.GetInt("chunk_index"), "error", err)
+		w.fail(job, fmt.Sprintf("Transcription of chunk %d failed: %v", chunk.GetInt("chunk_index"), err))
+		return
+	}
+
+	tracker.Update(progress.StagePostProcessing, 0)
+	declared := make([]float64, len(chunks))
+	durations := make([]float64, len(chunks))
+	for i, chunk := range chunks {
+		declared[i] = chunk.GetFloat("start_seconds")
+		durations[i] = chunkDuration(results[i], chunk.GetFloat("duration_seconds"))
+	}
+	merged := mergeChunkResults(results, chunkOffsets(declared, durations), durations)
+	filename := session.GetString("filename")
+	if size := int64(session.GetInt("original_file_size_bytes")); size > 0 {
+		totalBytes = size
+	}
+
+	processedFile, err := w.sessionProcessedFile(session, userID, filename, totalBytes, len(chunks), job.GetString("client_ip"))
+	if err != nil {
+		logger.Warn("Failed to create processed_files record", "error", err)
+	}
+	upload := audioUpload{Filename: filename, SizeBytes: totalBytes, BaseFilename: filename, OrganizationID: job.GetString("organization_id")}
+	completeAudioProcessing(w.app, logger, processedFile, userID, job.GetString("client_ip"), upload, merged, time.Since(startTime))
+	if _, _, err := moderateTranscript(w.ctx, w.app, logger, userID, processedFile, merged); errors.Is(err, moderation.ErrFlagged) {
+		w.fail(job, contentFlaggedMessage)
+		return
+	}
+
+	job.Set("status", JobCompleted)
+	job.Set("result", merged)
+	job.Set("error", "")
+	job.Set("completed_at", time.Now())
+	if err := w.app.Save(job); err != nil {
+		logger.Error("Failed to save transcription job result", "error", err)
+	}
+
+	session.Set("status", AudioSessionCompleted)
+	session.Set("error", "")
+	if processedFile != nil {
+		session.Set("processed_file_id", processedFile.Id)
+	}
+	if err := w.app.Save(session); err != nil {
+		logger.Error("Failed to complete audio session", "error", err)
+	}
+
+	// The merged transcript lives on the job; the chunk audio isn't needed anymore
+	for _, chunk := range chunks {
+		if err := w.app.Delete(chunk); err != nil {
+			logger.Warn("Failed to delete chunk", "chunk_index", chunk.GetInt("chunk_index"), "error", err)
+		}
+	}
+	tracker.Complete()
+}
+
+// sessionChunkConcurrency is how many chunks of one session are transcribed at once
+// (SESSION_CHUNK_CONCURRENCY, default 3). Each transcription worker runs its own pool
+func sessionChunkConcurrency() int {
+	if v, err := strconv.Atoi(os.Getenv("SESSION_CHUNK_CONCURRENCY")); err == nil && v > 0 {
+		return v
+	}
+	return 3
+}
+
+// runChunks calls fn for each index, at most concurrency at a time. The first failure cancels
+// the calls still running or waiting, and is returned with its index
+func runChunks(ctx context.Context, indices []int, concurrency int, fn func(ctx context.Context, i int) error) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		started  int
+		failed   = -1
+		firstErr error
+	)
+	slots := make(chan struct{}, max(concurrency, 1))
+	for _, i := range indices {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		started++
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(ctx, i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					failed, firstErr = i, err
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr == nil && started < len(indices) {
+		// Cancelled from outside before every chunk ran
+		return -1, ctx.Err()
+	}
+	return failed, firstErr
+}
+
+// transcribeChunk streams one stored chunk to Whisper
+func (w *transcriptionWorkers) transcribeChunk(ctx context.Context, chunk *core.Record) (*whisper.Result, error) {
+	fsys, err := w.app.NewFilesystem()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file storage: %w", err)
+	}
+	defer fsys.Close()
+
+	reader, err := fsys.GetReader(chunk.BaseFilesPath() + "/" + chunk.GetString("audio"))
+	if err != nil {
+		return nil, fmt.Errorf("stored chunk is missing: %w", err)
+	}
+	defer reader.Close()
+
+	prepared := prepareAudio(ctx, reader, reader.Size(), chunk.GetString("audio"))
+	defer prepared.Close()
+	return prepared.transcribe(ctx, nil)
+}
+
+// sessionProcessedFile creates the single processed_files record of a session upload
+func (w *transcriptionWorkers) sessionProcessedFile(session *core.Record, userID, filename string, sizeBytes int64, chunkCount int, clientIP string) (*core.Record, error) {
+	collection, err := w.app.FindCollectionByNameOrId("processed_files")
+	if err != nil {
+		return nil, err
+	}
+	record := core.NewRecord(collection)
+	record.Set("user_id", userID)
+	record.Set("filename", filename)
+	record.Set("file_size_bytes", sizeBytes)
+	record.Set("status", "processing")
+	record.Set("model_used", "whisper-1")
+	record.Set("client_ip", clientIP)
+	record.Set("base_filename", filename)
+	record.Set("is_chunk", false)
+	record.Set("chunk_index", chunkCount) // total chunk count, as on flattened uploads
+	record.Set("processing_count", 1)
+	if err := w.app.Save(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// ExpireAudioSessions expires open and failed sessions past their expiry and deletes their chunks
+func ExpireAudioSessions(app core.App) {
+	params := map[string]any{
+		"open":    AudioSessionOpen,
+		"failed":  AudioSessionFailed,
+		"expired": AudioSessionExpired,
+		"now":     time.Now().UTC().Format("2006-01-02 15:04:05.000Z"),
+	}
+
+	sessions, err := app.FindRecordsByFilter("audio_upload_sessions",
+		"(status = {:open} || status = {:failed}) && expires_at < {:now}", "", 0, 0, params)
+	if err != nil {
+		slog.Error("Failed to load expired audio sessions", "error", err)
+		return
+	}
+	for _, session := range sessions {
+		session.Set("status", AudioSessionExpired)
+		if err := app.Save(session); err != nil {
+			slog.Error("Failed to expire audio session", "session_id", session.Id, "error", err)
+		}
+	}
+
+	// Sessions are also expired lazily when their owner touches them, so chunks are looked up by session state
+	chunks, err := app.FindRecordsByFilter("audio_upload_chunks", "session_id.status = {:expired}", "", 0, 0, params)
+	if err != nil {
+		slog.Error("Failed to load chunks of expired audio sessions", "error", err)
+		return
+	}
+	deleted := 0
+	for _, chunk := range chunks {
+		if err := app.Delete(chunk); err != nil {
+			slog.Warn("Failed to delete expired chunk", "chunk_id", chunk.Id, "error", err)
+			continue
+		}
+		deleted++
+	}
+
+	if len(sessions) > 0 || deleted > 0 {
+		slog.Info("Expired audio sessions", "sessions", len(sessions), "chunks_deleted", deleted)
+	}
+}
@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pocketbase/internal/whisper"
+)
+
+func TestCreateAudioSessionRequestValidate(t *testing.T) {
+	valid := CreateAudioSessionRequest{Filename: " talk.mp3 ", ExpectedChunks: 3, Checksum: strings.Repeat("AB", 32)}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if valid.Filename != "talk.mp3" || valid.Checksum != strings.Repeat("ab", 32) {
+		t.Errorf("request wasn't normalized: %+v", valid)
+	}
+
+	invalid := map[string]CreateAudioSessionRequest{
+		"missing filename": {ExpectedChunks: 1},
+		"no chunks":        {Filename: "talk.mp3"},
+		"too many chunks":  {Filename: "talk.mp3", ExpectedChunks: maxSessionChunks + 1},
+		"bad checksum":     {Filename: "talk.mp3", ExpectedChunks: 1, Checksum: "abc"},
+		"negative size":    {Filename: "talk.mp3", ExpectedChunks: 1, OriginalFileSizeBytes: -1},
+	}
+	for name, req := range invalid {
+		if err := req.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMissingChunks(t *testing.T) {
+	if got := missingChunks(5, []int{0, 2, 4, 7}); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("missingChunks = %v, want [1 3]", got)
+	}
+	if got := missingChunks(2, []int{1, 0}); len(got) != 0 || got == nil {
+		t.Errorf("missingChunks = %#v, want an empty slice", got)
+	}
+}
+
+func TestChunkFilename(t *testing.T) {
+	if got := chunkFilename("interview.m4a", 7); got != "interview.part0007.m4a" {
+		t.Errorf("chunkFilename = %s", got)
+	}
+}
+
+func TestMergeChunkResults(t *testing.T) {
+	merged := mergeChunkResults([]*whisper.Result{
+		{
+			Transcript: "Hello there. ",
+			Duration:   10,
+			Language:   "english",
+			Words:      []whisper.Word{{Word: "Hello", Start: 0, End: 0.5}},
+			Segments:   []whisper.Segment{{ID: 0, Start: 0, End: 9.5, Text: "Hello there.", Words: []whisper.Word{{Word: "Hello", Start: 0, End: 0.5}}}},
+		},
+		{
+			Transcript: "General Kenobi.",
+			Duration:   5,
+			Words:      []whisper.Word{{Word: "General", Start: 1, End: 1.5}},
+			Segments:   []whisper.Segment{{ID: 0, Start: 1, End: 4, Text: "General Kenobi."}},
+		},
+	}, []float64{0, 10}, []float64{10, 5})
+
+	if merged.Transcript != "Hello there. General Kenobi." {
+		t.Errorf("transcript = %q", merged.Transcript)
+	}
+	if merged.Duration != 15 || merged.Language != "english" {
+		t.Errorf("duration = %v, language = %q", merged.Duration, merged.Language)
+	}
+	if len(merged.Words) != 2 || merged.Words[1].Start != 11 || merged.Words[1].End != 11.5 {
+		t.Errorf("words = %+v", merged.Words)
+	}
+	if len(merged.Segments) != 2 || merged.Segments[1].ID != 1 || merged.Segments[1].Start != 11 || merged.Segments[1].End != 14 {
+		t.Errorf("segments = %+v", merged.Segments)
+	}
+	if merged.Segments[0].Words[0].Start != 0 {
+		t.Errorf("first chunk words shouldn't move: %+v", merged.Segments[0].Words)
+	}
+}
+
+func TestRunChunksBoundsConcurrency(t *testing.T) {
+	var running, peak atomic.Int32
+	var mu sync.Mutex
+	var ran []int
+
+	failed, err := runChunks(context.Background(), []int{0, 1, 2, 3, 4, 5}, 2, func(ctx context.Context, i int) error {
+		now := running.Add(1)
+		for {
+			old := peak.Load()
+			if now <= old || peak.CompareAndSwap(old, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		running.Add(-1)
+
+		mu.Lock()
+		ran = append(ran, i)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil || failed != -1 {
+		t.Fatalf("runChunks = %d, %v", failed, err)
+	}
+	if len(ran) != 6 {
+		t.Errorf("ran %v, want every chunk", ran)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("%d chunks ran at once, want at most 2", peak.Load())
+	}
+}
+
+func TestRunChunksStopsOnFirstFailure(t *testing.T) {
+	boom := errors.New("provider rejected chunk")
+	var started atomic.Int32
+
+	failed, err := runChunks(context.Background(), []int{0, 1, 2, 3, 4, 5, 6, 7}, 2, func(ctx context.Context, i int) error {
+		started.Add(1)
+		if i == 1 {
+			return boom
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err() // a sibling's cancellation isn't reported as the failure
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		}
+	})
+	if !errors.Is(err, boom) || failed != 1 {
+		t.Fatalf("runChunks = %d, %v; want chunk 1's error", failed, err)
+	}
+	if started.Load() == 8 {
+		t.Error("chunks after the failure should not have started")
+	}
+}
+
+func TestRunChunksReportsOutsideCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := runChunks(ctx, []int{0, 1}, 1, func(context.Context, int) error { return nil }); err == nil {
+		t.Fatal("a cancelled run should report the cancellation")
+	}
+}
@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// BurstAllowance lets a plan go over its monthly hours by Percent, in up to PerYear months of
+// each calendar year. A burst is used up by the first month that goes past the plan limit
+type BurstAllowance struct {
+	Percent float64 `json:"percent"`
+	PerYear int     `json:"per_year"`
+}
+
+// BurstAllowanceFromEnv reads USAGE_BURST_PERCENT (default 20) and USAGE_BURSTS_PER_YEAR (default 2)
+func BurstAllowanceFromEnv() BurstAllowance {
+	allowance := BurstAllowance{Percent: 20, PerYear: 2}
+	if v, err := strconv.ParseFloat(os.Getenv("USAGE_BURST_PERCENT"), 64); err == nil && v >= 0 {
+		allowance.Percent = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("USAGE_BURSTS_PER_YEAR")); err == nil && v >= 0 {
+		allowance.PerYear = v
+	}
+	return allowance
+}
+
+// planBurstAllowance applies the defaults to paid plans. Plans can set burst_percent and
+// bursts_per_year to override them - that's also how a free plan gets bursts
+func planBurstAllowance(plan *core.Record, defaults BurstAllowance) BurstAllowance {
+	if plan == nil {
+		return BurstAllowance{}
+	}
+
+	var allowance BurstAllowance
+	if plan.GetInt("price_cents") > 0 {
+		allowance = defaults
+	}
+	if v := plan.GetFloat("burst_percent"); v > 0 {
+		allowance.Percent = v
+	}
+	if v := plan.GetInt("bursts_per_year"); v > 0 {
+		allowance.PerYear = v
+	}
+	return allowance
+}
+
+// BurstStatus is a user's burst allowance and consumption for the current month and year
+type BurstStatus struct {
+	BurstAllowance
+	UsedThisYear        int     `json:"used_this_year"`
+	Remaining           int     `json:"remaining"`
+	ActiveThisMonth     bool    `json:"active_this_month"`
+	BurstHoursThisMonth float64 `json:"burst_hours_this_month"` // hours used past the plan limit
+	LimitHours          float64 `json:"limit_hours"`
+	CeilingHours        float64 `json:"ceiling_hours"` // the plan limit plus the burst
+}
+
+// allows reports whether the month can reach projectedHours by bursting: either this month's
+// burst is already in use, or one is left for the year
+func (s BurstStatus) allows(projectedHours float64) bool {
+	if s.Percent <= 0 || projectedHours > s.CeilingHours {
+		return false
+	}
+	return s.ActiveThisMonth || s.Remaining > 0
+}
+
+// loadBurstStatus counts the months of now's year that used a burst
+func loadBurstStatus(app core.App, userID string, plan *core.Record, limitHours float64, now time.Time) BurstStatus {
+	status := BurstStatus{
+		BurstAllowance: planBurstAllowance(plan, BurstAllowanceFromEnv()),
+		LimitHours:     limitHours,
+		CeilingHours:   limitHours,
+	}
+	if status.Percent <= 0 || status.PerYear <= 0 {
+		status.Percent = 0
+		status.PerYear = 0
+		return status
+	}
+	status.CeilingHours = limitHours * (1 + status.Percent/100)
+
+	year := now.Format("2006")
+	bursts, err := app.FindRecordsByFilter("monthly_usage",
+		"user_id = {:user} && year_month >= {:start} && year_month <= {:end} && burst_used = true", "", 0, 0,
+		map[string]any{"user": userID, "start": year + "-01", "end": year + "-12"})
+	if err != nil {
+		slog.Warn("Failed to load bursts", "user_id", userID, "error", err)
+	}
+
+	currentMonth := subscription.CurrentUsagePeriod(app, userID, now).Key
+	for _, month := range bursts {
+		status.UsedThisYear++
+		if month.GetString("year_month") == currentMonth {
+			status.ActiveThisMonth = true
+			status.BurstHoursThisMonth = month.GetFloat("burst_hours")
+		}
+	}
+	status.Remaining = max(status.PerYear-status.UsedThisYear, 0)
+	return status
+}
+
+// userPlanLimit returns the user's plan and monthly hours, with the free tier's 0.5 hours as fallback
+func userPlanLimit(app core.App, userID string) (*core.Record, float64) {
+	service := subscription.NewService(subscription.NewRepository(app))
+	info, err := service.GetUserSubscriptionInfo(userID)
+	if err != nil || info.Plan == nil {
+		return nil, 0.5
+	}
+	return info.Plan, info.Plan.GetFloat("hours_per_month")
+}
+
+// recordBurstUsage marks a monthly_usage record as bursting once its hours go past the plan
+// limit plus the grace period, and keeps burst_hours up to date. The caller saves the record
+func recordBurstUsage(app core.App, logger *slog.Logger, userID string, monthlyUsage *core.Record) {
+	plan, limitHours := userPlanLimit(app, userID)
+	hoursUsed := monthlyUsage.GetFloat("hours_used")
+	if hoursUsed <= limitHours+planGracePeriodSeconds(plan)/3600.0 {
+		return
+	}
+
+	if !monthlyUsage.GetBool("burst_used") {
+		logger.Info("Burst started", "user_id", userID, "month", monthlyUsage.GetString("year_month"),
+			"hours_used", hoursUsed, "limit_hours", limitHours)
+	}
+	monthlyUsage.Set("burst_used", true)
+	monthlyUsage.Set("burst_hours", hoursUsed-limitHours)
+}
+
+// currentBurstStatus is the burst status reported by the usage API
+func currentBurstStatus(app core.App, userID string, now time.Time) BurstStatus {
+	plan, limitHours := userPlanLimit(app, userID)
+	return loadBurstStatus(app, userID, plan, limitHours, now)
+}
+
+// BurstMonth aggregates one month's bursts across accounts
+type BurstMonth struct {
+	YearMonth  string  `json:"year_month"`
+	Accounts   int     `json:"accounts"`
+	BurstHours float64 `json:"burst_hours"`
+}
+
+// BurstStatsHandler reports burst consumption per month for a year (superusers only)
+// GET /api/admin/metrics/usage-bursts?year=2026
+func BurstStatsHandler(e *core.RequestEvent, app core.App) error {
+	year := e.Request.URL.Query().Get("year")
+	if year == "" {
+		year = time.Now().Format("2006")
+	} else if _, err := time.Parse("2006", year); err != nil {
+		return e.JSON(400, map[string]string{"error": "year must be formatted as YYYY"})
+	}
+
+	records, err := app.FindRecordsByFilter("monthly_usage",
+		"year_month >= {:start} && year_month <= {:end} && burst_used = true", "year_month", 0, 0,
+		map[string]any{"start": year + "-01", "end": year + "-12"})
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load burst usage"})
+	}
+
+	return e.JSON(200, summarizeBursts(year, records))
+}
+
+func summarizeBursts(year string, records []*core.Record) map[string]any {
+	months := map[string]*BurstMonth{}
+	burstsPerAccount := map[string]int{}
+	totalHours := 0.0
+	for _, record := range records {
+		month, ok := months[record.GetString("year_month")]
+		if !ok {
+			month = &BurstMonth{YearMonth: record.GetString("year_month")}
+			months[month.YearMonth] = month
+		}
+		month.Accounts++
+		month.BurstHours += record.GetFloat("burst_hours")
+		totalHours += record.GetFloat("burst_hours")
+		burstsPerAccount[record.GetString("user_id")]++
+	}
+
+	byMonth := make([]BurstMonth, 0, len(months))
+	for _, month := range months {
+		byMonth = append(byMonth, *month)
+	}
+	sort.Slice(byMonth, func(i, j int) bool { return byMonth[i].YearMonth < byMonth[j].YearMonth })
+
+	// How many accounts used one burst, two bursts, ... this year
+	distribution := map[string]int{}
+	for _, count := range burstsPerAccount {
+		distribution[strconv.Itoa(count)]++
+	}
+
+	return map[string]any{
+		"year":                    year,
+		"total_bursts":            len(records),
+		"accounts":                len(burstsPerAccount),
+		"total_burst_hours":       totalHours,
+		"by_month":                byMonth,
+		"accounts_by_bursts_used": distribution,
+		"default_allowance":       BurstAllowanceFromEnv(),
+	}
+}
@@ -0,0 +1,106 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func burstTestPlan(priceCents int, burstPercent float64, burstsPerYear int) *core.Record {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(
+		&core.NumberField{Name: "price_cents"},
+		&core.NumberField{Name: "burst_percent"},
+		&core.NumberField{Name: "bursts_per_year"},
+	)
+	plan := core.NewRecord(collection)
+	plan.Set("price_cents", priceCents)
+	plan.Set("burst_percent", burstPercent)
+	plan.Set("bursts_per_year", burstsPerYear)
+	return plan
+}
+
+func TestPlanBurstAllowance(t *testing.T) {
+	defaults := BurstAllowance{Percent: 20, PerYear: 2}
+
+	tests := []struct {
+		name string
+		plan *core.Record
+		want BurstAllowance
+	}{
+		{"no plan", nil, BurstAllowance{}},
+		{"free plan", burstTestPlan(0, 0, 0), BurstAllowance{}},
+		{"paid plan uses defaults", burstTestPlan(999, 0, 0), defaults},
+		{"paid plan overrides percent", burstTestPlan(999, 50, 0), BurstAllowance{Percent: 50, PerYear: 2}},
+		{"free plan with its own bursts", burstTestPlan(0, 10, 1), BurstAllowance{Percent: 10, PerYear: 1}},
+	}
+	for _, tt := range tests {
+		if got := planBurstAllowance(tt.plan, defaults); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBurstStatusAllows(t *testing.T) {
+	status := BurstStatus{
+		BurstAllowance: BurstAllowance{Percent: 20, PerYear: 2},
+		LimitHours:     10,
+		CeilingHours:   12,
+		Remaining:      1,
+	}
+
+	if !status.allows(11.5) {
+		t.Error("a remaining burst should cover usage under the ceiling")
+	}
+	if status.allows(12.5) {
+		t.Error("usage past the ceiling should never be allowed")
+	}
+
+	status.Remaining = 0
+	if status.allows(11) {
+		t.Error("no bursts left and none active this month should reject")
+	}
+
+	status.ActiveThisMonth = true
+	if !status.allows(11) {
+		t.Error("a burst already started this month should keep covering it")
+	}
+
+	if (BurstStatus{LimitHours: 10, CeilingHours: 10, Remaining: 2}).allows(10.5) {
+		t.Error("plans without bursts should reject")
+	}
+}
+
+func TestSummarizeBursts(t *testing.T) {
+	collection := core.NewBaseCollection("monthly_usage")
+	collection.Fields.Add(
+		&core.TextField{Name: "user_id"},
+		&core.TextField{Name: "year_month"},
+		&core.NumberField{Name: "burst_hours"},
+	)
+	month := func(user, yearMonth string, hours float64) *core.Record {
+		record := core.NewRecord(collection)
+		record.Set("user_id", user)
+		record.Set("year_month", yearMonth)
+		record.Set("burst_hours", hours)
+		return record
+	}
+
+	summary := summarizeBursts("2026", []*core.Record{
+		month("u1", "2026-03", 1.5),
+		month("u2", "2026-01", 0.5),
+		month("u1", "2026-01", 1),
+	})
+
+	if summary["total_bursts"] != 3 || summary["accounts"] != 2 || summary["total_burst_hours"] != 3.0 {
+		t.Fatalf("unexpected totals: %+v", summary)
+	}
+	byMonth := summary["by_month"].([]BurstMonth)
+	if len(byMonth) != 2 || byMonth[0].YearMonth != "2026-01" || byMonth[0].Accounts != 2 || byMonth[0].BurstHours != 1.5 {
+		t.Errorf("unexpected by_month: %+v", byMonth)
+	}
+	distribution := summary["accounts_by_bursts_used"].(map[string]int)
+	if distribution["1"] != 1 || distribution["2"] != 1 {
+		t.Errorf("unexpected distribution: %+v", distribution)
+	}
+}
@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/whisper"
+)
+
+// Providers time every chunk from 0, so chunk transcripts are shifted onto the timeline of
+// the original file. A chunk starts where the client says it does (chunk_start_seconds, or
+// X-Chunk-Start-Seconds for session chunks), or where the chunks before it end. A declared start
+// of 0 is the same as none: only the first chunk can start there
+
+// parseChunkStart reads a declared chunk start in seconds - empty means none
+func parseChunkStart(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	start, err := strconv.ParseFloat(value, 64)
+	if err != nil || start < 0 || math.IsInf(start, 0) || math.IsNaN(start) {
+		return 0, fmt.Errorf("chunk start must be a number of seconds, 0 or more")
+	}
+	return start, nil
+}
+
+// chunkDuration is how long a chunk's audio runs: the provider's duration, else the probed
+// duration of the upload, else the end of its last timestamp
+func chunkDuration(result *whisper.Result, probedSeconds float64) float64 {
+	if result.Duration > 0 {
+		return result.Duration
+	}
+	if probedSeconds > 0 {
+		return probedSeconds
+	}
+	end := 0.0
+	for _, word := range result.Words {
+		end = max(end, word.End)
+	}
+	for _, segment := range result.Segments {
+		end = max(end, segment.End)
+	}
+	return end
+}
+
+// chunkOffsets returns where each chunk starts: its declared start when above 0, else the end
+// of the chunk before it
+func chunkOffsets(declared, durations []float64) []float64 {
+	offsets := make([]float64, len(durations))
+	for i := range durations {
+		switch {
+		case i < len(declared) && declared[i] > 0:
+			offsets[i] = declared[i]
+		case i > 0:
+			offsets[i] = offsets[i-1] + durations[i-1]
+		}
+	}
+	return offsets
+}
+
+// shiftResult moves a result's word and segment timestamps by offset seconds. Duration is
+// left alone - it's the chunk's own length, which usage is charged on
+func shiftResult(result *whisper.Result, offset float64) {
+	if offset == 0 {
+		return
+	}
+	for i := range result.Words {
+		result.Words[i].Start += offset
+		result.Words[i].End += offset
+	}
+	for i := range result.Segments {
+		result.Segments[i].Start += offset
+		result.Segments[i].End += offset
+		words := make([]whisper.Word, len(result.Segments[i].Words))
+		for j, word := range result.Segments[i].Words {
+			word.Start += offset
+			word.End += offset
+			words[j] = word
+		}
+		result.Segments[i].Words = words
+	}
+}
+
+// offsetChunkResult shifts a chunk uploaded through process-audio (is_chunk) onto the original
+// file's timeline and returns its start. Without a declared start, the start is the summed
+// duration of the earlier chunks; false means some of them haven't completed, so the chunk
+// is left timed from 0
+func offsetChunkResult(app core.App, userID string, upload audioUpload, result *whisper.Result) (float64, bool) {
+	start := upload.ChunkStart
+	if start <= 0 && upload.ChunkIndex > 0 {
+		earlier, err := app.FindRecordsByFilter("processed_files",
+			"user_id = {:user} && base_filename = {:base} && is_chunk = true && status = 'completed' && chunk_index < {:index}",
+			"", 0, 0, map[string]any{"user": userID, "base": upload.BaseFilename, "index": upload.ChunkIndex})
+		if err != nil {
+			return 0, false
+		}
+		// A chunk sent twice has a record per attempt
+		durations := make(map[int]float64, len(earlier))
+		for _, chunk := range earlier {
+			durations[chunk.GetInt("chunk_index")] = chunk.GetFloat("duration_seconds")
+		}
+		if len(durations) != upload.ChunkIndex {
+			return 0, false
+		}
+		start = 0
+		for _, duration := range durations {
+			start += duration
+		}
+	}
+	shiftResult(result, start)
+	return start, true
+}
@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+
+	"pocketbase/internal/whisper"
+)
+
+func TestParseChunkStart(t *testing.T) {
+	for value, want := range map[string]float64{"": 0, " ": 0, "0": 0, "12.5": 12.5, " 30 ": 30} {
+		got, err := parseChunkStart(value)
+		if err != nil || got != want {
+			t.Errorf("parseChunkStart(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"-1", "abc", "NaN", "Inf", "+Inf"} {
+		if _, err := parseChunkStart(value); err == nil {
+			t.Errorf("parseChunkStart(%q) should fail", value)
+		}
+	}
+}
+
+func TestChunkDuration(t *testing.T) {
+	if got := chunkDuration(&whisper.Result{Duration: 12}, 30); got != 12 {
+		t.Errorf("provider duration: got %v", got)
+	}
+	if got := chunkDuration(&whisper.Result{}, 30); got != 30 {
+		t.Errorf("probed duration: got %v", got)
+	}
+	result := &whisper.Result{
+		Words:    []whisper.Word{{Start: 1, End: 2}},
+		Segments: []whisper.Segment{{Start: 0, End: 8.5}},
+	}
+	if got := chunkDuration(result, 0); got != 8.5 {
+		t.Errorf("timestamp end: got %v", got)
+	}
+}
+
+func TestChunkOffsets(t *testing.T) {
+	tests := []struct {
+		name      string
+		declared  []float64
+		durations []float64
+		want      []float64
+	}{
+		{"derived", nil, []float64{10, 5, 7}, []float64{0, 10, 15}},
+		{"declared", []float64{0, 12, 0}, []float64{10, 5, 7}, []float64{0, 12, 17}},
+		{"declared first", []float64{3}, []float64{10, 5}, []float64{3, 13}},
+	}
+	for _, tt := range tests {
+		if got := chunkOffsets(tt.declared, tt.durations); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: chunkOffsets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShiftResult(t *testing.T) {
+	segmentWords := []whisper.Word{{Word: "hi", Start: 0, End: 0.5}}
+	result := &whisper.Result{
+		Duration: 5,
+		Words:    []whisper.Word{{Word: "hi", Start: 0, End: 0.5}},
+		Segments: []whisper.Segment{{Start: 0, End: 4, Words: segmentWords}},
+	}
+	shiftResult(result, 10)
+
+	if result.Duration != 5 {
+		t.Errorf("duration shouldn't move: %v", result.Duration)
+	}
+	if result.Words[0].Start != 10 || result.Words[0].End != 10.5 {
+		t.Errorf("words = %+v", result.Words)
+	}
+	if result.Segments[0].Start != 10 || result.Segments[0].End != 14 || result.Segments[0].Words[0].Start != 10 {
+		t.Errorf("segments = %+v", result.Segments)
+	}
+	if segmentWords[0].Start != 0 {
+		t.Errorf("segment words should be copied, not shifted in place: %+v", segmentWords)
+	}
+}
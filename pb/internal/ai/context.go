@@ -0,0 +1,344 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+	"pocketbase/internal/whisper"
+)
+
+// Token budget limits for prepared contexts
+const (
+	defaultContextTokens = 4000
+	minContextTokens     = 100
+	maxContextTokens     = 32000
+)
+
+// charsPerToken is a rough average for English text across the models we proxy to
+const charsPerToken = 4
+
+// PrepareContextRequest asks for a transcript packed into a token budget for a task
+type PrepareContextRequest struct {
+	TranscriptID string `json:"transcript_id"`        // file_uploads record holding the transcription
+	TaskType     string `json:"task_type"`            // same values as TextProcessingRequest.TaskType
+	Query        string `json:"query,omitempty"`      // optional focus, e.g. the user's chat message
+	MaxTokens    int    `json:"max_tokens,omitempty"` // defaults to 4000
+}
+
+// ContextSegment is one transcript segment included in a prepared context
+type ContextSegment struct {
+	Index int     `json:"index"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+// PreparedContext is a pre-formatted context block ready to embed in a prompt
+type PreparedContext struct {
+	TranscriptID    string           `json:"transcript_id"`
+	TaskType        string           `json:"task_type"`
+	Context         string           `json:"context"`
+	Segments        []ContextSegment `json:"segments"`
+	EstimatedTokens int              `json:"estimated_tokens"`
+	TotalSegments   int              `json:"total_segments"`
+	Truncated       bool             `json:"truncated"`
+}
+
+// contextStrategy weighs relevance against recency when segments don't all fit
+type contextStrategy struct {
+	Relevance float64
+	Recency   float64
+}
+
+// contextStrategies are tuned per task: chat follows the conversation's topic,
+// highlights favour salient moments, and edit tasks want even coverage of the recording
+var contextStrategies = map[string]contextStrategy{
+	"chat":               {Relevance: 0.7, Recency: 0.3},
+	"suggest_highlights": {Relevance: 0.9, Recency: 0.1},
+	"reorder":            {Relevance: 0.5, Recency: 0.5},
+	"improve_silences":   {Relevance: 0.3, Recency: 0.7},
+}
+
+var defaultContextStrategy = contextStrategy{Relevance: 0.5, Recency: 0.5}
+
+// contextSegment is the input to packing - timestamps are zero for plain-text transcripts
+type contextSegment struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// PrepareContextHandler returns a token-budgeted context block for one of the user's transcripts
+func PrepareContextHandler(e *core.RequestEvent, app core.App) error {
+	startTime := time.Now()
+	clientIP := getClientIP(e)
+	logger := logging.FromRequest(e).With("endpoint", "prepare_context", "ip", clientIP)
+
+	user, _, err := authenticateAPIKey(e, app, ScopeAIText)
+	if errors.Is(err, errMissingAPIKey) {
+		logger.Warn("Context request rejected", "reason", "missing_api_key")
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		logger.Warn("Context request rejected", "reason", "invalid_api_key", "error", err)
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+
+	var request PrepareContextRequest
+	if err := e.BindBody(&request); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request format"})
+	}
+	if request.TranscriptID == "" {
+		return e.JSON(400, map[string]string{"error": "transcript_id is required"})
+	}
+
+	maxTokens := request.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultContextTokens
+	}
+	if maxTokens < minContextTokens || maxTokens > maxContextTokens {
+		return e.JSON(400, map[string]string{"error": fmt.Sprintf("max_tokens must be between %d and %d", minContextTokens, maxContextTokens)})
+	}
+
+	// Transcripts belong to the uploading user - report others as missing rather than forbidden
+	record, err := app.FindRecordById("file_uploads", request.TranscriptID)
+	if err != nil || record.GetString("user") != user.Id {
+		return e.JSON(404, map[string]string{"error": "Transcript not found"})
+	}
+
+	segments := transcriptSegments(record)
+	if len(segments) == 0 {
+		return e.JSON(409, map[string]string{"error": "Transcript is not available yet"})
+	}
+
+	strategy, ok := contextStrategies[request.TaskType]
+	if !ok {
+		strategy = defaultContextStrategy
+	}
+
+	prepared := packContext(segments, strategy, request.Query, maxTokens)
+	prepared.TranscriptID = record.Id
+	prepared.TaskType = request.TaskType
+
+	logger.Info("Context prepared", "user_id", user.Id, "transcript_id", record.Id, "task", request.TaskType,
+		"segments", len(prepared.Segments), "total_segments", prepared.TotalSegments,
+		"estimated_tokens", prepared.EstimatedTokens, "max_tokens", maxTokens,
+		"duration_ms", time.Since(startTime).Milliseconds())
+
+	return e.JSON(200, prepared)
+}
+
+// transcriptSegments reads the timestamped segments of a transcription, falling back
+// to the plain transcript split into sentences for uploads without segment data
+func transcriptSegments(record *core.Record) []contextSegment {
+	var result whisper.Result
+	if raw := record.GetString("transcription_result"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &result); err != nil {
+			slog.Warn("Unreadable transcription_result", "record_id", record.Id, "error", err)
+		}
+	}
+
+	segments := []contextSegment{}
+	for _, s := range result.Segments {
+		if text := strings.TrimSpace(s.Text); text != "" {
+			segments = append(segments, contextSegment{Start: s.Start, End: s.End, Text: text})
+		}
+	}
+	if len(segments) > 0 {
+		return segments
+	}
+
+	transcript := result.Transcript
+	if transcript == "" {
+		transcript = record.GetString("transcript")
+	}
+	for _, sentence := range splitSentences(transcript) {
+		segments = append(segments, contextSegment{Text: sentence})
+	}
+	return segments
+}
+
+// packContext selects the highest scoring segments that fit the token budget
+// and formats them in their original order, marking where segments were skipped
+func packContext(segments []contextSegment, strategy contextStrategy, query string, maxTokens int) PreparedContext {
+	relevance := relevanceScores(segments, query)
+
+	scored := make([]ContextSegment, len(segments))
+	for i, s := range segments {
+		recency := 1.0
+		if len(segments) > 1 {
+			recency = float64(i) / float64(len(segments)-1)
+		}
+		scored[i] = ContextSegment{
+			Index: i,
+			Start: s.Start,
+			End:   s.End,
+			Text:  s.Text,
+			Score: math.Round((strategy.Relevance*relevance[i]+strategy.Recency*recency)*1000) / 1000,
+		}
+	}
+
+	ranked := make([]ContextSegment, len(scored))
+	copy(ranked, scored)
+	sort.SliceStable(ranked, func(a, b int) bool { return ranked[a].Score > ranked[b].Score })
+
+	// Greedy fill: a long segment that doesn't fit doesn't stop shorter ones from being packed
+	// Each line also reserves room for its newline and a possible "[...]" gap marker
+	budget := maxTokens
+	selected := []ContextSegment{}
+	for _, s := range ranked {
+		cost := estimateTokens(formatContextLine(s)) + 3
+		if cost > budget {
+			continue
+		}
+		budget -= cost
+		selected = append(selected, s)
+	}
+	sort.Slice(selected, func(a, b int) bool { return selected[a].Index < selected[b].Index })
+
+	lines := make([]string, 0, len(selected))
+	for i, s := range selected {
+		if (i == 0 && s.Index > 0) || (i > 0 && s.Index != selected[i-1].Index+1) {
+			lines = append(lines, "[...]")
+		}
+		lines = append(lines, formatContextLine(s))
+	}
+	if len(selected) > 0 && selected[len(selected)-1].Index < len(segments)-1 {
+		lines = append(lines, "[...]")
+	}
+
+	block := strings.Join(lines, "\n")
+	return PreparedContext{
+		Context:         block,
+		Segments:        selected,
+		EstimatedTokens: estimateTokens(block),
+		TotalSegments:   len(segments),
+		Truncated:       len(selected) < len(segments),
+	}
+}
+
+// relevanceScores rates each segment between 0 and 1 - by overlap with the query when
+// one is given, otherwise by how many of the transcript's recurring terms it contains
+func relevanceScores(segments []contextSegment, query string) []float64 {
+	scores := make([]float64, len(segments))
+	terms := make([][]string, len(segments))
+	for i, s := range segments {
+		terms[i] = contextTerms(s.Text)
+	}
+
+	weights := map[string]float64{}
+	if queryTerms := contextTerms(query); len(queryTerms) > 0 {
+		for _, t := range queryTerms {
+			weights[t] = 1
+		}
+	} else {
+		for _, segmentTerms := range terms {
+			seen := map[string]bool{}
+			for _, t := range segmentTerms {
+				if !seen[t] {
+					weights[t]++
+					seen[t] = true
+				}
+			}
+		}
+		// Terms said only once carry no signal about what the recording is about
+		for t, count := range weights {
+			if count < 2 {
+				delete(weights, t)
+			}
+		}
+	}
+
+	highest := 0.0
+	for i, segmentTerms := range terms {
+		for _, t := range segmentTerms {
+			scores[i] += weights[t]
+		}
+		if len(segmentTerms) > 0 {
+			scores[i] /= math.Sqrt(float64(len(segmentTerms)))
+		}
+		highest = math.Max(highest, scores[i])
+	}
+
+	if highest > 0 {
+		for i := range scores {
+			scores[i] /= highest
+		}
+	}
+	return scores
+}
+
+// contextStopWords are ignored when scoring relevance
+var contextStopWords = map[string]bool{
+	"the": true, "and": true, "that": true, "this": true, "with": true, "for": true, "you": true,
+	"are": true, "was": true, "but": true, "have": true, "not": true, "they": true, "what": true,
+	"from": true, "just": true, "like": true, "about": true, "there": true, "their": true,
+	"will": true, "would": true, "can": true, "your": true, "it's": true, "i'm": true, "so": true,
+	"um": true, "uh": true, "yeah": true, "know": true, "really": true, "then": true, "when": true,
+}
+
+func contextTerms(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.Trim(f, "'")
+		if len(f) < 3 || contextStopWords[f] {
+			continue
+		}
+		terms = append(terms, f)
+	}
+	return terms
+}
+
+func splitSentences(text string) []string {
+	sentences := []string{}
+	start := 0
+	for i, r := range text {
+		if r == '.' || r == '!' || r == '?' {
+			if sentence := strings.TrimSpace(text[start : i+1]); sentence != "" {
+				sentences = append(sentences, sentence)
+			}
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		sentences = append(sentences, rest)
+	}
+	return sentences
+}
+
+func formatContextLine(s ContextSegment) string {
+	if s.Start == 0 && s.End == 0 {
+		return s.Text
+	}
+	return fmt.Sprintf("[%s - %s] %s", formatTimestamp(s.Start), formatTimestamp(s.End), s.Text)
+}
+
+func formatTimestamp(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total%3600/60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
+
+func estimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackContextFitsBudget(t *testing.T) {
+	segments := []contextSegment{}
+	for i := 0; i < 50; i++ {
+		segments = append(segments, contextSegment{
+			Start: float64(i * 10),
+			End:   float64(i*10 + 10),
+			Text:  "We talked about the product launch timeline and the marketing budget for next quarter.",
+		})
+	}
+
+	prepared := packContext(segments, defaultContextStrategy, "", 200)
+
+	if prepared.EstimatedTokens > 200 {
+		t.Errorf("expected at most 200 tokens, got %d", prepared.EstimatedTokens)
+	}
+	if !prepared.Truncated {
+		t.Error("expected the context to be truncated")
+	}
+	for i := 1; i < len(prepared.Segments); i++ {
+		if prepared.Segments[i].Index <= prepared.Segments[i-1].Index {
+			t.Fatal("expected segments in transcript order")
+		}
+	}
+	if !strings.Contains(prepared.Context, "[...]") {
+		t.Error("expected skipped segments to be marked")
+	}
+}
+
+func TestPackContextPrefersQueryMatches(t *testing.T) {
+	segments := []contextSegment{
+		{Start: 0, End: 5, Text: "Welcome everyone to the weekly sync."},
+		{Start: 5, End: 10, Text: "The database migration is blocked on the schema review."},
+		{Start: 10, End: 15, Text: "Lunch will be served at noon in the main hall today."},
+		{Start: 15, End: 20, Text: "Thanks all, see you next week."},
+	}
+
+	prepared := packContext(segments, contextStrategies["chat"], "database migration", 25)
+
+	if len(prepared.Segments) == 0 || prepared.Segments[0].Index != 1 {
+		t.Fatalf("expected the migration segment to be selected, got %+v", prepared.Segments)
+	}
+	if !strings.HasPrefix(prepared.Context, "[...]\n[00:05 - 00:10]") {
+		t.Errorf("unexpected context format: %q", prepared.Context)
+	}
+}
+
+func TestPackContextKeepsEverythingWhenItFits(t *testing.T) {
+	segments := []contextSegment{{Text: "First sentence."}, {Text: "Second sentence."}}
+
+	prepared := packContext(segments, defaultContextStrategy, "", 1000)
+
+	if prepared.Truncated || prepared.Context != "First sentence.\nSecond sentence." {
+		t.Errorf("expected the full transcript, got %+v", prepared)
+	}
+}
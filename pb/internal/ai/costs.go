@@ -0,0 +1,20 @@
+package ai
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/costs"
+)
+
+// recordCost adds an upstream AI call to the cost ledger under the user's current plan. Failures
+// are logged and never fail the request
+func recordCost(app core.App, logger *slog.Logger, entry costs.Entry) {
+	if plan, _ := userPlanLimit(app, entry.UserID); plan != nil {
+		entry.PlanID = plan.Id
+	}
+	if err := costs.Record(app, entry); err != nil {
+		logger.Warn("Failed to record AI cost", "kind", entry.Kind, "model", entry.Model, "error", err)
+	}
+}
@@ -0,0 +1,89 @@
+package ai
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/whisper"
+)
+
+// audioDedupWindow reads AUDIO_DEDUP_DAYS: how long a completed transcript is reused when the
+// same user uploads an identical file (default 7 days, 0 disables deduplication)
+func audioDedupWindow() time.Duration {
+	days := 7
+	if v, err := strconv.Atoi(os.Getenv("AUDIO_DEDUP_DAYS")); err == nil && v >= 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// hashAudio returns the hex SHA-256 of an upload, read in one streaming pass, and rewinds it
+func hashAudio(file io.ReadSeeker) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// dedupCandidates caps how many earlier transcriptions of the same file are checked for matching options
+const dedupCandidates = 10
+
+// findDuplicateTranscript returns the user's latest completed transcription of a file with
+// contentHash, made with the same Whisper options, from within the dedup window, or nil
+func findDuplicateTranscript(app core.App, userID, contentHash string, options whisper.Options, now time.Time) (*core.Record, *whisper.Result) {
+	window := audioDedupWindow()
+	if window <= 0 || contentHash == "" {
+		return nil, nil
+	}
+
+	records, err := app.FindRecordsByFilter("processed_files",
+		"user_id = {:user} && content_hash = {:hash} && status = 'completed' && is_chunk = false && deleted_at = '' && created >= {:since}",
+		"-created", dedupCandidates, 0,
+		dbx.Params{"user": userID, "hash": contentHash, "since": now.Add(-window).UTC().Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, record := range records {
+		// A transcript in another language or style isn't a duplicate
+		var recorded whisper.Options
+		record.UnmarshalJSONField("transcription_options", &recorded)
+		if !sameTranscriptionOptions(recorded, options) {
+			continue
+		}
+
+		var result whisper.Result
+		if err := record.UnmarshalJSONField("transcription_result", &result); err != nil || result.Transcript == "" {
+			return nil, nil
+		}
+		return record, &result
+	}
+	return nil, nil
+}
+
+// sameTranscriptionOptions reports whether two sets of Whisper options produce the same transcript
+func sameTranscriptionOptions(a, b whisper.Options) bool {
+	if (a.Temperature == nil) != (b.Temperature == nil) || (a.Temperature != nil && *a.Temperature != *b.Temperature) {
+		return false
+	}
+	return a.Language == b.Language && a.Prompt == b.Prompt && a.Translate == b.Translate && a.Diarize == b.Diarize
+}
+
+// isForcedAudioRequest reports whether the client asked to transcribe again with force=true
+func isForcedAudioRequest(e *core.RequestEvent) bool {
+	return e.Request.FormValue("force") == "true" || e.Request.URL.Query().Get("force") == "true"
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"pocketbase/internal/whisper"
+)
+
+func TestHashAudioRewinds(t *testing.T) {
+	file := strings.NewReader("hello")
+	file.Seek(3, io.SeekStart) // probing leaves the reader anywhere
+
+	hash, err := hashAudio(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"; hash != want {
+		t.Errorf("hash = %s, want %s", hash, want)
+	}
+	if rest, _ := io.ReadAll(file); string(rest) != "hello" {
+		t.Errorf("reader not rewound, read %q", rest)
+	}
+}
+
+func TestAudioDedupWindow(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    7 * 24 * time.Hour,
+		"30":  30 * 24 * time.Hour,
+		"0":   0,
+		"-1":  7 * 24 * time.Hour,
+		"abc": 7 * 24 * time.Hour,
+	}
+	for value, want := range tests {
+		t.Setenv("AUDIO_DEDUP_DAYS", value)
+		if got := audioDedupWindow(); got != want {
+			t.Errorf("AUDIO_DEDUP_DAYS=%q: got %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestSameTranscriptionOptions(t *testing.T) {
+	low, high := 0.0, 0.5
+	if !sameTranscriptionOptions(whisper.Options{}, whisper.Options{}) {
+		t.Error("default options should match")
+	}
+	if sameTranscriptionOptions(whisper.Options{Language: "de"}, whisper.Options{}) {
+		t.Error("a language hint should not match detection")
+	}
+	if sameTranscriptionOptions(whisper.Options{Temperature: &low}, whisper.Options{}) {
+		t.Error("an explicit temperature should not match the default")
+	}
+	if sameTranscriptionOptions(whisper.Options{Temperature: &low}, whisper.Options{Temperature: &high}) {
+		t.Error("different temperatures should not match")
+	}
+	if !sameTranscriptionOptions(whisper.Options{Prompt: "x", Temperature: &high}, whisper.Options{Prompt: "x", Temperature: &high}) {
+		t.Error("identical options should match")
+	}
+}
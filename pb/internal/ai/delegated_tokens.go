@@ -0,0 +1,317 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/logging"
+)
+
+// Delegated tokens are short-lived credentials a signed-in user hands to the desktop app in
+// place of a permanent API key. Each exchange creates a grant in delegated_tokens: its access
+// tokens ("rd-" + a JWT) are accepted wherever API keys are, and are renewed with a refresh
+// token ("rr-...") that is replaced on every use. Revoking the grant stops both straight away
+const (
+	DelegatedTokenPrefix = "rd-"
+	refreshTokenPrefix   = "rr-"
+	delegatedTokenType   = "delegated"
+)
+
+// DelegationConfig is how long delegated tokens last
+type DelegationConfig struct {
+	AccessTTL  time.Duration // access token lifetime
+	RefreshTTL time.Duration // a grant expires when it isn't refreshed for this long
+}
+
+// DelegationConfigFromEnv reads DELEGATED_TOKEN_TTL_HOURS (default 24) and
+// DELEGATED_REFRESH_TTL_DAYS (default 30)
+func DelegationConfigFromEnv() DelegationConfig {
+	config := DelegationConfig{AccessTTL: 24 * time.Hour, RefreshTTL: 30 * 24 * time.Hour}
+	if v, err := strconv.Atoi(os.Getenv("DELEGATED_TOKEN_TTL_HOURS")); err == nil && v > 0 {
+		config.AccessTTL = time.Duration(v) * time.Hour
+	}
+	if v, err := strconv.Atoi(os.Getenv("DELEGATED_REFRESH_TTL_DAYS")); err == nil && v > 0 {
+		config.RefreshTTL = time.Duration(v) * 24 * time.Hour
+	}
+	return config
+}
+
+// DelegatedTokens is a token exchange or refresh response
+type DelegatedTokens struct {
+	AccessToken      string   `json:"access_token"`
+	TokenType        string   `json:"token_type"`
+	ExpiresIn        int      `json:"expires_in"` // seconds
+	ExpiresAt        string   `json:"expires_at"`
+	RefreshToken     string   `json:"refresh_token"`
+	RefreshExpiresAt string   `json:"refresh_expires_at"`
+	GrantID          string   `json:"grant_id"`
+	Scopes           []string `json:"scopes"`
+}
+
+// delegatedSigningKey combines the grant's own key with the users collection token secret, so
+// tokens can't be forged from the database alone
+func delegatedSigningKey(app core.App, grant *core.Record) (string, error) {
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return "", err
+	}
+	return grant.GetString("token_key") + users.AuthToken.Secret, nil
+}
+
+// issueDelegatedTokens mints an access token for a grant and replaces its refresh token,
+// pushing the grant's expiry back by the refresh lifetime
+func issueDelegatedTokens(app core.App, grant *core.Record, config DelegationConfig, now time.Time) (*DelegatedTokens, error) {
+	refreshToken := refreshTokenPrefix + security.RandomString(40)
+	grant.Set("refresh_hash", hashAPIKey(refreshToken))
+	grant.Set("expires_at", now.Add(config.RefreshTTL))
+	grant.Set("last_refreshed_at", now)
+	if err := app.Save(grant); err != nil {
+		return nil, fmt.Errorf("failed to save delegated token grant: %w", err)
+	}
+
+	key, err := delegatedSigningKey(app, grant)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := security.NewJWT(jwt.MapClaims{
+		"type": delegatedTokenType,
+		"gid":  grant.Id,
+		"uid":  grant.GetString("user_id"),
+	}, key, config.AccessTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign delegated token: %w", err)
+	}
+
+	return &DelegatedTokens{
+		AccessToken:      DelegatedTokenPrefix + accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(config.AccessTTL.Seconds()),
+		ExpiresAt:        now.Add(config.AccessTTL).UTC().Format(time.RFC3339),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: now.Add(config.RefreshTTL).UTC().Format(time.RFC3339),
+		GrantID:          grant.Id,
+		Scopes:           keyScopes(grant),
+	}, nil
+}
+
+// verifyDelegatedToken checks an access token's signature and expiry, and that its grant is
+// still live and its user active. It has no side effects, unlike lookupDelegatedToken
+func verifyDelegatedToken(app core.App, token string) (*core.Record, *core.Record, error) {
+	raw := strings.TrimPrefix(token, DelegatedTokenPrefix)
+	claims, err := security.ParseUnverifiedJWT(raw)
+	if err != nil || claims["type"] != delegatedTokenType {
+		return nil, nil, errors.New("invalid delegated token")
+	}
+	grantID, _ := claims["gid"].(string)
+	grant, err := app.FindRecordById("delegated_tokens", grantID)
+	if err != nil {
+		return nil, nil, errors.New("delegated token not found")
+	}
+
+	key, err := delegatedSigningKey(app, grant)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := security.ParseJWT(raw, key); err != nil {
+		return nil, nil, errors.New("invalid or expired delegated token")
+	}
+	if grant.GetBool("revoked") {
+		return nil, nil, errors.New("delegated token revoked")
+	}
+
+	user, err := app.FindRecordById("users", grant.GetString("user_id"))
+	if err != nil {
+		return nil, nil, errors.New("user not found")
+	}
+	if accounts.IsDeactivated(user) {
+		return nil, nil, errors.New("account is deactivated")
+	}
+	return grant, user, nil
+}
+
+// lookupDelegatedToken verifies an access token and notes that its grant was used
+func lookupDelegatedToken(app core.App, token string) (*core.Record, *core.Record, error) {
+	grant, user, err := verifyDelegatedToken(app, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	markAPIKeyUsed(app, grant, time.Now())
+	return grant, user, nil
+}
+
+// revokeGrant stops a grant's access and refresh tokens
+func revokeGrant(app core.App, grant *core.Record) error {
+	if grant.GetBool("revoked") {
+		return nil
+	}
+	grant.Set("revoked", true)
+	grant.Set("revoked_at", time.Now())
+	grant.Set("refresh_hash", "")
+	return app.Save(grant)
+}
+
+// CreateDelegatedTokenHandler exchanges a user session for a delegated token pair. Optional
+// scopes limit what the tokens can do, as for API keys
+// POST /api/auth/delegated-tokens
+func CreateDelegatedTokenHandler(e *core.RequestEvent, app core.App) error {
+	logger := logging.FromRequest(e).With("endpoint", "delegated_token_create", "ip", getClientIP(e))
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(401, map[string]string{"error": "Authentication required"})
+	}
+	if accounts.IsDeactivated(e.Auth) {
+		return e.JSON(403, map[string]string{"error": "Account is deactivated"})
+	}
+
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if e.Request.ContentLength != 0 {
+		if err := e.BindBody(&req); err != nil {
+			return e.JSON(400, map[string]string{"error": "Invalid request format"})
+		}
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = "Desktop app"
+	}
+	if len(req.Name) > 100 {
+		return e.JSON(400, map[string]string{"error": "name must be at most 100 characters"})
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	collection, err := app.FindCollectionByNameOrId("delegated_tokens")
+	if err != nil {
+		logger.Error("Failed to find delegated tokens collection", "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to create delegated token"})
+	}
+	grant := core.NewRecord(collection)
+	grant.Set("user_id", e.Auth.Id)
+	grant.Set("name", req.Name)
+	grant.Set("scopes", scopes)
+	grant.Set("token_key", security.RandomString(50))
+	grant.Set("client_ip", getClientIP(e))
+	grant.Set("user_agent", e.Request.Header.Get("User-Agent"))
+
+	tokens, err := issueDelegatedTokens(app, grant, DelegationConfigFromEnv(), time.Now())
+	if err != nil {
+		logger.Error("Failed to issue delegated token", "user_id", e.Auth.Id, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to create delegated token"})
+	}
+
+	logger.Info("Delegated token issued", "user_id", e.Auth.Id, "grant_id", grant.Id, "scopes", scopes)
+	return e.JSON(200, tokens)
+}
+
+// errRefreshTokenInvalid is a refresh token that's unknown, already used, revoked or expired,
+// or whose user is deactivated
+var errRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+
+// RefreshDelegatedTokenHandler swaps a refresh token for a new access and refresh token. The
+// old refresh token stops working, so clients must keep the new one. The lookup and the swap
+// run in one transaction, so a refresh token replayed concurrently is only accepted once
+// POST /api/auth/delegated-tokens/refresh
+func RefreshDelegatedTokenHandler(e *core.RequestEvent, app core.App) error {
+	logger := logging.FromRequest(e).With("endpoint", "delegated_token_refresh", "ip", getClientIP(e))
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := e.BindBody(&req); err != nil || !strings.HasPrefix(req.RefreshToken, refreshTokenPrefix) {
+		return e.JSON(400, map[string]string{"error": "refresh_token is required"})
+	}
+
+	now := time.Now()
+	var tokens *DelegatedTokens
+	err := app.RunInTransaction(func(txApp core.App) error {
+		grant, err := txApp.FindFirstRecordByFilter("delegated_tokens", "refresh_hash = {:hash} && revoked = false",
+			map[string]any{"hash": hashAPIKey(req.RefreshToken)})
+		if err != nil || now.After(grant.GetDateTime("expires_at").Time()) {
+			return errRefreshTokenInvalid
+		}
+		user, err := txApp.FindRecordById("users", grant.GetString("user_id"))
+		if err != nil || accounts.IsDeactivated(user) {
+			return errRefreshTokenInvalid
+		}
+		tokens, err = issueDelegatedTokens(txApp, grant, DelegationConfigFromEnv(), now)
+		return err
+	})
+	if errors.Is(err, errRefreshTokenInvalid) {
+		logger.Warn("Delegated token refresh rejected", "reason", "invalid_refresh_token")
+		return e.JSON(401, map[string]string{"error": "Invalid or expired refresh token", "code": "REFRESH_TOKEN_INVALID"})
+	}
+	if err != nil {
+		logger.Error("Failed to refresh delegated token", "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to refresh delegated token"})
+	}
+	return e.JSON(200, tokens)
+}
+
+// ListDelegatedTokensHandler lists the user's live grants - where the desktop app is signed in
+// GET /api/auth/delegated-tokens
+func ListDelegatedTokensHandler(e *core.RequestEvent, app core.App) error {
+	user, currentID, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	grants, err := app.FindRecordsByFilter("delegated_tokens", "user_id = {:user} && revoked = false && expires_at > {:now}",
+		"-last_refreshed_at", 0, 0, map[string]any{"user": user.Id, "now": time.Now().UTC().Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load delegated tokens"})
+	}
+
+	listed := make([]map[string]any, 0, len(grants))
+	for _, grant := range grants {
+		item := map[string]any{
+			"id":                grant.Id,
+			"name":              grant.GetString("name"),
+			"scopes":            keyScopes(grant),
+			"full_access":       len(grant.GetStringSlice("scopes")) == 0,
+			"client_ip":         grant.GetString("client_ip"),
+			"user_agent":        grant.GetString("user_agent"),
+			"current":           grant.Id == currentID,
+			"created":           grant.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+			"last_refreshed_at": grant.GetDateTime("last_refreshed_at").Time().UTC().Format(time.RFC3339),
+			"expires_at":        grant.GetDateTime("expires_at").Time().UTC().Format(time.RFC3339),
+		}
+		if last := grant.GetDateTime("last_used_at"); !last.IsZero() {
+			item["last_used_at"] = last.Time().UTC().Format(time.RFC3339)
+		}
+		listed = append(listed, item)
+	}
+	return e.JSON(200, map[string]any{"tokens": listed})
+}
+
+// RevokeDelegatedTokenHandler revokes a grant, e.g. a lost laptop from the web app, or the
+// desktop app signing out with its own token. Its access tokens fail from the next request on
+// POST /api/auth/delegated-tokens/{id}/revoke
+func RevokeDelegatedTokenHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := keyManager(e, app, ScopeKeysManage)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	grant, err := app.FindRecordById("delegated_tokens", e.Request.PathValue("id"))
+	if err != nil || grant.GetString("user_id") != user.Id {
+		return e.JSON(404, map[string]string{"error": "Delegated token not found"})
+	}
+	if err := revokeGrant(app, grant); err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to revoke delegated token"})
+	}
+
+	logging.FromRequest(e).Info("Delegated token revoked", "user_id", user.Id, "grant_id", grant.Id)
+	return e.JSON(200, map[string]any{"id": grant.Id, "revoked": true})
+}
@@ -0,0 +1,187 @@
+//go:build integration
+
+package ai_test
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/ai"
+	"pocketbase/internal/testharness"
+)
+
+// issueDelegated exchanges the user's session for a delegated token pair
+func issueDelegated(t *testing.T, server *testharness.Server, user *core.Record, body any) (access, refresh, grantID string) {
+	t.Helper()
+	issued := server.Do(t, http.MethodPost, "/api/auth/delegated-tokens", testharness.AuthToken(t, user), body).
+		Expect(t, http.StatusOK).JSON(t)
+	access, _ = issued["access_token"].(string)
+	refresh, _ = issued["refresh_token"].(string)
+	grantID, _ = issued["grant_id"].(string)
+	if access == "" || refresh == "" || grantID == "" {
+		t.Fatalf("incomplete token response: %v", issued)
+	}
+	return access, refresh, grantID
+}
+
+func TestDelegatedTokens(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "laptop@test.com")
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	server.Do(t, http.MethodPost, "/api/auth/delegated-tokens", "", nil).Expect(t, http.StatusUnauthorized)
+
+	access, refresh, grantID := issueDelegated(t, server, user, map[string]any{"name": "Work laptop"})
+	if !strings.HasPrefix(access, ai.DelegatedTokenPrefix) {
+		t.Errorf("access token = %q, want the %s prefix", access, ai.DelegatedTokenPrefix)
+	}
+	server.Do(t, http.MethodGet, "/api/usage/files", access, nil).Expect(t, http.StatusOK)
+
+	listed := server.Do(t, http.MethodGet, "/api/auth/delegated-tokens", access, nil).Expect(t, http.StatusOK).JSON(t)
+	tokens, _ := listed["tokens"].([]any)
+	if len(tokens) != 1 || tokens[0].(map[string]any)["current"] != true || tokens[0].(map[string]any)["name"] != "Work laptop" {
+		t.Errorf("listed = %v, want the current grant", listed)
+	}
+
+	// Refreshing replaces the refresh token; the old one is rejected from then on
+	refreshed := server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/refresh", "", map[string]any{"refresh_token": refresh}).
+		Expect(t, http.StatusOK).JSON(t)
+	if refreshed["grant_id"] != grantID || refreshed["refresh_token"] == refresh {
+		t.Errorf("refreshed = %v, want a new refresh token for grant %s", refreshed, grantID)
+	}
+	rejected := server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/refresh", "", map[string]any{"refresh_token": refresh}).
+		Expect(t, http.StatusUnauthorized).JSON(t)
+	if rejected["code"] != "REFRESH_TOKEN_INVALID" {
+		t.Errorf("reused refresh token: code %v", rejected["code"])
+	}
+	access, _ = refreshed["access_token"].(string)
+	server.Do(t, http.MethodGet, "/api/usage/files", access, nil).Expect(t, http.StatusOK)
+
+	// Revoking stops the access token on the next request, and the refresh token with it
+	server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/"+grantID+"/revoke", access, nil).Expect(t, http.StatusOK)
+	server.Do(t, http.MethodGet, "/api/usage/files", access, nil).Expect(t, http.StatusUnauthorized)
+	server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/refresh", "", map[string]any{"refresh_token": refreshed["refresh_token"]}).
+		Expect(t, http.StatusUnauthorized)
+}
+
+func TestDelegatedTokenForgedOrExpired(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "forger@test.com")
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+	_, _, grantID := issueDelegated(t, server, user, nil)
+
+	grant, err := app.FindRecordById("delegated_tokens", grantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := grant.GetString("token_key") + users.AuthToken.Secret
+	sign := func(gid, key string, duration time.Duration) string {
+		token, err := security.NewJWT(jwt.MapClaims{"type": "delegated", "gid": gid, "uid": user.Id}, key, duration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ai.DelegatedTokenPrefix + token
+	}
+
+	tests := map[string]string{
+		"wrong key":      sign(grant.Id, "not-the-signing-key", time.Hour),
+		"grant key only": sign(grant.Id, grant.GetString("token_key"), time.Hour),
+		"expired":        sign(grant.Id, key, -time.Minute),
+		"unknown grant":  sign("missing", key, time.Hour),
+		"not a JWT":      ai.DelegatedTokenPrefix + "garbage",
+	}
+	for name, token := range tests {
+		if got := server.Do(t, http.MethodGet, "/api/usage/files", token, nil).Code; got != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, got)
+		}
+	}
+
+	// The same claims signed with the real key are accepted
+	server.Do(t, http.MethodGet, "/api/usage/files", sign(grant.Id, key, time.Hour), nil).Expect(t, http.StatusOK)
+}
+
+func TestDelegatedTokenDeactivatedUser(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "leaving@test.com")
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+	access, refresh, _ := issueDelegated(t, server, user, nil)
+
+	if err := accounts.Deactivate(app, nil, user, "test"); err != nil {
+		t.Fatal(err)
+	}
+	server.Do(t, http.MethodGet, "/api/usage/files", access, nil).Expect(t, http.StatusUnauthorized)
+	server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/refresh", "", map[string]any{"refresh_token": refresh}).
+		Expect(t, http.StatusUnauthorized)
+}
+
+func TestDelegatedTokenScopes(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "scoped@test.com")
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	server.Do(t, http.MethodPost, "/api/auth/delegated-tokens", testharness.AuthToken(t, user),
+		map[string]any{"scopes": []string{"everything"}}).Expect(t, http.StatusBadRequest)
+
+	access, refresh, grantID := issueDelegated(t, server, user, map[string]any{"scopes": []string{ai.ScopeUsageRead}})
+	server.Do(t, http.MethodGet, "/api/usage/files", access, nil).Expect(t, http.StatusOK)
+	denied := server.Do(t, http.MethodGet, "/api/auth/delegated-tokens", access, nil).Expect(t, http.StatusForbidden).JSON(t)
+	if denied["code"] != "INSUFFICIENT_SCOPE" || denied["required_scope"] != ai.ScopeKeysManage {
+		t.Errorf("denied = %v", denied)
+	}
+	server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/"+grantID+"/revoke", access, nil).Expect(t, http.StatusForbidden)
+
+	// Refreshed tokens keep the grant's scopes
+	refreshed := server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/refresh", "", map[string]any{"refresh_token": refresh}).
+		Expect(t, http.StatusOK).JSON(t)
+	access, _ = refreshed["access_token"].(string)
+	server.Do(t, http.MethodGet, "/api/auth/delegated-tokens", access, nil).Expect(t, http.StatusForbidden)
+}
+
+func TestRefreshTokenReplayedConcurrently(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "desktop@test.com")
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	issued := server.Do(t, http.MethodPost, "/api/auth/delegated-tokens", testharness.AuthToken(t, user), nil).
+		Expect(t, http.StatusOK).JSON(t)
+	refresh := map[string]any{"refresh_token": issued["refresh_token"]}
+
+	// Widen the window between looking the refresh token up and replacing it
+	app.OnRecordUpdate("delegated_tokens").BindFunc(func(e *core.RecordEvent) error {
+		time.Sleep(20 * time.Millisecond)
+		return e.Next()
+	})
+
+	const attempts = 8
+	codes := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- server.Do(t, http.MethodPost, "/api/auth/delegated-tokens/refresh", "", refresh).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusUnauthorized] != attempts-1 {
+		t.Errorf("responses = %v, want one 200 and the rest 401", counts)
+	}
+}
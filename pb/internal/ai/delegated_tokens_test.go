@@ -0,0 +1,22 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelegationConfigFromEnv(t *testing.T) {
+	t.Setenv("DELEGATED_TOKEN_TTL_HOURS", "")
+	t.Setenv("DELEGATED_REFRESH_TTL_DAYS", "")
+	config := DelegationConfigFromEnv()
+	if config.AccessTTL != 24*time.Hour || config.RefreshTTL != 30*24*time.Hour {
+		t.Errorf("Unexpected defaults: %+v", config)
+	}
+
+	t.Setenv("DELEGATED_TOKEN_TTL_HOURS", "2")
+	t.Setenv("DELEGATED_REFRESH_TTL_DAYS", "0")
+	config = DelegationConfigFromEnv()
+	if config.AccessTTL != 2*time.Hour || config.RefreshTTL != 30*24*time.Hour {
+		t.Errorf("Expected a 2h access TTL and the default refresh TTL, got %+v", config)
+	}
+}
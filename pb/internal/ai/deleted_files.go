@@ -0,0 +1,180 @@
+package ai
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// notDeletedFilter keeps soft-deleted processed_files out of usage queries
+const notDeletedFilter = "deleted_at = ''"
+
+// deletedFileRestoreWindow reads PROCESSED_FILES_RESTORE_DAYS: how long a deleted processed file
+// can be restored before it's purged (default 30 days)
+func deletedFileRestoreWindow() time.Duration {
+	days := 30
+	if v, err := strconv.Atoi(os.Getenv("PROCESSED_FILES_RESTORE_DAYS")); err == nil && v >= 0 {
+		days = v
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// restoreDeadline returns when a processed file deleted at deletedAt stops being restorable
+func restoreDeadline(deletedAt time.Time) time.Time {
+	return deletedAt.Add(deletedFileRestoreWindow())
+}
+
+// findOwnedProcessedFile loads a flattened (non-chunk) processed file belonging to the user
+func findOwnedProcessedFile(app core.App, userID, fileID string) (*core.Record, error) {
+	record, err := app.FindRecordById("processed_files", fileID)
+	if err != nil || record.GetString("user_id") != userID || record.GetBool("is_chunk") {
+		return nil, errors.New("processed file not found")
+	}
+	return record, nil
+}
+
+// deletedFileResponse describes a soft-deleted processed file and how long it can be restored
+func deletedFileResponse(record *core.Record) map[string]any {
+	deletedAt := record.GetDateTime("deleted_at").Time()
+	return map[string]any{
+		"id":            record.Id,
+		"filename":      record.GetString("filename"),
+		"deleted_at":    deletedAt,
+		"restore_until": restoreDeadline(deletedAt),
+	}
+}
+
+// DeleteUsageFileHandler soft-deletes one of the user's processed files. It disappears from the
+// usage endpoints straight away and is purged once the restore window has passed
+// Hours already used this period aren't given back
+// DELETE /api/usage/files/{id}
+func DeleteUsageFileHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := authenticateAPIKey(e, app, ScopeUsageWrite)
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+	logger := logging.FromRequest(e).With("endpoint", "usage_file_delete", "user_id", user.Id)
+
+	record, err := findOwnedProcessedFile(app, user.Id, e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": "File not found"})
+	}
+	if !record.GetDateTime("deleted_at").IsZero() {
+		return e.JSON(200, deletedFileResponse(record))
+	}
+
+	record.Set("deleted_at", time.Now().UTC())
+	if err := app.Save(record); err != nil {
+		logger.Error("Failed to delete processed file", "file_id", record.Id, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to delete file"})
+	}
+
+	logger.Info("Processed file deleted", "file_id", record.Id)
+	return e.JSON(200, deletedFileResponse(record))
+}
+
+// RestoreUsageFileHandler undoes a deletion while the restore window is open
+// POST /api/usage/files/{id}/restore
+func RestoreUsageFileHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := authenticateAPIKey(e, app, ScopeUsageWrite)
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+
+	record, err := findOwnedProcessedFile(app, user.Id, e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": "File not found"})
+	}
+	return restoreProcessedFile(e, app, record)
+}
+
+// AdminRestoreUsageFileHandler restores any user's deleted processed file (superusers only)
+// POST /api/admin/usage/files/{id}/restore
+func AdminRestoreUsageFileHandler(e *core.RequestEvent, app core.App) error {
+	record, err := app.FindRecordById("processed_files", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": "File not found"})
+	}
+	return restoreProcessedFile(e, app, record)
+}
+
+func restoreProcessedFile(e *core.RequestEvent, app core.App, record *core.Record) error {
+	logger := logging.FromRequest(e).With("endpoint", "usage_file_restore", "user_id", record.GetString("user_id"))
+
+	deletedAt := record.GetDateTime("deleted_at")
+	if deletedAt.IsZero() {
+		return e.JSON(409, map[string]string{"error": "File is not deleted"})
+	}
+	if time.Now().After(restoreDeadline(deletedAt.Time())) {
+		return e.JSON(410, map[string]string{"error": "The restore window for this file has passed"})
+	}
+
+	record.Set("deleted_at", nil)
+	if err := app.Save(record); err != nil {
+		logger.Error("Failed to restore processed file", "file_id", record.Id, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to restore file"})
+	}
+
+	logger.Info("Processed file restored", "file_id", record.Id)
+	return e.JSON(200, map[string]any{"id": record.Id, "restored": true})
+}
+
+// deletedFilesSummary reports the user's deleted files that can still be restored
+func deletedFilesSummary(app core.App, userID string) map[string]any {
+	records, err := app.FindRecordsByFilter("processed_files",
+		"user_id = {:user} && deleted_at != '' && (is_chunk = false || is_chunk = '')", "", 0, 0,
+		map[string]any{"user": userID})
+	if err != nil {
+		records = nil
+	}
+	sizeBytes := int64(0)
+	for _, record := range records {
+		sizeBytes += int64(record.GetInt("file_size_bytes"))
+	}
+	return map[string]any{
+		"files":        len(records),
+		"size_bytes":   sizeBytes,
+		"restore_days": int(deletedFileRestoreWindow().Hours() / 24),
+	}
+}
+
+// PurgeDeletedFiles permanently removes processed files deleted longer ago than the restore window
+func PurgeDeletedFiles(app core.App) {
+	cutoff := time.Now().Add(-deletedFileRestoreWindow()).UTC().Format("2006-01-02 15:04:05.000Z")
+	records, err := app.FindRecordsByFilter("processed_files", "deleted_at != '' && deleted_at < {:cutoff}", "", 0, 0,
+		map[string]any{"cutoff": cutoff})
+	if err != nil {
+		slog.Error("Failed to load deleted processed files", "error", err)
+		return
+	}
+
+	purged := 0
+	for _, record := range records {
+		if err := app.Delete(record); err != nil {
+			slog.Warn("Failed to purge deleted processed file", "file_id", record.Id, "error", err)
+			continue
+		}
+		purged++
+	}
+	if purged > 0 {
+		slog.Info("Purged deleted processed files", "files", purged)
+	}
+}
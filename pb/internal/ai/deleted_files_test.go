@@ -0,0 +1,28 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletedFileRestoreWindow(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":    30 * 24 * time.Hour,
+		"7":   7 * 24 * time.Hour,
+		"0":   0,
+		"-1":  30 * 24 * time.Hour,
+		"abc": 30 * 24 * time.Hour,
+	}
+	for value, want := range tests {
+		t.Setenv("PROCESSED_FILES_RESTORE_DAYS", value)
+		if got := deletedFileRestoreWindow(); got != want {
+			t.Errorf("PROCESSED_FILES_RESTORE_DAYS=%q: got %v, want %v", value, got, want)
+		}
+	}
+
+	t.Setenv("PROCESSED_FILES_RESTORE_DAYS", "14")
+	deletedAt := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	if got := restoreDeadline(deletedAt); !got.Equal(time.Date(2025, time.March, 15, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("restoreDeadline = %v", got)
+	}
+}
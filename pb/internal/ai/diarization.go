@@ -0,0 +1,76 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/whisper"
+)
+
+// planHasDiarization reports whether a plan includes speaker diarization
+func planHasDiarization(plan *core.Record) bool {
+	return plan != nil && plan.GetBool("speaker_diarization")
+}
+
+// diarizationUpgradePlans returns the listed plans, cheapest first, that include speaker diarization
+func diarizationUpgradePlans(app core.App) []UpgradePlan {
+	plans, err := subscription.NewRepository(app).GetAllPlans()
+	if err != nil {
+		return nil
+	}
+	upgrades := []UpgradePlan{}
+	for _, plan := range plans {
+		if planHasDiarization(plan) {
+			upgrades = append(upgrades, UpgradePlan{ID: plan.Id, Name: plan.GetString("name"), PriceCents: plan.GetInt("price_cents")})
+		}
+	}
+	return upgrades
+}
+
+// checkDiarization rejects diarize=true when no diarizer is configured, for chunk uploads (speaker
+// labels wouldn't match across chunks) and for plans without speaker diarization
+// Returns handled=true once a response has been written
+func checkDiarization(e *core.RequestEvent, app core.App, logger *slog.Logger, userID string, isChunk bool) (handled bool, err error) {
+	if diarizer, err := whisper.DiarizerFromEnv(); diarizer == nil || err != nil {
+		logger.Warn("Audio request rejected", "reason", "diarization_unavailable", "error", err)
+		return true, e.JSON(503, map[string]string{"error": "Speaker diarization is not available", "code": "DIARIZATION_UNAVAILABLE"})
+	}
+	if isChunk {
+		logger.Warn("Audio request rejected", "reason", "diarization_chunk")
+		return true, e.JSON(400, map[string]string{"error": "Speaker diarization is not supported for chunk uploads - upload the whole file"})
+	}
+
+	plan, _ := userPlanLimit(app, userID)
+	if !planHasDiarization(plan) {
+		logger.Warn("Audio request rejected", "reason", "diarization_not_in_plan")
+		return true, e.JSON(403, map[string]any{
+			"error":         "Speaker diarization is not included in your plan - upgrade to use it",
+			"code":          "FEATURE_NOT_IN_PLAN",
+			"upgrade_plans": diarizationUpgradePlans(app),
+		})
+	}
+	return false, nil
+}
+
+// diarizeResult runs the configured diarizer over the audio and labels the result's segments
+func diarizeResult(ctx context.Context, audio io.Reader, filename string, result *whisper.Result) error {
+	diarizer, err := whisper.DiarizerFromEnv()
+	if err != nil {
+		return err
+	}
+	if diarizer == nil {
+		return fmt.Errorf("speaker diarization is not configured")
+	}
+
+	turns, err := diarizer.Diarize(ctx, audio, filename)
+	if err != nil {
+		return fmt.Errorf("%s diarization failed: %w", diarizer.Name(), err)
+	}
+	result.Speakers = whisper.AssignSpeakers(result.Segments, turns)
+	return nil
+}
@@ -2,23 +2,33 @@ package ai
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
-	"mime/multipart"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/hajimehoshi/go-mp3"
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/apisign"
+	"pocketbase/internal/audioprobe"
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/costs"
+	"pocketbase/internal/gateway"
+	"pocketbase/internal/keyguard"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/moderation"
+	"pocketbase/internal/organizations"
 	"pocketbase/internal/subscription"
+	"pocketbase/internal/whisper"
 )
 
 // TextProcessingRequest represents a request for text-based AI processing
@@ -28,20 +38,35 @@ type TextProcessingRequest struct {
 	Model        string                 `json:"model"`
 	TaskType     string                 `json:"task_type"` // "suggest_highlights", "reorder", "improve_silences", "chat"
 	Context      map[string]interface{} `json:"context,omitempty"`
+	Stream       bool                   `json:"stream,omitempty"` // relay the completion as Server-Sent Events
+
+	// followUp continues the conversation after the user prompt, e.g. to correct a malformed reply
+	followUp []Message
 }
 
-// TextProcessingResult represents the result of text processing
+// TextProcessingResult represents the result of text processing. Structured is the reply parsed
+// into its task's schema (see structuredTasks); ValidationError says why it couldn't be
 type TextProcessingResult struct {
-	Content    string      `json:"content"`
-	TaskType   string      `json:"task_type"`
-	Structured interface{} `json:"structured,omitempty"`
-	TokensUsed int         `json:"tokens_used,omitempty"`
+	Content         string      `json:"content"`
+	TaskType        string      `json:"task_type"`
+	Structured      interface{} `json:"structured,omitempty"`
+	TokensUsed      int         `json:"tokens_used,omitempty"`
+	ValidationError string      `json:"validation_error,omitempty"`
+	Attempts        int         `json:"attempts,omitempty"` // completions requested, corrective retries included
 }
 
 // OpenRouterRequest represents the request format for OpenRouter API
 type OpenRouterRequest struct {
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
+	// StreamOptions asks for a final chunk with token usage
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions are OpenAI-style streaming options
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // Message represents a chat message
@@ -52,11 +77,15 @@ type Message struct {
 
 // OpenRouterResponse represents the response from OpenRouter API
 type OpenRouterResponse struct {
-	Choices []Choice `json:"choices"`
+	Choices []Choice    `json:"choices"`
+	Usage   *TokenUsage `json:"usage,omitempty"`
 	Error   *struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
 	} `json:"error,omitempty"`
+	PaymentWarning *subscription.PaymentWarning `json:"payment_warning,omitempty"`
+	// Result is set for tasks with a structured reply, see validateStructuredReply
+	Result *TextProcessingResult `json:"result,omitempty"`
 }
 
 // Choice represents a response choice
@@ -66,181 +95,186 @@ type Choice struct {
 
 // AudioProcessingRequest is no longer used - audio streaming uses multipart form data directly
 
-// AudioProcessingResult represents the result of audio processing
-type AudioProcessingResult struct {
-	Transcript string    `json:"transcript"`
-	Duration   float64   `json:"duration,omitempty"`
-	Language   string    `json:"language,omitempty"`
-	Words      []Word    `json:"words,omitempty"`
-	Segments   []Segment `json:"segments,omitempty"`
-}
-
-// Word represents a word with timestamps
-type Word struct {
-	Word  string  `json:"word"`
-	Start float64 `json:"start"`
-	End   float64 `json:"end"`
-}
-
-// Segment represents a segment with timestamps
-type Segment struct {
-	ID               int     `json:"id"`
-	Seek             int     `json:"seek"`
-	Start            float64 `json:"start"`
-	End              float64 `json:"end"`
-	Text             string  `json:"text"`
-	Tokens           []int   `json:"tokens"`
-	Temperature      float64 `json:"temperature"`
-	AvgLogprob       float64 `json:"avg_logprob"`
-	CompressionRatio float64 `json:"compression_ratio"`
-	NoSpeechProb     float64 `json:"no_speech_prob"`
-	Words            []Word  `json:"words"`
-}
-
-// OpenAITranscriptionResponse represents the response from OpenAI transcription API
-type OpenAITranscriptionResponse struct {
-	Task     string    `json:"task"`
-	Language string    `json:"language"`
-	Duration float64   `json:"duration"`
-	Text     string    `json:"text"`
-	Segments []Segment `json:"segments"`
-	Words    []Word    `json:"words"`
-}
-
 // ProcessTextHandler handles text processing requests
 func ProcessTextHandler(e *core.RequestEvent, app core.App) error {
 	startTime := time.Now()
 	clientIP := getClientIP(e)
-	userAgent := e.Request.Header.Get("User-Agent")
-	
-	log.Printf("🤖 [AI TEXT REQUEST] IP: %s | User-Agent: %s | Method: %s", 
-		clientIP, userAgent, e.Request.Method)
+	logger := logging.FromRequest(e).With("endpoint", "process_text", "ip", clientIP)
 
-	// Validate API key
-	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
-	if apiKey == "" {
-		log.Printf("❌ [AI TEXT REQUEST] FAILED: Missing API key | IP: %s", clientIP)
+	logger.Debug("Text request received", "user_agent", e.Request.Header.Get("User-Agent"))
+
+	// Validate API key (Bearer or signed request)
+	user, maskedKey, err := authenticateAPIKey(e, app, ScopeAIText)
+	if errors.Is(err, errMissingAPIKey) {
+		logger.Warn("Text request rejected", "reason", "missing_api_key")
 		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
 	}
-
-	// Mask API key for logging (show first 8 chars)
-	maskedKey := apiKey[:8] + "..."
-	log.Printf("🔐 [AI TEXT REQUEST] API Key: %s | IP: %s", maskedKey, clientIP)
-
-	// Check API key validity and get user
-	user, err := validateAPIKey(app, apiKey)
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
 	if err != nil {
-		log.Printf("❌ [AI TEXT REQUEST] FAILED: Invalid API key %s | IP: %s | Error: %v", 
-			maskedKey, clientIP, err)
+		logger.Warn("Text request rejected", "reason", "invalid_api_key", "api_key", maskedKey, "error", err)
 		return e.JSON(401, map[string]string{"error": "Invalid API key"})
 	}
 
-	userEmail := user.GetString("email")
 	userID := user.Id
-	log.Printf("👤 [AI TEXT REQUEST] User: %s (%s) | API Key: %s | IP: %s", 
-		userEmail, userID, maskedKey, clientIP)
+	logger = logger.With("user_id", userID, "api_key", maskedKey)
+
+	// Sandbox tenants get canned completions - no subscription needed, no OpenRouter cost
+	if accounts.IsSandbox(user) {
+		return processSandboxText(e, user, clientIP)
+	}
 
 	// Check user's subscription status
-	if !isUserSubscribed(app, userID) {
-		log.Printf("❌ [AI TEXT REQUEST] FAILED: No active subscription | User: %s | IP: %s", 
-			userEmail, clientIP)
+	subscribed, paymentWarning := isUserSubscribed(app, userID)
+	if !subscribed {
+		logger.Warn("Text request rejected", "reason", "no_subscription")
 		return e.JSON(403, map[string]string{"error": "Active subscription required"})
 	}
+	if paymentWarning != nil {
+		logger.Warn("Payment past due, serving within grace period", "grace_ends_at", paymentWarning.GraceEndsAt.Format(time.RFC3339))
+		e.Response.Header().Set("X-Payment-Warning", paymentWarning.Code)
+	}
 
 	// Parse request body
 	var request TextProcessingRequest
 	if err := e.BindBody(&request); err != nil {
-		log.Printf("❌ [AI TEXT REQUEST] FAILED: Invalid request format | User: %s | IP: %s | Error: %v", 
-			userEmail, clientIP, err)
+		logger.Warn("Text request rejected", "reason", "invalid_body", "error", err)
 		return e.JSON(400, map[string]string{"error": "Invalid request format"})
 	}
 
 	// Validate required fields
 	if request.UserPrompt == "" {
-		log.Printf("❌ [AI TEXT REQUEST] FAILED: Missing user_prompt | User: %s | IP: %s", 
-			userEmail, clientIP)
+		logger.Warn("Text request rejected", "reason", "missing_user_prompt")
 		return e.JSON(400, map[string]string{"error": "user_prompt is required"})
 	}
 
-	// Set default model if not provided
-	if request.Model == "" {
-		request.Model = "anthropic/claude-3.5-sonnet"
+	// Fill in the default model and keep the request to models the plan includes
+	if handled, err := checkTextModel(e, app, userID, &request); handled {
+		logger.Warn("Text request rejected", "reason", "model_not_allowed", "model", request.Model)
+		return err
+	}
+
+	// Text requests draw from the plan's monthly token allowance
+	if err := validateTokenLimits(app, logger, userID); err != nil {
+		logger.Warn("Text request rejected", "reason", "token_limit_exceeded", "error", err)
+		return e.JSON(403, map[string]string{"error": err.Error(), "code": "TOKEN_LIMIT_EXCEEDED"})
+	}
+
+	logger = logger.With("task", request.TaskType, "model", request.Model)
+
+	// Prompts with disallowed content never reach the model
+	if handled, err := checkPrompt(e, app, logger, userID, &request); handled {
+		return err
 	}
 
-	// Log request details
-	log.Printf("📝 [AI TEXT REQUEST] Processing | User: %s | Task: %s | Model: %s | Prompt Length: %d chars | System Prompt Length: %d chars | IP: %s", 
-		userEmail, request.TaskType, request.Model, len(request.UserPrompt), len(request.SystemPrompt), clientIP)
+	logger.Info("Processing text request",
+		"prompt_chars", len(request.UserPrompt),
+		"system_prompt_chars", len(request.SystemPrompt),
+		"stream", request.Stream)
 
-	// Proxy request to OpenRouter
-	result, err := proxyToOpenRouter(&request)
+	// Wait for a text slot so bursts are queued instead of tripping OpenRouter rate limits
+	release, waited, err := sharedTextGate().Acquire(e.Request.Context(), userID, request.TaskType)
 	if err != nil {
-		elapsed := time.Since(startTime)
-		log.Printf("❌ [AI TEXT REQUEST] FAILED: OpenRouter error | User: %s | Task: %s | Model: %s | Duration: %v | IP: %s | Error: %v", 
-			userEmail, request.TaskType, request.Model, elapsed, clientIP, err)
+		logger.Warn("Text request rejected", "reason", "no_text_slot", "waited_ms", waited.Milliseconds(), "error", err)
+		switch {
+		case errors.Is(err, ErrTextQueueFull):
+			e.Response.Header().Set("Retry-After", "30")
+			return e.JSON(429, map[string]any{"error": "Too many text requests are queued, please retry shortly", "code": "TEXT_QUEUE_FULL"})
+		case errors.Is(err, ErrTextQueueTimeout):
+			e.Response.Header().Set("Retry-After", "30")
+			return e.JSON(503, map[string]any{"error": "Timed out waiting for a text processing slot", "code": "TEXT_QUEUE_TIMEOUT"})
+		default:
+			return err // client went away
+		}
+	}
+	defer release()
+	logTextQueueWait(logger, waited)
+
+	// Streaming requests relay the completion token by token as Server-Sent Events
+	if request.Stream {
+		return streamTextResponse(e, app, logger, &request, userID, clientIP, startTime)
+	}
+
+	// Proxy request to OpenRouter (or the fallback provider during an outage)
+	result, err := completeText(&request)
+	if err != nil {
+		logger.Error("Text request failed", "reason", "provider_error", "duration_ms", time.Since(startTime).Milliseconds(), "error", err)
+		var outage *circuit.OutageError
+		if errors.As(err, &outage) {
+			return providerOutageResponse(e, outage)
+		}
 		return e.JSON(500, map[string]string{"error": fmt.Sprintf("AI processing failed: %v", err)})
 	}
 
-	elapsed := time.Since(startTime)
 	responseLength := len(result.Choices[0].Message.Content)
+	usage := textTokenUsage(result.Usage, &request, responseLength)
+
+	// Replies the desktop app parses as JSON are validated, and corrected by the model when malformed
+	if schema, ok := structuredTasks[request.TaskType]; ok {
+		result, usage = completeStructured(&request, schema, result, usage, logger)
+		responseLength = len(result.Choices[0].Message.Content)
+	}
+	elapsed := time.Since(startTime)
+	result.PaymentWarning = paymentWarning
 	
 	// Log usage and success
-	logAIUsage(app, userID, userEmail, request.TaskType, request.Model, 0, len(request.UserPrompt), responseLength, elapsed, clientIP)
-	
-	log.Printf("✅ [AI TEXT REQUEST] SUCCESS | User: %s | Task: %s | Model: %s | Response Length: %d chars | Duration: %v | IP: %s", 
-		userEmail, request.TaskType, request.Model, responseLength, elapsed, clientIP)
+	recordTextUsage(app, logger, userID, &request, usage, responseLength, elapsed, clientIP)
 
 	return e.JSON(200, result)
 }
 
 // GenerateAPIKeyHandler generates a new API key for authenticated users
 func GenerateAPIKeyHandler(e *core.RequestEvent, app core.App) error {
-	clientIP := getClientIP(e)
-	userAgent := e.Request.Header.Get("User-Agent")
-	
-	log.Printf("🔑 [API KEY REQUEST] IP: %s | User-Agent: %s", clientIP, userAgent)
+	logger := logging.FromRequest(e).With("endpoint", "generate_api_key", "ip", getClientIP(e))
 
 	// Get authenticated user
 	user := e.Auth
 	if user == nil {
-		log.Printf("❌ [API KEY REQUEST] FAILED: No authentication | IP: %s", clientIP)
+		logger.Warn("API key request rejected", "reason", "unauthenticated")
 		return e.JSON(401, map[string]string{"error": "Authentication required"})
 	}
 
-	userEmail := user.GetString("email")
 	userID := user.Id
-	log.Printf("👤 [API KEY REQUEST] User: %s (%s) | IP: %s", userEmail, userID, clientIP)
 
-	// Generate API key
-	apiKey := generateAPIKey()
-	keyHash := hashAPIKey(apiKey)
+	// An optional organization_id makes the key charge that organization's pooled hours, and
+	// optional scopes limit what it can do (full access without them)
+	var req struct {
+		OrganizationID string   `json:"organization_id"`
+		Scopes         []string `json:"scopes"`
+	}
+	if e.Request.ContentLength != 0 {
+		if err := e.BindBody(&req); err != nil {
+			return e.JSON(400, map[string]string{"error": "Invalid request format"})
+		}
+	}
+	if req.OrganizationID != "" {
+		if _, err := organizations.ChargeablePool(app, req.OrganizationID, userID); err != nil {
+			logger.Warn("API key request rejected", "reason", "organization_not_chargeable", "organization_id", req.OrganizationID, "error", err)
+			return e.JSON(403, map[string]string{"error": "You can't use this organization's hours"})
+		}
+	}
 
-	// Create API key record
-	apiKeyCollection, err := app.FindCollectionByNameOrId("api_keys")
+	scopes, err := normalizeScopes(req.Scopes)
 	if err != nil {
-		log.Printf("❌ [API KEY REQUEST] FAILED: Cannot find api_keys collection | User: %s | IP: %s | Error: %v", 
-			userEmail, clientIP, err)
-		return e.JSON(500, map[string]string{"error": "Failed to find API keys collection"})
+		return e.JSON(400, map[string]string{"error": err.Error()})
 	}
 
-	record := core.NewRecord(apiKeyCollection)
-	record.Set("key_hash", keyHash)
-	record.Set("user_id", user.Id)
-	record.Set("active", true)
-	record.Set("name", fmt.Sprintf("API Key - %s", time.Now().Format("2006-01-02 15:04")))
-
-	if err := app.Save(record); err != nil {
-		log.Printf("❌ [API KEY REQUEST] FAILED: Cannot save API key | User: %s | IP: %s | Error: %v", 
-			userEmail, clientIP, err)
+	// Generate API key
+	apiKey, record, err := createAPIKey(app, user.Id, fmt.Sprintf("API Key - %s", time.Now().Format("2006-01-02 15:04")), req.OrganizationID, scopes)
+	if err != nil {
+		logger.Error("Failed to create API key", "error", err)
 		return e.JSON(500, map[string]string{"error": "Failed to save API key"})
 	}
 
-	maskedKey := apiKey[:8] + "..."
-	log.Printf("✅ [API KEY REQUEST] SUCCESS: Generated API key %s | User: %s | IP: %s", 
-		maskedKey, userEmail, clientIP)
+	logger.Info("Generated API key", "api_key", apiKey[:8]+"...", "key_id", record.Id, "organization_id", req.OrganizationID, "scopes", scopes)
 
-	return e.JSON(200, map[string]string{
-		"api_key": apiKey,
-		"message": "API key generated successfully",
+	return e.JSON(200, map[string]any{
+		"api_key":         apiKey,
+		"key_id":          record.Id,
+		"organization_id": req.OrganizationID,
+		"scopes":          keyScopes(record),
+		"message":         "API key generated successfully",
 	})
 }
 
@@ -268,7 +302,31 @@ func generateAPIKey() string {
 	return "ra-" + hex.EncodeToString(hash[:])[:32]
 }
 
-func validateAPIKey(app core.App, apiKey string) (*core.Record, error) {
+// UserForAPIKey resolves the user behind an active API key with scope, for endpoints outside
+// this package that accept API keys (TUS uploads, webhooks), along with the organization the key charges
+func UserForAPIKey(app core.App, apiKey string, r *http.Request, scope string) (*core.Record, string, error) {
+	key, user, err := lookupAPIKey(app, apiKey, r)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := checkScope(key, scope); err != nil {
+		return nil, "", err
+	}
+	return user, key.GetString("organization_id"), nil
+}
+
+func validateAPIKey(app core.App, apiKey string, r *http.Request) (*core.Record, error) {
+	_, userRecord, err := lookupAPIKey(app, apiKey, r)
+	return userRecord, err
+}
+
+// lookupAPIKey finds an active key and its user, checks the key may be used from where the
+// request came from, and notes that the key was used. Delegated tokens resolve to their grant
+func lookupAPIKey(app core.App, apiKey string, r *http.Request) (*core.Record, *core.Record, error) {
+	if strings.HasPrefix(apiKey, DelegatedTokenPrefix) {
+		return lookupDelegatedToken(app, apiKey)
+	}
+
 	keyHash := hashAPIKey(apiKey)
 	
 	// Find API key record
@@ -276,45 +334,85 @@ func validateAPIKey(app core.App, apiKey string) (*core.Record, error) {
 		"hash": keyHash,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("API key not found or inactive")
+		return nil, nil, fmt.Errorf("API key not found or inactive")
 	}
 
 	// Get user record
 	userRecord, err := app.FindRecordById("users", apiKeyRecord.GetString("user_id"))
 	if err != nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, nil, fmt.Errorf("user not found")
 	}
 
-	return userRecord, nil
+	// Soft-deleted accounts keep their keys, but they stop working until the account is restored
+	if accounts.IsDeactivated(userRecord) {
+		return nil, nil, fmt.Errorf("account is deactivated")
+	}
+	if err := keyguard.Check(app, apiKeyRecord, r); err != nil {
+		return nil, nil, err
+	}
+
+	markAPIKeyUsed(app, apiKeyRecord, time.Now())
+	return apiKeyRecord, userRecord, nil
 }
 
-// validateUsageLimits checks if user can process additional audio without exceeding monthly limits
-func validateUsageLimits(app core.App, userID string, hoursToAdd float64) error {
-	// Get grace period from environment variable (default to 60 seconds if not set)
-	gracePeriodSeconds := 60.0
-	if gracePeriodEnv := os.Getenv("USAGE_GRACE_PERIOD_SECONDS"); gracePeriodEnv != "" {
-		if parsed, err := strconv.ParseFloat(gracePeriodEnv, 64); err == nil {
-			gracePeriodSeconds = parsed
+// errMissingAPIKey means a request carried neither a Bearer API key nor a verified signature
+var errMissingAPIKey = errors.New("missing API key")
+
+// authenticateAPIKey resolves the user behind a request from a signature verified by
+// apisign.Middleware, or else from the Bearer API key, which needs scope (a *scopeError
+// otherwise). The label identifies the key in logs
+func authenticateAPIKey(e *core.RequestEvent, app core.App, scope string) (*core.Record, string, error) {
+	if user := apisign.SignedUser(e); user != nil {
+		label := "signed:" + e.Request.Header.Get(apisign.HeaderKeyID)
+		key := apisign.SignedKey(e)
+		if err := checkScope(key, scope); err != nil {
+			return nil, label, err
 		}
+		markAPIKeyUsed(app, key, time.Now())
+		return user, label, nil
 	}
-	gracePeriodHours := gracePeriodSeconds / 3600.0
 
-	// Get current month in YYYY-MM format
-	currentMonth := time.Now().Format("2006-01")
+	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
+	if apiKey == "" {
+		return nil, "", errMissingAPIKey
+	}
+
+	// Mask API key for logging (show first 8 chars)
+	maskedKey := apiKey
+	if len(apiKey) > 8 {
+		maskedKey = apiKey[:8] + "..."
+	}
+
+	key, user, err := lookupAPIKey(app, apiKey, e.Request)
+	if err != nil {
+		return nil, maskedKey, err
+	}
+	if err := checkScope(key, scope); err != nil {
+		return nil, maskedKey, err
+	}
+	e.Set(apiKeyContextKey, key)
+	return user, maskedKey, nil
+}
+
+// validateUsageLimits checks if the account can process additional audio without exceeding monthly limits
+func validateUsageLimits(app core.App, logger *slog.Logger, account usageAccount, hoursToAdd float64) error {
+	if account.OrganizationID != "" {
+		return validatePoolLimits(app, logger, account, hoursToAdd)
+	}
+	userID := account.UserID
+
+	// Usage is counted per billing period (calendar month for plans without one)
+	now := time.Now()
+	period := subscription.CurrentUsagePeriod(app, userID, now)
 	
-	// Find user's current monthly usage record
-	monthlyUsageRecord, err := app.FindFirstRecordByFilter("monthly_usage", 
-		"user_id = {:user_id} && year_month = {:month}", 
-		map[string]interface{}{
-			"user_id": userID,
-			"month":   currentMonth,
-		})
+	// Find user's current period usage record (organization pool rows are counted separately)
+	monthlyUsageRecord, err := subscription.FindUsageRecord(app, userID, "", period, now)
+	if err != nil {
+		logger.Warn("Failed to load usage record", "error", err)
+	}
 	
 	var currentHoursUsed float64
-	if err != nil {
-		// No usage record exists for this month - user starts at 0
-		currentHoursUsed = 0
-	} else {
+	if monthlyUsageRecord != nil {
 		currentHoursUsed = monthlyUsageRecord.GetFloat("hours_used")
 	}
 	
@@ -326,11 +424,33 @@ func validateUsageLimits(app core.App, userID string, hoursToAdd float64) error
 	subscriptionInfo, err := subscriptionService.GetUserSubscriptionInfo(userID)
 	if err != nil {
 		// Fallback to free tier limits (30 minutes = 0.5 hours) if subscription service fails
-		log.Printf("⚠️  [USAGE VALIDATION] Subscription service failed for user %s, using free tier limits: %v", userID, err)
+		logger.Warn("Subscription lookup failed, using free tier limits", "error", err)
 		monthlyLimitHours = 0.5 // 30 minutes for free users
 	} else {
 		monthlyLimitHours = subscriptionInfo.Plan.GetFloat("hours_per_month")
 	}
+
+	var plan *core.Record
+	if subscriptionInfo != nil {
+		plan = subscriptionInfo.Plan
+	}
+
+	// Sandbox plans were never paid for - they don't cover real providers
+	if subscriptionInfo != nil && subscription.IsSandboxPlan(subscriptionInfo.Subscription) {
+		logger.Warn("Audio request rejected", "reason", "sandbox_plan")
+		return fmt.Errorf("sandbox plans only cover sandbox API keys")
+	}
+
+	// A paused subscription has no hours until it resumes
+	if subscriptionInfo != nil && subscription.IsPaused(subscriptionInfo.Subscription, now) {
+		logger.Warn("Audio request rejected", "reason", "subscription_paused")
+		if resumesAt := subscriptionInfo.Subscription.GetDateTime("resumes_at"); !resumesAt.IsZero() {
+			return fmt.Errorf("your subscription is paused until %s - resume it to process audio", resumesAt.Time().UTC().Format("January 2, 2006"))
+		}
+		return fmt.Errorf("your subscription is paused - resume it to process audio")
+	}
+	gracePeriodSeconds := planGracePeriodSeconds(plan)
+	gracePeriodHours := gracePeriodSeconds / 3600.0
 	
 	// Calculate total usage after processing this audio
 	projectedUsage := currentHoursUsed + hoursToAdd
@@ -342,12 +462,21 @@ func validateUsageLimits(app core.App, userID string, hoursToAdd float64) error
 		
 		// Apply grace period logic: allow if excess is within grace period
 		if excessHours <= gracePeriodHours {
-			log.Printf("🎁 [GRACE PERIOD] User %s exceeding limit by %.2f hours, within grace period of %.2f hours - allowing", 
-				userID, excessHours, gracePeriodHours)
+			logger.Info("Usage over limit, allowed within grace period",
+				"hours_over", excessHours, "grace_hours", gracePeriodHours)
 			return nil
 		}
 		
-		// Excess is beyond grace period - reject
+		// Beyond the grace period, a burst can still cover a heavy month
+		burst := loadBurstStatus(app, userID, plan, monthlyLimitHours, time.Now())
+		if burst.allows(projectedUsage) {
+			logger.Info("Usage over limit, allowed as a burst",
+				"hours_over", excessHours, "burst_ceiling_hours", burst.CeilingHours,
+				"bursts_used", burst.UsedThisYear, "bursts_per_year", burst.PerYear)
+			return nil
+		}
+
+		// Excess is beyond grace period and burst - reject
 		var planName string
 		if subscriptionInfo != nil && subscriptionInfo.Plan != nil {
 			planName = subscriptionInfo.Plan.GetString("name")
@@ -358,44 +487,108 @@ func validateUsageLimits(app core.App, userID string, hoursToAdd float64) error
 			monthlyLimitHours, planName, currentHoursUsed, hoursToAdd, gracePeriodSeconds)
 	}
 	
-	log.Printf("✅ [USAGE VALIDATION] User %s: %.2f/%.1f hours used (adding %.2f hours)", 
-		userID, currentHoursUsed, monthlyLimitHours, hoursToAdd)
+	logger.Debug("Usage within limit",
+		"hours_used", currentHoursUsed, "hours_limit", monthlyLimitHours, "hours_requested", hoursToAdd)
 	
 	return nil
 }
 
-func updateUsageAfterProcessing(app core.App, userID string, durationSeconds float64) error {
+// usageGracePeriodSeconds reads USAGE_GRACE_PERIOD_SECONDS (default to 60 seconds if not set)
+func usageGracePeriodSeconds() float64 {
+	gracePeriodSeconds := 60.0
+	if gracePeriodEnv := os.Getenv("USAGE_GRACE_PERIOD_SECONDS"); gracePeriodEnv != "" {
+		if parsed, err := strconv.ParseFloat(gracePeriodEnv, 64); err == nil {
+			gracePeriodSeconds = parsed
+		}
+	}
+	return gracePeriodSeconds
+}
+
+// planGracePeriodSeconds returns the plan's grace_period_seconds, or the global default for
+// plans that don't set one (0)
+func planGracePeriodSeconds(plan *core.Record) float64 {
+	if plan != nil {
+		if seconds := plan.GetFloat("grace_period_seconds"); seconds > 0 {
+			return seconds
+		}
+	}
+	return usageGracePeriodSeconds()
+}
+
+// gracePeriodSummary reports the grace allowance that applies to the plan and where it comes from
+func gracePeriodSummary(plan *core.Record) map[string]any {
+	source := "default"
+	if plan != nil && plan.GetFloat("grace_period_seconds") > 0 {
+		source = "plan"
+	}
+	return map[string]any{
+		"seconds": planGracePeriodSeconds(plan),
+		"source":  source,
+	}
+}
+
+// validatePoolLimits checks an organization's pooled hours: every member's usage this month
+// counts against the attached plan. Pools get the grace period but not bursts
+func validatePoolLimits(app core.App, logger *slog.Logger, account usageAccount, hoursToAdd float64) error {
+	pool, err := organizations.FindPool(app, account.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("organization hours aren't available: %w", err)
+	}
+
+	currentHoursUsed := organizations.PooledHoursUsed(app, account.OrganizationID, time.Now().Format("2006-01"))
+	limitHours := pool.LimitHours()
+	gracePeriodSeconds := planGracePeriodSeconds(pool.Plan)
+
+	projectedUsage := currentHoursUsed + hoursToAdd
+	if projectedUsage-limitHours > gracePeriodSeconds/3600.0 {
+		return fmt.Errorf("organization monthly limit of %.1f hours exceeded for %s plan (currently used: %.2f hours, requested: %.2f hours, grace period: %.0f seconds)",
+			limitHours, pool.Plan.GetString("name"), currentHoursUsed, hoursToAdd, gracePeriodSeconds)
+	}
+
+	logger.Debug("Pooled usage within limit", "organization_id", account.OrganizationID,
+		"hours_used", currentHoursUsed, "hours_limit", limitHours, "hours_requested", hoursToAdd)
+	return nil
+}
+
+// updateUsageAfterProcessing adds processed audio to the account's monthly_usage record
+// Pooled usage is kept per member so organizations can see who used their hours
+func updateUsageAfterProcessing(app core.App, logger *slog.Logger, account usageAccount, durationSeconds float64) error {
+	userID := account.UserID
 	hoursUsed := durationSeconds / 3600.0
-	currentMonth := time.Now().Format("2006-01")
-	
-	// Try to find existing monthly usage record
-	monthlyUsageRecord, err := app.FindFirstRecordByFilter("monthly_usage",
-		"user_id = {:user_id} && year_month = {:month}",
-		map[string]interface{}{
-			"user_id": userID,
-			"month":   currentMonth,
-		})
+
+	// Personal usage follows the billing period, organization pools the calendar month
+	now := time.Now()
+	period := subscription.CalendarUsagePeriod(now)
+	if account.OrganizationID == "" {
+		period = subscription.CurrentUsagePeriod(app, userID, now)
+	}
+	currentMonth := period.Key
 	
+	// Try to find existing usage record for the period
+	monthlyUsageRecord, err := subscription.FindUsageRecord(app, userID, account.OrganizationID, period, now)
 	if err != nil {
+		return err
+	}
+	
+	if monthlyUsageRecord == nil {
 		// No record exists - create new one
-		collection, err := app.FindCollectionByNameOrId("monthly_usage")
+		record, err := subscription.NewUsageRecord(app, userID, account.OrganizationID, period)
 		if err != nil {
-			return fmt.Errorf("failed to find monthly_usage collection: %w", err)
+			return err
 		}
-		
-		record := core.NewRecord(collection)
-		record.Set("user_id", userID)
-		record.Set("year_month", currentMonth)
 		record.Set("hours_used", hoursUsed)
 		record.Set("files_processed", 1)
 		record.Set("last_processing_date", time.Now())
+		if account.OrganizationID == "" {
+			recordBurstUsage(app, logger, userID, record)
+		}
 		
 		if err := app.Save(record); err != nil {
 			return fmt.Errorf("failed to create monthly usage record: %w", err)
 		}
 		
-		log.Printf("📊 [USAGE UPDATE] Created new monthly usage record for user %s: %.3f hours", 
-			userID, hoursUsed)
+		logger.Info("Created monthly usage record", "organization_id", account.OrganizationID,
+			"month", currentMonth, "hours_used", hoursUsed)
 	} else {
 		// Update existing record
 		currentHours := monthlyUsageRecord.GetFloat("hours_used")
@@ -404,55 +597,55 @@ func updateUsageAfterProcessing(app core.App, userID string, durationSeconds flo
 		monthlyUsageRecord.Set("hours_used", currentHours + hoursUsed)
 		monthlyUsageRecord.Set("files_processed", currentFiles + 1)
 		monthlyUsageRecord.Set("last_processing_date", time.Now())
+		if account.OrganizationID == "" {
+			recordBurstUsage(app, logger, userID, monthlyUsageRecord)
+		}
 		
 		if err := app.Save(monthlyUsageRecord); err != nil {
 			return fmt.Errorf("failed to update monthly usage record: %w", err)
 		}
 		
-		log.Printf("📊 [USAGE UPDATE] Updated monthly usage for user %s: %.3f hours (was %.3f, added %.3f)", 
-			userID, currentHours + hoursUsed, currentHours, hoursUsed)
+		logger.Info("Updated monthly usage", "organization_id", account.OrganizationID,
+			"month", currentMonth, "hours_used", currentHours+hoursUsed, "hours_added", hoursUsed)
 	}
 	
 	return nil
 }
 
-func isUserSubscribed(app core.App, userID string) bool {
-	// Check if user has an active subscription using our new system
+// isUserSubscribed checks the user's subscription, keeping past_due subscriptions
+// subscribed until their grace window ends - the warning is set while inside that window
+func isUserSubscribed(app core.App, userID string) (bool, *subscription.PaymentWarning) {
 	repo := subscription.NewRepository(app)
-	subscriptionService := subscription.NewService(repo)
 	
-	userSubscription, err := subscriptionService.GetUserActiveSubscription(userID)
+	userSubscription, err := repo.FindSubscription(subscription.SubscriptionQuery{UserID: userID})
 	if err != nil {
-		log.Printf("No subscription found for user %s: %v", userID, err)
-		return false
+		slog.Debug("No subscription found", "user_id", userID, "error", err)
+		return false, nil
+	}
+	if subscription.IsSandboxPlan(userSubscription) {
+		slog.Warn("Sandbox plan used outside the sandbox", "user_id", userID)
+		return false, nil
 	}
 
-	status := userSubscription.GetString("status")
-	return status == "active" || status == "trialing"
+	access := subscription.AccessForSubscription(userSubscription, time.Now())
+	return access.Subscribed, access.Warning
 }
 
 func proxyToOpenRouter(request *TextProcessingRequest) (*OpenRouterResponse, error) {
-	// Build messages array
-	messages := []Message{}
-
-	// Add system message if provided
-	if request.SystemPrompt != "" {
-		messages = append(messages, Message{
-			Role:    "system",
-			Content: request.SystemPrompt,
-		})
+	openRouter := gateway.OpenRouterFromEnv()
+	if openRouter.APIKey == "" {
+		return nil, fmt.Errorf("OpenRouter API key not configured")
 	}
 
-	// Add user message
-	messages = append(messages, Message{
-		Role:    "user",
-		Content: request.UserPrompt,
-	})
+	return sendChatCompletion("OpenRouter", openRouter.URL("/chat/completions"), openRouter.Auth, request.Model, request)
+}
 
-	// Create OpenRouter request
+// sendChatCompletion sends a request to an OpenAI-compatible chat completions endpoint
+func sendChatCompletion(provider, endpoint string, auth gateway.Auth, model string, request *TextProcessingRequest) (*OpenRouterResponse, error) {
+	// Create chat completion request
 	openRouterReq := OpenRouterRequest{
-		Model:    request.Model,
-		Messages: messages,
+		Model:    model,
+		Messages: chatMessages(request),
 	}
 
 	// Marshal request
@@ -462,20 +655,13 @@ func proxyToOpenRouter(request *TextProcessingRequest) (*OpenRouterResponse, err
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", "https://openrouter.ai/api/v1/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// TODO: Get OpenRouter API key from environment or settings
-	// For now, this would need to be configured
-	openRouterAPIKey := getOpenRouterAPIKey()
-	if openRouterAPIKey == "" {
-		return nil, fmt.Errorf("OpenRouter API key not configured")
-	}
-
 	// Set headers
-	req.Header.Set("Authorization", "Bearer "+openRouterAPIKey)
+	auth.Apply(req.Header)
 	req.Header.Set("Content-Type", "application/json")
 
 	// Make request
@@ -493,7 +679,7 @@ func proxyToOpenRouter(request *TextProcessingRequest) (*OpenRouterResponse, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenRouter API error: %s", string(body))
+		return nil, &circuit.StatusError{Provider: provider, StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Parse response
@@ -505,44 +691,77 @@ func proxyToOpenRouter(request *TextProcessingRequest) (*OpenRouterResponse, err
 
 	// Check for API errors
 	if openRouterResp.Error != nil {
-		return nil, fmt.Errorf("OpenRouter API error: %s", openRouterResp.Error.Message)
+		return nil, fmt.Errorf("%s API error: %s", provider, openRouterResp.Error.Message)
 	}
 
 	if len(openRouterResp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenRouter API")
+		return nil, fmt.Errorf("no response from %s API", provider)
 	}
 
 	return &openRouterResp, nil
 }
 
+// chatMessages builds the system and user messages for a text request
+func chatMessages(request *TextProcessingRequest) []Message {
+	// Build messages array
+	messages := []Message{}
+
+	// Add system message if provided
+	if request.SystemPrompt != "" {
+		messages = append(messages, Message{
+			Role:    "system",
+			Content: request.SystemPrompt,
+		})
+	}
+
+	// Add user message
+	messages = append(messages, Message{
+		Role:    "user",
+		Content: request.UserPrompt,
+	})
+	messages = append(messages, request.followUp...)
+
+	return messages
+}
+
 func getOpenRouterAPIKey() string {
 	// Get OpenRouter API key from environment
 	return os.Getenv("OPENROUTER_API_KEY")
 }
 
-func logAIUsage(app core.App, userID, userEmail, taskType, model string, tokensUsed, inputSize, outputSize int, duration time.Duration, clientIP string) {
-	// Enhanced logging for AI usage analytics and billing
-	log.Printf("📊 [AI USAGE] User: %s (%s) | Task: %s | Model: %s | Input: %d | Output: %d | Duration: %v | IP: %s", 
-		userEmail, userID, taskType, model, inputSize, outputSize, duration, clientIP)
-	
-	// TODO: Optionally save to database for analytics/billing
-	// This could create records in an "ai_usage_logs" collection:
-	/*
+// logAIUsage logs a completed request and stores it in ai_usage_logs, with its tokens for text
+// requests, for usage analytics and billing
+func logAIUsage(app core.App, logger *slog.Logger, userID, taskType, model string, usage TokenUsage, inputSize, outputSize int, duration time.Duration, clientIP string) {
+	logger.Info("AI usage",
+		"task", taskType,
+		"model", model,
+		"prompt_tokens", usage.PromptTokens,
+		"completion_tokens", usage.CompletionTokens,
+		"tokens_estimated", usage.Estimated,
+		"input_size", inputSize,
+		"output_size", outputSize,
+		"duration_ms", duration.Milliseconds())
+
 	usageCollection, err := app.FindCollectionByNameOrId("ai_usage_logs")
-	if err == nil {
-		record := core.NewRecord(usageCollection)
-		record.Set("user_id", userID)
-		record.Set("task_type", taskType)
-		record.Set("model", model)
-		record.Set("tokens_used", tokensUsed)
-		record.Set("input_size", inputSize)
-		record.Set("output_size", outputSize)
-		record.Set("duration_ms", int(duration.Milliseconds()))
-		record.Set("client_ip", clientIP)
-		record.Set("timestamp", time.Now())
-		app.Save(record)
-	}
-	*/
+	if err != nil {
+		logger.Warn("Failed to find ai_usage_logs collection", "error", err)
+		return
+	}
+	record := core.NewRecord(usageCollection)
+	record.Set("user_id", userID)
+	record.Set("task_type", taskType)
+	record.Set("model", model)
+	record.Set("prompt_tokens", usage.PromptTokens)
+	record.Set("completion_tokens", usage.CompletionTokens)
+	record.Set("tokens_used", usage.TotalTokens)
+	record.Set("tokens_estimated", usage.Estimated)
+	record.Set("input_size", inputSize)
+	record.Set("output_size", outputSize)
+	record.Set("duration_ms", duration.Milliseconds())
+	record.Set("client_ip", clientIP)
+	if err := app.Save(record); err != nil {
+		logger.Warn("Failed to save AI usage log", "error", err)
+	}
 }
 
 func getClientIP(e *core.RequestEvent) string {
@@ -563,99 +782,91 @@ func getClientIP(e *core.RequestEvent) string {
 	return e.Request.RemoteAddr
 }
 
-// getMP3Duration extracts duration from MP3 files using pure Go library
-func getMP3Duration(audioFile multipart.File) (float64, error) {
-	// Reset file position to beginning
-	if _, err := audioFile.Seek(0, 0); err != nil {
-		return 0, fmt.Errorf("failed to seek to beginning of file: %w", err)
+// probeAudioDuration reads the upload's real duration, codec and sample rate from its headers.
+// Formats the transcription providers don't accept return audioprobe.ErrUnsupportedFormat;
+// damaged files, or containers that don't record a duration, fall back to a size estimate
+func probeAudioDuration(audioFile io.ReadSeeker, sizeBytes int64) (float64, *audioprobe.Info, error) {
+	info, err := audioprobe.Probe(audioFile)
+	if errors.Is(err, audioprobe.ErrUnsupportedFormat) {
+		return 0, nil, err
 	}
-
-	decoder, err := mp3.NewDecoder(audioFile)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create MP3 decoder: %w", err)
+	if err != nil || info.DurationSeconds <= 0 {
+		slog.Warn("No duration in the audio headers, estimating from file size", "size_bytes", sizeBytes, "error", err)
+		return audioprobe.EstimateDuration(sizeBytes), info, nil
 	}
-	
-	// Get sample rate
-	sampleRate := decoder.SampleRate()
-	
-	// Count total samples by reading through the entire file
-	var totalSamples int64
-	buf := make([]byte, 4096)
-	
-	for {
-		n, err := decoder.Read(buf)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return 0, fmt.Errorf("failed to read MP3 data: %w", err)
+	return info.DurationSeconds, info, nil
+}
+
+// unsupportedAudioResponse rejects an upload before it reaches the transcription provider
+func unsupportedAudioResponse(e *core.RequestEvent, err error) error {
+	return e.JSON(415, map[string]string{"error": err.Error(), "code": "UNSUPPORTED_AUDIO_FORMAT"})
+}
+
+// audioUploadMemoryLimit returns how much of a multipart audio upload is kept in memory
+// Set via AUDIO_UPLOAD_MEMORY_LIMIT_MB (default 8MB); the rest is spooled to disk
+func audioUploadMemoryLimit() int64 {
+	limitMB := int64(8)
+	if limitEnv := os.Getenv("AUDIO_UPLOAD_MEMORY_LIMIT_MB"); limitEnv != "" {
+		if parsed, err := strconv.ParseInt(limitEnv, 10, 64); err == nil && parsed > 0 {
+			limitMB = parsed
 		}
-		// Each sample is 4 bytes (2 channels * 2 bytes per channel)
-		totalSamples += int64(n / 4)
-	}
-	
-	if sampleRate == 0 {
-		return 0, fmt.Errorf("invalid sample rate in MP3 file")
 	}
-	
-	// Calculate duration in seconds
-	duration := float64(totalSamples) / float64(sampleRate)
-	
-	// Reset file position for subsequent use
-	audioFile.Seek(0, 0)
-	
-	return duration, nil
+	return limitMB << 20
 }
 
 // ProcessAudioHandler handles audio transcription requests using PocketBase native file uploads
 func ProcessAudioHandler(e *core.RequestEvent, app core.App) error {
 	startTime := time.Now()
 	clientIP := getClientIP(e)
-	userAgent := e.Request.Header.Get("User-Agent")
-	
-	log.Printf("🎵 [AI AUDIO REQUEST] IP: %s | User-Agent: %s | Method: %s", 
-		clientIP, userAgent, e.Request.Method)
+	logger := logging.FromRequest(e).With("endpoint", "process_audio", "ip", clientIP)
 
-	// Validate API key
-	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
-	if apiKey == "" {
-		log.Printf("❌ [AI AUDIO REQUEST] FAILED: Missing API key | IP: %s", clientIP)
+	logger.Debug("Audio request received", "user_agent", e.Request.Header.Get("User-Agent"))
+
+	// Validate API key (Bearer or signed request)
+	user, maskedKey, err := authenticateAPIKey(e, app, ScopeAIAudio)
+	if errors.Is(err, errMissingAPIKey) {
+		logger.Warn("Audio request rejected", "reason", "missing_api_key")
 		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
 	}
-
-	// Mask API key for logging (show first 8 chars)
-	maskedKey := apiKey[:8] + "..."
-	log.Printf("🔐 [AI AUDIO REQUEST] API Key: %s | IP: %s", maskedKey, clientIP)
-
-	// Check API key validity and get user
-	user, err := validateAPIKey(app, apiKey)
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
 	if err != nil {
-		log.Printf("❌ [AI AUDIO REQUEST] FAILED: Invalid API key %s | IP: %s | Error: %v", 
-			maskedKey, clientIP, err)
+		logger.Warn("Audio request rejected", "reason", "invalid_api_key", "api_key", maskedKey, "error", err)
 		return e.JSON(401, map[string]string{"error": "Invalid API key"})
 	}
 
-	userEmail := user.GetString("email")
 	userID := user.Id
-	log.Printf("👤 [AI AUDIO REQUEST] User: %s (%s) | API Key: %s | IP: %s", 
-		userEmail, userID, maskedKey, clientIP)
+	logger = logger.With("user_id", userID, "api_key", maskedKey)
+
+	// Sandbox tenants get fake transcripts - capped, and never billed or sent to Whisper
+	if accounts.IsSandbox(user) {
+		return processSandboxAudio(e, app, user, clientIP, startTime)
+	}
 
 	// Note: Removed hard subscription check - free users get 30min/month
-	// Usage limits will be validated in validateUsageLimits function
+	// Usage limits will be validated in validateUsageLimits function, against the organization
+	// pool when the API key belongs to one
+	account := requestUsageAccount(e, app, user)
 
 	// Parse multipart form data using PocketBase's capabilities (handles large files)
-	err = e.Request.ParseMultipartForm(500 << 20) // 500MB max memory for large audio files, rest goes to disk
+	// Only a small part is kept in memory; larger uploads spool to a temp file that stays seekable
+	// for the duration pre-check and is then streamed to Whisper without being buffered again
+	err = e.Request.ParseMultipartForm(audioUploadMemoryLimit())
+	if isUploadTooLarge(err) {
+		limit, _ := e.Get(uploadLimitContextKey).(int64)
+		logger.Warn("Audio request rejected", "reason", "upload_too_large", "limit_bytes", limit)
+		return uploadTooLargeResponse(e, limit)
+	}
 	if err != nil {
-		log.Printf("❌ [AI AUDIO REQUEST] FAILED: Invalid multipart form | User: %s | IP: %s | Error: %v", 
-			userEmail, clientIP, err)
+		logger.Warn("Audio request rejected", "reason", "invalid_multipart_form", "error", err)
 		return e.JSON(400, map[string]string{"error": "Invalid multipart form data"})
 	}
 
 	// Get the audio file from form data
 	file, header, err := e.Request.FormFile("audio")
 	if err != nil {
-		log.Printf("❌ [AI AUDIO REQUEST] FAILED: Missing audio file | User: %s | IP: %s | Error: %v", 
-			userEmail, clientIP, err)
+		logger.Warn("Audio request rejected", "reason", "missing_audio_file", "error", err)
 		return e.JSON(400, map[string]string{"error": "Audio file is required"})
 	}
 	defer file.Close()
@@ -680,55 +891,135 @@ func ProcessAudioHandler(e *core.RequestEvent, app core.App) error {
 	if durStr := e.Request.FormValue("original_duration_seconds"); durStr != "" {
 		fmt.Sscanf(durStr, "%f", &originalDuration)
 	}
+	chunkStart, err := parseChunkStart(e.Request.FormValue("chunk_start_seconds"))
+	if err != nil {
+		logger.Warn("Audio request rejected", "reason", "invalid_chunk_start", "error", err)
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
 	
 	// If not a chunk, use the current filename as base
 	if baseFilename == "" {
 		baseFilename = filename
 	}
+
+	// Optional transcription backend for this request, tried before the configured chain
+	provider := e.Request.FormValue("provider")
+	if err := whisper.ValidateProvider(provider); err != nil {
+		logger.Warn("Audio request rejected", "reason", "invalid_provider", "provider", provider, "error", err)
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
+
+	// Optional Whisper settings: language hint, prompt, temperature and translation to English
+	options, err := whisper.ParseOptions(e.Request.FormValue)
+	if err != nil {
+		logger.Warn("Audio request rejected", "reason", "invalid_transcription_options", "error", err)
+		return e.JSON(400, map[string]string{"error": err.Error()})
+	}
+	if options.Diarize {
+		if handled, err := checkDiarization(e, app, logger, userID, isChunk); handled {
+			return err
+		}
+	}
 	
 	if isChunk {
-		log.Printf("🎵 [AI AUDIO REQUEST] Processing Chunk | User: %s | Base: %s | Chunk: %d | Size: %d KB | Last: %v | IP: %s", 
-			userEmail, baseFilename, chunkIndex, fileSizeKB, isLastChunk, clientIP)
+		// Deprecated in favour of /api/ai/audio-sessions, which tracks chunks explicitly
+		e.Response.Header().Set("Deprecation", "true")
+		e.Response.Header().Set("Link", `</api/ai/audio-sessions>; rel="successor-version"`)
+		logger = logger.With("base_filename", baseFilename, "chunk_index", chunkIndex)
+		logger.Info("Processing audio chunk", "size_kb", fileSizeKB, "last_chunk", isLastChunk)
 	} else {
-		log.Printf("🎵 [AI AUDIO REQUEST] Processing | User: %s | Filename: %s | Audio Size: %d KB | IP: %s", 
-			userEmail, filename, fileSizeKB, clientIP)
+		logger = logger.With("filename", filename)
+		logger.Info("Processing audio", "size_kb", fileSizeKB)
 	}
 
-	// For non-chunks, validate usage limits using actual MP3 duration
+	// Probe every upload, chunks included, so unsupported formats never reach the provider
+	actualDurationSeconds, audioInfo, err := probeAudioDuration(file, fileSize)
+	if err != nil {
+		logger.Warn("Audio request rejected", "reason", "unsupported_format", "error", err)
+		return unsupportedAudioResponse(e, err)
+	}
+
+	// Identical re-uploads of a completed file get its transcript back - no provider call, no hours
+	// charged. Chunks are deduplicated as whole files once flattened, not one by one
+	var contentHash string
 	if !isChunk {
-		// Parse actual MP3 duration instead of estimating from file size
-		actualDurationSeconds, err := getMP3Duration(file)
+		contentHash, err = hashAudio(file)
 		if err != nil {
-			// Fallback to file size estimation if MP3 parsing fails
-			log.Printf("⚠️  [AI AUDIO REQUEST] MP3 duration parsing failed, using file size estimation: %v", err)
-			actualDurationSeconds = float64(fileSize) / 1048576.0 * 60.0
+			logger.Warn("Failed to hash audio, skipping deduplication", "error", err)
+		} else if !isForcedAudioRequest(e) {
+			if original, cached := findDuplicateTranscript(app, userID, contentHash, options, time.Now()); cached != nil {
+				logger.Info("Returning cached transcript for duplicate upload", "processed_file_id", original.Id)
+				e.Response.Header().Set("X-Deduplicated-From", original.Id)
+				return e.JSON(200, cached)
+			}
 		}
-		
-		log.Printf("📏 [AI AUDIO REQUEST] Pre-validation | User: %s | File size: %d KB | Actual duration: %.2fs (%.3f hours)", 
-			userEmail, fileSizeKB, actualDurationSeconds, actualDurationSeconds/3600.0)
+	}
+
+	// The same audio can only be transcribed so many times, set per plan - re-runs are granted
+	// by support or bought on paid plans
+	if contentHash != "" {
+		if handled, err := checkProcessingAttempts(e, app, logger, userID, contentHash); handled {
+			return err
+		}
+	}
+
+	// For non-chunks, validate usage limits using the probed duration
+	if !isChunk {
+		format := "unknown"
+		if audioInfo != nil {
+			format = audioInfo.Describe()
+		}
+		logger.Debug("Probed audio", "format", format, "duration_seconds", actualDurationSeconds)
 		
 		// Pre-validate using actual duration
-		if err := validateUsageLimits(app, userID, actualDurationSeconds/3600.0); err != nil {
-			log.Printf("❌ [AI AUDIO REQUEST] FAILED: Usage limit exceeded (pre-validation) | User: %s | Duration hours: %.3f | IP: %s | Error: %v", 
-				userEmail, actualDurationSeconds/3600.0, clientIP, err)
+		if err := validateUsageLimits(app, logger, account, actualDurationSeconds/3600.0); err != nil {
+			logger.Warn("Audio request rejected", "reason", "usage_limit_exceeded", "duration_seconds", actualDurationSeconds, "error", err)
 			return e.JSON(403, map[string]string{"error": err.Error(), "code": "USAGE_LIMIT_EXCEEDED"})
 		}
 		
-		// Reset file position for subsequent processing
-		file.Seek(0, 0)
 	}
 
 	// Create initial processed_files record with chunk metadata
 	processedFileRecord, err := createProcessedFileRecordWithChunkInfo(app, userID, filename, fileSize, clientIP, 
 		baseFilename, isChunk, isLastChunk, chunkIndex, originalFileSize, originalDuration)
 	if err != nil {
-		log.Printf("⚠️  [AI AUDIO REQUEST] Warning: Failed to create processed_files record | User: %s | Error: %v", 
-			userEmail, err)
+		logger.Warn("Failed to create processed_files record", "error", err)
 		// Continue processing even if logging fails
+	} else if contentHash != "" || !options.IsZero() {
+		processedFileRecord.Set("content_hash", contentHash)
+		if !options.IsZero() {
+			processedFileRecord.Set("transcription_options", options)
+		}
+		if err := app.Save(processedFileRecord); err != nil {
+			logger.Warn("Failed to store content hash and transcription options", "error", err)
+		}
+	}
+
+	upload := audioUpload{
+		Filename:         filename,
+		SizeBytes:        fileSize,
+		BaseFilename:     baseFilename,
+		IsChunk:          isChunk,
+		IsLastChunk:      isLastChunk,
+		ChunkIndex:       chunkIndex,
+		ChunkStart:       chunkStart,
+		OriginalFileSize: originalFileSize,
+		OriginalDuration: originalDuration,
+		Provider:         provider,
+		Options:          options,
+		OrganizationID:   account.OrganizationID,
+	}
+
+	// Async mode: keep the upload as a job and answer right away - a background worker transcribes it
+	if isAsyncAudioRequest(e) {
+		return enqueueTranscriptionJob(e, app, logger, user, header, upload, processedFileRecord, clientIP)
 	}
 
 	// Process audio using OpenAI Whisper API
-	result, err := streamToOpenAIWhisper(file, filename)
+	ctx := whisper.WithOptions(whisper.WithProvider(e.Request.Context(), provider), options)
+	prepared := prepareAudio(ctx, file, fileSize, filename)
+	defer prepared.Close()
+	result, err := prepared.transcribe(ctx, nil)
 	if err != nil {
 		elapsed := time.Since(startTime)
 		
@@ -737,148 +1028,132 @@ func ProcessAudioHandler(e *core.RequestEvent, app core.App) error {
 			updateProcessedFileRecord(app, processedFileRecord, "failed", 0, 0, 0, elapsed.Milliseconds())
 		}
 		
-		log.Printf("❌ [AI AUDIO REQUEST] FAILED: Transcription error | User: %s | Filename: %s | Duration: %v | IP: %s | Error: %v", 
-			userEmail, filename, elapsed, clientIP, err)
+		logger.Error("Transcription failed", "duration_ms", elapsed.Milliseconds(), "error", err)
+		var outage *circuit.OutageError
+		if errors.As(err, &outage) {
+			return providerOutageResponse(e, outage)
+		}
 		return e.JSON(500, map[string]string{"error": fmt.Sprintf("Transcription failed: %v", err)})
 	}
 
-	elapsed := time.Since(startTime)
+	// Speakers are a best-effort extra: the transcript is still returned if diarization fails
+	if options.Diarize {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			logger.Warn("Diarization skipped", "error", err)
+		} else if err := diarizeResult(e.Request.Context(), file, filename, result); err != nil {
+			logger.Warn("Diarization failed, returning transcript without speakers", "error", err)
+		}
+	}
+
+	if isChunk {
+		if start, ok := offsetChunkResult(app, userID, upload, result); ok {
+			e.Response.Header().Set("X-Chunk-Start-Seconds", strconv.FormatFloat(start, 'f', -1, 64))
+		} else {
+			logger.Warn("Earlier chunks haven't completed, chunk timestamps start at 0")
+		}
+	}
+
+	completeAudioProcessing(app, logger, processedFileRecord, userID, clientIP, upload, result, time.Since(startTime))
+
+	// The hours are charged either way - the provider has transcribed the audio
+	verdict, flag, err := moderateTranscript(e.Request.Context(), app, logger, userID, processedFileRecord, result)
+	if errors.Is(err, moderation.ErrFlagged) {
+		return contentFlaggedResponse(e, verdict, flag)
+	}
+	if verdict != nil && verdict.Flagged {
+		e.Response.Header().Set("X-Content-Flagged", strings.Join(verdict.Categories, ","))
+	}
+
+	return e.JSON(200, result)
+}
+
+// audioUpload describes an uploaded audio file and its chunk metadata
+type audioUpload struct {
+	Filename         string
+	SizeBytes        int64
+	BaseFilename     string
+	IsChunk          bool
+	IsLastChunk      bool
+	ChunkIndex       int
+	ChunkStart       float64 // declared start of the chunk in the original file, 0 to derive it
+	OriginalFileSize int64
+	OriginalDuration float64
+	Provider         string // per-request transcription provider override
+	Options          whisper.Options // per-request Whisper settings
+	OrganizationID   string // organization pool the hours are charged to, if any
+}
+
+// completeAudioProcessing records a successful transcription: the processed_files record,
+// chunk flattening, monthly usage and its cost. Shared by synchronous requests and transcription jobs
+func completeAudioProcessing(app core.App, logger *slog.Logger, processedFileRecord *core.Record, userID, clientIP string, upload audioUpload, result *whisper.Result, elapsed time.Duration) {
 	transcriptLength := len(result.Transcript)
 	wordCount := len(result.Words)
+	fileSizeKB := upload.SizeBytes / 1024
 	
+	model := result.Model
+	if model == "" {
+		model = result.Provider
+	}
+	if model == "" {
+		model = whisper.DefaultModel
+	}
+
 	// Update processed_files record with success
 	if processedFileRecord != nil {
+		processedFileRecord.Set("model_used", model)
+		if !upload.IsChunk {
+			// Kept so identical re-uploads can be answered without transcribing again
+			processedFileRecord.Set("transcription_result", result)
+		}
 		updateProcessedFileRecord(app, processedFileRecord, "completed", result.Duration, transcriptLength, wordCount, elapsed.Milliseconds())
 		
 		// If this is the last chunk, flatten all chunks into a single record
-		if isLastChunk {
-			if err := flattenChunkedRecords(app, userID, baseFilename, originalFileSize, originalDuration); err != nil {
-				log.Printf("⚠️  [AI AUDIO REQUEST] Warning: Failed to flatten chunk records | User: %s | Base: %s | Error: %v", 
-					userEmail, baseFilename, err)
+		if upload.IsLastChunk {
+			if err := flattenChunkedRecords(app, logger, userID, upload.BaseFilename, upload.OriginalFileSize, upload.OriginalDuration); err != nil {
+				logger.Warn("Failed to flatten chunk records", "base_filename", upload.BaseFilename, "error", err)
 				// Don't fail the request, just log the warning
-			} else {
-				log.Printf("✅ [AI AUDIO REQUEST] Flattened chunks | User: %s | Base: %s", userEmail, baseFilename)
 			}
 		}
 	}
 
 	// Update usage tracking for non-chunks (for chunks, usage is tracked when flattened)
-	if !isChunk {
-		if err := updateUsageAfterProcessing(app, userID, result.Duration); err != nil {
-			log.Printf("⚠️  [AI AUDIO REQUEST] Warning: Failed to update usage tracking | User: %s | Duration: %.2fs | Error: %v", 
-				userEmail, result.Duration, err)
+	if !upload.IsChunk {
+		if err := updateUsageAfterProcessing(app, logger, usageAccount{UserID: userID, OrganizationID: upload.OrganizationID}, result.Duration); err != nil {
+			logger.Warn("Failed to update usage tracking", "duration_seconds", result.Duration, "error", err)
 			// Don't fail the request if usage tracking fails
-		} else {
-			log.Printf("📊 [AI AUDIO REQUEST] Usage updated | User: %s | Duration: %.2fs (%.3f hours)", 
-				userEmail, result.Duration, result.Duration/3600.0)
 		}
 	}
 	
 	// Log usage and success
-	logAIUsage(app, userID, userEmail, "transcription", "whisper-1", 0, int(fileSizeKB), transcriptLength, elapsed, clientIP)
+	logAIUsage(app, logger, userID, "transcription", model, TokenUsage{}, int(fileSizeKB), transcriptLength, elapsed, clientIP)
+	provider := result.Provider
+	if provider == "" {
+		provider = whisper.ProviderOpenAI
+	}
+	recordCost(app, logger, costs.Entry{
+		UserID:         userID,
+		OrganizationID: upload.OrganizationID,
+		Kind:           costs.KindTranscription,
+		TaskType:       "transcription",
+		Provider:       provider,
+		Model:          model,
+		AudioSeconds:   result.Duration,
+	})
 	
-	if isChunk {
-		log.Printf("✅ [AI AUDIO REQUEST] CHUNK SUCCESS | User: %s | Base: %s | Chunk: %d | Transcript: %d chars | Duration: %v | IP: %s", 
-			userEmail, baseFilename, chunkIndex, transcriptLength, elapsed, clientIP)
-	} else {
-		log.Printf("✅ [AI AUDIO REQUEST] SUCCESS | User: %s | Filename: %s | Audio: %d KB | Transcript: %d chars | Words: %d | Duration: %v | IP: %s", 
-			userEmail, filename, fileSizeKB, transcriptLength, wordCount, elapsed, clientIP)
-	}
-
-	return e.JSON(200, result)
+	logger.Info("Transcription completed",
+		"chunk", upload.IsChunk,
+		"size_kb", fileSizeKB,
+		"transcript_chars", transcriptLength,
+		"words", wordCount,
+		"audio_seconds", result.Duration,
+		"duration_ms", elapsed.Milliseconds())
 }
 
-// streamToOpenAIWhisper streams audio directly to OpenAI's Whisper API without temp files
-func streamToOpenAIWhisper(audioFile multipart.File, filename string) (*AudioProcessingResult, error) {
-	// Get OpenAI API key from environment
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OpenAI API key not configured")
-	}
-
-	// Create a pipe for streaming multipart data to OpenAI
-	pipeReader, pipeWriter := io.Pipe()
-	multipartWriter := multipart.NewWriter(pipeWriter)
-
-	// Start goroutine to write multipart data
-	go func() {
-		defer pipeWriter.Close()
-		defer multipartWriter.Close()
-
-		// Add file field - stream directly from input
-		fileWriter, err := multipartWriter.CreateFormFile("file", filepath.Base(filename))
-		if err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to create form file: %w", err))
-			return
-		}
-
-		// Stream file contents directly from input to OpenAI
-		_, err = io.Copy(fileWriter, audioFile)
-		if err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to copy file: %w", err))
-			return
-		}
-
-		// Add model field
-		if err := multipartWriter.WriteField("model", "whisper-1"); err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to write model field: %w", err))
-			return
-		}
-
-		// Add response format for verbose JSON with timestamps
-		if err := multipartWriter.WriteField("response_format", "verbose_json"); err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to write response_format field: %w", err))
-			return
-		}
-
-		// Add timestamp granularities for word-level timestamps
-		if err := multipartWriter.WriteField("timestamp_granularities[]", "word"); err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to write timestamp_granularities field: %w", err))
-			return
-		}
-	}()
-
-	// Create request with streaming body
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/audio/transcriptions", pipeReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
-
-	// Make request
-	client := &http.Client{Timeout: 120 * time.Second} // Longer timeout for large files
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	// Parse response
-	var transcriptionResp OpenAITranscriptionResponse
-	if err := json.Unmarshal(body, &transcriptionResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return &AudioProcessingResult{
-		Transcript: transcriptionResp.Text,
-		Duration:   transcriptionResp.Duration,
-		Language:   transcriptionResp.Language,
-		Words:      transcriptionResp.Words,
-		Segments:   transcriptionResp.Segments,
-	}, nil
+// streamToTranscriptionProvider streams audio to the transcription provider without temp files
+// The provider comes from TRANSCRIPTION_PROVIDER or a per-request whisper.WithProvider override;
+// while it's down the next provider in the chain is used, or it fails fast with *circuit.OutageError
+func streamToTranscriptionProvider(ctx context.Context, audioFile io.Reader, filename string) (*whisper.Result, error) {
+	return whisper.Transcribe(ctx, audioFile, filename)
 }
 
 // createProcessedFileRecordWithChunkInfo creates a new record in processed_files collection with chunk metadata
@@ -890,24 +1165,6 @@ func createProcessedFileRecordWithChunkInfo(app core.App, userID, filename strin
 		return nil, fmt.Errorf("failed to find processed_files collection: %w", err)
 	}
 
-	// For non-chunks, check existing processing count
-	if !isChunk {
-		existingRecords, err := app.FindRecordsByFilter("processed_files", 
-			fmt.Sprintf("user_id = '%s' && filename = '%s' && is_chunk = false", userID, filename), 
-			"", 0, 0)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query existing processed files: %w", err)
-		}
-
-		processingCount := len(existingRecords) + 1
-		if processingCount > 2 {
-			return nil, fmt.Errorf("maximum processing limit reached for file '%s' (limit: 2 attempts)", filename)
-		}
-
-		log.Printf("📊 [PROCESSING COUNT] User: %s | Filename: %s | Attempt: %d/2 | IP: %s", 
-			userID, filename, processingCount, clientIP)
-	}
-
 	record := core.NewRecord(collection)
 	record.Set("user_id", userID)
 	record.Set("filename", filename)
@@ -952,7 +1209,7 @@ func updateProcessedFileRecord(app core.App, record *core.Record, status string,
 }
 
 // flattenChunkedRecords consolidates all chunk records into a single record after last chunk is processed
-func flattenChunkedRecords(app core.App, userID, baseFilename string, originalFileSize int64, originalDuration float64) error {
+func flattenChunkedRecords(app core.App, logger *slog.Logger, userID, baseFilename string, originalFileSize int64, originalDuration float64) error {
 	// Find all chunk records for this base filename
 	filter := fmt.Sprintf("user_id = '%s' && base_filename = '%s' && is_chunk = true && status = 'completed'", userID, baseFilename)
 	chunkRecords, err := app.FindRecordsByFilter("processed_files", filter, "chunk_index ASC", 0, 0)
@@ -964,8 +1221,6 @@ func flattenChunkedRecords(app core.App, userID, baseFilename string, originalFi
 		return fmt.Errorf("no completed chunks found for base file: %s", baseFilename)
 	}
 
-	log.Printf("📊 [FLATTEN CHUNKS] Found %d chunks for file: %s | User: %s", len(chunkRecords), baseFilename, userID)
-
 	// Aggregate data from all chunks
 	var totalTranscriptLength int64
 	var totalWordsCount int64
@@ -1007,52 +1262,49 @@ func flattenChunkedRecords(app core.App, userID, baseFilename string, originalFi
 		return fmt.Errorf("failed to save consolidated record: %w", err)
 	}
 
-	log.Printf("✅ [FLATTEN CHUNKS] Created consolidated record | File: %s | Chunks: %d | Total Duration: %.1fs | Total Words: %d", 
-		baseFilename, len(chunkRecords), originalDuration, totalWordsCount)
+	logger.Info("Flattened chunk records",
+		"base_filename", baseFilename,
+		"chunks", len(chunkRecords),
+		"duration_seconds", originalDuration,
+		"words", totalWordsCount)
 
 	// Delete the individual chunk records
 	for _, chunk := range chunkRecords {
 		if err := app.Delete(chunk); err != nil {
-			log.Printf("⚠️  [FLATTEN CHUNKS] Failed to delete chunk record %s: %v", chunk.Id, err)
+			logger.Warn("Failed to delete chunk record", "record_id", chunk.Id, "error", err)
 			// Continue deleting other chunks even if one fails
 		}
 	}
 
-	log.Printf("🗑️  [FLATTEN CHUNKS] Deleted %d chunk records for file: %s", len(chunkRecords), baseFilename)
-
 	return nil
 }
 
 // UsageSummaryHandler provides aggregated usage statistics for authenticated users via API key
 func UsageSummaryHandler(e *core.RequestEvent, app core.App) error {
-	clientIP := getClientIP(e)
-	userAgent := e.Request.Header.Get("User-Agent")
-	
-	log.Printf("📊 [USAGE SUMMARY REQUEST] IP: %s | User-Agent: %s", clientIP, userAgent)
+	logger := logging.FromRequest(e).With("endpoint", "usage_summary", "ip", getClientIP(e))
 
-	// Validate API key
-	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
-	if apiKey == "" {
-		log.Printf("❌ [USAGE SUMMARY REQUEST] FAILED: Missing API key | IP: %s", clientIP)
+	// Validate API key (Bearer or signed request)
+	user, maskedKey, err := authenticateAPIKey(e, app, ScopeUsageRead)
+	if errors.Is(err, errMissingAPIKey) {
+		logger.Warn("Usage summary request rejected", "reason", "missing_api_key")
 		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
 	}
-
-	user, err := validateAPIKey(app, apiKey)
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
 	if err != nil {
-		maskedKey := apiKey[:8] + "..."
-		log.Printf("❌ [USAGE SUMMARY REQUEST] FAILED: Invalid API key %s | IP: %s", maskedKey, clientIP)
+		logger.Warn("Usage summary request rejected", "reason", "invalid_api_key", "api_key", maskedKey, "error", err)
 		return e.JSON(401, map[string]string{"error": "Invalid API key"})
 	}
 
-	userEmail := user.GetString("email")
 	userID := user.Id
+	logger = logger.With("user_id", userID)
 
 	// Get month parameter (optional, defaults to current month)
 	month := e.Request.URL.Query().Get("month") // Format: YYYY-MM
 
-	// Query processed files for user (exclude chunk records)
-	filter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '')", userID)
-	log.Printf("🔍 [USAGE SUMMARY] Querying summary for user: %s with filter: %s", userID, filter)
+	// Query processed files for user (exclude chunk records and deleted files)
+	filter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && %s", userID, notDeletedFilter)
 	if month != "" {
 		// Add month filter if specified
 		filter += fmt.Sprintf(" && created >= '%s-01 00:00:00' && created < '%s-01 00:00:00'", month, getNextMonth(month))
@@ -1060,42 +1312,55 @@ func UsageSummaryHandler(e *core.RequestEvent, app core.App) error {
 
 	records, err := app.FindRecordsByFilter("processed_files", filter, "", 0, 0)
 	if err != nil {
-		log.Printf("❌ [USAGE SUMMARY REQUEST] FAILED: Database query error | User: %s | Error: %v", userEmail, err)
+		logger.Error("Failed to query usage summary", "error", err)
 		return e.JSON(500, map[string]string{"error": "Failed to retrieve usage data"})
 	}
 	
-	log.Printf("📊 [USAGE SUMMARY] Found %d records for summary | User: %s", len(records), userEmail)
-
-	// Aggregate statistics
-	summary := calculateUsageSummary(records)
+	// Aggregate statistics, including months archived by the retention job
+	summary := calculateUsageSummary(records, findArchivedUsage(app, userID, month))
 	summary["user_id"] = userID
 	summary["period"] = month
 	if month == "" {
 		summary["period"] = "all_time"
 	}
 
-	log.Printf("✅ [USAGE SUMMARY REQUEST] SUCCESS | User: %s | Records: %d | Period: %s | IP: %s", 
-		userEmail, len(records), summary["period"], clientIP)
+	// Text requests are counted in tokens per month - all-time summaries show the current period
+	summary["text_tokens"] = textTokenSummary(app, userID, month)
+
+	// The period monthly quotas are counted in - it follows the billing period for paid plans
+	summary["usage_period"] = subscription.CurrentUsagePeriod(app, userID, time.Now())
+
+	// Deleted files no longer count, but can be restored until they're purged
+	summary["deleted_files"] = deletedFilesSummary(app, userID)
+
+	// Seconds the monthly limit may be exceeded by before audio is rejected
+	plan, _ := userPlanLimit(app, userID)
+	summary["grace_period"] = gracePeriodSummary(plan)
+
+	// Largest audio upload process-audio accepts, so clients can split files before uploading
+	summary["max_upload_bytes"] = UploadLimitsFromEnv().ForPlan(plan)
+
+	logger.Debug("Served usage summary", "records", len(records), "period", summary["period"])
 
 	return e.JSON(200, summary)
 }
 
 // UsageFilesHandler provides detailed list of processed files for authenticated users via API key
 func UsageFilesHandler(e *core.RequestEvent, app core.App) error {
-	_ = getClientIP(e) // Get client IP for potential logging
-	
-	// Validate API key
-	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
-	if apiKey == "" {
+	// Validate API key (Bearer or signed request)
+	user, _, err := authenticateAPIKey(e, app, ScopeUsageRead)
+	if errors.Is(err, errMissingAPIKey) {
 		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
 	}
-
-	user, err := validateAPIKey(app, apiKey)
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
 	if err != nil {
 		return e.JSON(401, map[string]string{"error": "Invalid API key"})
 	}
 
 	userID := user.Id
+	logger := logging.FromRequest(e).With("endpoint", "usage_files", "user_id", userID)
 
 	// Parse pagination parameters
 	page := 1
@@ -1111,20 +1376,20 @@ func UsageFilesHandler(e *core.RequestEvent, app core.App) error {
 	}
 
 	// Query processed files (exclude chunk records) - get records where is_chunk is false or empty
-	filter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '')", userID)
-	
-	// Add debug logging for troubleshooting
-	log.Printf("🔍 [USAGE FILES] Querying files for user: %s with filter: %s", userID, filter)
+	// Deleted files are only listed with ?deleted=true, so clients can offer to restore them
+	deleted := e.Request.URL.Query().Get("deleted") == "true"
+	filter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && %s", userID, notDeletedFilter)
+	if deleted {
+		filter = fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && deleted_at != ''", userID)
+	}
 	sort := "" // No sorting for now to avoid created field issues
 	
 	records, err := app.FindRecordsByFilter("processed_files", filter, sort, perPage, (page-1)*perPage)
 	if err != nil {
-		log.Printf("❌ [USAGE FILES] Database query failed: %v", err)
+		logger.Error("Failed to query usage files", "error", err)
 		return e.JSON(500, map[string]string{"error": "Failed to retrieve files data"})
 	}
 	
-	log.Printf("📊 [USAGE FILES] Found %d records for user %s", len(records), userID)
-
 	// Convert to response format
 	files := make([]map[string]interface{}, len(records))
 	for i, record := range records {
@@ -1142,6 +1407,15 @@ func UsageFilesHandler(e *core.RequestEvent, app core.App) error {
 			"created":           record.GetDateTime("created"),
 			"updated":           record.GetDateTime("updated"),
 		}
+		if record.GetString("status") == "failed" && record.GetString("failure_reason") != "" {
+			files[i]["failure_reason"] = record.GetString("failure_reason")
+			files[i]["retriable"] = record.GetBool("retriable")
+		}
+		if deleted {
+			deletedAt := record.GetDateTime("deleted_at").Time()
+			files[i]["deleted_at"] = deletedAt
+			files[i]["restore_until"] = restoreDeadline(deletedAt)
+		}
 	}
 
 	// Get total count for pagination
@@ -1160,7 +1434,7 @@ func UsageFilesHandler(e *core.RequestEvent, app core.App) error {
 		"total_pages":  (totalRecords + int64(perPage) - 1) / int64(perPage),
 	}
 	
-	log.Printf("✅ [USAGE FILES] Returning %d files to user %s", len(files), userID)
+	logger.Debug("Served usage files", "files", len(files), "page", page)
 
 	return e.JSON(200, response)
 }
@@ -1169,13 +1443,14 @@ func UsageFilesHandler(e *core.RequestEvent, app core.App) error {
 func UsageStatsHandler(e *core.RequestEvent, app core.App) error {
 	_ = getClientIP(e) // Get client IP for potential logging
 	
-	// Validate API key
-	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
-	if apiKey == "" {
+	// Validate API key (Bearer or signed request)
+	user, _, err := authenticateAPIKey(e, app, ScopeUsageRead)
+	if errors.Is(err, errMissingAPIKey) {
 		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
 	}
-
-	user, err := validateAPIKey(app, apiKey)
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
 	if err != nil {
 		return e.JSON(401, map[string]string{"error": "Invalid API key"})
 	}
@@ -1188,18 +1463,18 @@ func UsageStatsHandler(e *core.RequestEvent, app core.App) error {
 	lastMonth := now.AddDate(0, -1, 0).Format("2006-01")
 
 	// Query current month (exclude chunk records)
-	currentFilter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && created >= '%s-01 00:00:00' && created < '%s-01 00:00:00'", 
-		userID, currentMonth, getNextMonth(currentMonth))
+	currentFilter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && %s && created >= '%s-01 00:00:00' && created < '%s-01 00:00:00'", 
+		userID, notDeletedFilter, currentMonth, getNextMonth(currentMonth))
 	currentRecords, _ := app.FindRecordsByFilter("processed_files", currentFilter, "", 0, 0)
 	
 	// Query last month (exclude chunk records)
-	lastFilter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && created >= '%s-01 00:00:00' && created < '%s-01 00:00:00'", 
-		userID, lastMonth, currentMonth)
+	lastFilter := fmt.Sprintf("user_id = '%s' && (is_chunk = false || is_chunk = '') && %s && created >= '%s-01 00:00:00' && created < '%s-01 00:00:00'", 
+		userID, notDeletedFilter, lastMonth, currentMonth)
 	lastRecords, _ := app.FindRecordsByFilter("processed_files", lastFilter, "", 0, 0)
 
 	// Calculate stats
-	currentStats := calculateUsageSummary(currentRecords)
-	lastStats := calculateUsageSummary(lastRecords)
+	currentStats := calculateUsageSummary(currentRecords, findArchivedUsage(app, userID, currentMonth))
+	lastStats := calculateUsageSummary(lastRecords, findArchivedUsage(app, userID, lastMonth))
 
 	response := map[string]interface{}{
 		"current_month": map[string]interface{}{
@@ -1214,6 +1489,19 @@ func UsageStatsHandler(e *core.RequestEvent, app core.App) error {
 			"files_change":    currentStats["total_files"].(int) - lastStats["total_files"].(int),
 			"duration_change": currentStats["total_duration"].(float64) - lastStats["total_duration"].(float64),
 		},
+		"burst": currentBurstStatus(app, userID, now),
+	}
+
+	// Keys that charge an organization also see how much of the shared pool is left
+	if account := requestUsageAccount(e, app, user); account.OrganizationID != "" {
+		if pool, err := organizations.FindPool(app, account.OrganizationID); err == nil {
+			response["organization"] = map[string]interface{}{
+				"id":          account.OrganizationID,
+				"plan":        pool.Plan.GetString("name"),
+				"hours_used":  organizations.PooledHoursUsed(app, account.OrganizationID, currentMonth),
+				"hours_limit": pool.LimitHours(),
+			}
+		}
 	}
 
 	return e.JSON(200, response)
@@ -1221,7 +1509,24 @@ func UsageStatsHandler(e *core.RequestEvent, app core.App) error {
 
 // Helper functions for usage calculations
 
-func calculateUsageSummary(records []*core.Record) map[string]interface{} {
+// findArchivedUsage returns the user's processed_file_summaries for a month, or for all months if month is empty
+func findArchivedUsage(app core.App, userID, month string) []*core.Record {
+	filter := "user_id = {:user}"
+	if month != "" {
+		filter += " && year_month = {:month}"
+	}
+	archives, err := app.FindRecordsByFilter("processed_file_summaries", filter, "", 0, 0, map[string]any{
+		"user":  userID,
+		"month": month,
+	})
+	if err != nil {
+		return nil
+	}
+	return archives
+}
+
+// calculateUsageSummary aggregates live processed_files records with archived monthly summaries
+func calculateUsageSummary(records []*core.Record, archives []*core.Record) map[string]interface{} {
 	totalFiles := len(records)
 	totalDuration := 0.0
 	totalFileSize := int64(0)
@@ -1243,6 +1548,16 @@ func calculateUsageSummary(records []*core.Record) map[string]interface{} {
 		}
 	}
 
+	for _, archive := range archives {
+		totalFiles += archive.GetInt("total_files")
+		totalDuration += archive.GetFloat("total_duration_seconds")
+		totalFileSize += int64(archive.GetInt("total_file_size_bytes"))
+		totalProcessingTime += int64(archive.GetInt("total_processing_time_ms"))
+		statusCounts["completed"] += archive.GetInt("completed_count")
+		statusCounts["processing"] += archive.GetInt("processing_count")
+		statusCounts["failed"] += archive.GetInt("failed_count")
+	}
+
 	// Convert duration to minutes and hours
 	totalMinutes := totalDuration / 60
 	totalHours := totalMinutes / 60
@@ -0,0 +1,95 @@
+package ai
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/gateway"
+	"pocketbase/internal/metrics"
+)
+
+// TextBreakerName identifies the OpenRouter circuit breaker
+const TextBreakerName = "openrouter"
+
+// textFallbackProvider labels the fallback provider in upstream metrics
+const textFallbackProvider = "text_fallback"
+
+// gatewayBreakerName identifies the circuit breaker of a gateway configured in MODEL_GATEWAYS
+func gatewayBreakerName(g gateway.Gateway) string {
+	return "gateway_" + g.Name
+}
+
+// textFallbackAuth is the fallback provider's key, sent in TEXT_FALLBACK_AUTH_HEADER when set
+func textFallbackAuth() gateway.Auth {
+	return gateway.Auth{APIKey: os.Getenv("TEXT_FALLBACK_API_KEY"), Header: os.Getenv("TEXT_FALLBACK_AUTH_HEADER")}
+}
+
+// TextFallbackConfigured reports whether text requests can be served by a fallback provider
+// configured with TEXT_FALLBACK_URL, TEXT_FALLBACK_API_KEY and TEXT_FALLBACK_MODEL
+func TextFallbackConfigured() bool {
+	return os.Getenv("TEXT_FALLBACK_URL") != "" && os.Getenv("TEXT_FALLBACK_API_KEY") != "" && os.Getenv("TEXT_FALLBACK_MODEL") != ""
+}
+
+// completeText proxies a text request to OpenRouter through its circuit breaker
+// While the breaker is open, the fallback provider is used if configured, otherwise
+// the request fails fast with *circuit.OutageError. Models served by a gateway in
+// MODEL_GATEWAYS go to that gateway instead, through its own breaker and without fallback
+func completeText(request *TextProcessingRequest) (*OpenRouterResponse, error) {
+	if g, model, ok := gateway.Route(request.Model); ok {
+		breaker := circuit.For(gatewayBreakerName(g))
+		if !breaker.Allow() {
+			return nil, &circuit.OutageError{Provider: g.Name, RetryAfter: breaker.RetryAfter()}
+		}
+		start := time.Now()
+		result, err := sendChatCompletion(g.Name, g.URL("/chat/completions"), g.Auth, model, request)
+		breaker.Record(err)
+		metrics.ObserveUpstream(gatewayBreakerName(g), start, err)
+		return result, err
+	}
+
+	if getOpenRouterAPIKey() == "" {
+		return nil, fmt.Errorf("OpenRouter API key not configured")
+	}
+
+	breaker := circuit.For(TextBreakerName)
+	if breaker.Allow() {
+		start := time.Now()
+		result, err := proxyToOpenRouter(request)
+		breaker.Record(err)
+		metrics.ObserveUpstream(TextBreakerName, start, err)
+		return result, err
+	}
+
+	if TextFallbackConfigured() {
+		slog.Warn("OpenRouter circuit open, using fallback provider", "task", request.TaskType)
+		start := time.Now()
+		result, err := sendChatCompletion("Fallback", os.Getenv("TEXT_FALLBACK_URL"), textFallbackAuth(), os.Getenv("TEXT_FALLBACK_MODEL"), request)
+		metrics.ObserveUpstream(textFallbackProvider, start, err)
+		return result, err
+	}
+
+	return nil, &circuit.OutageError{Provider: TextBreakerName, RetryAfter: breaker.RetryAfter()}
+}
+
+// providerOutageResponse tells the client a provider is down and when to retry
+func providerOutageResponse(e *core.RequestEvent, outage *circuit.OutageError) error {
+	retryAfter := int(math.Ceil(outage.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+
+	e.Response.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfter))
+	return e.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+		"error":               "The AI provider is temporarily unavailable. Please try again shortly.",
+		"code":                "PROVIDER_OUTAGE",
+		"provider":            outage.Provider,
+		"retry_after_seconds": retryAfter,
+	})
+}
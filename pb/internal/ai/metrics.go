@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/metrics"
+)
+
+// RegisterMetrics exposes the text gate and the transcription job queue as gauges on /metrics
+func RegisterMetrics(app core.App) {
+	metrics.NewGaugeFunc("ramble_text_gate_requests",
+		"Text requests holding a slot (in_flight) or waiting for one (queued), by task",
+		func() []metrics.Sample {
+			stats := sharedTextGate().Stats()
+			var samples []metrics.Sample
+			for task, count := range stats.InFlightByTask {
+				samples = append(samples, metrics.Sample{Labels: []string{"in_flight", task}, Value: float64(count)})
+			}
+			for task, count := range stats.QueuedByTask {
+				samples = append(samples, metrics.Sample{Labels: []string{"queued", task}, Value: float64(count)})
+			}
+			return samples
+		}, "state", "task")
+
+	metrics.NewGaugeFunc("ramble_transcription_jobs",
+		"Async transcription jobs waiting for or held by a worker, by status",
+		func() []metrics.Sample {
+			var rows []struct {
+				Status string `db:"status"`
+				Count  int    `db:"count"`
+			}
+			err := app.DB().NewQuery("SELECT status, COUNT(*) AS count FROM transcription_jobs WHERE status IN ({:queued}, {:processing}) GROUP BY status").
+				Bind(dbx.Params{"queued": JobQueued, "processing": JobProcessing}).
+				All(&rows)
+			if err != nil {
+				return nil
+			}
+
+			counts := map[string]int{JobQueued: 0, JobProcessing: 0}
+			for _, row := range rows {
+				counts[row.Status] = row.Count
+			}
+			samples := make([]metrics.Sample, 0, len(counts))
+			for status, count := range counts {
+				samples = append(samples, metrics.Sample{Labels: []string{status}, Value: float64(count)})
+			}
+			return samples
+		}, "status")
+}
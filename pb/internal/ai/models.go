@@ -0,0 +1,179 @@
+package ai
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// Built-in allowlist, used when AI_ALLOWED_MODELS / AI_FREE_MODELS are unset
+var (
+	defaultAllowedModels = []string{
+		"anthropic/claude-3.5-sonnet",
+		"anthropic/claude-3-haiku",
+		"openai/gpt-4o",
+		"openai/gpt-4o-mini",
+		"google/gemini-flash-1.5",
+		"meta-llama/llama-3.1-8b-instruct",
+	}
+	defaultFreeModels = []string{
+		"anthropic/claude-3-haiku",
+		"openai/gpt-4o-mini",
+		"google/gemini-flash-1.5",
+		"meta-llama/llama-3.1-8b-instruct",
+	}
+)
+
+const defaultTextModel = "anthropic/claude-3.5-sonnet"
+
+// ModelPolicy is the server-side list of OpenRouter models text requests may use. Models that
+// aren't in Allowed are never forwarded, whatever a plan says
+type ModelPolicy struct {
+	Allowed []string `json:"allowed"`
+	Free    []string `json:"free"`    // what free plans get unless they list allowed_models
+	Default string   `json:"default"` // used when a request doesn't name a model
+}
+
+// ModelPolicyFromEnv reads AI_ALLOWED_MODELS, AI_FREE_MODELS (comma separated) and AI_DEFAULT_MODEL
+func ModelPolicyFromEnv() ModelPolicy {
+	policy := ModelPolicy{
+		Allowed: splitModels(os.Getenv("AI_ALLOWED_MODELS"), defaultAllowedModels),
+		Free:    splitModels(os.Getenv("AI_FREE_MODELS"), defaultFreeModels),
+		Default: strings.TrimSpace(os.Getenv("AI_DEFAULT_MODEL")),
+	}
+	if policy.Default == "" {
+		policy.Default = defaultTextModel
+	}
+	return policy
+}
+
+func splitModels(value string, fallback []string) []string {
+	var models []string
+	for _, model := range strings.Split(value, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	if len(models) == 0 {
+		return fallback
+	}
+	return models
+}
+
+// planModels returns the allowlisted models a plan may use. Plans can list allowed_models ("*"
+// for every allowlisted model); otherwise paid plans get the full allowlist and free plans the
+// free subset
+func planModels(plan *core.Record, policy ModelPolicy) []string {
+	var listed []string
+	if plan != nil {
+		_ = plan.UnmarshalJSONField("allowed_models", &listed)
+	}
+
+	var wanted []string
+	switch {
+	case slices.Contains(listed, "*"):
+		wanted = policy.Allowed
+	case len(listed) > 0:
+		wanted = listed
+	case plan != nil && plan.GetInt("price_cents") > 0:
+		wanted = policy.Allowed
+	default:
+		wanted = policy.Free
+	}
+
+	models := []string{}
+	for _, model := range wanted {
+		if slices.Contains(policy.Allowed, model) && !slices.Contains(models, model) {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// resolveModel picks the model for a request: the requested one, or the policy default when
+// the plan has it, else the plan's first model
+func resolveModel(requested string, allowed []string, policy ModelPolicy) string {
+	if requested != "" {
+		return requested
+	}
+	if slices.Contains(allowed, policy.Default) || len(allowed) == 0 {
+		return policy.Default
+	}
+	return allowed[0]
+}
+
+// UpgradePlan is a listed plan that unlocks a model the caller's plan doesn't include
+type UpgradePlan struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PriceCents int    `json:"price_cents"`
+}
+
+// modelUpgradePlans returns the listed plans, cheapest first, whose models include model
+func modelUpgradePlans(app core.App, model string, policy ModelPolicy) []UpgradePlan {
+	plans, err := subscription.NewRepository(app).GetAllPlans()
+	if err != nil {
+		return nil
+	}
+	upgrades := []UpgradePlan{}
+	for _, plan := range plans {
+		if slices.Contains(planModels(plan, policy), model) {
+			upgrades = append(upgrades, UpgradePlan{ID: plan.Id, Name: plan.GetString("name"), PriceCents: plan.GetInt("price_cents")})
+		}
+	}
+	return upgrades
+}
+
+// checkTextModel resolves request.Model against the user's plan, answering 400 for models that
+// aren't allowlisted and 403 with the plans that include it for models the plan doesn't cover
+// Returns handled=true once a response has been written
+func checkTextModel(e *core.RequestEvent, app core.App, userID string, request *TextProcessingRequest) (handled bool, err error) {
+	policy := ModelPolicyFromEnv()
+	plan, _ := userPlanLimit(app, userID)
+	allowed := planModels(plan, policy)
+	request.Model = resolveModel(request.Model, allowed, policy)
+
+	if !slices.Contains(policy.Allowed, request.Model) {
+		return true, e.JSON(400, map[string]any{
+			"error":          "Model " + request.Model + " is not supported",
+			"code":           "MODEL_NOT_SUPPORTED",
+			"allowed_models": allowed,
+		})
+	}
+	if !slices.Contains(allowed, request.Model) {
+		return true, e.JSON(403, map[string]any{
+			"error":          "Model " + request.Model + " is not included in your plan - upgrade to use it",
+			"code":           "MODEL_NOT_ALLOWED",
+			"allowed_models": allowed,
+			"upgrade_plans":  modelUpgradePlans(app, request.Model, policy),
+		})
+	}
+	return false, nil
+}
+
+// ModelsHandler lists the models the caller's plan can use, and the default for requests that
+// don't name one
+// GET /api/ai/models
+func ModelsHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := keyManager(e, app, ScopeAIText)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+
+	policy := ModelPolicyFromEnv()
+	plan, _ := userPlanLimit(app, user.Id)
+	allowed := planModels(plan, policy)
+
+	response := map[string]any{
+		"models":        allowed,
+		"default_model": resolveModel("", allowed, policy),
+	}
+	if plan != nil {
+		response["plan"] = plan.GetString("name")
+	}
+	return e.JSON(200, response)
+}
@@ -0,0 +1,97 @@
+//go:build integration
+
+package ai_test
+
+import (
+	"net/http"
+	"testing"
+
+	"pocketbase/internal/ai"
+	"pocketbase/internal/testharness"
+)
+
+func TestModelsAuthentication(t *testing.T) {
+	app := testharness.New(t)
+	testharness.SeedPlans(t, app)
+	user := testharness.CreateUser(t, app, "caller@test.com")
+
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+	get := func(token string) testharness.Response {
+		return server.Do(t, http.MethodGet, "/api/ai/models", token, nil)
+	}
+
+	get("").Expect(t, http.StatusUnauthorized)
+	get("ra-00000000000000000000000000000000").Expect(t, http.StatusUnauthorized)
+
+	denied := get(testharness.CreateAPIKey(t, app, user, ai.ScopeAIAudio)).Expect(t, http.StatusForbidden).JSON(t)
+	if denied["required_scope"] != ai.ScopeAIText {
+		t.Errorf("denied = %v, want ai:text required", denied)
+	}
+
+	for name, token := range map[string]string{
+		"scoped key":      testharness.CreateAPIKey(t, app, user, ai.ScopeAIText),
+		"full access key": testharness.CreateAPIKey(t, app, user),
+		"session":         testharness.AuthToken(t, user),
+	} {
+		body := get(token).Expect(t, http.StatusOK).JSON(t)
+		if models, _ := body["models"].([]any); len(models) == 0 {
+			t.Errorf("%s: no models listed: %v", name, body)
+		}
+	}
+}
+
+func TestDeactivatedAccountKeysStopWorking(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "leaving@test.com")
+	key := testharness.CreateAPIKey(t, app, user)
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	server.Do(t, http.MethodGet, "/api/ai/models", key, nil).Expect(t, http.StatusOK)
+
+	user.Set("deactivated_at", "2026-01-01 00:00:00.000Z")
+	if err := app.Save(user); err != nil {
+		t.Fatal(err)
+	}
+	server.Do(t, http.MethodGet, "/api/ai/models", key, nil).Expect(t, http.StatusUnauthorized)
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "rotator@test.com")
+	key := testharness.CreateAPIKey(t, app, user, ai.ScopeAIText, ai.ScopeKeysManage)
+	record, err := app.FindFirstRecordByData("api_keys", "key_prefix", key[:8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	rotated := server.Do(t, http.MethodPost, "/api/api-keys/"+record.Id+"/rotate", key, nil).Expect(t, http.StatusOK).JSON(t)
+	replacement, _ := rotated["api_key"].(string)
+	if replacement == "" || replacement == key {
+		t.Fatalf("rotate = %v, want a new key", rotated)
+	}
+
+	server.Do(t, http.MethodGet, "/api/ai/models", key, nil).Expect(t, http.StatusUnauthorized)
+	server.Do(t, http.MethodGet, "/api/ai/models", replacement, nil).Expect(t, http.StatusOK)
+}
+
+func TestRotateAPIKeyNeedsTheKeysScopes(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "escalator@test.com")
+	manager := testharness.CreateAPIKey(t, app, user, ai.ScopeKeysManage)
+	full := testharness.CreateAPIKey(t, app, user)
+	record, err := app.FindFirstRecordByData("api_keys", "key_prefix", full[:8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	denied := server.Do(t, http.MethodPost, "/api/api-keys/"+record.Id+"/rotate", manager, nil).Expect(t, http.StatusForbidden).JSON(t)
+	if denied["api_key"] != nil || denied["code"] != "INSUFFICIENT_SCOPE" {
+		t.Errorf("rotate = %v, want INSUFFICIENT_SCOPE without a key", denied)
+	}
+	server.Do(t, http.MethodGet, "/api/ai/models", full, nil).Expect(t, http.StatusOK)
+
+	// The user's session can rotate any of their keys
+	server.Do(t, http.MethodPost, "/api/api-keys/"+record.Id+"/rotate", testharness.AuthToken(t, user), nil).Expect(t, http.StatusOK)
+}
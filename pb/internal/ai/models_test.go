@@ -0,0 +1,85 @@
+package ai
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func modelTestPlan(priceCents int, allowedModels string) *core.Record {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(
+		&core.NumberField{Name: "price_cents"},
+		&core.JSONField{Name: "allowed_models"},
+	)
+	plan := core.NewRecord(collection)
+	plan.Set("price_cents", priceCents)
+	if allowedModels != "" {
+		plan.Set("allowed_models", allowedModels)
+	}
+	return plan
+}
+
+func TestPlanModels(t *testing.T) {
+	policy := ModelPolicy{
+		Allowed: []string{"big", "medium", "small"},
+		Free:    []string{"small", "retired"},
+		Default: "big",
+	}
+
+	tests := []struct {
+		name string
+		plan *core.Record
+		want []string
+	}{
+		{"no plan", nil, []string{"small"}},
+		{"free plan gets the allowlisted free models", modelTestPlan(0, ""), []string{"small"}},
+		{"paid plan gets the allowlist", modelTestPlan(999, ""), []string{"big", "medium", "small"}},
+		{"plan list wins over price", modelTestPlan(999, `["medium","small"]`), []string{"medium", "small"}},
+		{"plan list can't add unlisted models", modelTestPlan(0, `["medium","huge","medium"]`), []string{"medium"}},
+		{"wildcard means the allowlist", modelTestPlan(0, `["*"]`), []string{"big", "medium", "small"}},
+	}
+	for _, tt := range tests {
+		if got := planModels(tt.plan, policy); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResolveModel(t *testing.T) {
+	policy := ModelPolicy{Default: "big"}
+
+	tests := []struct {
+		requested string
+		allowed   []string
+		want      string
+	}{
+		{"medium", []string{"small"}, "medium"}, // checked against the plan afterwards
+		{"", []string{"big", "small"}, "big"},
+		{"", []string{"small", "medium"}, "small"},
+		{"", nil, "big"},
+	}
+	for _, tt := range tests {
+		if got := resolveModel(tt.requested, tt.allowed, policy); got != tt.want {
+			t.Errorf("resolveModel(%q, %v) = %q, want %q", tt.requested, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestModelPolicyFromEnv(t *testing.T) {
+	t.Setenv("AI_ALLOWED_MODELS", " a/one, b/two ,,")
+	t.Setenv("AI_FREE_MODELS", "")
+	t.Setenv("AI_DEFAULT_MODEL", "b/two")
+
+	policy := ModelPolicyFromEnv()
+	if !slices.Equal(policy.Allowed, []string{"a/one", "b/two"}) {
+		t.Errorf("Allowed = %v", policy.Allowed)
+	}
+	if !slices.Equal(policy.Free, defaultFreeModels) {
+		t.Errorf("Free = %v, want the built-in list", policy.Free)
+	}
+	if policy.Default != "b/two" {
+		t.Errorf("Default = %q", policy.Default)
+	}
+}
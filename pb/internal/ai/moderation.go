@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/moderation"
+	"pocketbase/internal/whisper"
+)
+
+// moderationConfig reads the moderation policy. A misconfigured moderator turns moderation
+// off rather than failing every request; the startup doctor reports it
+func moderationConfig(logger *slog.Logger) moderation.Config {
+	config, err := moderation.ConfigFromEnv()
+	if err != nil {
+		logger.Warn("Content moderation misconfigured, skipping it", "error", err)
+	}
+	return config
+}
+
+// checkPrompt screens the user prompt of a text request before it's forwarded. Flagged prompts
+// are recorded and refused with CONTENT_FLAGGED. handled means a response was written
+func checkPrompt(e *core.RequestEvent, app core.App, logger *slog.Logger, userID string, request *TextProcessingRequest) (bool, error) {
+	config := moderationConfig(logger)
+	verdict, err := config.Check(e.Request.Context(), moderation.SourcePrompt, request.UserPrompt)
+	if err != nil {
+		if config.FailClosed {
+			logger.Warn("Text request rejected", "reason", "moderation_unavailable", "error", err)
+			return true, e.JSON(503, map[string]string{"error": "Content moderation is unavailable, please retry shortly", "code": "MODERATION_UNAVAILABLE"})
+		}
+		logger.Warn("Content moderation failed, forwarding the prompt unchecked", "error", err)
+		return false, nil
+	}
+	if verdict == nil || !verdict.Flagged {
+		return false, nil
+	}
+
+	flag, err := moderation.Record(app, moderation.Flag{
+		UserID:   userID,
+		Source:   moderation.SourcePrompt,
+		Action:   moderation.ActionBlock,
+		Text:     request.UserPrompt,
+		TaskType: request.TaskType,
+		Verdict:  verdict,
+	})
+	if err != nil {
+		logger.Error("Failed to record moderation flag", "error", err)
+	}
+	logger.Warn("Text request rejected", "reason", "content_flagged", "categories", verdict.Categories)
+	return true, contentFlaggedResponse(e, verdict, flag)
+}
+
+// moderateTranscript screens a finished transcript and records it when flagged. When
+// MODERATION_TRANSCRIPTS is block it returns moderation.ErrFlagged, and the transcript kept on
+// the processed_files record is dropped so a re-upload can't fetch it through deduplication.
+// A moderator failure lets the transcript through
+func moderateTranscript(ctx context.Context, app core.App, logger *slog.Logger, userID string, processedFile *core.Record, result *whisper.Result) (*moderation.Verdict, *core.Record, error) {
+	config := moderationConfig(logger)
+	verdict, err := config.Check(ctx, moderation.SourceTranscript, result.Transcript)
+	if err != nil {
+		logger.Warn("Content moderation failed, returning the transcript unchecked", "error", err)
+		return nil, nil, nil
+	}
+	if verdict == nil || !verdict.Flagged {
+		return verdict, nil, nil
+	}
+
+	flag := moderation.Flag{
+		UserID:  userID,
+		Source:  moderation.SourceTranscript,
+		Action:  config.Transcript,
+		Text:    result.Transcript,
+		Verdict: verdict,
+	}
+	if processedFile != nil {
+		flag.ProcessedFileID = processedFile.Id
+	}
+	record, err := moderation.Record(app, flag)
+	if err != nil {
+		logger.Error("Failed to record moderation flag", "error", err)
+	}
+	logger.Warn("Transcript flagged by content moderation", "categories", verdict.Categories, "action", config.Transcript)
+
+	if config.Transcript != moderation.ActionBlock {
+		return verdict, record, nil
+	}
+	if processedFile != nil {
+		processedFile.Set("transcription_result", nil)
+		if err := app.Save(processedFile); err != nil {
+			logger.Error("Failed to drop flagged transcript", "processed_file_id", processedFile.Id, "error", err)
+		}
+	}
+	return verdict, record, moderation.ErrFlagged
+}
+
+// contentFlaggedMessage is the error of a transcription job whose transcript was withheld
+const contentFlaggedMessage = "Transcript withheld: flagged by content moderation (" + moderation.ErrorCode + ")"
+
+// contentFlaggedResponse refuses content moderation flagged, naming the categories
+func contentFlaggedResponse(e *core.RequestEvent, verdict *moderation.Verdict, flag *core.Record) error {
+	body := map[string]any{
+		"error":      "Content was flagged by moderation: " + strings.Join(verdict.Categories, ", "),
+		"code":       moderation.ErrorCode,
+		"categories": verdict.Categories,
+	}
+	if flag != nil {
+		body["flag_id"] = flag.Id
+	}
+	return e.JSON(422, body)
+}
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"log/slog"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/apisign"
+	"pocketbase/internal/organizations"
+)
+
+// apiKeyContextKey stores the API key record that authenticated a Bearer request
+const apiKeyContextKey = "ai_api_key"
+
+// usageAccount is who a transcription's hours are charged to: the user's own monthly_usage,
+// or their row in an organization's pool (monthly_usage with organization_id set)
+type usageAccount struct {
+	UserID         string
+	OrganizationID string
+}
+
+func personalAccount(userID string) usageAccount {
+	return usageAccount{UserID: userID}
+}
+
+func (a usageAccount) String() string {
+	if a.OrganizationID != "" {
+		return a.UserID + " (org " + a.OrganizationID + ")"
+	}
+	return a.UserID
+}
+
+// requestAPIKey returns the key behind a signed or Bearer request authenticated by authenticateAPIKey
+func requestAPIKey(e *core.RequestEvent) *core.Record {
+	if key := apisign.SignedKey(e); key != nil {
+		return key
+	}
+	key, _ := e.Get(apiKeyContextKey).(*core.Record)
+	return key
+}
+
+// requestUsageAccount resolves the account for a request from the API key it used
+func requestUsageAccount(e *core.RequestEvent, app core.App, user *core.Record) usageAccount {
+	var orgID string
+	if key := requestAPIKey(e); key != nil {
+		orgID = key.GetString("organization_id")
+	}
+	return resolveUsageAccount(app, user.Id, orgID)
+}
+
+// resolveUsageAccount charges an organization's pool when the key belongs to it, or when the
+// user's own subscription is the one attached to an organization - otherwise the user pays.
+// A key whose member left, or whose organization has no pool anymore, falls back to the user
+func resolveUsageAccount(app core.App, userID, keyOrgID string) usageAccount {
+	if keyOrgID == "" {
+		sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": userID})
+		if err != nil || sub.GetString("organization_id") == "" {
+			return personalAccount(userID)
+		}
+		keyOrgID = sub.GetString("organization_id")
+	}
+
+	if _, err := organizations.ChargeablePool(app, keyOrgID, userID); err != nil {
+		slog.Warn("Organization can't be charged, using personal allowance", "organization_id", keyOrgID, "user_id", userID, "error", err)
+		return personalAccount(userID)
+	}
+	return usageAccount{UserID: userID, OrganizationID: keyOrgID}
+}
@@ -0,0 +1,139 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"pocketbase/internal/audioprep"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/progress"
+	"pocketbase/internal/whisper"
+)
+
+// preparedAudio is the audio sent to the transcription provider: the upload itself, a
+// compressed copy of it, or segments of it when it's over the provider's file size limit
+type preparedAudio struct {
+	Reader     io.Reader
+	SizeBytes  int64
+	Filename   string
+	Segments   []*audioprep.Segment // set when the audio was split; Reader isn't used then
+	compressed *audioprep.Compressed
+}
+
+// Close removes the compressed copy and segments, if any were made
+func (p *preparedAudio) Close() {
+	if p.compressed != nil {
+		p.compressed.Close()
+	}
+	for _, segment := range p.Segments {
+		segment.Close()
+	}
+}
+
+// prepareAudio compresses audio with ffmpeg when the request's preprocess option or
+// AUDIO_PREPROCESS asks for it (see audioprep). The original is sent when compression is off,
+// fails, or doesn't make the file smaller. Audio still over WHISPER_MAX_FILE_SIZE is split into
+// segments, in silences where it can be. Timestamps are unaffected either way
+func prepareAudio(ctx context.Context, audio io.ReadSeeker, sizeBytes int64, filename string) *preparedAudio {
+	prepared := &preparedAudio{Reader: audio, SizeBytes: sizeBytes, Filename: filename}
+	config := audioprep.ConfigFromEnv()
+	logger := logging.FromContext(ctx)
+
+	if config.ShouldCompress(whisper.OptionsFrom(ctx).Preprocess, sizeBytes) {
+		compressed, err := config.Compress(ctx, audio)
+		rewind(logger, audio)
+		switch {
+		case err != nil:
+			logger.Warn("Audio compression failed, sending the original", "size_bytes", sizeBytes, "error", err)
+		case compressed.SizeBytes >= sizeBytes && sizeBytes > 0:
+			compressed.Close()
+			logger.Debug("Compressed audio isn't smaller, sending the original", "size_bytes", sizeBytes)
+		default:
+			logger.Info("Compressed audio before transcription", "size_bytes", sizeBytes, "compressed_bytes", compressed.SizeBytes)
+			prepared = &preparedAudio{
+				Reader:     compressed,
+				SizeBytes:  compressed.SizeBytes,
+				Filename:   strings.TrimSuffix(filename, filepath.Ext(filename)) + ".ogg",
+				compressed: compressed,
+			}
+		}
+	}
+
+	// Split the original rather than the compressed copy, so the audio is only encoded once
+	maxFile := UploadLimitsFromEnv().ProviderFile
+	if prepared.SizeBytes <= maxFile || !config.Available() {
+		return prepared
+	}
+	segments, err := config.Split(ctx, audio, maxFile)
+	rewind(logger, audio)
+	if err != nil {
+		logger.Warn("Audio splitting failed, sending it whole", "size_bytes", prepared.SizeBytes, "error", err)
+		return prepared
+	}
+	logger.Info("Split audio over the provider's file size limit", "size_bytes", sizeBytes, "segments", len(segments))
+	if prepared.compressed != nil {
+		prepared.compressed.Close()
+	}
+	return &preparedAudio{Reader: audio, SizeBytes: sizeBytes, Filename: filename, Segments: segments}
+}
+
+// rewind moves audio back to its start after ffmpeg may have read it, for the provider or diarizer
+func rewind(logger *slog.Logger, audio io.Seeker) {
+	if _, err := audio.Seek(0, io.SeekStart); err != nil {
+		logger.Warn("Failed to rewind audio", "error", err)
+	}
+}
+
+// transcribe sends the prepared audio to the transcription provider, reporting progress on
+// tracker (which may be nil). Segments are transcribed concurrently, SESSION_CHUNK_CONCURRENCY
+// at a time, and joined in order on the original file's timeline
+func (p *preparedAudio) transcribe(ctx context.Context, tracker *progress.Tracker) (*whisper.Result, error) {
+	if len(p.Segments) == 0 {
+		return streamToTranscriptionProvider(ctx, tracker.Reader(p.Reader, p.SizeBytes, 0, 100), p.Filename)
+	}
+
+	results := make([]*whisper.Result, len(p.Segments))
+	indices := make([]int, len(p.Segments))
+	for i := range indices {
+		indices[i] = i
+	}
+	base := strings.TrimSuffix(p.Filename, filepath.Ext(p.Filename))
+	var doneMu sync.Mutex
+	done := 0
+	failed, err := runChunks(ctx, indices, sessionChunkConcurrency(), func(ctx context.Context, i int) error {
+		segment := p.Segments[i]
+		result, err := streamToTranscriptionProvider(ctx, segment, fmt.Sprintf("%s_segment_%03d.ogg", base, i))
+		if err != nil {
+			return err
+		}
+		results[i] = result
+
+		doneMu.Lock()
+		done++
+		tracker.Update(progress.StageTranscribing, done*100/len(p.Segments))
+		doneMu.Unlock()
+		return nil
+	})
+	if err != nil {
+		if failed >= 0 {
+			return nil, fmt.Errorf("segment %d of %d: %w", failed+1, len(p.Segments), err)
+		}
+		return nil, err
+	}
+
+	offsets := make([]float64, len(p.Segments))
+	durations := make([]float64, len(p.Segments))
+	for i, segment := range p.Segments {
+		offsets[i] = segment.Start
+		durations[i] = chunkDuration(results[i], segment.Duration)
+	}
+	merged := mergeChunkResults(results, offsets, durations)
+	merged.Model = results[0].Model
+	merged.Provider = results[0].Provider
+	return merged, nil
+}
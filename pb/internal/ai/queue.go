@@ -0,0 +1,279 @@
+package ai
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Errors returned by TextGate.Acquire when a request can't get a slot
+var (
+	ErrTextQueueFull    = errors.New("text request queue is full")
+	ErrTextQueueTimeout = errors.New("timed out waiting for a text processing slot")
+)
+
+// TextGateConfig bounds concurrent text requests globally, per user and per task type
+// A limit of 0 means unlimited
+type TextGateConfig struct {
+	Global       int
+	PerUser      int
+	PerTask      map[string]int
+	MaxQueued    int
+	QueueTimeout time.Duration
+}
+
+// TextGateConfigFromEnv reads TEXT_CONCURRENCY_GLOBAL, TEXT_CONCURRENCY_PER_USER,
+// TEXT_CONCURRENCY_TASKS ("chat=8,suggest_highlights=4"), TEXT_QUEUE_MAX and TEXT_QUEUE_TIMEOUT_SECONDS
+func TextGateConfigFromEnv() TextGateConfig {
+	config := TextGateConfig{
+		Global:       20,
+		PerUser:      2,
+		PerTask:      map[string]int{},
+		MaxQueued:    200,
+		QueueTimeout: 60 * time.Second,
+	}
+	if v, err := strconv.Atoi(os.Getenv("TEXT_CONCURRENCY_GLOBAL")); err == nil && v >= 0 {
+		config.Global = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("TEXT_CONCURRENCY_PER_USER")); err == nil && v >= 0 {
+		config.PerUser = v
+	}
+	for _, pair := range strings.Split(os.Getenv("TEXT_CONCURRENCY_TASKS"), ",") {
+		task, limit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.Atoi(strings.TrimSpace(limit)); err == nil && v >= 0 {
+			config.PerTask[strings.TrimSpace(task)] = v
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("TEXT_QUEUE_MAX")); err == nil && v >= 0 {
+		config.MaxQueued = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("TEXT_QUEUE_TIMEOUT_SECONDS")); err == nil && v > 0 {
+		config.QueueTimeout = time.Duration(v) * time.Second
+	}
+	return config
+}
+
+// TextQueueStats is a snapshot of the text gate for metrics
+type TextQueueStats struct {
+	InFlight       int            `json:"in_flight"`
+	Queued         int            `json:"queued"`
+	InFlightByTask map[string]int `json:"in_flight_by_task"`
+	QueuedByTask   map[string]int `json:"queued_by_task"`
+	MaxQueueDepth  int            `json:"max_queue_depth"` // highest queue depth since startup
+	TotalQueued    int64          `json:"total_queued"`    // requests that had to wait for a slot
+	TotalTimedOut  int64          `json:"total_timed_out"`
+	TotalRejected  int64          `json:"total_rejected"` // queue was full
+	Limits         TextGateConfig `json:"limits"`
+}
+
+type textWaiter struct {
+	userID string
+	task   string
+	ready  chan struct{}
+}
+
+// TextGate admits text requests while global, per-user and per-task slots are free
+// and queues the rest. Queued requests are admitted in arrival order, but a request
+// blocked only by its own user's or task's limit doesn't hold up others behind it
+type TextGate struct {
+	config TextGateConfig
+
+	mu       sync.Mutex
+	inFlight int
+	byUser   map[string]int
+	byTask   map[string]int
+	waiting  *list.List // of *textWaiter
+
+	maxQueueDepth int
+	totalQueued   int64
+	totalTimedOut int64
+	totalRejected int64
+}
+
+// NewTextGate creates a gate with the given limits
+func NewTextGate(config TextGateConfig) *TextGate {
+	return &TextGate{
+		config:  config,
+		byUser:  map[string]int{},
+		byTask:  map[string]int{},
+		waiting: list.New(),
+	}
+}
+
+var (
+	textGateOnce sync.Once
+	textGate     *TextGate
+)
+
+// sharedTextGate returns the process-wide gate, configured from the environment on first use
+func sharedTextGate() *TextGate {
+	textGateOnce.Do(func() {
+		textGate = NewTextGate(TextGateConfigFromEnv())
+	})
+	return textGate
+}
+
+// Acquire waits for a slot and returns a function that releases it, along with how long
+// the request was queued. It fails when the queue is full, the queue timeout passes or ctx ends
+func (g *TextGate) Acquire(ctx context.Context, userID, task string) (func(), time.Duration, error) {
+	g.mu.Lock()
+	if g.waiting.Len() == 0 && g.fits(userID, task) {
+		g.admit(userID, task)
+		g.mu.Unlock()
+		return g.releaser(userID, task), 0, nil
+	}
+
+	if g.config.MaxQueued > 0 && g.waiting.Len() >= g.config.MaxQueued {
+		g.totalRejected++
+		g.mu.Unlock()
+		return nil, 0, ErrTextQueueFull
+	}
+
+	waiter := &textWaiter{userID: userID, task: task, ready: make(chan struct{})}
+	element := g.waiting.PushBack(waiter)
+	g.totalQueued++
+	if g.waiting.Len() > g.maxQueueDepth {
+		g.maxQueueDepth = g.waiting.Len()
+	}
+	g.mu.Unlock()
+
+	queuedAt := time.Now()
+	timer := time.NewTimer(g.config.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-waiter.ready:
+		return g.releaser(userID, task), time.Since(queuedAt), nil
+	case <-timer.C:
+		if g.abandon(element, waiter) {
+			return g.releaser(userID, task), time.Since(queuedAt), nil
+		}
+		g.mu.Lock()
+		g.totalTimedOut++
+		g.mu.Unlock()
+		return nil, time.Since(queuedAt), ErrTextQueueTimeout
+	case <-ctx.Done():
+		if g.abandon(element, waiter) {
+			return g.releaser(userID, task), time.Since(queuedAt), nil
+		}
+		return nil, time.Since(queuedAt), ctx.Err()
+	}
+}
+
+// abandon removes a waiter from the queue, reporting true if it was admitted in the meantime
+func (g *TextGate) abandon(element *list.Element, waiter *textWaiter) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	select {
+	case <-waiter.ready:
+		return true
+	default:
+		g.waiting.Remove(element)
+		return false
+	}
+}
+
+// releaser returns a release function that is safe to call more than once
+func (g *TextGate) releaser(userID, task string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { g.release(userID, task) })
+	}
+}
+
+func (g *TextGate) release(userID, task string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.inFlight--
+	if g.byUser[userID]--; g.byUser[userID] <= 0 {
+		delete(g.byUser, userID)
+	}
+	if g.byTask[task]--; g.byTask[task] <= 0 {
+		delete(g.byTask, task)
+	}
+
+	for element := g.waiting.Front(); element != nil; {
+		next := element.Next()
+		waiter := element.Value.(*textWaiter)
+		if g.fits(waiter.userID, waiter.task) {
+			g.waiting.Remove(element)
+			g.admit(waiter.userID, waiter.task)
+			close(waiter.ready)
+		}
+		if g.config.Global > 0 && g.inFlight >= g.config.Global {
+			break
+		}
+		element = next
+	}
+}
+
+// fits reports whether a request for userID and task can start now (caller holds the lock)
+func (g *TextGate) fits(userID, task string) bool {
+	if g.config.Global > 0 && g.inFlight >= g.config.Global {
+		return false
+	}
+	if g.config.PerUser > 0 && g.byUser[userID] >= g.config.PerUser {
+		return false
+	}
+	if limit, ok := g.config.PerTask[task]; ok && limit > 0 && g.byTask[task] >= limit {
+		return false
+	}
+	return true
+}
+
+// admit takes the slots for a request (caller holds the lock)
+func (g *TextGate) admit(userID, task string) {
+	g.inFlight++
+	g.byUser[userID]++
+	g.byTask[task]++
+}
+
+// Stats returns a snapshot of in-flight and queued requests
+func (g *TextGate) Stats() TextQueueStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := TextQueueStats{
+		InFlight:       g.inFlight,
+		Queued:         g.waiting.Len(),
+		InFlightByTask: map[string]int{},
+		QueuedByTask:   map[string]int{},
+		MaxQueueDepth:  g.maxQueueDepth,
+		TotalQueued:    g.totalQueued,
+		TotalTimedOut:  g.totalTimedOut,
+		TotalRejected:  g.totalRejected,
+		Limits:         g.config,
+	}
+	for task, count := range g.byTask {
+		stats.InFlightByTask[task] = count
+	}
+	for element := g.waiting.Front(); element != nil; element = element.Next() {
+		stats.QueuedByTask[element.Value.(*textWaiter).task]++
+	}
+	return stats
+}
+
+// TextQueueStatsHandler exposes text request concurrency and queue depth (superusers only)
+// GET /api/admin/metrics/text-queue
+func TextQueueStatsHandler(e *core.RequestEvent) error {
+	return e.JSON(200, sharedTextGate().Stats())
+}
+
+// logTextQueueWait records requests that had to wait for a slot
+func logTextQueueWait(logger *slog.Logger, waited time.Duration) {
+	if waited > 0 {
+		logger.Info("Text request waited for a slot", "waited_ms", waited.Milliseconds())
+	}
+}
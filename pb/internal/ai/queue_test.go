@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTextGateQueuesOverPerUserLimit(t *testing.T) {
+	gate := NewTextGate(TextGateConfig{Global: 10, PerUser: 1, QueueTimeout: time.Second})
+
+	release, waited, err := gate.Acquire(context.Background(), "u1", "chat")
+	if err != nil || waited != 0 {
+		t.Fatalf("first acquire: waited %v, err %v", waited, err)
+	}
+
+	// Another user isn't held up by u1's limit
+	other, _, err := gate.Acquire(context.Background(), "u2", "chat")
+	if err != nil {
+		t.Fatalf("other user: %v", err)
+	}
+	defer other()
+
+	admitted := make(chan error, 1)
+	go func() {
+		second, _, err := gate.Acquire(context.Background(), "u1", "chat")
+		if err == nil {
+			second()
+		}
+		admitted <- err
+	}()
+
+	waitFor(t, func() bool { return gate.Stats().Queued == 1 })
+	if stats := gate.Stats(); stats.QueuedByTask["chat"] != 1 || stats.InFlight != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	release()
+	if err := <-admitted; err != nil {
+		t.Fatalf("queued acquire: %v", err)
+	}
+	if stats := gate.Stats(); stats.TotalQueued != 1 || stats.MaxQueueDepth != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTextGatePerTaskLimit(t *testing.T) {
+	gate := NewTextGate(TextGateConfig{PerTask: map[string]int{"suggest_highlights": 1}, QueueTimeout: 20 * time.Millisecond})
+
+	release, _, err := gate.Acquire(context.Background(), "u1", "suggest_highlights")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, _, err := gate.Acquire(context.Background(), "u2", "chat"); err != nil {
+		t.Fatalf("other task should not be limited: %v", err)
+	}
+	if _, _, err := gate.Acquire(context.Background(), "u2", "suggest_highlights"); !errors.Is(err, ErrTextQueueTimeout) {
+		t.Fatalf("expected queue timeout, got %v", err)
+	}
+	if stats := gate.Stats(); stats.TotalTimedOut != 1 || stats.Queued != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTextGateQueueFull(t *testing.T) {
+	gate := NewTextGate(TextGateConfig{Global: 1, MaxQueued: 1, QueueTimeout: time.Second})
+
+	release, _, _ := gate.Acquire(context.Background(), "u1", "chat")
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go gate.Acquire(ctx, "u2", "chat")
+	waitFor(t, func() bool { return gate.Stats().Queued == 1 })
+
+	if _, _, err := gate.Acquire(context.Background(), "u3", "chat"); !errors.Is(err, ErrTextQueueFull) {
+		t.Fatalf("expected full queue, got %v", err)
+	}
+
+	// Cancelled requests leave the queue
+	cancel()
+	waitFor(t, func() bool { return gate.Stats().Queued == 0 })
+}
+
+func TestTextGateConfigFromEnv(t *testing.T) {
+	t.Setenv("TEXT_CONCURRENCY_GLOBAL", "5")
+	t.Setenv("TEXT_CONCURRENCY_TASKS", "chat=3, suggest_highlights = 1,bogus")
+	t.Setenv("TEXT_QUEUE_TIMEOUT_SECONDS", "10")
+
+	config := TextGateConfigFromEnv()
+	if config.Global != 5 || config.PerUser != 2 || config.QueueTimeout != 10*time.Second {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if config.PerTask["chat"] != 3 || config.PerTask["suggest_highlights"] != 1 || len(config.PerTask) != 2 {
+		t.Fatalf("unexpected task limits %v", config.PerTask)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
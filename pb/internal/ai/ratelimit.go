@@ -0,0 +1,246 @@
+package ai
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/apisign"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/subscription"
+)
+
+// RateLimit is a token bucket: PerMinute tokens are refilled per minute, up to Burst
+// A zero PerMinute disables the limit
+type RateLimit struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+}
+
+// RateLimitConfig holds the default per-key limit (plans can override it) and the per-IP limit
+type RateLimitConfig struct {
+	Key RateLimit
+	IP  RateLimit
+}
+
+// RateLimitConfigFromEnv reads AI_RATE_LIMIT_PER_MINUTE, AI_RATE_LIMIT_BURST, AI_RATE_LIMIT_IP_PER_MINUTE
+// and AI_RATE_LIMIT_IP_BURST, falling back to defaults for unset or invalid values
+func RateLimitConfigFromEnv() RateLimitConfig {
+	config := RateLimitConfig{
+		Key: RateLimit{PerMinute: 60, Burst: 20},
+		IP:  RateLimit{PerMinute: 120, Burst: 40},
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("AI_RATE_LIMIT_PER_MINUTE"), 64); err == nil && v >= 0 {
+		config.Key.PerMinute = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("AI_RATE_LIMIT_BURST")); err == nil && v > 0 {
+		config.Key.Burst = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("AI_RATE_LIMIT_IP_PER_MINUTE"), 64); err == nil && v >= 0 {
+		config.IP.PerMinute = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("AI_RATE_LIMIT_IP_BURST")); err == nil && v > 0 {
+		config.IP.Burst = v
+	}
+	return config
+}
+
+// planLimitTTL is how long a key's plan limit is cached before the plan is looked up again
+const planLimitTTL = 5 * time.Minute
+
+// idleBucketTTL drops buckets that haven't been used for this long - they'd be full by then anyway
+const idleBucketTTL = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type cachedPlanLimit struct {
+	limit   RateLimit
+	expires time.Time
+}
+
+// RateLimiter keeps token buckets per API key and per client IP
+type RateLimiter struct {
+	config RateLimitConfig
+
+	mu         sync.Mutex
+	buckets    map[string]*tokenBucket
+	planLimits map[string]cachedPlanLimit
+	lastPrune  time.Time
+}
+
+// NewRateLimiter creates a limiter with the given defaults
+func NewRateLimiter(config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		config:     config,
+		buckets:    map[string]*tokenBucket{},
+		planLimits: map[string]cachedPlanLimit{},
+	}
+}
+
+// take removes a token from a bucket, returning how long to wait when it's empty
+func (l *RateLimiter) take(bucketKey string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	if limit.PerMinute <= 0 {
+		return true, 0
+	}
+	burst := float64(max(limit.Burst, 1))
+	perSecond := limit.PerMinute / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > idleBucketTTL {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.updated) > idleBucketTTL {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	bucket, ok := l.buckets[bucketKey]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, updated: now}
+		l.buckets[bucketKey] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+	return false, wait
+}
+
+// grantBucketPrefix keys the rate limit buckets of delegated token grants, instead of a key hash
+const grantBucketPrefix = "grant:"
+
+// keyLimit returns the limit for an API key's plan, caching it for planLimitTTL
+func (l *RateLimiter) keyLimit(app core.App, keyHash string, now time.Time) RateLimit {
+	l.mu.Lock()
+	cached, ok := l.planLimits[keyHash]
+	l.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.limit
+	}
+
+	limit := l.config.Key
+	if grantID, ok := strings.CutPrefix(keyHash, grantBucketPrefix); ok {
+		if grant, err := app.FindRecordById("delegated_tokens", grantID); err == nil {
+			limit = planRateLimit(app, grant.GetString("user_id"), l.config.Key)
+		}
+	} else if key, err := app.FindFirstRecordByFilter("api_keys", "key_hash = {:hash}", map[string]any{"hash": keyHash}); err == nil {
+		limit = planRateLimit(app, key.GetString("user_id"), l.config.Key)
+	}
+
+	l.mu.Lock()
+	l.planLimits[keyHash] = cachedPlanLimit{limit: limit, expires: now.Add(planLimitTTL)}
+	if len(l.planLimits) > 10000 {
+		for hash, entry := range l.planLimits {
+			if now.After(entry.expires) {
+				delete(l.planLimits, hash)
+			}
+		}
+	}
+	l.mu.Unlock()
+	return limit
+}
+
+// planRateLimit reads rate_limit_per_minute and rate_limit_burst from the user's plan
+// Plans that leave them at zero use the default
+func planRateLimit(app core.App, userID string, fallback RateLimit) RateLimit {
+	repo := subscription.NewRepository(app)
+	sub, err := repo.FindActiveSubscription(userID)
+	if err != nil {
+		return fallback
+	}
+	plan, err := repo.GetPlan(subscription.EffectivePlanID(sub, time.Now()))
+	if err != nil {
+		return fallback
+	}
+
+	limit := fallback
+	if v := plan.GetFloat("rate_limit_per_minute"); v > 0 {
+		limit.PerMinute = v
+	}
+	if v := plan.GetInt("rate_limit_burst"); v > 0 {
+		limit.Burst = v
+	}
+	return limit
+}
+
+var (
+	rateLimiterOnce sync.Once
+	rateLimiter     *RateLimiter
+)
+
+// sharedRateLimiter returns the process-wide limiter, so every AI endpoint draws from the same buckets
+func sharedRateLimiter() *RateLimiter {
+	rateLimiterOnce.Do(func() {
+		config := RateLimitConfigFromEnv()
+		slog.Info("AI rate limits configured", "key_per_minute", config.Key.PerMinute, "key_burst", config.Key.Burst,
+			"ip_per_minute", config.IP.PerMinute, "ip_burst", config.IP.Burst)
+		rateLimiter = NewRateLimiter(config)
+	})
+	return rateLimiter
+}
+
+// RateLimitMiddleware throttles AI requests per client IP and per API key, answering 429 with
+// Retry-After once a bucket is empty. Invalid keys are left to the handler to reject
+func RateLimitMiddleware(app core.App) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		limiter := sharedRateLimiter()
+		now := time.Now()
+		// Proxy headers only count when they're configured as trusted, so callers can't dodge
+		// their bucket by sending a different X-Forwarded-For with each request
+		clientIP := e.RealIP()
+
+		if ok, wait := limiter.take("ip:"+clientIP, limiter.config.IP, now); !ok {
+			logging.FromRequest(e).Warn("Rate limit hit", "scope", "ip", "path", e.Request.URL.Path, "ip", clientIP)
+			return rateLimited(e, wait)
+		}
+
+		keyHash := ""
+		if key := apisign.SignedKey(e); key != nil {
+			keyHash = key.GetString("key_hash")
+		} else if apiKey := extractBearerToken(e.Request.Header.Get("Authorization")); strings.HasPrefix(apiKey, DelegatedTokenPrefix) {
+			// Access tokens are renewed daily, so delegated tokens are throttled per grant
+			if grant, _, err := verifyDelegatedToken(app, apiKey); err == nil {
+				keyHash = grantBucketPrefix + grant.Id
+			}
+		} else if apiKey != "" {
+			keyHash = hashAPIKey(apiKey)
+		}
+		if keyHash != "" {
+			limit := limiter.keyLimit(app, keyHash, now)
+			if ok, wait := limiter.take("key:"+keyHash, limit, now); !ok {
+				logging.FromRequest(e).Warn("Rate limit hit", "scope", "key", "path", e.Request.URL.Path,
+					"per_minute", limit.PerMinute, "ip", clientIP)
+				return rateLimited(e, wait)
+			}
+		}
+
+		return e.Next()
+	}
+}
+
+func rateLimited(e *core.RequestEvent, wait time.Duration) error {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	e.Response.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+	return e.JSON(429, map[string]any{
+		"error":               fmt.Sprintf("Rate limit exceeded, retry in %d seconds", max(retryAfter, 1)),
+		"code":                "RATE_LIMITED",
+		"retry_after_seconds": max(retryAfter, 1),
+	})
+}
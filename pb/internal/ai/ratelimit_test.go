@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurstThenRefill(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{})
+	limit := RateLimit{PerMinute: 60, Burst: 3}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.take("key:a", limit, now); !ok {
+			t.Fatalf("request %d within the burst was rejected", i+1)
+		}
+	}
+
+	ok, wait := limiter.take("key:a", limit, now)
+	if ok {
+		t.Fatal("request beyond the burst should be rejected")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want up to one second at 60/min", wait)
+	}
+
+	// Other keys have their own bucket
+	if ok, _ := limiter.take("key:b", limit, now); !ok {
+		t.Error("a different key shouldn't share the bucket")
+	}
+
+	if ok, _ := limiter.take("key:a", limit, now.Add(time.Second)); !ok {
+		t.Error("a token should be refilled after a second")
+	}
+}
+
+func TestRateLimiterZeroDisables(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{})
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.take("ip:1.2.3.4", RateLimit{}, now); !ok {
+			t.Fatal("a zero limit should never reject")
+		}
+	}
+}
+
+func TestRateLimiterPrunesIdleBuckets(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{})
+	limit := RateLimit{PerMinute: 60, Burst: 1}
+	now := time.Now()
+
+	limiter.take("key:old", limit, now)
+	limiter.take("key:new", limit, now.Add(idleBucketTTL+time.Minute))
+
+	if _, ok := limiter.buckets["key:old"]; ok {
+		t.Error("idle bucket should have been pruned")
+	}
+	if _, ok := limiter.buckets["key:new"]; !ok {
+		t.Error("active bucket should be kept")
+	}
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/metrics"
+)
+
+// interruptedReason is the failure_reason of processed_files records failed by RecoverStuckProcessing
+const interruptedReason = "transcription was interrupted by a server restart"
+
+// recoveredProcessing counts processed_files records found stuck in processing at startup
+var recoveredProcessing = metrics.NewCounter("ramble_processing_recovered_total",
+	"processed_files records found stuck in processing at startup, by what was done with them (requeued, failed)",
+	"action")
+
+// stuckProcessingAge reads PROCESSING_RECOVERY_MINUTES: how long a processed_files record can
+// stay processing before it's considered abandoned (default 30, longer than any request may
+// run). 0 turns recovery off
+func stuckProcessingAge() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("PROCESSING_RECOVERY_MINUTES")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// RecoveryReport counts what RecoverStuckProcessing did
+type RecoveryReport struct {
+	Requeued int // left to a queued transcription job, which completes the record
+	Failed   int // marked failed and retriable
+}
+
+// RecoverStuckProcessing cleans up processed_files records left processing by a restart, so
+// they don't count as in-flight work or skew usage stats. Records of an async job that's queued
+// again (see StartTranscriptionWorkers) are left to it; synchronous uploads can't be resumed,
+// so their records are failed as retriable - the user uploads the file again, and failed
+// attempts don't count towards the processing limit
+func RecoverStuckProcessing(app core.App) RecoveryReport {
+	var report RecoveryReport
+	age := stuckProcessingAge()
+	if age == 0 {
+		return report
+	}
+
+	records, err := app.FindRecordsByFilter("processed_files", "status = 'processing' && updated < {:cutoff}", "", 0, 0,
+		map[string]any{"cutoff": time.Now().Add(-age).UTC().Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		slog.Error("Failed to load stuck processed files", "error", err)
+		return report
+	}
+
+	for _, record := range records {
+		logger := slog.Default().With("processed_file_id", record.Id, "user_id", record.GetString("user_id"))
+		if hasPendingJob(app, record.Id) {
+			report.Requeued++
+			recoveredProcessing.Inc("requeued")
+			logger.Info("Stuck processed file left to its queued job")
+			continue
+		}
+
+		record.Set("status", "failed")
+		record.Set("failure_reason", interruptedReason)
+		record.Set("retriable", true)
+		if err := app.Save(record); err != nil {
+			logger.Error("Failed to mark stuck processed file failed", "error", err)
+			continue
+		}
+		report.Failed++
+		recoveredProcessing.Inc("failed")
+		logger.Info("Marked stuck processed file failed", "stuck_since", record.GetDateTime("updated").Time())
+	}
+
+	if report.Requeued > 0 || report.Failed > 0 {
+		slog.Info("Recovered stuck processed files", "requeued", report.Requeued, "failed", report.Failed)
+	}
+	return report
+}
+
+// hasPendingJob reports whether a transcription job that will still complete the record is
+// queued or running
+func hasPendingJob(app core.App, processedFileID string) bool {
+	_, err := app.FindFirstRecordByFilter("transcription_jobs",
+		"processed_file_id = {:id} && (status = {:queued} || status = {:processing})",
+		map[string]any{"id": processedFileID, "queued": JobQueued, "processing": JobProcessing})
+	return err == nil
+}
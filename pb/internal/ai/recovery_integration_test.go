@@ -0,0 +1,83 @@
+//go:build integration
+
+package ai_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/ai"
+	"pocketbase/internal/testharness"
+)
+
+// processing records a processed file left processing since the given time
+func processing(t *testing.T, app core.App, user *core.Record, since time.Time) *core.Record {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId("processed_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := core.NewRecord(collection)
+	record.Set("user_id", user.Id)
+	record.Set("filename", "interview.mp3")
+	record.Set("status", "processing")
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+	// updated is an autodate, so it's backdated directly
+	_, err = app.DB().Update("processed_files", dbx.Params{"updated": since.UTC().Format("2006-01-02 15:04:05.000Z")},
+		dbx.HashExp{"id": record.Id}).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestRecoverStuckProcessing(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "restart@test.com")
+
+	stuck := processing(t, app, user, time.Now().Add(-2*time.Hour))
+	recent := processing(t, app, user, time.Now().Add(-time.Minute))
+	queued := processing(t, app, user, time.Now().Add(-2*time.Hour))
+
+	jobs, err := app.FindCollectionByNameOrId("transcription_jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := core.NewRecord(jobs)
+	job.Set("user_id", user.Id)
+	job.Set("status", ai.JobQueued)
+	job.Set("filename", "interview.mp3")
+	job.Set("processed_file_id", queued.Id)
+	if err := app.Save(job); err != nil {
+		t.Fatal(err)
+	}
+
+	report := ai.RecoverStuckProcessing(app)
+	if report.Failed != 1 || report.Requeued != 1 {
+		t.Fatalf("report = %+v, want 1 failed and 1 requeued", report)
+	}
+
+	want := map[string]string{stuck.Id: "failed", recent.Id: "processing", queued.Id: "processing"}
+	for id, status := range want {
+		record, err := app.FindRecordById("processed_files", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.GetString("status") != status {
+			t.Errorf("%s: status %s, want %s", id, record.GetString("status"), status)
+		}
+		if id == stuck.Id && (!record.GetBool("retriable") || record.GetString("failure_reason") == "") {
+			t.Errorf("failed record = %v, want retriable with a reason", record)
+		}
+	}
+
+	t.Setenv("PROCESSING_RECOVERY_MINUTES", "0")
+	if report := ai.RecoverStuckProcessing(app); report.Failed != 0 || report.Requeued != 0 {
+		t.Errorf("recovery turned off: report = %+v, want nothing done", report)
+	}
+}
@@ -0,0 +1,291 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// ErrProcessingLimitReached is returned by TranscribeStoredAudio when the same audio has already
+// been transcribed as many times as the user's plan allows
+var ErrProcessingLimitReached = errors.New("processing limit reached")
+
+// Sources of reprocess_grants records
+const (
+	grantSourceAdmin = "admin"
+	grantSourceRerun = "rerun"
+)
+
+// defaultProcessingAttempts reads AUDIO_MAX_ATTEMPTS: how many times a user can have the same
+// audio (by content hash) transcribed when their plan doesn't set max_processing_attempts.
+// Defaults to 2; 0 lifts the limit
+func defaultProcessingAttempts() int {
+	if v, err := strconv.Atoi(os.Getenv("AUDIO_MAX_ATTEMPTS")); err == nil && v >= 0 {
+		return v
+	}
+	return 2
+}
+
+// reprocessRerunLimit reads AUDIO_REPROCESS_RERUNS: how many re-runs of one file a user on a
+// paid plan can request (default 3). Superuser grants don't count towards it
+func reprocessRerunLimit() int {
+	if v, err := strconv.Atoi(os.Getenv("AUDIO_REPROCESS_RERUNS")); err == nil && v >= 0 {
+		return v
+	}
+	return 3
+}
+
+// planProcessingAttempts returns the plan's max_processing_attempts when set, otherwise fallback
+func planProcessingAttempts(plan *core.Record, fallback int) int {
+	if plan != nil {
+		if attempts := plan.GetInt("max_processing_attempts"); attempts > 0 {
+			return attempts
+		}
+	}
+	return fallback
+}
+
+// attemptUsage is how many times a user had a file transcribed against what they're allowed
+type attemptUsage struct {
+	Used    int // transcriptions that didn't fail, chunks excluded
+	Limit   int // the plan's attempts plus granted ones; 0 means no limit
+	Granted int // extra attempts from reprocess_grants
+	Reruns  int // of which re-runs the user requested
+}
+
+func (u attemptUsage) exhausted() bool {
+	return u.Limit > 0 && u.Used >= u.Limit
+}
+
+// processingAttemptUsage counts a user's transcriptions of the audio with contentHash. Failed
+// attempts aren't counted - they're usually the provider's fault, not the user's
+// Owners can only read processed_files, so they can't lower the count by editing or deleting them
+func processingAttemptUsage(app core.App, userID, contentHash string, plan *core.Record) (attemptUsage, error) {
+	var usage attemptUsage
+	used, err := app.CountRecords("processed_files", dbx.NewExp(
+		"user_id = {:user} AND content_hash = {:hash} AND is_chunk = FALSE AND status != 'failed'",
+		dbx.Params{"user": userID, "hash": contentHash}))
+	if err != nil {
+		return usage, err
+	}
+	usage.Used = int(used)
+
+	grants, err := app.FindRecordsByFilter("reprocess_grants", "user_id = {:user} && content_hash = {:hash}", "", 0, 0,
+		dbx.Params{"user": userID, "hash": contentHash})
+	if err != nil {
+		return usage, err
+	}
+	for _, grant := range grants {
+		usage.Granted += grant.GetInt("attempts")
+		if grant.GetString("source") == grantSourceRerun {
+			usage.Reruns += grant.GetInt("attempts")
+		}
+	}
+
+	if base := planProcessingAttempts(plan, defaultProcessingAttempts()); base > 0 {
+		usage.Limit = base + usage.Granted
+	}
+	return usage, nil
+}
+
+// checkProcessingAttempts rejects an upload of audio the user already had transcribed as many
+// times as they're allowed. Counting errors let the upload through
+func checkProcessingAttempts(e *core.RequestEvent, app core.App, logger *slog.Logger, userID, contentHash string) (handled bool, err error) {
+	plan, _ := userPlanLimit(app, userID)
+	usage, err := processingAttemptUsage(app, userID, contentHash, plan)
+	if err != nil {
+		logger.Warn("Failed to count processing attempts, allowing upload", "error", err)
+		return false, nil
+	}
+	if !usage.exhausted() {
+		return false, nil
+	}
+
+	logger.Warn("Audio request rejected", "reason", "processing_limit_reached", "attempts", usage.Used, "limit", usage.Limit)
+	response := map[string]any{
+		"error":        fmt.Sprintf("This file has already been transcribed %d times, the most your plan allows", usage.Used),
+		"code":         "PROCESSING_LIMIT_REACHED",
+		"content_hash": contentHash,
+		"attempts":     usage.Used,
+		"limit":        usage.Limit,
+	}
+	switch {
+	case plan == nil || plan.GetInt("price_cents") == 0:
+		response["hint"] = "Upgrade to a paid plan to re-run a file"
+	case usage.Reruns < reprocessRerunLimit():
+		response["hint"] = "Request a re-run with POST /api/ai/reprocess, then upload the file again with force=true"
+		response["reprocess_url"] = "/api/ai/reprocess"
+	default:
+		response["hint"] = "No more re-runs can be requested for this file - contact support"
+	}
+	return true, e.JSON(403, response)
+}
+
+// createReprocessGrant records extra attempts at transcribing a user's file
+func createReprocessGrant(app core.App, userID, contentHash string, attempts int, source, grantedBy, reason string) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("reprocess_grants")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find reprocess_grants collection: %w", err)
+	}
+	grant := core.NewRecord(collection)
+	grant.Set("user_id", userID)
+	grant.Set("content_hash", contentHash)
+	grant.Set("attempts", attempts)
+	grant.Set("source", source)
+	grant.Set("granted_by", grantedBy)
+	grant.Set("reason", reason)
+	if err := app.Save(grant); err != nil {
+		return nil, fmt.Errorf("failed to save reprocess grant: %w", err)
+	}
+	return grant, nil
+}
+
+// reprocessRequest names a file by its content hash or one of its processed_files records
+type reprocessRequest struct {
+	ContentHash     string `json:"content_hash"`
+	ProcessedFileID string `json:"processed_file_id"`
+}
+
+// resolveContentHash returns the content hash of a file the user had transcribed, or an error
+// response code
+func (r reprocessRequest) resolveContentHash(app core.App, userID string) (string, string) {
+	if r.ProcessedFileID != "" {
+		record, err := app.FindRecordById("processed_files", r.ProcessedFileID)
+		if err != nil || record.GetString("user_id") != userID {
+			return "", "FILE_NOT_FOUND"
+		}
+		if record.GetString("content_hash") == "" {
+			return "", "FILE_NOT_HASHED"
+		}
+		return record.GetString("content_hash"), ""
+	}
+	if r.ContentHash == "" {
+		return "", "FILE_REQUIRED"
+	}
+	if _, err := app.FindFirstRecordByFilter("processed_files", "user_id = {:user} && content_hash = {:hash} && is_chunk = false",
+		dbx.Params{"user": userID, "hash": r.ContentHash}); err != nil {
+		return "", "FILE_NOT_FOUND"
+	}
+	return r.ContentHash, ""
+}
+
+var reprocessFileErrors = map[string]struct {
+	status  int
+	message string
+}{
+	"FILE_REQUIRED":   {400, "content_hash or processed_file_id is required"},
+	"FILE_NOT_FOUND":  {404, "File not found"},
+	"FILE_NOT_HASHED": {409, "This file was transcribed in chunks or before content hashes were recorded, so it isn't limited"},
+}
+
+// ReprocessHandler grants one more transcription of a file that reached its processing limit.
+// Re-runs are for paid plans and aren't charged on their own: the transcription uses the plan's
+// hours like any other. The upload then needs force=true to skip the cached transcript
+// POST /api/ai/reprocess
+func ReprocessHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := keyManager(e, app, ScopeAIAudio)
+	if err != nil {
+		return keyManagerError(e, err)
+	}
+	logger := logging.FromRequest(e).With("endpoint", "reprocess", "user_id", user.Id)
+
+	var req reprocessRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request format"})
+	}
+	contentHash, code := req.resolveContentHash(app, user.Id)
+	if code != "" {
+		failure := reprocessFileErrors[code]
+		return e.JSON(failure.status, map[string]string{"error": failure.message, "code": code})
+	}
+
+	plan, _ := userPlanLimit(app, user.Id)
+	if plan == nil || plan.GetInt("price_cents") == 0 {
+		return e.JSON(402, map[string]string{
+			"error": "Re-runs are only available on paid plans - upgrade to reprocess this file",
+			"code":  "REPROCESS_REQUIRES_PAID_PLAN",
+		})
+	}
+
+	usage, err := processingAttemptUsage(app, user.Id, contentHash, plan)
+	if err != nil {
+		logger.Error("Failed to count processing attempts", "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to check processing attempts"})
+	}
+	if !usage.exhausted() {
+		return e.JSON(409, map[string]any{
+			"error":    "This file can still be transcribed without a re-run",
+			"code":     "REPROCESS_NOT_NEEDED",
+			"attempts": usage.Used,
+			"limit":    usage.Limit,
+		})
+	}
+	if usage.Reruns >= reprocessRerunLimit() {
+		return e.JSON(409, map[string]any{
+			"error":  "No more re-runs can be requested for this file - contact support",
+			"code":   "REPROCESS_LIMIT_REACHED",
+			"reruns": usage.Reruns,
+		})
+	}
+
+	grant, err := createReprocessGrant(app, user.Id, contentHash, 1, grantSourceRerun, "", "re-run requested by the user")
+	if err != nil {
+		logger.Error("Failed to create reprocess grant", "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to grant a re-run"})
+	}
+
+	logger.Info("Re-run granted", "grant_id", grant.Id, "content_hash", contentHash, "attempts", usage.Used, "reruns", usage.Reruns+1)
+	return e.JSON(201, map[string]any{
+		"id":           grant.Id,
+		"content_hash": contentHash,
+		"attempts":     usage.Used,
+		"limit":        usage.Limit + 1,
+	})
+}
+
+// AdminGrantReprocessHandler gives a user extra attempts at transcribing a file, e.g. after a
+// provider problem support has confirmed
+// POST /api/admin/reprocess-grants
+func AdminGrantReprocessHandler(e *core.RequestEvent, app core.App) error {
+	var req struct {
+		UserID      string `json:"user_id"`
+		ContentHash string `json:"content_hash"`
+		Attempts    int    `json:"attempts"`
+		Reason      string `json:"reason"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request format"})
+	}
+	if req.Attempts == 0 {
+		req.Attempts = 1
+	}
+	if req.UserID == "" || req.ContentHash == "" || req.Attempts < 1 || req.Attempts > 100 {
+		return e.JSON(400, map[string]string{"error": "user_id, content_hash and attempts (1-100) are required"})
+	}
+	if _, err := app.FindRecordById("users", req.UserID); err != nil {
+		return e.JSON(404, map[string]string{"error": "User not found"})
+	}
+
+	grant, err := createReprocessGrant(app, req.UserID, req.ContentHash, req.Attempts, grantSourceAdmin, e.Auth.Id, req.Reason)
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": err.Error()})
+	}
+
+	plan, _ := userPlanLimit(app, req.UserID)
+	usage, _ := processingAttemptUsage(app, req.UserID, req.ContentHash, plan)
+	logging.FromRequest(e).Info("Reprocess attempts granted", "user_id", req.UserID, "content_hash", req.ContentHash,
+		"attempts", req.Attempts, "granted_by", e.Auth.Id)
+	return e.JSON(201, map[string]any{
+		"id":           grant.Id,
+		"content_hash": req.ContentHash,
+		"attempts":     usage.Used,
+		"limit":        usage.Limit,
+	})
+}
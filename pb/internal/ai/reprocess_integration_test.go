@@ -0,0 +1,120 @@
+//go:build integration
+
+package ai_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/ai"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+// transcribed records a completed transcription of the audio with hash
+func transcribed(t *testing.T, app core.App, user *core.Record, hash string) {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId("processed_files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := core.NewRecord(collection)
+	record.Set("user_id", user.Id)
+	record.Set("filename", "interview.mp3")
+	record.Set("status", "completed")
+	record.Set("content_hash", hash)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReprocessRerun(t *testing.T) {
+	t.Setenv("AUDIO_REPROCESS_RERUNS", "1")
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	free := testharness.CreateUser(t, app, "free@test.com")
+	paid := testharness.CreateUser(t, app, "paid@test.com")
+	service := subscription.NewService(subscription.NewRepository(app))
+	if _, err := service.AssignPlan(paid.Id, plans["pro"].Id, time.Now().AddDate(0, 1, 0), "stripe"); err != nil {
+		t.Fatal(err)
+	}
+	freeKey := testharness.CreateAPIKey(t, app, free)
+	paidKey := testharness.CreateAPIKey(t, app, paid)
+
+	request := func(key, hash string) testharness.Response {
+		return server.Do(t, http.MethodPost, "/api/ai/reprocess", key, map[string]string{"content_hash": hash})
+	}
+	code := func(r testharness.Response) any {
+		return r.JSON(t)["code"]
+	}
+
+	if got := code(request(paidKey, "unknown").Expect(t, http.StatusNotFound)); got != "FILE_NOT_FOUND" {
+		t.Errorf("unknown file: code %v", got)
+	}
+
+	transcribed(t, app, paid, "hash-a")
+	if got := code(request(paidKey, "hash-a").Expect(t, http.StatusConflict)); got != "REPROCESS_NOT_NEEDED" {
+		t.Errorf("one of two attempts used: code %v", got)
+	}
+
+	transcribed(t, app, paid, "hash-a")
+	rerun := request(paidKey, "hash-a").Expect(t, http.StatusCreated).JSON(t)
+	if rerun["limit"] != 3.0 || rerun["attempts"] != 2.0 {
+		t.Errorf("rerun = %v, want 2 attempts used of 3", rerun)
+	}
+
+	transcribed(t, app, paid, "hash-a")
+	if got := code(request(paidKey, "hash-a").Expect(t, http.StatusConflict)); got != "REPROCESS_LIMIT_REACHED" {
+		t.Errorf("past AUDIO_REPROCESS_RERUNS: code %v", got)
+	}
+
+	transcribed(t, app, free, "hash-b")
+	transcribed(t, app, free, "hash-b")
+	if got := code(request(freeKey, "hash-b").Expect(t, http.StatusPaymentRequired)); got != "REPROCESS_REQUIRES_PAID_PLAN" {
+		t.Errorf("free plan: code %v", got)
+	}
+
+	// Support can still grant attempts past the re-run limit
+	admin := testharness.CreateSuperuser(t, app, "support@test.com")
+	grant := map[string]any{"user_id": paid.Id, "content_hash": "hash-a", "attempts": 2, "reason": "provider outage"}
+	server.Do(t, http.MethodPost, "/api/admin/reprocess-grants", testharness.AuthToken(t, paid), grant).Expect(t, http.StatusForbidden)
+	granted := server.Do(t, http.MethodPost, "/api/admin/reprocess-grants", testharness.AuthToken(t, admin), grant).
+		Expect(t, http.StatusCreated).JSON(t)
+	if granted["limit"] != 5.0 || granted["attempts"] != 3.0 {
+		t.Errorf("granted = %v, want 3 attempts used of 5", granted)
+	}
+}
+
+func TestOwnersCannotResetProcessingAttempts(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "reset@test.com")
+	key := testharness.CreateAPIKey(t, app, user)
+	token := testharness.AuthToken(t, user)
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+
+	transcribed(t, app, user, "hash-c")
+	transcribed(t, app, user, "hash-c")
+	files, err := app.FindRecordsByFilter("processed_files", "user_id = {:user}", "", 0, 0, map[string]any{"user": user.Id})
+	if err != nil || len(files) != 2 {
+		t.Fatalf("processed files = %d, %v", len(files), err)
+	}
+
+	records := "/api/collections/processed_files/records"
+	server.Do(t, http.MethodGet, records+"/"+files[0].Id, token, nil).Expect(t, http.StatusOK)
+	server.Do(t, http.MethodPatch, records+"/"+files[0].Id, token, map[string]any{"status": "failed"}).Expect(t, http.StatusForbidden)
+	server.Do(t, http.MethodDelete, records+"/"+files[1].Id, token, nil).Expect(t, http.StatusForbidden)
+	server.Do(t, http.MethodPost, records, token, map[string]any{"user_id": user.Id, "filename": "fake.mp3", "status": "completed"}).
+		Expect(t, http.StatusForbidden)
+
+	// Both attempts still count, so a re-run is needed
+	response := server.Do(t, http.MethodPost, "/api/ai/reprocess", key, map[string]string{"content_hash": "hash-c"}).
+		Expect(t, http.StatusPaymentRequired).JSON(t)
+	if response["code"] != "REPROCESS_REQUIRES_PAID_PLAN" {
+		t.Errorf("code = %v", response["code"])
+	}
+}
@@ -0,0 +1,50 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestPlanProcessingAttempts(t *testing.T) {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(&core.NumberField{Name: "max_processing_attempts"})
+	plan := core.NewRecord(collection)
+
+	if got := planProcessingAttempts(nil, 2); got != 2 {
+		t.Errorf("no plan: %d, want the default", got)
+	}
+	if got := planProcessingAttempts(plan, 2); got != 2 {
+		t.Errorf("unset: %d, want the default", got)
+	}
+	plan.Set("max_processing_attempts", 5)
+	if got := planProcessingAttempts(plan, 2); got != 5 {
+		t.Errorf("set: %d, want the plan's 5", got)
+	}
+}
+
+func TestDefaultProcessingAttempts(t *testing.T) {
+	for value, want := range map[string]int{"": 2, "4": 4, "0": 0, "-1": 2, "lots": 2} {
+		t.Setenv("AUDIO_MAX_ATTEMPTS", value)
+		if got := defaultProcessingAttempts(); got != want {
+			t.Errorf("AUDIO_MAX_ATTEMPTS=%q: %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestAttemptUsageExhausted(t *testing.T) {
+	tests := []struct {
+		usage attemptUsage
+		want  bool
+	}{
+		{attemptUsage{Used: 1, Limit: 2}, false},
+		{attemptUsage{Used: 2, Limit: 2}, true},
+		{attemptUsage{Used: 2, Limit: 3, Granted: 1}, false},
+		{attemptUsage{Used: 50, Limit: 0}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.usage.exhausted(); got != tt.want {
+			t.Errorf("%+v exhausted = %v, want %v", tt.usage, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ai
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+	"pocketbase/internal/sla"
+	"pocketbase/internal/whisper"
+)
+
+// RegisterRoutes adds the AI, API key, sandbox and usage routes. The AI routes are throttled
+// per API key (limits set per plan) and per IP
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/ai/process-text", Handler: func(e *core.RequestEvent) error {
+			return ProcessTextHandler(e, app)
+		}, Middlewares: []routes.Middleware{sla.Track(sla.EndpointAIText)}, RateLimited: true, Doc: &routes.Doc{Summary: "Run a text task on the AI model", Request: TextProcessingRequest{}, Response: OpenRouterResponse{}}},
+
+		// Audio processing route with streaming support and increased body limit
+		routes.Route{Method: http.MethodPost, Path: "/api/ai/process-audio", Handler: func(e *core.RequestEvent) error {
+			return ProcessAudioHandler(e, app)
+		}, BodyLimiter: limitAudioUpload(app), Middlewares: []routes.Middleware{sla.Track(sla.EndpointTranscription)}, RateLimited: true, Doc: &routes.Doc{Summary: "Transcribe audio uploaded as multipart form data", Response: whisper.Result{}}},
+
+		// Models the caller's plan can request in process-text
+		routes.Route{Method: http.MethodGet, Path: "/api/ai/models", Handler: func(e *core.RequestEvent) error {
+			return ModelsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List the models the caller's plan can use"}},
+
+		routes.Route{Method: http.MethodPost, Path: "/api/ai/prepare-context", Handler: func(e *core.RequestEvent) error {
+			return PrepareContextHandler(e, app)
+		}, RateLimited: true, Doc: &routes.Doc{Summary: "Pick the transcript segments that fit a token budget", Request: PrepareContextRequest{}, Response: PreparedContext{}}},
+
+		// Status polling for audio queued with async=true
+		routes.Route{Method: http.MethodGet, Path: "/api/ai/jobs", Handler: func(e *core.RequestEvent) error {
+			return ListTranscriptionJobsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List the caller's transcription jobs"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/ai/jobs/{id}", Handler: func(e *core.RequestEvent) error {
+			return GetTranscriptionJobHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Get a transcription job and its result", Response: TranscriptionJob{}}},
+
+		// Re-run, on a paid plan, of a file that reached its plan's processing attempt limit
+		routes.Route{Method: http.MethodPost, Path: "/api/ai/reprocess", Handler: func(e *core.RequestEvent) error {
+			return ReprocessHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Request another processing attempt at a file"}},
+
+		// Chunked uploads: open a session, PUT each chunk, then finalize to queue transcription
+		routes.Route{Method: http.MethodPost, Path: "/api/ai/audio-sessions", Handler: func(e *core.RequestEvent) error {
+			return CreateAudioSessionHandler(e, app)
+		}, RateLimited: true, Doc: &routes.Doc{Summary: "Open a chunked audio upload", Request: CreateAudioSessionRequest{}, Response: AudioSession{}, Status: http.StatusCreated}},
+		routes.Route{Method: http.MethodGet, Path: "/api/ai/audio-sessions/{id}", Handler: func(e *core.RequestEvent) error {
+			return GetAudioSessionHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Get a chunked audio upload", Response: AudioSession{}}},
+		routes.Route{Method: http.MethodPut, Path: "/api/ai/audio-sessions/{id}/chunks/{index}", Handler: func(e *core.RequestEvent) error {
+			return PutAudioChunkHandler(e, app)
+		}, BodyLimit: maxChunkBytes + 1<<20, Doc: &routes.Doc{Summary: "Upload one chunk of audio as the raw body"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/ai/audio-sessions/{id}/finalize", Handler: func(e *core.RequestEvent) error {
+			return FinalizeAudioSessionHandler(e, app)
+		}, Middlewares: []routes.Middleware{sla.Track(sla.EndpointTranscription)}, RateLimited: true, Doc: &routes.Doc{Summary: "Queue a completed upload for transcription", Response: AudioSession{}, Status: http.StatusAccepted}},
+
+		// Deprecated: progress of uploads chunked with the is_chunk form fields - use audio sessions
+		routes.Route{Method: http.MethodGet, Path: "/api/ai/upload-sessions/{base_filename}", Handler: func(e *core.RequestEvent) error {
+			return UploadSessionHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Get the progress of a form-chunked upload", Response: UploadSession{}}},
+
+		routes.Route{Method: http.MethodPost, Path: "/api/generate-api-key", Handler: func(e *core.RequestEvent) error {
+			return GenerateAPIKeyHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Generate an API key"}},
+
+		// Delegated tokens: a user session mints short-lived tokens for the desktop app
+		routes.Route{Method: http.MethodPost, Path: "/api/auth/delegated-tokens", Handler: func(e *core.RequestEvent) error {
+			return CreateDelegatedTokenHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Mint short-lived tokens for the desktop app", Response: DelegatedTokens{}}},
+		routes.Route{Method: http.MethodPost, Path: "/api/auth/delegated-tokens/refresh", Handler: func(e *core.RequestEvent) error {
+			return RefreshDelegatedTokenHandler(e, app)
+		}, RateLimited: true, Doc: &routes.Doc{Summary: "Refresh delegated tokens", Response: DelegatedTokens{}}},
+		routes.Route{Method: http.MethodGet, Path: "/api/auth/delegated-tokens", Handler: func(e *core.RequestEvent) error {
+			return ListDelegatedTokensHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List delegated tokens"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/auth/delegated-tokens/{id}/revoke", Handler: func(e *core.RequestEvent) error {
+			return RevokeDelegatedTokenHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Revoke a delegated token"}},
+
+		// Key management for the web and Wails apps - accepts a user session or an API key
+		routes.Route{Method: http.MethodGet, Path: "/api/api-keys", Handler: func(e *core.RequestEvent) error {
+			return ListAPIKeysHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List API keys"}},
+		routes.Route{Method: http.MethodPatch, Path: "/api/api-keys/{id}", Handler: func(e *core.RequestEvent) error {
+			return RenameAPIKeyHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Rename an API key"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/api-keys/{id}/revoke", Handler: func(e *core.RequestEvent) error {
+			return RevokeAPIKeyHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Revoke an API key"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/api-keys/{id}/rotate", Handler: func(e *core.RequestEvent) error {
+			return RotateAPIKeyHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Rotate an API key"}},
+		routes.Route{Method: http.MethodPut, Path: "/api/api-keys/{id}/restrictions", Handler: func(e *core.RequestEvent) error {
+			return SetAPIKeyRestrictionsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Restrict an API key to networks and countries"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/api-keys/{id}/activity", Handler: func(e *core.RequestEvent) error {
+			return APIKeyActivityHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List where an API key was used from"}},
+
+		// Sandbox tenant for integration partners - its API keys only reach fake providers
+		routes.Route{Method: http.MethodGet, Path: "/api/sandbox/tenant", Handler: func(e *core.RequestEvent) error {
+			return GetSandboxTenantHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Get the caller's sandbox tenant", Response: SandboxTenantResponse{}}},
+		routes.Route{Method: http.MethodPost, Path: "/api/sandbox/tenant", Handler: func(e *core.RequestEvent) error {
+			return CreateSandboxTenantHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Create a sandbox tenant", Response: SandboxTenantResponse{}}},
+
+		// Usage tracking routes for Wails app (requires API key)
+		routes.Route{Method: http.MethodGet, Path: "/api/usage/summary", Handler: func(e *core.RequestEvent) error {
+			return UsageSummaryHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Aggregated usage"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/usage/files", Handler: func(e *core.RequestEvent) error {
+			return UsageFilesHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List processed files"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/usage/stats", Handler: func(e *core.RequestEvent) error {
+			return UsageStatsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Current usage statistics"}},
+		routes.Route{Method: http.MethodDelete, Path: "/api/usage/files/{id}", Handler: func(e *core.RequestEvent) error {
+			return DeleteUsageFileHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Delete a processed file, restorable for a while"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/usage/files/{id}/restore", Handler: func(e *core.RequestEvent) error {
+			return RestoreUsageFileHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Restore a deleted processed file"}},
+		// Support can restore accidental deletions for any user during the restore window
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/usage/files/{id}/restore", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AdminRestoreUsageFileHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Restore any user's deleted processed file"}},
+
+		// Extra attempts at a file past its processing limit, e.g. after a provider problem
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/reprocess-grants", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AdminGrantReprocessHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Grant extra processing attempts at a file"}},
+
+		// Text request concurrency and queue depth
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/metrics/text-queue", Auth: routes.AuthSuperuser, Handler: TextQueueStatsHandler, Doc: &routes.Doc{Summary: "Text request concurrency and queue depth", Response: TextQueueStats{}}},
+
+		// Burst allowance consumption across accounts
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/metrics/usage-bursts", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return BurstStatsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Burst allowance consumption across accounts"}},
+	)
+}
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/whisper"
+)
+
+// sandboxModel is recorded as the model for everything the fake providers produce
+const sandboxModel = "sandbox"
+
+// sandboxSentences are cycled to build fake transcripts
+var sandboxSentences = []string{
+	"Welcome to the Ramble sandbox.",
+	"This transcript was generated without calling a speech provider.",
+	"Timestamps and word timings follow the same format as production responses.",
+	"Use it to build and test your integration end to end.",
+	"Sandbox requests never count towards billing or provider usage.",
+}
+
+// SandboxTenantResponse describes a partner's sandbox account
+type SandboxTenantResponse struct {
+	TenantID                string                 `json:"tenant_id"`
+	APIKey                  string                 `json:"api_key,omitempty"` // only returned when a key is issued
+	Limits                  accounts.SandboxLimits `json:"limits"`
+	TranscriptionsUsedToday int                    `json:"transcriptions_used_today"`
+}
+
+// GetSandboxTenantHandler returns the caller's sandbox account, if they have one
+// GET /api/sandbox/tenant
+func GetSandboxTenantHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil {
+		return e.JSON(401, map[string]string{"error": "Authentication required"})
+	}
+
+	tenant, err := accounts.FindSandboxTenant(app, e.Auth)
+	if err != nil {
+		return e.JSON(404, map[string]string{"error": "No sandbox tenant - create one first"})
+	}
+
+	return e.JSON(200, SandboxTenantResponse{
+		TenantID:                tenant.Id,
+		Limits:                  accounts.SandboxLimitsFromEnv(),
+		TranscriptionsUsedToday: sandboxTranscriptionsToday(app, tenant.Id),
+	})
+}
+
+// CreateSandboxTenantHandler creates the caller's sandbox account on first use and issues a fresh
+// sandbox API key, revoking earlier ones. Requests made with the key hit fake providers only
+// POST /api/sandbox/tenant
+func CreateSandboxTenantHandler(e *core.RequestEvent, app core.App) error {
+	clientIP := getClientIP(e)
+	if e.Auth == nil {
+		return e.JSON(401, map[string]string{"error": "Authentication required"})
+	}
+
+	tenant, created, err := accounts.EnsureSandboxTenant(app, e.Auth)
+	if err != nil {
+		if errors.Is(err, accounts.ErrSandboxOwner) {
+			return e.JSON(400, map[string]string{"error": err.Error()})
+		}
+		logging.FromRequest(e).Error("Cannot create sandbox tenant", "ip", clientIP, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to create sandbox tenant"})
+	}
+
+	// Only one sandbox key is live at a time, so a leaked key is revoked by requesting a new one
+	oldKeys, err := app.FindRecordsByFilter("api_keys", "user_id = {:user} && active = true", "", 0, 0,
+		map[string]any{"user": tenant.Id})
+	if err == nil {
+		for _, key := range oldKeys {
+			key.Set("active", false)
+			if err := app.Save(key); err != nil {
+				logging.FromRequest(e).Warn("Failed to revoke sandbox key", "key_id", key.Id, "error", err)
+			}
+		}
+	}
+
+	apiKey, _, err := createAPIKey(app, tenant.Id, fmt.Sprintf("Sandbox Key - %s", time.Now().Format("2006-01-02 15:04")), "", nil)
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to save API key"})
+	}
+
+	logging.FromRequest(e).Info("Sandbox key issued", "tenant_id", tenant.Id, "created", created,
+		"revoked", len(oldKeys), "ip", clientIP)
+
+	status := 200
+	if created {
+		status = 201
+	}
+	return e.JSON(status, SandboxTenantResponse{
+		TenantID:                tenant.Id,
+		APIKey:                  apiKey,
+		Limits:                  accounts.SandboxLimitsFromEnv(),
+		TranscriptionsUsedToday: sandboxTranscriptionsToday(app, tenant.Id),
+	})
+}
+
+// processSandboxAudio answers a sandbox transcription with a fake transcript sized to the upload
+// Files are recorded for the sandbox tenant, but monthly usage is left untouched
+func processSandboxAudio(e *core.RequestEvent, app core.App, user *core.Record, clientIP string, startTime time.Time) error {
+	limits := accounts.SandboxLimitsFromEnv()
+	logger := logging.FromRequest(e).With("endpoint", "sandbox_audio", "tenant_id", user.Id, "ip", clientIP)
+
+	if used := sandboxTranscriptionsToday(app, user.Id); used >= limits.DailyTranscriptions {
+		logger.Warn("Sandbox request rejected", "reason", "daily_cap", "used", used)
+		return e.JSON(429, map[string]string{"error": fmt.Sprintf("Sandbox is limited to %d transcriptions per day", limits.DailyTranscriptions), "code": "SANDBOX_LIMIT_EXCEEDED"})
+	}
+
+	if err := e.Request.ParseMultipartForm(audioUploadMemoryLimit()); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid multipart form data"})
+	}
+	file, header, err := e.Request.FormFile("audio")
+	if err != nil {
+		return e.JSON(400, map[string]string{"error": "Audio file is required"})
+	}
+	defer file.Close()
+
+	if header.Size > limits.MaxAudioBytes {
+		return e.JSON(413, map[string]string{"error": fmt.Sprintf("Sandbox uploads are limited to %d MB", limits.MaxAudioBytes>>20), "code": "SANDBOX_LIMIT_EXCEEDED"})
+	}
+
+	durationSeconds, _, err := probeAudioDuration(file, header.Size)
+	if err != nil {
+		return unsupportedAudioResponse(e, err)
+	}
+
+	result := fakeTranscription(durationSeconds)
+	elapsed := time.Since(startTime)
+
+	record, err := createProcessedFileRecordWithChunkInfo(app, user.Id, header.Filename, header.Size, clientIP,
+		header.Filename, false, false, 0, 0, 0)
+	if err == nil {
+		record.Set("model_used", sandboxModel)
+		updateProcessedFileRecord(app, record, "completed", result.Duration, len(result.Transcript), len(result.Words), elapsed.Milliseconds())
+	}
+
+	logger.Info("Sandbox audio processed", "filename", header.Filename, "fake_duration_s", result.Duration)
+
+	e.Response.Header().Set("X-Sandbox", "true")
+	return e.JSON(200, result)
+}
+
+// processSandboxText answers a sandbox text request with a canned completion
+func processSandboxText(e *core.RequestEvent, user *core.Record, clientIP string) error {
+	var request TextProcessingRequest
+	if err := e.BindBody(&request); err != nil {
+		return e.JSON(400, map[string]string{"error": "Invalid request format"})
+	}
+	if request.UserPrompt == "" {
+		return e.JSON(400, map[string]string{"error": "user_prompt is required"})
+	}
+
+	logging.FromRequest(e).Info("Sandbox text processed", "endpoint", "sandbox_text", "tenant_id", user.Id,
+		"task", request.TaskType, "prompt_chars", len(request.UserPrompt), "ip", clientIP)
+
+	e.Response.Header().Set("X-Sandbox", "true")
+	completion := fakeCompletion(request)
+	if request.Stream {
+		setSSEHeaders(e)
+		for _, word := range strings.SplitAfter(completion.Choices[0].Message.Content, " ") {
+			chunk, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"delta": map[string]string{"content": word}}}})
+			fmt.Fprintf(e.Response, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(e.Response, "data: [DONE]\n\n")
+		return e.Flush()
+	}
+	return e.JSON(200, completion)
+}
+
+// fakeTranscription builds a deterministic transcript with segment and word timings covering the duration
+func fakeTranscription(durationSeconds float64) *whisper.Result {
+	durationSeconds = math.Max(durationSeconds, 1)
+	const secondsPerWord = 0.4
+
+	result := &whisper.Result{Duration: durationSeconds, Language: "english"}
+	var transcript []string
+	clock := 0.0
+	for i := 0; clock < durationSeconds; i++ {
+		sentence := sandboxSentences[i%len(sandboxSentences)]
+		segment := whisper.Segment{ID: i, Start: clock, Text: " " + sentence}
+		for _, word := range strings.Fields(sentence) {
+			if clock >= durationSeconds {
+				break
+			}
+			end := math.Min(clock+secondsPerWord, durationSeconds)
+			segment.Words = append(segment.Words, whisper.Word{Word: word, Start: round2(clock), End: round2(end)})
+			clock = end
+		}
+		segment.Start = round2(segment.Start)
+		segment.End = round2(clock)
+		result.Words = append(result.Words, segment.Words...)
+		result.Segments = append(result.Segments, segment)
+		transcript = append(transcript, sentence)
+	}
+	result.Transcript = strings.Join(transcript, " ")
+	return result
+}
+
+// fakeCompletion returns an OpenRouter-shaped response without calling a model
+func fakeCompletion(request TextProcessingRequest) *OpenRouterResponse {
+	task := request.TaskType
+	if task == "" {
+		task = "text"
+	}
+	content := fmt.Sprintf("[sandbox] Simulated %s response for a %d character prompt.", task, len(request.UserPrompt))
+	return &OpenRouterResponse{
+		Choices: []Choice{{Message: Message{Role: "assistant", Content: content}}},
+	}
+}
+
+// sandboxTranscriptionsToday counts the tenant's transcriptions since midnight UTC
+func sandboxTranscriptionsToday(app core.App, userID string) int {
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	records, err := app.FindRecordsByFilter("processed_files", "user_id = {:user} && created >= {:since}", "", 0, 0,
+		map[string]any{"user": userID, "since": midnight.Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		return 0
+	}
+	return len(records)
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,77 @@
+//go:build integration
+
+package ai_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/ai"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+func TestUsersCannotMakeThemselvesSandboxTenants(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "sneaky@test.com")
+	server := testharness.Serve(t, app)
+
+	// Hidden fields are dropped from non-superuser bodies, so the update succeeds without them
+	for _, body := range []map[string]any{{"sandbox": true}, {"sandbox_owner": user.Id}} {
+		server.Do(t, http.MethodPatch, "/api/collections/users/records/"+user.Id, testharness.AuthToken(t, user), body)
+	}
+
+	reloaded, err := app.FindRecordById("users", user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accounts.IsSandbox(reloaded) || reloaded.GetBool("sandbox") {
+		t.Error("user turned themselves into a sandbox tenant")
+	}
+
+	// The server-side flag alone doesn't make a tenant either
+	reloaded.Set("sandbox", true)
+	if accounts.IsSandbox(reloaded) {
+		t.Error("sandbox without sandbox_owner reported as a tenant")
+	}
+
+	// Regular profile edits still go through
+	server.Do(t, http.MethodPatch, "/api/collections/users/records/"+user.Id, testharness.AuthToken(t, user),
+		map[string]any{"name": "Renamed"}).Expect(t, http.StatusOK)
+}
+
+func TestSandboxTenant(t *testing.T) {
+	app := testharness.New(t)
+	owner := testharness.CreateUser(t, app, "partner@test.com")
+
+	tenant, created, err := accounts.EnsureSandboxTenant(app, owner)
+	if err != nil || !created {
+		t.Fatalf("EnsureSandboxTenant = %v, %v", created, err)
+	}
+	if !accounts.IsSandbox(tenant) {
+		t.Error("tenant not reported as a sandbox")
+	}
+	if _, _, err := accounts.EnsureSandboxTenant(app, tenant); err != accounts.ErrSandboxOwner {
+		t.Errorf("tenant creating a sandbox: err = %v, want ErrSandboxOwner", err)
+	}
+}
+
+func TestSandboxPlanDoesNotReachRealProviders(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	user := testharness.CreateUser(t, app, "freeloader@test.com")
+	key := testharness.CreateAPIKey(t, app, user)
+
+	service := subscription.NewService(subscription.NewRepository(app))
+	if _, err := service.AssignPlan(user.Id, plans["pro"].Id, time.Now().Add(30*24*time.Hour), "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+
+	server := testharness.Serve(t, app, ai.RegisterRoutes)
+	server.Do(t, http.MethodPost, "/api/ai/process-text", key, map[string]any{
+		"user_prompt": "hello",
+		"task_type":   "chat",
+	}).Expect(t, http.StatusForbidden)
+}
@@ -0,0 +1,43 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFakeTranscriptionCoversDuration(t *testing.T) {
+	result := fakeTranscription(30)
+
+	if result.Duration != 30 || len(result.Segments) == 0 || len(result.Words) == 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	last := result.Words[len(result.Words)-1]
+	if last.End != 30 {
+		t.Fatalf("last word ends at %v, want 30", last.End)
+	}
+	for i := 1; i < len(result.Words); i++ {
+		if result.Words[i].Start < result.Words[i-1].End {
+			t.Fatalf("word %d overlaps the previous one", i)
+		}
+	}
+	if !strings.HasPrefix(result.Transcript, sandboxSentences[0]) {
+		t.Fatalf("unexpected transcript %q", result.Transcript)
+	}
+}
+
+func TestFakeTranscriptionIsDeterministic(t *testing.T) {
+	if fakeTranscription(12.5).Transcript != fakeTranscription(12.5).Transcript {
+		t.Fatal("expected the same transcript for the same duration")
+	}
+	if short := fakeTranscription(0); short.Duration != 1 || len(short.Words) == 0 {
+		t.Fatalf("expected at least one second of transcript, got %+v", short)
+	}
+}
+
+func TestFakeCompletion(t *testing.T) {
+	response := fakeCompletion(TextProcessingRequest{TaskType: "chat", UserPrompt: "hello"})
+	if len(response.Choices) != 1 || !strings.Contains(response.Choices[0].Message.Content, "chat") {
+		t.Fatalf("unexpected completion %+v", response)
+	}
+}
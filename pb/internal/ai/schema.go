@@ -0,0 +1,211 @@
+package ai
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the AI handlers read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "ai",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "api_keys",
+				Fields: []schemacheck.Field{
+					{Name: "key_hash", Type: "text"},
+					{Name: "user_id", Type: "relation"},
+					{Name: "active", Type: "bool"},
+					{Name: "name", Type: "text"},
+					{Name: "signing_secret", Type: "text"},
+					{Name: "key_prefix", Type: "text"},
+					{Name: "last_used_at", Type: "date"},
+					{Name: "organization_id", Type: "relation"},
+					{Name: "scopes", Type: "select"},
+				},
+			},
+			{
+				Collection: "delegated_tokens",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "name", Type: "text"},
+					{Name: "scopes", Type: "select"},
+					{Name: "token_key", Type: "text"},
+					{Name: "refresh_hash", Type: "text"},
+					{Name: "expires_at", Type: "date"},
+					{Name: "last_refreshed_at", Type: "date"},
+					{Name: "last_used_at", Type: "date"},
+					{Name: "revoked", Type: "bool"},
+				},
+			},
+			{
+				Collection: "processed_files",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "filename", Type: "text"},
+					{Name: "file_size_bytes", Type: "number"},
+					{Name: "duration_seconds", Type: "number"},
+					{Name: "processing_time_ms", Type: "number"},
+					{Name: "status", Type: "select", Values: []string{"processing", "completed", "failed"}},
+					{Name: "transcript_length", Type: "number"},
+					{Name: "words_count", Type: "number"},
+					{Name: "model_used", Type: "text"},
+					{Name: "client_ip", Type: "text"},
+					{Name: "base_filename", Type: "text"},
+					{Name: "is_chunk", Type: "bool"},
+					{Name: "is_last_chunk", Type: "bool"},
+					{Name: "chunk_index", Type: "number"},
+					{Name: "original_file_size_bytes", Type: "number"},
+					{Name: "original_duration_seconds", Type: "number"},
+					{Name: "processing_count", Type: "number"},
+					{Name: "content_hash", Type: "text"},
+					{Name: "transcription_result", Type: "json"},
+					{Name: "transcription_options", Type: "json"},
+					{Name: "deleted_at", Type: "date"},
+					{Name: "failure_reason", Type: "text"},
+					{Name: "retriable", Type: "bool"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_processed_files_user_id", Columns: "user_id"},
+					{Name: "idx_processed_files_content_hash", Columns: "user_id, content_hash"},
+				},
+			},
+			{
+				Collection: "reprocess_grants",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "content_hash", Type: "text"},
+					{Name: "attempts", Type: "number"},
+					{Name: "source", Type: "select", Values: []string{"admin", "rerun"}},
+					{Name: "granted_by", Type: "text"},
+					{Name: "reason", Type: "text"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_reprocess_grants_user_hash", Columns: "user_id, content_hash"},
+				},
+			},
+			{
+				Collection: "transcription_jobs",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "status", Type: "select", Values: []string{"queued", "processing", "completed", "failed"}},
+					{Name: "audio", Type: "file"},
+					{Name: "filename", Type: "text"},
+					{Name: "file_size_bytes", Type: "number"},
+					{Name: "base_filename", Type: "text"},
+					{Name: "is_chunk", Type: "bool"},
+					{Name: "is_last_chunk", Type: "bool"},
+					{Name: "chunk_index", Type: "number"},
+					{Name: "chunk_start_seconds", Type: "number"},
+					{Name: "original_file_size_bytes", Type: "number"},
+					{Name: "original_duration_seconds", Type: "number"},
+					{Name: "client_ip", Type: "text"},
+					{Name: "processed_file_id", Type: "text"},
+					{Name: "session_id", Type: "text"},
+					{Name: "provider", Type: "text"},
+					{Name: "transcription_options", Type: "json"},
+					{Name: "organization_id", Type: "text"},
+					{Name: "request_id", Type: "text"},
+					{Name: "attempts", Type: "number"},
+					{Name: "result", Type: "json"},
+					{Name: "error", Type: "text"},
+					{Name: "started_at", Type: "date"},
+					{Name: "completed_at", Type: "date"},
+				},
+			},
+			{
+				Collection: "audio_upload_sessions",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "filename", Type: "text"},
+					{Name: "expected_chunks", Type: "number"},
+					{Name: "checksum", Type: "text"},
+					{Name: "original_file_size_bytes", Type: "number"},
+					{Name: "original_duration_seconds", Type: "number"},
+					{Name: "status", Type: "select", Values: []string{"open", "transcribing", "completed", "failed", "expired"}},
+					{Name: "job_id", Type: "text"},
+					{Name: "processed_file_id", Type: "text"},
+					{Name: "client_ip", Type: "text"},
+					{Name: "error", Type: "text"},
+					{Name: "expires_at", Type: "date"},
+				},
+			},
+			{
+				Collection: "audio_upload_chunks",
+				Fields: []schemacheck.Field{
+					{Name: "session_id", Type: "relation"},
+					{Name: "chunk_index", Type: "number"},
+					{Name: "audio", Type: "file"},
+					{Name: "size_bytes", Type: "number"},
+					{Name: "duration_seconds", Type: "number"},
+					{Name: "start_seconds", Type: "number"},
+					{Name: "sha256", Type: "text"},
+					{Name: "status", Type: "select", Values: []string{"received", "transcribed", "failed"}},
+					{Name: "result", Type: "json"},
+					{Name: "error", Type: "text"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_audio_upload_chunks_session_index", Unique: true, Columns: "session_id, chunk_index"},
+				},
+			},
+			{
+				Collection: "file_uploads",
+				Fields: []schemacheck.Field{
+					{Name: "user", Type: "relation"},
+					{Name: "transcript", Type: "text"},
+					{Name: "transcription_result", Type: "json"},
+				},
+			},
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "rate_limit_per_minute", Type: "number"},
+					{Name: "rate_limit_burst", Type: "number"},
+					{Name: "burst_percent", Type: "number"},
+					{Name: "bursts_per_year", Type: "number"},
+					{Name: "allowed_models", Type: "json"},
+					{Name: "tokens_per_month", Type: "number"},
+					{Name: "grace_period_seconds", Type: "number"},
+					{Name: "max_upload_mb", Type: "number"},
+					{Name: "max_processing_attempts", Type: "number"},
+					{Name: "speaker_diarization", Type: "bool"},
+				},
+			},
+			{
+				Collection: "monthly_usage",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "year_month", Type: "text"},
+					{Name: "hours_used", Type: "number"},
+					{Name: "files_processed", Type: "number"},
+					{Name: "last_processing_date", Type: "date"},
+					{Name: "burst_used", Type: "bool"},
+					{Name: "burst_hours", Type: "number"},
+					{Name: "organization_id", Type: "relation"},
+					{Name: "tokens_used", Type: "number"},
+					{Name: "period_start", Type: "date"},
+					{Name: "period_end", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_monthly_usage_period", Columns: "user_id, period_start"},
+				},
+			},
+			{
+				Collection: "ai_usage_logs",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "task_type", Type: "text"},
+					{Name: "model", Type: "text"},
+					{Name: "prompt_tokens", Type: "number"},
+					{Name: "completion_tokens", Type: "number"},
+					{Name: "tokens_used", Type: "number"},
+					{Name: "tokens_estimated", Type: "bool"},
+					{Name: "input_size", Type: "number"},
+					{Name: "output_size", Type: "number"},
+					{Name: "duration_ms", Type: "number"},
+					{Name: "client_ip", Type: "text"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_ai_usage_logs_user_id", Columns: "user_id"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// API key scopes, picked when a key is generated. Keys without scopes (every key generated
+// before scopes existed, or without asking for any) have them all
+const (
+	ScopeAIText         = "ai:text"         // process-text, prepare-context and the model list
+	ScopeAIAudio        = "ai:audio"        // process-audio, transcription jobs, audio sessions and TUS uploads
+	ScopeUsageRead      = "usage:read"      // usage summary, files and stats
+	ScopeUsageWrite     = "usage:write"     // deleting and restoring processed files
+	ScopeKeysManage     = "keys:manage"     // listing, renaming, revoking and rotating keys with an API key
+	ScopeWebhooksManage = "webhooks:manage" // webhook endpoints
+)
+
+// AllScopes lists every scope, in the order they're reported
+var AllScopes = []string{ScopeAIText, ScopeAIAudio, ScopeUsageRead, ScopeUsageWrite, ScopeKeysManage, ScopeWebhooksManage}
+
+// scopeError means the authenticating key lacks the scope a route needs
+type scopeError struct {
+	scope string
+}
+
+func (e *scopeError) Error() string {
+	return fmt.Sprintf("API key lacks the %s scope", e.scope)
+}
+
+// normalizeScopes checks requested scopes are known and orders them like AllScopes, dropping
+// duplicates. No scopes, or all of them, is stored as none: a full access key
+func normalizeScopes(requested []string) ([]string, error) {
+	wanted := make(map[string]bool, len(requested))
+	for _, scope := range requested {
+		wanted[scope] = true
+	}
+
+	var scopes []string
+	for _, scope := range AllScopes {
+		if wanted[scope] {
+			scopes = append(scopes, scope)
+			delete(wanted, scope)
+		}
+	}
+	for scope := range wanted {
+		return nil, fmt.Errorf("unknown scope %q (valid scopes: %v)", scope, AllScopes)
+	}
+	if len(scopes) == len(AllScopes) {
+		return nil, nil
+	}
+	return scopes, nil
+}
+
+// keyScopes is what a key may do
+func keyScopes(key *core.Record) []string {
+	if scopes := key.GetStringSlice("scopes"); len(scopes) > 0 {
+		return scopes
+	}
+	return AllScopes
+}
+
+// checkScope returns a *scopeError unless key has scope
+func checkScope(key *core.Record, scope string) error {
+	for _, granted := range keyScopes(key) {
+		if granted == scope {
+			return nil
+		}
+	}
+	return &scopeError{scope: scope}
+}
+
+// checkScopes returns a *scopeError for the first of scopes key lacks
+func checkScopes(key *core.Record, scopes []string) error {
+	for _, scope := range scopes {
+		if err := checkScope(key, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeDeniedResponse refuses a request whose key lacks the scope err names. handled is false
+// when err isn't a *scopeError
+func scopeDeniedResponse(e *core.RequestEvent, err error) (bool, error) {
+	var denied *scopeError
+	if !errors.As(err, &denied) {
+		return false, nil
+	}
+	logging.FromRequest(e).Warn("Request rejected", "reason", "insufficient_scope", "scope", denied.scope)
+	return true, e.JSON(403, map[string]string{
+		"error":          fmt.Sprintf("This API key doesn't have the %s scope", denied.scope),
+		"code":           "INSUFFICIENT_SCOPE",
+		"required_scope": denied.scope,
+	})
+}
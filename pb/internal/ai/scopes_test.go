@@ -0,0 +1,51 @@
+package ai
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	tests := []struct {
+		requested []string
+		want      []string
+	}{
+		{nil, nil},
+		{[]string{ScopeUsageRead, ScopeAIText, ScopeUsageRead}, []string{ScopeAIText, ScopeUsageRead}},
+		{AllScopes, nil},
+	}
+	for _, tt := range tests {
+		got, err := normalizeScopes(tt.requested)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("normalizeScopes(%v) = %v, %v; want %v", tt.requested, got, err, tt.want)
+		}
+	}
+
+	if _, err := normalizeScopes([]string{ScopeAIText, "admin"}); err == nil {
+		t.Error("Expected an unknown scope to be rejected")
+	}
+}
+
+func TestCheckScope(t *testing.T) {
+	collection := core.NewBaseCollection("api_keys")
+	collection.Fields.Add(&core.SelectField{Name: "scopes", MaxSelect: len(AllScopes), Values: AllScopes})
+
+	key := core.NewRecord(collection)
+	for _, scope := range AllScopes {
+		if err := checkScope(key, scope); err != nil {
+			t.Errorf("Expected a key without scopes to have %s, got %v", scope, err)
+		}
+	}
+
+	key.Set("scopes", []string{ScopeAIAudio, ScopeUsageRead})
+	if err := checkScope(key, ScopeAIAudio); err != nil {
+		t.Errorf("Expected %s to be granted, got %v", ScopeAIAudio, err)
+	}
+	var denied *scopeError
+	if err := checkScope(key, ScopeAIText); !errors.As(err, &denied) || denied.scope != ScopeAIText {
+		t.Errorf("Expected %s to be denied, got %v", ScopeAIText, err)
+	}
+}
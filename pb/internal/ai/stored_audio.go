@@ -0,0 +1,94 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/progress"
+	"pocketbase/internal/whisper"
+)
+
+// ErrUsageLimitExceeded is returned by TranscribeStoredAudio when the audio would take the
+// user past their monthly limit, grace period and burst included
+var ErrUsageLimitExceeded = errors.New("usage limit exceeded")
+
+// StoredAudio is audio that reached the server outside /api/ai, such as a resumable TUS upload
+type StoredAudio struct {
+	Reader    io.ReadSeeker
+	Filename  string
+	SizeBytes int64
+	ClientIP  string
+	// OrganizationID is the pool named by the API key that uploaded the audio, if any
+	OrganizationID string
+	// Progress, if set, follows the audio being sent to the provider
+	Progress *progress.Tracker
+}
+
+// TranscribeStoredAudio runs stored audio through the same pipeline as /api/ai/process-audio:
+// the format is probed (audioprobe.ErrUnsupportedFormat) and usage limits are checked before
+// the provider is called, and the processed_files record, monthly usage and AI usage log are
+// written once it succeeds
+func TranscribeStoredAudio(ctx context.Context, app core.App, user *core.Record, audio StoredAudio) (*whisper.Result, error) {
+	startTime := time.Now()
+	logger := logging.FromContext(ctx).With("user_id", user.Id, "filename", audio.Filename)
+
+	if accounts.IsSandbox(user) {
+		return nil, fmt.Errorf("sandbox accounts can't transcribe stored audio")
+	}
+
+	account := resolveUsageAccount(app, user.Id, audio.OrganizationID)
+	durationSeconds, _, err := probeAudioDuration(audio.Reader, audio.SizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	contentHash, err := hashAudio(audio.Reader)
+	if err != nil {
+		logger.Warn("Failed to hash audio, skipping the processing attempt limit", "error", err)
+	} else {
+		plan, _ := userPlanLimit(app, user.Id)
+		if usage, err := processingAttemptUsage(app, user.Id, contentHash, plan); err == nil && usage.exhausted() {
+			logger.Warn("Stored audio rejected", "reason", "processing_limit_reached", "attempts", usage.Used, "limit", usage.Limit)
+			return nil, fmt.Errorf("%w: transcribed %d of %d times", ErrProcessingLimitReached, usage.Used, usage.Limit)
+		}
+	}
+	if err := validateUsageLimits(app, logger, account, durationSeconds/3600.0); err != nil {
+		logger.Warn("Stored audio rejected", "reason", "usage_limit_exceeded", "duration_seconds", durationSeconds, "error", err)
+		return nil, fmt.Errorf("%w: %v", ErrUsageLimitExceeded, err)
+	}
+
+	processedFileRecord, err := createProcessedFileRecordWithChunkInfo(app, user.Id, audio.Filename, audio.SizeBytes, audio.ClientIP,
+		audio.Filename, false, false, 0, 0, 0)
+	if err != nil {
+		logger.Warn("Failed to create processed_files record", "error", err)
+	} else if contentHash != "" {
+		processedFileRecord.Set("content_hash", contentHash)
+		if err := app.Save(processedFileRecord); err != nil {
+			logger.Warn("Failed to store content hash", "error", err)
+		}
+	}
+
+	prepared := prepareAudio(ctx, audio.Reader, audio.SizeBytes, audio.Filename)
+	defer prepared.Close()
+	result, err := prepared.transcribe(ctx, audio.Progress)
+	if err != nil {
+		if processedFileRecord != nil {
+			updateProcessedFileRecord(app, processedFileRecord, "failed", 0, 0, 0, time.Since(startTime).Milliseconds())
+		}
+		logger.Error("Stored audio transcription failed", "error", err)
+		return nil, err
+	}
+
+	upload := audioUpload{Filename: audio.Filename, SizeBytes: audio.SizeBytes, BaseFilename: audio.Filename, OrganizationID: account.OrganizationID}
+	completeAudioProcessing(app, logger, processedFileRecord, user.Id, audio.ClientIP, upload, result, time.Since(startTime))
+	if _, _, err := moderateTranscript(ctx, app, logger, user.Id, processedFileRecord, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/gateway"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/metrics"
+)
+
+// streamHeaderTimeout bounds how long a provider may take to start streaming
+// There's no overall timeout - long completions are the reason to stream in the first place
+const streamHeaderTimeout = 30 * time.Second
+
+var streamClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: streamHeaderTimeout,
+	},
+}
+
+// openChatStream starts a streamed chat completion and returns the provider's SSE body
+func openChatStream(ctx context.Context, provider, endpoint string, auth gateway.Auth, model string, request *TextProcessingRequest) (io.ReadCloser, error) {
+	jsonData, err := json.Marshal(OpenRouterRequest{
+		Model:         model,
+		Messages:      chatMessages(request),
+		Stream:        true,
+		StreamOptions: &StreamOptions{IncludeUsage: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	auth.Apply(req.Header)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &circuit.StatusError{Provider: provider, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp.Body, nil
+}
+
+// streamText opens a streamed completion with the same breaker and fallback rules as completeText
+func streamText(ctx context.Context, request *TextProcessingRequest) (io.ReadCloser, error) {
+	if g, model, ok := gateway.Route(request.Model); ok {
+		breaker := circuit.For(gatewayBreakerName(g))
+		if !breaker.Allow() {
+			return nil, &circuit.OutageError{Provider: g.Name, RetryAfter: breaker.RetryAfter()}
+		}
+		start := time.Now()
+		body, err := openChatStream(ctx, g.Name, g.URL("/chat/completions"), g.Auth, model, request)
+		if !errors.Is(err, context.Canceled) {
+			metrics.ObserveUpstream(gatewayBreakerName(g), start, err)
+		}
+		breaker.Record(err)
+		return body, err
+	}
+
+	openRouter := gateway.OpenRouterFromEnv()
+	if openRouter.APIKey == "" {
+		return nil, fmt.Errorf("OpenRouter API key not configured")
+	}
+
+	breaker := circuit.For(TextBreakerName)
+	if breaker.Allow() {
+		start := time.Now()
+		body, err := openChatStream(ctx, "OpenRouter", openRouter.URL("/chat/completions"), openRouter.Auth, request.Model, request)
+		// A client hanging up says nothing about OpenRouter's health - Record releases the
+		// breaker without counting it, so a cancelled half-open probe doesn't hold it open
+		if !errors.Is(err, context.Canceled) {
+			metrics.ObserveUpstream(TextBreakerName, start, err)
+		}
+		breaker.Record(err)
+		return body, err
+	}
+
+	if TextFallbackConfigured() {
+		logging.FromContext(ctx).Warn("OpenRouter circuit open, streaming from fallback provider", "task", request.TaskType)
+		start := time.Now()
+		body, err := openChatStream(ctx, "Fallback", os.Getenv("TEXT_FALLBACK_URL"), textFallbackAuth(), os.Getenv("TEXT_FALLBACK_MODEL"), request)
+		if !errors.Is(err, context.Canceled) {
+			metrics.ObserveUpstream(textFallbackProvider, start, err)
+		}
+		return body, err
+	}
+
+	return nil, &circuit.OutageError{Provider: TextBreakerName, RetryAfter: breaker.RetryAfter()}
+}
+
+// streamTextResponse proxies a streamed completion to the client as Server-Sent Events
+// Events are the provider's OpenAI-style chunks ("data: {...}" ending with "data: [DONE]"); a failure
+// after streaming started is reported as an "event: error" since the status code is already sent
+func streamTextResponse(e *core.RequestEvent, app core.App, logger *slog.Logger, request *TextProcessingRequest, userID, clientIP string, startTime time.Time) error {
+	upstream, err := streamText(e.Request.Context(), request)
+	if err != nil {
+		logger.Error("Text stream failed", "reason", "provider_error", "error", err)
+		var outage *circuit.OutageError
+		if errors.As(err, &outage) {
+			return providerOutageResponse(e, outage)
+		}
+		return e.JSON(500, map[string]string{"error": fmt.Sprintf("AI processing failed: %v", err)})
+	}
+	defer upstream.Close()
+
+	setSSEHeaders(e)
+	responseLength, usage, err := relayChatStream(e.Response, func() { e.Flush() }, upstream)
+	elapsed := time.Since(startTime)
+	if err != nil {
+		writeSSEError(e.Response, err)
+		e.Flush()
+		logger.Error("Text stream interrupted", "sent_chars", responseLength, "duration_ms", elapsed.Milliseconds(), "error", err)
+		return nil
+	}
+
+	recordTextUsage(app, logger, userID, request, textTokenUsage(usage, request, responseLength), responseLength, elapsed, clientIP)
+	return nil
+}
+
+func setSSEHeaders(e *core.RequestEvent) {
+	header := e.Response.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	e.Response.WriteHeader(http.StatusOK)
+}
+
+// streamChunk is the part of an OpenAI-style stream chunk we inspect
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *TokenUsage `json:"usage,omitempty"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// relayChatStream forwards data events from upstream to w, flushing after each one, and returns
+// how many content characters were relayed and the usage the provider reported, if any
+// Provider comments (keep-alives) are dropped
+func relayChatStream(w io.Writer, flush func(), upstream io.Reader) (int, *TokenUsage, error) {
+	scanner := bufio.NewScanner(upstream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	contentLength := 0
+	var usage *TokenUsage
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		if data != "[DONE]" {
+			var chunk streamChunk
+			if err := json.Unmarshal([]byte(data), &chunk); err == nil {
+				if chunk.Error != nil {
+					return contentLength, usage, fmt.Errorf("provider error: %s", chunk.Error.Message)
+				}
+				if chunk.Usage != nil {
+					usage = chunk.Usage
+				}
+				for _, choice := range chunk.Choices {
+					contentLength += len(choice.Delta.Content)
+				}
+			}
+		}
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return contentLength, usage, err
+		}
+		flush()
+
+		if data == "[DONE]" {
+			return contentLength, usage, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return contentLength, usage, err
+	}
+	return contentLength, usage, fmt.Errorf("stream ended before completion")
+}
+
+func writeSSEError(w io.Writer, err error) {
+	payload, _ := json.Marshal(map[string]string{"error": err.Error()})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", payload)
+}
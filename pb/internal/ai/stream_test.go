@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRelayChatStream(t *testing.T) {
+	upstream := strings.Join([]string{
+		": OPENROUTER PROCESSING",
+		"",
+		`data: {"choices":[{"delta":{"role":"assistant","content":"Hel"}}]}`,
+		"",
+		`data: {"choices":[{"delta":{"content":"lo"}}]}`,
+		"",
+		`data: {"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":2,"total_tokens":14}}`,
+		"",
+		"data: [DONE]",
+		"",
+	}, "\n")
+
+	var out bytes.Buffer
+	flushes := 0
+	length, usage, err := relayChatStream(&out, func() { flushes++ }, strings.NewReader(upstream))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if length != 5 {
+		t.Fatalf("relayed %d content chars, want 5", length)
+	}
+	if usage == nil || usage.TotalTokens != 14 || usage.PromptTokens != 12 {
+		t.Fatalf("usage = %+v, want the final chunk's usage", usage)
+	}
+	if flushes != 4 {
+		t.Fatalf("flushed %d times, want once per event", flushes)
+	}
+	if strings.Contains(out.String(), "OPENROUTER PROCESSING") {
+		t.Fatal("provider comments should not be forwarded")
+	}
+	if !strings.HasSuffix(out.String(), "data: [DONE]\n\n") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
+
+func TestRelayChatStreamErrors(t *testing.T) {
+	var out bytes.Buffer
+	if _, _, err := relayChatStream(&out, func() {}, strings.NewReader(`data: {"choices":[{"delta":{"content":"Hi"}}]}`+"\n")); err == nil {
+		t.Fatal("expected an error for a stream without [DONE]")
+	}
+
+	providerError := `data: {"error":{"message":"rate limited"}}` + "\n"
+	if _, _, err := relayChatStream(&out, func() {}, strings.NewReader(providerError)); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("expected provider error, got %v", err)
+	}
+}
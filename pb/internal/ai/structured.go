@@ -0,0 +1,238 @@
+package ai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// structuredSchema is the reply shape of a task whose output the desktop app parses as JSON
+type structuredSchema struct {
+	// Describe is the shape in words, quoted when the model is asked to correct a reply
+	Describe string
+	// Validate checks a decoded reply and returns it normalized for TextProcessingResult.Structured
+	Validate func(value any) (any, error)
+}
+
+// structuredTasks are the task types whose replies are validated. Other tasks are passed through
+var structuredTasks = map[string]structuredSchema{
+	"suggest_highlights": {
+		Describe: `a JSON array of highlights, each an object with numeric "start" and "end" (end after start); extra fields such as "text" or "reason" are allowed`,
+		Validate: validateHighlights,
+	},
+	"reorder": {
+		Describe: `a JSON array of the item IDs, as strings or numbers, in the new order`,
+		Validate: validateReorder,
+	},
+}
+
+// trailingCommaPattern matches the trailing commas models leave before a closing bracket
+var trailingCommaPattern = regexp.MustCompile(`,\s*([\]}])`)
+
+// structuredRetries is how many times a malformed reply is sent back to the model with a
+// corrective prompt (TEXT_STRUCTURED_RETRIES, default 1; 0 only validates)
+func structuredRetries() int {
+	if v, err := strconv.Atoi(os.Getenv("TEXT_STRUCTURED_RETRIES")); err == nil && v >= 0 {
+		return v
+	}
+	return 1
+}
+
+// parse finds the JSON in a reply and validates it. repaired is the JSON text the reply should
+// carry instead, or "" when the reply was already clean JSON
+func (s structuredSchema) parse(content string) (structured any, repaired string, err error) {
+	text := extractJSON(content)
+	if text == "" {
+		return nil, "", errors.New("reply contains no JSON")
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		text = trailingCommaPattern.ReplaceAllString(text, "$1")
+		if err := json.Unmarshal([]byte(text), &value); err != nil {
+			return nil, "", fmt.Errorf("reply is not valid JSON: %w", err)
+		}
+	}
+	structured, err = s.Validate(unwrapList(value))
+	if err != nil {
+		return nil, "", err
+	}
+	if text == strings.TrimSpace(content) {
+		text = ""
+	}
+	return structured, text, nil
+}
+
+// extractJSON returns the outermost JSON array or object in a reply, without the markdown
+// fences or prose models wrap it in
+func extractJSON(content string) string {
+	start := strings.IndexAny(content, "[{")
+	if start < 0 {
+		return ""
+	}
+	closer := "]"
+	if content[start] == '{' {
+		closer = "}"
+	}
+	end := strings.LastIndex(content, closer)
+	if end < start {
+		return ""
+	}
+	return content[start : end+1]
+}
+
+// unwrapList returns the list inside an object with a single list field, such as
+// {"highlights": [...]}, which models often reply with instead of the bare list
+func unwrapList(value any) any {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	var list any
+	for _, field := range object {
+		if _, isList := field.([]any); isList {
+			if list != nil {
+				return value
+			}
+			list = field
+		}
+	}
+	if list == nil {
+		return value
+	}
+	return list
+}
+
+// validateHighlights checks every highlight has a numeric start before its end. Numbers sent as
+// strings are converted
+func validateHighlights(value any) (any, error) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("expected a JSON array of highlights")
+	}
+	highlights := make([]map[string]any, 0, len(list))
+	for i, item := range list {
+		highlight, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("highlight %d is not an object", i)
+		}
+		start, err := jsonNumber(highlight["start"])
+		if err != nil {
+			return nil, fmt.Errorf("highlight %d: start %v", i, err)
+		}
+		end, err := jsonNumber(highlight["end"])
+		if err != nil {
+			return nil, fmt.Errorf("highlight %d: end %v", i, err)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("highlight %d ends before it starts", i)
+		}
+		highlight["start"], highlight["end"] = start, end
+		highlights = append(highlights, highlight)
+	}
+	return highlights, nil
+}
+
+// validateReorder checks the order is a list of IDs
+func validateReorder(value any) (any, error) {
+	list, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("expected a JSON array of IDs")
+	}
+	if len(list) == 0 {
+		return nil, errors.New("order is empty")
+	}
+	for i, item := range list {
+		switch id := item.(type) {
+		case string:
+			if strings.TrimSpace(id) == "" {
+				return nil, fmt.Errorf("ID %d is empty", i)
+			}
+		case float64:
+		default:
+			return nil, fmt.Errorf("ID %d is not a string or number", i)
+		}
+	}
+	return list, nil
+}
+
+// jsonNumber reads a number, or a string holding one
+func jsonNumber(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return n, nil
+		}
+	case nil:
+		return 0, errors.New("is missing")
+	}
+	return 0, errors.New("is not a number")
+}
+
+// correctivePrompt asks the model to fix its previous reply
+func correctivePrompt(schema structuredSchema, err error) string {
+	return fmt.Sprintf("Your previous reply could not be used: %v. Reply again with only %s, and no other text.", err, schema.Describe)
+}
+
+// completeStructured validates the reply of a task with a schema. A malformed reply is repaired
+// when it only has fences, surrounding prose or trailing commas, and otherwise sent back to the
+// model with a corrective prompt, up to structuredRetries times. The response gets a Result,
+// and its usage covers every attempt
+func completeStructured(request *TextProcessingRequest, schema structuredSchema, response *OpenRouterResponse, usage TokenUsage, logger *slog.Logger) (*OpenRouterResponse, TokenUsage) {
+	content := response.Choices[0].Message.Content
+	structured, repaired, err := schema.parse(content)
+	attempts := 1
+
+	for err != nil && attempts <= structuredRetries() {
+		logger.Warn("Malformed structured reply, asking the model to correct it", "attempt", attempts, "error", err)
+		retry := *request
+		retry.followUp = append(append([]Message(nil), request.followUp...),
+			Message{Role: "assistant", Content: content},
+			Message{Role: "user", Content: correctivePrompt(schema, err)})
+
+		corrected, callErr := completeText(&retry)
+		attempts++
+		if callErr != nil || len(corrected.Choices) == 0 {
+			logger.Warn("Corrective request failed", "error", callErr)
+			break
+		}
+		content = corrected.Choices[0].Message.Content
+		usage = addTokenUsage(usage, textTokenUsage(corrected.Usage, &retry, len(content)))
+		response.Choices = corrected.Choices
+		structured, repaired, err = schema.parse(content)
+	}
+
+	result := &TextProcessingResult{TaskType: request.TaskType, TokensUsed: usage.TotalTokens, Attempts: attempts}
+	if err != nil {
+		logger.Warn("Structured reply is still malformed, returning it as is", "attempts", attempts, "error", err)
+		result.ValidationError = err.Error()
+	} else {
+		if repaired != "" {
+			response.Choices[0].Message.Content = repaired
+		}
+		result.Structured = structured
+	}
+	result.Content = response.Choices[0].Message.Content
+	response.Result = result
+	if attempts > 1 {
+		response.Usage = &usage
+	}
+	return response, usage
+}
+
+// addTokenUsage sums the usage of two completions
+func addTokenUsage(a, b TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+		Estimated:        a.Estimated || b.Estimated,
+	}
+}
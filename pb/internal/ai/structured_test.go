@@ -0,0 +1,100 @@
+package ai
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStructuredParseRepairs(t *testing.T) {
+	schema := structuredTasks["suggest_highlights"]
+
+	tests := []struct {
+		name     string
+		content  string
+		repaired string
+	}{
+		{"clean", `[{"start": 1, "end": 4}]`, ""},
+		{"fenced", "```json\n[{\"start\": 1, \"end\": 4}]\n```", `[{"start": 1, "end": 4}]`},
+		{"prose and trailing comma", `Here you go: [{"start": 1, "end": 4},] Enjoy!`, `[{"start": 1, "end": 4}]`},
+		{"wrapped in an object", `{"highlights": [{"start": "1", "end": 4, "text": "hi"}]}`, ""},
+	}
+	for _, tt := range tests {
+		structured, repaired, err := schema.parse(tt.content)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if repaired != tt.repaired {
+			t.Errorf("%s: repaired %q, want %q", tt.name, repaired, tt.repaired)
+		}
+		highlights := structured.([]map[string]any)
+		if len(highlights) != 1 || highlights[0]["start"] != 1.0 || highlights[0]["end"] != 4.0 {
+			t.Errorf("%s: got %v", tt.name, highlights)
+		}
+	}
+}
+
+func TestStructuredParseRejects(t *testing.T) {
+	tests := map[string]struct {
+		task    string
+		content string
+	}{
+		"no json":           {"suggest_highlights", "I couldn't find any highlights."},
+		"truncated":         {"suggest_highlights", `[{"start": 1, "end": 4}, {"start": 5`},
+		"missing end":       {"suggest_highlights", `[{"start": 1}]`},
+		"ends before start": {"suggest_highlights", `[{"start": 5, "end": 4}]`},
+		"not a list":        {"reorder", `{"order": "a,b"}`},
+		"empty order":       {"reorder", `[]`},
+		"object ID":         {"reorder", `["a", {"id": "b"}]`},
+	}
+	for name, tt := range tests {
+		if _, _, err := structuredTasks[tt.task].parse(tt.content); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCompleteStructuredRetries(t *testing.T) {
+	var requests []OpenRouterRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenRouterRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+		json.NewEncoder(w).Encode(OpenRouterResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: `["b", "a"]`}}},
+			Usage:   &TokenUsage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25},
+		})
+	}))
+	defer server.Close()
+	t.Setenv("OPENROUTER_API_KEY", "test")
+	t.Setenv("OPENROUTER_BASE_URL", server.URL)
+	t.Setenv("TEXT_STRUCTURED_RETRIES", "1")
+
+	request := &TextProcessingRequest{UserPrompt: "reorder a, b", Model: "test/model", TaskType: "reorder"}
+	response := &OpenRouterResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: "b then a"}}}}
+	first := TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	response, usage := completeStructured(request, structuredTasks["reorder"], response, first, logger)
+	if len(requests) != 1 {
+		t.Fatalf("Expected one corrective request, got %d", len(requests))
+	}
+	messages := requests[0].Messages
+	if len(messages) != 3 || messages[1].Content != "b then a" || !strings.Contains(messages[2].Content, "JSON array") {
+		t.Errorf("Unexpected corrective conversation: %+v", messages)
+	}
+	if response.Result == nil || response.Result.ValidationError != "" || response.Result.Attempts != 2 {
+		t.Fatalf("Unexpected result: %+v", response.Result)
+	}
+	if order, _ := response.Result.Structured.([]any); len(order) != 2 || order[0] != "b" {
+		t.Errorf("Unexpected structured order: %v", response.Result.Structured)
+	}
+	if usage.TotalTokens != 40 || response.Usage == nil || response.Usage.TotalTokens != 40 {
+		t.Errorf("Expected the usage of both attempts, got %+v", usage)
+	}
+}
@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/costs"
+	"pocketbase/internal/subscription"
+)
+
+// TokenUsage is the usage block of an OpenAI-style completion (OpenRouter always sends it;
+// streams send it on the last chunk when asked to with stream_options)
+type TokenUsage struct {
+	PromptTokens     int  `json:"prompt_tokens"`
+	CompletionTokens int  `json:"completion_tokens"`
+	TotalTokens      int  `json:"total_tokens"`
+	Estimated        bool `json:"estimated,omitempty"` // the provider didn't report usage
+}
+
+// textTokenUsage returns the provider's usage, or an estimate from the prompt and output sizes
+// for providers that don't report it
+func textTokenUsage(reported *TokenUsage, request *TextProcessingRequest, outputChars int) TokenUsage {
+	if reported != nil && reported.TotalTokens > 0 {
+		return *reported
+	}
+	usage := TokenUsage{
+		PromptTokens:     estimateTokens(request.SystemPrompt) + estimateTokens(request.UserPrompt),
+		CompletionTokens: (outputChars + charsPerToken - 1) / charsPerToken,
+		Estimated:        true,
+	}
+	for _, message := range request.followUp {
+		usage.PromptTokens += estimateTokens(message.Content)
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
+
+// periodTokensUsed returns the tokens the user's text requests used in the usage period
+func periodTokensUsed(app core.App, userID string, period subscription.UsagePeriod, now time.Time) int {
+	record, err := subscription.FindUsageRecord(app, userID, "", period, now)
+	if err != nil || record == nil {
+		return 0
+	}
+	return record.GetInt("tokens_used")
+}
+
+// monthlyTokensUsed returns the tokens the user's text requests used in the period labelled yearMonth
+func monthlyTokensUsed(app core.App, userID string, yearMonth string) int {
+	record, err := app.FindFirstRecordByFilter("monthly_usage",
+		"user_id = {:user} && organization_id = '' && year_month = {:month}",
+		dbx.Params{"user": userID, "month": yearMonth})
+	if err != nil {
+		return 0
+	}
+	return record.GetInt("tokens_used")
+}
+
+// validateTokenLimits rejects text requests once the month's tokens reach the plan's
+// tokens_per_month. Plans without an allowance (0) aren't limited. The request that crosses
+// the limit is let through, since its output size isn't known up front
+func validateTokenLimits(app core.App, logger *slog.Logger, userID string) error {
+	plan, _ := userPlanLimit(app, userID)
+	if plan == nil {
+		return nil
+	}
+	limit := plan.GetInt("tokens_per_month")
+	if limit <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	used := periodTokensUsed(app, userID, subscription.CurrentUsagePeriod(app, userID, now), now)
+	if used >= limit {
+		return fmt.Errorf("monthly limit of %d text tokens reached for %s plan (currently used: %d tokens)",
+			limit, plan.GetString("name"), used)
+	}
+
+	logger.Debug("Token usage within limit", "tokens_used", used, "tokens_limit", limit)
+	return nil
+}
+
+// addMonthlyTokens adds a text request's tokens to the user's monthly_usage record for the
+// current usage period. Existing records are incremented in SQL so concurrent requests don't
+// overwrite each other
+func addMonthlyTokens(app core.App, userID string, tokens int) error {
+	if tokens <= 0 {
+		return nil
+	}
+	now := time.Now()
+	period := subscription.CurrentUsagePeriod(app, userID, now)
+
+	record, err := subscription.FindUsageRecord(app, userID, "", period, now)
+	if err != nil {
+		return err
+	}
+	if record != nil {
+		_, err = app.DB().NewQuery("UPDATE monthly_usage SET tokens_used = tokens_used + {:tokens} WHERE id = {:id}").
+			Bind(dbx.Params{"tokens": tokens, "id": record.Id}).Execute()
+		return err
+	}
+
+	record, err = subscription.NewUsageRecord(app, userID, "", period)
+	if err != nil {
+		return err
+	}
+	record.Set("tokens_used", tokens)
+	return app.Save(record)
+}
+
+// recordTextUsage logs a completed text request, counts its tokens against the month and adds
+// its cost to the cost ledger
+func recordTextUsage(app core.App, logger *slog.Logger, userID string, request *TextProcessingRequest, usage TokenUsage, outputChars int, elapsed time.Duration, clientIP string) {
+	logAIUsage(app, logger, userID, request.TaskType, request.Model, usage, len(request.UserPrompt), outputChars, elapsed, clientIP)
+	if err := addMonthlyTokens(app, userID, usage.TotalTokens); err != nil {
+		logger.Error("Failed to update monthly token usage", "tokens", usage.TotalTokens, "error", err)
+	}
+	recordCost(app, logger, costs.Entry{
+		UserID:           userID,
+		Kind:             costs.KindText,
+		TaskType:         request.TaskType,
+		Provider:         TextBreakerName,
+		Model:            request.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TokensEstimated:  usage.Estimated,
+	})
+}
+
+// textTokenSummary reports a month's text tokens next to the plan allowance (0 = unlimited).
+// Without a month it reports the current usage period
+func textTokenSummary(app core.App, userID, yearMonth string) map[string]any {
+	limit := 0
+	if plan, _ := userPlanLimit(app, userID); plan != nil {
+		limit = plan.GetInt("tokens_per_month")
+	}
+
+	var used int
+	if yearMonth == "" {
+		now := time.Now()
+		period := subscription.CurrentUsagePeriod(app, userID, now)
+		yearMonth = period.Key
+		used = periodTokensUsed(app, userID, period, now)
+	} else {
+		used = monthlyTokensUsed(app, userID, yearMonth)
+	}
+	return map[string]any{
+		"month": yearMonth,
+		"used":  used,
+		"limit": limit,
+	}
+}
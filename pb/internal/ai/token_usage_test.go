@@ -0,0 +1,25 @@
+package ai
+
+import "testing"
+
+func TestTextTokenUsage(t *testing.T) {
+	request := &TextProcessingRequest{SystemPrompt: "12345678", UserPrompt: "1234"}
+
+	reported := &TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	if got := textTokenUsage(reported, request, 400); got != *reported {
+		t.Errorf("reported usage should be kept, got %+v", got)
+	}
+
+	// Providers that don't report usage are estimated at charsPerToken
+	want := TokenUsage{
+		PromptTokens:     estimateTokens("12345678") + estimateTokens("1234"),
+		CompletionTokens: (9 + charsPerToken - 1) / charsPerToken,
+		Estimated:        true,
+	}
+	want.TotalTokens = want.PromptTokens + want.CompletionTokens
+	for _, missing := range []*TokenUsage{nil, {}} {
+		if got := textTokenUsage(missing, request, 9); got != want {
+			t.Errorf("textTokenUsage(%v) = %+v, want %+v", missing, got, want)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/filesystem"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/moderation"
+	"pocketbase/internal/progress"
+	"pocketbase/internal/whisper"
+)
+
+// Transcription job states
+const (
+	JobQueued     = "queued"
+	JobProcessing = "processing"
+	JobCompleted  = "completed"
+	JobFailed     = "failed"
+)
+
+// maxJobAttempts is how many times a job is started before it's failed - a job left
+// processing by a restart counts as an attempt
+const maxJobAttempts = 3
+
+// jobPollInterval is how often idle workers look for queued jobs they weren't woken for
+const jobPollInterval = 15 * time.Second
+
+// TranscriptionJob is a job as reported to its owner
+type TranscriptionJob struct {
+	ID           string          `json:"id"`
+	Status       string          `json:"status"`
+	Filename     string          `json:"filename"`
+	BaseFilename string          `json:"base_filename,omitempty"`
+	IsChunk      bool            `json:"is_chunk"`
+	ChunkIndex   int             `json:"chunk_index,omitempty"`
+	SessionID    string          `json:"session_id,omitempty"` // set for jobs queued by finalizing an upload session
+	Attempts     int             `json:"attempts"`
+	Error        string          `json:"error,omitempty"`
+	Result       *whisper.Result `json:"result,omitempty"` // only on GET /api/ai/jobs/{id} once completed
+	Created      string          `json:"created"`
+	StartedAt    string          `json:"started_at,omitempty"`
+	CompletedAt  string          `json:"completed_at,omitempty"`
+}
+
+// isAsyncAudioRequest reports whether the client asked for a job instead of waiting for the transcript
+func isAsyncAudioRequest(e *core.RequestEvent) bool {
+	return e.Request.FormValue("async") == "true" || e.Request.URL.Query().Get("async") == "true"
+}
+
+// enqueueTranscriptionJob stores the upload on a transcription_jobs record and wakes a worker
+// POST /api/ai/process-audio with async=true answers 202 with the job id to poll
+func enqueueTranscriptionJob(e *core.RequestEvent, app core.App, logger *slog.Logger, user *core.Record, header *multipart.FileHeader,
+	upload audioUpload, processedFileRecord *core.Record, clientIP string) error {
+	collection, err := app.FindCollectionByNameOrId("transcription_jobs")
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to find transcription jobs collection"})
+	}
+
+	audio, err := filesystem.NewFileFromMultipart(header)
+	if err != nil {
+		return e.JSON(400, map[string]string{"error": "Audio file is required"})
+	}
+
+	job := core.NewRecord(collection)
+	job.Set("user_id", user.Id)
+	job.Set("status", JobQueued)
+	job.Set("audio", audio)
+	job.Set("filename", upload.Filename)
+	job.Set("file_size_bytes", upload.SizeBytes)
+	job.Set("base_filename", upload.BaseFilename)
+	job.Set("is_chunk", upload.IsChunk)
+	job.Set("is_last_chunk", upload.IsLastChunk)
+	job.Set("chunk_index", upload.ChunkIndex)
+	job.Set("chunk_start_seconds", upload.ChunkStart)
+	job.Set("original_file_size_bytes", upload.OriginalFileSize)
+	job.Set("original_duration_seconds", upload.OriginalDuration)
+	job.Set("client_ip", clientIP)
+	job.Set("request_id", logging.RequestID(e.Request.Context()))
+	job.Set("provider", upload.Provider)
+	if !upload.Options.IsZero() {
+		job.Set("transcription_options", upload.Options)
+	}
+	job.Set("organization_id", upload.OrganizationID)
+	if processedFileRecord != nil {
+		job.Set("processed_file_id", processedFileRecord.Id)
+	}
+
+	if err := app.Save(job); err != nil {
+		logger.Error("Failed to queue transcription job", "error", err)
+		if processedFileRecord != nil {
+			updateProcessedFileRecord(app, processedFileRecord, "failed", 0, 0, 0, 0)
+		}
+		return e.JSON(500, map[string]string{"error": "Failed to queue transcription"})
+	}
+
+	logger.Info("Queued transcription job", "job_id", job.Id, "size_kb", upload.SizeBytes/1024)
+	tracker := jobProgress(app, job)
+	wakeTranscriptionWorkers()
+
+	return e.JSON(202, map[string]string{
+		"job_id":      job.Id,
+		"status":      JobQueued,
+		"status_url":  "/api/ai/jobs/" + job.Id,
+		"progress_id": tracker.ID(),
+	})
+}
+
+// GetTranscriptionJobHandler reports a job's status, with the transcript once it's completed
+// GET /api/ai/jobs/{id}
+func GetTranscriptionJobHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := authenticateAPIKey(e, app, ScopeAIAudio)
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+
+	job, err := app.FindRecordById("transcription_jobs", e.Request.PathValue("id"))
+	if err != nil || job.GetString("user_id") != user.Id {
+		return e.JSON(404, map[string]string{"error": "Job not found"})
+	}
+
+	view := transcriptionJobView(job)
+	if view.Status == JobCompleted {
+		var result whisper.Result
+		if err := job.UnmarshalJSONField("result", &result); err == nil {
+			view.Result = &result
+		}
+	}
+	return e.JSON(200, view)
+}
+
+// ListTranscriptionJobsHandler lists the user's 50 most recent jobs, without transcripts
+// GET /api/ai/jobs?status=queued|processing|completed|failed
+func ListTranscriptionJobsHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := authenticateAPIKey(e, app, ScopeAIAudio)
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+
+	filter := "user_id = {:user}"
+	params := map[string]any{"user": user.Id}
+	if status := e.Request.URL.Query().Get("status"); status != "" {
+		switch status {
+		case JobQueued, JobProcessing, JobCompleted, JobFailed:
+		default:
+			return e.JSON(400, map[string]string{"error": "status must be queued, processing, completed or failed"})
+		}
+		filter += " && status = {:status}"
+		params["status"] = status
+	}
+
+	records, err := app.FindRecordsByFilter("transcription_jobs", filter, "-created", 50, 0, params)
+	if err != nil {
+		return e.JSON(500, map[string]string{"error": "Failed to load jobs"})
+	}
+
+	jobs := make([]TranscriptionJob, 0, len(records))
+	for _, record := range records {
+		jobs = append(jobs, transcriptionJobView(record))
+	}
+	return e.JSON(200, map[string]any{"jobs": jobs})
+}
+
+func transcriptionJobView(job *core.Record) TranscriptionJob {
+	view := TranscriptionJob{
+		ID:           job.Id,
+		Status:       job.GetString("status"),
+		Filename:     job.GetString("filename"),
+		BaseFilename: job.GetString("base_filename"),
+		IsChunk:      job.GetBool("is_chunk"),
+		ChunkIndex:   job.GetInt("chunk_index"),
+		SessionID:    job.GetString("session_id"),
+		Attempts:     job.GetInt("attempts"),
+		Error:        job.GetString("error"),
+		Created:      job.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+	}
+	if started := job.GetDateTime("started_at"); !started.IsZero() {
+		view.StartedAt = started.Time().UTC().Format(time.RFC3339)
+	}
+	if completed := job.GetDateTime("completed_at"); !completed.IsZero() {
+		view.CompletedAt = completed.Time().UTC().Format(time.RFC3339)
+	}
+	return view
+}
+
+// transcriptionWorkers claims queued jobs one at a time and transcribes them
+type transcriptionWorkers struct {
+	app    core.App
+	ctx    context.Context
+	wake   chan struct{}
+	claims sync.Mutex // workers share one process, so claiming under a lock is enough
+}
+
+var activeWorkers *transcriptionWorkers
+
+// StartTranscriptionWorkers requeues jobs interrupted by a restart and starts TRANSCRIPTION_WORKERS
+// workers (default 2). Workers stop when the app terminates; their jobs are picked up on the next start
+func StartTranscriptionWorkers(app core.App) {
+	count := 2
+	if v, err := strconv.Atoi(os.Getenv("TRANSCRIPTION_WORKERS")); err == nil && v >= 0 {
+		count = v
+	}
+	if count == 0 {
+		slog.Warn("Transcription workers disabled, async jobs stay queued")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		cancel()
+		return e.Next()
+	})
+
+	workers := &transcriptionWorkers{app: app, ctx: ctx, wake: make(chan struct{}, count)}
+	workers.requeueInterrupted()
+	activeWorkers = workers
+
+	for i := 0; i < count; i++ {
+		go workers.run()
+	}
+	slog.Info("Started transcription workers", "workers", count)
+}
+
+// wakeTranscriptionWorkers nudges an idle worker after a job is queued
+func wakeTranscriptionWorkers() {
+	if activeWorkers == nil {
+		return
+	}
+	select {
+	case activeWorkers.wake <- struct{}{}:
+	default: // every worker is already busy or about to look
+	}
+}
+
+// requeueInterrupted puts jobs that were processing when the server stopped back in the queue
+func (w *transcriptionWorkers) requeueInterrupted() {
+	records, err := w.app.FindRecordsByFilter("transcription_jobs", "status = {:status}", "", 0, 0,
+		map[string]any{"status": JobProcessing})
+	if err != nil {
+		slog.Error("Failed to load interrupted transcription jobs", "error", err)
+		return
+	}
+	for _, job := range records {
+		if job.GetInt("attempts") >= maxJobAttempts {
+			w.fail(job, "transcription was interrupted too many times")
+			continue
+		}
+		job.Set("status", JobQueued)
+		if err := w.app.Save(job); err != nil {
+			jobLogger(job).Error("Failed to requeue interrupted job", "error", err)
+			continue
+		}
+		jobProgress(w.app, job).Update(progress.StageQueued, 0)
+		jobLogger(job).Info("Requeued interrupted job", "attempt", job.GetInt("attempts"))
+	}
+}
+
+func (w *transcriptionWorkers) run() {
+	for {
+		job := w.claim()
+		if job == nil {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-w.wake:
+			case <-time.After(jobPollInterval):
+			}
+			continue
+		}
+		w.process(job)
+		if w.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// claim marks the oldest queued job as processing and returns it
+func (w *transcriptionWorkers) claim() *core.Record {
+	w.claims.Lock()
+	defer w.claims.Unlock()
+
+	records, err := w.app.FindRecordsByFilter("transcription_jobs", "status = {:status}", "created", 1, 0,
+		map[string]any{"status": JobQueued})
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+
+	job := records[0]
+	job.Set("status", JobProcessing)
+	job.Set("attempts", job.GetInt("attempts")+1)
+	job.Set("started_at", time.Now())
+	if err := w.app.Save(job); err != nil {
+		jobLogger(job).Error("Failed to claim job", "error", err)
+		return nil
+	}
+	return job
+}
+
+// process streams the stored audio to Whisper and records the outcome
+func (w *transcriptionWorkers) process(job *core.Record) {
+	if job.GetString("session_id") != "" {
+		w.processSession(job)
+		return
+	}
+
+	startTime := time.Now()
+	userID := job.GetString("user_id")
+	upload := audioUpload{
+		Filename:         job.GetString("filename"),
+		SizeBytes:        int64(job.GetInt("file_size_bytes")),
+		BaseFilename:     job.GetString("base_filename"),
+		IsChunk:          job.GetBool("is_chunk"),
+		IsLastChunk:      job.GetBool("is_last_chunk"),
+		ChunkIndex:       job.GetInt("chunk_index"),
+		ChunkStart:       job.GetFloat("chunk_start_seconds"),
+		OriginalFileSize: int64(job.GetInt("original_file_size_bytes")),
+		OriginalDuration: job.GetFloat("original_duration_seconds"),
+		Provider:         job.GetString("provider"),
+		OrganizationID:   job.GetString("organization_id"),
+	}
+
+	logger := jobLogger(job).With("filename", upload.Filename)
+	logger.Info("Processing transcription job", "attempt", job.GetInt("attempts"))
+
+	tracker := jobProgress(w.app, job)
+	tracker.Update(progress.StageTranscribing, 0)
+	result, err := w.transcribe(job, upload.Filename, tracker)
+	if err != nil {
+		if w.ctx.Err() != nil {
+			// Shutting down - leave the job processing so the next start requeues it
+			logger.Info("Transcription job interrupted by shutdown")
+			return
+		}
+
+		if w.retryLater(job, err) {
+			return
+		}
+
+		elapsed := time.Since(startTime)
+		if processedFile := w.processedFile(job); processedFile != nil {
+			updateProcessedFileRecord(w.app, processedFile, "failed", 0, 0, 0, elapsed.Milliseconds())
+		}
+		logger.Error("Transcription job failed", "duration_ms", elapsed.Milliseconds(), "error", err)
+		w.fail(job, fmt.Sprintf("Transcription failed: %v", err))
+		return
+	}
+
+	tracker.Update(progress.StagePostProcessing, 0)
+	if upload.IsChunk {
+		if _, ok := offsetChunkResult(w.app, userID, upload, result); !ok {
+			logger.Warn("Earlier chunks haven't completed, chunk timestamps start at 0")
+		}
+	}
+	processedFile := w.processedFile(job)
+	completeAudioProcessing(w.app, logger, processedFile, userID, job.GetString("client_ip"), upload, result, time.Since(startTime))
+	if _, _, err := moderateTranscript(w.ctx, w.app, logger, userID, processedFile, result); errors.Is(err, moderation.ErrFlagged) {
+		w.fail(job, contentFlaggedMessage)
+		return
+	}
+
+	job.Set("status", JobCompleted)
+	job.Set("result", result)
+	job.Set("error", "")
+	job.Set("completed_at", time.Now())
+	job.Set("audio", nil) // the transcript is kept; the audio isn't needed anymore
+	if err := w.app.Save(job); err != nil {
+		logger.Error("Failed to save transcription job result", "error", err)
+	}
+	tracker.Complete()
+}
+
+// retryLater requeues a job hit by a provider outage instead of failing it, while it has attempts left
+func (w *transcriptionWorkers) retryLater(job *core.Record, err error) bool {
+	var outage *circuit.OutageError
+	if !errors.As(err, &outage) || job.GetInt("attempts") >= maxJobAttempts {
+		return false
+	}
+	job.Set("status", JobQueued)
+	job.Set("error", err.Error())
+	if saveErr := w.app.Save(job); saveErr != nil {
+		jobLogger(job).Error("Failed to requeue job", "error", saveErr)
+	}
+	jobProgress(w.app, job).Update(progress.StageQueued, 0)
+	jobLogger(job).Warn("Provider outage, requeued job", "provider", outage.Provider, "retry_after_seconds", outage.RetryAfter.Seconds())
+	return true
+}
+
+// transcribe streams the job's stored audio to Whisper, then to the diarizer when the job asks for speakers
+func (w *transcriptionWorkers) transcribe(job *core.Record, filename string, tracker *progress.Tracker) (*whisper.Result, error) {
+	fsys, err := w.app.NewFilesystem()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file storage: %w", err)
+	}
+	defer fsys.Close()
+
+	reader, err := fsys.GetReader(job.BaseFilesPath() + "/" + job.GetString("audio"))
+	if err != nil {
+		return nil, fmt.Errorf("stored audio is missing: %w", err)
+	}
+	defer reader.Close()
+
+	var options whisper.Options
+	job.UnmarshalJSONField("transcription_options", &options)
+	ctx := whisper.WithOptions(whisper.WithProvider(w.ctx, job.GetString("provider")), options)
+	prepared := prepareAudio(ctx, reader, reader.Size(), filename)
+	defer prepared.Close()
+	result, err := prepared.transcribe(ctx, tracker)
+	if err != nil || !options.Diarize {
+		return result, err
+	}
+
+	// Speakers are a best-effort extra, as for synchronous requests
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		jobLogger(job).Warn("Diarization skipped", "error", err)
+	} else if err := diarizeResult(w.ctx, reader, filename, result); err != nil {
+		jobLogger(job).Warn("Diarization failed, completing without speakers", "error", err)
+	}
+	return result, nil
+}
+
+func (w *transcriptionWorkers) processedFile(job *core.Record) *core.Record {
+	id := job.GetString("processed_file_id")
+	if id == "" {
+		return nil
+	}
+	record, err := w.app.FindRecordById("processed_files", id)
+	if err != nil {
+		return nil
+	}
+	return record
+}
+
+func (w *transcriptionWorkers) fail(job *core.Record, message string) {
+	job.Set("status", JobFailed)
+	job.Set("error", message)
+	job.Set("completed_at", time.Now())
+	job.Set("audio", nil)
+	if err := w.app.Save(job); err != nil {
+		jobLogger(job).Error("Failed to mark job failed", "error", err)
+	}
+	jobProgress(w.app, job).Fail(message)
+
+	// A failed session can be finalized again to retry the chunks that didn't transcribe
+	if sessionID := job.GetString("session_id"); sessionID != "" {
+		if session, err := w.app.FindRecordById("audio_upload_sessions", sessionID); err == nil {
+			session.Set("status", AudioSessionFailed)
+			session.Set("error", message)
+			if err := w.app.Save(session); err != nil {
+				jobLogger(job).Error("Failed to mark audio session failed", "session_id", session.Id, "error", err)
+			}
+		}
+	}
+}
+
+// jobProgress is the processing job clients follow a transcription job's progress on
+func jobProgress(app core.App, job *core.Record) *progress.Tracker {
+	return progress.Track(app, job.GetString("user_id"), progress.SourceTranscriptionJob, job.Id,
+		job.GetString("filename"), progress.StageQueued, int64(job.GetInt("file_size_bytes")))
+}
+
+// jobLogger tags a job's log lines with the request that queued it, so a transcription can be
+// followed from upload to result
+func jobLogger(job *core.Record) *slog.Logger {
+	logger := slog.Default().With("job_id", job.Id, "user_id", job.GetString("user_id"))
+	if requestID := job.GetString("request_id"); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	return logger
+}
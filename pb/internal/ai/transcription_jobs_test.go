@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func TestTranscriptionJobView(t *testing.T) {
+	collection := core.NewBaseCollection("transcription_jobs")
+	collection.Fields.Add(
+		&core.TextField{Name: "status"},
+		&core.TextField{Name: "filename"},
+		&core.NumberField{Name: "attempts"},
+		&core.DateField{Name: "started_at"},
+		&core.DateField{Name: "completed_at"},
+	)
+
+	job := core.NewRecord(collection)
+	job.Id = "job1"
+	job.Set("status", JobQueued)
+	job.Set("filename", "episode.m4a")
+
+	view := transcriptionJobView(job)
+	if view.ID != "job1" || view.Status != JobQueued || view.Filename != "episode.m4a" {
+		t.Fatalf("unexpected view: %+v", view)
+	}
+	if view.StartedAt != "" || view.CompletedAt != "" {
+		t.Errorf("queued job shouldn't report start or completion times: %+v", view)
+	}
+
+	started := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	startedAt, _ := types.ParseDateTime(started)
+	job.Set("status", JobProcessing)
+	job.Set("attempts", 1)
+	job.Set("started_at", startedAt)
+
+	view = transcriptionJobView(job)
+	if view.StartedAt != "2026-03-01T10:00:00Z" || view.Attempts != 1 {
+		t.Errorf("processing job: got started_at=%q attempts=%d", view.StartedAt, view.Attempts)
+	}
+	if view.Result != nil {
+		t.Error("the view never loads the result itself")
+	}
+}
@@ -0,0 +1,129 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/apisign"
+)
+
+// uploadLimitContextKey holds the body limit applied to an audio upload, for the error response
+const uploadLimitContextKey = "audioUploadLimit"
+
+// UploadLimits are the audio upload size limits, in bytes. Max is the ceiling no request body may
+// exceed; Free and Paid are the per-tier limits under it, which a plan's max_upload_mb overrides
+type UploadLimits struct {
+	Max  int64 `json:"max_bytes"`
+	Free int64 `json:"free_bytes"`
+	Paid int64 `json:"paid_bytes"`
+	// ProviderFile is the largest file sent to the transcription provider in one request
+	ProviderFile int64 `json:"provider_file_bytes"`
+}
+
+// UploadLimitsFromEnv reads UPLOAD_MAX_MB (default 2048), UPLOAD_FREE_MAX_MB (default 500),
+// UPLOAD_PAID_MAX_MB (default UPLOAD_MAX_MB) and WHISPER_MAX_FILE_SIZE (bytes, default 25MB).
+// Tier limits above the ceiling are capped to it
+func UploadLimitsFromEnv() UploadLimits {
+	limits := UploadLimits{Max: 2048 << 20, Free: 500 << 20, ProviderFile: 25 << 20}
+	if v, err := strconv.ParseInt(os.Getenv("UPLOAD_MAX_MB"), 10, 64); err == nil && v > 0 {
+		limits.Max = v << 20
+	}
+	limits.Paid = limits.Max
+	if v, err := strconv.ParseInt(os.Getenv("UPLOAD_FREE_MAX_MB"), 10, 64); err == nil && v > 0 {
+		limits.Free = v << 20
+	}
+	if v, err := strconv.ParseInt(os.Getenv("UPLOAD_PAID_MAX_MB"), 10, 64); err == nil && v > 0 {
+		limits.Paid = v << 20
+	}
+	if v, err := strconv.ParseInt(os.Getenv("WHISPER_MAX_FILE_SIZE"), 10, 64); err == nil && v > 0 {
+		limits.ProviderFile = v
+	}
+	limits.Free = min(limits.Free, limits.Max)
+	limits.Paid = min(limits.Paid, limits.Max)
+	return limits
+}
+
+// ForPlan returns the upload limit of a plan: its max_upload_mb when set, otherwise the free or
+// paid tier limit. Users without a plan get the free limit
+func (l UploadLimits) ForPlan(plan *core.Record) int64 {
+	if plan == nil {
+		return l.Free
+	}
+	if mb := plan.GetInt("max_upload_mb"); mb > 0 {
+		return min(int64(mb)<<20, l.Max)
+	}
+	if plan.GetInt("price_cents") > 0 {
+		return l.Paid
+	}
+	return l.Free
+}
+
+// limitAudioUpload is the body limit of audio uploads: the plan limit of the API key's owner, or
+// the ceiling when the caller isn't known yet - the handler rejects those. Larger bodies are
+// refused with the caller's limit, before or while they're read
+func limitAudioUpload(app core.App) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		limits := UploadLimitsFromEnv()
+		limit := limits.Max
+		if user := uploadOwner(app, e); user != nil {
+			plan, _ := userPlanLimit(app, user.Id)
+			limit = limits.ForPlan(plan)
+		}
+		e.Set(uploadLimitContextKey, limit)
+
+		if e.Request.ContentLength > limit {
+			return uploadTooLargeResponse(e, limit)
+		}
+		e.Request.Body = http.MaxBytesReader(e.Response, e.Request.Body, limit)
+		return e.Next()
+	}
+}
+
+// uploadOwner returns the owner of the request's API key, or of the key named by its signature,
+// without the side effects of authenticateAPIKey. Body limits run before signatures are verified,
+// so a signed request only picks its limit here - the handler still rejects a bad signature
+func uploadOwner(app core.App, e *core.RequestEvent) *core.Record {
+	var key *core.Record
+	var err error
+	if keyID := e.Request.Header.Get(apisign.HeaderKeyID); keyID != "" {
+		key, err = app.FindFirstRecordByFilter("api_keys", "id = {:id} && active = true", map[string]any{"id": keyID})
+	} else if apiKey := extractBearerToken(e.Request.Header.Get("Authorization")); strings.HasPrefix(apiKey, DelegatedTokenPrefix) {
+		_, user, _ := verifyDelegatedToken(app, apiKey)
+		return user
+	} else if apiKey != "" {
+		key, err = app.FindFirstRecordByFilter("api_keys", "key_hash = {:hash} && active = true",
+			map[string]any{"hash": hashAPIKey(apiKey)})
+	} else {
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+	user, err := app.FindRecordById("users", key.GetString("user_id"))
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// isUploadTooLarge reports whether reading the request body failed on the upload limit
+func isUploadTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// uploadTooLargeResponse tells the client the upload is over their limit, and what the limit is
+func uploadTooLargeResponse(e *core.RequestEvent, limit int64) error {
+	return e.JSON(http.StatusRequestEntityTooLarge, map[string]any{
+		"error":       fmt.Sprintf("Audio uploads are limited to %d MB on your plan", limit>>20),
+		"code":        "UPLOAD_TOO_LARGE",
+		"limit_bytes": limit,
+		"limit_mb":    limit >> 20,
+	})
+}
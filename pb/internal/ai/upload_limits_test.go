@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func uploadTestPlan(priceCents, maxUploadMB int) *core.Record {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(
+		&core.NumberField{Name: "price_cents"},
+		&core.NumberField{Name: "max_upload_mb"},
+	)
+	plan := core.NewRecord(collection)
+	plan.Set("price_cents", priceCents)
+	plan.Set("max_upload_mb", maxUploadMB)
+	return plan
+}
+
+func TestUploadLimitsFromEnv(t *testing.T) {
+	t.Setenv("UPLOAD_MAX_MB", "1000")
+	t.Setenv("UPLOAD_FREE_MAX_MB", "100")
+	t.Setenv("UPLOAD_PAID_MAX_MB", "5000")
+	t.Setenv("WHISPER_MAX_FILE_SIZE", "not-a-number")
+
+	limits := UploadLimitsFromEnv()
+	if limits.Max != 1000<<20 || limits.Free != 100<<20 {
+		t.Errorf("got max %d and free %d", limits.Max, limits.Free)
+	}
+	if limits.Paid != limits.Max {
+		t.Errorf("paid limit above the ceiling should be capped, got %d", limits.Paid)
+	}
+	if limits.ProviderFile != 25<<20 {
+		t.Errorf("invalid provider file size should keep the default, got %d", limits.ProviderFile)
+	}
+}
+
+func TestUploadLimitForPlan(t *testing.T) {
+	limits := UploadLimits{Max: 2048 << 20, Free: 500 << 20, Paid: 1024 << 20}
+
+	tests := []struct {
+		name string
+		plan *core.Record
+		want int64
+	}{
+		{"no plan", nil, 500 << 20},
+		{"free plan", uploadTestPlan(0, 0), 500 << 20},
+		{"paid plan", uploadTestPlan(999, 0), 1024 << 20},
+		{"plan override", uploadTestPlan(0, 50), 50 << 20},
+		{"plan override is capped", uploadTestPlan(999, 4096), 2048 << 20},
+	}
+	for _, tt := range tests {
+		if got := limits.ForPlan(tt.plan); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
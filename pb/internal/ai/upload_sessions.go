@@ -0,0 +1,150 @@
+package ai
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// Upload session states reported to clients
+const (
+	UploadSessionNotFound   = "not_found"
+	UploadSessionInProgress = "in_progress"
+	UploadSessionCompleted  = "completed"
+)
+
+// UploadSession tells a client which chunks of a chunked upload the server already has,
+// so a restarted upload can continue at NextChunkIndex instead of chunk 0
+type UploadSession struct {
+	BaseFilename            string  `json:"base_filename"`
+	Status                  string  `json:"status"`
+	Received                []int   `json:"received"`   // every chunk index with a record, in any state
+	Completed               []int   `json:"completed"`  // transcribed successfully - no need to resend
+	Processing              []int   `json:"processing"` // still being transcribed, or interrupted mid-request
+	Failed                  []int   `json:"failed"`
+	NextChunkIndex          int     `json:"next_chunk_index"` // first index that isn't completed
+	TotalChunks             int     `json:"total_chunks,omitempty"`
+	OriginalFileSizeBytes   int64   `json:"original_file_size_bytes,omitempty"`
+	OriginalDurationSeconds float64 `json:"original_duration_seconds,omitempty"`
+}
+
+// uploadChunk is the state of one processed_files chunk record
+type uploadChunk struct {
+	Index  int
+	Status string
+}
+
+// UploadSessionHandler reports the progress of a chunked upload for the API key's user
+// GET /api/ai/upload-sessions/{base_filename}
+func UploadSessionHandler(e *core.RequestEvent, app core.App) error {
+	user, _, err := authenticateAPIKey(e, app, ScopeAIAudio)
+	if errors.Is(err, errMissingAPIKey) {
+		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+	}
+	if handled, err := scopeDeniedResponse(e, err); handled {
+		return err
+	}
+	if err != nil {
+		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	}
+
+	baseFilename := e.Request.PathValue("base_filename")
+	if baseFilename == "" {
+		return e.JSON(400, map[string]string{"error": "base_filename is required"})
+	}
+
+	records, err := app.FindRecordsByFilter("processed_files", "user_id = {:user} && base_filename = {:base} && is_chunk = true",
+		"chunk_index", 0, 0, map[string]any{"user": user.Id, "base": baseFilename})
+	if err != nil {
+		logging.FromRequest(e).Error("Cannot load upload session chunks", "base_filename", baseFilename, "error", err)
+		return e.JSON(500, map[string]string{"error": "Failed to load upload session"})
+	}
+
+	chunks := make([]uploadChunk, 0, len(records))
+	for _, record := range records {
+		chunks = append(chunks, uploadChunk{Index: record.GetInt("chunk_index"), Status: record.GetString("status")})
+	}
+
+	session := summarizeUploadSession(baseFilename, chunks)
+	if len(records) > 0 {
+		session.OriginalFileSizeBytes = int64(records[0].GetInt("original_file_size_bytes"))
+		session.OriginalDurationSeconds = records[0].GetFloat("original_duration_seconds")
+	} else if flattened, err := app.FindFirstRecordByFilter("processed_files",
+		"user_id = {:user} && base_filename = {:base} && is_chunk = false && status = 'completed' && chunk_index > 0",
+		map[string]any{"user": user.Id, "base": baseFilename}); err == nil {
+		// Chunk records are folded into one record after the last chunk - chunk_index holds the count
+		session = completedUploadSession(baseFilename, flattened.GetInt("chunk_index"))
+		session.OriginalFileSizeBytes = int64(flattened.GetInt("file_size_bytes"))
+		session.OriginalDurationSeconds = flattened.GetFloat("duration_seconds")
+	}
+
+	return e.JSON(200, session)
+}
+
+// summarizeUploadSession groups chunk records by state; a chunk that was retried
+// counts as completed as soon as any attempt succeeded
+func summarizeUploadSession(baseFilename string, chunks []uploadChunk) UploadSession {
+	session := UploadSession{
+		BaseFilename: baseFilename,
+		Status:       UploadSessionNotFound,
+		Received:     []int{},
+		Completed:    []int{},
+		Processing:   []int{},
+		Failed:       []int{},
+	}
+	if len(chunks) == 0 {
+		return session
+	}
+	session.Status = UploadSessionInProgress
+
+	// The most advanced attempt wins: completed, then processing, then failed
+	rank := map[string]int{"failed": 1, "processing": 2, "completed": 3}
+	states := map[int]string{}
+	for _, chunk := range chunks {
+		if current, ok := states[chunk.Index]; !ok || rank[chunk.Status] > rank[current] {
+			states[chunk.Index] = chunk.Status
+		}
+	}
+
+	for index, status := range states {
+		session.Received = append(session.Received, index)
+		switch status {
+		case "completed":
+			session.Completed = append(session.Completed, index)
+		case "failed":
+			session.Failed = append(session.Failed, index)
+		default:
+			session.Processing = append(session.Processing, index)
+		}
+	}
+	sort.Ints(session.Received)
+	sort.Ints(session.Completed)
+	sort.Ints(session.Processing)
+	sort.Ints(session.Failed)
+
+	for states[session.NextChunkIndex] == "completed" {
+		session.NextChunkIndex++
+	}
+	return session
+}
+
+// completedUploadSession describes an upload whose chunks have all been transcribed and flattened
+func completedUploadSession(baseFilename string, totalChunks int) UploadSession {
+	indices := make([]int, totalChunks)
+	for i := range indices {
+		indices[i] = i
+	}
+	return UploadSession{
+		BaseFilename:   baseFilename,
+		Status:         UploadSessionCompleted,
+		Received:       indices,
+		Completed:      indices,
+		Processing:     []int{},
+		Failed:         []int{},
+		NextChunkIndex: totalChunks,
+		TotalChunks:    totalChunks,
+	}
+}
@@ -0,0 +1,47 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeUploadSession(t *testing.T) {
+	session := summarizeUploadSession("talk.mp3", []uploadChunk{
+		{Index: 0, Status: "completed"},
+		{Index: 1, Status: "failed"},
+		{Index: 1, Status: "completed"}, // retried after a failure
+		{Index: 2, Status: "processing"},
+		{Index: 3, Status: "failed"},
+		{Index: 4, Status: "completed"},
+	})
+
+	if session.Status != UploadSessionInProgress {
+		t.Fatalf("status = %s", session.Status)
+	}
+	if !reflect.DeepEqual(session.Received, []int{0, 1, 2, 3, 4}) {
+		t.Fatalf("received = %v", session.Received)
+	}
+	if !reflect.DeepEqual(session.Completed, []int{0, 1, 4}) {
+		t.Fatalf("completed = %v", session.Completed)
+	}
+	if !reflect.DeepEqual(session.Processing, []int{2}) || !reflect.DeepEqual(session.Failed, []int{3}) {
+		t.Fatalf("processing = %v, failed = %v", session.Processing, session.Failed)
+	}
+	if session.NextChunkIndex != 2 {
+		t.Fatalf("next chunk = %d, want 2", session.NextChunkIndex)
+	}
+}
+
+func TestSummarizeUploadSessionEmpty(t *testing.T) {
+	session := summarizeUploadSession("talk.mp3", nil)
+	if session.Status != UploadSessionNotFound || session.NextChunkIndex != 0 || session.Received == nil {
+		t.Fatalf("unexpected session %+v", session)
+	}
+}
+
+func TestCompletedUploadSession(t *testing.T) {
+	session := completedUploadSession("talk.mp3", 3)
+	if session.Status != UploadSessionCompleted || session.NextChunkIndex != 3 || !reflect.DeepEqual(session.Completed, []int{0, 1, 2}) {
+		t.Fatalf("unexpected session %+v", session)
+	}
+}
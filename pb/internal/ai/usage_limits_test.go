@@ -5,6 +5,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/pocketbase/pocketbase/core"
 )
 
 // MockApp implements the core.App interface for testing
@@ -793,4 +795,30 @@ func TestValidateUsageLimits_GracePeriod_SmallNumbers_ShouldWork(t *testing.T) {
 	if err == nil {
 		t.Fatal("Expected error exceeding small grace period, got nil")
 	}
-}
\ No newline at end of file
+}
+
+func TestPlanGracePeriodSeconds(t *testing.T) {
+	t.Setenv("USAGE_GRACE_PERIOD_SECONDS", "90")
+
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(&core.NumberField{Name: "grace_period_seconds"})
+	withGrace := core.NewRecord(collection)
+	withGrace.Set("grace_period_seconds", 300)
+	withoutGrace := core.NewRecord(collection)
+
+	if got := planGracePeriodSeconds(withGrace); got != 300 {
+		t.Errorf("plan grace = %v, want 300", got)
+	}
+	if got := planGracePeriodSeconds(withoutGrace); got != 90 {
+		t.Errorf("unset plan grace = %v, want the env default 90", got)
+	}
+	if got := planGracePeriodSeconds(nil); got != 90 {
+		t.Errorf("no plan grace = %v, want the env default 90", got)
+	}
+	if summary := gracePeriodSummary(withGrace); summary["source"] != "plan" || summary["seconds"] != 300.0 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+	if summary := gracePeriodSummary(nil); summary["source"] != "default" {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+}
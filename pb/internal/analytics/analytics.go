@@ -0,0 +1,349 @@
+// Package analytics computes business metrics for superusers - MRR, subscriptions, churn,
+// transcription volume, top users and failure rates - straight from the existing collections
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// dbTimeLayout matches how PocketBase stores datetimes, for comparisons in SQL
+const dbTimeLayout = "2006-01-02 15:04:05.000Z"
+
+// Sandbox subscriptions are test tenants and never count as revenue or churn
+const realSubscriptions = "s.payment_provider != 'sandbox'"
+
+// monthlyCents is what a plan brings in per month: yearly prices are spread over 12 months
+// and free plans bring in nothing
+func monthlyCents(priceCents int, billingInterval string) int {
+	switch billingInterval {
+	case "month":
+		return priceCents
+	case "year":
+		return int(math.Round(float64(priceCents) / 12))
+	default:
+		return 0
+	}
+}
+
+// rate is failed out of total as a percentage, rounded to two decimals
+func rate(failed, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(failed)*10000/float64(total)) / 100
+}
+
+// windowStart is the first day of a window of days ending today (UTC)
+func windowStart(now time.Time, days int) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(0, 0, -(days - 1))
+}
+
+// dayKeys lists every day of the window, so days without activity show up as zeros
+func dayKeys(start time.Time, days int) []string {
+	keys := make([]string, days)
+	for i := range keys {
+		keys[i] = start.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	return keys
+}
+
+// PlanRevenue is the recurring revenue of one plan
+type PlanRevenue struct {
+	PlanID          string `json:"plan_id" db:"plan_id"`
+	PlanName        string `json:"plan_name" db:"plan_name"`
+	BillingInterval string `json:"billing_interval" db:"billing_interval"`
+	Currency        string `json:"currency" db:"currency"`
+	PriceCents      int    `json:"price_cents" db:"price_cents"`
+	Subscriptions   int    `json:"subscriptions" db:"subscriptions"`
+	MRRCents        int    `json:"mrr_cents"`
+}
+
+// MRRReport is monthly recurring revenue by plan and currency. Active and past-due paid
+// subscriptions count; trials don't until they convert
+type MRRReport struct {
+	TotalCents map[string]int `json:"total_cents"` // by currency
+	Plans      []PlanRevenue  `json:"plans"`
+}
+
+// MRR computes monthly recurring revenue by plan
+func MRR(app core.App) (MRRReport, error) {
+	var plans []PlanRevenue
+	err := app.DB().NewQuery(`SELECT p.id AS plan_id, p.name AS plan_name, p.billing_interval, p.currency,
+		p.price_cents, COUNT(*) AS subscriptions
+		FROM current_user_subscriptions s JOIN subscription_plans p ON p.id = s.plan_id
+		WHERE s.status IN ('active', 'past_due') AND p.price_cents > 0 AND ` + realSubscriptions + `
+		GROUP BY p.id`).All(&plans)
+	if err != nil {
+		return MRRReport{}, err
+	}
+
+	report := MRRReport{TotalCents: map[string]int{}, Plans: []PlanRevenue{}}
+	for _, plan := range plans {
+		plan.MRRCents = monthlyCents(plan.PriceCents, plan.BillingInterval) * plan.Subscriptions
+		report.TotalCents[plan.Currency] += plan.MRRCents
+		report.Plans = append(report.Plans, plan)
+	}
+	sort.SliceStable(report.Plans, func(i, j int) bool { return report.Plans[i].MRRCents > report.Plans[j].MRRCents })
+	return report, nil
+}
+
+// PlanSubscriptions counts a plan's current subscriptions by status
+type PlanSubscriptions struct {
+	PlanID   string         `json:"plan_id"`
+	PlanName string         `json:"plan_name"`
+	Paid     bool           `json:"paid"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// SubscriptionsReport counts current subscriptions. Active covers active, trialing and past-due
+type SubscriptionsReport struct {
+	Active     int                 `json:"active"`
+	ActivePaid int                 `json:"active_paid"`
+	ByStatus   map[string]int      `json:"by_status"`
+	Plans      []PlanSubscriptions `json:"plans"`
+}
+
+// Subscriptions counts current subscriptions by status and plan
+func Subscriptions(app core.App) (SubscriptionsReport, error) {
+	var rows []struct {
+		PlanID     string `db:"plan_id"`
+		PlanName   string `db:"plan_name"`
+		PriceCents int    `db:"price_cents"`
+		Status     string `db:"status"`
+		Count      int    `db:"count"`
+	}
+	err := app.DB().NewQuery(`SELECT s.plan_id, COALESCE(p.name, '') AS plan_name, COALESCE(p.price_cents, 0) AS price_cents,
+		s.status, COUNT(*) AS count
+		FROM current_user_subscriptions s LEFT JOIN subscription_plans p ON p.id = s.plan_id
+		WHERE ` + realSubscriptions + `
+		GROUP BY s.plan_id, s.status`).All(&rows)
+	if err != nil {
+		return SubscriptionsReport{}, err
+	}
+
+	report := SubscriptionsReport{ByStatus: map[string]int{}, Plans: []PlanSubscriptions{}}
+	plans := map[string]int{}
+	for _, row := range rows {
+		report.ByStatus[row.Status] += row.Count
+		if row.Status != "cancelled" {
+			report.Active += row.Count
+			if row.PriceCents > 0 {
+				report.ActivePaid += row.Count
+			}
+		}
+		i, ok := plans[row.PlanID]
+		if !ok {
+			i = len(report.Plans)
+			plans[row.PlanID] = i
+			report.Plans = append(report.Plans, PlanSubscriptions{PlanID: row.PlanID, PlanName: row.PlanName, Paid: row.PriceCents > 0, ByStatus: map[string]int{}})
+		}
+		report.Plans[i].ByStatus[row.Status] += row.Count
+	}
+	return report, nil
+}
+
+// ChurnedUser is a user whose paid subscription ended in the month without a new paid one
+type ChurnedUser struct {
+	UserID       string `json:"user_id" db:"user_id"`
+	Email        string `json:"email" db:"email"`
+	PlanID       string `json:"plan_id" db:"plan_id"`
+	PlanName     string `json:"plan_name" db:"plan_name"`
+	Reason       string `json:"reason" db:"reason"`
+	ChurnedAt    string `json:"churned_at" db:"churned_at"`
+	LostMRRCents int    `json:"lost_mrr_cents"`
+
+	PriceCents      int    `json:"-" db:"price_cents"`
+	BillingInterval string `json:"-" db:"billing_interval"`
+}
+
+// ChurnReport lists the users who churned in a month
+type ChurnReport struct {
+	Month        string        `json:"month"`
+	Churned      int           `json:"churned"`
+	LostMRRCents int           `json:"lost_mrr_cents"`
+	Users        []ChurnedUser `json:"users"`
+}
+
+// Churn finds users whose paid subscription was cancelled (or switched to the free plan) in the
+// month (YYYY-MM) and who aren't on a paid plan now
+func Churn(app core.App, month time.Time) (ChurnReport, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var rows []ChurnedUser
+	err := app.DB().NewQuery(`SELECT s.user_id, COALESCE(u.email, '') AS email, s.plan_id, p.name AS plan_name,
+		p.price_cents, p.billing_interval, s.replacement_reason AS reason, s.replaced_at AS churned_at
+		FROM subscription_history s JOIN subscription_plans p ON p.id = s.plan_id
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.replaced_at >= {:start} AND s.replaced_at < {:end}
+			AND s.replacement_reason IN ('subscription_cancelled', 'switched_to_free_plan')
+			AND p.price_cents > 0 AND ` + realSubscriptions + `
+			AND NOT EXISTS (SELECT 1 FROM current_user_subscriptions c JOIN subscription_plans cp ON cp.id = c.plan_id
+				WHERE c.user_id = s.user_id AND c.status != 'cancelled' AND cp.price_cents > 0)
+		ORDER BY s.replaced_at`).
+		Bind(dbx.Params{"start": start.Format(dbTimeLayout), "end": start.AddDate(0, 1, 0).Format(dbTimeLayout)}).
+		All(&rows)
+	if err != nil {
+		return ChurnReport{}, err
+	}
+
+	// A user can leave more than one history record in a month - the last one counts
+	report := ChurnReport{Month: start.Format("2006-01"), Users: []ChurnedUser{}}
+	latest := map[string]int{}
+	for _, row := range rows {
+		row.LostMRRCents = monthlyCents(row.PriceCents, row.BillingInterval)
+		if i, ok := latest[row.UserID]; ok {
+			report.Users[i] = row
+			continue
+		}
+		latest[row.UserID] = len(report.Users)
+		report.Users = append(report.Users, row)
+	}
+	for _, user := range report.Users {
+		report.LostMRRCents += user.LostMRRCents
+	}
+	report.Churned = len(report.Users)
+	return report, nil
+}
+
+// DailyMinutes is one day of completed transcriptions
+type DailyMinutes struct {
+	Day     string  `json:"day"`
+	Minutes float64 `json:"minutes"`
+	Files   int     `json:"files"`
+}
+
+// TranscriptionMinutes sums completed transcriptions per day (UTC) over the last days. Chunked
+// uploads count once, through the record they're flattened into
+func TranscriptionMinutes(app core.App, days int, now time.Time) ([]DailyMinutes, error) {
+	start := windowStart(now, days)
+	var rows []struct {
+		Day     string  `db:"day"`
+		Seconds float64 `db:"seconds"`
+		Files   int     `db:"files"`
+	}
+	err := app.DB().NewQuery(`SELECT substr(created, 1, 10) AS day, COALESCE(SUM(duration_seconds), 0) AS seconds, COUNT(*) AS files
+		FROM processed_files WHERE status = 'completed' AND is_chunk = FALSE AND created >= {:start}
+		GROUP BY day`).
+		Bind(dbx.Params{"start": start.Format(dbTimeLayout)}).
+		All(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := map[string]DailyMinutes{}
+	for _, row := range rows {
+		byDay[row.Day] = DailyMinutes{Day: row.Day, Minutes: math.Round(row.Seconds/60*100) / 100, Files: row.Files}
+	}
+	series := make([]DailyMinutes, 0, days)
+	for _, day := range dayKeys(start, days) {
+		entry, ok := byDay[day]
+		if !ok {
+			entry = DailyMinutes{Day: day}
+		}
+		series = append(series, entry)
+	}
+	return series, nil
+}
+
+// TopUser is a user's usage in a month
+type TopUser struct {
+	UserID string  `json:"user_id" db:"user_id"`
+	Email  string  `json:"email" db:"email"`
+	Hours  float64 `json:"hours" db:"hours"`
+	Files  int     `json:"files" db:"files"`
+	Tokens int     `json:"tokens" db:"tokens"`
+}
+
+// TopUsers ranks users by the transcription hours they used in the usage month (YYYY-MM).
+// Hours charged to an organization's pool aren't included
+func TopUsers(app core.App, month string, limit int) ([]TopUser, error) {
+	users := []TopUser{}
+	err := app.DB().NewQuery(`SELECT m.user_id, COALESCE(u.email, '') AS email, COALESCE(SUM(m.hours_used), 0) AS hours,
+		COALESCE(SUM(m.files_processed), 0) AS files, COALESCE(SUM(m.tokens_used), 0) AS tokens
+		FROM monthly_usage m LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.year_month = {:month} AND m.organization_id = ''
+		GROUP BY m.user_id ORDER BY hours DESC LIMIT {:limit}`).
+		Bind(dbx.Params{"month": month, "limit": limit}).
+		All(&users)
+	return users, err
+}
+
+// DailyFailures is one day of finished work
+type DailyFailures struct {
+	Day       string  `json:"day"`
+	Completed int     `json:"completed"`
+	Failed    int     `json:"failed"`
+	Rate      float64 `json:"failure_rate"` // percent
+}
+
+// FailureSeries is the failure rate of one kind of work over a window
+type FailureSeries struct {
+	Completed int             `json:"completed"`
+	Failed    int             `json:"failed"`
+	Rate      float64         `json:"failure_rate"` // percent
+	Days      []DailyFailures `json:"days"`
+}
+
+// FailuresReport is the failure rate of audio processing requests (processed_files, chunks
+// included) and async transcription jobs
+type FailuresReport struct {
+	Days              int           `json:"days"`
+	AudioProcessing   FailureSeries `json:"audio_processing"`
+	TranscriptionJobs FailureSeries `json:"transcription_jobs"`
+}
+
+// Failures computes failure rates per day (UTC) over the last days
+func Failures(app core.App, days int, now time.Time) (FailuresReport, error) {
+	start := windowStart(now, days)
+	report := FailuresReport{Days: days}
+	for _, source := range []struct {
+		collection string
+		series     *FailureSeries
+	}{
+		{"processed_files", &report.AudioProcessing},
+		{"transcription_jobs", &report.TranscriptionJobs},
+	} {
+		var rows []struct {
+			Day       string `db:"day"`
+			Completed int    `db:"completed"`
+			Failed    int    `db:"failed"`
+		}
+		err := app.DB().NewQuery(`SELECT substr(created, 1, 10) AS day,
+			SUM(CASE WHEN status = 'completed' THEN 1 ELSE 0 END) AS completed,
+			SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END) AS failed
+			FROM ` + source.collection + ` WHERE status IN ('completed', 'failed') AND created >= {:start}
+			GROUP BY day`).
+			Bind(dbx.Params{"start": start.Format(dbTimeLayout)}).
+			All(&rows)
+		if err != nil {
+			return FailuresReport{}, err
+		}
+
+		byDay := map[string]DailyFailures{}
+		for _, row := range rows {
+			byDay[row.Day] = DailyFailures{Day: row.Day, Completed: row.Completed, Failed: row.Failed}
+		}
+		*source.series = failureSeries(dayKeys(start, days), byDay)
+	}
+	return report, nil
+}
+
+// failureSeries fills in the window's days and the rates
+func failureSeries(days []string, byDay map[string]DailyFailures) FailureSeries {
+	series := FailureSeries{Days: make([]DailyFailures, 0, len(days))}
+	for _, day := range days {
+		entry := byDay[day]
+		entry.Day = day
+		entry.Rate = rate(entry.Failed, entry.Completed+entry.Failed)
+		series.Completed += entry.Completed
+		series.Failed += entry.Failed
+		series.Days = append(series.Days, entry)
+	}
+	series.Rate = rate(series.Failed, series.Completed+series.Failed)
+	return series
+}
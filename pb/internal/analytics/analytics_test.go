@@ -0,0 +1,94 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMonthlyCents(t *testing.T) {
+	tests := []struct {
+		cents    int
+		interval string
+		want     int
+	}{
+		{700, "month", 700},
+		{7000, "year", 583},
+		{12000, "year", 1000},
+		{700, "free", 0},
+	}
+	for _, tt := range tests {
+		if got := monthlyCents(tt.cents, tt.interval); got != tt.want {
+			t.Errorf("monthlyCents(%d, %q) = %d, want %d", tt.cents, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestRate(t *testing.T) {
+	if got := rate(0, 0); got != 0 {
+		t.Errorf("rate(0, 0) = %v", got)
+	}
+	if got := rate(1, 3); got != 33.33 {
+		t.Errorf("rate(1, 3) = %v", got)
+	}
+	if got := rate(5, 5); got != 100 {
+		t.Errorf("rate(5, 5) = %v", got)
+	}
+}
+
+func TestWindowDays(t *testing.T) {
+	now := time.Date(2026, 3, 2, 15, 4, 5, 0, time.UTC)
+	start := windowStart(now, 3)
+	if !start.Equal(time.Date(2026, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("windowStart() = %v", start)
+	}
+	if got, want := dayKeys(start, 3), []string{"2026-02-28", "2026-03-01", "2026-03-02"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dayKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestFailureSeries(t *testing.T) {
+	series := failureSeries([]string{"2026-03-01", "2026-03-02", "2026-03-03"}, map[string]DailyFailures{
+		"2026-03-01": {Completed: 9, Failed: 1},
+		"2026-03-03": {Completed: 1, Failed: 1},
+	})
+	if series.Completed != 10 || series.Failed != 2 || series.Rate != 16.67 {
+		t.Errorf("totals = %d completed, %d failed, %v%%", series.Completed, series.Failed, series.Rate)
+	}
+	if len(series.Days) != 3 || series.Days[1].Day != "2026-03-02" || series.Days[1].Rate != 0 {
+		t.Errorf("days = %+v", series.Days)
+	}
+	if series.Days[0].Rate != 10 || series.Days[2].Rate != 50 {
+		t.Errorf("daily rates = %v, %v", series.Days[0].Rate, series.Days[2].Rate)
+	}
+}
+
+func TestReportCache(t *testing.T) {
+	t.Setenv("ANALYTICS_CACHE_SECONDS", "60")
+	cache := &reportCache{reports: map[string]cachedReport{}}
+	now := time.Now()
+
+	cache.put("mrr", cachedReport{value: 1, generatedAt: now})
+	if report, ok := cache.get("mrr", time.Minute, now.Add(30*time.Second)); !ok || report.value != 1 {
+		t.Errorf("fresh report should be served: %v, %v", report, ok)
+	}
+	if _, ok := cache.get("mrr", time.Minute, now.Add(time.Minute)); ok {
+		t.Error("expired report shouldn't be served")
+	}
+
+	cache.put("churn", cachedReport{value: 2, generatedAt: now.Add(2 * time.Minute)})
+	if _, ok := cache.reports["mrr"]; ok {
+		t.Error("expired reports should be dropped on put")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	t.Setenv("ANALYTICS_CACHE_SECONDS", "")
+	if got := cacheTTL(); got != defaultCacheTTL {
+		t.Errorf("default = %v", got)
+	}
+	t.Setenv("ANALYTICS_CACHE_SECONDS", "0")
+	if got := cacheTTL(); got != 0 {
+		t.Errorf("disabled = %v", got)
+	}
+}
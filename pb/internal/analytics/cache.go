@@ -0,0 +1,57 @@
+package analytics
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultCacheTTL is how long a computed report is served before it's computed again
+const defaultCacheTTL = 5 * time.Minute
+
+// cacheTTL reads ANALYTICS_CACHE_SECONDS (0 disables caching)
+func cacheTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("ANALYTICS_CACHE_SECONDS")); err == nil && v >= 0 {
+		return time.Duration(v) * time.Second
+	}
+	return defaultCacheTTL
+}
+
+type cachedReport struct {
+	value       any
+	generatedAt time.Time
+}
+
+// reportCache keeps computed reports by metric and parameters. Reports scan whole collections,
+// so dashboard refreshes are answered from here
+type reportCache struct {
+	mu      sync.Mutex
+	reports map[string]cachedReport
+}
+
+var reports = &reportCache{reports: map[string]cachedReport{}}
+
+// get returns the report cached under key, unless it's older than ttl
+func (c *reportCache) get(key string, ttl time.Duration, now time.Time) (cachedReport, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report, ok := c.reports[key]
+	if !ok || now.Sub(report.generatedAt) >= ttl {
+		return cachedReport{}, false
+	}
+	return report, true
+}
+
+func (c *reportCache) put(key string, report cachedReport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Drop expired reports so old months and windows don't pile up
+	ttl := cacheTTL()
+	for k, r := range c.reports {
+		if report.generatedAt.Sub(r.generatedAt) >= ttl {
+			delete(c.reports, k)
+		}
+	}
+	c.reports[key] = report
+}
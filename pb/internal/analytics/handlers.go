@@ -0,0 +1,186 @@
+package analytics
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Defaults and bounds of the report parameters
+const (
+	defaultDays     = 30
+	maxDays         = 366
+	defaultTopUsers = 10
+	maxTopUsers     = 100
+)
+
+// cached answers with the report stored under key, computing it when missing or expired.
+// ?refresh=true computes it again regardless
+func cached(e *core.RequestEvent, key string, compute func() (any, error)) error {
+	now := time.Now()
+	ttl := cacheTTL()
+	if e.Request.URL.Query().Get("refresh") != "true" {
+		if report, ok := reports.get(key, ttl, now); ok {
+			return respond(e, report, true)
+		}
+	}
+
+	value, err := compute()
+	if err != nil {
+		e.App.Logger().Error("Failed to compute analytics", "report", key, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to compute analytics"})
+	}
+	report := cachedReport{value: value, generatedAt: now}
+	if ttl > 0 {
+		reports.put(key, report)
+	}
+	return respond(e, report, false)
+}
+
+func respond(e *core.RequestEvent, report cachedReport, fromCache bool) error {
+	return e.JSON(http.StatusOK, map[string]any{
+		"generated_at": report.generatedAt.UTC(),
+		"cached":       fromCache,
+		"data":         report.value,
+	})
+}
+
+// monthParam reads ?month=YYYY-MM, defaulting to the current month (UTC)
+func monthParam(e *core.RequestEvent) (time.Time, error) {
+	value := e.Request.URL.Query().Get("month")
+	if value == "" {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	month, err := time.Parse("2006-01", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("month must be in YYYY-MM format")
+	}
+	return month, nil
+}
+
+// intParam reads a positive integer query parameter up to max
+func intParam(e *core.RequestEvent, name string, fallback, max int) (int, error) {
+	value := e.Request.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 || parsed > max {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, max)
+	}
+	return parsed, nil
+}
+
+// OverviewHandler returns every report with its default parameters (superusers only)
+// GET /api/admin/analytics
+func OverviewHandler(e *core.RequestEvent, app core.App) error {
+	return cached(e, "overview", func() (any, error) {
+		now := time.Now().UTC()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+		mrr, err := MRR(app)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions, err := Subscriptions(app)
+		if err != nil {
+			return nil, err
+		}
+		churn, err := Churn(app, month)
+		if err != nil {
+			return nil, err
+		}
+		minutes, err := TranscriptionMinutes(app, defaultDays, now)
+		if err != nil {
+			return nil, err
+		}
+		topUsers, err := TopUsers(app, month.Format("2006-01"), defaultTopUsers)
+		if err != nil {
+			return nil, err
+		}
+		failures, err := Failures(app, defaultDays, now)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"mrr":                   mrr,
+			"subscriptions":         subscriptions,
+			"churn":                 churn,
+			"transcription_minutes": minutes,
+			"top_users":             topUsers,
+			"failures":              failures,
+		}, nil
+	})
+}
+
+// MRRHandler returns monthly recurring revenue by plan (superusers only)
+// GET /api/admin/analytics/mrr
+func MRRHandler(e *core.RequestEvent, app core.App) error {
+	return cached(e, "mrr", func() (any, error) {
+		return MRR(app)
+	})
+}
+
+// SubscriptionsHandler returns current subscription counts by status and plan (superusers only)
+// GET /api/admin/analytics/subscriptions
+func SubscriptionsHandler(e *core.RequestEvent, app core.App) error {
+	return cached(e, "subscriptions", func() (any, error) {
+		return Subscriptions(app)
+	})
+}
+
+// ChurnHandler returns the users who churned in a month (superusers only)
+// GET /api/admin/analytics/churn?month=YYYY-MM - defaults to the current month
+func ChurnHandler(e *core.RequestEvent, app core.App) error {
+	month, err := monthParam(e)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return cached(e, "churn:"+month.Format("2006-01"), func() (any, error) {
+		return Churn(app, month)
+	})
+}
+
+// TranscriptionMinutesHandler returns transcription minutes per day (superusers only)
+// GET /api/admin/analytics/transcription-minutes?days=30
+func TranscriptionMinutesHandler(e *core.RequestEvent, app core.App) error {
+	days, err := intParam(e, "days", defaultDays, maxDays)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return cached(e, "transcription-minutes:"+strconv.Itoa(days), func() (any, error) {
+		return TranscriptionMinutes(app, days, time.Now().UTC())
+	})
+}
+
+// TopUsersHandler returns the users with the most transcription hours in a month (superusers only)
+// GET /api/admin/analytics/top-users?month=YYYY-MM&limit=10 - defaults to the current month
+func TopUsersHandler(e *core.RequestEvent, app core.App) error {
+	month, err := monthParam(e)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	limit, err := intParam(e, "limit", defaultTopUsers, maxTopUsers)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return cached(e, fmt.Sprintf("top-users:%s:%d", month.Format("2006-01"), limit), func() (any, error) {
+		return TopUsers(app, month.Format("2006-01"), limit)
+	})
+}
+
+// FailuresHandler returns failure rates of audio processing and transcription jobs (superusers only)
+// GET /api/admin/analytics/failures?days=30
+func FailuresHandler(e *core.RequestEvent, app core.App) error {
+	days, err := intParam(e, "days", defaultDays, maxDays)
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return cached(e, "failures:"+strconv.Itoa(days), func() (any, error) {
+		return Failures(app, days, time.Now().UTC())
+	})
+}
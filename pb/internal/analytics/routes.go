@@ -0,0 +1,38 @@
+package analytics
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the analytics routes (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return OverviewHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics/mrr", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return MRRHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics/subscriptions", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return SubscriptionsHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics/churn", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ChurnHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics/transcription-minutes", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return TranscriptionMinutesHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics/top-users", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return TopUsersHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/analytics/failures", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return FailuresHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,65 @@
+package analytics
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the analytics reports read
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "analytics",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "name", Type: "text"},
+					{Name: "price_cents", Type: "number"},
+					{Name: "currency", Type: "text"},
+					{Name: "billing_interval", Type: "select"},
+				},
+			},
+			{
+				Collection: "current_user_subscriptions",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "plan_id", Type: "relation"},
+					{Name: "status", Type: "select"},
+					{Name: "payment_provider", Type: "select"},
+				},
+			},
+			{
+				Collection: "subscription_history",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "plan_id", Type: "relation"},
+					{Name: "payment_provider", Type: "select"},
+					{Name: "replaced_at", Type: "date"},
+					{Name: "replacement_reason", Type: "select"},
+				},
+			},
+			{
+				Collection: "processed_files",
+				Fields: []schemacheck.Field{
+					{Name: "status", Type: "select"},
+					{Name: "duration_seconds", Type: "number"},
+					{Name: "is_chunk", Type: "bool"},
+				},
+			},
+			{
+				Collection: "transcription_jobs",
+				Fields: []schemacheck.Field{
+					{Name: "status", Type: "select"},
+				},
+			},
+			{
+				Collection: "monthly_usage",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "organization_id", Type: "relation"},
+					{Name: "year_month", Type: "text"},
+					{Name: "hours_used", Type: "number"},
+					{Name: "files_processed", Type: "number"},
+					{Name: "tokens_used", Type: "number"},
+				},
+			},
+		},
+	}
+}
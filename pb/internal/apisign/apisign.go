@@ -0,0 +1,256 @@
+package apisign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/keyguard"
+	"pocketbase/internal/secrets"
+)
+
+// Headers carried by signed requests
+const (
+	HeaderKeyID     = "X-Signature-Key-Id"    // id of the api_keys record
+	HeaderTimestamp = "X-Signature-Timestamp" // unix seconds
+	HeaderSignature = "X-Signature"           // hex HMAC-SHA256 of the canonical request
+)
+
+// SigningSecretField is stored encrypted - see the secrets package
+var SigningSecretField = secrets.Field{Collection: "api_keys", Name: "signing_secret"}
+
+// MaxClockSkew is how far a request timestamp may drift from server time
+// Signatures are remembered for this long on either side, so a captured request can't be replayed
+const MaxClockSkew = 5 * time.Minute
+
+// memoryBodyLimit is the largest body hashed in memory - larger ones (audio uploads) spool to disk
+const memoryBodyLimit = 10 << 20
+
+// Keys storing the verified user and API key on the request event
+const (
+	signedUserKey = "apisign.user"
+	signedKeyKey  = "apisign.key"
+)
+
+// CanonicalRequest is the string a client signs:
+// timestamp, method, path with query, and the hex SHA-256 of the body, joined by newlines
+func CanonicalRequest(timestamp, method, pathAndQuery, bodySHA256 string) string {
+	return strings.Join([]string{timestamp, strings.ToUpper(method), pathAndQuery, bodySHA256}, "\n")
+}
+
+// Sign returns the hex HMAC-SHA256 of a canonical request
+func Sign(secret, canonical string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// replayCache remembers signatures seen within the skew window
+type replayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // signature -> when it can be forgotten
+}
+
+var replays = &replayCache{seen: map[string]time.Time{}}
+
+// remember records a signature, reporting false if it was already used
+func (c *replayCache) remember(signature string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for sig, expires := range c.seen {
+		if now.After(expires) {
+			delete(c.seen, sig)
+		}
+	}
+	if _, ok := c.seen[signature]; ok {
+		return false
+	}
+	c.seen[signature] = now.Add(2 * MaxClockSkew)
+	return true
+}
+
+// Middleware verifies signed requests and leaves everything else untouched, so Bearer API keys
+// keep working. A request that carries a signature but fails verification is rejected outright
+func Middleware(app core.App) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		if e.Request.Header.Get(HeaderSignature) == "" {
+			return e.Next()
+		}
+
+		cleanup, err := verify(app, e, time.Now())
+		if cleanup != nil {
+			defer cleanup()
+		}
+		// A body cut off by the route's body limit isn't a bad signature
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return e.JSON(http.StatusRequestEntityTooLarge, map[string]any{
+				"error":       fmt.Sprintf("Request body is limited to %d MB", maxBytesErr.Limit>>20),
+				"code":        "UPLOAD_TOO_LARGE",
+				"limit_bytes": maxBytesErr.Limit,
+				"limit_mb":    maxBytesErr.Limit >> 20,
+			})
+		}
+		if err != nil {
+			log.Printf("❌ [SIGNED REQUEST] FAILED: %v | Key: %s | Path: %s", err, e.Request.Header.Get(HeaderKeyID), e.Request.URL.Path)
+			return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid request signature", "code": "INVALID_SIGNATURE"})
+		}
+
+		return e.Next()
+	}
+}
+
+// SignedUser returns the user whose API key signed the request, or nil for unsigned requests
+func SignedUser(e *core.RequestEvent) *core.Record {
+	user, _ := e.Get(signedUserKey).(*core.Record)
+	return user
+}
+
+// SignedKey returns the api_keys record that signed the request, or nil for unsigned requests
+func SignedKey(e *core.RequestEvent) *core.Record {
+	key, _ := e.Get(signedKeyKey).(*core.Record)
+	return key
+}
+
+// verify checks the signature headers against the key's signing secret and the request body
+// The returned cleanup removes any spooled body once the request is done
+func verify(app core.App, e *core.RequestEvent, now time.Time) (func(), error) {
+	keyID := e.Request.Header.Get(HeaderKeyID)
+	timestamp := e.Request.Header.Get(HeaderTimestamp)
+	signature := strings.ToLower(e.Request.Header.Get(HeaderSignature))
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); math.Abs(skew.Seconds()) > MaxClockSkew.Seconds() {
+		return nil, fmt.Errorf("timestamp outside the allowed window (skew %v)", skew.Round(time.Second))
+	}
+
+	key, err := app.FindRecordById("api_keys", keyID)
+	if err != nil || !key.GetBool("active") || key.GetString("signing_secret") == "" {
+		return nil, fmt.Errorf("unknown key or signing not enabled")
+	}
+
+	secret, err := secrets.Open(key.GetString("signing_secret"))
+	if err != nil {
+		log.Printf("❌ [SIGNED REQUEST] Cannot decrypt signing secret for key %s: %v", key.Id, err)
+		return nil, fmt.Errorf("signing secret unavailable")
+	}
+
+	bodyHash, cleanup, err := hashBody(e.Request)
+	if err != nil {
+		return cleanup, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	canonical := CanonicalRequest(timestamp, e.Request.Method, e.Request.URL.RequestURI(), bodyHash)
+	expected := Sign(secret, canonical)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return cleanup, fmt.Errorf("signature mismatch")
+	}
+
+	// Checked after the signature so forged requests can't poison the cache
+	if !replays.remember(signature, now) {
+		return cleanup, fmt.Errorf("signature already used")
+	}
+
+	user, err := app.FindRecordById("users", key.GetString("user_id"))
+	if err != nil {
+		return cleanup, fmt.Errorf("user not found")
+	}
+	if accounts.IsDeactivated(user) {
+		return cleanup, fmt.Errorf("account is deactivated")
+	}
+	if err := keyguard.Check(app, key, e.Request); err != nil {
+		return cleanup, err
+	}
+
+	e.Set(signedUserKey, user)
+	e.Set(signedKeyKey, key)
+	return cleanup, nil
+}
+
+// hashBody returns the hex SHA-256 of the request body and replaces the body so handlers can still read it
+func hashBody(r *http.Request) (string, func(), error) {
+	hash := sha256.New()
+	if r.Body == nil || r.Body == http.NoBody {
+		return hex.EncodeToString(hash.Sum(nil)), nil, nil
+	}
+
+	if r.ContentLength >= 0 && r.ContentLength <= memoryBodyLimit {
+		body, err := io.ReadAll(io.TeeReader(r.Body, hash))
+		if err != nil {
+			return "", nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return hex.EncodeToString(hash.Sum(nil)), nil, nil
+	}
+
+	spool, err := os.CreateTemp("", "signed-body-*")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+	if _, err := io.Copy(io.MultiWriter(spool, hash), r.Body); err != nil {
+		return "", cleanup, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return "", cleanup, err
+	}
+	r.Body = spool
+	return hex.EncodeToString(hash.Sum(nil)), cleanup, nil
+}
+
+// EnableSigningHandler issues a new signing secret for one of the caller's API keys
+// The secret is only returned here - rotating it invalidates the previous one
+// POST /api/api-keys/{id}/signing-secret
+func EnableSigningHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	key, err := app.FindRecordById("api_keys", e.Request.PathValue("id"))
+	if err != nil || key.GetString("user_id") != e.Auth.Id || !key.GetBool("active") {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+
+	secret := "rs-" + security.RandomString(40)
+	sealed, err := secrets.Seal(secret)
+	if err != nil {
+		log.Printf("❌ [SIGNED REQUEST] Cannot encrypt signing secret | Key: %s | Error: %v", key.Id, err)
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Signing is not available right now"})
+	}
+	key.Set("signing_secret", sealed)
+	if err := app.Save(key); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save signing secret"})
+	}
+
+	log.Printf("🔏 [SIGNED REQUEST] Signing enabled | Key: %s | User: %s", key.Id, e.Auth.GetString("email"))
+
+	// The only time the secret is shown - afterwards the API returns a masked preview
+	return e.JSON(http.StatusOK, map[string]string{
+		"key_id":         key.Id,
+		"signing_secret": secret,
+		"message":        "Sign requests with HMAC-SHA256 over timestamp, method, path and body hash",
+	})
+}
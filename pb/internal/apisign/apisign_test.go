@@ -0,0 +1,78 @@
+package apisign
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignIsStableForCanonicalRequest(t *testing.T) {
+	canonical := CanonicalRequest("1700000000", "post", "/api/ai/process-text?x=1", "abc")
+	if canonical != "1700000000\nPOST\n/api/ai/process-text?x=1\nabc" {
+		t.Fatalf("unexpected canonical request %q", canonical)
+	}
+	if Sign("secret", canonical) != Sign("secret", canonical) || Sign("secret", canonical) == Sign("other", canonical) {
+		t.Fatal("signature should depend only on secret and canonical request")
+	}
+}
+
+func TestReplayCacheRejectsReuse(t *testing.T) {
+	cache := &replayCache{seen: map[string]time.Time{}}
+	now := time.Now()
+
+	if !cache.remember("sig", now) {
+		t.Fatal("first use should be accepted")
+	}
+	if cache.remember("sig", now.Add(time.Minute)) {
+		t.Fatal("replay inside the window should be rejected")
+	}
+	if !cache.remember("sig", now.Add(3*MaxClockSkew)) {
+		t.Fatal("signature should be forgotten once its timestamp can no longer pass the skew check")
+	}
+}
+
+func TestHashBodyKeepsBodyReadable(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/ai/process-text", strings.NewReader(`{"user_prompt":"hi"}`))
+
+	hash, cleanup, err := hashBody(req)
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(`{"user_prompt":"hi"}`))
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected body hash %s", hash)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"user_prompt":"hi"}` {
+		t.Fatalf("body not restored: %q", body)
+	}
+}
+
+func TestHashBodySpoolsLargeBodies(t *testing.T) {
+	payload := strings.Repeat("a", memoryBodyLimit+1)
+	req := httptest.NewRequest("POST", "/api/ai/process-audio", strings.NewReader(payload))
+
+	hash, cleanup, err := hashBody(req)
+	if cleanup == nil {
+		t.Fatal("expected a spooled body to clean up")
+	}
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(payload))
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Fatal("unexpected body hash")
+	}
+	if body, _ := io.ReadAll(req.Body); len(body) != len(payload) {
+		t.Fatalf("spooled body has %d bytes, want %d", len(body), len(payload))
+	}
+}
@@ -0,0 +1,20 @@
+package apisign
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds opt-in HMAC signing for server-side callers - it coexists with Bearer API keys
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/api-keys/{id}/signing-secret", Handler: func(e *core.RequestEvent) error {
+			return EnableSigningHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,173 @@
+// Package audioprep shrinks audio with ffmpeg before it's sent to a transcription provider:
+// downmixed to mono, resampled to 16kHz (what Whisper works at anyway) and compressed to Opus in
+// an Ogg container. An hour of uncompressed WAV drops from ~600MB to ~15MB, under Whisper's 25MB limit
+package audioprep
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Modes read from AUDIO_PREPROCESS
+const (
+	ModeAuto   = "auto"   // uploads over the threshold, unless the request opts out
+	ModeAlways = "always" // every upload, unless the request opts out
+	ModeOff    = "off"    // never, whatever the request asks
+)
+
+// Config controls when and how audio is compressed
+type Config struct {
+	Mode           string
+	ThresholdBytes int64  // auto mode compresses uploads larger than this
+	BitrateKbps    int    // Opus bitrate; 24-32kbps is transparent for speech
+	FFmpegPath     string // resolved path, "" when ffmpeg isn't installed
+}
+
+// ConfigFromEnv reads AUDIO_PREPROCESS (auto, always or off; default auto),
+// AUDIO_PREPROCESS_THRESHOLD_MB (default 10), AUDIO_PREPROCESS_BITRATE_KBPS (default 32)
+// and FFMPEG_PATH (default ffmpeg on the PATH)
+func ConfigFromEnv() Config {
+	config := Config{Mode: ModeAuto, ThresholdBytes: 10 << 20, BitrateKbps: 32}
+	switch mode := strings.ToLower(strings.TrimSpace(os.Getenv("AUDIO_PREPROCESS"))); mode {
+	case ModeAlways, ModeOff:
+		config.Mode = mode
+	}
+	if v, err := strconv.ParseInt(os.Getenv("AUDIO_PREPROCESS_THRESHOLD_MB"), 10, 64); err == nil && v >= 0 {
+		config.ThresholdBytes = v << 20
+	}
+	if v, err := strconv.Atoi(os.Getenv("AUDIO_PREPROCESS_BITRATE_KBPS")); err == nil && v > 0 {
+		config.BitrateKbps = v
+	}
+
+	ffmpeg := os.Getenv("FFMPEG_PATH")
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	if path, err := exec.LookPath(ffmpeg); err == nil {
+		config.FFmpegPath = path
+	}
+	return config
+}
+
+// Available reports whether audio can be compressed at all
+func (c Config) Available() bool {
+	return c.Mode != ModeOff && c.FFmpegPath != ""
+}
+
+// ShouldCompress decides for one upload. requested is the request's preprocess flag, nil when
+// the client didn't send one
+func (c Config) ShouldCompress(requested *bool, sizeBytes int64) bool {
+	if !c.Available() {
+		return false
+	}
+	if requested != nil {
+		return *requested
+	}
+	return c.Mode == ModeAlways || sizeBytes > c.ThresholdBytes
+}
+
+// Compressed is audio written by Compress. Close removes its temp file
+type Compressed struct {
+	*os.File
+	SizeBytes int64
+}
+
+// Close closes and removes the compressed audio
+func (c *Compressed) Close() error {
+	err := c.File.Close()
+	os.Remove(c.File.Name())
+	return err
+}
+
+// Compress transcodes src, returning the compressed audio at its start. Files are read by ffmpeg
+// in place; anything else is copied to a temp file first, as MP4 needs a seekable input.
+// src may be left anywhere. The caller closes the result
+func (c Config) Compress(ctx context.Context, src io.Reader) (*Compressed, error) {
+	if c.FFmpegPath == "" {
+		return nil, fmt.Errorf("ffmpeg is not installed")
+	}
+
+	input, cleanup, err := stage(src)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return c.encode(ctx, input, nil)
+}
+
+// stage returns the path of a file holding src, and a cleanup removing it when it was copied
+func stage(src io.Reader) (string, func(), error) {
+	if file, ok := src.(*os.File); ok {
+		return file.Name(), func() {}, nil
+	}
+	staged, err := os.CreateTemp("", "audioprep-in-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	cleanup := func() {
+		staged.Close()
+		os.Remove(staged.Name())
+	}
+	if _, err := io.Copy(staged, src); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to stage audio: %w", err)
+	}
+	return staged.Name(), cleanup, nil
+}
+
+// encode runs ffmpeg over input into a new temp file; inputArgs go before -i, e.g. to trim it
+func (c Config) encode(ctx context.Context, input string, inputArgs []string) (*Compressed, error) {
+	output, err := os.CreateTemp("", "audioprep-out-*.ogg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	compressed := &Compressed{File: output}
+
+	if _, err := c.run(ctx, c.args(input, output.Name(), inputArgs)...); err != nil {
+		compressed.Close()
+		return nil, err
+	}
+
+	info, err := output.Stat()
+	if err != nil {
+		compressed.Close()
+		return nil, fmt.Errorf("failed to read compressed audio: %w", err)
+	}
+	compressed.SizeBytes = info.Size()
+	return compressed, nil
+}
+
+// run runs ffmpeg and returns what it logged
+func (c Config) run(ctx context.Context, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.FFmpegPath, args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %w: %s", err, lastLine(stderr.String()))
+	}
+	return stderr.String(), nil
+}
+
+// args drops any video or cover art stream, downmixes to mono at 16kHz and encodes Opus tuned for speech
+func (c Config) args(input, output string, inputArgs []string) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin", "-y"}
+	args = append(args, inputArgs...)
+	return append(args,
+		"-i", input,
+		"-vn", "-ac", "1", "-ar", "16000",
+		"-c:a", "libopus", "-b:a", strconv.Itoa(c.BitrateKbps)+"k", "-application", "voip",
+		"-f", "ogg", output,
+	)
+}
+
+// lastLine is the last non-empty line of ffmpeg's log, usually the error
+func lastLine(log string) string {
+	lines := strings.Split(strings.TrimSpace(log), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
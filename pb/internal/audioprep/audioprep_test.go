@@ -0,0 +1,91 @@
+package audioprep
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestShouldCompress(t *testing.T) {
+	yes, no := true, false
+	auto := Config{Mode: ModeAuto, ThresholdBytes: 10 << 20, FFmpegPath: "/usr/bin/ffmpeg"}
+
+	tests := []struct {
+		name      string
+		config    Config
+		requested *bool
+		size      int64
+		want      bool
+	}{
+		{"auto, small upload", auto, nil, 1 << 20, false},
+		{"auto, large upload", auto, nil, 50 << 20, true},
+		{"auto, request opts in", auto, &yes, 1 << 20, true},
+		{"auto, request opts out", auto, &no, 50 << 20, false},
+		{"always", Config{Mode: ModeAlways, ThresholdBytes: 10 << 20, FFmpegPath: "ffmpeg"}, nil, 1, true},
+		{"off ignores the request", Config{Mode: ModeOff, FFmpegPath: "ffmpeg"}, &yes, 50 << 20, false},
+		{"no ffmpeg", Config{Mode: ModeAlways}, &yes, 50 << 20, false},
+	}
+	for _, tt := range tests {
+		if got := tt.config.ShouldCompress(tt.requested, tt.size); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("AUDIO_PREPROCESS", "ALWAYS")
+	t.Setenv("AUDIO_PREPROCESS_THRESHOLD_MB", "0")
+	t.Setenv("AUDIO_PREPROCESS_BITRATE_KBPS", "nope")
+	t.Setenv("FFMPEG_PATH", "/nonexistent/ffmpeg")
+
+	config := ConfigFromEnv()
+	if config.Mode != ModeAlways || config.ThresholdBytes != 0 || config.BitrateKbps != 32 {
+		t.Errorf("Unexpected config: %+v", config)
+	}
+	if config.FFmpegPath != "" || config.Available() {
+		t.Errorf("Expected ffmpeg to be unavailable, got %q", config.FFmpegPath)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	config := ConfigFromEnv()
+	if config.FFmpegPath == "" {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	compressed, err := config.Compress(context.Background(), bytes.NewReader(silentWAV(44100, 2, 5)))
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	defer compressed.Close()
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(compressed, header); err != nil || string(header) != "OggS" {
+		t.Errorf("Expected Ogg output, got %q (%v)", header, err)
+	}
+	if compressed.SizeBytes <= 0 || compressed.SizeBytes >= int64(len(silentWAV(44100, 2, 5))) {
+		t.Errorf("Expected smaller output, got %d bytes", compressed.SizeBytes)
+	}
+}
+
+// silentWAV builds a 16-bit PCM WAV file of the given length
+func silentWAV(sampleRate, channels, seconds int) []byte {
+	dataSize := sampleRate * channels * 2 * seconds
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*channels*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&buf, binary.LittleEndian, uint16(16))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	buf.Write(make([]byte, dataSize))
+	return buf.Bytes()
+}
@@ -0,0 +1,140 @@
+package audioprep
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Silence is a quiet stretch of audio, in seconds from the start
+type Silence struct {
+	Start float64
+	End   float64
+}
+
+// Segment is one piece of a split file, encoded like Compress output. Start and Duration place
+// it on the original file's timeline
+type Segment struct {
+	*Compressed
+	Start    float64
+	Duration float64
+}
+
+// Silences quieter than -30dB and longer than half a second are cut candidates
+const silenceFilter = "silencedetect=noise=-30dB:d=0.5"
+
+// segmentMargin keeps segments under the size limit when Opus runs above its nominal bitrate
+const segmentMargin = 0.9
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?[0-9.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: ([0-9.]+)`)
+	progressTimePattern = regexp.MustCompile(`time=(\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+)
+
+// Split cuts src into segments of at most maxBytes each, preferably in silences so no word is
+// cut in half. Files are read in place; anything else is staged first. The caller closes the
+// segments; on error none are left behind
+func (c Config) Split(ctx context.Context, src io.Reader, maxBytes int64) ([]*Segment, error) {
+	if c.FFmpegPath == "" {
+		return nil, fmt.Errorf("ffmpeg is not installed")
+	}
+	maxSeconds := float64(maxBytes) * 8 / float64(c.BitrateKbps*1000) * segmentMargin
+	if maxSeconds < 1 {
+		return nil, fmt.Errorf("a %d byte limit is too small to split audio at %dkbps", maxBytes, c.BitrateKbps)
+	}
+
+	input, cleanup, err := stage(src)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	// Decoding the whole file once finds the silences and the real duration, which
+	// MediaRecorder WebM doesn't record in its headers
+	log, err := c.run(ctx, "-hide_banner", "-nostdin", "-i", input, "-vn", "-af", silenceFilter, "-f", "null", "-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan audio: %w", err)
+	}
+	duration := decodedDuration(log)
+	if duration <= 0 {
+		return nil, fmt.Errorf("failed to scan audio: no duration in ffmpeg output")
+	}
+
+	cuts := CutPoints(duration, maxSeconds, parseSilences(log))
+	bounds := append(append([]float64{0}, cuts...), duration)
+	segments := make([]*Segment, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		start, length := bounds[i], bounds[i+1]-bounds[i]
+		encoded, err := c.encode(ctx, input, []string{
+			"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+			"-t", strconv.FormatFloat(length, 'f', 3, 64),
+		})
+		if err != nil {
+			for _, segment := range segments {
+				segment.Close()
+			}
+			return nil, fmt.Errorf("failed to write segment %d: %w", i, err)
+		}
+		segments = append(segments, &Segment{Compressed: encoded, Start: start, Duration: length})
+	}
+	return segments, nil
+}
+
+// CutPoints returns where to split audio of the given duration so no segment runs longer than
+// maxSeconds. Each cut goes in the middle of the latest silence in the second half of its
+// segment, or at the limit when that half has none
+func CutPoints(duration, maxSeconds float64, silences []Silence) []float64 {
+	var cuts []float64
+	start := 0.0
+	for duration-start > maxSeconds {
+		limit := start + maxSeconds
+		cut := limit
+		for _, silence := range silences {
+			middle := (silence.Start + silence.End) / 2
+			if middle > start+maxSeconds/2 && middle <= limit {
+				cut = middle
+			}
+		}
+		cuts = append(cuts, cut)
+		start = cut
+	}
+	return cuts
+}
+
+// parseSilences reads the silences silencedetect logged, in order. A silence still running at
+// the end of the file has no end and can't be cut in, so it's dropped
+func parseSilences(log string) []Silence {
+	var silences []Silence
+	var start float64
+	open := false
+	// Progress lines end in \r, so log lines can share a \n-terminated line with them
+	lines := strings.FieldsFunc(log, func(r rune) bool { return r == '\n' || r == '\r' })
+	for _, line := range lines {
+		if match := silenceStartPattern.FindStringSubmatch(line); match != nil {
+			start, _ = strconv.ParseFloat(match[1], 64)
+			open = true
+		} else if match := silenceEndPattern.FindStringSubmatch(line); match != nil && open {
+			end, _ := strconv.ParseFloat(match[1], 64)
+			silences = append(silences, Silence{Start: max(start, 0), End: end})
+			open = false
+		}
+	}
+	return silences
+}
+
+// decodedDuration is the last progress time ffmpeg logged while decoding, in seconds
+func decodedDuration(log string) float64 {
+	matches := progressTimePattern.FindAllStringSubmatch(log, -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	last := matches[len(matches)-1]
+	hours, _ := strconv.Atoi(last[1])
+	minutes, _ := strconv.Atoi(last[2])
+	seconds, _ := strconv.ParseFloat(last[3], 64)
+	return float64(hours*3600+minutes*60) + seconds
+}
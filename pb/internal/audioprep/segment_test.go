@@ -0,0 +1,84 @@
+package audioprep
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestCutPoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		duration float64
+		silences []Silence
+		want     []float64
+	}{
+		{"fits in one segment", 90, nil, nil},
+		{"no silences cuts at the limit", 250, nil, []float64{100, 200}},
+		{"latest silence in the second half", 250, []Silence{{60, 62}, {80, 84}, {120, 130}}, []float64{82, 182}},
+		{"silence in the first half is too early", 150, []Silence{{10, 12}}, []float64{100}},
+		{"silence past the limit is too late", 150, []Silence{{99, 103}}, []float64{100}},
+	}
+	for _, tt := range tests {
+		if got := CutPoints(tt.duration, 100, tt.silences); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSilences(t *testing.T) {
+	log := "[silencedetect @ 0x1] silence_start: -0.01\n" +
+		"[silencedetect @ 0x1] silence_end: 1.5 | silence_duration: 1.51\n" +
+		"size=N/A time=00:00:05.00 bitrate=N/A\r[silencedetect @ 0x1] silence_start: 10.25\r" +
+		"[silencedetect @ 0x1] silence_end: 11 | silence_duration: 0.75\n" +
+		"[silencedetect @ 0x1] silence_start: 58\n"
+
+	want := []Silence{{0, 1.5}, {10.25, 11}}
+	if got := parseSilences(log); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodedDuration(t *testing.T) {
+	log := "size=N/A time=00:00:30.50 bitrate=N/A\rsize=N/A time=01:02:03.25 bitrate=N/A speed=900x\n"
+	if got := decodedDuration(log); math.Abs(got-3723.25) > 1e-9 {
+		t.Errorf("got %v, want 3723.25", got)
+	}
+	if got := decodedDuration("no progress"); got != 0 {
+		t.Errorf("got %v without progress, want 0", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	config := ConfigFromEnv()
+	if config.FFmpegPath == "" {
+		t.Skip("ffmpeg is not installed")
+	}
+
+	// 12s at 32kbps is ~48KB; a 20KB limit allows ~4.5s per segment
+	segments, err := config.Split(context.Background(), bytes.NewReader(silentWAV(16000, 1, 12)), 20_000)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+	defer func() {
+		for _, segment := range segments {
+			segment.Close()
+		}
+	}()
+
+	if len(segments) < 3 {
+		t.Fatalf("Expected at least 3 segments, got %d", len(segments))
+	}
+	end := 0.0
+	for i, segment := range segments {
+		if math.Abs(segment.Start-end) > 1e-9 {
+			t.Errorf("Segment %d starts at %v, want %v", i, segment.Start, end)
+		}
+		end = segment.Start + segment.Duration
+	}
+	if math.Abs(end-12) > 0.1 {
+		t.Errorf("Segments end at %v, want 12", end)
+	}
+}
@@ -0,0 +1,457 @@
+package audioprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// probeMP3 walks the MPEG frame headers; go-mp3 computes the length without decoding when r seeks
+func probeMP3(r io.ReadSeeker) (*Info, error) {
+	decoder, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	sampleRate := decoder.SampleRate()
+	if sampleRate == 0 || decoder.Length() <= 0 {
+		return nil, errors.New("no MPEG frames found")
+	}
+	// go-mp3 always decodes to 16-bit stereo, 4 bytes per sample
+	return &Info{
+		Codec:           "mp3",
+		DurationSeconds: float64(decoder.Length()) / 4 / float64(sampleRate),
+		SampleRate:      sampleRate,
+	}, nil
+}
+
+// probeWAV reads the fmt chunk and divides the data chunk size by the byte rate
+func probeWAV(r io.ReadSeeker) (*Info, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	var byteRate uint32
+	chunkHeader := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			return nil, errors.New("no data chunk")
+		}
+		id, size := string(chunkHeader[:4]), binary.LittleEndian.Uint32(chunkHeader[4:])
+
+		switch id {
+		case "fmt ":
+			format := make([]byte, 16)
+			if size < 16 {
+				return nil, errors.New("fmt chunk too short")
+			}
+			if _, err := io.ReadFull(r, format); err != nil {
+				return nil, err
+			}
+			info.Codec = wavCodec(binary.LittleEndian.Uint16(format[0:]))
+			info.Channels = int(binary.LittleEndian.Uint16(format[2:]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(format[4:]))
+			byteRate = binary.LittleEndian.Uint32(format[8:])
+			if _, err := r.Seek(int64(size-16+size%2), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		case "data":
+			if byteRate == 0 {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			info.DurationSeconds = float64(size) / float64(byteRate)
+			return info, nil
+		default:
+			// Chunks are padded to an even size
+			if _, err := r.Seek(int64(size+size%2), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func wavCodec(formatTag uint16) string {
+	switch formatTag {
+	case 1:
+		return "pcm"
+	case 3:
+		return "pcm_float"
+	case 6:
+		return "alaw"
+	case 7:
+		return "mulaw"
+	case 0xFFFE:
+		return "pcm_extensible"
+	}
+	return fmt.Sprintf("wav_0x%04x", formatTag)
+}
+
+// probeFLAC reads the STREAMINFO block, which always follows the fLaC marker
+func probeFLAC(r io.ReadSeeker) (*Info, error) {
+	block := make([]byte, 4+4+34)
+	if _, err := io.ReadFull(r, block); err != nil {
+		return nil, err
+	}
+	if block[4]&0x7F != 0 {
+		return nil, errors.New("first metadata block isn't STREAMINFO")
+	}
+
+	streamInfo := block[8:]
+	// Bits 80-99: sample rate, 100-102: channels - 1, 103-107: bits per sample - 1, 108-143: total samples
+	packed := binary.BigEndian.Uint64(streamInfo[10:18])
+	sampleRate := int(packed >> 44)
+	channels := int(packed>>41&0x7) + 1
+	totalSamples := packed & 0xFFFFFFFFF
+	if sampleRate == 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+
+	return &Info{
+		Codec:           "flac",
+		DurationSeconds: float64(totalSamples) / float64(sampleRate),
+		SampleRate:      sampleRate,
+		Channels:        channels,
+	}, nil
+}
+
+// probeOgg reads the codec from the first page and the duration from the granule position of
+// the last page, which counts samples (48kHz for Opus, after its pre-skip)
+func probeOgg(r io.ReadSeeker) (*Info, error) {
+	first := make([]byte, 27+255+19)
+	n, _ := io.ReadFull(r, first)
+	first = first[:n]
+	if len(first) < 27 {
+		return nil, errors.New("truncated page")
+	}
+	packet := first[27+int(first[26]):]
+
+	info := &Info{}
+	var preSkip uint16
+	clockRate := 0
+	switch {
+	case bytes.HasPrefix(packet, []byte("OpusHead")) && len(packet) >= 16:
+		info.Codec = "opus"
+		info.Channels = int(packet[9])
+		preSkip = binary.LittleEndian.Uint16(packet[10:])
+		info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+		clockRate = 48000
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")) && len(packet) >= 16:
+		info.Codec = "vorbis"
+		info.Channels = int(packet[11])
+		info.SampleRate = int(binary.LittleEndian.Uint32(packet[12:]))
+		clockRate = info.SampleRate
+	default:
+		return nil, errors.New("unknown Ogg codec")
+	}
+	if clockRate == 0 {
+		return nil, errors.New("invalid sample rate")
+	}
+
+	granule, err := lastOggGranule(r)
+	if err != nil {
+		return nil, err
+	}
+	samples := float64(granule) - float64(preSkip)
+	info.DurationSeconds = math.Max(samples, 0) / float64(clockRate)
+	return info, nil
+}
+
+// lastOggGranule finds the granule position of the last page in the final 64KB of the file
+func lastOggGranule(r io.ReadSeeker) (int64, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	start := size - 64*1024
+	if start < 0 {
+		start = 0
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	last := bytes.LastIndex(tail, []byte("OggS"))
+	if last < 0 || len(tail)-last < 14 {
+		return 0, errors.New("no final page")
+	}
+	return int64(binary.LittleEndian.Uint64(tail[last+6:])), nil
+}
+
+// probeMP4 walks the box tree to moov/mvhd for the duration and to the first sample entry for the codec
+func probeMP4(r io.ReadSeeker) (*Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	found := false
+	var walk func(start, end int64) error
+	walk = func(start, end int64) error {
+		for offset := start; offset+8 <= end; {
+			boxType, boxSize, headerSize, err := readBoxHeader(r, offset, end)
+			if err != nil {
+				return err
+			}
+
+			body := offset + headerSize
+			switch boxType {
+			case "moov", "trak", "mdia", "minf", "stbl":
+				if err := walk(body, offset+boxSize); err != nil {
+					return err
+				}
+			case "mvhd":
+				if err := readMVHD(r, body, info); err != nil {
+					return err
+				}
+				found = true
+			case "stsd":
+				if info.Codec == "" {
+					readSampleEntry(r, body, info)
+				}
+			}
+			offset += boxSize
+		}
+		return nil
+	}
+
+	if err := walk(0, size); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("no movie header")
+	}
+	return info, nil
+}
+
+// readBoxHeader returns an MP4 box's type, total size and header size
+func readBoxHeader(r io.ReadSeeker, offset, end int64) (string, int64, int64, error) {
+	header := make([]byte, 16)
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return "", 0, 0, err
+	}
+	if _, err := io.ReadFull(r, header[:8]); err != nil {
+		return "", 0, 0, err
+	}
+
+	boxSize := int64(binary.BigEndian.Uint32(header))
+	headerSize := int64(8)
+	switch boxSize {
+	case 0: // extends to the end of the file
+		boxSize = end - offset
+	case 1: // 64-bit size follows the type
+		if _, err := io.ReadFull(r, header[8:16]); err != nil {
+			return "", 0, 0, err
+		}
+		boxSize = int64(binary.BigEndian.Uint64(header[8:]))
+		headerSize = 16
+	}
+	if boxSize < headerSize || offset+boxSize > end {
+		return "", 0, 0, fmt.Errorf("invalid %q box size", header[4:8])
+	}
+	return string(header[4:8]), boxSize, headerSize, nil
+}
+
+func readMVHD(r io.ReadSeeker, body int64, info *Info) error {
+	header := make([]byte, 32)
+	if _, err := r.Seek(body, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+
+	var timescale uint32
+	var duration uint64
+	if header[0] == 1 {
+		// version 1: 64-bit creation and modification times and duration
+		timescale = binary.BigEndian.Uint32(header[20:])
+		duration = binary.BigEndian.Uint64(header[24:])
+	} else {
+		timescale = binary.BigEndian.Uint32(header[12:])
+		duration = uint64(binary.BigEndian.Uint32(header[16:]))
+	}
+	if timescale == 0 {
+		return errors.New("invalid timescale")
+	}
+	info.DurationSeconds = float64(duration) / float64(timescale)
+	return nil
+}
+
+// readSampleEntry reads the codec, channels and sample rate of the first audio sample entry
+func readSampleEntry(r io.ReadSeeker, body int64, info *Info) {
+	entry := make([]byte, 8+8+28)
+	if _, err := r.Seek(body, io.SeekStart); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(r, entry); err != nil {
+		return
+	}
+
+	// version/flags and entry count, then the entry's size and format
+	format := string(entry[12:16])
+	switch format {
+	case "mp4a":
+		info.Codec = "aac"
+	case "alac", "Opus", "fLaC", "ac-3", "ec-3":
+		info.Codec = format
+	default:
+		return // a video track
+	}
+	sampleEntry := entry[16:]
+	info.Channels = int(binary.BigEndian.Uint16(sampleEntry[16:]))
+	info.SampleRate = int(binary.BigEndian.Uint32(sampleEntry[24:]) >> 16)
+}
+
+// EBML element ids used by probeWebM
+const (
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlCluster       = 0x1F43B675
+)
+
+// probeWebM reads Segment/Info for the duration. Browsers' MediaRecorder doesn't write one,
+// so a missing duration isn't an error
+func probeWebM(r io.ReadSeeker) (*Info, error) {
+	info := &Info{Codec: "webm"}
+	br := &countingReader{r: r}
+
+	// EBML header
+	id, size, err := readEBMLElement(br)
+	if err != nil {
+		return nil, err
+	}
+	if id != 0x1A45DFA3 {
+		return nil, errors.New("missing EBML header")
+	}
+	if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+		return nil, err
+	}
+
+	if id, _, err = readEBMLElement(br); err != nil || id != ebmlSegment {
+		return nil, errors.New("missing segment")
+	}
+
+	// Segment children until Info or the first Cluster
+	for {
+		id, size, err := readEBMLElement(br)
+		if err != nil {
+			return info, nil
+		}
+		switch id {
+		case ebmlInfo:
+			readWebMInfo(br, size, info)
+			return info, nil
+		case ebmlCluster:
+			return info, nil
+		default:
+			if size == unknownEBMLSize {
+				return info, nil
+			}
+			if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+				return info, nil
+			}
+		}
+	}
+}
+
+func readWebMInfo(br *countingReader, size uint64, info *Info) {
+	scale := uint64(1000000) // nanoseconds per timecode tick
+	var duration float64
+	end := br.read + int64(size)
+	for br.read < end {
+		id, childSize, err := readEBMLElement(br)
+		if err != nil || childSize > 8 {
+			return
+		}
+		value := make([]byte, childSize)
+		if _, err := io.ReadFull(br, value); err != nil {
+			return
+		}
+		switch id {
+		case ebmlTimecodeScale:
+			scale = 0
+			for _, b := range value {
+				scale = scale<<8 | uint64(b)
+			}
+		case ebmlDuration:
+			switch childSize {
+			case 4:
+				duration = float64(math.Float32frombits(binary.BigEndian.Uint32(value)))
+			case 8:
+				duration = math.Float64frombits(binary.BigEndian.Uint64(value))
+			}
+		}
+	}
+	info.DurationSeconds = duration * float64(scale) / 1e9
+}
+
+// unknownEBMLSize is the reserved "size unknown" value, used by live recordings
+const unknownEBMLSize = math.MaxUint64
+
+// readEBMLElement reads an element id and its data size
+func readEBMLElement(r *countingReader) (uint32, uint64, error) {
+	id, idLength, err := readVarInt(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Element ids keep their length marker bits
+	id |= 1 << (7 * idLength)
+
+	size, sizeLength, err := readVarInt(r)
+	if err != nil {
+		return 0, 0, err
+	}
+	if size == 1<<(7*sizeLength)-1 {
+		size = unknownEBMLSize
+	}
+	return uint32(id), size, nil
+}
+
+// readVarInt reads an EBML variable length integer, returning its value without the length marker
+func readVarInt(r *countingReader) (uint64, int, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(r, first); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, errors.New("invalid EBML integer")
+	}
+
+	value := uint64(first[0] & (0xFF >> length))
+	rest := make([]byte, length-1)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return 0, 0, err
+	}
+	for _, b := range rest {
+		value = value<<8 | uint64(b)
+	}
+	return value, length, nil
+}
+
+// countingReader tracks the offset while parsing EBML, whose sizes are relative to it
+type countingReader struct {
+	r    io.ReadSeeker
+	read int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,120 @@
+// Package audioprobe reads the container headers of an audio upload to find its real duration,
+// codec and sample rate before it's sent to a transcription provider
+package audioprobe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Containers recognised by Probe, matching the formats Whisper accepts
+const (
+	FormatMP3  = "mp3"
+	FormatWAV  = "wav"
+	FormatFLAC = "flac"
+	FormatOgg  = "ogg"
+	FormatMP4  = "mp4" // also m4a
+	FormatWebM = "webm"
+)
+
+// ErrUnsupportedFormat means the upload isn't in a container the transcription providers accept
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// Info describes a probed upload. DurationSeconds is 0 when the container doesn't record it
+// (WebM from MediaRecorder, for example) - callers fall back to an estimate then
+type Info struct {
+	Format          string  `json:"format"`
+	Codec           string  `json:"codec,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	SampleRate      int     `json:"sample_rate,omitempty"`
+	Channels        int     `json:"channels,omitempty"`
+}
+
+// Probe identifies the container from its magic bytes and reads the duration from its headers.
+// It returns ErrUnsupportedFormat for anything else, and leaves r at offset 0
+func Probe(r io.ReadSeeker) (*Info, error) {
+	defer r.Seek(0, io.SeekStart)
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek audio: %w", err)
+	}
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: file is empty", ErrUnsupportedFormat)
+	}
+	header = header[:n]
+
+	format := detect(header)
+	if format == "" {
+		return nil, fmt.Errorf("%w: expected mp3, wav, flac, ogg, m4a/mp4 or webm", ErrUnsupportedFormat)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek audio: %w", err)
+	}
+
+	var info *Info
+	switch format {
+	case FormatMP3:
+		info, err = probeMP3(r)
+	case FormatWAV:
+		info, err = probeWAV(r)
+	case FormatFLAC:
+		info, err = probeFLAC(r)
+	case FormatOgg:
+		info, err = probeOgg(r)
+	case FormatMP4:
+		info, err = probeMP4(r)
+	case FormatWebM:
+		info, err = probeWebM(r)
+	}
+	if err != nil {
+		return &Info{Format: format}, fmt.Errorf("failed to read %s headers: %w", format, err)
+	}
+	info.Format = format
+	return info, nil
+}
+
+// detect returns the container for the first bytes of a file, or "" when it isn't audio we accept
+func detect(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return FormatMP3
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0 && header[1]&0x06 != 0:
+		return FormatMP3 // MPEG audio frame sync; layer bits 00 would be AAC in ADTS
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return FormatWAV
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FormatFLAC
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return FormatOgg
+	case len(header) >= 8 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return FormatMP4
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return FormatWebM
+	}
+	return ""
+}
+
+// EstimateDuration is the size based fallback (1MB is about a minute of 128kbps audio)
+func EstimateDuration(sizeBytes int64) float64 {
+	return float64(sizeBytes) / 1048576.0 * 60.0
+}
+
+// Describe formats the probe for logs
+func (i *Info) Describe() string {
+	parts := []string{i.Format}
+	if i.Codec != "" {
+		parts = append(parts, i.Codec)
+	}
+	if i.SampleRate > 0 {
+		parts = append(parts, fmt.Sprintf("%dHz", i.SampleRate))
+	}
+	if i.Channels > 0 {
+		parts = append(parts, fmt.Sprintf("%dch", i.Channels))
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,169 @@
+package audioprobe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+// mp3File is silent MPEG-1 Layer III at 128kbps/44.1kHz; each frame holds 1152 samples
+func mp3File(frames int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0x64})
+	return bytes.Repeat(frame, frames)
+}
+
+func wavFile(sampleRate, channels int, seconds float64) []byte {
+	byteRate := sampleRate * channels * 2
+	dataSize := int(float64(byteRate) * seconds)
+
+	var b bytes.Buffer
+	b.WriteString("RIFF")
+	binary.Write(&b, binary.LittleEndian, uint32(36+dataSize))
+	b.WriteString("WAVEfmt ")
+	binary.Write(&b, binary.LittleEndian, uint32(16))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(channels))
+	binary.Write(&b, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&b, binary.LittleEndian, uint32(byteRate))
+	binary.Write(&b, binary.LittleEndian, uint16(channels*2))
+	binary.Write(&b, binary.LittleEndian, uint16(16))
+	b.WriteString("LIST")
+	binary.Write(&b, binary.LittleEndian, uint32(3)) // odd size, padded
+	b.Write([]byte{1, 2, 3, 0})
+	b.WriteString("data")
+	binary.Write(&b, binary.LittleEndian, uint32(dataSize))
+	b.Write(make([]byte, dataSize))
+	return b.Bytes()
+}
+
+func flacFile(sampleRate, channels int, totalSamples uint64) []byte {
+	streamInfo := make([]byte, 34)
+	packed := uint64(sampleRate)<<44 | uint64(channels-1)<<41 | uint64(15)<<36 | totalSamples
+	binary.BigEndian.PutUint64(streamInfo[10:], packed)
+
+	b := []byte("fLaC")
+	b = append(b, 0x80, 0, 0, 34) // last block, STREAMINFO, 34 bytes
+	return append(b, streamInfo...)
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	page := []byte("OggS")
+	page = append(page, 0, 0)
+	page = binary.LittleEndian.AppendUint64(page, granule)
+	page = append(page, make([]byte, 12)...) // serial, sequence, checksum
+	page = append(page, 1, byte(len(packet)))
+	return append(page, packet...)
+}
+
+func opusFile(seconds float64) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, 2)
+	head = binary.LittleEndian.AppendUint16(head, 312)
+	head = binary.LittleEndian.AppendUint32(head, 44100)
+	head = append(head, 0, 0, 0)
+
+	b := oggPage(0, head)
+	b = append(b, make([]byte, 100)...)
+	return append(b, oggPage(uint64(seconds*48000)+312, []byte{0})...)
+}
+
+func box(boxType string, body ...[]byte) []byte {
+	content := bytes.Join(body, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(content)))
+	b = append(b, boxType...)
+	return append(b, content...)
+}
+
+func mp4File(timescale, duration uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], timescale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+
+	sampleEntry := make([]byte, 28)
+	binary.BigEndian.PutUint16(sampleEntry[16:], 2)
+	binary.BigEndian.PutUint32(sampleEntry[24:], 44100<<16)
+	stsd := append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, box("mp4a", sampleEntry)...)
+
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("M4A \x00\x00\x00\x00")),
+		box("mdat", make([]byte, 64)),
+		box("moov", box("mvhd", mvhd), box("trak", box("mdia", box("minf", box("stbl", box("stsd", stsd)))))),
+	}, nil)
+}
+
+func webmFile(seconds float64) []byte {
+	duration := binary.BigEndian.AppendUint64(nil, math.Float64bits(seconds*1000))
+	info := []byte{0x2A, 0xD7, 0xB1, 0x83, 0x0F, 0x42, 0x40} // TimecodeScale 1000000
+	info = append(info, 0x44, 0x89, 0x88)
+	info = append(info, duration...)
+
+	b := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x84, 0x42, 0x86, 0x81, 0x01}                     // EBML header with EBMLVersion
+	b = append(b, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF) // Segment, unknown size
+	b = append(b, 0x15, 0x49, 0xA9, 0x66, 0x80|byte(len(info)))
+	return append(b, info...)
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Info
+	}{
+		{"mp3", mp3File(100), Info{Format: FormatMP3, Codec: "mp3", DurationSeconds: 100 * 1152 / 44100.0, SampleRate: 44100}},
+		{"wav", wavFile(16000, 1, 2.5), Info{Format: FormatWAV, Codec: "pcm", DurationSeconds: 2.5, SampleRate: 16000, Channels: 1}},
+		{"flac", flacFile(48000, 2, 48000*90), Info{Format: FormatFLAC, Codec: "flac", DurationSeconds: 90, SampleRate: 48000, Channels: 2}},
+		{"opus", opusFile(12), Info{Format: FormatOgg, Codec: "opus", DurationSeconds: 12, SampleRate: 44100, Channels: 2}},
+		{"m4a", mp4File(1000, 61500), Info{Format: FormatMP4, Codec: "aac", DurationSeconds: 61.5, SampleRate: 44100, Channels: 2}},
+		{"webm", webmFile(7.25), Info{Format: FormatWebM, Codec: "webm", DurationSeconds: 7.25}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bytes.NewReader(tt.data)
+			info, err := Probe(r)
+			if err != nil {
+				t.Fatalf("Probe: %v", err)
+			}
+			if !approx(info.DurationSeconds, tt.want.DurationSeconds) {
+				t.Errorf("duration = %v, want %v", info.DurationSeconds, tt.want.DurationSeconds)
+			}
+			got := *info
+			got.DurationSeconds = tt.want.DurationSeconds
+			if got != tt.want {
+				t.Errorf("info = %+v, want %+v", got, tt.want)
+			}
+			if offset, _ := r.Seek(0, io.SeekCurrent); offset != 0 {
+				t.Errorf("reader left at offset %d", offset)
+			}
+		})
+	}
+}
+
+func TestProbeRejectsUnsupportedFormats(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"pdf":   []byte("%PDF-1.7 not audio"),
+		"empty": nil,
+		"adts":  {0xFF, 0xF1, 0x50, 0x80, 0x02, 0x1F, 0xFC},
+	} {
+		if _, err := Probe(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedFormat", name, err)
+		}
+	}
+}
+
+func TestProbeReportsDamagedHeaders(t *testing.T) {
+	info, err := Probe(bytes.NewReader([]byte("RIFF\x00\x00\x00\x00WAVE")))
+	if err == nil || errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("err = %v, want a header error", err)
+	}
+	if info == nil || info.Format != FormatWAV {
+		t.Errorf("info = %+v, want the detected format", info)
+	}
+}
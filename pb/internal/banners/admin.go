@@ -0,0 +1,317 @@
+package banners
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Limits the desktop client lays banners out for
+const (
+	maxTitleLength      = 100
+	maxMessageLength    = 500
+	maxActionTextLength = 30
+)
+
+var bannerTypes = []string{"info", "warning", "success", "error"}
+
+// Banner is the editable content of a banner
+type Banner struct {
+	Title        string    `json:"title"`
+	Message      string    `json:"message"`
+	Type         string    `json:"type"`
+	RequiresAuth bool      `json:"requires_auth"`
+	ActionURL    string    `json:"action_url"`
+	ActionText   string    `json:"action_text"`
+	ExpiresAt    time.Time `json:"expires_at"` // zero = never expires
+}
+
+// BannerRequest creates or updates a banner. Fields left out of an update keep their value;
+// expires_at "" clears the expiry
+type BannerRequest struct {
+	Title        *string `json:"title"`
+	Message      *string `json:"message"`
+	Type         *string `json:"type"`
+	RequiresAuth *bool   `json:"requires_auth"`
+	ActionURL    *string `json:"action_url"`
+	ActionText   *string `json:"action_text"`
+	ExpiresAt    *string `json:"expires_at"`
+	Active       *bool   `json:"active"` // create only: publish straight away
+}
+
+// apply copies the request's fields onto the banner
+func (r BannerRequest) apply(banner *Banner) []string {
+	if r.Title != nil {
+		banner.Title = strings.TrimSpace(*r.Title)
+	}
+	if r.Message != nil {
+		banner.Message = strings.TrimSpace(*r.Message)
+	}
+	if r.Type != nil {
+		banner.Type = *r.Type
+	}
+	if r.RequiresAuth != nil {
+		banner.RequiresAuth = *r.RequiresAuth
+	}
+	if r.ActionURL != nil {
+		banner.ActionURL = strings.TrimSpace(*r.ActionURL)
+	}
+	if r.ActionText != nil {
+		banner.ActionText = strings.TrimSpace(*r.ActionText)
+	}
+	if r.ExpiresAt != nil {
+		if *r.ExpiresAt == "" {
+			banner.ExpiresAt = time.Time{}
+		} else {
+			expiresAt, err := time.Parse(time.RFC3339, *r.ExpiresAt)
+			if err != nil {
+				return []string{"expires_at must be an RFC 3339 timestamp"}
+			}
+			banner.ExpiresAt = expiresAt.UTC()
+		}
+	}
+	return nil
+}
+
+// Problems lists everything that would stop the banner from being saved
+func (b Banner) Problems() []string {
+	problems := []string{}
+	switch {
+	case b.Title == "":
+		problems = append(problems, "title is required")
+	case len([]rune(b.Title)) > maxTitleLength:
+		problems = append(problems, "title must be at most 100 characters")
+	}
+	switch {
+	case b.Message == "":
+		problems = append(problems, "message is required")
+	case len([]rune(b.Message)) > maxMessageLength:
+		problems = append(problems, "message must be at most 500 characters")
+	}
+	problems = append(problems, markdownProblems(b.Message)...)
+	if !slices.Contains(bannerTypes, b.Type) {
+		problems = append(problems, "type must be one of info, warning, success or error")
+	}
+
+	if (b.ActionURL == "") != (b.ActionText == "") {
+		problems = append(problems, "action_url and action_text must be set together")
+	}
+	if b.ActionURL != "" {
+		if parsed, err := url.Parse(b.ActionURL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			problems = append(problems, "action_url must be an absolute http(s) URL")
+		}
+	}
+	if len([]rune(b.ActionText)) > maxActionTextLength {
+		problems = append(problems, "action_text must be at most 30 characters")
+	}
+	return problems
+}
+
+// bannerFromRecord reads a banner record's editable content
+func bannerFromRecord(record *core.Record) Banner {
+	return Banner{
+		Title:        record.GetString("title"),
+		Message:      record.GetString("message"),
+		Type:         record.GetString("type"),
+		RequiresAuth: record.GetBool("requires_auth"),
+		ActionURL:    record.GetString("action_url"),
+		ActionText:   record.GetString("action_text"),
+		ExpiresAt:    record.GetDateTime("expires_at").Time(),
+	}
+}
+
+func (b Banner) setOn(record *core.Record) {
+	record.Set("title", b.Title)
+	record.Set("message", b.Message)
+	record.Set("type", b.Type)
+	record.Set("requires_auth", b.RequiresAuth)
+	record.Set("action_url", b.ActionURL)
+	record.Set("action_text", b.ActionText)
+	if b.ExpiresAt.IsZero() {
+		record.Set("expires_at", "")
+	} else {
+		record.Set("expires_at", b.ExpiresAt)
+	}
+}
+
+// previewResponse is what the desktop client would show for the banner, and whether it can be saved
+func previewResponse(banner Banner) map[string]any {
+	problems := banner.Problems()
+	return map[string]any{
+		"valid":        len(problems) == 0,
+		"errors":       problems,
+		"banner":       banner,
+		"message_html": renderMarkdown(banner.Message),
+	}
+}
+
+func invalidBannerResponse(e *core.RequestEvent, problems []string) error {
+	return e.JSON(http.StatusBadRequest, map[string]any{"error": "Invalid banner: " + problems[0], "errors": problems})
+}
+
+// ListAdminBannersHandler returns every banner, including drafts, expired and incident banners (superusers only)
+// GET /api/admin/banners
+func ListAdminBannersHandler(e *core.RequestEvent, app core.App) error {
+	records, err := app.FindRecordsByFilter("banners", "", "-created", 0, 0)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list banners"})
+	}
+	return e.JSON(http.StatusOK, map[string]any{"banners": records})
+}
+
+// CreateBannerHandler validates and saves a new banner, as a draft unless active is true (superusers only)
+// POST /api/admin/banners
+func CreateBannerHandler(e *core.RequestEvent, app core.App) error {
+	var req BannerRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	banner := Banner{Type: "info"}
+	problems := req.apply(&banner)
+	if len(problems) == 0 {
+		problems = banner.Problems()
+	}
+	if len(problems) > 0 {
+		return invalidBannerResponse(e, problems)
+	}
+
+	collection, err := app.FindCollectionByNameOrId("banners")
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to find banners collection"})
+	}
+	record := core.NewRecord(collection)
+	banner.setOn(record)
+	record.Set("active", req.Active != nil && *req.Active)
+
+	if err := app.Save(record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save banner"})
+	}
+
+	log.Printf("[BANNERS] Created banner %s (%q, active=%t)", record.Id, banner.Title, record.GetBool("active"))
+	return e.JSON(http.StatusCreated, record)
+}
+
+// UpdateBannerHandler validates and applies changes to a banner (superusers only). Publishing
+// goes through the publish and unpublish endpoints
+// PATCH /api/admin/banners/{id}
+func UpdateBannerHandler(e *core.RequestEvent, app core.App) error {
+	record, err := app.FindRecordById("banners", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Banner not found"})
+	}
+
+	var req BannerRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	banner := bannerFromRecord(record)
+	problems := req.apply(&banner)
+	if len(problems) == 0 {
+		problems = banner.Problems()
+	}
+	if len(problems) > 0 {
+		return invalidBannerResponse(e, problems)
+	}
+
+	banner.setOn(record)
+	if err := app.Save(record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save banner"})
+	}
+
+	log.Printf("[BANNERS] Updated banner %s", record.Id)
+	return e.JSON(http.StatusOK, record)
+}
+
+// DeleteBannerHandler removes a banner and its dismissals (superusers only)
+// DELETE /api/admin/banners/{id}
+func DeleteBannerHandler(e *core.RequestEvent, app core.App) error {
+	record, err := app.FindRecordById("banners", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Banner not found"})
+	}
+
+	dismissals, _ := app.FindRecordsByFilter("banner_dismissals", "banner_id = {:banner}", "", 0, 0, map[string]any{"banner": record.Id})
+	for _, dismissal := range dismissals {
+		if err := app.Delete(dismissal); err != nil {
+			log.Printf("[BANNERS] Failed to delete dismissal %s: %v", dismissal.Id, err)
+		}
+	}
+	if err := app.Delete(record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete banner"})
+	}
+
+	log.Printf("[BANNERS] Deleted banner %s", record.Id)
+	return e.NoContent(http.StatusNoContent)
+}
+
+// PublishBannerHandler makes a banner visible to clients (superusers only). The stored content is
+// validated again, so banners edited through the admin UI can't be published broken
+// POST /api/admin/banners/{id}/publish
+func PublishBannerHandler(e *core.RequestEvent, app core.App) error {
+	return setBannerActive(e, app, true)
+}
+
+// UnpublishBannerHandler hides a banner from clients without deleting it (superusers only)
+// POST /api/admin/banners/{id}/unpublish
+func UnpublishBannerHandler(e *core.RequestEvent, app core.App) error {
+	return setBannerActive(e, app, false)
+}
+
+func setBannerActive(e *core.RequestEvent, app core.App, active bool) error {
+	record, err := app.FindRecordById("banners", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Banner not found"})
+	}
+
+	if active {
+		banner := bannerFromRecord(record)
+		if problems := banner.Problems(); len(problems) > 0 {
+			return invalidBannerResponse(e, problems)
+		}
+		if !banner.ExpiresAt.IsZero() && banner.ExpiresAt.Before(time.Now()) {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Banner has expired - set a later expires_at before publishing"})
+		}
+	}
+
+	record.Set("active", active)
+	if err := app.Save(record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save banner"})
+	}
+
+	log.Printf("[BANNERS] Set banner %s active=%t", record.Id, active)
+	return e.JSON(http.StatusOK, record)
+}
+
+// PreviewBannerHandler validates banner content and renders its message without saving anything
+// (superusers only). With an id, the request's fields are previewed on top of that banner
+// POST /api/admin/banners/preview
+// POST /api/admin/banners/{id}/preview
+func PreviewBannerHandler(e *core.RequestEvent, app core.App) error {
+	banner := Banner{Type: "info"}
+	if id := e.Request.PathValue("id"); id != "" {
+		record, err := app.FindRecordById("banners", id)
+		if err != nil {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "Banner not found"})
+		}
+		banner = bannerFromRecord(record)
+	}
+
+	var req BannerRequest
+	if e.Request.ContentLength != 0 {
+		if err := e.BindBody(&req); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+	if problems := req.apply(&banner); len(problems) > 0 {
+		return invalidBannerResponse(e, problems)
+	}
+
+	return e.JSON(http.StatusOK, previewResponse(banner))
+}
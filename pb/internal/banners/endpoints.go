@@ -3,10 +3,17 @@ package banners
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/apisign"
+	"pocketbase/internal/communications"
+	"pocketbase/internal/keyguard"
 )
 
 // GetBannersHandler handles all banner requests with optional authentication and filtering
@@ -20,7 +27,9 @@ func GetBannersHandler(e *core.RequestEvent, app core.App) error {
 	var records []*core.Record
 	var err error
 	
-	if apiKey == "" {
+	signedUser := apisign.SignedUser(e)
+	
+	if apiKey == "" && signedUser == nil {
 		// No API key - return only public banners
 		records, err = app.FindRecordsByFilter(
 			"banners",
@@ -41,10 +50,13 @@ func GetBannersHandler(e *core.RequestEvent, app core.App) error {
 		})
 	}
 	
-	// Validate API key
-	_, err = validateAPIKey(app, apiKey)
-	if err != nil {
-		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+	// Validate API key (a verified signature stands in for it)
+	userRecord := signedUser
+	if userRecord == nil {
+		userRecord, err = validateAPIKey(app, apiKey, e.Request)
+		if err != nil {
+			return e.JSON(401, map[string]string{"error": "Invalid API key"})
+		}
 	}
 	
 	// Authenticated request - get all accessible banners
@@ -63,7 +75,7 @@ func GetBannersHandler(e *core.RequestEvent, app core.App) error {
 	}
 	
 	// Add dismissal status to each banner
-	keyHash := hashAPIKey(apiKey)
+	keyHash := requestKeyHash(e, apiKey)
 	bannersWithStatus := make([]map[string]interface{}, 0, len(records))
 	
 	for _, banner := range records {
@@ -100,6 +112,18 @@ func GetBannersHandler(e *core.RequestEvent, app core.App) error {
 			continue
 		}
 		
+		// Record the first time each banner reaches this user in their communications timeline
+		if !isDismissed {
+			communications.LogOnce(app, communications.Entry{
+				UserID:    userRecord.Id,
+				Channel:   communications.ChannelBanner,
+				Kind:      "banner_shown",
+				Subject:   banner.GetString("title"),
+				Status:    communications.StatusShown,
+				Reference: banner.Id,
+			})
+		}
+		
 		bannersWithStatus = append(bannersWithStatus, bannerData)
 	}
 	
@@ -110,16 +134,20 @@ func GetBannersHandler(e *core.RequestEvent, app core.App) error {
 
 // DismissBannerHandler handles dismissing a banner for a specific API key
 func DismissBannerHandler(e *core.RequestEvent, app core.App) error {
-	// Validate API key
+	// Validate API key (a verified signature stands in for it)
 	apiKey := extractBearerToken(e.Request.Header.Get("Authorization"))
-	if apiKey == "" {
-		return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
-	}
+	userRecord := apisign.SignedUser(e)
+	if userRecord == nil {
+		if apiKey == "" {
+			return e.JSON(401, map[string]string{"error": "Missing or invalid API key"})
+		}
 
-	// Validate API key using existing validation
-	userRecord, err := validateAPIKey(app, apiKey)
-	if err != nil {
-		return e.JSON(401, map[string]string{"error": "Invalid API key"})
+		// Validate API key using existing validation
+		var err error
+		userRecord, err = validateAPIKey(app, apiKey, e.Request)
+		if err != nil {
+			return e.JSON(401, map[string]string{"error": "Invalid API key"})
+		}
 	}
 
 	// Get banner ID from URL parameter
@@ -136,7 +164,7 @@ func DismissBannerHandler(e *core.RequestEvent, app core.App) error {
 
 	// Create or update dismissal record
 	// We'll store dismissals using a combination of API key hash and banner ID
-	keyHash := hashAPIKey(apiKey)
+	keyHash := requestKeyHash(e, apiKey)
 	dismissalID := keyHash + "_" + bannerID
 
 	// Check if dismissal already exists
@@ -196,7 +224,7 @@ func extractBearerToken(authHeader string) string {
 	return parts[1]
 }
 
-func validateAPIKey(app core.App, apiKey string) (*core.Record, error) {
+func validateAPIKey(app core.App, apiKey string, r *http.Request) (*core.Record, error) {
 	keyHash := hashAPIKey(apiKey)
 	
 	// Find API key record
@@ -213,9 +241,25 @@ func validateAPIKey(app core.App, apiKey string) (*core.Record, error) {
 		return nil, err
 	}
 
+	// Soft-deleted accounts keep their keys, but they stop working until the account is restored
+	if accounts.IsDeactivated(userRecord) {
+		return nil, fmt.Errorf("account is deactivated")
+	}
+	if err := keyguard.Check(app, apiKeyRecord, r); err != nil {
+		return nil, err
+	}
+
 	return userRecord, nil
 }
 
+// requestKeyHash identifies the API key behind a request - the signing key for signed requests
+func requestKeyHash(e *core.RequestEvent, apiKey string) string {
+	if key := apisign.SignedKey(e); key != nil {
+		return key.GetString("key_hash")
+	}
+	return hashAPIKey(apiKey)
+}
+
 func hashAPIKey(apiKey string) string {
 	hash := sha256.Sum256([]byte(apiKey))
 	return hex.EncodeToString(hash[:])
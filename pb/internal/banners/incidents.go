@@ -0,0 +1,73 @@
+package banners
+
+import (
+	"log"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// incidentBannerTTL expires incident banners on their own if the recovery is never observed,
+// e.g. because the server restarted while the provider was down
+const incidentBannerTTL = 6 * time.Hour
+
+// incidentCopy is the user-facing title and message for each provider outage
+var incidentCopy = map[string]struct{ Title, Message string }{
+	"openai": {
+		Title:   "Transcription is temporarily unavailable",
+		Message: "Our transcription provider is having problems. New transcriptions will fail until it recovers - please try again in a few minutes.",
+	},
+	"openrouter": {
+		Title:   "AI text features are temporarily unavailable",
+		Message: "Our AI provider is having problems. Suggestions and chat will fail until it recovers - please try again in a few minutes.",
+	},
+}
+
+// SyncIncident publishes an automatic incident banner while a provider is down and
+// deactivates it once the provider recovers
+func SyncIncident(app core.App, provider string, down bool) {
+	key := "provider_outage:" + provider
+	existing, err := app.FindFirstRecordByFilter("banners", "incident_key = {:key}", map[string]any{"key": key})
+
+	if !down {
+		if err == nil && existing.GetBool("active") {
+			existing.Set("active", false)
+			if err := app.Save(existing); err != nil {
+				log.Printf("[BANNERS] Failed to resolve incident banner for %s: %v", provider, err)
+				return
+			}
+			log.Printf("[BANNERS] Resolved incident banner for %s", provider)
+		}
+		return
+	}
+
+	content, ok := incidentCopy[provider]
+	if !ok {
+		content.Title = "Some features are temporarily unavailable"
+		content.Message = "One of our providers is having problems. Please try again in a few minutes."
+	}
+
+	record := existing
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("banners")
+		if err != nil {
+			log.Printf("[BANNERS] Failed to find banners collection: %v", err)
+			return
+		}
+		record = core.NewRecord(collection)
+		record.Set("incident_key", key)
+	}
+
+	record.Set("title", content.Title)
+	record.Set("message", content.Message)
+	record.Set("type", "error")
+	record.Set("active", true)
+	record.Set("requires_auth", false)
+	record.Set("expires_at", time.Now().Add(incidentBannerTTL))
+
+	if err := app.Save(record); err != nil {
+		log.Printf("[BANNERS] Failed to publish incident banner for %s: %v", provider, err)
+		return
+	}
+	log.Printf("[BANNERS] Published incident banner for %s", provider)
+}
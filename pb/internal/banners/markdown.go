@@ -0,0 +1,95 @@
+package banners
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Banner messages are rendered by the desktop client with a small markdown subset: **bold**,
+// *italic*, `code`, [links](https://...) and line breaks. Anything else is shown literally,
+// and unbalanced markers or raw HTML have broken its renderer before, so they're rejected
+var (
+	markdownHTMLTag = regexp.MustCompile(`<\/?[a-zA-Z!]`)
+	markdownLink    = regexp.MustCompile(`\[([^\]\n]+)\]\(([^)\s]+)\)`)
+	markdownCode    = regexp.MustCompile("`([^`\n]+)`")
+	markdownBold    = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	markdownItalic  = regexp.MustCompile(`\*([^*\n]+)\*`)
+)
+
+// markdownProblems lists what would break the client's rendering of a banner message
+func markdownProblems(message string) []string {
+	problems := []string{}
+	if markdownHTMLTag.MatchString(message) {
+		problems = append(problems, "message must not contain HTML tags")
+	}
+
+	// Links are checked and removed first, so their text and URLs don't count as markers
+	withoutLinks := message
+	for _, match := range markdownLink.FindAllStringSubmatch(message, -1) {
+		if !isAllowedLinkURL(match[2]) {
+			problems = append(problems, fmt.Sprintf("link %q must be an absolute http(s) or mailto URL", match[2]))
+		}
+		withoutLinks = strings.Replace(withoutLinks, match[0], match[1], 1)
+	}
+	if strings.Contains(withoutLinks, "](") {
+		problems = append(problems, "message has a malformed link - use [text](https://...)")
+	}
+
+	withoutCode := markdownCode.ReplaceAllString(withoutLinks, "")
+	if strings.Contains(withoutCode, "`") {
+		problems = append(problems, "message has an unclosed ` code span")
+	}
+	if strings.Count(withoutCode, "**")%2 != 0 {
+		problems = append(problems, "message has an unclosed ** bold marker")
+	} else if strings.Count(strings.ReplaceAll(withoutCode, "**", ""), "*")%2 != 0 {
+		problems = append(problems, "message has an unclosed * italic marker")
+	}
+	return problems
+}
+
+func isAllowedLinkURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	switch parsed.Scheme {
+	case "http", "https":
+		return parsed.Host != ""
+	case "mailto":
+		return parsed.Opaque != ""
+	default:
+		return false
+	}
+}
+
+// renderMarkdown renders a banner message to HTML the way the client would, for previews
+// Everything is escaped first, so only the supported markup produces tags
+func renderMarkdown(message string) string {
+	escaped := html.EscapeString(message)
+
+	// Code spans are set aside so nothing inside them is formatted
+	var spans []string
+	escaped = markdownCode.ReplaceAllStringFunc(escaped, func(match string) string {
+		spans = append(spans, "<code>"+markdownCode.FindStringSubmatch(match)[1]+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	escaped = markdownLink.ReplaceAllStringFunc(escaped, func(match string) string {
+		link := markdownLink.FindStringSubmatch(match)
+		if !isAllowedLinkURL(html.UnescapeString(link[2])) {
+			return link[1]
+		}
+		return `<a href="` + link[2] + `" rel="noopener noreferrer">` + link[1] + `</a>`
+	})
+	escaped = markdownBold.ReplaceAllString(escaped, "<strong>$1</strong>")
+	escaped = markdownItalic.ReplaceAllString(escaped, "<em>$1</em>")
+	escaped = strings.ReplaceAll(escaped, "\n", "<br>")
+
+	for i, span := range spans {
+		escaped = strings.Replace(escaped, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return escaped
+}
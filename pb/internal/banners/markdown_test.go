@@ -0,0 +1,62 @@
+package banners
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownProblems(t *testing.T) {
+	valid := []string{
+		"Plain text",
+		"**Bold**, *italic* and `code`",
+		"See [the changelog](https://ramble.goosebyteshq.com/changelog) or [email us](mailto:support@example.com)",
+		"Use `a*b` in code spans",
+	}
+	for _, message := range valid {
+		if problems := markdownProblems(message); len(problems) > 0 {
+			t.Errorf("%q: unexpected problems %v", message, problems)
+		}
+	}
+
+	invalid := map[string]string{
+		"<script>alert(1)</script>":   "HTML",
+		"**unclosed bold":             "bold",
+		"*unclosed italic":            "italic",
+		"`unclosed code":              "code",
+		"[click](javascript:alert(1)": "mailto",
+		"[click] (https://x.com)](":   "malformed link",
+		"[click](javascript:alert)":   "mailto",
+		"[relative](/download)":       "mailto",
+	}
+	for message, want := range invalid {
+		problems := markdownProblems(message)
+		if len(problems) == 0 || !strings.Contains(strings.Join(problems, "; "), want) {
+			t.Errorf("%q: expected a problem mentioning %q, got %v", message, want, problems)
+		}
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	got := renderMarkdown("**New** *release* with `<tags>`\n[Download](https://example.com/?a=1&b=2)")
+	want := `<strong>New</strong> <em>release</em> with <code>&lt;tags&gt;</code><br>` +
+		`<a href="https://example.com/?a=1&amp;b=2" rel="noopener noreferrer">Download</a>`
+	if got != want {
+		t.Errorf("renderMarkdown =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := renderMarkdown("[x](javascript:alert)"); strings.Contains(got, "href") {
+		t.Errorf("unsafe link rendered: %s", got)
+	}
+}
+
+func TestBannerProblems(t *testing.T) {
+	banner := Banner{Title: "Update", Message: "Now available", Type: "info", ActionURL: "https://example.com", ActionText: "Get it"}
+	if problems := banner.Problems(); len(problems) > 0 {
+		t.Errorf("unexpected problems %v", problems)
+	}
+
+	broken := Banner{Message: strings.Repeat("a", maxMessageLength+1), Type: "alert", ActionURL: "example.com"}
+	if problems := broken.Problems(); len(problems) != 5 {
+		t.Errorf("expected title, message, type, action pairing and action URL problems, got %v", problems)
+	}
+}
@@ -0,0 +1,47 @@
+package banners
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the banner routes
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/banners", Handler: func(e *core.RequestEvent) error {
+			return GetBannersHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List the banners shown to the caller"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/banners/dismiss/{id}", Handler: func(e *core.RequestEvent) error {
+			return DismissBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Dismiss a banner"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/banners", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ListAdminBannersHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List all banners"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/banners", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return CreateBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Create a banner", Request: BannerRequest{}, Status: http.StatusCreated}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/banners/preview", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return PreviewBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Preview an unsaved banner", Request: BannerRequest{}}},
+		routes.Route{Method: http.MethodPatch, Path: "/api/admin/banners/{id}", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return UpdateBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Update a banner", Request: BannerRequest{}}},
+		routes.Route{Method: http.MethodDelete, Path: "/api/admin/banners/{id}", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return DeleteBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Delete a banner"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/banners/{id}/publish", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return PublishBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Publish a banner"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/banners/{id}/unpublish", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return UnpublishBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Unpublish a banner"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/banners/{id}/preview", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return PreviewBannerHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Preview a banner, with unsaved changes from the body"}},
+	)
+}
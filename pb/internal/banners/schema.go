@@ -0,0 +1,26 @@
+package banners
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the banner handlers read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "banners",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "banners",
+				Fields: []schemacheck.Field{
+					{Name: "title", Type: "text"},
+					{Name: "message", Type: "text"},
+					{Name: "type", Type: "select", Values: []string{"info", "warning", "success", "error"}},
+					{Name: "active", Type: "bool"},
+					{Name: "requires_auth", Type: "bool"},
+					{Name: "action_url", Type: "text"},
+					{Name: "action_text", Type: "text"},
+					{Name: "expires_at", Type: "date"},
+					{Name: "incident_key", Type: "text"},
+				},
+			},
+		},
+	}
+}
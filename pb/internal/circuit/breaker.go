@@ -0,0 +1,253 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// State of a circuit breaker
+type State string
+
+const (
+	StateClosed   State = "closed"    // requests flow to the provider
+	StateOpen     State = "open"      // provider is failing, requests are short-circuited
+	StateHalfOpen State = "half_open" // cooldown elapsed, a single probe request is allowed through
+)
+
+// Config controls when a breaker trips and how long it stays open
+type Config struct {
+	Window      time.Duration // outcomes older than this are forgotten
+	MinRequests int           // don't judge the error rate on fewer requests than this
+	ErrorRate   float64       // failure ratio (0-1) that trips the breaker
+	Cooldown    time.Duration // how long to stay open before probing the provider again
+}
+
+// ConfigFromEnv reads CIRCUIT_WINDOW_SECONDS, CIRCUIT_MIN_REQUESTS, CIRCUIT_ERROR_RATE
+// and CIRCUIT_COOLDOWN_SECONDS, falling back to defaults for unset or invalid values
+func ConfigFromEnv() Config {
+	config := Config{
+		Window:      60 * time.Second,
+		MinRequests: 10,
+		ErrorRate:   0.5,
+		Cooldown:    60 * time.Second,
+	}
+	if v, err := strconv.Atoi(os.Getenv("CIRCUIT_WINDOW_SECONDS")); err == nil && v > 0 {
+		config.Window = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("CIRCUIT_MIN_REQUESTS")); err == nil && v > 0 {
+		config.MinRequests = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("CIRCUIT_ERROR_RATE"), 64); err == nil && v > 0 && v <= 1 {
+		config.ErrorRate = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("CIRCUIT_COOLDOWN_SECONDS")); err == nil && v > 0 {
+		config.Cooldown = time.Duration(v) * time.Second
+	}
+	return config
+}
+
+type outcome struct {
+	at      time.Time
+	failure bool
+}
+
+// Breaker tracks the recent error rate of one provider
+type Breaker struct {
+	name   string
+	config Config
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	openedAt time.Time
+	probing  bool
+	outcomes []outcome
+}
+
+// New creates a closed breaker
+func New(name string, config Config) *Breaker {
+	return &Breaker{name: name, config: config, now: time.Now, state: StateClosed}
+}
+
+// Name returns the provider name the breaker guards
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns the current state, moving an open breaker to half-open once its cooldown has passed
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Allow reports whether a request may be sent to the provider
+// While half-open only one probe is let through until its outcome is recorded
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case StateClosed:
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+// RetryAfter returns how long until the breaker will probe the provider again
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != StateOpen {
+		return 0
+	}
+	if remaining := b.config.Cooldown - b.now().Sub(b.openedAt); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Record registers the outcome of a request allowed by Allow
+// Only provider-side failures (see IsOutage) count against the provider; a cancelled request
+// says nothing about it either way and is released instead
+func (b *Breaker) Record(err error) {
+	if errors.Is(err, context.Canceled) {
+		b.Release()
+		return
+	}
+	failure := IsOutage(err)
+
+	b.mu.Lock()
+	previous := b.state
+	now := b.now()
+
+	switch b.state {
+	case StateHalfOpen:
+		b.probing = false
+		if failure {
+			b.state, b.openedAt = StateOpen, now
+		} else {
+			b.state, b.outcomes = StateClosed, nil
+		}
+	case StateClosed:
+		b.outcomes = append(b.prune(now), outcome{at: now, failure: failure})
+		if b.tripped() {
+			b.state, b.openedAt, b.outcomes = StateOpen, now, nil
+		}
+	}
+
+	current := b.state
+	b.mu.Unlock()
+
+	if current != previous {
+		notify(b, current)
+	}
+}
+
+// Release ends a request allowed by Allow without recording an outcome, e.g. when the client
+// hung up first. A half-open breaker lets the next probe through
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateHalfOpen {
+		b.probing = false
+	}
+}
+
+// advance moves an open breaker to half-open after the cooldown (caller holds the lock)
+func (b *Breaker) advance() {
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.config.Cooldown {
+		b.state, b.probing = StateHalfOpen, false
+	}
+}
+
+// prune drops outcomes outside the window (caller holds the lock)
+func (b *Breaker) prune(now time.Time) []outcome {
+	cutoff := now.Add(-b.config.Window)
+	kept := b.outcomes[:0]
+	for _, o := range b.outcomes {
+		if o.at.After(cutoff) {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
+
+// tripped reports whether the error rate in the window crossed the threshold (caller holds the lock)
+func (b *Breaker) tripped() bool {
+	if len(b.outcomes) < b.config.MinRequests {
+		return false
+	}
+	failures := 0
+	for _, o := range b.outcomes {
+		if o.failure {
+			failures++
+		}
+	}
+	return float64(failures)/float64(len(b.outcomes)) >= b.config.ErrorRate
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Breaker{}
+	listener   func(name string, state State)
+)
+
+// For returns the shared breaker for a provider, creating it from the environment on first use
+func For(name string) *Breaker {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if b, ok := registry[name]; ok {
+		return b
+	}
+	b := New(name, ConfigFromEnv())
+	registry[name] = b
+	return b
+}
+
+// OnStateChange registers a function called whenever a shared breaker opens or closes
+// It runs on its own goroutine so slow listeners never hold up provider requests
+func OnStateChange(fn func(name string, state State)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	listener = fn
+}
+
+func notify(b *Breaker, state State) {
+	log.Printf("[CIRCUIT] %s breaker is now %s", b.name, state)
+
+	registryMu.Lock()
+	fn := listener
+	registryMu.Unlock()
+
+	if fn != nil {
+		go fn(b.name, state)
+	}
+}
+
+// Breakers returns every breaker created through For, sorted by name
+func Breakers() []*Breaker {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	breakers := make([]*Breaker, 0, len(registry))
+	for _, b := range registry {
+		breakers = append(breakers, b)
+	}
+	sort.Slice(breakers, func(i, j int) bool { return breakers[i].name < breakers[j].name })
+	return breakers
+}
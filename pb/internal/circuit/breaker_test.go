@@ -0,0 +1,143 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(now *time.Time) *Breaker {
+	b := New("test", Config{Window: time.Minute, MinRequests: 4, ErrorRate: 0.5, Cooldown: 30 * time.Second})
+	b.now = func() time.Time { return *now }
+	return b
+}
+
+var outage = &StatusError{Provider: "Test", StatusCode: 502, Body: "bad gateway"}
+
+func TestBreakerTripsOnErrorRate(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+
+	b.Record(nil)
+	b.Record(outage)
+	b.Record(outage)
+	if b.State() != StateClosed {
+		t.Fatal("expected breaker to stay closed below the minimum request count")
+	}
+
+	b.Record(nil)
+	if b.State() != StateOpen {
+		t.Fatalf("expected breaker to open at a 50%% error rate, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Error("expected an open breaker to reject requests")
+	}
+	if b.RetryAfter() != 30*time.Second {
+		t.Errorf("expected 30s retry after, got %v", b.RetryAfter())
+	}
+}
+
+func TestBreakerIgnoresOldOutcomes(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+
+	b.Record(outage)
+	b.Record(outage)
+	b.Record(outage)
+	now = now.Add(2 * time.Minute)
+	b.Record(nil)
+	b.Record(nil)
+	b.Record(nil)
+	b.Record(outage)
+
+	if b.State() != StateClosed {
+		t.Error("expected failures outside the window to be forgotten")
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+	for i := 0; i < 4; i++ {
+		b.Record(outage)
+	}
+
+	now = now.Add(31 * time.Second)
+	if !b.Allow() {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("expected only one probe while half-open")
+	}
+
+	b.Record(outage)
+	if b.State() != StateOpen {
+		t.Fatal("expected a failed probe to reopen the breaker")
+	}
+
+	now = now.Add(31 * time.Second)
+	if !b.Allow() {
+		t.Fatal("expected another probe after the cooldown")
+	}
+	b.Record(nil)
+	if b.State() != StateClosed || !b.Allow() {
+		t.Error("expected a successful probe to close the breaker")
+	}
+}
+
+func TestIsOutage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"server error", &StatusError{StatusCode: 503}, true},
+		{"rate limited", &StatusError{StatusCode: 429}, true},
+		{"bad request", &StatusError{StatusCode: 400}, false},
+		{"wrapped server error", fmt.Errorf("call failed: %w", &StatusError{StatusCode: 500}), true},
+		{"connection failure", &url.Error{Op: "Post", URL: "https://example.com", Err: errors.New("connection refused")}, true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"client went away", context.Canceled, false},
+		{"other", errors.New("failed to marshal request"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOutage(tt.err); got != tt.want {
+				t.Errorf("IsOutage(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerCancelledProbe(t *testing.T) {
+	now := time.Date(2025, time.June, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestBreaker(&now)
+	for i := 0; i < 4; i++ {
+		b.Record(outage)
+	}
+
+	now = now.Add(31 * time.Second)
+	if !b.Allow() {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	b.Record(fmt.Errorf("stream: %w", context.Canceled))
+	if b.State() != StateHalfOpen {
+		t.Fatalf("expected a cancelled probe to leave the breaker half-open, got %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("expected another probe after a cancelled one")
+	}
+	b.Release()
+	if !b.Allow() {
+		t.Fatal("expected Release to let another probe through")
+	}
+	b.Record(nil)
+	if b.State() != StateClosed {
+		t.Errorf("expected a successful probe to close the breaker, got %s", b.State())
+	}
+}
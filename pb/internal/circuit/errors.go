@@ -0,0 +1,49 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// StatusError is returned by provider clients when the provider answers with a non-200 status
+type StatusError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s API error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// OutageError is returned instead of calling a provider whose breaker is open
+type OutageError struct {
+	Provider   string
+	RetryAfter time.Duration
+}
+
+func (e *OutageError) Error() string {
+	return fmt.Sprintf("%s is temporarily unavailable", e.Provider)
+}
+
+// IsOutage reports whether err points at the provider rather than the request:
+// 5xx and 429 responses, timeouts and connection failures
+func IsOutage(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
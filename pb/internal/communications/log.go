@@ -0,0 +1,88 @@
+package communications
+
+import (
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Channels a user can be contacted through
+const (
+	ChannelEmail        = "email"
+	ChannelBanner       = "banner"
+	ChannelNotification = "notification"
+	ChannelWebhook      = "webhook"
+	ChannelSMS          = "sms"
+)
+
+// Delivery statuses
+const (
+	StatusSent      = "sent"
+	StatusFailed    = "failed"
+	StatusShown     = "shown"
+	StatusDismissed = "dismissed"
+)
+
+// Entry describes one message sent or shown to a user
+type Entry struct {
+	UserID    string
+	Channel   string
+	Kind      string // e.g. "otp_login", "organization_invitation", "banner_shown"
+	Recipient string // email address, phone number or webhook URL
+	Subject   string
+	Status    string
+	Reference string // ID of the source record (banner, invitation, ...)
+	Details   map[string]interface{}
+}
+
+// Log records a communication in the user's timeline
+// Failures are logged but never block the message itself
+func Log(app core.App, entry Entry) {
+	collection, err := app.FindCollectionByNameOrId("communications")
+	if err != nil {
+		log.Printf("[COMMS] Failed to find communications collection: %v", err)
+		return
+	}
+
+	// Emails are often sent by address only - attach them to the account when one exists
+	if entry.UserID == "" && entry.Channel == ChannelEmail && entry.Recipient != "" {
+		if user, err := app.FindAuthRecordByEmail("users", entry.Recipient); err == nil {
+			entry.UserID = user.Id
+		}
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("user_id", entry.UserID)
+	record.Set("channel", entry.Channel)
+	record.Set("kind", entry.Kind)
+	record.Set("recipient", entry.Recipient)
+	record.Set("subject", entry.Subject)
+	record.Set("status", entry.Status)
+	record.Set("reference", entry.Reference)
+	if entry.Details != nil {
+		record.Set("details", entry.Details)
+	}
+
+	if err := app.Save(record); err != nil {
+		log.Printf("[COMMS] Failed to log %s %s for %s: %v", entry.Channel, entry.Kind, entry.Recipient, err)
+	}
+}
+
+// LogOnce records a communication unless the same user was already logged for the same
+// channel, status and reference - used for things shown repeatedly, like banners
+func LogOnce(app core.App, entry Entry) {
+	_, err := app.FindFirstRecordByFilter("communications",
+		"user_id = {:user} && channel = {:channel} && status = {:status} && reference = {:reference}",
+		map[string]any{
+			"user":      entry.UserID,
+			"channel":   entry.Channel,
+			"status":    entry.Status,
+			"reference": entry.Reference,
+		},
+	)
+	if err == nil {
+		return
+	}
+
+	Log(app, entry)
+}
@@ -0,0 +1,20 @@
+package communications
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the per-user communication timeline (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/users/{id}/communications", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return TimelineHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,37 @@
+package communications
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the communications timeline reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "communications",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "communications",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "channel", Type: "select", Values: []string{ChannelEmail, ChannelBanner, ChannelNotification, ChannelWebhook, ChannelSMS}},
+					{Name: "kind", Type: "text"},
+					{Name: "recipient", Type: "text"},
+					{Name: "subject", Type: "text"},
+					{Name: "status", Type: "select", Values: []string{StatusSent, StatusFailed, StatusShown, StatusDismissed}},
+					{Name: "reference", Type: "text"},
+					{Name: "details", Type: "json"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_communications_user_created", Columns: "user_id, created"},
+				},
+			},
+			{
+				Collection: "banner_dismissals",
+				Fields: []schemacheck.Field{
+					{Name: "banner_id", Type: "relation"},
+					{Name: "user_id", Type: "relation"},
+					{Name: "api_key_hash", Type: "text"},
+					{Name: "dismissed_at", Type: "date"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,135 @@
+package communications
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// TimelineEntry is one item in a user's communications timeline
+type TimelineEntry struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Channel   string                 `json:"channel"`
+	Kind      string                 `json:"kind"`
+	Recipient string                 `json:"recipient,omitempty"`
+	Subject   string                 `json:"subject,omitempty"`
+	Status    string                 `json:"status"`
+	Reference string                 `json:"reference,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Timeline merges the communications log with banner dismissals for a user, newest first
+// Emails sent to the user's address before the account existed are included too
+func Timeline(app core.App, user *core.Record, channel string, limit int) ([]TimelineEntry, error) {
+	filter := "(user_id = {:user} || recipient = {:email})"
+	if channel != "" {
+		filter += " && channel = {:channel}"
+	}
+
+	records, err := app.FindRecordsByFilter("communications", filter, "-created", limit, 0, map[string]any{
+		"user":    user.Id,
+		"email":   user.GetString("email"),
+		"channel": channel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]TimelineEntry, 0, len(records))
+	for _, record := range records {
+		entry := TimelineEntry{
+			Timestamp: record.GetDateTime("created").Time(),
+			Channel:   record.GetString("channel"),
+			Kind:      record.GetString("kind"),
+			Recipient: record.GetString("recipient"),
+			Subject:   record.GetString("subject"),
+			Status:    record.GetString("status"),
+			Reference: record.GetString("reference"),
+		}
+		record.UnmarshalJSONField("details", &entry.Details)
+		entries = append(entries, entry)
+	}
+
+	if channel == "" || channel == ChannelBanner {
+		entries = append(entries, bannerDismissals(app, user.Id, limit)...)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// bannerDismissals reads the user's dismissals straight from banner_dismissals
+func bannerDismissals(app core.App, userID string, limit int) []TimelineEntry {
+	dismissals, err := app.FindRecordsByFilter("banner_dismissals", "user_id = {:user}", "-created", limit, 0, map[string]any{
+		"user": userID,
+	})
+	if err != nil {
+		return nil
+	}
+
+	entries := make([]TimelineEntry, 0, len(dismissals))
+	for _, dismissal := range dismissals {
+		timestamp := dismissal.GetDateTime("dismissed_at")
+		if timestamp.IsZero() {
+			timestamp = dismissal.GetDateTime("created")
+		}
+
+		entry := TimelineEntry{
+			Timestamp: timestamp.Time(),
+			Channel:   ChannelBanner,
+			Kind:      "banner_dismissed",
+			Status:    StatusDismissed,
+			Reference: dismissal.GetString("banner_id"),
+		}
+		if banner, err := app.FindRecordById("banners", entry.Reference); err == nil {
+			entry.Subject = banner.GetString("title")
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// TimelineHandler returns a user's communications timeline for support (superusers only)
+// GET /api/admin/users/{id}/communications?channel=email&limit=100
+func TimelineHandler(e *core.RequestEvent, app core.App) error {
+	user, err := app.FindRecordById("users", e.Request.PathValue("id"))
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	channel := e.Request.URL.Query().Get("channel")
+	switch channel {
+	case "", ChannelEmail, ChannelBanner, ChannelNotification, ChannelWebhook, ChannelSMS:
+	default:
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Unknown channel"})
+	}
+
+	limit := 100
+	if l, err := strconv.Atoi(e.Request.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	entries, err := Timeline(app, user, channel, limit)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load communications"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"user_id": user.Id,
+		"email":   user.GetString("email"),
+		"entries": entries,
+	})
+}
@@ -0,0 +1,125 @@
+// Package cors holds the cross-origin policy for the API. The policy from the environment replaces
+// PocketBase's default (any origin, no credentials) for every route, and routes that need more
+// headers, e.g. the TUS upload protocol's, declare an Override
+package cors
+
+import (
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// Policy is the cross-origin access granted to browsers
+type Policy struct {
+	AllowedOrigins   []string // exact origins or patterns like https://*.example.com; "*" allows any
+	AllowCredentials bool     // lets the web frontend send cookies and auth headers; never with "*"
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	MaxAge           int // seconds browsers may cache a preflight answer
+}
+
+// Override adjusts the policy for one route. Origins and methods replace the policy's, headers are
+// added to them
+type Override struct {
+	AllowedOrigins   []string
+	AllowCredentials *bool
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+}
+
+var defaultMethods = []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE"}
+
+// PolicyFromEnv reads CORS_ALLOWED_ORIGINS (comma separated, "*" by default),
+// CORS_ALLOW_CREDENTIALS and CORS_MAX_AGE_SECONDS
+func PolicyFromEnv() Policy {
+	policy := Policy{
+		AllowedOrigins: []string{"*"},
+		AllowMethods:   defaultMethods,
+		AllowHeaders:   []string{"Authorization", "Content-Type", "X-Requested-With", "API-Version"},
+		ExposeHeaders:  []string{"API-Version", "Deprecation", "Sunset", "Link"}, // see routes.Version
+		MaxAge:         600,
+	}
+	if origins := splitList(os.Getenv("CORS_ALLOWED_ORIGINS")); len(origins) > 0 {
+		policy.AllowedOrigins = origins
+	} else if os.Getenv("DEVELOPMENT") != "true" {
+		log.Printf("[CORS] CORS_ALLOWED_ORIGINS is not set - any origin may call the API")
+	}
+	policy.AllowCredentials = os.Getenv("CORS_ALLOW_CREDENTIALS") == "true"
+	if v := os.Getenv("CORS_MAX_AGE_SECONDS"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+			policy.MaxAge = seconds
+		}
+	}
+
+	if policy.AllowCredentials && policy.allowsAnyOrigin() {
+		log.Printf("[CORS] CORS_ALLOW_CREDENTIALS ignored - credentials need an explicit CORS_ALLOWED_ORIGINS list")
+		policy.AllowCredentials = false
+	}
+	return policy
+}
+
+// Apply returns the policy with the route's override applied
+func (p Policy) Apply(o Override) Policy {
+	if len(o.AllowedOrigins) > 0 {
+		p.AllowedOrigins = o.AllowedOrigins
+	}
+	if o.AllowCredentials != nil {
+		p.AllowCredentials = *o.AllowCredentials
+	}
+	if len(o.AllowMethods) > 0 {
+		p.AllowMethods = o.AllowMethods
+	}
+	p.AllowHeaders = merge(p.AllowHeaders, o.AllowHeaders)
+	p.ExposeHeaders = merge(p.ExposeHeaders, o.ExposeHeaders)
+
+	if p.AllowCredentials && p.allowsAnyOrigin() {
+		p.AllowCredentials = false
+	}
+	return p
+}
+
+// Middleware answers preflight requests and sets the CORS headers. It shares PocketBase's CORS
+// middleware id, so binding it to the router or a route replaces the default policy there
+func (p Policy) Middleware() *hook.Handler[*core.RequestEvent] {
+	return apis.CORS(apis.CORSConfig{
+		AllowOrigins:     p.AllowedOrigins,
+		AllowCredentials: p.AllowCredentials,
+		AllowMethods:     p.AllowMethods,
+		AllowHeaders:     p.AllowHeaders,
+		ExposeHeaders:    p.ExposeHeaders,
+		MaxAge:           p.MaxAge,
+	})
+}
+
+func (p Policy) allowsAnyOrigin() bool {
+	return slices.Contains(p.AllowedOrigins, "*")
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, strings.TrimSuffix(item, "/"))
+		}
+	}
+	return items
+}
+
+// merge appends the headers base doesn't have yet, without modifying base
+func merge(base, extra []string) []string {
+	merged := slices.Clone(base)
+	for _, header := range extra {
+		if !slices.ContainsFunc(merged, func(h string) bool { return strings.EqualFold(h, header) }) {
+			merged = append(merged, header)
+		}
+	}
+	return merged
+}
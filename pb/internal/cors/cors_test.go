@@ -0,0 +1,60 @@
+package cors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://app.example.com/, https://*.example.com ")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	policy := PolicyFromEnv()
+	if want := []string{"https://app.example.com", "https://*.example.com"}; !reflect.DeepEqual(policy.AllowedOrigins, want) {
+		t.Errorf("origins = %v, want %v", policy.AllowedOrigins, want)
+	}
+	if !policy.AllowCredentials {
+		t.Error("credentials should be allowed for explicit origins")
+	}
+}
+
+func TestCredentialsNeedExplicitOrigins(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+
+	policy := PolicyFromEnv()
+	if !reflect.DeepEqual(policy.AllowedOrigins, []string{"*"}) || policy.AllowCredentials {
+		t.Errorf("got origins %v, credentials %v - want any origin without credentials", policy.AllowedOrigins, policy.AllowCredentials)
+	}
+
+	allow := true
+	if policy.Apply(Override{AllowCredentials: &allow}).AllowCredentials {
+		t.Error("an override must not enable credentials for any origin")
+	}
+}
+
+func TestApply(t *testing.T) {
+	base := Policy{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowMethods:   []string{"GET"},
+		AllowHeaders:   []string{"Authorization", "Content-Type"},
+	}
+	got := base.Apply(Override{
+		AllowHeaders:  []string{"content-type", "Upload-Offset"},
+		ExposeHeaders: []string{"Location"},
+	})
+
+	if want := []string{"Authorization", "Content-Type", "Upload-Offset"}; !reflect.DeepEqual(got.AllowHeaders, want) {
+		t.Errorf("headers = %v, want %v", got.AllowHeaders, want)
+	}
+	if !reflect.DeepEqual(got.ExposeHeaders, []string{"Location"}) || !reflect.DeepEqual(got.AllowMethods, []string{"GET"}) {
+		t.Errorf("unexpected policy %+v", got)
+	}
+	if len(base.AllowHeaders) != 2 {
+		t.Errorf("Apply modified the base policy: %v", base.AllowHeaders)
+	}
+
+	if got := base.Apply(Override{AllowedOrigins: []string{"wails://wails"}}); !reflect.DeepEqual(got.AllowedOrigins, []string{"wails://wails"}) {
+		t.Errorf("origins = %v, want the override's", got.AllowedOrigins)
+	}
+}
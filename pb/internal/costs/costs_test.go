@@ -0,0 +1,96 @@
+package costs
+
+import "testing"
+
+func TestPricingFromEnv(t *testing.T) {
+	t.Setenv("AI_MODEL_PRICES", "openai/gpt-4o=5:20, custom/model = 1:2, broken=3, bad=x:1")
+	t.Setenv("TRANSCRIPTION_PRICES", "deepgram=0.01,openai=-1,whispercpp=abc")
+
+	pricing := PricingFromEnv()
+	if got := pricing.Models["openai/gpt-4o"]; got != (ModelPrice{Prompt: 5, Completion: 20}) {
+		t.Errorf("override: got %+v", got)
+	}
+	if got := pricing.Models["custom/model"]; got != (ModelPrice{Prompt: 1, Completion: 2}) {
+		t.Errorf("added model: got %+v", got)
+	}
+	if _, ok := pricing.Models["broken"]; ok {
+		t.Error("entries without a completion price should be ignored")
+	}
+	if _, ok := pricing.Models["bad"]; ok {
+		t.Error("entries with invalid prices should be ignored")
+	}
+	if got := pricing.Models["openai/gpt-4o-mini"]; got != defaultModelPrices["openai/gpt-4o-mini"] {
+		t.Errorf("built-in price lost: got %+v", got)
+	}
+	if pricing.Transcription["deepgram"] != 0.01 || pricing.Transcription["openai"] != 0.006 || pricing.Transcription["whispercpp"] != 0 {
+		t.Errorf("transcription prices = %v", pricing.Transcription)
+	}
+}
+
+func TestCosts(t *testing.T) {
+	pricing := Pricing{
+		Models:        map[string]ModelPrice{"m": {Prompt: 3, Completion: 15}},
+		Transcription: map[string]float64{"openai": 0.006},
+	}
+
+	if cost, priced := pricing.TextCost("m", 1000, 500); !priced || cost != 0.0105 {
+		t.Errorf("TextCost() = %v, %v", cost, priced)
+	}
+	if cost, priced := pricing.TextCost("unknown", 1000, 500); priced || cost != 0 {
+		t.Errorf("unknown model: TextCost() = %v, %v", cost, priced)
+	}
+	if cost, priced := pricing.TranscriptionCost("openai", 90); !priced || cost != 0.009 {
+		t.Errorf("TranscriptionCost() = %v, %v", cost, priced)
+	}
+	if cost, priced := pricing.TranscriptionCost("deepgram", 90); priced || cost != 0 {
+		t.Errorf("unknown provider: TranscriptionCost() = %v, %v", cost, priced)
+	}
+
+	entry := Entry{Kind: KindTranscription, Provider: "openai", Model: "m", AudioSeconds: 60, PromptTokens: 1000}
+	if cost, _ := entry.Cost(pricing); cost != 0.006 {
+		t.Errorf("transcription entry cost = %v", cost)
+	}
+	entry.Kind = KindText
+	if cost, _ := entry.Cost(pricing); cost != 0.003 {
+		t.Errorf("text entry cost = %v", cost)
+	}
+}
+
+func TestMonthlyRevenue(t *testing.T) {
+	tests := []struct {
+		cents    int
+		interval string
+		want     float64
+	}{
+		{1500, "month", 15},
+		{12000, "year", 10},
+		{10000, "year", 8.333333},
+		{1500, "free", 0},
+		{1500, "", 0},
+	}
+	for _, tt := range tests {
+		if got := monthlyRevenue(tt.cents, tt.interval); got != tt.want {
+			t.Errorf("monthlyRevenue(%d, %q) = %v, want %v", tt.cents, tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestToGroupAndSort(t *testing.T) {
+	groups := []Group{
+		toGroup(ledgerRow{UserID: "cheap", Users: 1, TextCost: 0.5}, nil),
+		toGroup(ledgerRow{UserID: "heavy", Users: 1, TextCost: 3, TranscriptionCost: 2}, nil),
+		toGroup(ledgerRow{UserID: "light", Users: 1, TranscriptionCost: 0.25}, nil),
+	}
+	if g := groups[1]; g.Cost != 5 || g.Margin != -5 || !g.Unprofitable {
+		t.Errorf("group = %+v", g)
+	}
+
+	sortByMargin(groups)
+	var order []string
+	for _, g := range groups {
+		order = append(order, g.UserID)
+	}
+	if order[0] != "heavy" || order[1] != "cheap" || order[2] != "light" {
+		t.Errorf("order = %v, want least profitable first", order)
+	}
+}
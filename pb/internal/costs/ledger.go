@@ -0,0 +1,66 @@
+package costs
+
+import (
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Kinds of ledger entries
+const (
+	KindText          = "text"
+	KindTranscription = "transcription"
+)
+
+// Entry is one upstream AI call to record in the cost ledger
+type Entry struct {
+	UserID         string
+	OrganizationID string // organization the usage was charged to, if any
+	PlanID         string // the user's plan when the call was made
+	Kind           string
+	TaskType       string
+	Provider       string // openrouter, or the transcription provider
+	Model          string
+
+	// Text calls
+	PromptTokens     int
+	CompletionTokens int
+	TokensEstimated  bool // the provider didn't report usage
+
+	// Transcriptions
+	AudioSeconds float64
+}
+
+// Cost estimates the entry's upstream cost in USD with the current price list
+func (e Entry) Cost(pricing Pricing) (cost float64, priced bool) {
+	if e.Kind == KindTranscription {
+		return pricing.TranscriptionCost(e.Provider, e.AudioSeconds)
+	}
+	return pricing.TextCost(e.Model, e.PromptTokens, e.CompletionTokens)
+}
+
+// Record prices an AI call and adds it to the cost ledger under the current month (UTC)
+func Record(app core.App, entry Entry) error {
+	collection, err := app.FindCollectionByNameOrId("cost_ledger")
+	if err != nil {
+		return err
+	}
+	cost, priced := entry.Cost(PricingFromEnv())
+
+	record := core.NewRecord(collection)
+	record.Set("user_id", entry.UserID)
+	record.Set("organization_id", entry.OrganizationID)
+	record.Set("plan_id", entry.PlanID)
+	record.Set("year_month", time.Now().UTC().Format("2006-01"))
+	record.Set("kind", entry.Kind)
+	record.Set("task_type", entry.TaskType)
+	record.Set("provider", entry.Provider)
+	record.Set("model", entry.Model)
+	record.Set("prompt_tokens", entry.PromptTokens)
+	record.Set("completion_tokens", entry.CompletionTokens)
+	record.Set("tokens_estimated", entry.TokensEstimated)
+	record.Set("audio_seconds", entry.AudioSeconds)
+	record.Set("cost_usd", cost)
+	record.Set("priced", priced)
+	return app.Save(record)
+}
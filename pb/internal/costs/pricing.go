@@ -0,0 +1,106 @@
+// Package costs estimates what each AI call costs upstream - OpenRouter tokens and transcription
+// minutes - and keeps a ledger of it per user, plan and month, so unprofitable customers show up
+package costs
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ModelPrice is an OpenRouter model's price in USD per million tokens
+type ModelPrice struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+// Built-in prices of the allowlisted models, used unless AI_MODEL_PRICES overrides them
+var defaultModelPrices = map[string]ModelPrice{
+	"anthropic/claude-3.5-sonnet":      {Prompt: 3, Completion: 15},
+	"anthropic/claude-3-haiku":         {Prompt: 0.25, Completion: 1.25},
+	"openai/gpt-4o":                    {Prompt: 2.5, Completion: 10},
+	"openai/gpt-4o-mini":               {Prompt: 0.15, Completion: 0.6},
+	"google/gemini-flash-1.5":          {Prompt: 0.075, Completion: 0.3},
+	"meta-llama/llama-3.1-8b-instruct": {Prompt: 0.02, Completion: 0.05},
+}
+
+// Built-in transcription prices in USD per audio minute, keyed by provider name (see the
+// whisper package), used unless TRANSCRIPTION_PRICES overrides them. whispercpp is self-hosted
+var defaultTranscriptionPrices = map[string]float64{
+	"openai":     0.006,
+	"fallback":   0.006,
+	"deepgram":   0.0043,
+	"assemblyai": 0.0062,
+	"whispercpp": 0,
+}
+
+// Pricing is the price list costs are estimated with
+type Pricing struct {
+	Models        map[string]ModelPrice `json:"models"`
+	Transcription map[string]float64    `json:"transcription"` // USD per minute
+}
+
+// PricingFromEnv reads AI_MODEL_PRICES ("openai/gpt-4o=2.5:10,...", USD per million prompt and
+// completion tokens) and TRANSCRIPTION_PRICES ("openai=0.006,deepgram=0.0043", USD per minute)
+// on top of the built-in prices
+func PricingFromEnv() Pricing {
+	pricing := Pricing{Models: map[string]ModelPrice{}, Transcription: map[string]float64{}}
+	for model, price := range defaultModelPrices {
+		pricing.Models[model] = price
+	}
+	for provider, price := range defaultTranscriptionPrices {
+		pricing.Transcription[provider] = price
+	}
+
+	for _, pair := range strings.Split(os.Getenv("AI_MODEL_PRICES"), ",") {
+		model, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		prompt, completion, ok := strings.Cut(value, ":")
+		if !ok {
+			continue
+		}
+		p, err1 := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		c, err2 := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if err1 == nil && err2 == nil && p >= 0 && c >= 0 {
+			pricing.Models[strings.TrimSpace(model)] = ModelPrice{Prompt: p, Completion: c}
+		}
+	}
+	for _, pair := range strings.Split(os.Getenv("TRANSCRIPTION_PRICES"), ",") {
+		provider, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && v >= 0 {
+			pricing.Transcription[strings.TrimSpace(provider)] = v
+		}
+	}
+	return pricing
+}
+
+// TextCost is the cost of a completion. priced is false for models missing from the price
+// list, whose cost is then 0
+func (p Pricing) TextCost(model string, promptTokens, completionTokens int) (cost float64, priced bool) {
+	price, ok := p.Models[model]
+	if !ok {
+		return 0, false
+	}
+	return roundUSD((float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6), true
+}
+
+// TranscriptionCost is the cost of transcribing audioSeconds of audio with a provider. priced is
+// false for providers missing from the price list, whose cost is then 0
+func (p Pricing) TranscriptionCost(provider string, audioSeconds float64) (cost float64, priced bool) {
+	price, ok := p.Transcription[provider]
+	if !ok {
+		return 0, false
+	}
+	return roundUSD(max(audioSeconds, 0) / 60 * price), true
+}
+
+// roundUSD keeps costs to millionths of a dollar, so sums don't drift
+func roundUSD(usd float64) float64 {
+	return math.Round(usd*1e6) / 1e6
+}
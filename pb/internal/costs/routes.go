@@ -0,0 +1,20 @@
+package costs
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the cost summary route (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/costs/summary", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return SummaryHandler(e, app)
+		}},
+	)
+}
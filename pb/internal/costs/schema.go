@@ -0,0 +1,34 @@
+package costs
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the cost ledger reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "costs",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "cost_ledger",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "organization_id", Type: "text"},
+					{Name: "plan_id", Type: "text"},
+					{Name: "year_month", Type: "text"},
+					{Name: "kind", Type: "select", Values: []string{KindText, KindTranscription}},
+					{Name: "task_type", Type: "text"},
+					{Name: "provider", Type: "text"},
+					{Name: "model", Type: "text"},
+					{Name: "prompt_tokens", Type: "number"},
+					{Name: "completion_tokens", Type: "number"},
+					{Name: "tokens_estimated", Type: "bool"},
+					{Name: "audio_seconds", Type: "number"},
+					{Name: "cost_usd", Type: "number"},
+					{Name: "priced", Type: "bool"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_cost_ledger_month_user", Columns: "year_month, user_id"},
+				},
+			},
+		},
+	}
+}
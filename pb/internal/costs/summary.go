@@ -0,0 +1,220 @@
+package costs
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Group is the month's cost of one user or plan next to what it pays. Revenue is the plan's
+// monthly price (yearly prices spread over 12 months), taken to be in USD
+type Group struct {
+	UserID            string  `json:"user_id,omitempty"`
+	Email             string  `json:"email,omitempty"`
+	PlanID            string  `json:"plan_id"`
+	PlanName          string  `json:"plan_name"`
+	Users             int     `json:"users"`
+	Requests          int     `json:"requests"`
+	PromptTokens      int     `json:"prompt_tokens"`
+	CompletionTokens  int     `json:"completion_tokens"`
+	AudioSeconds      float64 `json:"audio_seconds"`
+	TextCost          float64 `json:"text_cost_usd"`
+	TranscriptionCost float64 `json:"transcription_cost_usd"`
+	Cost              float64 `json:"cost_usd"`
+	Revenue           float64 `json:"revenue_usd"`
+	Margin            float64 `json:"margin_usd"`
+	Unprofitable      bool    `json:"unprofitable"`
+	UnpricedRequests  int     `json:"unpriced_requests"` // calls to models or providers missing from the price list
+}
+
+// ledgerRow is one user's (or plan's) aggregated ledger entries for a month
+type ledgerRow struct {
+	UserID            string  `db:"user_id"`
+	PlanID            string  `db:"plan_id"`
+	Users             int     `db:"users"`
+	Requests          int     `db:"requests"`
+	PromptTokens      int     `db:"prompt_tokens"`
+	CompletionTokens  int     `db:"completion_tokens"`
+	AudioSeconds      float64 `db:"audio_seconds"`
+	TextCost          float64 `db:"text_cost"`
+	TranscriptionCost float64 `db:"transcription_cost"`
+	Unpriced          int     `db:"unpriced"`
+}
+
+const ledgerSums = `COUNT(*) AS requests,
+	COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+	COALESCE(SUM(completion_tokens), 0) AS completion_tokens,
+	COALESCE(SUM(audio_seconds), 0) AS audio_seconds,
+	COALESCE(SUM(CASE WHEN kind = 'text' THEN cost_usd ELSE 0 END), 0) AS text_cost,
+	COALESCE(SUM(CASE WHEN kind = 'transcription' THEN cost_usd ELSE 0 END), 0) AS transcription_cost,
+	COALESCE(SUM(CASE WHEN priced THEN 0 ELSE 1 END), 0) AS unpriced`
+
+// A user is put on the plan of their latest entry in the month
+const userSummaryQuery = `SELECT user_id,
+	(SELECT latest.plan_id FROM cost_ledger latest WHERE latest.user_id = cost_ledger.user_id
+		AND latest.year_month = {:month} ORDER BY latest.created DESC LIMIT 1) AS plan_id,
+	1 AS users, ` + ledgerSums + `
+	FROM cost_ledger WHERE year_month = {:month} GROUP BY user_id`
+
+const planSummaryQuery = `SELECT plan_id, COUNT(DISTINCT user_id) AS users, ` + ledgerSums + `
+	FROM cost_ledger WHERE year_month = {:month} GROUP BY plan_id`
+
+// monthlyRevenue is what a plan brings in per month and subscriber, in dollars
+func monthlyRevenue(priceCents int, billingInterval string) float64 {
+	switch billingInterval {
+	case "month":
+		return float64(priceCents) / 100
+	case "year":
+		return roundUSD(float64(priceCents) / 100 / 12)
+	default:
+		return 0
+	}
+}
+
+// toGroup prices a ledger row against its plan. Plan revenue is counted once per user who made
+// calls - subscribers who didn't use the service that month aren't in the ledger
+func toGroup(row ledgerRow, plan *core.Record) Group {
+	group := Group{
+		UserID:            row.UserID,
+		PlanID:            row.PlanID,
+		Users:             row.Users,
+		Requests:          row.Requests,
+		PromptTokens:      row.PromptTokens,
+		CompletionTokens:  row.CompletionTokens,
+		AudioSeconds:      row.AudioSeconds,
+		TextCost:          roundUSD(row.TextCost),
+		TranscriptionCost: roundUSD(row.TranscriptionCost),
+		Cost:              roundUSD(row.TextCost + row.TranscriptionCost),
+		UnpricedRequests:  row.Unpriced,
+	}
+	if plan != nil {
+		group.PlanName = plan.GetString("name")
+		group.Revenue = roundUSD(monthlyRevenue(plan.GetInt("price_cents"), plan.GetString("billing_interval")) * float64(row.Users))
+	}
+	group.Margin = roundUSD(group.Revenue - group.Cost)
+	group.Unprofitable = group.Margin < 0
+	return group
+}
+
+// sortByMargin puts the least profitable groups first
+func sortByMargin(groups []Group) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Margin != groups[j].Margin {
+			return groups[i].Margin < groups[j].Margin
+		}
+		return groups[i].Cost > groups[j].Cost
+	})
+}
+
+// Summarize returns a month's costs by user or by plan, least profitable first
+func Summarize(app core.App, month, groupBy string) ([]Group, error) {
+	query := userSummaryQuery
+	if groupBy == "plan" {
+		query = planSummaryQuery
+	}
+	var rows []ledgerRow
+	if err := app.DB().NewQuery(query).Bind(dbx.Params{"month": month}).All(&rows); err != nil {
+		return nil, err
+	}
+
+	var planIDs, userIDs []string
+	for _, row := range rows {
+		if row.PlanID != "" {
+			planIDs = append(planIDs, row.PlanID)
+		}
+		if row.UserID != "" {
+			userIDs = append(userIDs, row.UserID)
+		}
+	}
+	plans := map[string]*core.Record{}
+	if len(planIDs) > 0 {
+		records, err := app.FindRecordsByIds("subscription_plans", planIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, plan := range records {
+			plans[plan.Id] = plan
+		}
+	}
+	emails := map[string]string{}
+	if len(userIDs) > 0 {
+		records, err := app.FindRecordsByIds("users", userIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range records {
+			emails[user.Id] = user.Email()
+		}
+	}
+
+	groups := make([]Group, 0, len(rows))
+	for _, row := range rows {
+		group := toGroup(row, plans[row.PlanID])
+		group.Email = emails[row.UserID]
+		groups = append(groups, group)
+	}
+	sortByMargin(groups)
+	return groups, nil
+}
+
+// SummaryHandler reports a month's upstream AI costs against plan revenue (superusers only)
+// GET /api/admin/costs/summary?month=YYYY-MM&group_by=user|plan&limit=100 - defaults to the
+// current month by user. Least profitable first
+func SummaryHandler(e *core.RequestEvent, app core.App) error {
+	query := e.Request.URL.Query()
+	month := query.Get("month")
+	if month == "" {
+		month = time.Now().UTC().Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "month must be in YYYY-MM format"})
+	}
+	groupBy := query.Get("group_by")
+	if groupBy == "" {
+		groupBy = "user"
+	}
+	if groupBy != "user" && groupBy != "plan" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "group_by must be user or plan"})
+	}
+	limit := 100
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "limit must be between 1 and 1000"})
+		}
+		limit = parsed
+	}
+
+	groups, err := Summarize(app, month, groupBy)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load cost ledger"})
+	}
+
+	var totalCost, totalRevenue float64
+	unprofitable := 0
+	for _, group := range groups {
+		totalCost += group.Cost
+		totalRevenue += group.Revenue
+		if group.Unprofitable {
+			unprofitable++
+		}
+	}
+	if len(groups) > limit {
+		groups = groups[:limit]
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"month":        month,
+		"group_by":     groupBy,
+		"cost_usd":     roundUSD(totalCost),
+		"revenue_usd":  roundUSD(totalRevenue),
+		"margin_usd":   roundUSD(totalRevenue - totalCost),
+		"unprofitable": unprofitable,
+		"groups":       groups,
+		"pricing":      PricingFromEnv(),
+	})
+}
@@ -0,0 +1,92 @@
+package diagnostics
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// defaultExportRetention is how many snapshots per profile type are kept on disk
+const defaultExportRetention = 48
+
+// StartProfileExporter periodically writes heap and goroutine profiles to PROFILE_EXPORT_DIR
+// It is disabled unless PROFILE_EXPORT_DIR is set. PROFILE_EXPORT_INTERVAL_MINUTES defaults to 15.
+func StartProfileExporter() {
+	dir := os.Getenv("PROFILE_EXPORT_DIR")
+	if dir == "" {
+		return
+	}
+
+	interval := 15 * time.Minute
+	if v := os.Getenv("PROFILE_EXPORT_INTERVAL_MINUTES"); v != "" {
+		if minutes, err := strconv.Atoi(v); err == nil && minutes > 0 {
+			interval = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("[PROFILING] ERROR: Failed to create profile export dir %s: %v", dir, err)
+		return
+	}
+
+	log.Printf("[PROFILING] Exporting heap and goroutine profiles to %s every %v", dir, interval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			for _, name := range []string{"heap", "goroutine"} {
+				if err := exportProfile(dir, name); err != nil {
+					log.Printf("[PROFILING] ERROR: Failed to export %s profile: %v", name, err)
+				}
+			}
+		}
+	}()
+}
+
+// exportProfile writes a single profile snapshot and prunes old snapshots of the same type
+func exportProfile(dir, name string) error {
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %s", name)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.pb.gz", name, time.Now().UTC().Format("20060102-150405")))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := profile.WriteTo(file, 0); err != nil {
+		return err
+	}
+
+	return pruneProfiles(dir, name, defaultExportRetention)
+}
+
+// pruneProfiles removes the oldest snapshots beyond the retention limit
+func pruneProfiles(dir, name string, keep int) error {
+	matches, err := filepath.Glob(filepath.Join(dir, name+"-*.pb.gz"))
+	if err != nil {
+		return err
+	}
+	if len(matches) <= keep {
+		return nil
+	}
+
+	// Timestamped names sort chronologically
+	sort.Strings(matches)
+	for _, path := range matches[:len(matches)-keep] {
+		if err := os.Remove(path); err != nil {
+			log.Printf("[PROFILING] Failed to remove old profile %s: %v", path, err)
+		}
+	}
+	return nil
+}
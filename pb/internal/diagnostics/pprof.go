@@ -0,0 +1,50 @@
+package diagnostics
+
+import (
+	"net/http"
+	"net/http/pprof"
+	runtimepprof "runtime/pprof"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ProfilesHandler lists the available runtime profiles (superusers only)
+// Fetch one with: curl -H "Authorization: <superuser token>" .../api/admin/debug/pprof/heap > heap.pb.gz
+// then inspect it locally with: go tool pprof heap.pb.gz
+func ProfilesHandler(e *core.RequestEvent) error {
+	profiles := []map[string]interface{}{}
+	for _, p := range runtimepprof.Profiles() {
+		profiles = append(profiles, map[string]interface{}{
+			"name":  p.Name(),
+			"count": p.Count(),
+		})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"profiles": profiles,
+		"extra":    []string{"profile (CPU, ?seconds=30)", "trace (?seconds=5)"},
+	})
+}
+
+// ProfileHandler serves a named runtime profile such as heap, goroutine, allocs or block (superusers only)
+func ProfileHandler(e *core.RequestEvent) error {
+	name := e.Request.PathValue("name")
+	if runtimepprof.Lookup(name) == nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Unknown profile"})
+	}
+
+	pprof.Handler(name).ServeHTTP(e.Response, e.Request)
+	return nil
+}
+
+// CPUProfileHandler records a CPU profile for ?seconds= (default 30) (superusers only)
+func CPUProfileHandler(e *core.RequestEvent) error {
+	pprof.Profile(e.Response, e.Request)
+	return nil
+}
+
+// TraceHandler records an execution trace for ?seconds= (default 1) (superusers only)
+func TraceHandler(e *core.RequestEvent) error {
+	pprof.Trace(e.Response, e.Request)
+	return nil
+}
@@ -0,0 +1,18 @@
+package diagnostics
+
+import (
+	"net/http"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds profiling and runtime metrics for diagnosing memory growth (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/debug/runtime", Auth: routes.AuthSuperuser, Handler: RuntimeStatsHandler},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/debug/pprof", Auth: routes.AuthSuperuser, Handler: ProfilesHandler},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/debug/pprof/profile", Auth: routes.AuthSuperuser, Handler: CPUProfileHandler},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/debug/pprof/trace", Auth: routes.AuthSuperuser, Handler: TraceHandler},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/debug/pprof/{name}", Auth: routes.AuthSuperuser, Handler: ProfileHandler},
+	)
+}
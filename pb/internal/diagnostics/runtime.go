@@ -0,0 +1,71 @@
+package diagnostics
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// startedAt is used to report process uptime
+var startedAt = time.Now()
+
+// RuntimeStats is a snapshot of Go runtime metrics relevant to memory growth
+type RuntimeStats struct {
+	UptimeSeconds int64     `json:"uptime_seconds"`
+	Goroutines    int       `json:"goroutines"`
+	HeapAllocMB   float64   `json:"heap_alloc_mb"`
+	HeapInuseMB   float64   `json:"heap_inuse_mb"`
+	HeapObjects   uint64    `json:"heap_objects"`
+	SysMB         float64   `json:"sys_mb"`
+	NumGC         uint32    `json:"num_gc"`
+	LastGCPauseMs float64   `json:"last_gc_pause_ms"`
+	MaxGCPauseMs  float64   `json:"max_recent_gc_pause_ms"`
+	GCCPUFraction float64   `json:"gc_cpu_fraction"`
+	LastGC        time.Time `json:"last_gc"`
+}
+
+// CollectRuntimeStats reads the current Go runtime metrics
+func CollectRuntimeStats() RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	stats := RuntimeStats{
+		UptimeSeconds: int64(time.Since(startedAt).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		HeapAllocMB:   toMB(m.HeapAlloc),
+		HeapInuseMB:   toMB(m.HeapInuse),
+		HeapObjects:   m.HeapObjects,
+		SysMB:         toMB(m.Sys),
+		NumGC:         m.NumGC,
+		GCCPUFraction: m.GCCPUFraction,
+	}
+
+	if m.NumGC > 0 {
+		stats.LastGC = time.Unix(0, int64(m.LastGC))
+		stats.LastGCPauseMs = float64(m.PauseNs[(m.NumGC+255)%256]) / 1e6
+
+		// PauseNs is a circular buffer of the most recent 256 pauses
+		recent := m.NumGC
+		if recent > 256 {
+			recent = 256
+		}
+		for i := uint32(0); i < recent; i++ {
+			if pause := float64(m.PauseNs[i]) / 1e6; pause > stats.MaxGCPauseMs {
+				stats.MaxGCPauseMs = pause
+			}
+		}
+	}
+
+	return stats
+}
+
+// RuntimeStatsHandler returns goroutine, heap and GC metrics (superusers only)
+func RuntimeStatsHandler(e *core.RequestEvent) error {
+	return e.JSON(http.StatusOK, CollectRuntimeStats())
+}
+
+func toMB(bytes uint64) float64 {
+	return float64(bytes) / 1024 / 1024
+}
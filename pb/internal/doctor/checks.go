@@ -0,0 +1,229 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/audioprep"
+	"pocketbase/internal/gateway"
+	"pocketbase/internal/moderation"
+	"pocketbase/internal/schemacheck"
+	"pocketbase/internal/whisper"
+)
+
+type checker struct {
+	opts Options
+}
+
+// missing reports an unset variable - a failure in production, a warning in development
+func (c checker) missing(variable, impact string) Result {
+	status := StatusFail
+	if c.opts.Development {
+		status = StatusWarn
+	}
+	return Result{Status: status, Message: variable + " is not set - " + impact, Fix: "set " + variable + " in the environment or .env"}
+}
+
+// ping calls a provider endpoint with the credential and returns the status code and start of the body
+func (c checker) ping(ctx context.Context, url string, auth gateway.Auth) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	auth.Apply(req.Header)
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return resp.StatusCode, string(body), nil
+}
+
+// pingResult turns a provider ping into a result: rejected credentials fail, an unreachable provider only warns
+func (c checker) pingResult(ctx context.Context, url string, auth gateway.Auth, variable, provider string) Result {
+	status, _, err := c.ping(ctx, url, auth)
+	switch {
+	case err != nil:
+		return Result{Status: StatusWarn, Message: fmt.Sprintf("couldn't reach %s: %v", provider, err), Fix: "check outbound network access to " + url}
+	case status == http.StatusOK:
+		return Result{Status: StatusOK, Message: provider + " accepted the key"}
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return Result{Status: StatusFail, Message: fmt.Sprintf("%s rejected the key (HTTP %d)", provider, status), Fix: "replace " + variable + " with an active key from the " + provider + " dashboard"}
+	default:
+		return Result{Status: StatusWarn, Message: fmt.Sprintf("%s answered HTTP %d", provider, status), Fix: "retry later - the key couldn't be verified"}
+	}
+}
+
+func (c checker) stripeKey(ctx context.Context) Result {
+	key := os.Getenv("STRIPE_SECRET_KEY")
+	if key == "" {
+		return c.missing("STRIPE_SECRET_KEY", "checkout, plan changes and the billing portal will fail")
+	}
+	if !strings.HasPrefix(key, "sk_") && !strings.HasPrefix(key, "rk_") {
+		return Result{Status: StatusFail, Message: "STRIPE_SECRET_KEY doesn't look like a secret key", Fix: "use a secret (sk_) or restricted (rk_) key - publishable (pk_) keys can't call the API"}
+	}
+
+	result := c.pingResult(ctx, c.opts.Endpoints.Stripe, gateway.Auth{APIKey: key}, "STRIPE_SECRET_KEY", "Stripe")
+	if result.Status == StatusOK && !c.opts.Development && strings.Contains(key, "_test_") {
+		return Result{Status: StatusWarn, Message: "STRIPE_SECRET_KEY is a test mode key in production", Fix: "use the live mode key"}
+	}
+	return result
+}
+
+func (c checker) stripeWebhookSecret(context.Context) Result {
+	secret := os.Getenv("STRIPE_SECRET_WHSEC")
+	if secret == "" {
+		return c.missing("STRIPE_SECRET_WHSEC", "webhook signatures can't be verified")
+	}
+	if !strings.HasPrefix(secret, "whsec_") || len(secret) < 20 {
+		return Result{Status: StatusFail, Message: "STRIPE_SECRET_WHSEC doesn't look like a webhook signing secret", Fix: "copy the signing secret (whsec_...) of the /api/webhooks/stripe endpoint from the Stripe dashboard"}
+	}
+	return Result{Status: StatusOK, Message: "webhook signing secret is set"}
+}
+
+func (c checker) openAIKey(ctx context.Context) Result {
+	openAI := gateway.OpenAIFromEnv()
+	if openAI.APIKey == "" {
+		return c.missing("OPENAI_API_KEY", "audio transcription will fail")
+	}
+	return c.pingResult(ctx, c.opts.Endpoints.OpenAI, openAI.Auth, "OPENAI_API_KEY", "OpenAI")
+}
+
+// transcriptionProviders checks every provider in the transcription chain has its credentials
+func (c checker) transcriptionProviders(ctx context.Context) Result {
+	chain := whisper.ProviderChain()
+	if _, err := whisper.ProviderFromEnv(chain[0]); err != nil {
+		return Result{Status: StatusFail, Message: fmt.Sprintf("transcription provider %s isn't usable: %v", chain[0], err), Fix: "set TRANSCRIPTION_PROVIDER to a configured provider or add its credentials"}
+	}
+	if len(chain) == 1 {
+		return Result{Status: StatusWarn, Message: "transcription via " + chain[0] + " with no fallback provider", Fix: "set TRANSCRIPTION_FALLBACK_PROVIDERS to fail over during outages"}
+	}
+	return Result{Status: StatusOK, Message: "transcription via " + strings.Join(chain, ", then ")}
+}
+
+// audioPreprocessing checks ffmpeg is installed unless audio compression is turned off
+func (c checker) audioPreprocessing(context.Context) Result {
+	config := audioprep.ConfigFromEnv()
+	switch {
+	case config.Mode == audioprep.ModeOff:
+		return Result{Status: StatusSkip, Message: "audio compression disabled (AUDIO_PREPROCESS=off)"}
+	case config.FFmpegPath == "":
+		return Result{Status: StatusWarn, Message: "ffmpeg not found - audio is sent uncompressed", Fix: "install ffmpeg, set FFMPEG_PATH, or set AUDIO_PREPROCESS=off"}
+	}
+	return Result{Status: StatusOK, Message: fmt.Sprintf("compressing audio with %s (%s mode)", config.FFmpegPath, config.Mode)}
+}
+
+// moderation checks the content moderation provider is usable when one is configured
+func (c checker) moderation(context.Context) Result {
+	config, err := moderation.ConfigFromEnv()
+	switch {
+	case err != nil:
+		return Result{Status: StatusFail, Message: fmt.Sprintf("content moderation misconfigured: %v", err), Fix: "fix MODERATION_PROVIDER / MODERATION_BLOCKLIST, or unset MODERATION_PROVIDER"}
+	case config.Moderator == nil:
+		return Result{Status: StatusSkip, Message: "content moderation disabled (MODERATION_PROVIDER unset)"}
+	case config.Moderator.Name() == moderation.ProviderOpenAI && gateway.OpenAIFromEnv().APIKey == "":
+		return c.missing("OPENAI_API_KEY", "content moderation will fail")
+	}
+	return Result{Status: StatusOK, Message: fmt.Sprintf("moderating with %s (prompts: %t, transcripts: %s)", config.Moderator.Name(), config.Prompts, config.Transcript)}
+}
+
+func (c checker) openRouterKey(ctx context.Context) Result {
+	openRouter := gateway.OpenRouterFromEnv()
+	if openRouter.APIKey == "" {
+		return c.missing("OPENROUTER_API_KEY", "text processing will fail")
+	}
+	return c.pingResult(ctx, c.opts.Endpoints.OpenRouter, openRouter.Auth, "OPENROUTER_API_KEY", "OpenRouter")
+}
+
+// email checks SMTP in development (Mailpit) and the Resend API in production, where SMTP is
+// the fallback
+func (c checker) email(ctx context.Context) Result {
+	if c.opts.Development {
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return Result{Status: StatusWarn, Message: "SMTP_HOST is not set - email verification is disabled", Fix: "set SMTP_HOST and SMTP_PORT (Mailpit listens on 1025)"}
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			return Result{Status: StatusFail, Message: fmt.Sprintf("can't connect to SMTP %s:%s: %v", host, port, err), Fix: "start Mailpit or fix SMTP_HOST / SMTP_PORT"}
+		}
+		conn.Close()
+		return Result{Status: StatusOK, Message: fmt.Sprintf("SMTP %s:%s accepts connections", host, port)}
+	}
+
+	key := os.Getenv("RESEND_API_KEY")
+	if key == "" {
+		if os.Getenv("SMTP_HOST") != "" {
+			return Result{Status: StatusWarn, Message: "RESEND_API_KEY is not set - emails go through SMTP only, with no fallback", Fix: "set RESEND_API_KEY in the environment or .env"}
+		}
+		return c.missing("RESEND_API_KEY", "verification and OTP emails won't be sent")
+	}
+	status, body, err := c.ping(ctx, c.opts.Endpoints.Resend, gateway.Auth{APIKey: key})
+	if err == nil && status == http.StatusUnauthorized && strings.Contains(body, "restricted") {
+		// Sending-only keys can't list domains, but they're valid
+		return Result{Status: StatusOK, Message: "Resend accepted the key (sending access only)"}
+	}
+	return c.pingResult(ctx, c.opts.Endpoints.Resend, gateway.Auth{APIKey: key}, "RESEND_API_KEY", "Resend")
+}
+
+func (c checker) schema(app core.App) Result {
+	problems := schemacheck.Check(app, c.opts.SchemaModules...)
+	if len(problems) == 0 {
+		return Result{Status: StatusOK, Message: fmt.Sprintf("schema matches the requirements of %d modules", len(c.opts.SchemaModules))}
+	}
+
+	first := problems[0]
+	return Result{
+		Status:  StatusFail,
+		Message: fmt.Sprintf("%d schema problems, e.g. [%s] %s: %s", len(problems), first.Module, first.Collection, first.Detail),
+		Fix:     "apply the migrations logged with [SCHEMA] at startup, starting with: " + first.Suggestion,
+	}
+}
+
+// dataDirs checks that the data dir, and local file storage when S3 isn't used, are writable
+func (c checker) dataDirs(app core.App) Result {
+	dirs := []string{app.DataDir()}
+	if !app.Settings().S3.Enabled {
+		dirs = append(dirs, filepath.Join(app.DataDir(), core.LocalStorageDirName))
+	}
+
+	for _, dir := range dirs {
+		if err := CheckWritable(dir); err != nil {
+			return Result{Status: StatusFail, Message: fmt.Sprintf("%s isn't writable: %v", dir, err), Fix: "fix the permissions or mount of " + dir}
+		}
+	}
+	return Result{Status: StatusOK, Message: strings.Join(dirs, ", ") + " writable"}
+}
+
+// CheckWritable creates dir if needed and writes and removes a probe file in it
+func CheckWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	_, writeErr := f.WriteString("ok")
+	closeErr := f.Close()
+	os.Remove(name)
+	if writeErr != nil {
+		return writeErr
+	}
+	return closeErr
+}
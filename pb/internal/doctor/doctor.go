@@ -0,0 +1,168 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/gateway"
+	"pocketbase/internal/schemacheck"
+)
+
+// Check outcomes
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Startup modes read from STARTUP_DOCTOR
+const (
+	ModeOff  = "off"
+	ModeWarn = "warn" // report problems and start anyway
+	ModeFail = "fail" // refuse to start while a check fails
+)
+
+// Result is the outcome of one check, with the fix when it didn't pass
+type Result struct {
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Message  string        `json:"message"`
+	Fix      string        `json:"fix,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report holds the results in the order the checks are declared
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Count returns how many results have the given status
+func (r Report) Count(status string) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes the report as a table followed by the fixes for every problem
+func (r Report) Print(w io.Writer) {
+	labels := map[string]string{StatusOK: "OK  ", StatusWarn: "WARN", StatusFail: "FAIL", StatusSkip: "SKIP"}
+	for _, result := range r.Results {
+		fmt.Fprintf(w, "[%s] %-22s %s\n", labels[result.Status], result.Name, result.Message)
+		if result.Fix != "" && (result.Status == StatusFail || result.Status == StatusWarn) {
+			fmt.Fprintf(w, "       %-22s fix: %s\n", "", result.Fix)
+		}
+	}
+	fmt.Fprintf(w, "\n%d ok, %d warnings, %d failures, %d skipped\n",
+		r.Count(StatusOK), r.Count(StatusWarn), r.Count(StatusFail), r.Count(StatusSkip))
+}
+
+// Options configures a doctor run
+type Options struct {
+	Development   bool                 // relaxes checks for services that are optional locally
+	SchemaModules []schemacheck.Module // requirements checked against the deployed schema
+	Timeout       time.Duration        // per network check, 5s by default
+	HTTPClient    *http.Client
+	Endpoints     Endpoints
+}
+
+// Endpoints are the provider URLs pinged to validate credentials
+type Endpoints struct {
+	Stripe     string
+	OpenAI     string
+	OpenRouter string
+	Resend     string
+}
+
+// DefaultEndpoints are the production provider endpoints
+var DefaultEndpoints = Endpoints{
+	Stripe:     "https://api.stripe.com/v1/balance",
+	OpenAI:     gateway.DefaultOpenAIBaseURL + "/models",
+	OpenRouter: gateway.DefaultOpenRouterBaseURL + "/key",
+	Resend:     "https://api.resend.com/domains",
+}
+
+// EndpointsFromEnv returns DefaultEndpoints with OpenAI and OpenRouter pinged at OPENAI_BASE_URL
+// and OPENROUTER_BASE_URL. A gateway standing in for OpenRouter is pinged at /models, as /key
+// only exists on OpenRouter
+func EndpointsFromEnv() Endpoints {
+	endpoints := DefaultEndpoints
+	endpoints.OpenAI = gateway.OpenAIFromEnv().URL("/models")
+	if openRouter := gateway.OpenRouterFromEnv(); openRouter.BaseURL != gateway.DefaultOpenRouterBaseURL {
+		endpoints.OpenRouter = openRouter.URL("/models")
+	}
+	return endpoints
+}
+
+type check struct {
+	name string
+	run  func(ctx context.Context) Result
+}
+
+// Run executes every check concurrently and returns the results in declaration order
+func Run(ctx context.Context, app core.App, opts Options) Report {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{}
+	}
+	if opts.Endpoints == (Endpoints{}) {
+		opts.Endpoints = EndpointsFromEnv()
+	}
+	c := checker{opts: opts}
+
+	checks := []check{
+		{"stripe_secret_key", c.stripeKey},
+		{"stripe_webhook_secret", c.stripeWebhookSecret},
+		{"openai_api_key", c.openAIKey},
+		{"transcription_providers", c.transcriptionProviders},
+		{"audio_preprocessing", c.audioPreprocessing},
+		{"openrouter_api_key", c.openRouterKey},
+		{"moderation", c.moderation},
+		{"email", c.email},
+		{"schema", func(context.Context) Result { return c.schema(app) }},
+		{"data_dir", func(context.Context) Result { return c.dataDirs(app) }},
+	}
+
+	report := Report{Results: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, chk := range checks {
+		wg.Add(1)
+		go func(i int, chk check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			result := chk.run(checkCtx)
+			result.Name = chk.name
+			result.Duration = time.Since(start)
+			report.Results[i] = result
+		}(i, chk)
+	}
+	wg.Wait()
+	return report
+}
+
+// StartupMode reads STARTUP_DOCTOR, defaulting to warn
+func StartupMode() string {
+	switch mode := strings.ToLower(os.Getenv("STARTUP_DOCTOR")); mode {
+	case ModeOff, ModeFail:
+		return mode
+	default:
+		return ModeWarn
+	}
+}
@@ -0,0 +1,97 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"pocketbase/internal/gateway"
+)
+
+func TestPingResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			w.WriteHeader(http.StatusOK)
+		case "Bearer flaky":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	c := checker{opts: Options{HTTPClient: server.Client()}}
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"good", StatusOK},
+		{"bad", StatusFail},
+		{"flaky", StatusWarn},
+	}
+	for _, tt := range tests {
+		if got := c.pingResult(context.Background(), server.URL, gateway.Auth{APIKey: tt.token}, "TEST_KEY", "Test"); got.Status != tt.want {
+			t.Errorf("token %q: status = %s, want %s (%s)", tt.token, got.Status, tt.want, got.Message)
+		}
+	}
+
+	unreachable := c.pingResult(context.Background(), "http://127.0.0.1:1", gateway.Auth{APIKey: "good"}, "TEST_KEY", "Test")
+	if unreachable.Status != StatusWarn {
+		t.Errorf("unreachable provider: status = %s, want warn", unreachable.Status)
+	}
+}
+
+func TestMissingDependsOnEnvironment(t *testing.T) {
+	if got := (checker{opts: Options{}}).missing("X", "y"); got.Status != StatusFail {
+		t.Errorf("production: status = %s, want fail", got.Status)
+	}
+	if got := (checker{opts: Options{Development: true}}).missing("X", "y"); got.Status != StatusWarn {
+		t.Errorf("development: status = %s, want warn", got.Status)
+	}
+}
+
+func TestStripeChecks(t *testing.T) {
+	c := checker{opts: Options{Development: true}}
+
+	t.Setenv("STRIPE_SECRET_KEY", "pk_test_123")
+	if got := c.stripeKey(context.Background()); got.Status != StatusFail {
+		t.Errorf("publishable key: status = %s, want fail", got.Status)
+	}
+
+	t.Setenv("STRIPE_SECRET_WHSEC", "not-a-secret")
+	if got := c.stripeWebhookSecret(context.Background()); got.Status != StatusFail {
+		t.Errorf("malformed webhook secret: status = %s, want fail", got.Status)
+	}
+	t.Setenv("STRIPE_SECRET_WHSEC", "whsec_0123456789abcdef")
+	if got := c.stripeWebhookSecret(context.Background()); got.Status != StatusOK {
+		t.Errorf("webhook secret: status = %s, want ok", got.Status)
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	report := Report{Results: []Result{
+		{Name: "stripe_secret_key", Status: StatusOK, Message: "Stripe accepted the key"},
+		{Name: "openai_api_key", Status: StatusFail, Message: "OpenAI rejected the key", Fix: "replace OPENAI_API_KEY"},
+	}}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	for _, want := range []string{"[OK  ] stripe_secret_key", "[FAIL] openai_api_key", "fix: replace OPENAI_API_KEY", "1 ok, 0 warnings, 1 failures"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestStartupMode(t *testing.T) {
+	for env, want := range map[string]string{"": ModeWarn, "FAIL": ModeFail, "off": ModeOff, "bogus": ModeWarn} {
+		t.Setenv("STARTUP_DOCTOR", env)
+		if got := StartupMode(); got != want {
+			t.Errorf("STARTUP_DOCTOR=%q: got %s, want %s", env, got, want)
+		}
+	}
+}
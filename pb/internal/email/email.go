@@ -0,0 +1,153 @@
+// Package email sends transactional email through a chain of providers - Resend first and the
+// SMTP server from the PocketBase settings as the fallback by default - skipping providers whose
+// circuit breaker is open. Every send is tracked in sent_emails, whose status follows the
+// delivery, bounce and complaint events Resend posts to its webhook
+package email
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/metrics"
+)
+
+// Provider names, used in EMAIL_PROVIDERS and as circuit breaker names
+const (
+	ProviderResend = "resend"
+	ProviderSMTP   = "smtp"
+)
+
+// sendTimeout bounds one provider attempt, so an outage leaves time for the fallback
+const sendTimeout = 30 * time.Second
+
+// ErrNotConfigured is returned when no provider in the chain is configured
+var ErrNotConfigured = errors.New("no email provider configured (set RESEND_API_KEY or SMTP_HOST)")
+
+// Message is an HTML email to one recipient
+type Message struct {
+	To        string
+	Subject   string
+	HTML      string
+	Kind      string // e.g. "otp_login", kept on the sent_emails record
+	Reference string // ID of the record that triggered the email, if any
+}
+
+// Provider delivers email, returning the provider's message ID when it has one
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, app core.App, msg Message) (string, error)
+}
+
+// Attempt is one provider's failure while sending a message
+type Attempt struct {
+	Provider string `json:"provider"`
+	Error    string `json:"error"`
+}
+
+// Delivery is the outcome of Send
+type Delivery struct {
+	Provider  string    // the provider that accepted the message
+	MessageID string    // its message ID, used to match webhook events
+	Attempts  []Attempt // providers that failed or were skipped first
+	RecordID  string    // the sent_emails record, when it could be saved
+}
+
+// ProviderFromEnv creates a provider by name, or returns nil when it isn't configured
+func ProviderFromEnv(app core.App, name string) Provider {
+	switch name {
+	case ProviderResend:
+		if resend := NewResendFromEnv(); resend != nil {
+			return resend
+		}
+	case ProviderSMTP:
+		if app.Settings().SMTP.Enabled {
+			return SMTP{}
+		}
+	}
+	return nil
+}
+
+// Chain returns the configured providers in the order they're tried: EMAIL_PROVIDERS, a comma
+// separated list that defaults to "resend,smtp". Unknown and unconfigured providers are skipped
+func Chain(app core.App) []Provider {
+	names := os.Getenv("EMAIL_PROVIDERS")
+	if strings.TrimSpace(names) == "" {
+		names = ProviderResend + "," + ProviderSMTP
+	}
+
+	var chain []Provider
+	seen := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if provider := ProviderFromEnv(app, name); provider != nil {
+			chain = append(chain, provider)
+		}
+	}
+	return chain
+}
+
+// ProviderNames lists the names in Chain
+func ProviderNames(app core.App) []string {
+	var names []string
+	for _, provider := range Chain(app) {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+// Send delivers a message through the first provider that accepts it. Providers whose breaker
+// is open are skipped, and a failing provider hands over to the next one. The outcome is
+// recorded in sent_emails either way
+func Send(app core.App, msg Message) (*Delivery, error) {
+	delivery := &Delivery{}
+	err := send(app, msg, Chain(app), delivery)
+	delivery.RecordID = track(app, msg, delivery, err)
+	return delivery, err
+}
+
+func send(app core.App, msg Message, chain []Provider, delivery *Delivery) error {
+	if len(chain) == 0 {
+		return ErrNotConfigured
+	}
+
+	var lastErr error
+	for _, provider := range chain {
+		breaker := circuit.For(provider.Name())
+		if !breaker.Allow() {
+			lastErr = &circuit.OutageError{Provider: provider.Name(), RetryAfter: breaker.RetryAfter()}
+			delivery.Attempts = append(delivery.Attempts, Attempt{Provider: provider.Name(), Error: lastErr.Error()})
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		start := time.Now()
+		messageID, err := provider.Send(ctx, app, msg)
+		cancel()
+		breaker.Record(err)
+		metrics.ObserveUpstream(provider.Name(), start, err)
+		if err == nil {
+			delivery.Provider = provider.Name()
+			delivery.MessageID = messageID
+			if len(delivery.Attempts) > 0 {
+				log.Printf("[EMAIL] Sent %s to %s through fallback %s after %d failed attempts", msg.Kind, msg.To, provider.Name(), len(delivery.Attempts))
+			}
+			return nil
+		}
+
+		log.Printf("[EMAIL] %s failed to send %s to %s: %v", provider.Name(), msg.Kind, msg.To, err)
+		delivery.Attempts = append(delivery.Attempts, Attempt{Provider: provider.Name(), Error: err.Error()})
+		lastErr = err
+	}
+	return lastErr
+}
@@ -0,0 +1,146 @@
+package email
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/circuit"
+)
+
+type fakeProvider struct {
+	name  string
+	id    string
+	err   error
+	calls int
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Send(context.Context, core.App, Message) (string, error) {
+	f.calls++
+	return f.id, f.err
+}
+
+func TestSendFailsOver(t *testing.T) {
+	primary := &fakeProvider{name: "test-failover-primary", err: &circuit.StatusError{Provider: "primary", StatusCode: 503}}
+	fallback := &fakeProvider{name: "test-failover-fallback", id: "msg_1"}
+
+	delivery := &Delivery{}
+	if err := send(nil, Message{To: "a@example.com", Kind: "test"}, []Provider{primary, fallback}, delivery); err != nil {
+		t.Fatalf("send() = %v, want nil", err)
+	}
+	if delivery.Provider != fallback.name || delivery.MessageID != "msg_1" {
+		t.Errorf("delivered by %q (%q), want %q (msg_1)", delivery.Provider, delivery.MessageID, fallback.name)
+	}
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].Provider != primary.name {
+		t.Errorf("attempts = %+v, want the primary's failure", delivery.Attempts)
+	}
+}
+
+func TestSendSkipsOpenBreaker(t *testing.T) {
+	primary := &fakeProvider{name: "test-open-primary"}
+	fallback := &fakeProvider{name: "test-open-fallback", id: "msg_2"}
+
+	breaker := circuit.For(primary.name)
+	for breaker.State() != circuit.StateOpen {
+		breaker.Record(&circuit.StatusError{Provider: "primary", StatusCode: 500})
+	}
+
+	delivery := &Delivery{}
+	if err := send(nil, Message{To: "a@example.com"}, []Provider{primary, fallback}, delivery); err != nil {
+		t.Fatalf("send() = %v, want nil", err)
+	}
+	if primary.calls != 0 {
+		t.Errorf("provider with an open breaker was called %d times", primary.calls)
+	}
+	if delivery.Provider != fallback.name {
+		t.Errorf("delivered by %q, want %q", delivery.Provider, fallback.name)
+	}
+}
+
+func TestSendAllFail(t *testing.T) {
+	last := errors.New("rejected")
+	chain := []Provider{
+		&fakeProvider{name: "test-fail-a", err: errors.New("down")},
+		&fakeProvider{name: "test-fail-b", err: last},
+	}
+
+	delivery := &Delivery{}
+	if err := send(nil, Message{To: "a@example.com"}, chain, delivery); !errors.Is(err, last) {
+		t.Errorf("send() = %v, want the last provider's error", err)
+	}
+	if delivery.Provider != "" || len(delivery.Attempts) != 2 {
+		t.Errorf("delivery = %+v, want no provider and two attempts", delivery)
+	}
+
+	if err := send(nil, Message{}, nil, &Delivery{}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("send() with no providers = %v, want ErrNotConfigured", err)
+	}
+}
+
+func TestVerifyResendSignature(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	secret := "whsec_" + base64.StdEncoding.EncodeToString(key)
+	body := []byte(`{"type":"email.delivered","data":{"email_id":"msg_1"}}`)
+	now := time.Unix(1760000000, 0)
+
+	sign := func(id string, at time.Time, payload []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(id + "." + strconv.FormatInt(at.Unix(), 10) + "."))
+		mac.Write(payload)
+		return "v1," + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	headers := func(at time.Time, signature string) http.Header {
+		header := http.Header{}
+		header.Set("svix-id", "msg_abc")
+		header.Set("svix-timestamp", strconv.FormatInt(at.Unix(), 10))
+		header.Set("svix-signature", signature)
+		return header
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header http.Header
+		valid  bool
+	}{
+		{"valid", secret, headers(now, sign("msg_abc", now, body)), true},
+		{"valid among rotated", secret, headers(now, "v1,b2xk "+sign("msg_abc", now, body)), true},
+		{"tampered body", secret, headers(now, sign("msg_abc", now, []byte("{}"))), false},
+		{"wrong secret", "whsec_" + base64.StdEncoding.EncodeToString([]byte("other")), headers(now, sign("msg_abc", now, body)), false},
+		{"stale", secret, headers(now.Add(-10*time.Minute), sign("msg_abc", now.Add(-10*time.Minute), body)), false},
+		{"missing headers", secret, http.Header{}, false},
+		{"no secret", "", headers(now, sign("msg_abc", now, body)), false},
+	}
+	for _, tt := range tests {
+		err := verifyResendSignature(tt.secret, tt.header, body, now)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: verifyResendSignature() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestStatusRankNeverMovesBackwards(t *testing.T) {
+	order := []string{StatusFailed, StatusSent, StatusDelayed, StatusDelivered, StatusBounced, StatusComplained}
+	for i := 1; i < len(order); i++ {
+		if statusRank[order[i]] <= statusRank[order[i-1]] {
+			t.Errorf("%s should rank above %s", order[i], order[i-1])
+		}
+	}
+	for _, status := range resendEventStatuses {
+		if _, ok := statusRank[status]; !ok {
+			t.Errorf("event status %q has no rank", status)
+		}
+	}
+}
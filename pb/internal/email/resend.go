@@ -0,0 +1,82 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/circuit"
+)
+
+// DefaultResendEndpoint is Resend's send endpoint
+const DefaultResendEndpoint = "https://api.resend.com/emails"
+
+// Resend delivers email through the Resend HTTP API
+type Resend struct {
+	APIKey     string
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// NewResendFromEnv creates a Resend provider from RESEND_API_KEY, or returns nil when it isn't set.
+// RESEND_BASE_URL overrides the API base URL
+func NewResendFromEnv() *Resend {
+	key := os.Getenv("RESEND_API_KEY")
+	if key == "" {
+		return nil
+	}
+	endpoint := DefaultResendEndpoint
+	if base := strings.TrimRight(os.Getenv("RESEND_BASE_URL"), "/"); base != "" {
+		endpoint = base + "/emails"
+	}
+	return &Resend{APIKey: key, Endpoint: endpoint, HTTPClient: &http.Client{Timeout: sendTimeout}}
+}
+
+// Name implements Provider
+func (r *Resend) Name() string {
+	return ProviderResend
+}
+
+// Send implements Provider, returning Resend's email ID
+func (r *Resend) Send(ctx context.Context, app core.App, msg Message) (string, error) {
+	payload, err := json.Marshal(map[string]any{
+		"from":    fmt.Sprintf("%s <%s>", app.Settings().Meta.SenderName, app.Settings().Meta.SenderAddress),
+		"to":      []string{msg.To},
+		"subject": msg.Subject,
+		"html":    msg.HTML,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal email payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+r.APIKey)
+
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send email via Resend: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return "", &circuit.StatusError{Provider: "Resend", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var sent struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(body, &sent)
+	return sent.ID, nil
+}
@@ -0,0 +1,20 @@
+package email
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the Resend delivery event webhook, verified by its signature in the handler
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/webhooks/resend", Unversioned: true, Handler: func(e *core.RequestEvent) error {
+			return ResendWebhookHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,32 @@
+package email
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collection email delivery tracking reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "email",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "sent_emails",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "recipient", Type: "text"},
+					{Name: "subject", Type: "text"},
+					{Name: "kind", Type: "text"},
+					{Name: "reference", Type: "text"},
+					{Name: "provider", Type: "text"},
+					{Name: "provider_message_id", Type: "text"},
+					{Name: "status", Type: "select", Values: []string{StatusSent, StatusFailed, StatusDelayed, StatusDelivered, StatusBounced, StatusComplained}},
+					{Name: "attempts", Type: "json"},
+					{Name: "events", Type: "json"},
+					{Name: "error", Type: "text"},
+					{Name: "last_event_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_sent_emails_provider_message", Columns: "provider, provider_message_id"},
+				},
+			},
+		},
+	}
+}
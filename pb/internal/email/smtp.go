@@ -0,0 +1,47 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/mailer"
+)
+
+// SMTP delivers email through the SMTP server configured in the PocketBase settings (SMTP_HOST)
+type SMTP struct{}
+
+// Name implements Provider
+func (SMTP) Name() string {
+	return ProviderSMTP
+}
+
+// Send implements Provider. SMTP has no message ID to return
+func (SMTP) Send(ctx context.Context, app core.App, msg Message) (string, error) {
+	message := &mailer.Message{
+		From: mail.Address{
+			Address: app.Settings().Meta.SenderAddress,
+			Name:    app.Settings().Meta.SenderName,
+		},
+		To:      []mail.Address{{Address: msg.To}},
+		Subject: msg.Subject,
+		HTML:    msg.HTML,
+	}
+
+	// The mail client takes no context, so a hung server is abandoned at the deadline
+	done := make(chan error, 1)
+	go func() {
+		done <- app.NewMailClient().Send(message)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", fmt.Errorf("failed to send email via SMTP: %w", err)
+		}
+		return "", nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("SMTP email sending timed out: %w", ctx.Err())
+	}
+}
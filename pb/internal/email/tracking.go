@@ -0,0 +1,91 @@
+package email
+
+import (
+	"log"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Statuses of a sent_emails record. Sent and failed come from Send, the rest from provider
+// webhook events
+const (
+	StatusSent       = "sent"
+	StatusFailed     = "failed"
+	StatusDelayed    = "delayed"
+	StatusDelivered  = "delivered"
+	StatusBounced    = "bounced"
+	StatusComplained = "complained"
+)
+
+// statusRank orders statuses so events arriving out of order never move an email backwards,
+// e.g. a late delivery_delayed after delivered
+var statusRank = map[string]int{
+	StatusFailed:     0,
+	StatusSent:       1,
+	StatusDelayed:    2,
+	StatusDelivered:  3,
+	StatusBounced:    4,
+	StatusComplained: 5,
+}
+
+// maxEvents caps the provider events kept on one record
+const maxEvents = 20
+
+// track records a send in sent_emails and returns the record ID. Failures are logged but never
+// fail the send itself
+func track(app core.App, msg Message, delivery *Delivery, sendErr error) string {
+	collection, err := app.FindCollectionByNameOrId("sent_emails")
+	if err != nil {
+		log.Printf("[EMAIL] Failed to find sent_emails collection: %v", err)
+		return ""
+	}
+
+	record := core.NewRecord(collection)
+	if user, err := app.FindAuthRecordByEmail("users", msg.To); err == nil {
+		record.Set("user_id", user.Id)
+	}
+	record.Set("recipient", msg.To)
+	record.Set("subject", msg.Subject)
+	record.Set("kind", msg.Kind)
+	record.Set("reference", msg.Reference)
+	record.Set("provider", delivery.Provider)
+	record.Set("provider_message_id", delivery.MessageID)
+	record.Set("attempts", delivery.Attempts)
+	record.Set("status", StatusSent)
+	if sendErr != nil {
+		record.Set("status", StatusFailed)
+		record.Set("error", sendErr.Error())
+	}
+
+	if err := app.Save(record); err != nil {
+		log.Printf("[EMAIL] Failed to track %s email to %s: %v", msg.Kind, msg.To, err)
+		return ""
+	}
+	return record.Id
+}
+
+// applyEvent moves a tracked email to status (unless it's already further along) and keeps
+// the event for support
+func applyEvent(app core.App, record *core.Record, eventType, status string, at time.Time, detail string) error {
+	if statusRank[status] > statusRank[record.GetString("status")] {
+		record.Set("status", status)
+		if detail != "" {
+			record.Set("error", detail)
+		}
+	}
+
+	var events []map[string]any
+	record.UnmarshalJSONField("events", &events)
+	event := map[string]any{"type": eventType, "at": at.UTC().Format(time.RFC3339)}
+	if detail != "" {
+		event["detail"] = detail
+	}
+	events = append(events, event)
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	record.Set("events", events)
+	record.Set("last_event_at", at)
+	return app.Save(record)
+}
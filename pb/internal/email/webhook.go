@@ -0,0 +1,136 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// webhookTolerance is how far a delivery's signed timestamp may drift from now
+const webhookTolerance = 5 * time.Minute
+
+// resendEventStatuses maps the Resend events that change a tracked email's status. Opens and
+// clicks are ignored
+var resendEventStatuses = map[string]string{
+	"email.delivery_delayed": StatusDelayed,
+	"email.delivered":        StatusDelivered,
+	"email.bounced":          StatusBounced,
+	"email.complained":       StatusComplained,
+}
+
+// resendEvent is the part of a Resend webhook event this package reads
+type resendEvent struct {
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Data      struct {
+		EmailID string `json:"email_id"`
+		Bounce  *struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"bounce,omitempty"`
+	} `json:"data"`
+}
+
+// verifyResendSignature checks a Resend (Svix) webhook signature: the base64 HMAC-SHA256 of
+// "<svix-id>.<svix-timestamp>.<body>" keyed with the decoded whsec_ secret, one of the
+// space separated "v1,<signature>" entries of svix-signature
+func verifyResendSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	if secret == "" {
+		return errors.New("RESEND_WEBHOOK_SECRET is not set")
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return fmt.Errorf("RESEND_WEBHOOK_SECRET is not a valid signing secret: %w", err)
+	}
+
+	id, timestamp, signatures := header.Get("svix-id"), header.Get("svix-timestamp"), header.Get("svix-signature")
+	if id == "" || timestamp == "" || signatures == "" {
+		return errors.New("missing signature headers")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid signature timestamp")
+	}
+	if math.Abs(now.Sub(time.Unix(unix, 0)).Seconds()) > webhookTolerance.Seconds() {
+		return errors.New("signature timestamp outside the tolerance window")
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, entry := range strings.Fields(signatures) {
+		version, signature, ok := strings.Cut(entry, ",")
+		if !ok || version != "v1" {
+			continue
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			return nil
+		}
+	}
+	return errors.New("no signature matches the secret")
+}
+
+// ResendWebhookHandler ingests Resend delivery events, verified with RESEND_WEBHOOK_SECRET,
+// and moves the matching sent_emails record to delivered, delayed, bounced or complained
+// POST /api/webhooks/resend
+func ResendWebhookHandler(e *core.RequestEvent, app core.App) error {
+	logger := logging.FromRequest(e).With("endpoint", "resend_webhook")
+
+	body, err := io.ReadAll(io.LimitReader(e.Request.Body, 1<<20))
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read body"})
+	}
+	if err := verifyResendSignature(os.Getenv("RESEND_WEBHOOK_SECRET"), e.Request.Header, body, time.Now()); err != nil {
+		logger.Warn("Resend webhook rejected", "error", err)
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Invalid signature"})
+	}
+
+	var event resendEvent
+	if err := json.Unmarshal(body, &event); err != nil || event.Type == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid event"})
+	}
+	status, tracked := resendEventStatuses[event.Type]
+	if !tracked || event.Data.EmailID == "" {
+		return e.JSON(http.StatusOK, map[string]string{"status": "ignored"})
+	}
+
+	record, err := app.FindFirstRecordByFilter("sent_emails", "provider = {:provider} && provider_message_id = {:id}",
+		map[string]any{"provider": ProviderResend, "id": event.Data.EmailID})
+	if err != nil {
+		// Sent before tracking existed, or by something else on the same Resend account
+		return e.JSON(http.StatusOK, map[string]string{"status": "unknown_email"})
+	}
+
+	at, err := time.Parse(time.RFC3339, event.CreatedAt)
+	if err != nil {
+		at = time.Now()
+	}
+	var detail string
+	if event.Data.Bounce != nil {
+		detail = strings.TrimSpace(event.Data.Bounce.Type + ": " + event.Data.Bounce.Message)
+	}
+	if err := applyEvent(app, record, event.Type, status, at, detail); err != nil {
+		logger.Error("Failed to record email event", "sent_email_id", record.Id, "type", event.Type, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record event"})
+	}
+
+	if status == StatusBounced || status == StatusComplained {
+		logger.Warn("Email not delivered", "sent_email_id", record.Id, "kind", record.GetString("kind"), "status", status, "detail", detail)
+	}
+	return e.JSON(http.StatusOK, map[string]string{"status": record.GetString("status")})
+}
@@ -0,0 +1,136 @@
+// Package gateway resolves where OpenAI-compatible requests are sent: the OpenAI and OpenRouter
+// base URLs, how each gateway expects its key, and which extra gateway serves a requested model.
+// Pointing the base URLs at Azure OpenAI or a self-hosted gateway needs no code changes
+package gateway
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Built-in gateways
+const (
+	OpenAI     = "openai"
+	OpenRouter = "openrouter"
+)
+
+// Production base URLs, used unless OPENAI_BASE_URL or OPENROUTER_BASE_URL override them
+const (
+	DefaultOpenAIBaseURL     = "https://api.openai.com/v1"
+	DefaultOpenRouterBaseURL = "https://openrouter.ai/api/v1"
+)
+
+// Auth is how a gateway expects its API key
+type Auth struct {
+	APIKey string
+	// Header carries the key. Empty or "Authorization" sends "Bearer <key>"; any other header,
+	// such as Azure OpenAI's "api-key", sends the bare key. Without a key nothing is sent
+	Header string
+}
+
+// Apply sets the key on outgoing request headers
+func (a Auth) Apply(header http.Header) {
+	if a.APIKey == "" {
+		return
+	}
+	if a.Header == "" || strings.EqualFold(a.Header, "Authorization") {
+		header.Set("Authorization", "Bearer "+a.APIKey)
+		return
+	}
+	header.Set(a.Header, a.APIKey)
+}
+
+// Gateway is an OpenAI-compatible API
+type Gateway struct {
+	Auth
+	Name       string
+	BaseURL    string   // without a trailing slash, e.g. https://api.openai.com/v1
+	APIVersion string   // sent as the api-version query parameter, which Azure OpenAI requires
+	Models     []string // models it serves; entries ending in "/" are prefixes stripped before forwarding
+}
+
+// URL returns the gateway URL of an API path such as /chat/completions
+func (g Gateway) URL(path string) string {
+	u := g.BaseURL + path
+	if g.APIVersion != "" {
+		u += "?api-version=" + url.QueryEscape(g.APIVersion)
+	}
+	return u
+}
+
+// Match reports whether the gateway serves model, and the model name to forward to it
+func (g Gateway) Match(model string) (string, bool) {
+	for _, entry := range g.Models {
+		if strings.HasSuffix(entry, "/") {
+			if rest, ok := strings.CutPrefix(model, entry); ok && rest != "" {
+				return rest, true
+			}
+		} else if model == entry {
+			return model, true
+		}
+	}
+	return "", false
+}
+
+// fromEnv reads a gateway from <prefix>_BASE_URL, _API_KEY, _AUTH_HEADER, _API_VERSION and _MODELS
+func fromEnv(name, prefix, defaultBaseURL string) Gateway {
+	baseURL := strings.TrimRight(os.Getenv(prefix+"_BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	g := Gateway{
+		Auth:       Auth{APIKey: os.Getenv(prefix + "_API_KEY"), Header: os.Getenv(prefix + "_AUTH_HEADER")},
+		Name:       name,
+		BaseURL:    baseURL,
+		APIVersion: os.Getenv(prefix + "_API_VERSION"),
+	}
+	for _, model := range strings.Split(os.Getenv(prefix+"_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			g.Models = append(g.Models, model)
+		}
+	}
+	return g
+}
+
+// OpenAIFromEnv returns the OpenAI gateway, used for transcriptions
+func OpenAIFromEnv() Gateway {
+	return fromEnv(OpenAI, "OPENAI", DefaultOpenAIBaseURL)
+}
+
+// OpenRouterFromEnv returns the OpenRouter gateway, used for text requests no other gateway serves
+func OpenRouterFromEnv() Gateway {
+	return fromEnv(OpenRouter, "OPENROUTER", DefaultOpenRouterBaseURL)
+}
+
+// Configured returns the extra text gateways named in MODEL_GATEWAYS ("azure,local"), each read
+// from GATEWAY_<NAME>_BASE_URL, _API_KEY, _AUTH_HEADER, _API_VERSION and _MODELS. Gateways
+// without a base URL or models are skipped
+func Configured() []Gateway {
+	var gateways []Gateway
+	for _, name := range strings.Split(os.Getenv("MODEL_GATEWAYS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		g := fromEnv(name, "GATEWAY_"+strings.ToUpper(name), "")
+		if g.BaseURL == "" || len(g.Models) == 0 {
+			continue
+		}
+		gateways = append(gateways, g)
+	}
+	return gateways
+}
+
+// Route returns the configured gateway serving model and the model name to forward to it. ok is
+// false when no gateway claims the model, which then goes to OpenRouter. The first gateway in
+// MODEL_GATEWAYS order wins
+func Route(model string) (g Gateway, forwardModel string, ok bool) {
+	for _, g := range Configured() {
+		if forwardModel, ok := g.Match(model); ok {
+			return g, forwardModel, true
+		}
+	}
+	return Gateway{}, "", false
+}
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuthApply(t *testing.T) {
+	tests := []struct {
+		auth       Auth
+		header     string
+		wantHeader string
+	}{
+		{Auth{APIKey: "sk"}, "Authorization", "Bearer sk"},
+		{Auth{APIKey: "sk", Header: "authorization"}, "Authorization", "Bearer sk"},
+		{Auth{APIKey: "az", Header: "api-key"}, "Api-Key", "az"},
+		{Auth{}, "Authorization", ""},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		tt.auth.Apply(header)
+		if got := header.Get(tt.header); got != tt.wantHeader {
+			t.Errorf("Apply(%+v) %s = %q, want %q", tt.auth, tt.header, got, tt.wantHeader)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	if g := OpenAIFromEnv(); g.URL("/audio/transcriptions") != "https://api.openai.com/v1/audio/transcriptions" {
+		t.Errorf("default OpenAI URL = %q", g.URL("/audio/transcriptions"))
+	}
+
+	t.Setenv("OPENROUTER_BASE_URL", "https://example.openai.azure.com/openai/deployments/gpt4o/")
+	t.Setenv("OPENROUTER_API_VERSION", "2024-06-01")
+	t.Setenv("OPENROUTER_AUTH_HEADER", "api-key")
+	g := OpenRouterFromEnv()
+	if got := g.URL("/chat/completions"); got != "https://example.openai.azure.com/openai/deployments/gpt4o/chat/completions?api-version=2024-06-01" {
+		t.Errorf("URL() = %q", got)
+	}
+	if g.Header != "api-key" {
+		t.Errorf("Header = %q", g.Header)
+	}
+}
+
+func TestRoute(t *testing.T) {
+	t.Setenv("MODEL_GATEWAYS", "local, azure, incomplete")
+	t.Setenv("GATEWAY_LOCAL_BASE_URL", "http://localhost:8000/v1")
+	t.Setenv("GATEWAY_LOCAL_MODELS", "local/")
+	t.Setenv("GATEWAY_AZURE_BASE_URL", "https://example.openai.azure.com/openai/deployments/gpt4o")
+	t.Setenv("GATEWAY_AZURE_MODELS", "openai/gpt-4o")
+	t.Setenv("GATEWAY_INCOMPLETE_MODELS", "openai/gpt-4o-mini")
+
+	tests := []struct {
+		model, wantGateway, wantModel string
+		wantOK                        bool
+	}{
+		{"local/llama3", "local", "llama3", true},
+		{"local/", "", "", false},
+		{"openai/gpt-4o", "azure", "openai/gpt-4o", true},
+		{"openai/gpt-4o-mini", "", "", false},
+		{"anthropic/claude-3-haiku", "", "", false},
+	}
+	for _, tt := range tests {
+		g, model, ok := Route(tt.model)
+		if ok != tt.wantOK || g.Name != tt.wantGateway || model != tt.wantModel {
+			t.Errorf("Route(%q) = %q, %q, %v; want %q, %q, %v", tt.model, g.Name, model, ok, tt.wantGateway, tt.wantModel, tt.wantOK)
+		}
+	}
+}
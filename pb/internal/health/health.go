@@ -0,0 +1,247 @@
+// Package health reports whether the server and the services it depends on are usable, for
+// Kamal's deploy checks and uptime monitoring
+package health
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/doctor"
+	"pocketbase/internal/email"
+	"pocketbase/internal/gateway"
+)
+
+// Component and overall states
+const (
+	StatusHealthy   = "healthy"
+	StatusDegraded  = "degraded"  // a feature depending on it is impaired, the server keeps serving
+	StatusUnhealthy = "unhealthy" // the server can't do its job and should be taken out of rotation
+)
+
+// Component is the state of one dependency
+type Component struct {
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	Critical  bool      `json:"critical"` // unhealthy makes the whole server unhealthy
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the overall state and the state of every component
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+	CheckedAt  time.Time            `json:"checked_at"`
+}
+
+// Overall is unhealthy when a critical component is unhealthy and degraded when any component
+// isn't healthy
+func Overall(components map[string]Component) string {
+	status := StatusHealthy
+	for _, component := range components {
+		switch {
+		case component.Status == StatusHealthy:
+		case component.Critical:
+			return StatusUnhealthy
+		default:
+			status = StatusDegraded
+		}
+	}
+	return status
+}
+
+// Options configures a Checker
+type Options struct {
+	Timeout     time.Duration // per probe, 3s by default
+	ExternalTTL time.Duration // how long provider probes are cached, 1 minute by default
+	HTTPClient  *http.Client
+	Endpoints   doctor.Endpoints
+}
+
+type probe struct {
+	name     string
+	critical bool
+	external bool // calls a third party, so it's cached between health checks
+	run      func(ctx context.Context, app core.App) (string, string)
+}
+
+// Checker runs the probes, caching the external ones so frequent health checks don't hammer
+// (or get rate limited by) the providers
+type Checker struct {
+	opts   Options
+	probes []probe
+
+	mu    sync.Mutex
+	cache map[string]Component
+}
+
+// NewChecker creates a checker with the local and provider probes
+func NewChecker(opts Options) *Checker {
+	if opts.Timeout == 0 {
+		opts.Timeout = 3 * time.Second
+	}
+	if opts.ExternalTTL == 0 {
+		opts.ExternalTTL = time.Minute
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{}
+	}
+	if opts.Endpoints == (doctor.Endpoints{}) {
+		opts.Endpoints = doctor.EndpointsFromEnv()
+	}
+
+	c := &Checker{opts: opts, cache: map[string]Component{}}
+	c.probes = []probe{
+		{name: "database", critical: true, run: probeDatabase},
+		{name: "storage", critical: true, run: probeStorage},
+		{name: "email", run: probeEmail},
+		{name: "stripe", external: true, run: c.providerProbe(opts.Endpoints.Stripe, "STRIPE_SECRET_KEY", "", "Stripe")},
+		{name: "openai", external: true, run: c.providerProbe(opts.Endpoints.OpenAI, "OPENAI_API_KEY", gateway.OpenAIFromEnv().Header, "OpenAI")},
+		{name: "openrouter", external: true, run: c.providerProbe(opts.Endpoints.OpenRouter, "OPENROUTER_API_KEY", gateway.OpenRouterFromEnv().Header, "OpenRouter")},
+	}
+	return c
+}
+
+// Check runs every probe concurrently, reusing provider results younger than ExternalTTL
+func (c *Checker) Check(ctx context.Context, app core.App) Report {
+	now := time.Now()
+	report := Report{Components: map[string]Component{}, CheckedAt: now}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, p := range c.probes {
+		if p.external {
+			if cached, ok := c.cached(p.name, now); ok {
+				report.Components[p.name] = cached
+				continue
+			}
+		}
+
+		wg.Add(1)
+		go func(p probe) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			status, message := p.run(probeCtx, app)
+			component := Component{
+				Status:    status,
+				Message:   message,
+				Critical:  p.critical,
+				LatencyMs: time.Since(start).Milliseconds(),
+				CheckedAt: start,
+			}
+			// Configuration problems would otherwise be logged on every health check
+			if status != StatusHealthy && (p.critical || p.external) {
+				log.Printf("[HEALTH] %s is %s: %s", p.name, status, message)
+			}
+			if p.external {
+				c.store(p.name, component)
+			}
+
+			mu.Lock()
+			report.Components[p.name] = component
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	report.Status = Overall(report.Components)
+	return report
+}
+
+func (c *Checker) cached(name string, now time.Time) (Component, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	component, ok := c.cache[name]
+	if !ok || now.Sub(component.CheckedAt) >= c.opts.ExternalTTL {
+		return Component{}, false
+	}
+	return component, true
+}
+
+func (c *Checker) store(name string, component Component) {
+	c.mu.Lock()
+	c.cache[name] = component
+	c.mu.Unlock()
+}
+
+func probeDatabase(ctx context.Context, app core.App) (string, string) {
+	var one int
+	if err := app.DB().NewQuery("SELECT 1").WithContext(ctx).Row(&one); err != nil {
+		return StatusUnhealthy, fmt.Sprintf("query failed: %v", err)
+	}
+	return StatusHealthy, "query succeeded"
+}
+
+// probeStorage checks resumable (TUS) uploads can be written
+func probeStorage(_ context.Context, app core.App) (string, string) {
+	if err := doctor.CheckWritable(filepath.Join(app.DataDir(), "tus_uploads")); err != nil {
+		return StatusUnhealthy, fmt.Sprintf("upload storage isn't writable: %v", err)
+	}
+	return StatusHealthy, "upload storage is writable"
+}
+
+// probeEmail checks the provider chain is configured and at least one provider's breaker is
+// closed - delivery itself is verified by `pocketbase doctor`
+func probeEmail(_ context.Context, app core.App) (string, string) {
+	providers := email.ProviderNames(app)
+	if len(providers) == 0 {
+		return StatusDegraded, "no email provider is configured - verification and OTP emails won't be sent"
+	}
+
+	var available []string
+	for _, name := range providers {
+		if circuit.For(name).State() != circuit.StateOpen {
+			available = append(available, name)
+		}
+	}
+	if len(available) == 0 {
+		return StatusDegraded, fmt.Sprintf("every email provider is failing (%s)", strings.Join(providers, ", "))
+	}
+	if len(available) < len(providers) {
+		return StatusDegraded, fmt.Sprintf("sending through %s, the rest of the chain is failing", strings.Join(available, ", "))
+	}
+	return StatusHealthy, fmt.Sprintf("email provider chain: %s", strings.Join(providers, ", "))
+}
+
+// providerProbe checks a provider is reachable and still accepts the key in variable, sent in
+// authHeader (see gateway.Auth)
+func (c *Checker) providerProbe(url, variable, authHeader, provider string) func(context.Context, core.App) (string, string) {
+	return func(ctx context.Context, _ core.App) (string, string) {
+		key := os.Getenv(variable)
+		if key == "" {
+			return StatusDegraded, variable + " is not set"
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return StatusDegraded, err.Error()
+		}
+		gateway.Auth{APIKey: key, Header: authHeader}.Apply(req.Header)
+		resp, err := c.opts.HTTPClient.Do(req)
+		if err != nil {
+			return StatusDegraded, provider + " is unreachable"
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return StatusDegraded, fmt.Sprintf("%s rejected %s (HTTP %d)", provider, variable, resp.StatusCode)
+		case resp.StatusCode >= 500:
+			return StatusDegraded, fmt.Sprintf("%s answered HTTP %d", provider, resp.StatusCode)
+		}
+		return StatusHealthy, provider + " is reachable"
+	}
+}
@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"pocketbase/internal/doctor"
+)
+
+func TestOverall(t *testing.T) {
+	tests := []struct {
+		name       string
+		components map[string]Component
+		want       string
+	}{
+		{"all healthy", map[string]Component{
+			"database": {Status: StatusHealthy, Critical: true},
+			"stripe":   {Status: StatusHealthy},
+		}, StatusHealthy},
+		{"provider down", map[string]Component{
+			"database": {Status: StatusHealthy, Critical: true},
+			"stripe":   {Status: StatusDegraded},
+		}, StatusDegraded},
+		{"database down", map[string]Component{
+			"database": {Status: StatusUnhealthy, Critical: true},
+			"stripe":   {Status: StatusDegraded},
+		}, StatusUnhealthy},
+	}
+	for _, tt := range tests {
+		if got := Overall(tt.components); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProviderProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer good":
+			w.WriteHeader(http.StatusOK)
+		case "Bearer flaky":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	c := NewChecker(Options{HTTPClient: server.Client()})
+	probe := c.providerProbe(server.URL, "TEST_KEY", "", "Test")
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"", StatusDegraded},
+		{"good", StatusHealthy},
+		{"bad", StatusDegraded},
+		{"flaky", StatusDegraded},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_KEY", tt.key)
+		if got, message := probe(context.Background(), nil); got != tt.want {
+			t.Errorf("key %q: status = %s, want %s (%s)", tt.key, got, tt.want, message)
+		}
+	}
+}
+
+func TestCheckCachesProviders(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	c := NewChecker(Options{
+		HTTPClient:  server.Client(),
+		ExternalTTL: time.Hour,
+		Endpoints:   doctor.Endpoints{Stripe: server.URL, OpenAI: server.URL, OpenRouter: server.URL},
+	})
+	c.probes = []probe{{name: "stripe", external: true, run: c.providerProbe(server.URL, "TEST_KEY", "", "Test")}}
+	t.Setenv("TEST_KEY", "good")
+
+	for range 3 {
+		if report := c.Check(context.Background(), nil); report.Status != StatusHealthy {
+			t.Fatalf("status = %s, want healthy", report.Status)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("provider called %d times, want 1", got)
+	}
+}
@@ -0,0 +1,32 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+var defaultChecker = NewChecker(Options{})
+
+// RegisterRoutes adds the dependency health check
+func RegisterRoutes(r *routes.Registry) {
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/healthcheck", Unversioned: true, Handler: Handler},
+	)
+}
+
+// Handler reports the overall and per-component health. Degraded still answers 200 so a provider
+// outage doesn't get the container restarted; only unhealthy (database or upload storage down)
+// answers 503
+// GET /api/healthcheck
+func Handler(e *core.RequestEvent) error {
+	report := defaultChecker.Check(e.Request.Context(), e.App)
+
+	status := http.StatusOK
+	if report.Status == StatusUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	return e.JSON(status, report)
+}
@@ -14,8 +14,9 @@ func CleanupExpiredOTPs(app core.App) {
 	startTime := time.Now()
 	
 	// Delete all expired OTP entries
-	// expires_at < datetime('now') finds all entries that have passed their expiration time
-	query := app.DB().NewQuery("DELETE FROM user_otps WHERE expires_at < datetime('now')")
+	// expires_at < datetime('now') finds all entries that have passed their expiration time;
+	// entries holding an OTP lockout are kept until the lockout ends
+	query := app.DB().NewQuery("DELETE FROM user_otps WHERE expires_at < datetime('now') AND (COALESCE(locked_until, '') = '' OR locked_until < datetime('now'))")
 	
 	result, err := query.Execute()
 	if err != nil {
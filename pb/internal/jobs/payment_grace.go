@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/notifications"
+	"pocketbase/internal/otp"
+	"pocketbase/internal/subscription"
+)
+
+// graceEndingNotice is how long before the grace window closes the final reminder is sent
+const graceEndingNotice = 48 * time.Hour
+
+// Reminder stages within one past_due episode
+const (
+	graceStageStarted = "started"
+	graceStageEnding  = "ending"
+)
+
+// SendPaymentGraceReminders emails users whose subscription is past_due but still inside its
+// grace window, once when the window opens and once shortly before features are lost
+func SendPaymentGraceReminders(app core.App) {
+	records, err := app.FindRecordsByFilter("current_user_subscriptions", "status = 'past_due'", "", 0, 0)
+	if err != nil {
+		log.Printf("[PAYMENT_GRACE] ERROR: Failed to load past_due subscriptions: %v", err)
+		return
+	}
+
+	now := time.Now()
+	sent := 0
+	for _, record := range records {
+		access := subscription.AccessForSubscription(record, now)
+		if !access.Subscribed || access.Warning == nil {
+			continue // grace window already over
+		}
+
+		stage := graceStageStarted
+		if access.Warning.GraceEndsAt.Sub(now) <= graceEndingNotice {
+			stage = graceStageEnding
+		}
+
+		// One reminder per stage for each past_due episode
+		reference := fmt.Sprintf("%s:%s:%s", record.Id, subscription.PastDueSince(record).UTC().Format("20060102"), stage)
+		if _, err := app.FindFirstRecordByFilter("communications", "kind = 'payment_grace_reminder' && reference = {:reference}",
+			map[string]any{"reference": reference}); err == nil {
+			continue
+		}
+
+		user, err := app.FindRecordById("users", record.GetString("user_id"))
+		if err != nil {
+			log.Printf("[PAYMENT_GRACE] WARNING: Subscription %s has no user: %v", record.Id, err)
+			continue
+		}
+
+		notifications.Create(app, notifications.Notification{
+			UserID:    user.Id,
+			Kind:      notifications.KindPaymentFailed,
+			Title:     "Payment failed",
+			Body:      fmt.Sprintf("Update your billing details by %s to keep your plan.", access.Warning.GraceEndsAt.UTC().Format("January 2")),
+			Link:      "/settings/billing",
+			Reference: reference,
+		})
+		subject, body := getPaymentGraceEmailContent(app.Settings().Meta.AppName, stage, access.Warning.GraceEndsAt)
+		if err := otp.SendTrackedEmail(app, user.GetString("email"), subject, body, "payment_grace_reminder", reference); err != nil {
+			log.Printf("[PAYMENT_GRACE] ERROR: Failed to email user %s: %v", user.Id, err)
+			continue
+		}
+		sent++
+	}
+
+	log.Printf("[PAYMENT_GRACE] Checked %d past_due subscriptions, sent %d reminders", len(records), sent)
+}
+
+// getPaymentGraceEmailContent returns subject and HTML body for payment grace reminders
+func getPaymentGraceEmailContent(appName, stage string, graceEndsAt time.Time) (string, string) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	link := fmt.Sprintf("%s/pricing", frontendURL)
+	deadline := graceEndsAt.UTC().Format("January 2, 2006")
+
+	subject := fmt.Sprintf("Your %s payment failed - please update your billing details", appName)
+	if stage == graceStageEnding {
+		subject = fmt.Sprintf("Your %s plan ends on %s unless billing is fixed", appName, deadline)
+	}
+
+	body := fmt.Sprintf(`
+		<h2>We couldn't process your payment</h2>
+		<p>Your subscription to %s is past due. Your plan's features stay available until <strong>%s</strong>.</p>
+		<p><a href="%s">Update your payment method</a> before then to avoid losing access.</p>
+		<p>If you've already updated your billing details, you can ignore this email.</p>
+		`, html.EscapeString(appName), deadline, link)
+
+	return subject, body
+}
@@ -4,6 +4,14 @@ import (
 	"log"
 
 	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/ai"
+	"pocketbase/internal/progress"
+	"pocketbase/internal/retention"
+	"pocketbase/internal/sla"
+	"pocketbase/internal/warehouse"
+	"pocketbase/internal/webhooks"
 )
 
 // RegisterJobs registers all scheduled jobs with the PocketBase cron scheduler
@@ -22,6 +30,138 @@ func RegisterJobs(app core.App) error {
 	}
 	
 	log.Printf("[JOBS] Successfully registered OTP cleanup job (runs every 10 minutes)")
+
+	// Register SLA rollup job to persist request metrics every 5 minutes
+	err = app.Cron().Add("sla_rollup", "*/5 * * * *", func() {
+		sla.FlushRollups(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register SLA rollup job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered SLA rollup job (runs every 5 minutes)")
+
+	// Register warehouse export of yesterday's snapshot, daily at 02:30 - before retention prunes processed_files
+	err = app.Cron().Add("warehouse_export", "30 2 * * *", func() {
+		warehouse.RunScheduled(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register warehouse export job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered warehouse export job (runs daily at 02:30)")
+
+	// Register processed_files retention job to run daily at 03:30
+	err = app.Cron().Add("processed_files_retention", "30 3 * * *", func() {
+		retention.RunScheduled(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register retention job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered retention job (runs daily at 03:30)")
+
+	// Register purge of deactivated accounts past their retention window, daily at 04:00
+	err = app.Cron().Add("deactivated_accounts_purge", "0 4 * * *", func() {
+		accounts.PurgeExpired(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register account purge job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered account purge job (runs daily at 04:00)")
+
+	// Register payment grace reminders for past_due subscriptions, hourly at :15
+	err = app.Cron().Add("payment_grace_reminders", "15 * * * *", func() {
+		SendPaymentGraceReminders(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register payment grace reminder job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered payment grace reminder job (runs hourly)")
+
+	// Register trial reminders and expiry of unpaid trials, hourly at :30
+	err = app.Cron().Add("trial_checks", "30 * * * *", func() {
+		CheckTrials(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register trial check job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered trial check job (runs hourly)")
+
+	// Register weekly/monthly usage digests, checked daily at 09:00
+	err = app.Cron().Add("usage_digests", "0 9 * * *", func() {
+		SendUsageDigests(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register usage digest job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered usage digest job (runs daily at 09:00)")
+
+	// Register cleanup of abandoned audio upload sessions, hourly at :45
+	err = app.Cron().Add("audio_session_cleanup", "45 * * * *", func() {
+		ai.ExpireAudioSessions(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register audio session cleanup job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered audio session cleanup job (runs hourly)")
+
+	// Register purge of deleted processed files past their restore window, daily at 04:15
+	err = app.Cron().Add("deleted_files_purge", "15 4 * * *", func() {
+		ai.PurgeDeletedFiles(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register deleted files purge job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered deleted files purge job (runs daily at 04:15)")
+
+	// Register retries of webhook deliveries whose next attempt is due, every minute
+	err = app.Cron().Add("webhook_deliveries", "* * * * *", func() {
+		webhooks.ProcessDue(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register webhook delivery job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered webhook delivery job (runs every minute)")
+
+	// Register purge of processing progress records finished over a week ago, daily at 04:30
+	err = app.Cron().Add("processing_jobs_purge", "30 4 * * *", func() {
+		progress.PurgeFinished(app)
+	})
+
+	if err != nil {
+		log.Printf("[JOBS] ERROR: Failed to register processing jobs purge job: %v", err)
+		return err
+	}
+
+	log.Printf("[JOBS] Successfully registered processing jobs purge job (runs daily at 04:30)")
 	log.Printf("[JOBS] All scheduled jobs registered successfully")
 	
 	return nil
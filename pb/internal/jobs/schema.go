@@ -0,0 +1,24 @@
+package jobs
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the fields the scheduled jobs read beyond those owned by other modules
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "jobs",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "users",
+				Fields: []schemacheck.Field{
+					{Name: "usage_digest", Type: "select", Values: []string{DigestWeekly, DigestMonthly}},
+				},
+			},
+			{
+				Collection: "current_user_subscriptions",
+				Fields: []schemacheck.Field{
+					{Name: "past_due_since", Type: "date"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/notifications"
+	"pocketbase/internal/otp"
+	"pocketbase/internal/payment"
+	"pocketbase/internal/subscription"
+)
+
+// trialEndSlack gives Stripe's own trial-end handling (the first charge, or cancelling a trial
+// without a card) time to arrive by webhook before an expired trial is dealt with here
+const trialEndSlack = time.Hour
+
+// What CheckTrials does with a trialing subscription
+const (
+	trialActionNone   = ""
+	trialActionRemind = "remind"
+	trialActionExpire = "expire"
+)
+
+// TrialReminderDays returns how many days before a trial ends the reminder email is sent
+// Set via TRIAL_REMINDER_DAYS (default 3)
+func TrialReminderDays() int {
+	days := 3
+	if v := os.Getenv("TRIAL_REMINDER_DAYS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+	return days
+}
+
+// trialAction decides what a trial ending at trialEnd needs at now
+func trialAction(trialEnd, now time.Time, reminderDays int) string {
+	switch {
+	case now.After(trialEnd.Add(trialEndSlack)):
+		return trialActionExpire
+	case now.Before(trialEnd) && trialEnd.Sub(now) <= time.Duration(reminderDays)*24*time.Hour:
+		return trialActionRemind
+	}
+	return trialActionNone
+}
+
+// CheckTrials emails users whose free trial ends within TrialReminderDays, and moves trials that
+// ended without a payment method to the free plan. Trials that converted but whose webhook never
+// arrived are resynced from Stripe
+func CheckTrials(app core.App) {
+	records, err := app.FindRecordsByFilter("current_user_subscriptions", "status = 'trialing' && trial_end != ''", "", 0, 0)
+	if err != nil {
+		log.Printf("[TRIALS] ERROR: Failed to load trialing subscriptions: %v", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	// Without Stripe the payment method can't be checked, so expired trials are left alone
+	paymentService, err := payment.NewStripeService()
+	if err != nil {
+		log.Printf("[TRIALS] WARNING: Payment service unavailable, expired trials won't be processed: %v", err)
+	}
+	subscriptionService := subscription.NewService(subscription.NewRepository(app))
+	if paymentService != nil {
+		subscriptionService = subscription.NewServiceWithProvider(subscription.NewRepository(app), paymentService.SubscriptionProvider())
+	}
+
+	now := time.Now()
+	reminderDays := TrialReminderDays()
+	reminded, expired := 0, 0
+	for _, record := range records {
+		trialEnd := record.GetDateTime("trial_end").Time()
+		userID := record.GetString("user_id")
+
+		switch trialAction(trialEnd, now, reminderDays) {
+		case trialActionRemind:
+			// One reminder per trial
+			reference := fmt.Sprintf("%s:%s", record.Id, trialEnd.UTC().Format("20060102"))
+			if _, err := app.FindFirstRecordByFilter("communications", "kind = 'trial_ending_reminder' && reference = {:reference}",
+				map[string]any{"reference": reference}); err == nil {
+				continue
+			}
+			user, err := app.FindRecordById("users", userID)
+			if err != nil {
+				log.Printf("[TRIALS] WARNING: Subscription %s has no user: %v", record.Id, err)
+				continue
+			}
+			hasCard, _ := hasPaymentMethod(app, paymentService, userID)
+			notice := "Add a payment method before then to keep your plan."
+			if hasCard {
+				notice = "Your payment method on file will be charged then."
+			}
+			notifications.Create(app, notifications.Notification{
+				UserID:    userID,
+				Kind:      notifications.KindTrialEnding,
+				Title:     "Your trial is ending",
+				Body:      fmt.Sprintf("Your trial ends on %s. %s", trialEnd.UTC().Format("January 2"), notice),
+				Link:      "/pricing",
+				Reference: reference,
+			})
+			if !notifications.EmailEnabled(user, notifications.KindTrialEnding) {
+				continue
+			}
+			subject, body := getTrialEndingEmailContent(app.Settings().Meta.AppName, trialEnd, hasCard)
+			if err := otp.SendTrackedEmail(app, user.GetString("email"), subject, body, "trial_ending_reminder", reference); err != nil {
+				log.Printf("[TRIALS] ERROR: Failed to email user %s: %v", userID, err)
+				continue
+			}
+			reminded++
+
+		case trialActionExpire:
+			if paymentService == nil {
+				continue
+			}
+			hasCard, err := hasPaymentMethod(app, paymentService, userID)
+			if err != nil {
+				log.Printf("[TRIALS] WARNING: Couldn't check payment method for user %s: %v", userID, err)
+				continue
+			}
+			if hasCard {
+				// Stripe charged (or is retrying) the card - pick up the state the webhook should have delivered
+				if _, err := subscriptionService.ResyncFromProvider(userID); err != nil {
+					log.Printf("[TRIALS] ERROR: Failed to resync converted trial for user %s: %v", userID, err)
+				}
+				continue
+			}
+			if err := expireTrial(app, paymentService, subscriptionService, record); err != nil {
+				log.Printf("[TRIALS] ERROR: Failed to end trial for user %s: %v", userID, err)
+				continue
+			}
+			expired++
+		}
+	}
+
+	log.Printf("[TRIALS] Checked %d trials, sent %d reminders, moved %d expired trials to the free plan", len(records), reminded, expired)
+}
+
+// hasPaymentMethod reports whether the user's Stripe customer has a usable payment method
+func hasPaymentMethod(app core.App, paymentService *payment.Service, userID string) (bool, error) {
+	if paymentService == nil {
+		return false, fmt.Errorf("payment service not available")
+	}
+	customer, err := app.FindFirstRecordByFilter("payment_customers", "user_id = {:user}", map[string]any{"user": userID})
+	if err != nil {
+		return false, nil // never reached checkout with a customer, so there's no card either
+	}
+	status, err := paymentService.HasValidPaymentMethod(customer.GetString("provider_customer_id"))
+	if err != nil {
+		return false, err
+	}
+	return status.HasValidPaymentMethod, nil
+}
+
+// expireTrial cancels a trial that ended without a payment method and moves the user to the free plan
+func expireTrial(app core.App, paymentService *payment.Service, subscriptionService subscription.Service, record *core.Record) error {
+	userID := record.GetString("user_id")
+	if providerSubID := record.GetString("provider_subscription_id"); providerSubID != "" {
+		// Stripe may already have cancelled it; the local record is what's stale then
+		if _, err := paymentService.CancelSubscription(providerSubID, false); err != nil {
+			log.Printf("[TRIALS] WARNING: Failed to cancel Stripe subscription %s: %v", providerSubID, err)
+		}
+	}
+
+	if _, err := subscriptionService.SwitchToFreePlan(userID); err != nil {
+		return err
+	}
+
+	if user, err := app.FindRecordById("users", userID); err == nil {
+		subject, body := getTrialEndedEmailContent(app.Settings().Meta.AppName)
+		if err := otp.SendTrackedEmail(app, user.GetString("email"), subject, body, "trial_ended", record.Id); err != nil {
+			log.Printf("[TRIALS] WARNING: Failed to send trial ended email to user %s: %v", userID, err)
+		}
+	}
+
+	log.Printf("[TRIALS] Trial for user %s ended without a payment method - moved to the free plan", userID)
+	return nil
+}
+
+// getTrialEndingEmailContent returns subject and HTML body for the trial ending reminder
+func getTrialEndingEmailContent(appName string, trialEnd time.Time, hasCard bool) (string, string) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	link := fmt.Sprintf("%s/pricing", frontendURL)
+	deadline := trialEnd.UTC().Format("January 2, 2006")
+
+	subject := fmt.Sprintf("Your %s trial ends on %s", appName, deadline)
+	next := fmt.Sprintf(`<p>Add a payment method before then to keep your plan - otherwise you'll be moved to the free plan.</p>
+		<p><a href="%s">Add a payment method</a></p>`, link)
+	if hasCard {
+		next = fmt.Sprintf(`<p>Your payment method on file will be charged when the trial ends. Nothing else to do.</p>
+		<p>Changed your mind? <a href="%s">Manage your plan</a> before then.</p>`, link)
+	}
+
+	body := fmt.Sprintf(`
+		<h2>Your free trial is almost over</h2>
+		<p>Your %s trial ends on <strong>%s</strong>.</p>
+		%s
+		`, html.EscapeString(appName), deadline, next)
+
+	return subject, body
+}
+
+// getTrialEndedEmailContent returns subject and HTML body for a trial that ended without payment
+func getTrialEndedEmailContent(appName string) (string, string) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	link := fmt.Sprintf("%s/pricing", frontendURL)
+
+	subject := fmt.Sprintf("Your %s trial has ended", appName)
+	body := fmt.Sprintf(`
+		<h2>Your free trial has ended</h2>
+		<p>No payment method was added, so your %s account has moved to the free plan. Your transcripts are still here.</p>
+		<p><a href="%s">Upgrade any time</a> to get your plan back.</p>
+		`, html.EscapeString(appName), link)
+
+	return subject, body
+}
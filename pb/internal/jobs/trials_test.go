@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrialAction(t *testing.T) {
+	trialEnd := time.Date(2026, time.October, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{trialEnd.AddDate(0, 0, -5), trialActionNone},
+		{trialEnd.AddDate(0, 0, -3), trialActionRemind},
+		{trialEnd.Add(-time.Minute), trialActionRemind},
+		{trialEnd.Add(30 * time.Minute), trialActionNone}, // Stripe's webhook may still be on its way
+		{trialEnd.Add(2 * time.Hour), trialActionExpire},
+	}
+	for _, tt := range tests {
+		if got := trialAction(trialEnd, tt.now, 3); got != tt.want {
+			t.Errorf("trialAction at %v = %q, want %q", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestTrialReminderDays(t *testing.T) {
+	t.Setenv("TRIAL_REMINDER_DAYS", "7")
+	if got := TrialReminderDays(); got != 7 {
+		t.Errorf("TrialReminderDays() = %d, want 7", got)
+	}
+	t.Setenv("TRIAL_REMINDER_DAYS", "soon")
+	if got := TrialReminderDays(); got != 3 {
+		t.Errorf("invalid values should keep the default, got %d", got)
+	}
+}
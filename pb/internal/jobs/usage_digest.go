@@ -0,0 +1,218 @@
+package jobs
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/otp"
+)
+
+// Digest frequencies users opt into with the usage_digest field (empty means no digest)
+const (
+	DigestWeekly  = "weekly"
+	DigestMonthly = "monthly"
+)
+
+// UsageDigest summarizes a user's usage and billing over one digest period
+type UsageDigest struct {
+	Frequency      string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	HoursUsed      float64
+	FilesProcessed int
+
+	// Calendar month the period falls in, against the plan's monthly limit
+	MonthHoursUsed  float64
+	MonthHoursLimit float64
+
+	PlanName      string
+	RenewalDate   time.Time // zero when the plan doesn't renew
+	RenewalCents  int
+	Currency      string
+	PendingChange string
+	PaymentIssue  bool
+}
+
+// digestDue reports whether a digest of the given frequency goes out today
+// Weekly digests are sent on Mondays, monthly ones on the 1st
+func digestDue(frequency string, now time.Time) bool {
+	switch frequency {
+	case DigestWeekly:
+		return now.Weekday() == time.Monday
+	case DigestMonthly:
+		return now.Day() == 1
+	default:
+		return false
+	}
+}
+
+// digestPeriod returns the period a digest sent at now covers: the previous 7 days
+// for weekly digests and the previous calendar month for monthly ones
+func digestPeriod(frequency string, now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if frequency == DigestMonthly {
+		end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return end.AddDate(0, -1, 0), end
+	}
+	return today.AddDate(0, 0, -7), today
+}
+
+// SendUsageDigests emails the weekly or monthly usage digests due today
+func SendUsageDigests(app core.App) {
+	now := time.Now()
+	for _, frequency := range []string{DigestWeekly, DigestMonthly} {
+		if !digestDue(frequency, now) {
+			continue
+		}
+
+		users, err := app.FindRecordsByFilter("users", "usage_digest = {:frequency} && deactivated_at = ''", "", 0, 0,
+			map[string]any{"frequency": frequency})
+		if err != nil {
+			log.Printf("[DIGEST] ERROR: Failed to load %s digest subscribers: %v", frequency, err)
+			continue
+		}
+
+		start, end := digestPeriod(frequency, now)
+		sent := 0
+		for _, user := range users {
+			// Re-runs on the same day must not send a second copy
+			reference := fmt.Sprintf("%s:%s", frequency, start.Format("2006-01-02"))
+			if _, err := app.FindFirstRecordByFilter("communications", "user_id = {:user} && kind = 'usage_digest' && reference = {:reference}",
+				map[string]any{"user": user.Id, "reference": reference}); err == nil {
+				continue
+			}
+
+			digest := buildUsageDigest(app, user.Id, frequency, start, end)
+			subject, body := getUsageDigestEmailContent(app.Settings().Meta.AppName, digest)
+			if err := otp.SendTrackedEmail(app, user.GetString("email"), subject, body, "usage_digest", reference); err != nil {
+				log.Printf("[DIGEST] ERROR: Failed to email %s digest to user %s: %v", frequency, user.Id, err)
+				continue
+			}
+			sent++
+		}
+
+		log.Printf("[DIGEST] Sent %d/%d %s digests for %s - %s", sent, len(users), frequency,
+			start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+}
+
+// buildUsageDigest collects usage from processed_files and monthly_usage and billing from the subscription
+func buildUsageDigest(app core.App, userID, frequency string, start, end time.Time) UsageDigest {
+	digest := UsageDigest{Frequency: frequency, PeriodStart: start, PeriodEnd: end}
+
+	// Chunks are flattened into one record per file, so only whole files are counted
+	files, err := app.FindRecordsByFilter("processed_files",
+		"user_id = {:user} && status = 'completed' && is_chunk = false && created >= {:start} && created < {:end}", "", 0, 0,
+		map[string]any{"user": userID, "start": start.Format("2006-01-02 15:04:05.000Z"), "end": end.Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		log.Printf("[DIGEST] WARNING: Failed to load processed files for user %s: %v", userID, err)
+	}
+	for _, file := range files {
+		digest.HoursUsed += file.GetFloat("duration_seconds") / 3600.0
+		digest.FilesProcessed++
+	}
+
+	// The month the period ends in - for monthly digests that's the month being summarized
+	month := end.Add(-time.Second).Format("2006-01")
+	if usage, err := app.FindFirstRecordByFilter("monthly_usage", "user_id = {:user} && organization_id = '' && year_month = {:month}",
+		map[string]any{"user": userID, "month": month}); err == nil {
+		digest.MonthHoursUsed = usage.GetFloat("hours_used")
+		// processed_files may have been pruned by retention - monthly_usage is authoritative
+		if frequency == DigestMonthly {
+			digest.HoursUsed = usage.GetFloat("hours_used")
+			digest.FilesProcessed = usage.GetInt("files_processed")
+		}
+	}
+
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": userID})
+	if err != nil {
+		digest.PlanName = "Free"
+		return digest
+	}
+
+	if plan, err := app.FindRecordById("subscription_plans", sub.GetString("plan_id")); err == nil {
+		digest.PlanName = plan.GetString("name")
+		digest.MonthHoursLimit = plan.GetFloat("hours_per_month")
+		if plan.GetInt("price_cents") > 0 {
+			digest.RenewalCents = plan.GetInt("price_cents")
+			digest.Currency = plan.GetString("currency")
+		}
+	}
+
+	periodEnd := sub.GetDateTime("current_period_end").Time()
+	switch {
+	case !sub.GetDateTime("canceled_at").IsZero():
+		digest.PendingChange = fmt.Sprintf("Your %s plan is cancelled and ends on %s - you'll move to the Free plan.",
+			digest.PlanName, periodEnd.UTC().Format("January 2, 2006"))
+	case digest.RenewalCents > 0 && !periodEnd.IsZero():
+		digest.RenewalDate = periodEnd
+	}
+	digest.PaymentIssue = sub.GetString("status") == "past_due"
+
+	return digest
+}
+
+// getUsageDigestEmailContent returns subject and HTML body for usage digest emails
+func getUsageDigestEmailContent(appName string, d UsageDigest) (string, string) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+
+	periodLabel := fmt.Sprintf("%s - %s", d.PeriodStart.Format("Jan 2"), d.PeriodEnd.AddDate(0, 0, -1).Format("Jan 2, 2006"))
+	subject := fmt.Sprintf("Your weekly %s summary (%s)", appName, periodLabel)
+	if d.Frequency == DigestMonthly {
+		periodLabel = d.PeriodStart.Format("January 2006")
+		subject = fmt.Sprintf("Your %s summary for %s", appName, periodLabel)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, `
+		<h2>Your usage for %s</h2>
+		<p><strong>%.2f hours</strong> transcribed across <strong>%d files</strong>.</p>`,
+		html.EscapeString(periodLabel), d.HoursUsed, d.FilesProcessed)
+
+	if d.MonthHoursLimit > 0 {
+		fmt.Fprintf(&body, `
+		<p>%s so far: %.2f of %.1f hours used on the %s plan.</p>`,
+			d.PeriodEnd.Add(-time.Second).Format("January"), d.MonthHoursUsed, d.MonthHoursLimit, html.EscapeString(d.PlanName))
+	}
+
+	if !d.RenewalDate.IsZero() {
+		fmt.Fprintf(&body, `
+		<p>Your plan renews on <strong>%s</strong> for <strong>%s</strong>.</p>`,
+			d.RenewalDate.UTC().Format("January 2, 2006"), formatAmount(d.RenewalCents, d.Currency))
+	}
+
+	if d.PendingChange != "" {
+		fmt.Fprintf(&body, `
+		<p>%s</p>`, html.EscapeString(d.PendingChange))
+	}
+
+	if d.PaymentIssue {
+		fmt.Fprintf(&body, `
+		<p><strong>Your last payment failed.</strong> <a href="%s/pricing">Update your payment method</a> to keep your plan.</p>`,
+			frontendURL)
+	}
+
+	fmt.Fprintf(&body, `
+		<p>You're receiving this because you turned on %s summaries. You can turn them off from your <a href="%s/dashboard">dashboard</a>.</p>
+		`, d.Frequency, frontendURL)
+
+	return subject, body.String()
+}
+
+// formatAmount renders a price in minor units, e.g. 1500 "usd" -> "USD 15.00"
+func formatAmount(cents int, currency string) string {
+	if currency == "" {
+		currency = "usd"
+	}
+	return fmt.Sprintf("%s %.2f", strings.ToUpper(currency), float64(cents)/100)
+}
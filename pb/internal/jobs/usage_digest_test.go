@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestPeriod(t *testing.T) {
+	monday := time.Date(2025, time.June, 9, 9, 0, 0, 0, time.UTC)
+
+	start, end := digestPeriod(DigestWeekly, monday)
+	if !start.Equal(time.Date(2025, time.June, 2, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2025, time.June, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected weekly period %v - %v", start, end)
+	}
+
+	firstOfMonth := time.Date(2025, time.January, 1, 9, 0, 0, 0, time.UTC)
+	start, end = digestPeriod(DigestMonthly, firstOfMonth)
+	if !start.Equal(time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(firstOfMonth.Truncate(24*time.Hour)) {
+		t.Errorf("unexpected monthly period %v - %v", start, end)
+	}
+}
+
+func TestDigestDue(t *testing.T) {
+	monday := time.Date(2025, time.June, 9, 9, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	first := time.Date(2025, time.July, 1, 9, 0, 0, 0, time.UTC)
+
+	if !digestDue(DigestWeekly, monday) || digestDue(DigestWeekly, tuesday) {
+		t.Error("weekly digests should only be due on Mondays")
+	}
+	if !digestDue(DigestMonthly, first) || digestDue(DigestMonthly, monday) {
+		t.Error("monthly digests should only be due on the 1st")
+	}
+	if digestDue("", monday) {
+		t.Error("users without a frequency should never get a digest")
+	}
+}
+
+func TestUsageDigestEmailContent(t *testing.T) {
+	digest := UsageDigest{
+		Frequency:       DigestMonthly,
+		PeriodStart:     time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:       time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC),
+		HoursUsed:       4.5,
+		FilesProcessed:  12,
+		MonthHoursUsed:  4.5,
+		MonthHoursLimit: 10,
+		PlanName:        "Pro",
+		RenewalDate:     time.Date(2025, time.June, 15, 0, 0, 0, 0, time.UTC),
+		RenewalCents:    1500,
+		Currency:        "usd",
+	}
+
+	subject, body := getUsageDigestEmailContent("Ramble", digest)
+
+	if subject != "Your Ramble summary for May 2025" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	for _, want := range []string{"4.50 hours", "12 files", "4.50 of 10.0 hours", "June 15, 2025", "USD 15.00"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected body to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "payment failed") {
+		t.Error("did not expect a payment warning")
+	}
+}
@@ -0,0 +1,205 @@
+package keyguard
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/otp"
+)
+
+// Alert kinds
+const (
+	AlertNewCountry     = "new_country"     // a key was used from a country it hadn't been used from before
+	AlertBlockedIP      = "blocked_ip"      // a request was refused by the key's allowed IP ranges
+	AlertBlockedCountry = "blocked_country" // a request was refused by the key's allowed countries
+)
+
+// seenResolution limits fingerprint writes to one per key and IP in this window
+const seenResolution = 5 * time.Minute
+
+// refusedResolution limits flags for refused requests to one per key and IP in this window
+const refusedResolution = time.Hour
+
+// recent remembers when something last happened, so hot paths skip the database
+type recent struct {
+	mu sync.Mutex
+	at map[string]time.Time
+}
+
+var (
+	seen     = &recent{at: map[string]time.Time{}}
+	refusals = &recent{at: map[string]time.Time{}}
+)
+
+// due reports whether key hasn't been seen within window, and notes it as seen now
+func (c *recent) due(key string, now time.Time, window time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if last, ok := c.at[key]; ok && now.Sub(last) < window {
+		return false
+	}
+	if len(c.at) > 10000 {
+		for k, last := range c.at {
+			if now.Sub(last) >= window {
+				delete(c.at, k)
+			}
+		}
+	}
+	c.at[key] = now
+	return true
+}
+
+// observe records the fingerprint of a request made with a key. A key's first use sets its
+// baseline; afterwards a fingerprint from a country the key hasn't been used from is flagged
+func observe(app core.App, key *core.Record, ip, country, userAgent string) {
+	now := time.Now()
+	if !seen.due(key.Id+"|"+ip, now, seenResolution) {
+		return
+	}
+
+	fingerprint, err := app.FindFirstRecordByFilter("api_key_fingerprints", "key_id = {:key} && ip = {:ip}",
+		dbx.Params{"key": key.Id, "ip": ip})
+	if err == nil {
+		// Written directly so frequent requests don't run record hooks or bump "updated"
+		_, err = app.DB().Update("api_key_fingerprints", dbx.Params{
+			"last_seen":  now.UTC().Format("2006-01-02 15:04:05.000Z"),
+			"country":    country,
+			"user_agent": truncate(userAgent, 500),
+		}, dbx.HashExp{"id": fingerprint.Id}).Execute()
+		if err != nil {
+			log.Printf("[KEYGUARD] Failed to update fingerprint of key %s: %v", key.Id, err)
+		}
+		return
+	}
+
+	known := knownCountries(app, key.Id)
+	collection, err := app.FindCollectionByNameOrId("api_key_fingerprints")
+	if err != nil {
+		return
+	}
+	fingerprint = core.NewRecord(collection)
+	fingerprint.Set("key_id", key.Id)
+	fingerprint.Set("user_id", key.GetString("user_id"))
+	fingerprint.Set("ip", ip)
+	fingerprint.Set("country", country)
+	fingerprint.Set("user_agent", truncate(userAgent, 500))
+	fingerprint.Set("first_seen", now)
+	fingerprint.Set("last_seen", now)
+	if err := app.Save(fingerprint); err != nil {
+		// A concurrent request from the same address got there first
+		return
+	}
+
+	if isNewCountry(known, country) {
+		flag(app, key, AlertNewCountry, ip, country, userAgent, known)
+	}
+}
+
+// isNewCountry reports whether a key used from the known countries is now used from another.
+// A key without known countries is on its first (located) use, which sets the baseline
+func isNewCountry(known []string, country string) bool {
+	return country != "" && len(known) > 0 && !slices.Contains(known, country)
+}
+
+// knownCountries lists the countries a key has been used from
+func knownCountries(app core.App, keyID string) []string {
+	var countries []string
+	err := app.DB().NewQuery("SELECT DISTINCT country FROM api_key_fingerprints WHERE key_id = {:key} AND country != '' ORDER BY country").
+		Bind(dbx.Params{"key": keyID}).
+		Column(&countries)
+	if err != nil {
+		log.Printf("[KEYGUARD] Failed to load known countries of key %s: %v", keyID, err)
+	}
+	return countries
+}
+
+// refused flags a request the key's restrictions turned away
+func refused(app core.App, key *core.Record, ip, country, userAgent string, reason error) {
+	log.Printf("[KEYGUARD] Refused request with key %s from %s (%s): %v", key.Id, ip, country, reason)
+	if !refusals.due(key.Id+"|"+ip, time.Now(), refusedResolution) {
+		return
+	}
+	kind := AlertBlockedIP
+	if reason == ErrCountryNotAllowed {
+		kind = AlertBlockedCountry
+	}
+	flag(app, key, kind, ip, country, userAgent, knownCountries(app, key.Id))
+}
+
+// flag records an alert on the key, and emails its owner about use from a new country
+func flag(app core.App, key *core.Record, kind, ip, country, userAgent string, known []string) {
+	collection, err := app.FindCollectionByNameOrId("api_key_alerts")
+	if err != nil {
+		return
+	}
+	alert := core.NewRecord(collection)
+	alert.Set("key_id", key.Id)
+	alert.Set("user_id", key.GetString("user_id"))
+	alert.Set("kind", kind)
+	alert.Set("ip", ip)
+	alert.Set("country", country)
+	alert.Set("known_countries", known)
+	alert.Set("user_agent", truncate(userAgent, 500))
+	if err := app.Save(alert); err != nil {
+		log.Printf("[KEYGUARD] Failed to flag key %s (%s): %v", key.Id, kind, err)
+		return
+	}
+	log.Printf("[KEYGUARD] Flagged key %s: %s from %s (%s), known countries %v", key.Id, kind, ip, country, known)
+
+	if kind == AlertNewCountry && os.Getenv("API_KEY_ALERT_EMAILS") != "false" {
+		go notify(app, key, alert)
+	}
+}
+
+// notify emails the key's owner about an alert and marks it notified
+func notify(app core.App, key *core.Record, alert *core.Record) {
+	user, err := app.FindRecordById("users", key.GetString("user_id"))
+	if err != nil || user.Email() == "" {
+		return
+	}
+	subject, body := newCountryEmailContent(app.Settings().Meta.AppName, key, alert)
+	if err := otp.SendTrackedEmail(app, user.Email(), subject, body, "api_key_new_country", alert.Id); err != nil {
+		log.Printf("[KEYGUARD] Failed to email alert %s to user %s: %v", alert.Id, user.Id, err)
+		return
+	}
+	alert.Set("notified", true)
+	if err := app.Save(alert); err != nil {
+		log.Printf("[KEYGUARD] Failed to mark alert %s notified: %v", alert.Id, err)
+	}
+}
+
+func newCountryEmailContent(appName string, key *core.Record, alert *core.Record) (string, string) {
+	name := key.GetString("name")
+	if name == "" {
+		name = key.GetString("key_prefix") + "..."
+	}
+	known := "none"
+	var countries []string
+	if err := alert.UnmarshalJSONField("known_countries", &countries); err == nil && len(countries) > 0 {
+		known = strings.Join(countries, ", ")
+	}
+
+	subject := fmt.Sprintf("Your %s API key was used from a new country", appName)
+	body := fmt.Sprintf(`<p>Your API key <strong>%s</strong> was just used from <strong>%s</strong> (IP address %s).</p>
+<p>It had only been used from: %s.</p>
+<p>If this was you, there's nothing to do. If it wasn't, revoke or rotate the key from your account settings right away,
+and consider limiting it to your countries or IP addresses.</p>`,
+		html.EscapeString(name), html.EscapeString(alert.GetString("country")), html.EscapeString(alert.GetString("ip")), html.EscapeString(known))
+	return subject, body
+}
+
+func truncate(value string, limit int) string {
+	if len(value) > limit {
+		return value[:limit]
+	}
+	return value
+}
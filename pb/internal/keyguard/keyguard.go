@@ -0,0 +1,140 @@
+// Package keyguard restricts where API keys can be used from and watches how they're used.
+// Keys can be limited to IP ranges and countries, every key's usage fingerprints (IP,
+// country, user agent) are recorded, and a key suddenly used from a new country is flagged
+// and its owner emailed - stolen desktop keys are the main abuse vector
+package keyguard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// maxRestrictions bounds the CIDRs and countries a key can list
+const maxRestrictions = 50
+
+// Errors returned when a key's restrictions refuse a request
+var (
+	ErrIPNotAllowed      = errors.New("API key is not allowed from this IP address")
+	ErrCountryNotAllowed = errors.New("API key is not allowed from this country")
+)
+
+// Restrictions limit where a key can be used from. Empty lists don't restrict anything
+type Restrictions struct {
+	AllowedCIDRs     []string `json:"allowed_cidrs"`
+	AllowedCountries []string `json:"allowed_countries"` // ISO 3166-1 alpha-2 codes
+}
+
+// Normalize validates the restrictions, turning bare IPs into single-address ranges and
+// country codes to upper case
+func (r *Restrictions) Normalize() error {
+	if len(r.AllowedCIDRs) > maxRestrictions || len(r.AllowedCountries) > maxRestrictions {
+		return fmt.Errorf("at most %d IP ranges and %d countries can be listed", maxRestrictions, maxRestrictions)
+	}
+
+	cidrs := []string{}
+	for _, value := range r.AllowedCIDRs {
+		value = strings.TrimSpace(value)
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return fmt.Errorf("%q is not an IP address or CIDR range", value)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if cidr := prefix.Masked().String(); !slices.Contains(cidrs, cidr) {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+
+	countries := []string{}
+	for _, value := range r.AllowedCountries {
+		code := strings.ToUpper(strings.TrimSpace(value))
+		if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+			return fmt.Errorf("%q is not a two-letter country code", value)
+		}
+		if !slices.Contains(countries, code) {
+			countries = append(countries, code)
+		}
+	}
+
+	r.AllowedCIDRs, r.AllowedCountries = cidrs, countries
+	return nil
+}
+
+// KeyRestrictions reads the restrictions stored on an api_keys record
+func KeyRestrictions(key *core.Record) Restrictions {
+	restrictions := Restrictions{AllowedCIDRs: []string{}, AllowedCountries: []string{}}
+	_ = key.UnmarshalJSONField("allowed_cidrs", &restrictions.AllowedCIDRs)
+	_ = key.UnmarshalJSONField("allowed_countries", &restrictions.AllowedCountries)
+	return restrictions
+}
+
+// Allow reports whether a request from ip and country passes the restrictions. An address
+// or country that can't be determined doesn't pass a list that restricts it
+func (r Restrictions) Allow(ip, country string) error {
+	if len(r.AllowedCIDRs) > 0 {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return ErrIPNotAllowed
+		}
+		addr = addr.Unmap()
+		allowed := false
+		for _, cidr := range r.AllowedCIDRs {
+			if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrIPNotAllowed
+		}
+	}
+	if len(r.AllowedCountries) > 0 && !slices.Contains(r.AllowedCountries, country) {
+		return ErrCountryNotAllowed
+	}
+	return nil
+}
+
+// ClientIP is the caller's address as PocketBase resolves it (RequestEvent.RealIP): proxy
+// headers are only honoured when they're configured as trusted in the settings, otherwise the
+// connection's remote address is used, so callers can't spoof their way past an allowlist
+func ClientIP(app core.App, r *http.Request) string {
+	e := &core.RequestEvent{App: app}
+	e.Request = r
+	return e.RealIP()
+}
+
+// Country is the caller's country as resolved by the CDN in front of the app, read from the
+// GEOIP_COUNTRY_HEADER header (CF-IPCountry by default). Empty when it's unknown. The header
+// can be forged unless every request goes through the CDN
+func Country(r *http.Request) string {
+	header := os.Getenv("GEOIP_COUNTRY_HEADER")
+	if header == "" {
+		header = "CF-IPCountry"
+	}
+	country := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+	if country == "XX" || len(country) != 2 {
+		return "" // Cloudflare's "unknown"
+	}
+	return country
+}
+
+// Check applies a key's restrictions to a request and records the request's fingerprint
+// Refused requests are flagged on the key before the error is returned
+func Check(app core.App, key *core.Record, r *http.Request) error {
+	ip, country := ClientIP(app, r), Country(r)
+	if err := KeyRestrictions(key).Allow(ip, country); err != nil {
+		refused(app, key, ip, country, r.UserAgent(), err)
+		return err
+	}
+	observe(app, key, ip, country, r.UserAgent())
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build integration
+
+package keyguard_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"pocketbase/internal/keyguard"
+	"pocketbase/internal/testharness"
+)
+
+func TestSpoofedHeadersDontPassAllowlist(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "allowlist@test.com")
+	apiKey := testharness.CreateAPIKey(t, app, user)
+
+	key, err := app.FindFirstRecordByData("api_keys", "key_prefix", apiKey[:8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set("allowed_cidrs", []string{"198.51.100.0/24"})
+	if err := app.Save(key); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "192.0.2.1:4321"
+	for _, header := range []string{"CF-Connecting-IP", "X-Real-IP", "X-Forwarded-For"} {
+		r.Header.Set(header, "198.51.100.20")
+	}
+	if got := keyguard.ClientIP(app, r); got != "192.0.2.1" {
+		t.Errorf("ClientIP() with untrusted headers = %q, want the remote address", got)
+	}
+	if err := keyguard.Check(app, key, r); err != keyguard.ErrIPNotAllowed {
+		t.Errorf("Check() with a spoofed header = %v, want ErrIPNotAllowed", err)
+	}
+
+	// Behind a proxy configured as trusted, its header is the caller's address
+	app.Settings().TrustedProxy.Headers = []string{"X-Forwarded-For"}
+	if got := keyguard.ClientIP(app, r); got != "198.51.100.20" {
+		t.Errorf("ClientIP() with a trusted header = %q", got)
+	}
+	if err := keyguard.Check(app, key, r); err != nil {
+		t.Errorf("Check() behind a trusted proxy = %v", err)
+	}
+}
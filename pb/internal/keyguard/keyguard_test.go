@@ -0,0 +1,112 @@
+package keyguard
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRestrictionsNormalize(t *testing.T) {
+	r := Restrictions{
+		AllowedCIDRs:     []string{" 203.0.113.7 ", "198.51.100.99/24", "2001:db8::1", "198.51.100.0/24"},
+		AllowedCountries: []string{"us", " Ca ", "US"},
+	}
+	if err := r.Normalize(); err != nil {
+		t.Fatalf("Normalize() = %v", err)
+	}
+	if want := []string{"203.0.113.7/32", "198.51.100.0/24", "2001:db8::1/128"}; !reflect.DeepEqual(r.AllowedCIDRs, want) {
+		t.Errorf("cidrs = %v, want %v", r.AllowedCIDRs, want)
+	}
+	if want := []string{"US", "CA"}; !reflect.DeepEqual(r.AllowedCountries, want) {
+		t.Errorf("countries = %v, want %v", r.AllowedCountries, want)
+	}
+
+	for _, invalid := range []Restrictions{
+		{AllowedCIDRs: []string{"not-an-ip"}},
+		{AllowedCIDRs: []string{"10.0.0.0/33"}},
+		{AllowedCountries: []string{"USA"}},
+		{AllowedCountries: []string{"1A"}},
+		{AllowedCountries: make([]string, maxRestrictions+1)},
+	} {
+		if err := invalid.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) should fail", invalid)
+		}
+	}
+}
+
+func TestRestrictionsAllow(t *testing.T) {
+	r := Restrictions{AllowedCIDRs: []string{"198.51.100.0/24", "2001:db8::/32"}, AllowedCountries: []string{"US", "CA"}}
+
+	tests := []struct {
+		ip, country string
+		want        error
+	}{
+		{"198.51.100.20", "US", nil},
+		{"::ffff:198.51.100.20", "CA", nil},
+		{"2001:db8::5", "US", nil},
+		{"203.0.113.1", "US", ErrIPNotAllowed},
+		{"garbage", "US", ErrIPNotAllowed},
+		{"198.51.100.20", "FR", ErrCountryNotAllowed},
+		{"198.51.100.20", "", ErrCountryNotAllowed},
+	}
+	for _, tt := range tests {
+		if got := r.Allow(tt.ip, tt.country); got != tt.want {
+			t.Errorf("Allow(%q, %q) = %v, want %v", tt.ip, tt.country, got, tt.want)
+		}
+	}
+
+	if err := (Restrictions{}).Allow("", ""); err != nil {
+		t.Errorf("no restrictions should allow everything: %v", err)
+	}
+}
+
+func TestCountry(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if got := Country(r); got != "" {
+		t.Errorf("Country() without header = %q", got)
+	}
+	r.Header.Set("CF-IPCountry", "de")
+	if got := Country(r); got != "DE" {
+		t.Errorf("Country() = %q", got)
+	}
+	r.Header.Set("CF-IPCountry", "XX")
+	if got := Country(r); got != "" {
+		t.Errorf("unknown country = %q", got)
+	}
+
+	t.Setenv("GEOIP_COUNTRY_HEADER", "CloudFront-Viewer-Country")
+	r.Header.Set("CloudFront-Viewer-Country", "JP")
+	if got := Country(r); got != "JP" {
+		t.Errorf("Country() with custom header = %q", got)
+	}
+}
+
+func TestIsNewCountry(t *testing.T) {
+	if isNewCountry(nil, "US") {
+		t.Error("a key's first located use sets the baseline")
+	}
+	if isNewCountry([]string{"US"}, "US") {
+		t.Error("a known country isn't new")
+	}
+	if isNewCountry([]string{"US"}, "") {
+		t.Error("an unknown country isn't flagged")
+	}
+	if !isNewCountry([]string{"CA", "US"}, "RU") {
+		t.Error("a country outside the known ones is new")
+	}
+}
+
+func TestRecentDue(t *testing.T) {
+	c := &recent{at: map[string]time.Time{}}
+	now := time.Now()
+	if !c.due("k", now, time.Minute) {
+		t.Error("first sighting should be due")
+	}
+	if c.due("k", now.Add(30*time.Second), time.Minute) {
+		t.Error("sighting within the window shouldn't be due")
+	}
+	if !c.due("k", now.Add(2*time.Minute), time.Minute) {
+		t.Error("sighting after the window should be due")
+	}
+}
@@ -0,0 +1,47 @@
+package keyguard
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields key restrictions and usage auditing read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "keyguard",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "api_keys",
+				Fields: []schemacheck.Field{
+					{Name: "allowed_cidrs", Type: "json"},
+					{Name: "allowed_countries", Type: "json"},
+				},
+			},
+			{
+				Collection: "api_key_fingerprints",
+				Fields: []schemacheck.Field{
+					{Name: "key_id", Type: "relation"},
+					{Name: "user_id", Type: "relation"},
+					{Name: "ip", Type: "text"},
+					{Name: "country", Type: "text"},
+					{Name: "user_agent", Type: "text"},
+					{Name: "first_seen", Type: "date"},
+					{Name: "last_seen", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_api_key_fingerprints_key_ip", Columns: "key_id, ip"},
+				},
+			},
+			{
+				Collection: "api_key_alerts",
+				Fields: []schemacheck.Field{
+					{Name: "key_id", Type: "relation"},
+					{Name: "user_id", Type: "relation"},
+					{Name: "kind", Type: "select", Values: []string{AlertNewCountry, AlertBlockedIP, AlertBlockedCountry}},
+					{Name: "ip", Type: "text"},
+					{Name: "country", Type: "text"},
+					{Name: "known_countries", Type: "json"},
+					{Name: "user_agent", Type: "text"},
+					{Name: "notified", Type: "bool"},
+				},
+			},
+		},
+	}
+}
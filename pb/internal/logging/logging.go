@@ -0,0 +1,67 @@
+// Package logging configures the process-wide structured logger and carries a correlation ID
+// through each request, so production logs can be queried by request, user or job
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Setup makes a JSON (or LOG_FORMAT=text) slog logger the default, at the level in LOG_LEVEL
+// (debug, info, warn or error; default info). Plain log.Printf output goes through it as well
+func Setup() *slog.Logger {
+	logger := New(os.Stderr, os.Getenv("LOG_FORMAT"), ParseLevel(os.Getenv("LOG_LEVEL")))
+	slog.SetDefault(logger)
+	// slog.SetDefault routes the log package through the handler; drop its own timestamp prefix
+	log.SetFlags(0)
+	return logger
+}
+
+// New creates a logger writing to w in the given format ("text", anything else is JSON)
+func New(w io.Writer, format string, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(format, "text") {
+		return slog.New(slog.NewTextHandler(w, options))
+	}
+	return slog.New(slog.NewJSONHandler(w, options))
+}
+
+// ParseLevel reads a LOG_LEVEL value, falling back to info for empty or unknown values
+func ParseLevel(value string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+type loggerKey struct{}
+
+// WithLogger attaches a logger to ctx, so code further down logs with the same attributes
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger attached to ctx, or the default logger tagged with the
+// request ID in ctx if there is one
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx == nil {
+		return slog.Default()
+	}
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
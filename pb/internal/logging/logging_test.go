@@ -0,0 +1,119 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"debug":   slog.LevelDebug,
+		" WARN ":  slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+		"verbose": slog.LevelInfo,
+	}
+	for value, want := range tests {
+		if got := ParseLevel(value); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := map[string]bool{
+		"":                                     false,
+		"abc-123_DEF.4":                        true,
+		"has space":                            false,
+		"line\nbreak":                          false,
+		"quote\"":                              false,
+		strings.Repeat("a", 64):                true,
+		strings.Repeat("a", 65):                false,
+		"5f0c6a8e-2d4b-4e1a-9b7c-1d2e3f4a5b6c": true,
+	}
+	for id, want := range tests {
+		if got := validRequestID(id); got != want {
+			t.Errorf("validRequestID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+// runMiddleware passes a request through Middleware and returns the response and the request ID
+// the next handler saw on its context
+func runMiddleware(t *testing.T, incoming string) (*httptest.ResponseRecorder, string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/test", nil)
+	if incoming != "" {
+		req.Header.Set(RequestIDHeader, incoming)
+	}
+	rec := httptest.NewRecorder()
+	e := &core.RequestEvent{Event: router.Event{Response: rec, Request: req}}
+	if err := Middleware()(e); err != nil {
+		t.Fatalf("middleware returned %v", err)
+	}
+	return rec, RequestID(e.Request.Context())
+}
+
+func TestMiddlewareKeepsValidID(t *testing.T) {
+	rec, id := runMiddleware(t, "client-abc.123")
+	if id != "client-abc.123" {
+		t.Errorf("context request ID = %q, want the incoming one", id)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != id {
+		t.Errorf("response header = %q, want %q", got, id)
+	}
+}
+
+func TestMiddlewareReplacesInvalidID(t *testing.T) {
+	for _, incoming := range []string{"", "bad id", strings.Repeat("x", 100)} {
+		rec, id := runMiddleware(t, incoming)
+		if id == incoming || !validRequestID(id) || len(id) != 32 {
+			t.Errorf("incoming %q: got request ID %q, want a generated one", incoming, id)
+		}
+		if got := rec.Header().Get(RequestIDHeader); got != id {
+			t.Errorf("incoming %q: response header = %q, want %q", incoming, got, id)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(New(&buf, "json", slog.LevelInfo))
+	defer slog.SetDefault(previous)
+
+	FromContext(WithRequestID(context.Background(), "req-1")).Info("hello", "user_id", "u1")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v (%s)", err, buf.String())
+	}
+	if line["msg"] != "hello" || line["request_id"] != "req-1" || line["user_id"] != "u1" {
+		t.Errorf("unexpected log line: %s", buf.String())
+	}
+
+	attached := New(&buf, "json", slog.LevelInfo).With("job_id", "j1")
+	if got := FromContext(WithLogger(context.Background(), attached)); got != attached {
+		t.Error("FromContext should return the attached logger")
+	}
+}
+
+func TestNewRespectsLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "text", slog.LevelWarn)
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if out := buf.String(); strings.Contains(out, "dropped") || !strings.Contains(out, "msg=kept") {
+		t.Errorf("unexpected text output: %q", out)
+	}
+}
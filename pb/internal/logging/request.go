@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RequestIDHeader carries the correlation ID - a valid incoming value is kept so IDs can be
+// followed across a proxy or client, and it's echoed on every response
+const RequestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 64
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying a request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID in ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID generates a random 16 byte hex ID
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID accepts short IDs made of characters that are safe to log and echo back
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware assigns every request an ID, echoes it in X-Request-ID and puts it on the request
+// context, where FromContext and FromRequest pick it up
+func Middleware() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		id := e.Request.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = NewRequestID()
+		}
+		e.Response.Header().Set(RequestIDHeader, id)
+		e.Request = e.Request.WithContext(WithRequestID(e.Request.Context(), id))
+		return e.Next()
+	}
+}
+
+// FromRequest returns a logger tagged with the request ID and, once the request is
+// authenticated as a user, their ID
+func FromRequest(e *core.RequestEvent) *slog.Logger {
+	logger := FromContext(e.Request.Context())
+	if e.Auth != nil && !e.Auth.IsSuperuser() {
+		logger = logger.With("user_id", e.Auth.Id)
+	}
+	return logger
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+
+	"pocketbase/internal/routes"
+)
+
+var (
+	httpRequests = NewCounter("ramble_http_requests_total",
+		"HTTP requests handled, by subsystem, route pattern, method and status code",
+		"subsystem", "route", "method", "status")
+	httpDuration = NewHistogram("ramble_http_request_duration_seconds",
+		"Time spent handling HTTP requests, by subsystem and route pattern",
+		DefaultBuckets, "subsystem", "route")
+)
+
+// subsystemPrefixes map route paths to the subsystem they're reported under - the first match wins
+var subsystemPrefixes = []struct {
+	prefix    string
+	subsystem string
+}{
+	{"/api/ai/process-text", "ai_text"},
+	{"/api/ai/prepare-context", "ai_text"},
+	{"/api/ai/process-audio", "audio"},
+	{"/api/ai/audio-sessions", "audio"},
+	{"/api/ai/upload-sessions", "audio"},
+	{"/api/ai/jobs", "audio"},
+	{"/api/tus", "audio"},
+	{"/api/payment", "payment"},
+	{"/api/billing", "payment"},
+	{"/api/subscription", "payment"},
+	{"/api/webhooks", "payment"},
+	{"/send-otp", "otp"},
+	{"/verify-otp", "otp"},
+}
+
+// Subsystem is the subsystem a route path is reported under, "other" when it isn't one we break out
+func Subsystem(path string) string {
+	for _, entry := range subsystemPrefixes {
+		if strings.HasPrefix(path, entry.prefix) {
+			return entry.subsystem
+		}
+	}
+	return "other"
+}
+
+// Instrument returns a middleware counting a route's requests and timing them. It's labelled
+// with the route pattern rather than the request path so IDs don't explode the series count
+func Instrument(route routes.Route) routes.Middleware {
+	subsystem := Subsystem(route.Path)
+
+	return func(e *core.RequestEvent) error {
+		start := time.Now()
+		err := e.Next()
+
+		httpDuration.Observe(time.Since(start).Seconds(), subsystem, route.Path)
+		httpRequests.Inc(subsystem, route.Path, route.Method, strconv.Itoa(responseStatus(e, err)))
+		return err
+	}
+}
+
+// responseStatus is the status the client gets: what the handler wrote, or what PocketBase will
+// write for a returned error
+func responseStatus(e *core.RequestEvent, err error) int {
+	if status := e.Status(); status != 0 {
+		return status
+	}
+	if err == nil {
+		return http.StatusOK
+	}
+	var apiErr *router.ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.Status
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"pocketbase/internal/circuit"
+)
+
+func render(r *Registry) string {
+	var b strings.Builder
+	r.WriteText(&b)
+	return b.String()
+}
+
+func TestCounterText(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_requests_total", "Requests handled", "route", "status")
+	c.Inc("/api/a", "200")
+	c.Inc("/api/a", "200")
+	c.Add(3, "/api/b", "500")
+	c.Add(-1, "/api/b", "500") // counters never go down
+
+	want := `# HELP test_requests_total Requests handled
+# TYPE test_requests_total counter
+test_requests_total{route="/api/a",status="200"} 2
+test_requests_total{route="/api/b",status="500"} 3
+`
+	if got := render(r); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramText(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("test_duration_seconds", "Latency", []float64{1, 0.1}, "route")
+	h.Observe(0.05, "/x")
+	h.Observe(0.1, "/x") // bucket bounds are inclusive
+	h.Observe(0.5, "/x")
+	h.Observe(2, "/x")
+
+	want := `# HELP test_duration_seconds Latency
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/x",le="0.1"} 2
+test_duration_seconds_bucket{route="/x",le="1"} 3
+test_duration_seconds_bucket{route="/x",le="+Inf"} 4
+test_duration_seconds_sum{route="/x"} 2.65
+test_duration_seconds_count{route="/x"} 4
+`
+	if got := render(r); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeFuncReplacesCollector(t *testing.T) {
+	r := NewRegistry()
+	r.NewGaugeFunc("test_queue", "Queue depth", func() []Sample {
+		return []Sample{{Labels: []string{"queued"}, Value: 1}}
+	}, "state")
+	r.NewGaugeFunc("test_queue", "Queue depth", func() []Sample {
+		return []Sample{{Labels: []string{"queued"}, Value: 4}, {Labels: []string{"active"}, Value: 2}}
+	}, "state")
+
+	want := `# HELP test_queue Queue depth
+# TYPE test_queue gauge
+test_queue{state="active"} 2
+test_queue{state="queued"} 4
+`
+	if got := render(r); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistryOrderAndReuse(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("b_total", "B")
+	first := r.NewCounter("a_total", "A")
+	if again := r.NewCounter("a_total", "A"); again != first {
+		t.Error("registering a counter twice should return the existing one")
+	}
+	first.Inc()
+
+	got := render(r)
+	if strings.Index(got, "a_total") > strings.Index(got, "b_total") {
+		t.Errorf("metrics not sorted by name:\n%s", got)
+	}
+	if !strings.Contains(got, "\na_total 1\n") {
+		t.Errorf("unlabelled counter not rendered:\n%s", got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "Help with \\ and\nnewline", "value").Inc("say \"hi\"\n\\")
+
+	got := render(r)
+	if !strings.Contains(got, `# HELP test_total Help with \\ and\nnewline`) {
+		t.Errorf("help not escaped:\n%s", got)
+	}
+	if !strings.Contains(got, `test_total{value="say \"hi\"\n\\"} 1`) {
+		t.Errorf("label not escaped:\n%s", got)
+	}
+}
+
+func TestSubsystem(t *testing.T) {
+	tests := map[string]string{
+		"/api/ai/process-text":                 "ai_text",
+		"/api/ai/process-audio":                "audio",
+		"/api/ai/audio-sessions/{id}/finalize": "audio",
+		"/api/tus/{path...}":                   "audio",
+		"/api/webhooks/stripe":                 "payment",
+		"/api/payment/checkout":                "payment",
+		"/send-otp":                            "otp",
+		"/api/orgs/{orgId}/usage":              "other",
+		"/api/admin/webhook-events/rejections": "other",
+	}
+	for path, want := range tests {
+		if got := Subsystem(path); got != want {
+			t.Errorf("Subsystem(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestUpstreamOutcome(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, OutcomeOK},
+		{fmt.Errorf("call: %w", &circuit.StatusError{StatusCode: 502}), OutcomeUpstreamError},
+		{&circuit.StatusError{StatusCode: 400}, OutcomeRequestError},
+		{errors.New("bad input"), OutcomeRequestError},
+	}
+	for _, tt := range tests {
+		if got := upstreamOutcome(tt.err); got != tt.want {
+			t.Errorf("upstreamOutcome(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the Prometheus scrape endpoint
+func RegisterRoutes(r *routes.Registry) {
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/metrics", Handler: Handler},
+	)
+}
+
+// Handler serves every metric in the Prometheus text format. Scrapers authenticate with
+// "Authorization: Bearer $METRICS_TOKEN"; without a token configured only superusers can read it
+// GET /metrics
+func Handler(e *core.RequestEvent) error {
+	if !authorized(e) {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Metrics require a valid token"})
+	}
+
+	var body bytes.Buffer
+	Default.WriteText(&body)
+
+	return e.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", body.Bytes())
+}
+
+func authorized(e *core.RequestEvent) bool {
+	if e.HasSuperuserAuth() {
+		return true
+	}
+	token := os.Getenv("METRICS_TOKEN")
+	if token == "" {
+		return false
+	}
+	provided, ok := strings.CutPrefix(e.Request.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"time"
+
+	"pocketbase/internal/circuit"
+)
+
+// Upstream outcomes - only upstream_error counts against the provider (see circuit.IsOutage)
+const (
+	OutcomeOK            = "ok"
+	OutcomeUpstreamError = "upstream_error"
+	OutcomeRequestError  = "request_error"
+)
+
+var (
+	upstreamRequests = NewCounter("ramble_upstream_requests_total",
+		"Calls to upstream AI providers, by provider and outcome",
+		"provider", "outcome")
+	upstreamDuration = NewHistogram("ramble_upstream_request_duration_seconds",
+		"Latency of calls to upstream AI providers, by provider",
+		DefaultBuckets, "provider")
+)
+
+// ObserveUpstream records a call to a provider that started at start and ended with err
+func ObserveUpstream(provider string, start time.Time, err error) {
+	upstreamDuration.Observe(time.Since(start).Seconds(), provider)
+	upstreamRequests.Inc(provider, upstreamOutcome(err))
+}
+
+func upstreamOutcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case circuit.IsOutage(err):
+		return OutcomeUpstreamError
+	default:
+		return OutcomeRequestError
+	}
+}
+
+func init() {
+	NewGaugeFunc("ramble_circuit_state",
+		"Circuit breaker state per provider: 1 for the current state, 0 otherwise",
+		func() []Sample {
+			var samples []Sample
+			for _, breaker := range circuit.Breakers() {
+				current := breaker.State()
+				for _, state := range []circuit.State{circuit.StateClosed, circuit.StateOpen, circuit.StateHalfOpen} {
+					value := 0.0
+					if state == current {
+						value = 1
+					}
+					samples = append(samples, Sample{Labels: []string{breaker.Name(), string(state)}, Value: value})
+				}
+			}
+			return samples
+		}, "provider", "state")
+}
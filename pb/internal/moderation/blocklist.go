@@ -0,0 +1,67 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// blocklistCategory is the category of every blocklist match
+const blocklistCategory = "blocklist"
+
+// Blocklist is a local classifier flagging text that contains any of its terms as whole words,
+// ignoring case. It needs no network access, for self-hosted deployments
+type Blocklist struct {
+	pattern *regexp.Regexp
+}
+
+// NewBlocklist builds a classifier for terms (words or phrases)
+func NewBlocklist(terms []string) (*Blocklist, error) {
+	var quoted []string
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			quoted = append(quoted, regexp.QuoteMeta(term))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil, fmt.Errorf("moderation blocklist is empty")
+	}
+	pattern, err := regexp.Compile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	if err != nil {
+		return nil, err
+	}
+	return &Blocklist{pattern: pattern}, nil
+}
+
+// NewBlocklistFromEnv reads the terms from MODERATION_BLOCKLIST (comma separated) or from the
+// file named by MODERATION_BLOCKLIST_FILE (one term per line, # for comments)
+func NewBlocklistFromEnv() (*Blocklist, error) {
+	terms := strings.Split(os.Getenv("MODERATION_BLOCKLIST"), ",")
+	if path := os.Getenv("MODERATION_BLOCKLIST_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read moderation blocklist: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				terms = append(terms, line)
+			}
+		}
+	}
+	return NewBlocklist(terms)
+}
+
+func (b *Blocklist) Name() string { return ProviderBlocklist }
+
+// Moderate flags text containing a term, scoring it by how many matches it holds
+func (b *Blocklist) Moderate(_ context.Context, text string) (*Verdict, error) {
+	matches := len(b.pattern.FindAllStringIndex(text, -1))
+	verdict := &Verdict{Flagged: matches > 0, Provider: ProviderBlocklist}
+	if matches > 0 {
+		verdict.Categories = []string{blocklistCategory}
+		verdict.Scores = map[string]float64{blocklistCategory: float64(matches)}
+	}
+	return verdict, nil
+}
@@ -0,0 +1,153 @@
+// Package moderation screens user text prompts and audio transcripts for disallowed content,
+// through the OpenAI moderation endpoint or a local blocklist, and records what it flags in the
+// moderation_flags collection (superusers only)
+package moderation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Moderator names read from MODERATION_PROVIDER
+const (
+	ProviderOpenAI    = "openai"
+	ProviderBlocklist = "blocklist"
+)
+
+// Sources of moderated text
+const (
+	SourcePrompt     = "prompt"
+	SourceTranscript = "transcript"
+)
+
+// Actions taken on flagged content, read from MODERATION_TRANSCRIPTS for transcripts.
+// Flagged prompts are always blocked
+const (
+	ActionBlock = "block" // the request fails with CONTENT_FLAGGED
+	ActionFlag  = "flag"  // the content is recorded, and returned anyway
+	ActionOff   = "off"   // not moderated
+)
+
+// ErrorCode is the API error code of requests refused for their content
+const ErrorCode = "CONTENT_FLAGGED"
+
+// excerptLength caps how much flagged text is kept for review
+const excerptLength = 500
+
+// ErrFlagged is returned when content was flagged and the action is block
+var ErrFlagged = errors.New("content flagged by moderation")
+
+// Verdict is a moderator's decision on one text
+type Verdict struct {
+	Flagged    bool               `json:"flagged"`
+	Categories []string           `json:"categories,omitempty"` // flagged categories, e.g. "harassment"
+	Scores     map[string]float64 `json:"scores,omitempty"`
+	Provider   string             `json:"provider"`
+}
+
+// Moderator classifies text
+type Moderator interface {
+	Name() string
+	Moderate(ctx context.Context, text string) (*Verdict, error)
+}
+
+// Config is the moderation policy
+type Config struct {
+	Moderator  Moderator // nil when moderation is off
+	Prompts    bool      // screen process-text prompts
+	Transcript string    // ActionBlock, ActionFlag or ActionOff
+	FailClosed bool      // refuse prompts when the moderator is unreachable
+}
+
+// ConfigFromEnv reads MODERATION_PROVIDER (openai or blocklist; unset turns moderation off),
+// MODERATION_PROMPTS (default true), MODERATION_TRANSCRIPTS (flag, block or off; default flag)
+// and MODERATION_FAIL_CLOSED (default false)
+func ConfigFromEnv() (Config, error) {
+	config := Config{Prompts: os.Getenv("MODERATION_PROMPTS") != "false", Transcript: ActionFlag,
+		FailClosed: os.Getenv("MODERATION_FAIL_CLOSED") == "true"}
+	switch action := strings.ToLower(strings.TrimSpace(os.Getenv("MODERATION_TRANSCRIPTS"))); action {
+	case ActionBlock, ActionOff:
+		config.Transcript = action
+	}
+
+	switch provider := strings.ToLower(strings.TrimSpace(os.Getenv("MODERATION_PROVIDER"))); provider {
+	case "":
+	case ProviderOpenAI:
+		config.Moderator = NewOpenAIFromEnv()
+	case ProviderBlocklist:
+		moderator, err := NewBlocklistFromEnv()
+		if err != nil {
+			return Config{}, err
+		}
+		config.Moderator = moderator
+	default:
+		return Config{}, fmt.Errorf("unknown moderation provider %q", provider)
+	}
+	return config, nil
+}
+
+// Enabled reports whether text from source is moderated
+func (c Config) Enabled(source string) bool {
+	if c.Moderator == nil {
+		return false
+	}
+	if source == SourcePrompt {
+		return c.Prompts
+	}
+	return c.Transcript != ActionOff
+}
+
+// Flag is flagged content to record
+type Flag struct {
+	UserID          string
+	Source          string
+	Action          string
+	Text            string // trimmed to an excerpt when stored
+	TaskType        string // text requests
+	ProcessedFileID string // transcripts
+	Verdict         *Verdict
+}
+
+// Record stores a flag in moderation_flags
+func Record(app core.App, flag Flag) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("moderation_flags")
+	if err != nil {
+		return nil, err
+	}
+	record := core.NewRecord(collection)
+	record.Set("user_id", flag.UserID)
+	record.Set("source", flag.Source)
+	record.Set("action", flag.Action)
+	record.Set("provider", flag.Verdict.Provider)
+	record.Set("categories", flag.Verdict.Categories)
+	record.Set("scores", flag.Verdict.Scores)
+	record.Set("excerpt", Excerpt(flag.Text))
+	record.Set("task_type", flag.TaskType)
+	record.Set("processed_file_id", flag.ProcessedFileID)
+	return record, app.Save(record)
+}
+
+// Excerpt trims text to the part kept for review
+func Excerpt(text string) string {
+	text = strings.TrimSpace(text)
+	if runes := []rune(text); len(runes) > excerptLength {
+		return string(runes[:excerptLength]) + "…"
+	}
+	return text
+}
+
+// Check moderates text with a timeout, returning a nil verdict when moderation is off for source
+func (c Config) Check(ctx context.Context, source, text string) (*Verdict, error) {
+	if !c.Enabled(source) || strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	return c.Moderator.Moderate(ctx, text)
+}
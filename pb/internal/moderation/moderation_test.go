@@ -0,0 +1,129 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlocklist(t *testing.T) {
+	blocklist, err := NewBlocklist([]string{"forbidden", " bad phrase ", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]float64{
+		"nothing to see here":               0,
+		"this is FORBIDDEN":                 1,
+		"a bad phrase, and forbidden again": 2,
+		"unforbiddenly a bad phrasebook":    0,
+		"Forbidden. Forbidden! forbidden?":  3,
+	}
+	for text, matches := range tests {
+		verdict, err := blocklist.Moderate(context.Background(), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdict.Flagged != (matches > 0) || verdict.Scores[blocklistCategory] != matches {
+			t.Errorf("%q: got %+v, want %v matches", text, verdict, matches)
+		}
+	}
+
+	if _, err := NewBlocklist([]string{" ", ""}); err == nil {
+		t.Error("Expected an empty blocklist to be rejected")
+	}
+}
+
+func TestBlocklistFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	os.WriteFile(path, []byte("# reviewed terms\nfirst\n\nsecond term\n"), 0o600)
+	t.Setenv("MODERATION_BLOCKLIST", "")
+	t.Setenv("MODERATION_BLOCKLIST_FILE", path)
+
+	blocklist, err := NewBlocklistFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict, _ := blocklist.Moderate(context.Background(), "the second term"); !verdict.Flagged {
+		t.Error("Expected a term from the file to be flagged")
+	}
+	if verdict, _ := blocklist.Moderate(context.Background(), "reviewed terms"); verdict.Flagged {
+		t.Error("Expected comments to be ignored")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("MODERATION_PROVIDER", "")
+	t.Setenv("MODERATION_PROMPTS", "")
+	t.Setenv("MODERATION_TRANSCRIPTS", "")
+	t.Setenv("MODERATION_FAIL_CLOSED", "")
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Enabled(SourcePrompt) || config.Enabled(SourceTranscript) {
+		t.Error("Expected moderation to be off without a provider")
+	}
+	if verdict, err := config.Check(context.Background(), SourcePrompt, "anything"); verdict != nil || err != nil {
+		t.Errorf("Expected no verdict when moderation is off, got %v, %v", verdict, err)
+	}
+
+	t.Setenv("MODERATION_PROVIDER", "Blocklist")
+	t.Setenv("MODERATION_BLOCKLIST", "forbidden")
+	t.Setenv("MODERATION_TRANSCRIPTS", "off")
+	config, err = ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.Enabled(SourcePrompt) || config.Enabled(SourceTranscript) {
+		t.Errorf("Expected prompts only to be moderated, got %+v", config)
+	}
+
+	t.Setenv("MODERATION_PROVIDER", "other")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("Expected an unknown provider to be rejected")
+	}
+}
+
+func TestOpenAIModerate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/moderations" || r.Header.Get("Authorization") != "Bearer test" {
+			t.Errorf("Unexpected request %s %v", r.URL.Path, r.Header)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["model"] != DefaultOpenAIModel {
+			t.Errorf("Unexpected model %q", body["model"])
+		}
+		w.Write([]byte(`{"results": [{"flagged": true,
+			"categories": {"violence": true, "harassment": true, "sexual": false},
+			"category_scores": {"violence": 0.91, "harassment": 0.62, "sexual": 0.01}}]}`))
+	}))
+	defer server.Close()
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+	t.Setenv("MODERATION_MODEL", "")
+
+	verdict, err := NewOpenAIFromEnv().Moderate(context.Background(), "some text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verdict.Flagged || strings.Join(verdict.Categories, ",") != "harassment,violence" || verdict.Scores["violence"] != 0.91 {
+		t.Errorf("Unexpected verdict %+v", verdict)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("é", excerptLength+10)
+	if got := []rune(Excerpt(long)); len(got) != excerptLength+1 {
+		t.Errorf("Expected the excerpt to be trimmed, got %d runes", len(got))
+	}
+	if got := Excerpt("  short  "); got != "short" {
+		t.Errorf("Unexpected excerpt %q", got)
+	}
+}
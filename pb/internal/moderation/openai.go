@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+
+	"pocketbase/internal/gateway"
+)
+
+// DefaultOpenAIModel is the moderation model used unless MODERATION_MODEL overrides it
+const DefaultOpenAIModel = "omni-moderation-latest"
+
+// OpenAI classifies text with the OpenAI moderation endpoint, free for OpenAI API users
+type OpenAI struct {
+	Gateway    gateway.Gateway
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewOpenAIFromEnv uses the OpenAI gateway (OPENAI_API_KEY, OPENAI_BASE_URL) and MODERATION_MODEL
+func NewOpenAIFromEnv() *OpenAI {
+	model := os.Getenv("MODERATION_MODEL")
+	if model == "" {
+		model = DefaultOpenAIModel
+	}
+	return &OpenAI{Gateway: gateway.OpenAIFromEnv(), Model: model, HTTPClient: http.DefaultClient}
+}
+
+func (o *OpenAI) Name() string { return ProviderOpenAI }
+
+// Moderate sends text to /moderations and reports the categories OpenAI flagged
+func (o *OpenAI) Moderate(ctx context.Context, text string) (*Verdict, error) {
+	body, err := json.Marshal(map[string]string{"model": o.Model, "input": text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.Gateway.URL("/moderations"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	o.Gateway.Auth.Apply(req.Header)
+
+	resp, err := o.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("moderation endpoint returned %d: %s", resp.StatusCode, detail)
+	}
+
+	var parsed struct {
+		Results []struct {
+			Flagged        bool               `json:"flagged"`
+			Categories     map[string]bool    `json:"categories"`
+			CategoryScores map[string]float64 `json:"category_scores"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("invalid moderation response: %w", err)
+	}
+	if len(parsed.Results) == 0 {
+		return nil, fmt.Errorf("moderation response has no results")
+	}
+
+	result := parsed.Results[0]
+	verdict := &Verdict{Flagged: result.Flagged, Scores: result.CategoryScores, Provider: ProviderOpenAI}
+	for category, flagged := range result.Categories {
+		if flagged {
+			verdict.Categories = append(verdict.Categories, category)
+		}
+	}
+	sort.Strings(verdict.Categories)
+	return verdict, nil
+}
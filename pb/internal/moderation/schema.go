@@ -0,0 +1,29 @@
+package moderation
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields moderation writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "moderation",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "moderation_flags",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "source", Type: "select", Values: []string{SourcePrompt, SourceTranscript}},
+					{Name: "action", Type: "select", Values: []string{ActionBlock, ActionFlag}},
+					{Name: "provider", Type: "text"},
+					{Name: "categories", Type: "json"},
+					{Name: "scores", Type: "json"},
+					{Name: "excerpt", Type: "text"},
+					{Name: "task_type", Type: "text"},
+					{Name: "processed_file_id", Type: "text"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_moderation_flags_user", Columns: "user_id, created"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,82 @@
+package notifications
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"os"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/otp"
+)
+
+// sendEmail emails the user a notification of the kind once per reference, unless they've turned
+// the kind off. It's logged in the communications timeline, which is also how repeats are spotted
+func sendEmail(app core.App, user *core.Record, kind, reference, subject, body string) {
+	if user.Email() == "" || !user.GetDateTime("deactivated_at").IsZero() || !EmailEnabled(user, kind) {
+		return
+	}
+	if _, err := app.FindFirstRecordByFilter("communications", "kind = {:kind} && reference = {:reference}",
+		map[string]any{"kind": kind, "reference": reference}); err == nil {
+		return
+	}
+
+	if err := otp.SendTrackedEmail(app, user.Email(), subject, body, kind, reference); err != nil {
+		log.Printf("[NOTIFICATIONS] ERROR: Failed to email %s notification to user %s: %v", kind, user.Id, err)
+		return
+	}
+	log.Printf("[NOTIFICATIONS] Emailed %s notification to user %s", kind, user.Id)
+}
+
+// frontendLink returns a link to a page of the web app
+func frontendLink(path string) string {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	return frontendURL + path
+}
+
+// getSubscriptionCanceledEmailContent returns subject and HTML body for a cancelled subscription
+func getSubscriptionCanceledEmailContent(appName, planName string) (string, string) {
+	subject := fmt.Sprintf("Your %s subscription has been cancelled", appName)
+	body := fmt.Sprintf(`
+		<h2>Your subscription has been cancelled</h2>
+		<p>Your <strong>%s</strong> plan has ended and your account is now on the free plan.
+		Any unused time on a paid plan is credited by our payment provider.</p>
+		<p>Your files and API keys are untouched. <a href="%s">Pick a plan</a> whenever you need more hours again.</p>
+		`, html.EscapeString(planName), frontendLink("/pricing"))
+	return subject, body
+}
+
+// getPlanChangedEmailContent returns subject and HTML body for a plan change
+func getPlanChangedEmailContent(appName, fromPlan, toPlan string, toHours float64) (string, string) {
+	subject := fmt.Sprintf("Your %s plan is now %s", appName, toPlan)
+	body := fmt.Sprintf(`
+		<h2>Your plan has changed</h2>
+		<p>Your plan changed from <strong>%s</strong> to <strong>%s</strong>, which includes %.0f transcription hours a month.
+		The change applies straight away.</p>
+		<p>You can review your plan changes and invoices in your <a href="%s">billing settings</a>.
+		If you didn't make this change, please contact support.</p>
+		`, html.EscapeString(fromPlan), html.EscapeString(toPlan), toHours, frontendLink("/settings/billing"))
+	return subject, body
+}
+
+// getUsageEmailContent returns subject and HTML body for usage reaching percent of the plan's hours
+func getUsageEmailContent(appName string, percent int, hoursUsed, hoursLimit float64, resetsOn string) (string, string) {
+	subject := fmt.Sprintf("You've used %d%% of your %s hours this month", percent, appName)
+	next := fmt.Sprintf(`<p>Your hours reset on %s. <a href="%s">Upgrade your plan</a> if you need more before then.</p>`,
+		resetsOn, frontendLink("/pricing"))
+	if percent >= 100 {
+		subject = fmt.Sprintf("You've used all your %s hours this month", appName)
+		next = fmt.Sprintf(`<p>New transcriptions are paused until your hours reset on %s.
+		<a href="%s">Upgrade your plan</a> to keep transcribing now.</p>`, resetsOn, frontendLink("/pricing"))
+	}
+	body := fmt.Sprintf(`
+		<h2>%d%% of your monthly hours used</h2>
+		<p>You've transcribed %.1f of your plan's %.0f hours this month.</p>
+		%s
+		`, percent, hoursUsed, hoursLimit, next)
+	return subject, body
+}
@@ -0,0 +1,132 @@
+package notifications
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// GetPreferencesHandler returns the caller's notification preferences
+// GET /api/notifications/preferences
+func GetPreferencesHandler(e *core.RequestEvent) error {
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+	return e.JSON(http.StatusOK, UserPreferences(e.Auth))
+}
+
+// UpdatePreferencesHandler turns notification kinds on or off for the caller and sets their usage
+// thresholds. Kinds left out of the request keep their setting, as do thresholds when omitted
+// PUT /api/notifications/preferences
+func UpdatePreferencesHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var update Preferences
+	if err := e.BindBody(&update); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	preferences := UserPreferences(e.Auth)
+	if err := preferences.Apply(update); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	e.Auth.Set("notification_preferences", preferences)
+	if err := app.Save(e.Auth); err != nil {
+		logging.FromRequest(e).Error("Cannot save notification preferences", "user_id", e.Auth.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save notification preferences"})
+	}
+
+	return e.JSON(http.StatusOK, preferences)
+}
+
+// ListHandler returns the caller's notifications, newest first, with the unread count
+// GET /api/notifications?unread=true&page=1&per_page=30
+func ListHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	query := e.Request.URL.Query()
+	page, perPage := 1, 30
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if pp, err := strconv.Atoi(query.Get("per_page")); err == nil && pp > 0 {
+		perPage = min(pp, 100)
+	}
+
+	filter := "user_id = {:user}"
+	where := dbx.HashExp{"user_id": e.Auth.Id}
+	if query.Get("unread") == "true" {
+		filter += " && read = false"
+		where["read"] = false
+	}
+	records, err := app.FindRecordsByFilter("notifications", filter, "-created", perPage, (page-1)*perPage,
+		dbx.Params{"user": e.Auth.Id})
+	if err != nil {
+		logging.FromRequest(e).Error("Cannot list notifications", "user_id", e.Auth.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load notifications"})
+	}
+	total, err := app.CountRecords("notifications", where)
+	if err != nil {
+		total = int64(len(records))
+	}
+	unread, err := UnreadCount(app, e.Auth.Id)
+	if err != nil {
+		logging.FromRequest(e).Warn("Cannot count unread notifications", "user_id", e.Auth.Id, "error", err)
+	}
+
+	items := make([]Item, len(records))
+	for i, record := range records {
+		items[i] = toItem(record)
+	}
+	return e.JSON(http.StatusOK, map[string]any{
+		"items":        items,
+		"page":         page,
+		"per_page":     perPage,
+		"total":        total,
+		"unread_count": unread,
+	})
+}
+
+// MarkReadHandler marks one of the caller's notifications read
+// POST /api/notifications/{id}/read
+func MarkReadHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	record, err := app.FindRecordById("notifications", e.Request.PathValue("id"))
+	if err != nil || record.GetString("user_id") != e.Auth.Id {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Notification not found"})
+	}
+	if err := MarkRead(app, record); err != nil {
+		logging.FromRequest(e).Error("Cannot mark notification read", "notification_id", record.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update notification"})
+	}
+
+	return e.JSON(http.StatusOK, toItem(record))
+}
+
+// MarkAllReadHandler marks all of the caller's notifications read
+// POST /api/notifications/read-all
+func MarkAllReadHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	updated, err := MarkAllRead(app, e.Auth.Id)
+	if err != nil {
+		logging.FromRequest(e).Error("Cannot mark notifications read", "user_id", e.Auth.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update notifications"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{"updated": updated})
+}
@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// RegisterHooks notifies users in the app and by email when their subscription is cancelled, their
+// plan changes and their usage crosses a threshold. Payment failures and trial reminders are sent
+// by the billing jobs
+func RegisterHooks(app core.App) {
+	app.OnRecordAfterCreateSuccess("subscription_history").BindFunc(func(e *core.RecordEvent) error {
+		go notifySubscriptionChange(e.App, e.Record)
+		return e.Next()
+	})
+
+	usageChanged := func(e *core.RecordEvent) error {
+		// Read before the goroutine, as the record keeps changing with later saves
+		before := e.Record.Original().GetFloat("hours_used")
+		go notifyUsage(e.App, e.Record.Fresh(), before)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("monthly_usage").BindFunc(usageChanged)
+	app.OnRecordAfterUpdateSuccess("monthly_usage").BindFunc(usageChanged)
+}
+
+// historyKind returns the notification kind for a subscription being moved to history, or ""
+// when the change isn't worth an email. Trials running out and the automatic free plan fallback
+// aren't cancellations - the trial emails cover the former
+func historyKind(reason, previousStatus, initiatedBy, fromPlanID, toPlanID string) string {
+	switch reason {
+	case "subscription_cancelled":
+		return KindSubscriptionCanceled
+	case "switched_to_free_plan":
+		if previousStatus == "trialing" || initiatedBy == string(subscription.InitiatedBySystem) {
+			return ""
+		}
+		return KindSubscriptionCanceled
+	case "upgrade", "downgrade", "interval_change", "plan_change", "replaced_by_new_subscription":
+		if toPlanID == "" || toPlanID == fromPlanID {
+			return ""
+		}
+		return KindPlanChanged
+	}
+	return ""
+}
+
+func notifySubscriptionChange(app core.App, history *core.Record) {
+	fromPlanID := history.GetString("plan_id")
+	toPlanID := history.GetString("new_plan_id")
+	kind := historyKind(history.GetString("replacement_reason"), history.GetString("status"),
+		history.GetString("initiated_by"), fromPlanID, toPlanID)
+	if kind == "" {
+		return
+	}
+
+	user, err := app.FindRecordById("users", history.GetString("user_id"))
+	if err != nil {
+		return
+	}
+	fromPlan, err := app.FindRecordById("subscription_plans", fromPlanID)
+	if err != nil {
+		return
+	}
+	appName := app.Settings().Meta.AppName
+
+	switch kind {
+	case KindSubscriptionCanceled:
+		if fromPlan.GetInt("price_cents") == 0 {
+			return // nothing was cancelled on a free plan
+		}
+		// A user cancelling moves the subscription to history, then the provider's webhook may
+		// too; both share the provider subscription, so the user gets one email
+		reference := history.GetString("provider_subscription_id")
+		if reference == "" {
+			reference = history.Id
+		}
+		Create(app, Notification{
+			UserID:    user.Id,
+			Kind:      kind,
+			Title:     "Subscription cancelled",
+			Body:      fmt.Sprintf("Your %s plan has ended and your account is now on the free plan.", fromPlan.GetString("name")),
+			Link:      "/pricing",
+			Reference: reference,
+		})
+		subject, body := getSubscriptionCanceledEmailContent(appName, fromPlan.GetString("name"))
+		sendEmail(app, user, kind, reference, subject, body)
+	case KindPlanChanged:
+		toPlan, err := app.FindRecordById("subscription_plans", toPlanID)
+		if err != nil {
+			return
+		}
+		Create(app, Notification{
+			UserID: user.Id,
+			Kind:   kind,
+			Title:  "Plan changed",
+			Body: fmt.Sprintf("Your plan changed from %s to %s, which includes %.0f transcription hours a month.",
+				fromPlan.GetString("name"), toPlan.GetString("name"), toPlan.GetFloat("hours_per_month")),
+			Link:      "/settings/billing",
+			Reference: history.Id,
+		})
+		subject, body := getPlanChangedEmailContent(appName, fromPlan.GetString("name"), toPlan.GetString("name"),
+			toPlan.GetFloat("hours_per_month"))
+		sendEmail(app, user, kind, history.Id, subject, body)
+	}
+}
+
+// notifyUsage notifies a user whose personal usage crossed one of their usage thresholds (80% and
+// 100% of their plan's hours unless they set others). Only the highest threshold crossed by an
+// update is sent. Organization pools aren't tied to one user
+// and are skipped
+func notifyUsage(app core.App, usage *core.Record, before float64) {
+	if usage.GetString("organization_id") != "" {
+		return
+	}
+	after := usage.GetFloat("hours_used")
+	if after <= before {
+		return
+	}
+
+	user, err := app.FindRecordById("users", usage.GetString("user_id"))
+	if err != nil {
+		return
+	}
+	info, err := subscription.NewService(subscription.NewRepository(app)).GetUserSubscriptionInfo(user.Id)
+	if err != nil {
+		return
+	}
+	limit := info.Plan.GetFloat("hours_per_month")
+
+	crossed := subscription.CrossedUsageThresholds(before, after, limit, UserPreferences(user).UsageThresholds)
+	if len(crossed) == 0 {
+		return
+	}
+	percent := crossed[len(crossed)-1]
+	resetsOn := "the start of your next billing period"
+	if periodEnd := usage.GetDateTime("period_end"); !periodEnd.IsZero() {
+		resetsOn = periodEnd.Time().UTC().Format("January 2")
+	}
+	kind, reference := UsageKind(percent), usage.Id+":"+strconv.Itoa(percent)
+	Create(app, Notification{
+		UserID:    user.Id,
+		Kind:      kind,
+		Title:     fmt.Sprintf("%d%% of your monthly hours used", percent),
+		Body:      fmt.Sprintf("You've transcribed %.1f of your plan's %.0f hours this month. Your hours reset on %s.", after, limit, resetsOn),
+		Link:      "/pricing",
+		Reference: reference,
+	})
+	subject, body := getUsageEmailContent(app.Settings().Meta.AppName, percent, after, limit, resetsOn)
+	sendEmail(app, user, kind, reference, subject, body)
+}
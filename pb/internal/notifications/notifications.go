@@ -0,0 +1,117 @@
+// Package notifications tells users about billing and usage events on their account: payment
+// failures, cancellations, plan changes, usage nearing or reaching the plan's hours and trials
+// ending. Users can turn off the kinds they don't want in their notification preferences
+package notifications
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// Notification kinds. The email kinds recorded in the communications timeline match them
+const (
+	KindPaymentFailed        = "payment_failed" // payment grace reminders
+	KindSubscriptionCanceled = "subscription_canceled"
+	KindPlanChanged          = "plan_changed"
+	KindUsage80              = "usage_80"
+	KindUsage100             = "usage_100"
+	KindUsageAlert           = "usage_alert" // usage thresholds users set other than 80% and 100%
+	KindTrialEnding          = "trial_ending"
+)
+
+// Kinds lists the notification kinds, in the order preferences are shown
+var Kinds = []string{KindPaymentFailed, KindSubscriptionCanceled, KindPlanChanged, KindUsage80, KindUsage100, KindUsageAlert, KindTrialEnding}
+
+// maxUsageThresholds caps how many usage alerts a user can set, so a month's usage doesn't turn
+// into an email per transcription
+const maxUsageThresholds = 5
+
+// requiredKinds can't be turned off - losing access to a plan without warning costs more than an email
+var requiredKinds = []string{KindPaymentFailed}
+
+// UsageKind is the notification kind for usage crossing percent of the plan's hours. The
+// default thresholds have their own kinds; any other threshold a user sets is a usage_alert
+func UsageKind(percent int) string {
+	switch percent {
+	case 80:
+		return KindUsage80
+	case 100:
+		return KindUsage100
+	}
+	return KindUsageAlert
+}
+
+// Preferences are the notifications a user receives, by channel and kind. Kinds missing from a
+// stored preference are on
+type Preferences struct {
+	Email map[string]bool `json:"email"`
+	// UsageThresholds are the percentages of the plan's hours the user is notified at, ascending.
+	// Missing means subscription.UsageThresholds; empty turns usage alerts off
+	UsageThresholds []int `json:"usage_thresholds"`
+}
+
+// UserPreferences returns the user's preferences with every kind filled in
+func UserPreferences(user *core.Record) Preferences {
+	var stored Preferences
+	_ = user.UnmarshalJSONField("notification_preferences", &stored)
+
+	preferences := Preferences{Email: map[string]bool{}}
+	for _, kind := range Kinds {
+		enabled, ok := stored.Email[kind]
+		preferences.Email[kind] = !ok || enabled || slices.Contains(requiredKinds, kind)
+	}
+	preferences.UsageThresholds = stored.UsageThresholds
+	if preferences.UsageThresholds == nil {
+		preferences.UsageThresholds = slices.Clone(subscription.UsageThresholds)
+	}
+	return preferences
+}
+
+// EmailEnabled reports whether the user receives emails of the kind
+func EmailEnabled(user *core.Record, kind string) bool {
+	enabled, ok := UserPreferences(user).Email[kind]
+	return !ok || enabled
+}
+
+// Apply updates the preferences with the kinds set in update, and replaces the usage thresholds
+// when update has them
+func (p *Preferences) Apply(update Preferences) error {
+	for kind, enabled := range update.Email {
+		if !slices.Contains(Kinds, kind) {
+			return fmt.Errorf("unknown notification kind %q", kind)
+		}
+		if !enabled && slices.Contains(requiredKinds, kind) {
+			return fmt.Errorf("%s emails can't be turned off", kind)
+		}
+		p.Email[kind] = enabled
+	}
+	if update.UsageThresholds != nil {
+		thresholds, err := normalizeUsageThresholds(update.UsageThresholds)
+		if err != nil {
+			return err
+		}
+		p.UsageThresholds = thresholds
+	}
+	return nil
+}
+
+// normalizeUsageThresholds sorts and dedupes thresholds, rejecting ones outside 1-100%
+func normalizeUsageThresholds(thresholds []int) ([]int, error) {
+	normalized := []int{}
+	for _, percent := range thresholds {
+		if percent < 1 || percent > 100 {
+			return nil, fmt.Errorf("usage threshold %d%% must be between 1 and 100", percent)
+		}
+		normalized = append(normalized, percent)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > maxUsageThresholds {
+		return nil, fmt.Errorf("at most %d usage thresholds can be set", maxUsageThresholds)
+	}
+	return normalized, nil
+}
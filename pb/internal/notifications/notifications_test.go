@@ -0,0 +1,102 @@
+package notifications
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestUserPreferences(t *testing.T) {
+	users := core.NewAuthCollection("users")
+	users.Fields.Add(&core.JSONField{Name: "notification_preferences"})
+	user := core.NewRecord(users)
+
+	for _, kind := range Kinds {
+		if !EmailEnabled(user, kind) {
+			t.Errorf("%s should be on by default", kind)
+		}
+	}
+
+	user.Set("notification_preferences", map[string]any{"email": map[string]bool{KindUsage80: false, KindPaymentFailed: false}})
+	if EmailEnabled(user, KindUsage80) {
+		t.Error("usage_80 was turned off")
+	}
+	if !EmailEnabled(user, KindUsage100) {
+		t.Error("kinds missing from stored preferences should be on")
+	}
+	if !EmailEnabled(user, KindPaymentFailed) {
+		t.Error("payment_failed can't be turned off")
+	}
+	if got := UserPreferences(user).UsageThresholds; !slices.Equal(got, []int{80, 100}) {
+		t.Errorf("usage thresholds = %v, want the defaults", got)
+	}
+
+	user.Set("notification_preferences", map[string]any{"usage_thresholds": []int{}})
+	if got := UserPreferences(user).UsageThresholds; got == nil || len(got) != 0 {
+		t.Errorf("usage thresholds = %v, want usage alerts off", got)
+	}
+}
+
+func TestUsageKind(t *testing.T) {
+	for percent, want := range map[int]string{80: KindUsage80, 100: KindUsage100, 50: KindUsageAlert, 95: KindUsageAlert} {
+		if got := UsageKind(percent); got != want {
+			t.Errorf("UsageKind(%d) = %s, want %s", percent, got, want)
+		}
+	}
+}
+
+func TestPreferencesApply(t *testing.T) {
+	preferences := Preferences{Email: map[string]bool{KindPlanChanged: true}}
+	if err := preferences.Apply(Preferences{Email: map[string]bool{KindPlanChanged: false}}); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+	if preferences.Email[KindPlanChanged] {
+		t.Error("plan_changed should be off")
+	}
+
+	if err := preferences.Apply(Preferences{Email: map[string]bool{"newsletter": false}}); err == nil {
+		t.Error("unknown kinds should be rejected")
+	}
+	if err := preferences.Apply(Preferences{Email: map[string]bool{KindPaymentFailed: false}}); err == nil {
+		t.Error("turning off payment_failed should be rejected")
+	}
+
+	preferences.UsageThresholds = []int{80, 100}
+	if err := preferences.Apply(Preferences{UsageThresholds: []int{95, 50, 80, 50}}); err != nil {
+		t.Fatalf("Apply() = %v", err)
+	}
+	if !slices.Equal(preferences.UsageThresholds, []int{50, 80, 95}) {
+		t.Errorf("usage thresholds = %v, want [50 80 95]", preferences.UsageThresholds)
+	}
+	if err := preferences.Apply(Preferences{Email: map[string]bool{KindPlanChanged: true}}); err != nil || len(preferences.UsageThresholds) != 3 {
+		t.Errorf("usage thresholds = %v after an update without them, want them kept", preferences.UsageThresholds)
+	}
+	for _, thresholds := range [][]int{{0}, {120}, {10, 20, 30, 40, 50, 60}} {
+		if err := preferences.Apply(Preferences{UsageThresholds: thresholds}); err == nil {
+			t.Errorf("usage thresholds %v should be rejected", thresholds)
+		}
+	}
+}
+
+func TestHistoryKind(t *testing.T) {
+	tests := []struct {
+		reason, status, initiatedBy, from, to string
+		want                                  string
+	}{
+		{"subscription_cancelled", "active", "webhook", "pro", "", KindSubscriptionCanceled},
+		{"switched_to_free_plan", "active", "user", "pro", "free", KindSubscriptionCanceled},
+		{"switched_to_free_plan", "trialing", "user", "pro", "free", ""},
+		{"switched_to_free_plan", "active", "system", "pro", "free", ""},
+		{"upgrade", "active", "user", "basic", "pro", KindPlanChanged},
+		{"plan_change", "active", "admin", "basic", "pro", KindPlanChanged},
+		{"replaced_by_new_subscription", "trialing", "webhook", "pro", "pro", ""},
+		{"interval_change", "active", "user", "pro", "", ""},
+		{"unknown", "active", "user", "basic", "pro", ""},
+	}
+	for _, tt := range tests {
+		if got := historyKind(tt.reason, tt.status, tt.initiatedBy, tt.from, tt.to); got != tt.want {
+			t.Errorf("historyKind(%q, %q, %q, %q, %q) = %q, want %q", tt.reason, tt.status, tt.initiatedBy, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
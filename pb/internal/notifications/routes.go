@@ -0,0 +1,32 @@
+package notifications
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the notification center and preference routes
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/notifications", Handler: func(e *core.RequestEvent) error {
+			return ListHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/notifications/read-all", Handler: func(e *core.RequestEvent) error {
+			return MarkAllReadHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/notifications/{id}/read", Handler: func(e *core.RequestEvent) error {
+			return MarkReadHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/notifications/preferences", Handler: func(e *core.RequestEvent) error {
+			return GetPreferencesHandler(e)
+		}},
+		routes.Route{Method: http.MethodPut, Path: "/api/notifications/preferences", Handler: func(e *core.RequestEvent) error {
+			return UpdatePreferencesHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,34 @@
+package notifications
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the notification center and preferences read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "notifications",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "users",
+				Fields: []schemacheck.Field{
+					{Name: "notification_preferences", Type: "json"},
+				},
+			},
+			{
+				Collection: "notifications",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "type", Type: "select", Values: Kinds},
+					{Name: "title", Type: "text"},
+					{Name: "body", Type: "text"},
+					{Name: "link", Type: "text"},
+					{Name: "reference", Type: "text"},
+					{Name: "read", Type: "bool"},
+					{Name: "read_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_notifications_user_read", Columns: "user_id, read, created"},
+				},
+			},
+		},
+	}
+}
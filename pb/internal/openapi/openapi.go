@@ -0,0 +1,352 @@
+// Package openapi generates an OpenAPI 3 document from the routes modules declare, so the
+// desktop and web clients can generate typed SDKs. Request and response schemas are reflected
+// from the Go types in each route's Doc; routes without one are listed with their method,
+// path, parameters and auth only
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"pocketbase/internal/routes"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps lowercase HTTP methods to their operations
+type PathItem map[string]*Operation
+
+// Operation is one method on a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody is a JSON request body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response for one status
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas operations refer to and the security schemes
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way of authenticating requests
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema is the subset of JSON Schema the reflected Go types need
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// errorSchema is the body every custom route answers errors with
+const errorSchema = "Error"
+
+// Security schemes: API keys and delegated tokens are sent as bearer tokens, PocketBase auth
+// tokens (users and superusers) as the bare Authorization header
+const (
+	schemeAPIKey  = "apiKey"
+	schemeSession = "pocketbaseAuth"
+)
+
+var pathParam = regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`)
+
+// Build generates the document for the routes as served under API version. Routes that accept
+// any method (the tus protocol) aren't JSON endpoints and are left out
+func Build(declared []routes.Route, version int) *Document {
+	g := &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+	g.schemas[errorSchema] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error": {Type: "string"},
+			"code":  {Type: "string"},
+		},
+		Required: []string{"error"},
+	}
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Ramble AI API",
+			Description: "Custom endpoints of the Ramble AI backend. PocketBase's built-in collection API isn't included",
+			Version:     strconv.Itoa(version),
+		},
+		Paths: map[string]PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]SecurityScheme{
+				schemeAPIKey:  {Type: "http", Scheme: "bearer", Description: "API key or delegated token"},
+				schemeSession: {Type: "apiKey", In: "header", Name: "Authorization", Description: "PocketBase auth token of a user or superuser"},
+			},
+		},
+	}
+
+	for _, route := range declared {
+		if route.Method == "" {
+			continue
+		}
+		path := route.Path
+		if !route.Unversioned {
+			path = routes.VersionedPath(path, version)
+		}
+		path = pathParam.ReplaceAllString(path, "{$1}")
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = g.operation(route, path)
+	}
+	return doc
+}
+
+// operation describes the route served at path
+func (g *generator) operation(route routes.Route, path string) *Operation {
+	op := &Operation{
+		OperationID: operationID(route.Method, route.Path),
+		Tags:        []string{tag(route.Path)},
+		Responses:   map[string]*Response{},
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+
+	if route.Auth == routes.AuthSuperuser {
+		op.Security = []map[string][]string{{schemeSession: {}}}
+	} else {
+		// Handlers check auth themselves, and some routes are public
+		op.Security = []map[string][]string{{schemeAPIKey: {}}, {schemeSession: {}}, {}}
+	}
+
+	status := http.StatusOK
+	success := &Response{Description: http.StatusText(status)}
+	if doc := route.Doc; doc != nil {
+		op.Summary = doc.Summary
+		if doc.Status != 0 {
+			status = doc.Status
+			success.Description = http.StatusText(status)
+		}
+		if doc.Request != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: jsonContent(g.schema(reflect.TypeOf(doc.Request)))}
+		}
+		if doc.Response != nil {
+			success.Content = jsonContent(g.schema(reflect.TypeOf(doc.Response)))
+		}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	if route.RateLimited {
+		op.Responses["429"] = &Response{Description: "Rate limit exceeded", Content: jsonContent(ref(errorSchema))}
+	}
+	op.Responses["default"] = &Response{Description: "Error", Content: jsonContent(ref(errorSchema))}
+	return op
+}
+
+// operationID names an operation after its method and path, e.g. getAiJobsById for
+// GET /api/ai/jobs/{id}
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api/"), "/") {
+		if match := pathParam.FindStringSubmatch(segment); match != nil {
+			b.WriteString("By")
+			segment = match[1]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// tag groups operations by the path's first segment after /api/, e.g. "ai" or "admin"
+func tag(path string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/"), "/")
+	return segment
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+func ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType      = reflect.TypeFor[time.Time]()
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+)
+
+// generator reflects Go types into schemas, named struct types becoming shared components
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// schema returns the schema of t as encoding/json marshals it
+func (g *generator) schema(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Custom encodings, e.g. json.RawMessage, could be anything
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return ref(g.component(t))
+	default:
+		// interfaces hold any JSON value
+		return &Schema{}
+	}
+}
+
+// component adds the named struct type to the components once, returning its name. Types
+// sharing a name across packages get the later one's package as a prefix
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	// Reserved before reflecting the fields, so recursive types refer to themselves
+	g.names[t] = name
+	g.schemas[name] = &Schema{}
+	*g.schemas[name] = *g.object(t)
+	return name
+}
+
+// object reflects a struct's exported fields by their json tags. Fields without omitempty are
+// always present and so required
+func (g *generator) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(t, schema)
+	return schema
+}
+
+func (g *generator) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(embedded, schema)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		if strings.Contains(options, "string") {
+			property = &Schema{Type: "string"}
+		}
+		schema.Properties[name] = property
+		if !strings.Contains(options, "omitempty") && !strings.Contains(options, "omitzero") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+//go:build integration
+
+package openapi_test
+
+import (
+	"net/http"
+	"testing"
+
+	"pocketbase/internal/banners"
+	"pocketbase/internal/openapi"
+	"pocketbase/internal/testharness"
+)
+
+func TestSpecEndpoint(t *testing.T) {
+	app := testharness.New(t)
+	server := testharness.Serve(t, app, banners.RegisterRoutes, openapi.RegisterRoutes)
+
+	spec := server.Do(t, http.MethodGet, "/api/openapi.json", "", nil).Expect(t, http.StatusOK).JSON(t)
+	if spec["openapi"] != "3.0.3" {
+		t.Errorf("openapi = %v", spec["openapi"])
+	}
+	paths, _ := spec["paths"].(map[string]any)
+	for _, path := range []string{"/api/v1/banners", "/api/v1/admin/banners/{id}", "/api/openapi.json"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("spec is missing %s", path)
+		}
+	}
+	schemas, _ := spec["components"].(map[string]any)["schemas"].(map[string]any)
+	if _, ok := schemas["BannerRequest"]; !ok {
+		t.Error("spec is missing the BannerRequest schema")
+	}
+
+	server.Do(t, http.MethodGet, "/api/docs", "", nil).Expect(t, http.StatusNotFound)
+}
+
+func TestSwaggerUIInDevelopment(t *testing.T) {
+	t.Setenv("DEVELOPMENT", "true")
+	app := testharness.New(t)
+	server := testharness.Serve(t, app, openapi.RegisterRoutes)
+
+	server.Do(t, http.MethodGet, "/api/docs", "", nil).Expect(t, http.StatusOK)
+}
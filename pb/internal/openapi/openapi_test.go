@@ -0,0 +1,120 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"pocketbase/internal/routes"
+)
+
+type testUsage struct {
+	Tokens int `json:"tokens"`
+}
+
+type testEmbedded struct {
+	ID string `json:"id"`
+}
+
+type testReply struct {
+	testEmbedded
+	Text     string            `json:"text"`
+	Usage    *testUsage        `json:"usage,omitempty"`
+	History  []testUsage       `json:"history"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Created  time.Time         `json:"created"`
+	Note     *string           `json:"note"`
+	Raw      json.RawMessage   `json:"raw,omitempty"`
+	Parent   *testReply        `json:"parent,omitempty"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+type testRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+func TestBuild(t *testing.T) {
+	doc := Build([]routes.Route{
+		{Method: http.MethodPost, Path: "/api/ai/reply", RateLimited: true, Doc: &routes.Doc{
+			Summary: "Reply", Request: testRequest{}, Response: testReply{}, Status: http.StatusCreated,
+		}},
+		{Method: http.MethodGet, Path: "/api/admin/files/{id}", Auth: routes.AuthSuperuser},
+		{Method: http.MethodPost, Path: "/api/webhooks/stripe", Unversioned: true},
+		{Path: "/api/tus/{path...}", Unversioned: true},
+	}, 1)
+
+	reply := doc.Paths["/api/v1/ai/reply"]["post"]
+	if reply == nil {
+		t.Fatalf("versioned path missing, got paths %v", doc.Paths)
+	}
+	if reply.OperationID != "postAiReply" || reply.Summary != "Reply" || !slices.Equal(reply.Tags, []string{"ai"}) {
+		t.Errorf("operation = %q %q %v", reply.OperationID, reply.Summary, reply.Tags)
+	}
+	if got := reply.RequestBody.Content["application/json"].Schema.Ref; got != "#/components/schemas/testRequest" {
+		t.Errorf("request schema = %q", got)
+	}
+	if reply.Responses["201"] == nil || reply.Responses["429"] == nil || reply.Responses["default"] == nil {
+		t.Errorf("responses = %v, want 201, 429 and default", reply.Responses)
+	}
+
+	admin := doc.Paths["/api/v1/admin/files/{id}"]["get"]
+	if admin == nil || admin.OperationID != "getAdminFilesById" {
+		t.Fatalf("admin operation = %+v", admin)
+	}
+	if len(admin.Parameters) != 1 || admin.Parameters[0].Name != "id" || admin.Parameters[0].In != "path" {
+		t.Errorf("parameters = %+v", admin.Parameters)
+	}
+	if len(admin.Security) != 1 || admin.Security[0][schemeSession] == nil {
+		t.Errorf("superuser route security = %v", admin.Security)
+	}
+
+	if doc.Paths["/api/webhooks/stripe"]["post"] == nil {
+		t.Error("unversioned route not at its own path")
+	}
+	if _, ok := doc.Paths["/api/tus/{path}"]; ok {
+		t.Error("any-method route documented")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	g := &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+	if got := g.schema(reflect.TypeOf(testReply{})).Ref; got != "#/components/schemas/testReply" {
+		t.Fatalf("ref = %q", got)
+	}
+
+	reply := g.schemas["testReply"]
+	wantRequired := []string{"id", "text", "history", "created", "note"}
+	if !slices.Equal(reply.Required, wantRequired) {
+		t.Errorf("required = %v, want %v", reply.Required, wantRequired)
+	}
+	for _, name := range []string{"Ignored", "internal", "testEmbedded"} {
+		if _, ok := reply.Properties[name]; ok {
+			t.Errorf("property %s shouldn't be in the schema", name)
+		}
+	}
+
+	tests := map[string]Schema{
+		"id":      {Type: "string"},
+		"usage":   {Ref: "#/components/schemas/testUsage"},
+		"created": {Type: "string", Format: "date-time"},
+		"note":    {Type: "string", Nullable: true},
+		"raw":     {},
+		"parent":  {Ref: "#/components/schemas/testReply"},
+	}
+	for name, want := range tests {
+		got := reply.Properties[name]
+		if got == nil || got.Ref != want.Ref || got.Type != want.Type || got.Format != want.Format || got.Nullable != want.Nullable {
+			t.Errorf("%s = %+v, want %+v", name, got, want)
+		}
+	}
+	if history := reply.Properties["history"]; history.Type != "array" || history.Items.Ref != "#/components/schemas/testUsage" {
+		t.Errorf("history = %+v", history)
+	}
+	if labels := reply.Properties["labels"]; labels.Type != "object" || labels.AdditionalProperties.Type != "string" {
+		t.Errorf("labels = %+v", labels)
+	}
+}
@@ -0,0 +1,67 @@
+package openapi
+
+import (
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the OpenAPI document of every route in the registry, and Swagger UI in
+// development. Register it last: the document is built on first request from the routes
+// declared by then
+func RegisterRoutes(r *routes.Registry) {
+	var (
+		once sync.Once
+		spec *Document
+	)
+	document := func() *Document {
+		once.Do(func() { spec = Build(r.Routes(), routes.CurrentVersion) })
+		return spec
+	}
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/openapi.json", Unversioned: true, Handler: func(e *core.RequestEvent) error {
+			return SpecHandler(e, document())
+		}, Doc: &routes.Doc{Summary: "OpenAPI document of the custom endpoints"}},
+	)
+
+	// Browsable docs for trying requests against a local server
+	if os.Getenv("DEVELOPMENT") == "true" {
+		r.Add(routes.Route{Method: http.MethodGet, Path: "/api/docs", Unversioned: true, Handler: SwaggerUIHandler})
+	}
+}
+
+// SpecHandler serves the OpenAPI document, from which the desktop and web clients generate
+// their SDKs
+// GET /api/openapi.json
+func SpecHandler(e *core.RequestEvent, spec *Document) error {
+	return e.JSON(http.StatusOK, spec)
+}
+
+// swaggerUI loads Swagger UI from a CDN and points it at the spec
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Ramble AI API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// SwaggerUIHandler serves Swagger UI for the OpenAPI document (development only)
+// GET /api/docs
+func SwaggerUIHandler(e *core.RequestEvent) error {
+	return e.HTML(http.StatusOK, swaggerUI)
+}
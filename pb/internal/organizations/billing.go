@@ -0,0 +1,280 @@
+package organizations
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// ErrNoPool means the organization has no subscription attached, so its members can't charge it
+var ErrNoPool = errors.New("organization has no subscription attached")
+
+// ErrNoSeats means every seat of the organization's pool plan is taken
+var ErrNoSeats = errors.New("all seats of the organization's plan are taken")
+
+var yearMonthPattern = regexp.MustCompile(`^\d{4}-(0[1-9]|1[0-2])$`)
+
+// Pool is an organization's shared allowance: the subscription attached to it and the plan
+// whose hours_per_month all members draw from
+type Pool struct {
+	OrganizationID string
+	Subscription   *core.Record
+	Plan           *core.Record
+}
+
+// LimitHours is the pooled monthly allowance
+func (p *Pool) LimitHours() float64 {
+	return p.Plan.GetFloat("hours_per_month")
+}
+
+// Seats is how many members the pool's plan allows, 0 when it doesn't limit them. Only
+// custom enterprise plans set seat_count
+func (p *Pool) Seats() int {
+	return p.Plan.GetInt("seat_count")
+}
+
+// FindPool loads the subscription attached to an organization. A subscription that lost
+// access (canceled, or past_due beyond its grace window) doesn't count as a pool
+func FindPool(app core.App, orgID string) (*Pool, error) {
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "organization_id = {:org}", map[string]any{"org": orgID})
+	if err != nil {
+		return nil, ErrNoPool
+	}
+
+	now := time.Now()
+	if !subscription.AccessForSubscription(sub, now).Subscribed || subscription.IsSandboxPlan(sub) {
+		return nil, ErrNoPool
+	}
+	plan, err := app.FindRecordById("subscription_plans", subscription.EffectivePlanID(sub, now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan of organization %s: %w", orgID, err)
+	}
+	return &Pool{OrganizationID: orgID, Subscription: sub, Plan: plan}, nil
+}
+
+// ChargeablePool returns the pool a user may charge within an organization: they must
+// still be a member whose role consumes hours, and the organization must have a pool
+func ChargeablePool(app core.App, orgID, userID string) (*Pool, error) {
+	membership, err := FindMembership(app, orgID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user %s is not a member of organization %s", userID, orgID)
+	}
+	if !Role(membership.GetString("role")).Can(AbilityConsumeHours) {
+		return nil, fmt.Errorf("role %s can't consume organization hours", membership.GetString("role"))
+	}
+	return FindPool(app, orgID)
+}
+
+// checkSeat returns ErrNoSeats when the organization's pool has no seat left for another member.
+// Organizations without a pool aren't limited
+func checkSeat(app core.App, orgID string) error {
+	pool, err := FindPool(app, orgID)
+	if errors.Is(err, ErrNoPool) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pool.Seats() == 0 {
+		return nil
+	}
+	members, err := countMembers(app, orgID)
+	if err != nil {
+		return err
+	}
+	if members >= pool.Seats() {
+		return ErrNoSeats
+	}
+	return nil
+}
+
+func countMembers(app core.App, orgID string) (int, error) {
+	total, err := app.CountRecords("organization_members", dbx.HashExp{"organization_id": orgID})
+	return int(total), err
+}
+
+// PooledHoursUsed sums the monthly_usage of every member charged to an organization
+func PooledHoursUsed(app core.App, orgID, yearMonth string) float64 {
+	records, err := findPoolUsage(app, orgID, yearMonth)
+	if err != nil {
+		return 0
+	}
+	var total float64
+	for _, record := range records {
+		total += record.GetFloat("hours_used")
+	}
+	return total
+}
+
+func findPoolUsage(app core.App, orgID, yearMonth string) ([]*core.Record, error) {
+	return app.FindRecordsByFilter("monthly_usage", "organization_id = {:org} && year_month = {:month}", "", 0, 0, map[string]any{
+		"org":   orgID,
+		"month": yearMonth,
+	})
+}
+
+// MemberUsage is one member's share of an organization's pooled hours for a month
+type MemberUsage struct {
+	UserID         string  `json:"user_id"`
+	Email          string  `json:"email"`
+	Name           string  `json:"name"`
+	HoursUsed      float64 `json:"hours_used"`
+	FilesProcessed int     `json:"files_processed"`
+}
+
+// memberUsage groups pooled monthly_usage rows by member, heaviest users first
+func memberUsage(records []*core.Record) []MemberUsage {
+	byUser := map[string]*MemberUsage{}
+	for _, record := range records {
+		userID := record.GetString("user_id")
+		usage, ok := byUser[userID]
+		if !ok {
+			usage = &MemberUsage{UserID: userID}
+			byUser[userID] = usage
+		}
+		usage.HoursUsed += record.GetFloat("hours_used")
+		usage.FilesProcessed += record.GetInt("files_processed")
+	}
+
+	members := make([]MemberUsage, 0, len(byUser))
+	for _, usage := range byUser {
+		members = append(members, *usage)
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].HoursUsed != members[j].HoursUsed {
+			return members[i].HoursUsed > members[j].HoursUsed
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members
+}
+
+// GetUsageHandler reports an organization's pooled hours for a month, broken down per member
+// (requires AbilityViewMembers). ?month=YYYY-MM defaults to the current month
+func GetUsageHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+
+	month := e.Request.URL.Query().Get("month")
+	if month == "" {
+		month = time.Now().Format("2006-01")
+	}
+	if !yearMonthPattern.MatchString(month) {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "month must be in YYYY-MM format"})
+	}
+
+	records, err := findPoolUsage(app, orgID, month)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load usage"})
+	}
+
+	members := memberUsage(records)
+	var hoursUsed float64
+	for i := range members {
+		hoursUsed += members[i].HoursUsed
+		if user, err := app.FindRecordById("users", members[i].UserID); err == nil {
+			members[i].Email = user.GetString("email")
+			members[i].Name = user.GetString("name")
+		}
+	}
+
+	response := map[string]any{
+		"organization_id": orgID,
+		"period":          month,
+		"hours_used":      hoursUsed,
+		"members":         members,
+		"pooled":          false,
+	}
+	if pool, err := FindPool(app, orgID); err == nil {
+		response["pooled"] = true
+		response["plan"] = pool.Plan.GetString("name")
+		response["hours_limit"] = pool.LimitHours()
+		if seats := pool.Seats(); seats > 0 {
+			response["seats"] = seats
+		}
+	}
+
+	return e.JSON(http.StatusOK, response)
+}
+
+// AttachSubscriptionHandler makes the caller's own paid subscription the organization's pool
+// (requires AbilityManageBilling). Billing stays with the caller; members' org API keys draw from it
+func AttachSubscriptionHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": e.Auth.Id})
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "You don't have a subscription to attach"})
+	}
+	now := time.Now()
+	if !subscription.AccessForSubscription(sub, now).Subscribed {
+		return e.JSON(http.StatusConflict, map[string]string{"error": "Your subscription is not active"})
+	}
+	if subscription.IsSandboxPlan(sub) {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Sandbox plans can't be shared with an organization"})
+	}
+	plan, err := app.FindRecordById("subscription_plans", subscription.EffectivePlanID(sub, now))
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load plan"})
+	}
+	if plan.GetInt("price_cents") <= 0 {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Only paid plans can be shared with an organization"})
+	}
+
+	switch attached := sub.GetString("organization_id"); attached {
+	case orgID:
+		return e.JSON(http.StatusOK, sub)
+	case "":
+	default:
+		return e.JSON(http.StatusConflict, map[string]string{"error": "Your subscription is already attached to another organization"})
+	}
+	if _, err := FindPool(app, orgID); err == nil {
+		return e.JSON(http.StatusConflict, map[string]string{"error": "This organization already has a subscription attached"})
+	}
+	if seats := plan.GetInt("seat_count"); seats > 0 {
+		members, err := countMembers(app, orgID)
+		if err != nil {
+			return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to count members"})
+		}
+		if members > seats {
+			return e.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("Your plan has %d seats but the organization has %d members", seats, members)})
+		}
+	}
+
+	sub.Set("organization_id", orgID)
+	if err := app.Save(sub); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to attach subscription"})
+	}
+
+	log.Printf("[ORG] User %s attached subscription %s (%s) to organization %s", e.Auth.Id, sub.Id, plan.GetString("name"), orgID)
+
+	return e.JSON(http.StatusOK, sub)
+}
+
+// DetachSubscriptionHandler stops pooling an organization's hours (requires AbilityManageBilling)
+// Members' org API keys fall back to their own allowance afterwards
+func DetachSubscriptionHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "organization_id = {:org}", map[string]any{"org": orgID})
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "No subscription is attached to this organization"})
+	}
+
+	sub.Set("organization_id", "")
+	if err := app.Save(sub); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to detach subscription"})
+	}
+
+	log.Printf("[ORG] User %s detached subscription %s from organization %s", e.Auth.Id, sub.Id, orgID)
+
+	return e.JSON(http.StatusOK, map[string]string{"message": "Subscription detached"})
+}
@@ -0,0 +1,47 @@
+package organizations
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestMemberUsage(t *testing.T) {
+	collection := core.NewBaseCollection("monthly_usage")
+	row := func(userID string, hours float64, files int) *core.Record {
+		record := core.NewRecord(collection)
+		record.Set("user_id", userID)
+		record.Set("hours_used", hours)
+		record.Set("files_processed", files)
+		return record
+	}
+
+	members := memberUsage([]*core.Record{
+		row("alice", 1.5, 3),
+		row("bob", 4, 2),
+		row("alice", 0.5, 1),
+		row("carol", 2, 1),
+	})
+
+	want := []MemberUsage{
+		{UserID: "bob", HoursUsed: 4, FilesProcessed: 2},
+		{UserID: "alice", HoursUsed: 2, FilesProcessed: 4},
+		{UserID: "carol", HoursUsed: 2, FilesProcessed: 1},
+	}
+	if len(members) != len(want) {
+		t.Fatalf("Expected %d members, got %d", len(want), len(members))
+	}
+	for i := range want {
+		if members[i] != want[i] {
+			t.Errorf("Expected member %d to be %+v, got %+v", i, want[i], members[i])
+		}
+	}
+}
+
+func TestYearMonthPattern(t *testing.T) {
+	for month, valid := range map[string]bool{"2026-01": true, "2026-12": true, "2026-13": false, "2026-1": false, "": false} {
+		if got := yearMonthPattern.MatchString(month); got != valid {
+			t.Errorf("Expected %q valid=%v, got %v", month, valid, got)
+		}
+	}
+}
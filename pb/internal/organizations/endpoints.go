@@ -0,0 +1,161 @@
+package organizations
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// CreateOrganizationHandler creates an organization with the authenticated user as its owner
+func CreateOrganizationHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+
+	var org *core.Record
+	err := app.RunInTransaction(func(txApp core.App) error {
+		orgCollection, err := txApp.FindCollectionByNameOrId("organizations")
+		if err != nil {
+			return err
+		}
+		org = core.NewRecord(orgCollection)
+		org.Set("name", req.Name)
+		org.Set("owner_id", user.Id)
+		if err := txApp.Save(org); err != nil {
+			return err
+		}
+
+		return addMember(txApp, org.Id, user.Id, RoleOwner)
+	})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create organization: %v", err)})
+	}
+
+	log.Printf("[ORG] User %s created organization %s (%s)", user.Id, org.Id, req.Name)
+
+	return e.JSON(http.StatusCreated, org)
+}
+
+// ListMembersHandler lists the members of an organization (requires AbilityViewMembers)
+func ListMembersHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+
+	records, err := app.FindRecordsByFilter("organization_members", "organization_id = {:org}", "+created", 0, 0, map[string]any{
+		"org": orgID,
+	})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list members"})
+	}
+
+	members := make([]MemberInfo, 0, len(records))
+	for _, record := range records {
+		info := MemberInfo{
+			UserID:   record.GetString("user_id"),
+			Role:     Role(record.GetString("role")),
+			JoinedAt: record.GetDateTime("created").Time(),
+		}
+		if user, err := app.FindRecordById("users", info.UserID); err == nil {
+			info.Email = user.GetString("email")
+			info.Name = user.GetString("name")
+		}
+		members = append(members, info)
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{"members": members})
+}
+
+// UpdateMemberRoleHandler changes a member's role (requires AbilityManageRoles)
+func UpdateMemberRoleHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+	targetUserID := e.Request.PathValue("userId")
+
+	var req struct {
+		Role Role `json:"role"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Role != RoleAdmin && req.Role != RoleMember {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "role must be 'admin' or 'member'"})
+	}
+	if targetUserID == e.Auth.Id {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "You cannot change your own role"})
+	}
+
+	membership, err := FindMembership(app, orgID, targetUserID)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+	}
+	if Role(membership.GetString("role")) == RoleOwner {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "The owner's role cannot be changed"})
+	}
+
+	membership.Set("role", string(req.Role))
+	if err := app.Save(membership); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update role"})
+	}
+
+	return e.JSON(http.StatusOK, membership)
+}
+
+// RemoveMemberHandler removes a member from an organization (requires AbilityViewMembers)
+// Any member may remove themselves; removing others requires AbilityManageMembers and a higher role
+func RemoveMemberHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+	targetUserID := e.Request.PathValue("userId")
+	actor := MembershipFromEvent(e)
+	actorRole := Role(actor.GetString("role"))
+
+	membership, err := FindMembership(app, orgID, targetUserID)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Member not found"})
+	}
+	targetRole := Role(membership.GetString("role"))
+
+	if targetRole == RoleOwner {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "The owner cannot be removed from the organization"})
+	}
+
+	if targetUserID != e.Auth.Id {
+		if !actorRole.Can(AbilityManageMembers) || !actorRole.Outranks(targetRole) {
+			return e.JSON(http.StatusForbidden, map[string]string{"error": "Your role does not allow removing this member"})
+		}
+	}
+
+	if err := app.Delete(membership); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove member"})
+	}
+
+	log.Printf("[ORG] User %s removed from organization %s by %s", targetUserID, orgID, e.Auth.Id)
+
+	return e.JSON(http.StatusOK, map[string]string{"message": "Member removed"})
+}
+
+// addMember creates a membership record for a user
+func addMember(app core.App, orgID, userID string, role Role) error {
+	collection, err := app.FindCollectionByNameOrId("organization_members")
+	if err != nil {
+		return err
+	}
+
+	member := core.NewRecord(collection)
+	member.Set("organization_id", orgID)
+	member.Set("user_id", userID)
+	member.Set("role", string(role))
+	return app.Save(member)
+}
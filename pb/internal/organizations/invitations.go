@@ -0,0 +1,246 @@
+package organizations
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocketbase/internal/otp"
+)
+
+// CreateInvitationHandler invites someone by email to join an organization (requires AbilityManageMembers)
+func CreateInvitationHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+	actorRole := Role(MembershipFromEvent(e).GetString("role"))
+
+	var req struct {
+		Email string `json:"email"`
+		Role  Role   `json:"role"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Email == "" || !strings.Contains(req.Email, "@") {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "A valid email is required"})
+	}
+	if req.Role == "" {
+		req.Role = RoleMember
+	}
+	if req.Role != RoleAdmin && req.Role != RoleMember {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "role must be 'admin' or 'member'"})
+	}
+	if !actorRole.Outranks(req.Role) {
+		return e.JSON(http.StatusForbidden, map[string]string{"error": "Your role does not allow inviting with this role"})
+	}
+
+	org, err := app.FindRecordById("organizations", orgID)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Organization not found"})
+	}
+
+	// Pending invitations don't hold a seat, acceptance checks again
+	if err := checkSeat(app, orgID); err != nil {
+		if errors.Is(err, ErrNoSeats) {
+			return e.JSON(http.StatusConflict, map[string]string{"error": "All seats of the organization's plan are taken"})
+		}
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to check seats"})
+	}
+
+	// Reject invitations for people who are already members
+	if user, err := app.FindAuthRecordByEmail("users", req.Email); err == nil {
+		if _, err := FindMembership(app, orgID, user.Id); err == nil {
+			return e.JSON(http.StatusConflict, map[string]string{"error": "This user is already a member"})
+		}
+	}
+
+	// Replace any pending invitation for the same email so only the latest link works
+	pending, _ := app.FindRecordsByFilter("organization_invitations", "organization_id = {:org} && email = {:email} && accepted_at = ''", "", 0, 0, map[string]any{
+		"org":   orgID,
+		"email": req.Email,
+	})
+	for _, invitation := range pending {
+		if err := app.Delete(invitation); err != nil {
+			log.Printf("[ORG] Failed to delete previous invitation %s: %v", invitation.Id, err)
+		}
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate invitation"})
+	}
+
+	collection, err := app.FindCollectionByNameOrId("organization_invitations")
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to find organization_invitations collection"})
+	}
+
+	invitation := core.NewRecord(collection)
+	invitation.Set("organization_id", orgID)
+	invitation.Set("email", req.Email)
+	invitation.Set("role", string(req.Role))
+	invitation.Set("token_hash", hashInvitationToken(token))
+	invitation.Set("expires_at", time.Now().Add(InvitationTTL))
+	invitation.Set("invited_by", e.Auth.Id)
+	if err := app.Save(invitation); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save invitation: %v", err)})
+	}
+
+	emailSent := true
+	subject, body := getInvitationEmailContent(app.Settings().Meta.AppName, org.GetString("name"), e.Auth.GetString("email"), token)
+	if err := otp.SendTrackedEmail(app, req.Email, subject, body, "organization_invitation", invitation.Id); err != nil {
+		log.Printf("[ORG] Failed to send invitation email to %s: %v", req.Email, err)
+		emailSent = false
+	}
+
+	return e.JSON(http.StatusCreated, map[string]interface{}{
+		"id":         invitation.Id,
+		"email":      req.Email,
+		"role":       req.Role,
+		"expires_at": invitation.GetDateTime("expires_at"),
+		"email_sent": emailSent,
+	})
+}
+
+// ListInvitationsHandler lists pending invitations of an organization (requires AbilityManageMembers)
+func ListInvitationsHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+
+	records, err := app.FindRecordsByFilter("organization_invitations", "organization_id = {:org} && accepted_at = '' && expires_at > {:now}", "-created", 0, 0, map[string]any{
+		"org": orgID,
+		"now": types.NowDateTime(),
+	})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list invitations"})
+	}
+
+	invitations := make([]map[string]interface{}, 0, len(records))
+	for _, record := range records {
+		invitations = append(invitations, map[string]interface{}{
+			"id":         record.Id,
+			"email":      record.GetString("email"),
+			"role":       record.GetString("role"),
+			"expires_at": record.GetDateTime("expires_at"),
+		})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{"invitations": invitations})
+}
+
+// RevokeInvitationHandler deletes a pending invitation (requires AbilityManageMembers)
+func RevokeInvitationHandler(e *core.RequestEvent, app core.App) error {
+	orgID := e.Request.PathValue("orgId")
+
+	invitation, err := app.FindRecordById("organization_invitations", e.Request.PathValue("id"))
+	if err != nil || invitation.GetString("organization_id") != orgID {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Invitation not found"})
+	}
+
+	if err := app.Delete(invitation); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to revoke invitation"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]string{"message": "Invitation revoked"})
+}
+
+// AcceptInvitationHandler adds the authenticated user to the organization of a valid invitation token
+func AcceptInvitationHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := e.BindBody(&req); err != nil || req.Token == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "token is required"})
+	}
+
+	invitation, err := app.FindFirstRecordByFilter("organization_invitations", "token_hash = {:hash}", map[string]any{
+		"hash": hashInvitationToken(req.Token),
+	})
+	if err != nil || !invitation.GetDateTime("accepted_at").IsZero() {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Invitation not found"})
+	}
+	if invitation.GetDateTime("expires_at").Time().Before(time.Now()) {
+		return e.JSON(http.StatusGone, map[string]string{"error": "Invitation has expired"})
+	}
+	if !strings.EqualFold(invitation.GetString("email"), user.GetString("email")) {
+		return e.JSON(http.StatusForbidden, map[string]string{"error": "This invitation was sent to a different email address"})
+	}
+
+	orgID := invitation.GetString("organization_id")
+	if _, err := FindMembership(app, orgID, user.Id); err == nil {
+		return e.JSON(http.StatusConflict, map[string]string{"error": "You are already a member of this organization"})
+	}
+
+	err = app.RunInTransaction(func(txApp core.App) error {
+		if err := checkSeat(txApp, orgID); err != nil {
+			return err
+		}
+		if err := addMember(txApp, orgID, user.Id, Role(invitation.GetString("role"))); err != nil {
+			return err
+		}
+		invitation.Set("accepted_at", time.Now())
+		return txApp.Save(invitation)
+	})
+	if errors.Is(err, ErrNoSeats) {
+		return e.JSON(http.StatusConflict, map[string]string{"error": "All seats of the organization's plan are taken"})
+	}
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to accept invitation: %v", err)})
+	}
+
+	log.Printf("[ORG] User %s joined organization %s as %s", user.Id, orgID, invitation.GetString("role"))
+
+	return e.JSON(http.StatusOK, map[string]string{
+		"organization_id": orgID,
+		"role":            invitation.GetString("role"),
+	})
+}
+
+// generateInvitationToken creates a random URL-safe invitation token
+func generateInvitationToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// hashInvitationToken hashes a token for storage - raw tokens are only ever sent by email
+func hashInvitationToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// getInvitationEmailContent returns subject and HTML body for invitation emails
+func getInvitationEmailContent(appName, orgName, inviterEmail, token string) (string, string) {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+	link := fmt.Sprintf("%s/invite?token=%s", frontendURL, token)
+
+	subject := fmt.Sprintf("You've been invited to join %s on %s", orgName, appName)
+	body := fmt.Sprintf(`
+		<h2>You've been invited to %s</h2>
+		<p>%s has invited you to join <strong>%s</strong> on %s.</p>
+		<p><a href="%s">Accept invitation</a></p>
+		<p>This invitation expires in 7 days. If you weren't expecting it, please ignore this email.</p>
+		`, html.EscapeString(orgName), html.EscapeString(inviterEmail), html.EscapeString(orgName), html.EscapeString(appName), link)
+
+	return subject, body
+}
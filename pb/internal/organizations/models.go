@@ -0,0 +1,76 @@
+package organizations
+
+import "time"
+
+// Role represents a member's role within an organization
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+// Ability represents an action a member may perform within an organization
+type Ability string
+
+const (
+	AbilityConsumeHours  Ability = "consume_hours"
+	AbilityViewMembers   Ability = "view_members"
+	AbilityManageMembers Ability = "manage_members"
+	AbilityManageBilling Ability = "manage_billing"
+	AbilityManageRoles   Ability = "manage_roles"
+)
+
+// roleAbilities defines what each role is allowed to do
+// Members only consume hours; admins manage people and billing; owners can also change roles
+var roleAbilities = map[Role][]Ability{
+	RoleOwner:  {AbilityConsumeHours, AbilityViewMembers, AbilityManageMembers, AbilityManageBilling, AbilityManageRoles},
+	RoleAdmin:  {AbilityConsumeHours, AbilityViewMembers, AbilityManageMembers, AbilityManageBilling},
+	RoleMember: {AbilityConsumeHours, AbilityViewMembers},
+}
+
+// InvitationTTL is how long an invitation token stays valid
+const InvitationTTL = 7 * 24 * time.Hour
+
+// IsValid reports whether the role is a known organization role
+func (r Role) IsValid() bool {
+	_, ok := roleAbilities[r]
+	return ok
+}
+
+// Can reports whether the role grants the given ability
+func (r Role) Can(ability Ability) bool {
+	for _, a := range roleAbilities[r] {
+		if a == ability {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether the role may manage a member holding the other role
+func (r Role) Outranks(other Role) bool {
+	return rank(r) > rank(other)
+}
+
+func rank(r Role) int {
+	switch r {
+	case RoleOwner:
+		return 3
+	case RoleAdmin:
+		return 2
+	case RoleMember:
+		return 1
+	}
+	return 0
+}
+
+// MemberInfo represents an organization member in API responses
+type MemberInfo struct {
+	UserID   string    `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	Role     Role      `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
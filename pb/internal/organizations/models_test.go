@@ -0,0 +1,40 @@
+package organizations
+
+import "testing"
+
+func TestRoleAbilities(t *testing.T) {
+	testCases := []struct {
+		role    Role
+		ability Ability
+		allowed bool
+	}{
+		{RoleOwner, AbilityManageRoles, true},
+		{RoleOwner, AbilityManageBilling, true},
+		{RoleAdmin, AbilityManageBilling, true},
+		{RoleAdmin, AbilityManageMembers, true},
+		{RoleAdmin, AbilityManageRoles, false},
+		{RoleMember, AbilityConsumeHours, true},
+		{RoleMember, AbilityViewMembers, true},
+		{RoleMember, AbilityManageBilling, false},
+		{RoleMember, AbilityManageMembers, false},
+		{Role("guest"), AbilityConsumeHours, false},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.role.Can(tc.ability); got != tc.allowed {
+			t.Errorf("Expected %s.Can(%s) to be %v, got %v", tc.role, tc.ability, tc.allowed, got)
+		}
+	}
+}
+
+func TestRoleOutranks(t *testing.T) {
+	if !RoleOwner.Outranks(RoleAdmin) || !RoleAdmin.Outranks(RoleMember) {
+		t.Error("Expected owner > admin > member")
+	}
+	if RoleAdmin.Outranks(RoleAdmin) {
+		t.Error("Expected admins not to outrank other admins")
+	}
+	if RoleMember.Outranks(RoleAdmin) {
+		t.Error("Expected members not to outrank admins")
+	}
+}
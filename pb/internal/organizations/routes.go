@@ -0,0 +1,50 @@
+package organizations
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the organization routes - member abilities are enforced per role by RequireAbility
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/orgs", Handler: func(e *core.RequestEvent) error {
+			return CreateOrganizationHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/orgs/invitations/accept", Handler: func(e *core.RequestEvent) error {
+			return AcceptInvitationHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodGet, Path: "/api/orgs/{orgId}/members", Handler: func(e *core.RequestEvent) error {
+			return ListMembersHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityViewMembers)}},
+		routes.Route{Method: http.MethodPatch, Path: "/api/orgs/{orgId}/members/{userId}", Handler: func(e *core.RequestEvent) error {
+			return UpdateMemberRoleHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityManageRoles)}},
+		routes.Route{Method: http.MethodDelete, Path: "/api/orgs/{orgId}/members/{userId}", Handler: func(e *core.RequestEvent) error {
+			return RemoveMemberHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityViewMembers)}},
+		routes.Route{Method: http.MethodGet, Path: "/api/orgs/{orgId}/usage", Handler: func(e *core.RequestEvent) error {
+			return GetUsageHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityViewMembers)}},
+		routes.Route{Method: http.MethodPut, Path: "/api/orgs/{orgId}/subscription", Handler: func(e *core.RequestEvent) error {
+			return AttachSubscriptionHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityManageBilling)}},
+		routes.Route{Method: http.MethodDelete, Path: "/api/orgs/{orgId}/subscription", Handler: func(e *core.RequestEvent) error {
+			return DetachSubscriptionHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityManageBilling)}},
+		routes.Route{Method: http.MethodGet, Path: "/api/orgs/{orgId}/invitations", Handler: func(e *core.RequestEvent) error {
+			return ListInvitationsHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityManageMembers)}},
+		routes.Route{Method: http.MethodPost, Path: "/api/orgs/{orgId}/invitations", Handler: func(e *core.RequestEvent) error {
+			return CreateInvitationHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityManageMembers)}},
+		routes.Route{Method: http.MethodDelete, Path: "/api/orgs/{orgId}/invitations/{id}", Handler: func(e *core.RequestEvent) error {
+			return RevokeInvitationHandler(e, app)
+		}, Middlewares: []routes.Middleware{RequireAbility(app, AbilityManageMembers)}},
+	)
+}
@@ -0,0 +1,51 @@
+package organizations
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the organization handlers read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "organizations",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "organizations",
+				Fields: []schemacheck.Field{
+					{Name: "name", Type: "text"},
+					{Name: "owner_id", Type: "relation"},
+				},
+			},
+			{
+				Collection: "organization_members",
+				Fields: []schemacheck.Field{
+					{Name: "organization_id", Type: "relation"},
+					{Name: "user_id", Type: "relation"},
+					{Name: "role", Type: "select", Values: []string{"owner", "admin", "member"}},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_organization_members_org_user", Unique: true, Columns: "organization_id, user_id"},
+				},
+			},
+			{
+				Collection: "organization_invitations",
+				Fields: []schemacheck.Field{
+					{Name: "organization_id", Type: "relation"},
+					{Name: "email", Type: "text"},
+					{Name: "role", Type: "select", Values: []string{"admin", "member"}},
+					{Name: "token_hash", Type: "text"},
+					{Name: "expires_at", Type: "date"},
+					{Name: "invited_by", Type: "relation"},
+					{Name: "accepted_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_organization_invitations_token", Unique: true, Columns: "token_hash"},
+				},
+			},
+			{
+				Collection: "current_user_subscriptions",
+				Fields: []schemacheck.Field{
+					{Name: "organization_id", Type: "relation"},
+				},
+			},
+		},
+	}
+}
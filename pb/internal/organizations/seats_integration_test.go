@@ -0,0 +1,101 @@
+//go:build integration
+
+package organizations_test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	"pocketbase/internal/organizations"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+var invitationToken = regexp.MustCompile(`token=([0-9a-f]{64})`)
+
+// newApp returns an app whose invitation emails land in its test mailer
+func newApp(t *testing.T) *tests.TestApp {
+	t.Helper()
+	t.Setenv("EMAIL_PROVIDERS", "smtp")
+	app := testharness.New(t)
+	app.Settings().SMTP.Enabled = true
+	return app
+}
+
+// ownerWithSeats subscribes a new user to a plan limited to seats members
+func ownerWithSeats(t *testing.T, app core.App, seats int) *core.Record {
+	t.Helper()
+	plan := testharness.SeedPlans(t, app)["pro"]
+	plan.Set("seat_count", seats)
+	if err := app.Save(plan); err != nil {
+		t.Fatal(err)
+	}
+	owner := testharness.CreateUser(t, app, "owner@test.com")
+	service := subscription.NewService(subscription.NewRepository(app))
+	if _, err := service.AssignPlan(owner.Id, plan.Id, time.Now().AddDate(0, 1, 0), "invoice"); err != nil {
+		t.Fatal(err)
+	}
+	return owner
+}
+
+// invite invites email and returns the token sent to them
+func invite(t *testing.T, app *tests.TestApp, server *testharness.Server, token, orgID, email string) string {
+	t.Helper()
+	server.Do(t, http.MethodPost, "/api/orgs/"+orgID+"/invitations", token, map[string]string{"email": email}).Expect(t, http.StatusCreated)
+	match := invitationToken.FindStringSubmatch(app.TestMailer.LastMessage().HTML)
+	if match == nil {
+		t.Fatalf("no invitation token in %q", app.TestMailer.LastMessage().HTML)
+	}
+	return match[1]
+}
+
+func TestSeatsLimitMembers(t *testing.T) {
+	app := newApp(t)
+	owner := ownerWithSeats(t, app, 2)
+	ownerToken := testharness.AuthToken(t, owner)
+	server := testharness.Serve(t, app, organizations.RegisterRoutes)
+
+	org := server.Do(t, http.MethodPost, "/api/orgs", ownerToken, map[string]string{"name": "Acme"}).Expect(t, http.StatusCreated).JSON(t)
+	orgID, _ := org["id"].(string)
+	server.Do(t, http.MethodPut, "/api/orgs/"+orgID+"/subscription", ownerToken, nil).Expect(t, http.StatusOK)
+
+	// Invitations don't hold a seat, so both go out, but only the first acceptance gets one
+	first := invite(t, app, server, ownerToken, orgID, "first@test.com")
+	second := invite(t, app, server, ownerToken, orgID, "second@test.com")
+	accept := func(email, invitation string) testharness.Response {
+		user := testharness.CreateUser(t, app, email)
+		return server.Do(t, http.MethodPost, "/api/orgs/invitations/accept", testharness.AuthToken(t, user), map[string]string{"token": invitation})
+	}
+	accept("first@test.com", first).Expect(t, http.StatusOK)
+	accept("second@test.com", second).Expect(t, http.StatusConflict)
+
+	server.Do(t, http.MethodPost, "/api/orgs/"+orgID+"/invitations", ownerToken, map[string]string{"email": "third@test.com"}).Expect(t, http.StatusConflict)
+	usage := server.Do(t, http.MethodGet, "/api/orgs/"+orgID+"/usage", ownerToken, nil).Expect(t, http.StatusOK).JSON(t)
+	if usage["seats"] != 2.0 {
+		t.Errorf("seats = %v, want 2", usage["seats"])
+	}
+
+	// Without the pool the organization isn't limited
+	server.Do(t, http.MethodDelete, "/api/orgs/"+orgID+"/subscription", ownerToken, nil).Expect(t, http.StatusOK)
+	server.Do(t, http.MethodPost, "/api/orgs/"+orgID+"/invitations", ownerToken, map[string]string{"email": "third@test.com"}).Expect(t, http.StatusCreated)
+}
+
+func TestAttachRejectsPlanWithTooFewSeats(t *testing.T) {
+	app := newApp(t)
+	owner := ownerWithSeats(t, app, 1)
+	ownerToken := testharness.AuthToken(t, owner)
+	server := testharness.Serve(t, app, organizations.RegisterRoutes)
+
+	org := server.Do(t, http.MethodPost, "/api/orgs", ownerToken, map[string]string{"name": "Acme"}).Expect(t, http.StatusCreated).JSON(t)
+	orgID, _ := org["id"].(string)
+	member := testharness.CreateUser(t, app, "member@test.com")
+	server.Do(t, http.MethodPost, "/api/orgs/invitations/accept", testharness.AuthToken(t, member),
+		map[string]string{"token": invite(t, app, server, ownerToken, orgID, "member@test.com")}).Expect(t, http.StatusOK)
+
+	server.Do(t, http.MethodPut, "/api/orgs/"+orgID+"/subscription", ownerToken, nil).Expect(t, http.StatusConflict)
+}
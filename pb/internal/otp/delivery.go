@@ -0,0 +1,289 @@
+package otp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/communications"
+)
+
+// Delivery channel names, used in the send-otp channel field and the users.otp_channel preference
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// PurposePhoneVerification confirms a phone number before codes can be sent to it by SMS
+const PurposePhoneVerification = "phone_verification"
+
+// DeliveryChannel sends an OTP code to a recipient - an email address or an E.164 phone number
+type DeliveryChannel interface {
+	Name() string
+	Send(app core.App, recipient, otpCode, purpose string) error
+}
+
+// ChannelByName returns the delivery channel for a name; SMS needs Twilio to be configured
+func ChannelByName(name string) (DeliveryChannel, error) {
+	switch name {
+	case "", ChannelEmail:
+		return EmailChannel{}, nil
+	case ChannelSMS:
+		return NewTwilioSMSFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown OTP channel %q", name)
+	}
+}
+
+// EmailChannel delivers codes by email through the email provider chain
+type EmailChannel struct{}
+
+// Name implements DeliveryChannel
+func (EmailChannel) Name() string {
+	return ChannelEmail
+}
+
+// Send implements DeliveryChannel
+func (EmailChannel) Send(app core.App, recipient, otpCode, purpose string) error {
+	return SendOTPEmail(app, recipient, otpCode, purpose)
+}
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone strips the spaces, dashes, dots and parentheses people type into phone numbers
+// and checks the result is an E.164 number such as +14155550123
+func NormalizePhone(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+	if !e164Pattern.MatchString(normalized) {
+		return "", fmt.Errorf("phone must be in international E.164 format, e.g. +14155550123")
+	}
+	return normalized, nil
+}
+
+// DefaultTwilioEndpoint is Twilio's REST API base URL
+const DefaultTwilioEndpoint = "https://api.twilio.com/2010-04-01"
+
+// TwilioSMS delivers codes by text message through Twilio's Messages API
+type TwilioSMS struct {
+	AccountSID          string
+	AuthToken           string
+	From                string // sending number, used when MessagingServiceSID is empty
+	MessagingServiceSID string
+	Endpoint            string
+	HTTPClient          *http.Client
+}
+
+// NewTwilioSMSFromEnv creates a Twilio channel from TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and
+// either TWILIO_MESSAGING_SERVICE_SID or TWILIO_FROM_NUMBER
+func NewTwilioSMSFromEnv() (*TwilioSMS, error) {
+	sms := &TwilioSMS{
+		AccountSID:          os.Getenv("TWILIO_ACCOUNT_SID"),
+		AuthToken:           os.Getenv("TWILIO_AUTH_TOKEN"),
+		From:                os.Getenv("TWILIO_FROM_NUMBER"),
+		MessagingServiceSID: os.Getenv("TWILIO_MESSAGING_SERVICE_SID"),
+		Endpoint:            DefaultTwilioEndpoint,
+		HTTPClient:          &http.Client{Timeout: 30 * time.Second},
+	}
+	if sms.AccountSID == "" || sms.AuthToken == "" || (sms.From == "" && sms.MessagingServiceSID == "") {
+		return nil, fmt.Errorf("SMS delivery is not configured")
+	}
+	return sms, nil
+}
+
+// Name implements DeliveryChannel
+func (t *TwilioSMS) Name() string {
+	return ChannelSMS
+}
+
+// Send implements DeliveryChannel
+func (t *TwilioSMS) Send(app core.App, recipient, otpCode, purpose string) error {
+	body := getOTPSMSContent(otpCode, purpose)
+	err := t.sendMessage(recipient, body)
+
+	entry := communications.Entry{
+		Channel:   communications.ChannelSMS,
+		Kind:      "otp_" + purpose,
+		Recipient: recipient,
+		Subject:   "Verification code",
+		Status:    communications.StatusSent,
+	}
+	if err != nil {
+		entry.Status = communications.StatusFailed
+		entry.Details = map[string]interface{}{"error": err.Error()}
+	}
+	communications.Log(app, entry)
+
+	return err
+}
+
+func (t *TwilioSMS) sendMessage(to, body string) error {
+	form := url.Values{"To": {to}, "Body": {body}}
+	if t.MessagingServiceSID != "" {
+		form.Set("MessagingServiceSid", t.MessagingServiceSID)
+	} else {
+		form.Set("From", t.From)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", t.Endpoint, t.AccountSID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		log.Printf("[SMS] Twilio request failed: %v", err)
+		return fmt.Errorf("failed to send SMS via Twilio: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		log.Printf("[SMS] Twilio API error - Status: %d, Body: %s", resp.StatusCode, respBody)
+		return fmt.Errorf("Twilio API returned status %d", resp.StatusCode)
+	}
+
+	log.Printf("[SMS] Twilio message sent to %s", maskPhone(to))
+	return nil
+}
+
+// getOTPSMSContent returns the text message for an OTP
+func getOTPSMSContent(otpCode, purpose string) string {
+	action := "verification"
+	switch purpose {
+	case "signup_verification":
+		action = "sign-up"
+	case "email_change":
+		action = "email change"
+	case "password_reset":
+		action = "password reset"
+	case PurposePhoneVerification:
+		action = "phone verification"
+	}
+	return fmt.Sprintf("Your Pulse %s code is %s. It expires in 10 minutes.", action, otpCode)
+}
+
+// maskPhone keeps the last four digits of a phone number for logs
+func maskPhone(phone string) string {
+	if len(phone) <= 4 {
+		return phone
+	}
+	return strings.Repeat("*", len(phone)-4) + phone[len(phone)-4:]
+}
+
+// sendRequest is the send-otp body. Channel is optional: without it the user's otp_channel
+// preference is used, then email. Phone is only read for phone_verification
+type sendRequest struct {
+	Email   string `json:"email" form:"email"`
+	Phone   string `json:"phone" form:"phone"`
+	UserID  string `json:"user_id" form:"user_id"`
+	Purpose string `json:"purpose" form:"purpose"`
+	Channel string `json:"channel" form:"channel"`
+}
+
+// resolveDelivery picks the channel and recipient for a send-otp request. SMS codes only go to
+// the user's verified phone, except for phone_verification, which confirms a new number
+func resolveDelivery(user *core.Record, data sendRequest) (DeliveryChannel, string, error) {
+	if data.Purpose == PurposePhoneVerification {
+		phone, err := NormalizePhone(data.Phone)
+		if err != nil {
+			return nil, "", err
+		}
+		sms, err := NewTwilioSMSFromEnv()
+		if err != nil {
+			return nil, "", err
+		}
+		return sms, phone, nil
+	}
+
+	name := data.Channel
+	if name == "" {
+		name = user.GetString("otp_channel")
+	}
+
+	if name == ChannelSMS {
+		phone := user.GetString("phone")
+		sms, err := NewTwilioSMSFromEnv()
+		if err == nil && (!user.GetBool("phone_verified") || phone == "") {
+			err = fmt.Errorf("no verified phone number - verify one with the %s purpose first", PurposePhoneVerification)
+		}
+		if err == nil {
+			return sms, phone, nil
+		}
+		// A stored preference falls back to email; an explicit request doesn't
+		if data.Channel != "" || data.Email == "" {
+			return nil, "", err
+		}
+		name = ChannelEmail
+	}
+
+	channel, err := ChannelByName(name)
+	if err != nil {
+		return nil, "", err
+	}
+	if data.Email == "" {
+		return nil, "", fmt.Errorf("Missing required fields")
+	}
+	return channel, data.Email, nil
+}
+
+// maskRecipient hides most of a phone number in logs and responses; email addresses are shown as is
+func maskRecipient(channel DeliveryChannel, recipient string) string {
+	if channel.Name() == ChannelSMS {
+		return maskPhone(recipient)
+	}
+	return recipient
+}
+
+// SetChannelHandler sets the signed-in user's preferred OTP channel. SMS needs a verified phone
+// PUT /api/otp/channel
+func SetChannelHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil || user.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	data := struct {
+		Channel string `json:"channel"`
+	}{}
+	if err := e.BindBody(&data); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if _, err := ChannelByName(data.Channel); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if data.Channel == ChannelSMS && (!user.GetBool("phone_verified") || user.GetString("phone") == "") {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Verify a phone number before choosing SMS"})
+	}
+
+	user.Set("otp_channel", data.Channel)
+	if err := app.Save(user); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save OTP channel"})
+	}
+
+	response := map[string]any{"channel": data.Channel}
+	if phone := user.GetString("phone"); phone != "" {
+		response["phone"] = maskPhone(phone)
+	}
+	return e.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,95 @@
+package otp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	valid := map[string]string{
+		"+14155550123":       "+14155550123",
+		"+1 (415) 555-0123":  "+14155550123",
+		" +44 20 7946 0958 ": "+442079460958",
+	}
+	for input, want := range valid {
+		if got, err := NormalizePhone(input); err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	for _, input := range []string{"", "4155550123", "+0123456789", "+1415abc0123", "+1234567", "+1234567890123456"} {
+		if _, err := NormalizePhone(input); err == nil {
+			t.Errorf("NormalizePhone(%q) should fail", input)
+		}
+	}
+}
+
+func newTestUser(phone string, verified bool, preference string) *core.Record {
+	user := core.NewRecord(core.NewBaseCollection("users"))
+	user.Set("phone", phone)
+	user.Set("phone_verified", verified)
+	user.Set("otp_channel", preference)
+	return user
+}
+
+func TestResolveDelivery(t *testing.T) {
+	t.Setenv("TWILIO_ACCOUNT_SID", "AC123")
+	t.Setenv("TWILIO_AUTH_TOKEN", "token")
+	t.Setenv("TWILIO_FROM_NUMBER", "+15005550006")
+
+	tests := []struct {
+		name          string
+		user          *core.Record
+		data          sendRequest
+		wantChannel   string
+		wantRecipient string
+		wantErr       bool
+	}{
+		{"email by default", newTestUser("", false, ""), sendRequest{Email: "a@example.com"}, ChannelEmail, "a@example.com", false},
+		{"sms preference", newTestUser("+14155550123", true, ChannelSMS), sendRequest{Email: "a@example.com"}, ChannelSMS, "+14155550123", false},
+		{"unverified preference falls back", newTestUser("+14155550123", false, ChannelSMS), sendRequest{Email: "a@example.com"}, ChannelEmail, "a@example.com", false},
+		{"explicit sms needs a verified phone", newTestUser("+14155550123", false, ""), sendRequest{Email: "a@example.com", Channel: ChannelSMS}, "", "", true},
+		{"request overrides preference", newTestUser("+14155550123", true, ChannelSMS), sendRequest{Email: "a@example.com", Channel: ChannelEmail}, ChannelEmail, "a@example.com", false},
+		{"phone verification", newTestUser("", false, ""), sendRequest{Purpose: PurposePhoneVerification, Phone: "+1 415 555 0199"}, ChannelSMS, "+14155550199", false},
+		{"phone verification needs E.164", newTestUser("", false, ""), sendRequest{Purpose: PurposePhoneVerification, Phone: "555-0199"}, "", "", true},
+		{"email needs an address", newTestUser("", false, ""), sendRequest{}, "", "", true},
+	}
+	for _, tt := range tests {
+		channel, recipient, err := resolveDelivery(tt.user, tt.data)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+			continue
+		}
+		if err != nil || channel.Name() != tt.wantChannel || recipient != tt.wantRecipient {
+			t.Errorf("%s: got %v %q (%v), want %s %q", tt.name, channel, recipient, err, tt.wantChannel, tt.wantRecipient)
+		}
+	}
+}
+
+func TestTwilioSendMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Accounts/AC123/Messages.json" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "AC123" || pass != "token" {
+			t.Errorf("expected basic auth with the account SID")
+		}
+		if r.FormValue("To") != "+14155550123" || r.FormValue("MessagingServiceSid") != "MG123" || r.FormValue("From") != "" {
+			t.Errorf("unexpected form %v", r.PostForm)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sms := &TwilioSMS{AccountSID: "AC123", AuthToken: "token", MessagingServiceSID: "MG123", Endpoint: server.URL,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	if err := sms.sendMessage("+14155550123", getOTPSMSContent("123456", "password_reset")); err != nil {
+		t.Fatalf("sendMessage failed: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package otp
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Error codes returned by the OTP endpoints when a request is throttled
+const (
+	CodeRateLimited = "OTP_RATE_LIMITED"
+	CodeLocked      = "OTP_LOCKED"
+)
+
+// Limits protects the OTP endpoints from brute force and from being used to spam inboxes
+type Limits struct {
+	SendPerEmailPerHour int           // codes sent to one address
+	SendPerIPPerHour    int           // codes requested from one client IP
+	VerifyPerIPPerHour  int           // verification attempts from one client IP
+	ResendCooldown      time.Duration // minimum time between codes for the same user and purpose
+	MaxAttempts         int           // wrong codes before the user is locked out
+	Lockout             time.Duration
+}
+
+// LimitsFromEnv reads OTP_SEND_PER_EMAIL_PER_HOUR, OTP_SEND_PER_IP_PER_HOUR,
+// OTP_VERIFY_PER_IP_PER_HOUR, OTP_RESEND_COOLDOWN_SECONDS, OTP_MAX_ATTEMPTS and
+// OTP_LOCKOUT_MINUTES, falling back to defaults for unset or invalid values
+func LimitsFromEnv() Limits {
+	limits := Limits{
+		SendPerEmailPerHour: 5,
+		SendPerIPPerHour:    20,
+		VerifyPerIPPerHour:  60,
+		ResendCooldown:      60 * time.Second,
+		MaxAttempts:         5,
+		Lockout:             15 * time.Minute,
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTP_SEND_PER_EMAIL_PER_HOUR")); err == nil && v > 0 {
+		limits.SendPerEmailPerHour = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTP_SEND_PER_IP_PER_HOUR")); err == nil && v > 0 {
+		limits.SendPerIPPerHour = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTP_VERIFY_PER_IP_PER_HOUR")); err == nil && v > 0 {
+		limits.VerifyPerIPPerHour = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTP_RESEND_COOLDOWN_SECONDS")); err == nil && v >= 0 {
+		limits.ResendCooldown = time.Duration(v) * time.Second
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTP_MAX_ATTEMPTS")); err == nil && v > 0 {
+		limits.MaxAttempts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("OTP_LOCKOUT_MINUTES")); err == nil && v > 0 {
+		limits.Lockout = time.Duration(v) * time.Minute
+	}
+	return limits
+}
+
+// window is a fixed one-hour counter
+type window struct {
+	count int
+	start time.Time
+}
+
+// hourlyLimiter counts requests per key in fixed one-hour windows
+type hourlyLimiter struct {
+	mu        sync.Mutex
+	windows   map[string]*window
+	lastPrune time.Time
+}
+
+func newHourlyLimiter() *hourlyLimiter {
+	return &hourlyLimiter{windows: map[string]*window{}}
+}
+
+// take counts a request against key, returning how long to wait once limit is reached
+func (l *hourlyLimiter) take(key string, limit int, now time.Time) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > time.Hour {
+		for k, w := range l.windows {
+			if now.Sub(w.start) >= time.Hour {
+				delete(l.windows, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	w, ok := l.windows[key]
+	if !ok || now.Sub(w.start) >= time.Hour {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= limit {
+		return false, w.start.Add(time.Hour).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+var (
+	limitsOnce    sync.Once
+	sharedLimits  Limits
+	sharedLimiter *hourlyLimiter
+)
+
+// limiter returns the process-wide limits and counters
+func limiter() (Limits, *hourlyLimiter) {
+	limitsOnce.Do(func() {
+		sharedLimits = LimitsFromEnv()
+		sharedLimiter = newHourlyLimiter()
+	})
+	return sharedLimits, sharedLimiter
+}
+
+// throttled answers 429 with Retry-After. The message key is what the OTP form displays
+func throttled(e *core.RequestEvent, code, message string, wait time.Duration) error {
+	retryAfter := max(int(math.Ceil(wait.Seconds())), 1)
+	e.Response.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return e.JSON(http.StatusTooManyRequests, map[string]any{
+		"message":             message,
+		"code":                code,
+		"retry_after_seconds": retryAfter,
+	})
+}
+
+// waitMessage describes a wait in minutes, or seconds when it's under a minute
+func waitMessage(wait time.Duration) string {
+	amount, unit := max(int(math.Ceil(wait.Seconds())), 1), "second"
+	if wait >= time.Minute {
+		amount, unit = int(math.Ceil(wait.Minutes())), "minute"
+	}
+	if amount != 1 {
+		unit += "s"
+	}
+	return fmt.Sprintf("%d %s", amount, unit)
+}
+
+// lockedUntil returns when the user's lockout for a purpose ends, or the zero time
+func lockedUntil(app core.App, userID, purpose string, now time.Time) time.Time {
+	record, err := app.FindFirstRecordByFilter("user_otps",
+		"user_id = {:user} && purpose = {:purpose} && locked_until > {:now}",
+		map[string]any{"user": userID, "purpose": purpose, "now": now.UTC().Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		return time.Time{}
+	}
+	return record.GetDateTime("locked_until").Time()
+}
+
+// resendWait returns how long until another code may be sent for the user and purpose
+func resendWait(app core.App, userID, purpose string, cooldown time.Duration, now time.Time) time.Duration {
+	if cooldown <= 0 {
+		return 0
+	}
+	records, err := app.FindRecordsByFilter("user_otps", "user_id = {:user} && purpose = {:purpose}", "-created", 1, 0,
+		map[string]any{"user": userID, "purpose": purpose})
+	if err != nil || len(records) == 0 {
+		return 0
+	}
+	if wait := records[0].GetDateTime("created").Time().Add(cooldown).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,38 @@
+//go:build integration
+
+package otp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"pocketbase/internal/otp"
+	"pocketbase/internal/testharness"
+)
+
+func TestSpoofedForwardedForDoesNotResetIPLimit(t *testing.T) {
+	// Read once per process, so it must be set before the first OTP request
+	t.Setenv("OTP_VERIFY_PER_IP_PER_HOUR", "2")
+	app := testharness.New(t)
+	server := testharness.Serve(t, app, otp.RegisterRoutes)
+
+	verify := func(forwardedFor string) testharness.Response {
+		req := testharness.NewRequest(t, http.MethodPost, "/verify-otp", "", map[string]string{
+			"user_id":  "missing",
+			"otp_code": "000000",
+			"purpose":  "login",
+		})
+		req.RemoteAddr = "192.0.2.1:4321"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		return server.Send(req)
+	}
+
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2"} {
+		if response := verify(ip); response.Code == http.StatusTooManyRequests {
+			t.Fatalf("request from %s throttled before the limit", ip)
+		}
+	}
+	if response := verify("198.51.100.3"); response.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d with a rotated X-Forwarded-For, want 429", response.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package otp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHourlyLimiter(t *testing.T) {
+	limiter := newHourlyLimiter()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.take("send-email:a@example.com", 3, start.Add(time.Duration(i)*time.Minute)); !ok {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+	}
+
+	ok, wait := limiter.take("send-email:a@example.com", 3, start.Add(10*time.Minute))
+	if ok || wait != 50*time.Minute {
+		t.Errorf("fourth request: ok=%v wait=%v, want blocked for 50m", ok, wait)
+	}
+	if ok, _ := limiter.take("send-email:b@example.com", 3, start.Add(10*time.Minute)); !ok {
+		t.Error("other keys should have their own window")
+	}
+	if ok, _ := limiter.take("send-email:a@example.com", 3, start.Add(time.Hour)); !ok {
+		t.Error("the window should reset after an hour")
+	}
+}
+
+func TestLimitsFromEnv(t *testing.T) {
+	t.Setenv("OTP_MAX_ATTEMPTS", "3")
+	t.Setenv("OTP_LOCKOUT_MINUTES", "30")
+	t.Setenv("OTP_RESEND_COOLDOWN_SECONDS", "0")
+	t.Setenv("OTP_SEND_PER_EMAIL_PER_HOUR", "-1")
+
+	limits := LimitsFromEnv()
+	if limits.MaxAttempts != 3 || limits.Lockout != 30*time.Minute || limits.ResendCooldown != 0 {
+		t.Errorf("unexpected limits %+v", limits)
+	}
+	if limits.SendPerEmailPerHour != 5 {
+		t.Errorf("invalid values should keep the default, got %d", limits.SendPerEmailPerHour)
+	}
+}
+
+func TestWaitMessage(t *testing.T) {
+	tests := map[time.Duration]string{
+		500 * time.Millisecond: "1 second",
+		45 * time.Second:       "45 seconds",
+		90 * time.Second:       "2 minutes",
+	}
+	for wait, want := range tests {
+		if got := waitMessage(wait); got != want {
+			t.Errorf("waitMessage(%v) = %q, want %q", wait, got, want)
+		}
+	}
+}
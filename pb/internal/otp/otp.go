@@ -1,22 +1,22 @@
 package otp
 
 import (
-	"bytes"
-	"context"
 	"crypto/rand"
-	"encoding/json"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
 	"net/http"
-	"net/mail"
-	"os"
+	"strings"
 	"time"
 
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
-	"github.com/pocketbase/pocketbase/tools/mailer"
 	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocketbase/internal/communications"
+	"pocketbase/internal/email"
 )
 
 // GenerateOTP generates a 6-digit OTP code
@@ -32,8 +32,9 @@ func GenerateOTP() (string, error) {
 	return fmt.Sprintf("%06d", n.Add(n, min).Int64()), nil
 }
 
-// CreateOTP creates and stores an OTP for a user
-func CreateOTP(app core.App, userID, email, purpose string) (string, error) {
+// CreateOTP creates and stores an OTP for a user, recording where it's delivered: an email
+// address, or a phone number for the SMS channel
+func CreateOTP(app core.App, userID, purpose, channel, recipient string) (string, error) {
 	// Generate OTP code
 	otpCode, err := GenerateOTP()
 	if err != nil {
@@ -49,13 +50,28 @@ func CreateOTP(app core.App, userID, email, purpose string) (string, error) {
 		return "", err
 	}
 
+	// Only the latest code is valid, so attempts can't be spread over several outstanding codes
+	previous, _ := app.FindRecordsByFilter(collection, "user_id = {:user} && purpose = {:purpose} && used = false", "", 0, 0,
+		map[string]any{"user": userID, "purpose": purpose})
+	for _, old := range previous {
+		old.Set("used", true)
+		if err := app.Save(old); err != nil {
+			return "", err
+		}
+	}
+
 	record := core.NewRecord(collection)
 	record.Set("user_id", userID)
 	record.Set("otp_code", otpCode)
 	record.Set("purpose", purpose)
 	record.Set("expires_at", expiresAt)
 	record.Set("used", false)
-	record.Set("email", email)
+	record.Set("channel", channel)
+	if channel == ChannelSMS {
+		record.Set("phone", recipient)
+	} else {
+		record.Set("email", recipient)
+	}
 
 	if err := app.Save(record); err != nil {
 		return "", err
@@ -64,25 +80,61 @@ func CreateOTP(app core.App, userID, email, purpose string) (string, error) {
 	return otpCode, nil
 }
 
-// VerifyOTP verifies an OTP code for a user
-func VerifyOTP(app core.App, userID, otpCode, purpose string) error {
-	// Find the OTP record
+// LockedError is returned by VerifyOTP once too many wrong codes were entered
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed attempts, locked until %s", e.Until.Format(time.RFC3339))
+}
+
+// VerifyOTP verifies an OTP code for a user and returns its record. Wrong codes count against
+// the outstanding code; after maxAttempts the user is locked out for the purpose and
+// *LockedError is returned
+func VerifyOTP(app core.App, userID, otpCode, purpose string, maxAttempts int, lockout time.Duration) (*core.Record, error) {
+	now := time.Now()
+	if until := lockedUntil(app, userID, purpose, now); !until.IsZero() {
+		return nil, &LockedError{Until: until}
+	}
+
+	// Find the outstanding OTP record
 	collection, err := app.FindCollectionByNameOrId("user_otps")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	record, err := app.FindFirstRecordByFilter(
+	records, err := app.FindRecordsByFilter(
 		collection,
-		"user_id = {:userId} && otp_code = {:otpCode} && purpose = {:purpose} && used = false",
+		"user_id = {:userId} && purpose = {:purpose} && used = false",
+		"-created", 1, 0,
 		map[string]any{
 			"userId":  userID,
-			"otpCode": otpCode,
 			"purpose": purpose,
 		},
 	)
-	if err != nil {
-		return fmt.Errorf("invalid or expired OTP")
+	if err != nil || len(records) == 0 {
+		return nil, fmt.Errorf("invalid or expired OTP")
+	}
+	record := records[0]
+
+	if subtle.ConstantTimeCompare([]byte(record.GetString("otp_code")), []byte(otpCode)) != 1 {
+		attempts := record.GetInt("attempts") + 1
+		record.Set("attempts", attempts)
+		var locked *LockedError
+		if attempts >= maxAttempts {
+			// The code is burnt as well - a new one is needed once the lockout ends
+			locked = &LockedError{Until: now.Add(lockout).UTC()}
+			record.Set("locked_until", locked.Until)
+			record.Set("used", true)
+		}
+		if err := app.Save(record); err != nil {
+			return nil, err
+		}
+		if locked != nil {
+			return nil, locked
+		}
+		return nil, fmt.Errorf("invalid or expired OTP")
 	}
 
 	// Check if OTP has expired
@@ -96,130 +148,58 @@ func VerifyOTP(app core.App, userID, otpCode, purpose string) error {
 	case time.Time:
 		expiresAt = v
 	default:
-		return fmt.Errorf("invalid expires_at field type")
+		return nil, fmt.Errorf("invalid expires_at field type")
 	}
 
 	if time.Now().After(expiresAt) {
-		return fmt.Errorf("OTP has expired")
+		return nil, fmt.Errorf("OTP has expired")
 	}
 
 	// Mark OTP as used
 	record.Set("used", true)
 	if err := app.Save(record); err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
-}
-
-// SendOTPEmail sends an OTP via email using appropriate method based on environment
-func SendOTPEmail(app core.App, email, otpCode, purpose string) error {
-	// isDevelopment := os.Getenv("DEVELOPMENT") == "true"
-
-	// if isDevelopment {
-	// 	// Development: Use PocketBase's built-in SMTP (Mailpit)
-	// 	return sendOTPEmailSMTP(app, email, otpCode, purpose)
-	// } else {
-	// Production: Use Resend HTTP API
-	return sendOTPEmailResend(app, email, otpCode, purpose)
-	// }
+	return record, nil
 }
 
-// sendOTPEmailSMTP sends OTP via SMTP (development with Mailpit)
-func sendOTPEmailSMTP(app core.App, email, otpCode, purpose string) error {
+// SendOTPEmail sends an OTP by email through the provider chain
+func SendOTPEmail(app core.App, address, otpCode, purpose string) error {
 	subject, body := getOTPEmailContent(otpCode, purpose)
 
-	message := &mailer.Message{
-		From: mail.Address{
-			Address: app.Settings().Meta.SenderAddress,
-			Name:    app.Settings().Meta.SenderName,
-		},
-		To:      []mail.Address{{Address: email}},
-		Subject: subject,
-		HTML:    body,
-	}
+	log.Printf("[OTP] Sending email to %s for purpose: %s", address, purpose)
 
-	log.Printf("[OTP] Sending email via SMTP to %s for purpose: %s", email, purpose)
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	done := make(chan error, 1)
-	go func() {
-		done <- app.NewMailClient().Send(message)
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			log.Printf("[OTP] SMTP email sending failed: %v", err)
-			return fmt.Errorf("failed to send email via SMTP: %v", err)
-		}
-		log.Printf("[OTP] SMTP email sent successfully to %s", email)
-		return nil
-	case <-ctx.Done():
-		log.Printf("[OTP] SMTP email sending timed out after 30 seconds for %s", email)
-		return fmt.Errorf("SMTP email sending timed out")
-	}
+	return SendTrackedEmail(app, address, subject, body, "otp_"+purpose, "")
 }
 
-// sendOTPEmailResend sends OTP via Resend HTTP API (production)
-func sendOTPEmailResend(app core.App, email, otpCode, purpose string) error {
-	resendAPIKey := os.Getenv("RESEND_API_KEY")
-	if resendAPIKey == "" {
-		return fmt.Errorf("RESEND_API_KEY not configured")
-	}
-
-	subject, body := getOTPEmailContent(otpCode, purpose)
-
-	// Resend API payload
-	payload := map[string]interface{}{
-		"from":    fmt.Sprintf("%s <%s>", app.Settings().Meta.SenderName, app.Settings().Meta.SenderAddress),
-		"to":      []string{email},
-		"subject": subject,
-		"html":    body,
-	}
-
-	payloadBytes, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal email payload: %v", err)
-	}
-
-	log.Printf("[OTP] Sending email via Resend API to %s for purpose: %s", email, purpose)
-
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// SendEmail sends a transactional HTML email through the provider chain
+func SendEmail(app core.App, address, subject, body string) error {
+	return SendTrackedEmail(app, address, subject, body, "transactional", "")
+}
 
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.resend.com/emails", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+// SendTrackedEmail sends an email through the provider chain (see the email package) and records
+// it in the recipient's communications timeline
+// kind identifies the email type (e.g. "otp_login") and reference the record that triggered it, if any
+func SendTrackedEmail(app core.App, address, subject, body, kind, reference string) error {
+	delivery, err := email.Send(app, email.Message{To: address, Subject: subject, HTML: body, Kind: kind, Reference: reference})
+
+	entry := communications.Entry{
+		Channel:   communications.ChannelEmail,
+		Kind:      kind,
+		Recipient: address,
+		Subject:   subject,
+		Status:    communications.StatusSent,
+		Reference: reference,
+		Details:   map[string]interface{}{"provider": delivery.Provider, "sent_email_id": delivery.RecordID},
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+resendAPIKey)
-
-	// Send request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("[OTP] Resend API request failed: %v", err)
-		return fmt.Errorf("failed to send email via Resend: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		// Read response body for debugging
-		var respBody bytes.Buffer
-		respBody.ReadFrom(resp.Body)
-		log.Printf("[OTP] Resend API error - Status: %d, Body: %s", resp.StatusCode, respBody.String())
-		return fmt.Errorf("Resend API returned status %d", resp.StatusCode)
+		entry.Status = communications.StatusFailed
+		entry.Details["error"] = err.Error()
 	}
+	communications.Log(app, entry)
 
-	log.Printf("[OTP] Resend email sent successfully to %s", email)
-	return nil
+	return err
 }
 
 // getOTPEmailContent returns subject and HTML body for OTP emails
@@ -280,74 +260,73 @@ func getOTPEmailContent(otpCode, purpose string) (string, string) {
 
 // SendOTPHandler handles OTP generation and sending
 func SendOTPHandler(e *core.RequestEvent, app core.App) error {
-	// Set CORS headers - restrict to your frontend domain in production
-	origin := os.Getenv("FRONTEND_URL")
-	if origin == "" {
-		origin = "*" // fallback for development
+	data := sendRequest{}
+	if err := e.BindBody(&data); err != nil {
+		return apis.NewBadRequestError("Invalid request data", err)
 	}
-	e.Response.Header().Set("Access-Control-Allow-Origin", origin)
-	e.Response.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	e.Response.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-	e.Response.Header().Set("Access-Control-Allow-Credentials", "true")
 
-	// Handle preflight OPTIONS requests
-	if e.Request.Method == "OPTIONS" {
-		e.Response.WriteHeader(204)
-		return nil
+	// Validate required fields
+	if data.UserID == "" || data.Purpose == "" {
+		return apis.NewBadRequestError("Missing required fields", nil)
 	}
 
-	data := struct {
-		Email   string `json:"email" form:"email"`
-		UserID  string `json:"user_id" form:"user_id"`
-		Purpose string `json:"purpose" form:"purpose"`
-	}{}
+	// Throttle by client IP and recipient first, so unknown users can't be used to probe or spam.
+	// RealIP only honours the trusted proxy headers, so rotating X-Forwarded-For doesn't get a new window
+	limits, counters := limiter()
+	now := time.Now()
+	ip := e.RealIP()
+	if ok, wait := counters.take("send-ip:"+ip, limits.SendPerIPPerHour, now); !ok {
+		log.Printf("[OTP] Send rate limit hit for IP %s", ip)
+		return throttled(e, CodeRateLimited, "Too many verification codes requested. Try again in "+waitMessage(wait), wait)
+	}
 
-	if err := e.BindBody(&data); err != nil {
-		return apis.NewBadRequestError("Invalid request data", err)
+	user, err := app.FindRecordById("users", data.UserID)
+	if err != nil {
+		return apis.NewBadRequestError("Invalid user", nil)
+	}
+	// Adding a phone number is only possible from the account itself
+	if data.Purpose == PurposePhoneVerification && (e.Auth == nil || e.Auth.Id != user.Id) {
+		return apis.NewUnauthorizedError("Sign in to verify a phone number", nil)
+	}
+	channel, recipient, err := resolveDelivery(user, data)
+	if err != nil {
+		return apis.NewBadRequestError(err.Error(), nil)
 	}
 
-	// Validate required fields
-	if data.Email == "" || data.UserID == "" || data.Purpose == "" {
-		return apis.NewBadRequestError("Missing required fields", nil)
+	if ok, wait := counters.take("send-"+channel.Name()+":"+strings.ToLower(recipient), limits.SendPerEmailPerHour, now); !ok {
+		log.Printf("[OTP] Send rate limit hit for %s", maskRecipient(channel, recipient))
+		return throttled(e, CodeRateLimited, "Too many verification codes sent to this address. Try again in "+waitMessage(wait), wait)
+	}
+	if until := lockedUntil(app, data.UserID, data.Purpose, now); !until.IsZero() {
+		return throttled(e, CodeLocked, "Too many incorrect codes. Try again in "+waitMessage(until.Sub(now)), until.Sub(now))
+	}
+	if wait := resendWait(app, data.UserID, data.Purpose, limits.ResendCooldown, now); wait > 0 {
+		return throttled(e, CodeRateLimited, "Please wait "+waitMessage(wait)+" before requesting another code", wait)
 	}
 
 	// Generate and store OTP
-	otpCode, err := CreateOTP(app, data.UserID, data.Email, data.Purpose)
+	otpCode, err := CreateOTP(app, data.UserID, data.Purpose, channel.Name(), recipient)
 	if err != nil {
 		return apis.NewInternalServerError("Failed to generate OTP", err)
 	}
 
-	// Send OTP via email
-	log.Printf("[OTP] Attempting to send OTP email to %s (UserID: %s, Purpose: %s)", data.Email, data.UserID, data.Purpose)
-	if err := SendOTPEmail(app, data.Email, otpCode, data.Purpose); err != nil {
-		log.Printf("[OTP] Failed to send OTP email to %s: %v", data.Email, err)
-		return apis.NewInternalServerError("Failed to send OTP email", err)
+	// Send OTP through the resolved channel
+	log.Printf("[OTP] Attempting to send OTP by %s to %s (UserID: %s, Purpose: %s)", channel.Name(), maskRecipient(channel, recipient), data.UserID, data.Purpose)
+	if err := channel.Send(app, recipient, otpCode, data.Purpose); err != nil {
+		log.Printf("[OTP] Failed to send OTP by %s to %s: %v", channel.Name(), maskRecipient(channel, recipient), err)
+		return apis.NewInternalServerError("Failed to send OTP "+channel.Name(), err)
 	}
-	log.Printf("[OTP] OTP email sent successfully to %s", data.Email)
+	log.Printf("[OTP] OTP sent successfully by %s to %s", channel.Name(), maskRecipient(channel, recipient))
 
 	return e.JSON(http.StatusOK, map[string]any{
-		"message": "OTP sent successfully",
+		"message":     "OTP sent successfully",
+		"channel":     channel.Name(),
+		"destination": maskRecipient(channel, recipient),
 	})
 }
 
 // VerifyOTPHandler handles OTP verification
 func VerifyOTPHandler(e *core.RequestEvent, app core.App) error {
-	// Set CORS headers - restrict to your frontend domain in production
-	origin := os.Getenv("FRONTEND_URL")
-	if origin == "" {
-		origin = "*" // fallback for development
-	}
-	e.Response.Header().Set("Access-Control-Allow-Origin", origin)
-	e.Response.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	e.Response.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-	e.Response.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	// Handle preflight OPTIONS requests
-	if e.Request.Method == "OPTIONS" {
-		e.Response.WriteHeader(204)
-		return nil
-	}
-
 	data := struct {
 		UserID  string `json:"user_id" form:"user_id"`
 		OTPCode string `json:"otp_code" form:"otp_code"`
@@ -363,8 +342,23 @@ func VerifyOTPHandler(e *core.RequestEvent, app core.App) error {
 		return apis.NewBadRequestError("Missing required fields", nil)
 	}
 
+	limits, counters := limiter()
+	now := time.Now()
+	ip := e.RealIP()
+	if ok, wait := counters.take("verify-ip:"+ip, limits.VerifyPerIPPerHour, now); !ok {
+		log.Printf("[OTP] Verify rate limit hit for IP %s", ip)
+		return throttled(e, CodeRateLimited, "Too many verification attempts. Try again in "+waitMessage(wait), wait)
+	}
+
 	// Verify OTP
-	if err := VerifyOTP(app, data.UserID, data.OTPCode, data.Purpose); err != nil {
+	record, err := VerifyOTP(app, data.UserID, data.OTPCode, data.Purpose, limits.MaxAttempts, limits.Lockout)
+	if err != nil {
+		var locked *LockedError
+		if errors.As(err, &locked) {
+			log.Printf("[OTP] User %s locked out of %s verification until %s", data.UserID, data.Purpose, locked.Until.Format(time.RFC3339))
+			wait := locked.Until.Sub(now)
+			return throttled(e, CodeLocked, "Too many incorrect codes. Request a new code in "+waitMessage(wait), wait)
+		}
 		return apis.NewBadRequestError("Invalid or expired OTP", err)
 	}
 
@@ -381,6 +375,20 @@ func VerifyOTPHandler(e *core.RequestEvent, app core.App) error {
 		}
 	}
 
+	// A verified phone number can receive codes by SMS from now on
+	if data.Purpose == PurposePhoneVerification {
+		userRecord, err := app.FindRecordById("users", data.UserID)
+		if err != nil {
+			return apis.NewInternalServerError("User not found", err)
+		}
+
+		userRecord.Set("phone", record.GetString("phone"))
+		userRecord.Set("phone_verified", true)
+		if err := app.Save(userRecord); err != nil {
+			return apis.NewInternalServerError("Failed to verify phone number", err)
+		}
+	}
+
 	return e.JSON(http.StatusOK, map[string]any{
 		"message": "OTP verified successfully",
 	})
@@ -0,0 +1,38 @@
+//go:build integration
+
+package otp_test
+
+import (
+	"net/http"
+	"testing"
+
+	"pocketbase/internal/testharness"
+)
+
+func TestUsersCannotVerifyTheirOwnPhone(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "phone@test.com")
+	token := testharness.AuthToken(t, user)
+	server := testharness.Serve(t, app)
+
+	for _, body := range []map[string]any{{"phone_verified": true}, {"phone": "+15555550100"}, {"name": "Renamed", "phone_verified": true}} {
+		if response := server.Do(t, http.MethodPatch, "/api/collections/users/records/"+user.Id, token, body); response.Code == http.StatusOK {
+			t.Errorf("PATCH %v = 200, want it rejected", body)
+		}
+	}
+	reloaded, err := app.FindRecordById("users", user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.GetBool("phone_verified") || reloaded.GetString("phone") != "" || reloaded.GetString("name") == "Renamed" {
+		t.Errorf("user changed their phone fields: phone %q, verified %v", reloaded.GetString("phone"), reloaded.GetBool("phone_verified"))
+	}
+
+	// Signing up with a verified phone isn't possible either
+	server.Do(t, http.MethodPost, "/api/collections/users/records", "", map[string]any{
+		"email": "signup@test.com", "password": "password123", "passwordConfirm": "password123", "phone_verified": true,
+	}).Expect(t, http.StatusBadRequest)
+
+	// Regular profile edits still go through
+	server.Do(t, http.MethodPatch, "/api/collections/users/records/"+user.Id, token, map[string]any{"name": "Renamed"}).Expect(t, http.StatusOK)
+}
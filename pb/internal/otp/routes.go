@@ -0,0 +1,24 @@
+package otp
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the OTP routes
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+	sendOTP := func(e *core.RequestEvent) error { return SendOTPHandler(e, app) }
+	verifyOTP := func(e *core.RequestEvent) error { return VerifyOTPHandler(e, app) }
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/send-otp", Handler: sendOTP, Doc: &routes.Doc{Summary: "Send a one-time code to a user"}},
+		routes.Route{Method: http.MethodPost, Path: "/verify-otp", Handler: verifyOTP, Doc: &routes.Doc{Summary: "Verify a one-time code"}},
+		routes.Route{Method: http.MethodPut, Path: "/api/otp/channel", Handler: func(e *core.RequestEvent) error {
+			return SetChannelHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Choose how one-time codes are delivered"}},
+	)
+}
@@ -0,0 +1,35 @@
+package otp
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the OTP handlers read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "otp",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "user_otps",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "otp_code", Type: "text"},
+					{Name: "purpose", Type: "select", Values: []string{"signup_verification", "email_change", "password_reset", PurposePhoneVerification}},
+					{Name: "expires_at", Type: "date"},
+					{Name: "used", Type: "bool"},
+					{Name: "email", Type: "text"},
+					{Name: "attempts", Type: "number"},
+					{Name: "locked_until", Type: "date"},
+					{Name: "channel", Type: "select", Values: []string{ChannelEmail, ChannelSMS}},
+					{Name: "phone", Type: "text"},
+				},
+			},
+			{
+				Collection: "users",
+				Fields: []schemacheck.Field{
+					{Name: "phone", Type: "text"},
+					{Name: "phone_verified", Type: "bool"},
+					{Name: "otp_channel", Type: "select", Values: []string{ChannelEmail, ChannelSMS}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,11 @@
+package payment
+
+import "time"
+
+// MonthStartAnchor returns the billing cycle anchor for plans aligned to the 1st of the month:
+// midnight UTC on the first day of the month after now. Stripe requires the anchor to be in the
+// future, so a checkout on the 1st is anchored to the following month.
+func MonthStartAnchor(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
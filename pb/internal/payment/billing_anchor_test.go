@@ -0,0 +1,25 @@
+package payment
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthStartAnchor(t *testing.T) {
+	testCases := []struct {
+		now      time.Time
+		expected time.Time
+	}{
+		{time.Date(2025, 3, 15, 10, 30, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2025, 12, 31, 23, 59, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 22:00 on Jan 31 in UTC-5 is already February 1st in UTC
+		{time.Date(2025, 1, 31, 22, 0, 0, 0, time.FixedZone("EST", -5*3600)), time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		if got := MonthStartAnchor(tc.now); !got.Equal(tc.expected) {
+			t.Errorf("Expected MonthStartAnchor(%s) to be %s, got %s", tc.now, tc.expected, got)
+		}
+	}
+}
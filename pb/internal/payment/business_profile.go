@@ -0,0 +1,211 @@
+package payment
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// euCountries lists the EU member states whose VAT IDs use Stripe's "eu_vat" type
+var euCountries = map[string]bool{
+	"AT": true, "BE": true, "BG": true, "CY": true, "CZ": true, "DE": true, "DK": true,
+	"EE": true, "ES": true, "FI": true, "FR": true, "GR": true, "HR": true, "HU": true,
+	"IE": true, "IT": true, "LT": true, "LU": true, "LV": true, "MT": true, "NL": true,
+	"PL": true, "PT": true, "RO": true, "SE": true, "SI": true, "SK": true,
+}
+
+// gstTaxIDTypes maps non-EU countries to their VAT/GST tax ID type
+var gstTaxIDTypes = map[string]string{
+	"GB": "gb_vat",
+	"CH": "ch_vat",
+	"NO": "no_vat",
+	"AU": "au_abn",
+	"NZ": "nz_gst",
+	"CA": "ca_gst_hst",
+	"SG": "sg_gst",
+	"IN": "in_gst",
+}
+
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// BusinessProfileRequest represents the business details submitted by a user
+type BusinessProfileRequest struct {
+	CompanyName  string `json:"company_name"`
+	VATID        string `json:"vat_id"`
+	AddressLine1 string `json:"address_line1"`
+	AddressLine2 string `json:"address_line2"`
+	City         string `json:"city"`
+	PostalCode   string `json:"postal_code"`
+	State        string `json:"state"`
+	Country      string `json:"country"`
+}
+
+// Normalize trims the request fields and upper-cases the country and VAT ID
+func (r *BusinessProfileRequest) Normalize() {
+	r.CompanyName = strings.TrimSpace(r.CompanyName)
+	r.VATID = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(r.VATID), " ", ""))
+	r.AddressLine1 = strings.TrimSpace(r.AddressLine1)
+	r.AddressLine2 = strings.TrimSpace(r.AddressLine2)
+	r.City = strings.TrimSpace(r.City)
+	r.PostalCode = strings.TrimSpace(r.PostalCode)
+	r.State = strings.TrimSpace(r.State)
+	r.Country = strings.ToUpper(strings.TrimSpace(r.Country))
+}
+
+// Validate checks the business profile and returns the provider tax ID type for the VAT ID
+func (r *BusinessProfileRequest) Validate() (string, error) {
+	if r.CompanyName == "" {
+		return "", fmt.Errorf("company_name is required")
+	}
+	if !countryCodePattern.MatchString(r.Country) {
+		return "", fmt.Errorf("country must be a 2-letter ISO country code")
+	}
+	if r.AddressLine1 == "" || r.City == "" || r.PostalCode == "" {
+		return "", fmt.Errorf("address_line1, city and postal_code are required")
+	}
+	if r.VATID == "" {
+		return "", nil
+	}
+
+	taxIDType := TaxIDTypeForCountry(r.Country)
+	if taxIDType == "" {
+		return "", fmt.Errorf("VAT/GST IDs are not supported for country %s", r.Country)
+	}
+	return taxIDType, nil
+}
+
+// TaxIDTypeForCountry returns the provider tax ID type used for a country, or "" if unsupported
+func TaxIDTypeForCountry(country string) string {
+	if euCountries[country] {
+		return "eu_vat"
+	}
+	return gstTaxIDTypes[country]
+}
+
+// GetBusinessProfileHandler returns the authenticated user's business profile
+func GetBusinessProfileHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	profile, err := app.FindFirstRecordByFilter("business_profiles", "user_id = {:user_id}", map[string]any{"user_id": user.Id})
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Business profile not found"})
+	}
+
+	return e.JSON(http.StatusOK, profile)
+}
+
+// UpdateBusinessProfileHandler creates or updates the authenticated user's business profile
+// and pushes it to the payment provider customer so invoices carry the company details
+func UpdateBusinessProfileHandler(e *core.RequestEvent, app core.App, paymentService *Service) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req BusinessProfileRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	req.Normalize()
+
+	taxIDType, err := req.Validate()
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	profile, err := app.FindFirstRecordByFilter("business_profiles", "user_id = {:user_id}", map[string]any{"user_id": user.Id})
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("business_profiles")
+		if err != nil {
+			return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to find business_profiles collection"})
+		}
+		profile = core.NewRecord(collection)
+		profile.Set("user_id", user.Id)
+	}
+
+	// Only re-register the tax ID with the provider when it actually changed
+	taxIDChanged := profile.GetString("vat_id") != req.VATID
+
+	profile.Set("company_name", req.CompanyName)
+	profile.Set("vat_id", req.VATID)
+	profile.Set("tax_id_type", taxIDType)
+	profile.Set("address_line1", req.AddressLine1)
+	profile.Set("address_line2", req.AddressLine2)
+	profile.Set("city", req.City)
+	profile.Set("postal_code", req.PostalCode)
+	profile.Set("state", req.State)
+	profile.Set("country", req.Country)
+
+	if err := app.Save(profile); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to save business profile: %v", err)})
+	}
+
+	// Customers that haven't checked out yet get the profile pushed when their customer is created
+	customers, err := app.FindRecordsByFilter("payment_customers", fmt.Sprintf("user_id = '%s'", user.Id), "", 1, 0)
+	if err == nil && len(customers) > 0 && paymentService != nil {
+		if err := SyncBusinessProfile(app, paymentService, profile, customers[0].GetString("provider_customer_id"), taxIDChanged); err != nil {
+			return e.JSON(http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Profile saved but failed to update billing details: %v", err)})
+		}
+	}
+
+	return e.JSON(http.StatusOK, profile)
+}
+
+// SyncBusinessProfile pushes a business profile record to the payment provider customer
+func SyncBusinessProfile(app core.App, paymentService *Service, profile *core.Record, customerID string, syncTaxID bool) error {
+	params := BusinessProfileParams{
+		CompanyName:  profile.GetString("company_name"),
+		AddressLine1: profile.GetString("address_line1"),
+		AddressLine2: profile.GetString("address_line2"),
+		City:         profile.GetString("city"),
+		PostalCode:   profile.GetString("postal_code"),
+		State:        profile.GetString("state"),
+		Country:      profile.GetString("country"),
+	}
+	if syncTaxID {
+		params.PreviousTaxID = profile.GetString("provider_tax_id")
+		params.TaxIDType = profile.GetString("tax_id_type")
+		params.TaxIDValue = profile.GetString("vat_id")
+	}
+
+	result, err := paymentService.UpdateCustomerBusinessProfile(customerID, params)
+	if err != nil {
+		return err
+	}
+
+	if syncTaxID && result.TaxID != profile.GetString("provider_tax_id") {
+		profile.Set("provider_tax_id", result.TaxID)
+		if err := app.Save(profile); err != nil {
+			slog.Warn("Failed to save provider tax ID", "profile_id", profile.Id, "error", err)
+		}
+	}
+
+	// Keep the tax details invoices are generated with in step with the profile
+	details := &CustomerTaxDetails{
+		Name: params.CompanyName,
+		Address: BillingAddress{
+			Line1:      params.AddressLine1,
+			Line2:      params.AddressLine2,
+			City:       params.City,
+			PostalCode: params.PostalCode,
+			State:      params.State,
+			Country:    params.Country,
+		},
+		TaxIDs: []TaxID{},
+	}
+	if vatID := profile.GetString("vat_id"); vatID != "" {
+		details.TaxIDs = append(details.TaxIDs, TaxID{Type: profile.GetString("tax_id_type"), Value: vatID})
+	}
+	if err := saveCustomerTaxDetails(app, customerID, details); err != nil {
+		slog.Warn("Failed to save customer tax details", "customer_id", customerID, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package payment
+
+import "testing"
+
+func TestBusinessProfileRequestValidate(t *testing.T) {
+	valid := func() BusinessProfileRequest {
+		return BusinessProfileRequest{
+			CompanyName:  " Acme GmbH ",
+			AddressLine1: " Hauptstr. 1 ",
+			City:         "Berlin",
+			PostalCode:   "10115",
+			Country:      " de ",
+			VATID:        "de 123 456 789",
+		}
+	}
+
+	tests := []struct {
+		name          string
+		modify        func(r *BusinessProfileRequest)
+		wantTaxIDType string
+		wantErr       bool
+	}{
+		{"EU VAT ID", func(r *BusinessProfileRequest) {}, "eu_vat", false},
+		{"UK VAT ID", func(r *BusinessProfileRequest) { r.Country, r.VATID = "GB", "GB123456789" }, "gb_vat", false},
+		{"Australian ABN", func(r *BusinessProfileRequest) { r.Country, r.VATID = "au", "51824753556" }, "au_abn", false},
+		{"no VAT ID", func(r *BusinessProfileRequest) { r.VATID = "" }, "", false},
+		{"no VAT ID in an unsupported country", func(r *BusinessProfileRequest) { r.Country, r.VATID = "US", "" }, "", false},
+		{"VAT ID in an unsupported country", func(r *BusinessProfileRequest) { r.Country = "US" }, "", true},
+		{"missing company", func(r *BusinessProfileRequest) { r.CompanyName = "   " }, "", true},
+		{"missing country", func(r *BusinessProfileRequest) { r.Country = "" }, "", true},
+		{"country name instead of code", func(r *BusinessProfileRequest) { r.Country = "Germany" }, "", true},
+		{"numeric country", func(r *BusinessProfileRequest) { r.Country = "12" }, "", true},
+		{"missing address", func(r *BusinessProfileRequest) { r.AddressLine1 = "" }, "", true},
+		{"missing city", func(r *BusinessProfileRequest) { r.City = " " }, "", true},
+		{"missing postal code", func(r *BusinessProfileRequest) { r.PostalCode = "" }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(&req)
+			req.Normalize()
+			taxIDType, err := req.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if taxIDType != tt.wantTaxIDType {
+				t.Errorf("Validate() tax ID type = %q, want %q", taxIDType, tt.wantTaxIDType)
+			}
+		})
+	}
+}
+
+func TestBusinessProfileRequestNormalize(t *testing.T) {
+	req := BusinessProfileRequest{CompanyName: " Acme ", Country: " fr ", VATID: " fr 12 345678901 "}
+	req.Normalize()
+	if req.CompanyName != "Acme" || req.Country != "FR" || req.VATID != "FR12345678901" {
+		t.Errorf("Normalize() = %+v", req)
+	}
+}
+
+func TestTaxIDTypeForCountry(t *testing.T) {
+	tests := map[string]string{
+		// EU member states share one type
+		"DE": "eu_vat",
+		"FR": "eu_vat",
+		"IE": "eu_vat",
+		"SK": "eu_vat",
+		// European countries outside the EU have their own
+		"GB": "gb_vat",
+		"CH": "ch_vat",
+		"NO": "no_vat",
+		// GST countries
+		"AU": "au_abn",
+		"NZ": "nz_gst",
+		"CA": "ca_gst_hst",
+		"SG": "sg_gst",
+		"IN": "in_gst",
+		// Unsupported or invalid input
+		"US":  "",
+		"de":  "",
+		"":    "",
+		"DEU": "",
+		"EU":  "",
+	}
+	for country, want := range tests {
+		if got := TaxIDTypeForCountry(country); got != want {
+			t.Errorf("TaxIDTypeForCountry(%q) = %q, want %q", country, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package payment
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Checkout session statuses tracked in the checkout_sessions collection
+const (
+	CheckoutSessionOpen      = "open"
+	CheckoutSessionCompleted = "completed"
+	CheckoutSessionExpired   = "expired"
+)
+
+// checkoutSessionTTL is how long a new checkout session stays payable (Stripe's minimum is 30 minutes)
+const checkoutSessionTTL = 30 * time.Minute
+
+// checkoutReuseMargin keeps us from handing out a session that expires before the user can pay
+const checkoutReuseMargin = 2 * time.Minute
+
+// checkoutLocks serializes checkout creation per user so double-clicks can't race past the reuse check
+var checkoutLocks sync.Map // user ID -> *sync.Mutex
+
+func lockCheckout(userID string) func() {
+	value, _ := checkoutLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// checkoutSessionReusable reports whether an open session can still be handed back to the user
+func checkoutSessionReusable(status string, expiresAt, now time.Time) bool {
+	return status == CheckoutSessionOpen && expiresAt.After(now.Add(checkoutReuseMargin))
+}
+
+// findReusableCheckoutSession returns the user's open session for the plan, if it hasn't expired
+func findReusableCheckoutSession(app core.App, userID, planID string, now time.Time) *core.Record {
+	record, err := app.FindFirstRecordByFilter("checkout_sessions",
+		"user_id = {:user_id} && plan_id = {:plan_id} && status = {:status}",
+		map[string]any{"user_id": userID, "plan_id": planID, "status": CheckoutSessionOpen})
+	if err != nil {
+		return nil
+	}
+
+	if !checkoutSessionReusable(record.GetString("status"), record.GetDateTime("expires_at").Time(), now) {
+		return nil
+	}
+	return record
+}
+
+// expireStaleCheckoutSessions closes every open session of the user except keepID
+// Sessions still payable at the provider are expired there too, so they can't create a second subscription
+func expireStaleCheckoutSessions(app core.App, paymentService *Service, userID, keepID string, now time.Time) {
+	records, err := app.FindRecordsByFilter("checkout_sessions",
+		"user_id = {:user_id} && status = {:status}", "", 0, 0,
+		map[string]any{"user_id": userID, "status": CheckoutSessionOpen})
+	if err != nil {
+		slog.Warn("Failed to load open checkout sessions", "user_id", userID, "error", err)
+		return
+	}
+
+	for _, record := range records {
+		if record.Id == keepID {
+			continue
+		}
+
+		if record.GetDateTime("expires_at").Time().After(now) {
+			if err := paymentService.ExpireCheckoutSession(record.GetString("provider_session_id")); err != nil {
+				// The session may have been completed or expired already - the webhook will settle it
+				slog.Warn("Failed to expire checkout session", "checkout_session_id", record.GetString("provider_session_id"), "error", err)
+				continue
+			}
+		}
+
+		record.Set("status", CheckoutSessionExpired)
+		if err := app.Save(record); err != nil {
+			slog.Warn("Failed to mark checkout session expired", "record_id", record.Id, "error", err)
+		}
+	}
+}
+
+// recordCheckoutSession stores a newly created session so repeated checkouts can reuse it
+func recordCheckoutSession(app core.App, userID, planID string, session *CheckoutSession) error {
+	collection, err := app.FindCollectionByNameOrId("checkout_sessions")
+	if err != nil {
+		return err
+	}
+
+	expiresAt, err := types.ParseDateTime(session.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("user_id", userID)
+	record.Set("plan_id", planID)
+	record.Set("provider_session_id", session.ID)
+	record.Set("url", session.URL)
+	record.Set("status", CheckoutSessionOpen)
+	record.Set("expires_at", expiresAt)
+	return app.Save(record)
+}
+
+// UpdateCheckoutSessionStatus marks a tracked session completed or expired when the provider reports it
+func UpdateCheckoutSessionStatus(app core.App, sessionID, status string) {
+	record, err := app.FindFirstRecordByFilter("checkout_sessions", "provider_session_id = {:id}", map[string]any{"id": sessionID})
+	if err != nil {
+		return // sessions created before tracking was added aren't recorded
+	}
+
+	if record.GetString("status") == status {
+		return
+	}
+
+	record.Set("status", status)
+	if err := app.Save(record); err != nil {
+		slog.Error("Failed to update checkout session status", "checkout_session_id", sessionID, "status", status, "error", err)
+	}
+}
@@ -0,0 +1,31 @@
+package payment
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckoutSessionReusable(t *testing.T) {
+	now := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		status    string
+		expiresAt time.Time
+		want      bool
+	}{
+		{"open with time left", CheckoutSessionOpen, now.Add(20 * time.Minute), true},
+		{"open but about to expire", CheckoutSessionOpen, now.Add(time.Minute), false},
+		{"open but already expired", CheckoutSessionOpen, now.Add(-time.Minute), false},
+		{"completed", CheckoutSessionCompleted, now.Add(20 * time.Minute), false},
+		{"expired", CheckoutSessionExpired, now.Add(20 * time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkoutSessionReusable(tt.status, tt.expiresAt, now); got != tt.want {
+				t.Errorf("checkoutSessionReusable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package payment
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79"
+
+	"pocketbase/internal/logging"
+)
+
+// ErrPromotionCodeNotFound is returned when no promotion code matches what the customer typed
+var ErrPromotionCodeNotFound = errors.New("promotion code not found")
+
+// Reasons a coupon can't be used, returned to the frontend as reason
+const (
+	CouponNotFound         = "COUPON_NOT_FOUND"
+	CouponInactive         = "COUPON_INACTIVE"
+	CouponExpired          = "COUPON_EXPIRED"
+	CouponExhausted        = "COUPON_EXHAUSTED"
+	CouponNotForCustomer   = "COUPON_NOT_FOR_CUSTOMER"
+	CouponNewCustomersOnly = "COUPON_NEW_CUSTOMERS_ONLY"
+	CouponNotForPlan       = "COUPON_NOT_FOR_PLAN"
+	CouponMinimumNotMet    = "COUPON_MINIMUM_NOT_MET"
+)
+
+var couponMessages = map[string]string{
+	CouponNotFound:         "This code doesn't exist",
+	CouponInactive:         "This code is no longer active",
+	CouponExpired:          "This code has expired",
+	CouponExhausted:        "This code has reached its redemption limit",
+	CouponNotForCustomer:   "This code belongs to another account",
+	CouponNewCustomersOnly: "This code is only for new customers",
+	CouponNotForPlan:       "This code doesn't apply to the selected plan",
+	CouponMinimumNotMet:    "The selected plan doesn't meet this code's minimum amount",
+}
+
+// Metadata for restrictions Stripe doesn't enforce at checkout, set on the promotion code or its
+// coupon (the promotion code wins)
+const (
+	couponNewCustomersOnlyKey = "new_customers_only" // "true" to refuse anyone who had a paid subscription
+	couponPlanIDsKey          = "plan_ids"           // comma-separated subscription_plans IDs
+)
+
+// couponCustomer is who is redeeming a code
+type couponCustomer struct {
+	CustomerID string // "" before their first checkout
+	Returning  bool   // has, or had, a paid subscription
+}
+
+// couponPlan is a plan a code may be applied to
+type couponPlan struct {
+	ID         string
+	Name       string
+	ProductID  string
+	PriceCents int64
+	Currency   string
+}
+
+func couponPlanFromRecord(plan *core.Record) couponPlan {
+	return couponPlan{
+		ID:         plan.Id,
+		Name:       plan.GetString("name"),
+		ProductID:  plan.GetString("provider_product_id"),
+		PriceCents: int64(plan.GetInt("price_cents")),
+		Currency:   plan.GetString("currency"),
+	}
+}
+
+// couponMetadata reads a restriction from the promotion code, falling back to its coupon
+func couponMetadata(promo *PromotionCode, key string) string {
+	if v := promo.Metadata[key]; v != "" {
+		return v
+	}
+	return promo.Coupon.Metadata[key]
+}
+
+// promotionProblem returns why the customer can't redeem promo, or "" if they can
+func promotionProblem(promo *PromotionCode, customer couponCustomer, now time.Time) string {
+	switch {
+	case !promo.Active || !promo.Coupon.Valid:
+		return CouponInactive
+	case promo.ExpiresAt != nil && !now.Before(*promo.ExpiresAt):
+		return CouponExpired
+	case promo.MaxRedemptions > 0 && promo.TimesRedeemed >= promo.MaxRedemptions:
+		return CouponExhausted
+	case promo.CustomerID != "" && promo.CustomerID != customer.CustomerID:
+		return CouponNotForCustomer
+	case customer.Returning && (promo.FirstTimeOnly || couponMetadata(promo, couponNewCustomersOnlyKey) == "true"):
+		return CouponNewCustomersOnly
+	}
+	return ""
+}
+
+// planProblem returns why promo can't be applied to plan, or "" if it can
+func planProblem(promo *PromotionCode, plan couponPlan) string {
+	coupon := promo.Coupon
+	if len(coupon.AppliesToProducts) > 0 && !slices.Contains(coupon.AppliesToProducts, plan.ProductID) {
+		return CouponNotForPlan
+	}
+	if planIDs := couponMetadata(promo, couponPlanIDsKey); planIDs != "" {
+		allowed := false
+		for _, id := range strings.Split(planIDs, ",") {
+			if strings.TrimSpace(id) == plan.ID {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return CouponNotForPlan
+		}
+	}
+	// Stripe only applies fixed amounts in the coupon's own currency
+	if coupon.AmountOff > 0 && !strings.EqualFold(coupon.Currency, plan.Currency) {
+		return CouponNotForPlan
+	}
+	if promo.MinimumAmount > 0 && strings.EqualFold(promo.MinimumAmountCurrency, plan.Currency) && plan.PriceCents < promo.MinimumAmount {
+		return CouponMinimumNotMet
+	}
+	return ""
+}
+
+// discountedPrice is what the first invoice for priceCents comes to with coupon applied
+func discountedPrice(coupon Coupon, priceCents int64) int64 {
+	discounted := priceCents
+	if coupon.PercentOff > 0 {
+		discounted -= int64(math.Round(float64(priceCents) * coupon.PercentOff / 100))
+	}
+	if coupon.AmountOff > 0 {
+		discounted -= coupon.AmountOff
+	}
+	return max(discounted, 0)
+}
+
+func convertStripePromotionCode(promo *stripe.PromotionCode) *PromotionCode {
+	converted := &PromotionCode{
+		ID:             promo.ID,
+		Code:           promo.Code,
+		Active:         promo.Active,
+		MaxRedemptions: promo.MaxRedemptions,
+		TimesRedeemed:  promo.TimesRedeemed,
+		Metadata:       promo.Metadata,
+	}
+	if promo.Customer != nil {
+		converted.CustomerID = promo.Customer.ID
+	}
+	if promo.ExpiresAt > 0 {
+		expiresAt := unixTime(promo.ExpiresAt)
+		converted.ExpiresAt = &expiresAt
+	}
+	if r := promo.Restrictions; r != nil {
+		converted.FirstTimeOnly = r.FirstTimeTransaction
+		converted.MinimumAmount = r.MinimumAmount
+		converted.MinimumAmountCurrency = string(r.MinimumAmountCurrency)
+	}
+	if c := promo.Coupon; c != nil {
+		converted.Coupon = Coupon{
+			ID:               c.ID,
+			Name:             c.Name,
+			PercentOff:       c.PercentOff,
+			AmountOff:        c.AmountOff,
+			Currency:         string(c.Currency),
+			Duration:         string(c.Duration),
+			DurationInMonths: c.DurationInMonths,
+			Valid:            c.Valid,
+			Metadata:         c.Metadata,
+		}
+		if c.RedeemBy > 0 && (converted.ExpiresAt == nil || c.RedeemBy < promo.ExpiresAt) {
+			redeemBy := unixTime(c.RedeemBy)
+			converted.ExpiresAt = &redeemBy
+		}
+		if c.AppliesTo != nil {
+			converted.Coupon.AppliesToProducts = c.AppliesTo.Products
+		}
+	}
+	return converted
+}
+
+// invalidCoupon is the response for a code the user can't redeem
+func invalidCoupon(e *core.RequestEvent, reason string) error {
+	return e.JSON(http.StatusOK, map[string]any{
+		"valid":   false,
+		"reason":  reason,
+		"message": couponMessages[reason],
+	})
+}
+
+// ValidateCouponHandler checks a promotion code before checkout so the user sees the discounted
+// price up front. Without plan_id it lists every plan the code applies to
+// POST /api/payment/validate-coupon {"code": "...", "plan_id": "..."}
+func ValidateCouponHandler(e *core.RequestEvent, app core.App, paymentService *Service) error {
+	if paymentService == nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+	}
+
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		Code   string `json:"code"`
+		PlanID string `json:"plan_id"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "code is required"})
+	}
+
+	var selected *couponPlan
+	if req.PlanID != "" {
+		plan, err := app.FindRecordById("subscription_plans", req.PlanID)
+		if err != nil || (plan.GetBool("is_custom") && plan.GetString("assigned_user_id") != user.Id) {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
+		}
+		p := couponPlanFromRecord(plan)
+		selected = &p
+	}
+
+	promo, err := paymentService.GetPromotionCode(req.Code)
+	if errors.Is(err, ErrPromotionCodeNotFound) {
+		return invalidCoupon(e, CouponNotFound)
+	}
+	if err != nil {
+		logging.FromRequest(e).Error("Failed to look up promotion code", "user_id", user.Id, "error", err)
+		return e.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to validate coupon"})
+	}
+
+	customer := couponCustomer{
+		CustomerID: findPaymentCustomerID(app, user.Id),
+		Returning:  hadStripeSubscription(app, user.Id),
+	}
+	if reason := promotionProblem(promo, customer, time.Now()); reason != "" {
+		return invalidCoupon(e, reason)
+	}
+
+	planResponse := func(plan couponPlan) map[string]any {
+		return map[string]any{
+			"id":                     plan.ID,
+			"name":                   plan.Name,
+			"price_cents":            plan.PriceCents,
+			"discounted_price_cents": discountedPrice(promo.Coupon, plan.PriceCents),
+			"currency":               plan.Currency,
+		}
+	}
+
+	// Plans anyone can buy through checkout, plus the one asked about
+	records, err := app.FindRecordsByFilter("subscription_plans",
+		"is_active = true && is_custom = false && price_cents > 0 && billing_method != 'invoice'", "price_cents", 0, 0)
+	if err != nil {
+		logging.FromRequest(e).Error("Failed to load plans", "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load plans"})
+	}
+	applicable := []map[string]any{}
+	for _, record := range records {
+		if plan := couponPlanFromRecord(record); planProblem(promo, plan) == "" {
+			applicable = append(applicable, planResponse(plan))
+		}
+	}
+
+	response := map[string]any{
+		"valid":            true,
+		"code":             promo.Code,
+		"discount":         promo.Coupon,
+		"applicable_plans": applicable,
+	}
+	if promo.ExpiresAt != nil {
+		response["expires_at"] = promo.ExpiresAt
+	}
+
+	if selected != nil {
+		if reason := planProblem(promo, *selected); reason != "" {
+			return invalidCoupon(e, reason)
+		}
+		response["plan"] = planResponse(*selected)
+	} else if len(applicable) == 0 {
+		return invalidCoupon(e, CouponNotForPlan)
+	}
+
+	return e.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,119 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v79"
+)
+
+func TestPromotionProblem(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+
+	valid := func() *PromotionCode {
+		return &PromotionCode{Active: true, Coupon: Coupon{Valid: true, PercentOff: 20}}
+	}
+	tests := []struct {
+		name     string
+		modify   func(*PromotionCode)
+		customer couponCustomer
+		want     string
+	}{
+		{"valid", func(p *PromotionCode) {}, couponCustomer{}, ""},
+		{"inactive code", func(p *PromotionCode) { p.Active = false }, couponCustomer{}, CouponInactive},
+		{"deleted coupon", func(p *PromotionCode) { p.Coupon.Valid = false }, couponCustomer{}, CouponInactive},
+		{"expired", func(p *PromotionCode) { p.ExpiresAt = &past }, couponCustomer{}, CouponExpired},
+		{"exhausted", func(p *PromotionCode) { p.MaxRedemptions, p.TimesRedeemed = 5, 5 }, couponCustomer{}, CouponExhausted},
+		{"other customer", func(p *PromotionCode) { p.CustomerID = "cus_other" }, couponCustomer{CustomerID: "cus_me"}, CouponNotForCustomer},
+		{"own customer", func(p *PromotionCode) { p.CustomerID = "cus_me" }, couponCustomer{CustomerID: "cus_me"}, ""},
+		{"first time only", func(p *PromotionCode) { p.FirstTimeOnly = true }, couponCustomer{Returning: true}, CouponNewCustomersOnly},
+		{"first time only, new customer", func(p *PromotionCode) { p.FirstTimeOnly = true }, couponCustomer{}, ""},
+		{"new customers metadata", func(p *PromotionCode) {
+			p.Coupon.Metadata = map[string]string{couponNewCustomersOnlyKey: "true"}
+		}, couponCustomer{Returning: true}, CouponNewCustomersOnly},
+	}
+	for _, tt := range tests {
+		promo := valid()
+		tt.modify(promo)
+		if got := promotionProblem(promo, tt.customer, now); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanProblem(t *testing.T) {
+	basic := couponPlan{ID: "plan_basic", ProductID: "prod_basic", PriceCents: 500, Currency: "usd"}
+	pro := couponPlan{ID: "plan_pro", ProductID: "prod_pro", PriceCents: 2000, Currency: "usd"}
+
+	tests := []struct {
+		name  string
+		promo PromotionCode
+		plan  couponPlan
+		want  string
+	}{
+		{"any plan", PromotionCode{Coupon: Coupon{PercentOff: 10}}, basic, ""},
+		{"product restriction", PromotionCode{Coupon: Coupon{AppliesToProducts: []string{"prod_pro"}}}, basic, CouponNotForPlan},
+		{"product restriction met", PromotionCode{Coupon: Coupon{AppliesToProducts: []string{"prod_pro"}}}, pro, ""},
+		{"plan metadata", PromotionCode{Metadata: map[string]string{couponPlanIDsKey: "plan_x, plan_pro"}}, pro, ""},
+		{"plan metadata excludes", PromotionCode{Metadata: map[string]string{couponPlanIDsKey: "plan_pro"}}, basic, CouponNotForPlan},
+		{"currency mismatch", PromotionCode{Coupon: Coupon{AmountOff: 300, Currency: "eur"}}, basic, CouponNotForPlan},
+		{"minimum not met", PromotionCode{MinimumAmount: 1000, MinimumAmountCurrency: "usd"}, basic, CouponMinimumNotMet},
+		{"minimum met", PromotionCode{MinimumAmount: 1000, MinimumAmountCurrency: "usd"}, pro, ""},
+	}
+	for _, tt := range tests {
+		if got := planProblem(&tt.promo, tt.plan); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiscountedPrice(t *testing.T) {
+	tests := []struct {
+		coupon Coupon
+		price  int64
+		want   int64
+	}{
+		{Coupon{PercentOff: 25}, 1999, 1499},
+		{Coupon{PercentOff: 100}, 1999, 0},
+		{Coupon{AmountOff: 500}, 1999, 1499},
+		{Coupon{AmountOff: 5000}, 1999, 0},
+	}
+	for _, tt := range tests {
+		if got := discountedPrice(tt.coupon, tt.price); got != tt.want {
+			t.Errorf("discountedPrice(%+v, %d) = %d, want %d", tt.coupon, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestConvertStripePromotionCode(t *testing.T) {
+	promo := convertStripePromotionCode(&stripe.PromotionCode{
+		ID:        "promo_1",
+		Code:      "LAUNCH",
+		Active:    true,
+		ExpiresAt: 2000,
+		Restrictions: &stripe.PromotionCodeRestrictions{
+			FirstTimeTransaction: true,
+		},
+		Coupon: &stripe.Coupon{
+			ID:        "co_1",
+			Duration:  stripe.CouponDurationRepeating,
+			AmountOff: 300,
+			Currency:  stripe.CurrencyUSD,
+			RedeemBy:  1000,
+			Valid:     true,
+			AppliesTo: &stripe.CouponAppliesTo{Products: []string{"prod_pro"}},
+		},
+	})
+
+	if promo.Code != "LAUNCH" || !promo.FirstTimeOnly || promo.Coupon.Duration != "repeating" || promo.Coupon.Currency != "usd" {
+		t.Errorf("unexpected promotion code %+v", promo)
+	}
+	// The coupon's redeem_by is earlier than the code's own expiry
+	if promo.ExpiresAt == nil || promo.ExpiresAt.Unix() != 1000 {
+		t.Errorf("expected expiry at the coupon's redeem_by, got %v", promo.ExpiresAt)
+	}
+	if len(promo.Coupon.AppliesToProducts) != 1 || promo.Coupon.AppliesToProducts[0] != "prod_pro" {
+		t.Errorf("unexpected applies_to %v", promo.Coupon.AppliesToProducts)
+	}
+}
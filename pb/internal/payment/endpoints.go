@@ -2,11 +2,13 @@ package payment
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
 )
 
 // CreateCheckoutSessionHandler handles requests to create a Stripe checkout session
@@ -30,6 +32,16 @@ func CreateCheckoutSessionHandler(e *core.RequestEvent, app core.App, paymentSer
 		return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
 	}
 
+	// Custom plans can only be purchased by the user they were built for
+	if plan.GetBool("is_custom") && plan.GetString("assigned_user_id") != req.UserID {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
+	}
+
+	// Invoice-billed custom plans are activated by an admin, not through checkout
+	if plan.GetString("billing_method") == "invoice" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "This plan is billed by invoice - contact support to activate it"})
+	}
+
 	// Check if this is a free plan
 	if plan.GetInt("price_cents") == 0 {
 		// For free plans, don't create a checkout session, just return success
@@ -46,6 +58,25 @@ func CreateCheckoutSessionHandler(e *core.RequestEvent, app core.App, paymentSer
 		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
 	}
 
+	// Sandbox tenants (see accounts.IsSandbox) get the plan straight away without a Stripe checkout
+	if user.GetString("sandbox_owner") != "" {
+		return activateSandboxPlan(e, app, user, plan)
+	}
+
+	// Double-clicks and retries get the session already in progress instead of a second subscription
+	unlock := lockCheckout(req.UserID)
+	defer unlock()
+
+	now := time.Now()
+	if existing := findReusableCheckoutSession(app, req.UserID, req.PlanID, now); existing != nil {
+		expireStaleCheckoutSessions(app, paymentService, req.UserID, existing.Id, now)
+		return e.JSON(http.StatusOK, map[string]any{
+			"url":    existing.GetString("url"),
+			"reused": true,
+		})
+	}
+	expireStaleCheckoutSessions(app, paymentService, req.UserID, "", now)
+
 	// Check if customer exists
 	customers, err := app.FindRecordsByFilter("payment_customers", fmt.Sprintf("user_id = '%s'", req.UserID), "", 1, 0)
 	var customerID string
@@ -69,9 +100,16 @@ func CreateCheckoutSessionHandler(e *core.RequestEvent, app core.App, paymentSer
 		record.Set("user_id", req.UserID)
 		record.Set("provider_customer_id", customer.ID)
 		if err := app.Save(record); err != nil {
-			log.Printf("Failed to save customer record: %v", err)
+			logging.FromRequest(e).Error("Failed to save customer record", "error", err)
 		}
 		customerID = customer.ID
+
+		// Carry over business details entered before the first checkout
+		if profile, err := app.FindFirstRecordByFilter("business_profiles", "user_id = {:user_id}", map[string]any{"user_id": req.UserID}); err == nil {
+			if err := SyncBusinessProfile(app, paymentService, profile, customerID, true); err != nil {
+				logging.FromRequest(e).Warn("Failed to sync business profile", "user_id", req.UserID, "error", err)
+			}
+		}
 	} else {
 		customerID = customers[0].GetString("provider_customer_id")
 	}
@@ -92,6 +130,15 @@ func CreateCheckoutSessionHandler(e *core.RequestEvent, app core.App, paymentSer
 		AllowPromoCodes: true,
 		UserID:          req.UserID,
 		PlanID:          req.PlanID,
+		ExpiresAt:       now.Add(checkoutSessionTTL).Unix(),
+		CollectTax:      stripeTaxEnabled(),
+	}
+
+	// Plans aligned to the 1st renew on calendar months, matching monthly_usage periods. Trials
+	// aren't anchored - the first charge lands when the trial ends
+	checkoutParams.TrialDays = TrialDays(app, plan, req.UserID)
+	if plan.GetBool("align_billing_to_month_start") && checkoutParams.TrialDays == 0 {
+		checkoutParams.BillingCycleAnchor = MonthStartAnchor(now).Unix()
 	}
 
 	session, err := paymentService.CreateCheckoutSession(checkoutParams)
@@ -99,7 +146,15 @@ func CreateCheckoutSessionHandler(e *core.RequestEvent, app core.App, paymentSer
 		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Failed to create checkout session: %v", err)})
 	}
 
-	return e.JSON(http.StatusOK, map[string]string{"url": session.URL})
+	if err := recordCheckoutSession(app, req.UserID, req.PlanID, session); err != nil {
+		logging.FromRequest(e).Error("Failed to record checkout session", "checkout_session_id", session.ID, "error", err)
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"url":        session.URL,
+		"reused":     false,
+		"trial_days": checkoutParams.TrialDays,
+	})
 }
 
 // CreatePortalLinkHandler handles requests to create a billing portal link
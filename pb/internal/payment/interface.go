@@ -9,15 +9,37 @@ type Provider interface {
 	// Checkout operations
 	CreateCheckoutSession(params CheckoutSessionParams) (*CheckoutSession, error)
 	CreateBillingPortalLink(customerID string, returnURL string) (*PortalLink, error)
+	ExpireCheckoutSession(sessionID string) error
 	
 	// Subscription management
+	GetSubscription(subscriptionID string) (*Subscription, error)
 	ChangeSubscriptionPlan(subscriptionID string, newPriceID string, prorationBehavior string) (*Subscription, error)
+	SchedulePlanChange(subscriptionID string, newPriceID string) (*Subscription, error)
+	// PauseSubscription stops collecting payment until resumesAt (indefinitely when zero)
+	PauseSubscription(subscriptionID string, resumesAt time.Time) (*Subscription, error)
+	ResumeSubscription(subscriptionID string) (*Subscription, error)
+	// CancelSubscription cancels at the period end, or immediately crediting the unused time
 	CancelSubscription(subscriptionID string, cancelAtPeriodEnd bool) (*Subscription, error)
+	// ExtendSubscription pushes the next renewal out to until without charging for the extra time
+	ExtendSubscription(subscriptionID string, until time.Time) (*Subscription, error)
 	
 	// Customer management
 	CreateCustomer(params CustomerParams) (*Customer, error)
 	GetCustomer(customerID string) (*Customer, error)
 	HasValidPaymentMethod(customerID string) (*PaymentMethodStatus, error)
+	UpdateCustomerBusinessProfile(customerID string, params BusinessProfileParams) (*BusinessProfileResult, error)
+	// UpdateCustomerMetadata sets metadata keys on a customer, leaving the others as they are
+	UpdateCustomerMetadata(customerID string, metadata map[string]string) error
+	
+	// Billing history
+	ListInvoices(customerID string, limit int64) ([]*InvoiceSummary, error)
+	GetUpcomingInvoice(params UpcomingInvoiceParams) (*UpcomingInvoice, error)
+
+	// Discounts
+	GetPromotionCode(code string) (*PromotionCode, error)
+
+	// Refunds
+	RefundInvoice(params RefundParams) (*Refund, error)
 	
 	// Webhook handling
 	ParseWebhookEvent(payload []byte, signature string) (*WebhookEvent, error)
@@ -47,15 +69,21 @@ type CheckoutSessionParams struct {
 	UserID         string // For metadata
 	PlanID         string // For metadata
 	AllowPromoCodes bool
+	BillingCycleAnchor int64 // Unix time of the first renewal, 0 to renew on the checkout anniversary
+	ExpiresAt          int64 // Unix time the session stops accepting payment, 0 for the provider default
+	TrialDays          int64 // free trial before the first charge; a card is only asked for if the provider requires one
+	CollectTax         bool  // calculate tax and ask for a billing address and VAT/GST ID
 }
 
 // CheckoutSession represents a payment checkout session
 type CheckoutSession struct {
-	ID         string
-	URL        string
-	CustomerID string
-	Status     string
-	Metadata   map[string]string
+	ID              string
+	URL             string
+	CustomerID      string
+	Status          string
+	ExpiresAt       time.Time
+	Metadata        map[string]string
+	CustomerDetails *CustomerTaxDetails // billing details entered at checkout, on completed sessions
 }
 
 // PortalLink represents a billing portal/management link
@@ -71,6 +99,25 @@ type CustomerParams struct {
 	Metadata map[string]string
 }
 
+// BusinessProfileParams represents the business details pushed to a customer for compliant invoices
+type BusinessProfileParams struct {
+	CompanyName   string
+	TaxIDType     string // Provider tax ID type, e.g. "eu_vat", "gb_vat", "au_abn"
+	TaxIDValue    string
+	PreviousTaxID string // Provider tax ID to replace, if any
+	AddressLine1  string
+	AddressLine2  string
+	City          string
+	PostalCode    string
+	State         string
+	Country       string // ISO 3166-1 alpha-2
+}
+
+// BusinessProfileResult contains the provider identifiers created for a business profile
+type BusinessProfileResult struct {
+	TaxID string
+}
+
 // Customer represents a payment provider customer
 type Customer struct {
 	ID       string
@@ -88,7 +135,13 @@ type Subscription struct {
 	CurrentPeriodStart   time.Time
 	CurrentPeriodEnd     time.Time
 	CanceledAt           *time.Time
+	TrialEnd             *time.Time
 	PriceID              string
+	ProductID            string
+	Interval             string // billing interval of the price, "month" or "year"
+	ProrationAmount      *int64 // total of the proration invoice raised by a plan change, if any
+	Paused               bool   // payment collection is paused
+	ResumesAt            *time.Time // when a paused subscription resumes by itself, if set
 	Metadata             map[string]string
 }
 
@@ -134,6 +187,104 @@ type Invoice struct {
 	Metadata       map[string]string
 }
 
+// InvoiceSummary is an invoice from the customer's billing history
+type InvoiceSummary struct {
+	ID               string     `json:"id"`
+	Number           string     `json:"number"`
+	Status           string     `json:"status"`
+	AmountDue        int64      `json:"amount_due"`
+	AmountPaid       int64      `json:"amount_paid"`
+	Total            int64      `json:"total"`
+	Currency         string     `json:"currency"`
+	HostedInvoiceURL string     `json:"hosted_invoice_url,omitempty"`
+	InvoicePDF       string     `json:"invoice_pdf,omitempty"`
+	PeriodStart      time.Time  `json:"period_start"`
+	PeriodEnd        time.Time  `json:"period_end"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+	Created          time.Time  `json:"created"`
+	Tax              int64      `json:"tax"`                        // tax included in Total
+	CustomerTaxIDs   []TaxID    `json:"customer_tax_ids,omitempty"` // the customer's tax IDs printed on the invoice
+}
+
+// RefundParams selects the invoice to refund in full
+type RefundParams struct {
+	InvoiceID string
+	Metadata  map[string]string
+	// EndSubscription cancels the invoice's subscription at once, without crediting the unused
+	// time - the refund already covers it
+	EndSubscription bool
+}
+
+// Refund is a refund issued by the payment provider
+type Refund struct {
+	ID        string
+	InvoiceID string
+	Amount    int64
+	Currency  string
+	Status    string
+}
+
+// UpcomingInvoiceParams selects the subscription to preview and, optionally, a price to preview
+// switching to
+type UpcomingInvoiceParams struct {
+	CustomerID     string
+	SubscriptionID string
+	NewPriceID     string // Empty to preview the renewal on the current price
+	ProrationDate  int64  // Unix time the change is priced at, 0 for now
+}
+
+// UpcomingInvoice is a preview of the customer's next charge
+type UpcomingInvoice struct {
+	AmountDue       int64                 `json:"amount_due"`
+	Subtotal        int64                 `json:"subtotal"`
+	Total           int64                 `json:"total"`
+	ProrationAmount int64                 `json:"proration_amount"` // Net of the proration lines
+	Currency        string                `json:"currency"`
+	NextPaymentAt   *time.Time            `json:"next_payment_at,omitempty"`
+	PeriodStart     time.Time             `json:"period_start"`
+	PeriodEnd       time.Time             `json:"period_end"`
+	Lines           []UpcomingInvoiceLine `json:"lines"`
+}
+
+// UpcomingInvoiceLine is a line of an upcoming invoice
+type UpcomingInvoiceLine struct {
+	Description string    `json:"description"`
+	Amount      int64     `json:"amount"`
+	Proration   bool      `json:"proration"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+// PromotionCode is a customer-facing code for a coupon, with the provider's redemption limits
+type PromotionCode struct {
+	ID                    string
+	Code                  string
+	Active                bool
+	CustomerID            string // only this customer may redeem it, if set
+	ExpiresAt             *time.Time
+	MaxRedemptions        int64 // 0 for unlimited
+	TimesRedeemed         int64
+	FirstTimeOnly         bool  // only for customers without prior payments
+	MinimumAmount         int64 // in MinimumAmountCurrency's smallest unit, 0 for no minimum
+	MinimumAmountCurrency string
+	Metadata              map[string]string
+	Coupon                Coupon
+}
+
+// Coupon is the discount behind a promotion code
+type Coupon struct {
+	ID                string            `json:"id"`
+	Name              string            `json:"name,omitempty"`
+	PercentOff        float64           `json:"percent_off,omitempty"`
+	AmountOff         int64             `json:"amount_off,omitempty"`
+	Currency          string            `json:"currency,omitempty"`
+	Duration          string            `json:"duration"` // once, repeating or forever
+	DurationInMonths  int64             `json:"duration_in_months,omitempty"`
+	AppliesToProducts []string          `json:"-"` // empty for every product
+	Valid             bool              `json:"-"`
+	Metadata          map[string]string `json:"-"`
+}
+
 // PaymentMethodStatus represents the status of a customer's payment methods
 type PaymentMethodStatus struct {
 	HasValidPaymentMethod bool      `json:"has_valid_payment_method"`
@@ -175,14 +326,38 @@ func (s *Service) CreateBillingPortalLink(customerID string, returnURL string) (
 	return s.provider.CreateBillingPortalLink(customerID, returnURL)
 }
 
+func (s *Service) ExpireCheckoutSession(sessionID string) error {
+	return s.provider.ExpireCheckoutSession(sessionID)
+}
+
+func (s *Service) GetSubscription(subscriptionID string) (*Subscription, error) {
+	return s.provider.GetSubscription(subscriptionID)
+}
+
 func (s *Service) ChangeSubscriptionPlan(subscriptionID string, newPriceID string, prorationBehavior string) (*Subscription, error) {
 	return s.provider.ChangeSubscriptionPlan(subscriptionID, newPriceID, prorationBehavior)
 }
 
+func (s *Service) SchedulePlanChange(subscriptionID string, newPriceID string) (*Subscription, error) {
+	return s.provider.SchedulePlanChange(subscriptionID, newPriceID)
+}
+
+func (s *Service) PauseSubscription(subscriptionID string, resumesAt time.Time) (*Subscription, error) {
+	return s.provider.PauseSubscription(subscriptionID, resumesAt)
+}
+
+func (s *Service) ResumeSubscription(subscriptionID string) (*Subscription, error) {
+	return s.provider.ResumeSubscription(subscriptionID)
+}
+
 func (s *Service) CancelSubscription(subscriptionID string, cancelAtPeriodEnd bool) (*Subscription, error) {
 	return s.provider.CancelSubscription(subscriptionID, cancelAtPeriodEnd)
 }
 
+func (s *Service) ExtendSubscription(subscriptionID string, until time.Time) (*Subscription, error) {
+	return s.provider.ExtendSubscription(subscriptionID, until)
+}
+
 func (s *Service) CreateCustomer(params CustomerParams) (*Customer, error) {
 	return s.provider.CreateCustomer(params)
 }
@@ -195,6 +370,30 @@ func (s *Service) HasValidPaymentMethod(customerID string) (*PaymentMethodStatus
 	return s.provider.HasValidPaymentMethod(customerID)
 }
 
+func (s *Service) UpdateCustomerBusinessProfile(customerID string, params BusinessProfileParams) (*BusinessProfileResult, error) {
+	return s.provider.UpdateCustomerBusinessProfile(customerID, params)
+}
+
+func (s *Service) UpdateCustomerMetadata(customerID string, metadata map[string]string) error {
+	return s.provider.UpdateCustomerMetadata(customerID, metadata)
+}
+
+func (s *Service) ListInvoices(customerID string, limit int64) ([]*InvoiceSummary, error) {
+	return s.provider.ListInvoices(customerID, limit)
+}
+
+func (s *Service) GetUpcomingInvoice(params UpcomingInvoiceParams) (*UpcomingInvoice, error) {
+	return s.provider.GetUpcomingInvoice(params)
+}
+
+func (s *Service) GetPromotionCode(code string) (*PromotionCode, error) {
+	return s.provider.GetPromotionCode(code)
+}
+
+func (s *Service) RefundInvoice(params RefundParams) (*Refund, error) {
+	return s.provider.RefundInvoice(params)
+}
+
 func (s *Service) ParseWebhookEvent(payload []byte, signature string) (*WebhookEvent, error) {
 	return s.provider.ParseWebhookEvent(payload, signature)
 }
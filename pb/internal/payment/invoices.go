@@ -0,0 +1,210 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79"
+
+	"pocketbase/internal/logging"
+	"pocketbase/internal/subscription"
+)
+
+// ErrNoUpcomingInvoice is returned when the customer has nothing left to be billed for
+var ErrNoUpcomingInvoice = errors.New("no upcoming invoice")
+
+const (
+	defaultInvoiceLimit = 24
+	maxInvoiceLimit     = 100
+)
+
+// invoiceLimit reads the optional limit query parameter, clamped to 1..maxInvoiceLimit
+func invoiceLimit(value string) int64 {
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		return defaultInvoiceLimit
+	}
+	if limit > maxInvoiceLimit {
+		return maxInvoiceLimit
+	}
+	return limit
+}
+
+// unixTime converts a Stripe timestamp, leaving unset (0) timestamps as the zero time
+func unixTime(ts int64) time.Time {
+	if ts <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0).UTC()
+}
+
+func convertStripeInvoiceSummary(inv *stripe.Invoice) *InvoiceSummary {
+	summary := &InvoiceSummary{
+		ID:               inv.ID,
+		Number:           inv.Number,
+		Status:           string(inv.Status),
+		AmountDue:        inv.AmountDue,
+		AmountPaid:       inv.AmountPaid,
+		Total:            inv.Total,
+		Currency:         string(inv.Currency),
+		HostedInvoiceURL: inv.HostedInvoiceURL,
+		InvoicePDF:       inv.InvoicePDF,
+		PeriodStart:      unixTime(inv.PeriodStart),
+		PeriodEnd:        unixTime(inv.PeriodEnd),
+		Created:          unixTime(inv.Created),
+		Tax:              inv.Tax,
+	}
+	for _, taxID := range inv.CustomerTaxIDs {
+		converted := TaxID{Value: taxID.Value}
+		if taxID.Type != nil {
+			converted.Type = string(*taxID.Type)
+		}
+		summary.CustomerTaxIDs = append(summary.CustomerTaxIDs, converted)
+	}
+	if inv.StatusTransitions != nil && inv.StatusTransitions.PaidAt > 0 {
+		paidAt := unixTime(inv.StatusTransitions.PaidAt)
+		summary.PaidAt = &paidAt
+	}
+	return summary
+}
+
+// convertStripeUpcomingInvoice converts a previewed invoice. Its period is taken from the
+// subscription lines, since an upcoming invoice's own period is the one being closed
+func convertStripeUpcomingInvoice(inv *stripe.Invoice) *UpcomingInvoice {
+	upcoming := &UpcomingInvoice{
+		AmountDue:   inv.AmountDue,
+		Subtotal:    inv.Subtotal,
+		Total:       inv.Total,
+		Currency:    string(inv.Currency),
+		PeriodStart: unixTime(inv.PeriodStart),
+		PeriodEnd:   unixTime(inv.PeriodEnd),
+		Lines:       []UpcomingInvoiceLine{},
+	}
+	if inv.NextPaymentAttempt > 0 {
+		next := unixTime(inv.NextPaymentAttempt)
+		upcoming.NextPaymentAt = &next
+	}
+
+	if inv.Lines == nil {
+		return upcoming
+	}
+	for _, line := range inv.Lines.Data {
+		converted := UpcomingInvoiceLine{
+			Description: line.Description,
+			Amount:      line.Amount,
+			Proration:   line.Proration,
+		}
+		if line.Period != nil {
+			converted.PeriodStart = unixTime(line.Period.Start)
+			converted.PeriodEnd = unixTime(line.Period.End)
+			if !line.Proration {
+				upcoming.PeriodStart = converted.PeriodStart
+				upcoming.PeriodEnd = converted.PeriodEnd
+			}
+		}
+		if line.Proration {
+			upcoming.ProrationAmount += line.Amount
+		}
+		upcoming.Lines = append(upcoming.Lines, converted)
+	}
+	return upcoming
+}
+
+// findPaymentCustomerID returns the user's provider customer ID, or "" if they never checked out
+func findPaymentCustomerID(app core.App, userID string) string {
+	customers, err := app.FindRecordsByFilter("payment_customers", "user_id = {:user}", "", 1, 0, map[string]any{"user": userID})
+	if err != nil || len(customers) == 0 {
+		return ""
+	}
+	return customers[0].GetString("provider_customer_id")
+}
+
+// ListInvoicesHandler returns the user's invoice history so the frontend can render it without
+// redirecting to the billing portal
+// GET /api/payment/invoices?limit=24
+func ListInvoicesHandler(e *core.RequestEvent, app core.App, paymentService *Service) error {
+	if paymentService == nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+	}
+
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	customerID := findPaymentCustomerID(app, user.Id)
+	if customerID == "" {
+		// Never checked out = no invoices
+		return e.JSON(http.StatusOK, map[string]any{"invoices": []*InvoiceSummary{}})
+	}
+
+	invoices, err := paymentService.ListInvoices(customerID, invoiceLimit(e.Request.URL.Query().Get("limit")))
+	if err != nil {
+		logging.FromRequest(e).Error("Failed to list invoices", "user_id", user.Id, "error", err)
+		return e.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to load invoices"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{"invoices": invoices})
+}
+
+// UpcomingInvoiceHandler previews the user's next charge. With plan_id it previews switching to
+// that plan now, including the proration credit and charge
+// GET /api/payment/upcoming-invoice?plan_id=...
+func UpcomingInvoiceHandler(e *core.RequestEvent, app core.App, paymentService *Service) error {
+	if paymentService == nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+	}
+
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	repo := subscription.NewRepository(app)
+	sub, err := repo.FindActiveSubscription(user.Id)
+	if err != nil || sub.GetString("provider_subscription_id") == "" {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "No active paid subscription"})
+	}
+
+	customerID := findPaymentCustomerID(app, user.Id)
+	if customerID == "" {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "No active paid subscription"})
+	}
+
+	params := UpcomingInvoiceParams{
+		CustomerID:     customerID,
+		SubscriptionID: sub.GetString("provider_subscription_id"),
+	}
+
+	if planID := e.Request.URL.Query().Get("plan_id"); planID != "" && planID != sub.GetString("plan_id") {
+		plan, err := repo.GetPlan(planID)
+		if err != nil {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
+		}
+		params.NewPriceID = plan.GetString("provider_price_id")
+		if params.NewPriceID == "" {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Plan has no price to switch to"})
+		}
+		params.ProrationDate = time.Now().Unix()
+	}
+
+	upcoming, err := paymentService.GetUpcomingInvoice(params)
+	if errors.Is(err, ErrNoUpcomingInvoice) {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "No upcoming invoice"})
+	}
+	if err != nil {
+		logging.FromRequest(e).Error("Failed to preview upcoming invoice", "user_id", user.Id, "error", err)
+		return e.JSON(http.StatusBadGateway, map[string]string{"error": fmt.Sprintf("Failed to preview upcoming invoice: %v", err)})
+	}
+
+	response := map[string]any{"invoice": upcoming}
+	if params.NewPriceID != "" {
+		response["plan_id"] = e.Request.URL.Query().Get("plan_id")
+		response["proration_date"] = unixTime(params.ProrationDate)
+	}
+	return e.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,74 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v79"
+)
+
+func TestInvoiceLimit(t *testing.T) {
+	tests := map[string]int64{
+		"":    defaultInvoiceLimit,
+		"abc": defaultInvoiceLimit,
+		"-3":  defaultInvoiceLimit,
+		"10":  10,
+		"500": maxInvoiceLimit,
+	}
+	for value, want := range tests {
+		if got := invoiceLimit(value); got != want {
+			t.Errorf("invoiceLimit(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestConvertStripeInvoiceSummary(t *testing.T) {
+	summary := convertStripeInvoiceSummary(&stripe.Invoice{
+		ID:               "in_1",
+		Number:           "ABC-0001",
+		Status:           stripe.InvoiceStatusPaid,
+		AmountDue:        999,
+		AmountPaid:       999,
+		Total:            999,
+		Currency:         stripe.CurrencyUSD,
+		HostedInvoiceURL: "https://invoice.example/in_1",
+		InvoicePDF:       "https://invoice.example/in_1.pdf",
+		PeriodStart:      1700000000,
+		PeriodEnd:        1702592000,
+	})
+	if summary.Status != "paid" || summary.Currency != "usd" || summary.InvoicePDF == "" {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if !summary.PeriodStart.Equal(time.Unix(1700000000, 0)) || !summary.Created.IsZero() {
+		t.Errorf("unexpected times: start %v, created %v", summary.PeriodStart, summary.Created)
+	}
+}
+
+func TestConvertStripeUpcomingInvoice(t *testing.T) {
+	upcoming := convertStripeUpcomingInvoice(&stripe.Invoice{
+		AmountDue:          1500,
+		Total:              1500,
+		Currency:           stripe.CurrencyEUR,
+		PeriodStart:        100,
+		PeriodEnd:          200,
+		NextPaymentAttempt: 300,
+		Lines: &stripe.InvoiceLineItemList{Data: []*stripe.InvoiceLineItem{
+			{Description: "Unused time on Basic", Amount: -400, Proration: true, Period: &stripe.Period{Start: 150, End: 300}},
+			{Description: "Remaining time on Pro", Amount: 900, Proration: true, Period: &stripe.Period{Start: 150, End: 300}},
+			{Description: "1 × Pro", Amount: 1000, Period: &stripe.Period{Start: 300, End: 400}},
+		}},
+	})
+
+	if upcoming.ProrationAmount != 500 {
+		t.Errorf("ProrationAmount = %d, want 500", upcoming.ProrationAmount)
+	}
+	if len(upcoming.Lines) != 3 || !upcoming.Lines[0].Proration || upcoming.Lines[2].Proration {
+		t.Errorf("unexpected lines: %+v", upcoming.Lines)
+	}
+	if upcoming.PeriodStart.Unix() != 300 || upcoming.PeriodEnd.Unix() != 400 {
+		t.Errorf("period = %v..%v, want the subscription line's period", upcoming.PeriodStart, upcoming.PeriodEnd)
+	}
+	if upcoming.NextPaymentAt == nil || upcoming.NextPaymentAt.Unix() != 300 {
+		t.Errorf("NextPaymentAt = %v", upcoming.NextPaymentAt)
+	}
+}
@@ -0,0 +1,381 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+	"pocketbase/internal/subscription"
+)
+
+// Refund case states in refunds
+const (
+	RefundPending  = "pending" // waiting for a superuser to approve or deny it
+	RefundRefunded = "refunded"
+	RefundDenied   = "denied"
+	RefundFailed   = "failed" // the provider rejected the refund; a superuser can approve it again
+)
+
+// refundDecidedByPolicy is recorded as decided_by on refunds issued without a review
+const refundDecidedByPolicy = "policy"
+
+// errRefundAlreadyRequested means the payment already has a refund case that wasn't denied
+var errRefundAlreadyRequested = errors.New("refund already requested")
+
+// RefundPolicy decides which refund requests are refunded straight away. The rest wait for review
+type RefundPolicy struct {
+	WindowDays   int     // days after payment a refund is automatic; 0 sends every request to review
+	MaxAutoHours float64 // hours transcribed this billing period above which a refund needs review
+}
+
+// refundPolicyFromEnv reads REFUND_AUTO_WINDOW_DAYS (default 14) and REFUND_AUTO_MAX_HOURS (default 1)
+func refundPolicyFromEnv() RefundPolicy {
+	policy := RefundPolicy{WindowDays: 14, MaxAutoHours: 1}
+	if v, err := strconv.Atoi(os.Getenv("REFUND_AUTO_WINDOW_DAYS")); err == nil && v >= 0 {
+		policy.WindowDays = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("REFUND_AUTO_MAX_HOURS"), 64); err == nil && v >= 0 {
+		policy.MaxAutoHours = v
+	}
+	return policy
+}
+
+// reviewReason returns why a refund of a payment made at paidAt needs a superuser's review, or ""
+// when it can be refunded automatically. Users refunded before always go to review
+func (p RefundPolicy) reviewReason(paidAt time.Time, hoursUsed float64, previousRefunds int, now time.Time) string {
+	switch {
+	case p.WindowDays == 0:
+		return "automatic refunds are turned off"
+	case now.Sub(paidAt) > time.Duration(p.WindowDays)*24*time.Hour:
+		return fmt.Sprintf("paid more than %d days ago", p.WindowDays)
+	case hoursUsed >= p.MaxAutoHours:
+		return fmt.Sprintf("%.2f hours transcribed this billing period, the automatic limit is %.2f", hoursUsed, p.MaxAutoHours)
+	case previousRefunds > 0:
+		return "refunded before"
+	}
+	return ""
+}
+
+// refundableInvoice picks the invoice to refund from the customer's history: invoiceID when set,
+// otherwise the most recent paid one. latest reports whether it's the most recent paid invoice,
+// i.e. the one paying for the current period
+func refundableInvoice(invoices []*InvoiceSummary, invoiceID string) (inv *InvoiceSummary, latest bool) {
+	first := true
+	for _, candidate := range invoices {
+		if candidate.Status != "paid" || candidate.AmountPaid <= 0 {
+			continue
+		}
+		if invoiceID == "" || candidate.ID == invoiceID {
+			return candidate, first
+		}
+		first = false
+	}
+	return nil, false
+}
+
+// invoicePaidAt is when an invoice was paid, falling back to when it was created
+func invoicePaidAt(inv *InvoiceSummary) time.Time {
+	if inv.PaidAt != nil {
+		return *inv.PaidAt
+	}
+	return inv.Created
+}
+
+// RefundInfo is a refund case as shown to its user and to admins
+type RefundInfo struct {
+	ID               string  `json:"id"`
+	UserID           string  `json:"user_id"`
+	InvoiceID        string  `json:"invoice_id"`
+	AmountCents      int     `json:"amount_cents"`
+	Currency         string  `json:"currency"`
+	Reason           string  `json:"reason,omitempty"`
+	Status           string  `json:"status"`
+	HoursUsed        float64 `json:"hours_used"`
+	ReviewReason     string  `json:"review_reason,omitempty"`
+	EndsSubscription bool    `json:"ends_subscription"`
+	DecidedBy        string  `json:"decided_by,omitempty"`
+	DecisionNote     string  `json:"decision_note,omitempty"`
+	ProviderRefundID string  `json:"provider_refund_id,omitempty"`
+	Error            string  `json:"error,omitempty"`
+	DecidedAt        string  `json:"decided_at,omitempty"`
+	Created          string  `json:"created"`
+}
+
+func refundInfo(record *core.Record) RefundInfo {
+	info := RefundInfo{
+		ID:               record.Id,
+		UserID:           record.GetString("user_id"),
+		InvoiceID:        record.GetString("provider_invoice_id"),
+		AmountCents:      record.GetInt("amount_cents"),
+		Currency:         record.GetString("currency"),
+		Reason:           record.GetString("reason"),
+		Status:           record.GetString("status"),
+		HoursUsed:        record.GetFloat("hours_used"),
+		ReviewReason:     record.GetString("review_reason"),
+		EndsSubscription: record.GetBool("ends_subscription"),
+		DecidedBy:        record.GetString("decided_by"),
+		DecisionNote:     record.GetString("decision_note"),
+		ProviderRefundID: record.GetString("provider_refund_id"),
+		Error:            record.GetString("error"),
+		Created:          record.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+	}
+	if t := record.GetDateTime("decided_at"); !t.IsZero() {
+		info.DecidedAt = t.Time().UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// issueRefund refunds a case through the provider and records the outcome. A refund the provider
+// made is recorded even if ending the subscription afterwards failed
+func issueRefund(app core.App, paymentService *Service, logger *slog.Logger, record *core.Record, decidedBy, note string) error {
+	refund, refundErr := paymentService.RefundInvoice(RefundParams{
+		InvoiceID:       record.GetString("provider_invoice_id"),
+		EndSubscription: record.GetBool("ends_subscription"),
+		Metadata:        map[string]string{"refund_case_id": record.Id, "user_id": record.GetString("user_id")},
+	})
+
+	record.Set("decided_by", decidedBy)
+	record.Set("decision_note", note)
+	record.Set("decided_at", time.Now())
+	if refund != nil {
+		record.Set("status", RefundRefunded)
+		record.Set("provider_refund_id", refund.ID)
+		record.Set("error", "")
+		if refundErr != nil {
+			record.Set("error", refundErr.Error())
+			logger.Error("Refund issued but the subscription wasn't ended", "refund_case_id", record.Id, "error", refundErr)
+		}
+	} else {
+		record.Set("status", RefundFailed)
+		record.Set("error", refundErr.Error())
+	}
+	if err := app.Save(record); err != nil {
+		return fmt.Errorf("failed to save refund case: %w", err)
+	}
+	if refund == nil {
+		return refundErr
+	}
+	return nil
+}
+
+// RefundRequestHandler opens a refund case for the caller's latest payment, or invoice_id. Requests
+// within the policy's window and usage limit are refunded at once, the rest wait for review.
+// Refunding the payment for the current period ends the subscription
+// POST /api/payment/refund-request
+func RefundRequestHandler(e *core.RequestEvent, app core.App, paymentService *Service) error {
+	if paymentService == nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+	}
+	user := e.Auth
+	if user == nil || user.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		InvoiceID string `json:"invoice_id"`
+		Reason    string `json:"reason"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if len(req.Reason) > 1000 {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "reason must be at most 1000 characters"})
+	}
+	logger := logging.FromRequest(e).With("user_id", user.Id)
+
+	customerID := findPaymentCustomerID(app, user.Id)
+	if customerID == "" {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "No payment to refund", "code": "NO_REFUNDABLE_PAYMENT"})
+	}
+	invoices, err := paymentService.ListInvoices(customerID, maxInvoiceLimit)
+	if err != nil {
+		logger.Error("Failed to list invoices for a refund", "error", err)
+		return e.JSON(http.StatusBadGateway, map[string]string{"error": "Failed to load invoices"})
+	}
+	inv, latest := refundableInvoice(invoices, req.InvoiceID)
+	if inv == nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "No payment to refund", "code": "NO_REFUNDABLE_PAYMENT"})
+	}
+
+	now := time.Now()
+	previousRefunds, _ := app.CountRecords("refunds", dbx.HashExp{"user_id": user.Id, "status": RefundRefunded})
+	reviewReason := "usage couldn't be checked"
+	hoursUsed, _, err := subscription.NewRepository(app).GetPeriodHoursUsed(user.Id, now)
+	if err == nil {
+		reviewReason = refundPolicyFromEnv().reviewReason(invoicePaidAt(inv), hoursUsed, int(previousRefunds), now)
+	} else {
+		logger.Warn("Failed to read usage for a refund, sending it to review", "error", err)
+	}
+
+	// The check and the insert share a transaction, so concurrent requests for the same payment
+	// can't both open a case and refund it twice
+	var record, existing *core.Record
+	err = app.RunInTransaction(func(txApp core.App) error {
+		found, err := txApp.FindFirstRecordByFilter("refunds", "provider_invoice_id = {:invoice} && status != {:denied}",
+			map[string]any{"invoice": inv.ID, "denied": RefundDenied})
+		if err == nil {
+			existing = found
+			return errRefundAlreadyRequested
+		}
+
+		collection, err := txApp.FindCollectionByNameOrId("refunds")
+		if err != nil {
+			return err
+		}
+		record = core.NewRecord(collection)
+		record.Set("user_id", user.Id)
+		record.Set("provider_invoice_id", inv.ID)
+		record.Set("amount_cents", inv.AmountPaid)
+		record.Set("currency", inv.Currency)
+		record.Set("reason", req.Reason)
+		record.Set("status", RefundPending)
+		record.Set("hours_used", hoursUsed)
+		record.Set("review_reason", reviewReason)
+		record.Set("ends_subscription", latest)
+		return txApp.Save(record)
+	})
+	if errors.Is(err, errRefundAlreadyRequested) {
+		return e.JSON(http.StatusConflict, map[string]any{
+			"error":  "A refund was already requested for this payment",
+			"code":   "REFUND_ALREADY_REQUESTED",
+			"refund": refundInfo(existing),
+		})
+	}
+	if err != nil {
+		logger.Error("Failed to save refund case", "invoice_id", inv.ID, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open refund case"})
+	}
+	logger = logger.With("refund_case_id", record.Id, "invoice_id", inv.ID)
+
+	if reviewReason == "" {
+		if err := issueRefund(app, paymentService, logger, record, refundDecidedByPolicy, ""); err != nil {
+			// Leave it to a superuser rather than telling the user no
+			logger.Error("Automatic refund failed, sending it to review", "error", err)
+			record.Set("status", RefundPending)
+			record.Set("review_reason", "automatic refund failed")
+			record.Set("decided_by", "")
+			record.Set("decided_at", nil)
+			if err := app.Save(record); err != nil {
+				logger.Error("Failed to save refund case", "error", err)
+			}
+		} else {
+			logger.Info("Refund issued automatically", "amount_cents", inv.AmountPaid, "ends_subscription", latest)
+		}
+	} else {
+		logger.Info("Refund request sent to review", "review_reason", reviewReason)
+	}
+
+	return e.JSON(http.StatusCreated, refundInfo(record))
+}
+
+// ListRefundsHandler returns the caller's refund cases, newest first
+// GET /api/payment/refunds
+func ListRefundsHandler(e *core.RequestEvent, app core.App) error {
+	if e.Auth == nil || e.Auth.Collection().Name != "users" {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+	records, err := app.FindRecordsByFilter("refunds", "user_id = {:user}", "-created", 100, 0, map[string]any{"user": e.Auth.Id})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load refunds"})
+	}
+	refunds := make([]RefundInfo, 0, len(records))
+	for _, record := range records {
+		refunds = append(refunds, refundInfo(record))
+	}
+	return e.JSON(http.StatusOK, map[string]any{"refunds": refunds})
+}
+
+// AdminListRefundsHandler lists the 100 most recent refund cases, pending ones by default (superusers only)
+// GET /api/admin/refunds?status=pending|refunded|denied|failed
+func AdminListRefundsHandler(e *core.RequestEvent, app core.App) error {
+	status := e.Request.URL.Query().Get("status")
+	if status == "" {
+		status = RefundPending
+	}
+	switch status {
+	case RefundPending, RefundRefunded, RefundDenied, RefundFailed:
+	default:
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "status must be pending, refunded, denied or failed"})
+	}
+
+	records, err := app.FindRecordsByFilter("refunds", "status = {:status}", "-created", 100, 0, map[string]any{"status": status})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load refunds"})
+	}
+	refunds := make([]RefundInfo, 0, len(records))
+	for _, record := range records {
+		refunds = append(refunds, refundInfo(record))
+	}
+	return e.JSON(http.StatusOK, map[string]any{"refunds": refunds})
+}
+
+// reviewableRefund loads a refund case a superuser can still decide on: pending, or failed at the provider
+func reviewableRefund(e *core.RequestEvent, app core.App) (*core.Record, error) {
+	record, err := app.FindRecordById("refunds", e.Request.PathValue("refundId"))
+	if err != nil {
+		return nil, e.JSON(http.StatusNotFound, map[string]string{"error": "Refund not found"})
+	}
+	if status := record.GetString("status"); status != RefundPending && status != RefundFailed {
+		return nil, e.JSON(http.StatusConflict, map[string]string{"error": "Refund is already " + status})
+	}
+	return record, nil
+}
+
+type refundDecision struct {
+	Note string `json:"note"`
+}
+
+// AdminApproveRefundHandler refunds a pending or failed case through the provider (superusers only)
+// POST /api/admin/refunds/{refundId}/approve
+func AdminApproveRefundHandler(e *core.RequestEvent, app core.App, paymentService *Service) error {
+	if paymentService == nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Payment service not available"})
+	}
+	var decision refundDecision
+	if err := e.BindBody(&decision); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	record, err := reviewableRefund(e, app)
+	if record == nil {
+		return err
+	}
+
+	logger := logging.FromRequest(e).With("refund_case_id", record.Id, "user_id", record.GetString("user_id"))
+	if err := issueRefund(app, paymentService, logger, record, e.Auth.Id, decision.Note); err != nil {
+		logger.Error("Approved refund failed", "error", err)
+		return e.JSON(http.StatusBadGateway, map[string]any{"error": "The payment provider rejected the refund", "refund": refundInfo(record)})
+	}
+	logger.Info("Admin approved refund", "approved_by", e.Auth.Id, "amount_cents", record.GetInt("amount_cents"))
+	return e.JSON(http.StatusOK, refundInfo(record))
+}
+
+// AdminDenyRefundHandler closes a pending or failed case without refunding it (superusers only)
+// POST /api/admin/refunds/{refundId}/deny
+func AdminDenyRefundHandler(e *core.RequestEvent, app core.App) error {
+	var decision refundDecision
+	if err := e.BindBody(&decision); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	record, err := reviewableRefund(e, app)
+	if record == nil {
+		return err
+	}
+
+	record.Set("status", RefundDenied)
+	record.Set("decided_by", e.Auth.Id)
+	record.Set("decision_note", decision.Note)
+	record.Set("decided_at", time.Now())
+	if err := app.Save(record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to save refund"})
+	}
+	logging.FromRequest(e).Info("Admin denied refund", "refund_case_id", record.Id, "denied_by", e.Auth.Id)
+	return e.JSON(http.StatusOK, refundInfo(record))
+}
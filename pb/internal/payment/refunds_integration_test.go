@@ -0,0 +1,131 @@
+//go:build integration
+
+package payment_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+
+	"pocketbase/internal/payment"
+	"pocketbase/internal/routes"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+// refundProvider bills one paid invoice and counts the refunds made of it
+type refundProvider struct {
+	payment.Provider
+	mu      sync.Mutex
+	refunds int
+}
+
+func (p *refundProvider) ListInvoices(_ string, _ int64) ([]*payment.InvoiceSummary, error) {
+	paidAt := time.Now().Add(-time.Hour)
+	return []*payment.InvoiceSummary{{ID: "in_1", Status: "paid", AmountPaid: 1500, Currency: "usd", PaidAt: &paidAt, Created: paidAt}}, nil
+}
+
+func (p *refundProvider) RefundInvoice(params payment.RefundParams) (*payment.Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refunds++
+	return &payment.Refund{ID: "re_1", InvoiceID: params.InvoiceID, Amount: 1500}, nil
+}
+
+// refundCustomer creates a user billed through the provider and serves the payment routes
+func refundCustomer(t *testing.T, app *tests.TestApp, provider payment.Provider) (*core.Record, *testharness.Server) {
+	t.Helper()
+	user := testharness.CreateUser(t, app, "refund@test.com")
+	customers, err := app.FindCollectionByNameOrId("payment_customers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	customer := core.NewRecord(customers)
+	customer.Set("user_id", user.Id)
+	customer.Set("provider_customer_id", "cus_1")
+	if err := app.Save(customer); err != nil {
+		t.Fatal(err)
+	}
+
+	return user, testharness.Serve(t, app, func(r *routes.Registry) {
+		payment.RegisterRoutes(r, payment.NewService(provider, payment.Config{}))
+	})
+}
+
+func TestRefundRequestedConcurrently(t *testing.T) {
+	app := testharness.New(t)
+	provider := &refundProvider{}
+	user, server := refundCustomer(t, app, provider)
+	token := testharness.AuthToken(t, user)
+
+	// Widen the window between a request's duplicate check and its insert
+	app.OnRecordCreate("refunds").BindFunc(func(e *core.RecordEvent) error {
+		time.Sleep(100 * time.Millisecond)
+		return e.Next()
+	})
+
+	const requests = 3
+	statuses := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses <- server.Do(t, http.MethodPost, "/api/payment/refund-request", token, map[string]string{"reason": "not for me"}).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	created := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("status = %d, want 201 or 409", status)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d requests opened a case, want 1", created)
+	}
+	if total, _ := app.CountRecords("refunds"); total != 1 {
+		t.Errorf("%d refund cases, want 1", total)
+	}
+	if provider.refunds != 1 {
+		t.Errorf("refunded %d times, want once", provider.refunds)
+	}
+}
+
+func TestRefundAboveUsageLimitGoesToReview(t *testing.T) {
+	t.Setenv("REFUND_AUTO_MAX_HOURS", "1")
+	app := testharness.New(t)
+	provider := &refundProvider{}
+	user, server := refundCustomer(t, app, provider)
+
+	now := time.Now()
+	period := subscription.CurrentUsagePeriod(app, user.Id, now)
+	usage, err := subscription.NewUsageRecord(app, user.Id, "", period)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage.Set("hours_used", 4)
+	usage.Set("last_processing_date", now)
+	if err := app.Save(usage); err != nil {
+		t.Fatal(err)
+	}
+
+	refund := server.Do(t, http.MethodPost, "/api/payment/refund-request", testharness.AuthToken(t, user), map[string]string{"reason": "not for me"}).
+		Expect(t, http.StatusCreated).JSON(t)
+	if refund["status"] != payment.RefundPending || refund["hours_used"] != 4.0 {
+		t.Errorf("refund = %v, want a pending case with 4 hours used", refund)
+	}
+	if provider.refunds != 0 {
+		t.Errorf("refunded %d times, want the case left to review", provider.refunds)
+	}
+}
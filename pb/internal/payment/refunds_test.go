@@ -0,0 +1,53 @@
+package payment
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefundPolicyReviewReason(t *testing.T) {
+	now := time.Date(2026, 5, 20, 12, 0, 0, 0, time.UTC)
+	policy := RefundPolicy{WindowDays: 14, MaxAutoHours: 1}
+
+	tests := []struct {
+		name            string
+		policy          RefundPolicy
+		paidAt          time.Time
+		hoursUsed       float64
+		previousRefunds int
+		wantReview      bool
+	}{
+		{"recent and unused", policy, now.AddDate(0, 0, -3), 0.5, 0, false},
+		{"last day of the window", policy, now.AddDate(0, 0, -14), 0, 0, false},
+		{"outside the window", policy, now.AddDate(0, 0, -15), 0, 0, true},
+		{"used an hour", policy, now.AddDate(0, 0, -1), 1, 0, true},
+		{"refunded before", policy, now.AddDate(0, 0, -1), 0, 1, true},
+		{"automatic refunds off", RefundPolicy{MaxAutoHours: 1}, now, 0, 0, true},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.reviewReason(tt.paidAt, tt.hoursUsed, tt.previousRefunds, now); (got != "") != tt.wantReview {
+			t.Errorf("%s: reviewReason() = %q, want review %v", tt.name, got, tt.wantReview)
+		}
+	}
+}
+
+func TestRefundableInvoice(t *testing.T) {
+	invoices := []*InvoiceSummary{
+		{ID: "in_open", Status: "open", AmountDue: 1500},
+		{ID: "in_new", Status: "paid", AmountPaid: 1500},
+		{ID: "in_free", Status: "paid", AmountPaid: 0},
+		{ID: "in_old", Status: "paid", AmountPaid: 1500},
+	}
+
+	if inv, latest := refundableInvoice(invoices, ""); inv == nil || inv.ID != "in_new" || !latest {
+		t.Errorf("refundableInvoice() = %v, %v; want the newest paid invoice", inv, latest)
+	}
+	if inv, latest := refundableInvoice(invoices, "in_old"); inv == nil || inv.ID != "in_old" || latest {
+		t.Errorf("refundableInvoice(in_old) = %v, %v; want an older invoice", inv, latest)
+	}
+	for _, id := range []string{"in_open", "in_free", "in_missing"} {
+		if inv, _ := refundableInvoice(invoices, id); inv != nil {
+			t.Errorf("refundableInvoice(%s) = %s, want nothing to refund", id, inv.ID)
+		}
+	}
+}
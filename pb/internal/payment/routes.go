@@ -0,0 +1,91 @@
+package payment
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the payment routes (provider-agnostic - Stripe is the only provider for now)
+func RegisterRoutes(r *routes.Registry, paymentService *Service) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/payment/checkout", Handler: func(e *core.RequestEvent) error {
+			return CreateCheckoutSessionHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Start a checkout for a plan, or change plan directly with a saved card"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/payment/portal", Handler: func(e *core.RequestEvent) error {
+			return CreatePortalLinkHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Create a billing portal link"}},
+		// Check if user has valid payment methods for direct plan changes
+		routes.Route{Method: http.MethodGet, Path: "/api/payment/check-method", Handler: func(e *core.RequestEvent) error {
+			return CheckPaymentMethodHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Check for a saved payment method", Response: PaymentMethodStatus{}}},
+		// Promotion code check before checkout - rate limited so codes can't be guessed
+		routes.Route{Method: http.MethodPost, Path: "/api/payment/validate-coupon", RateLimited: true, Handler: func(e *core.RequestEvent) error {
+			return ValidateCouponHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Check a promotion code against a plan"}},
+		// Billing history and next-charge preview
+		routes.Route{Method: http.MethodGet, Path: "/api/payment/invoices", Handler: func(e *core.RequestEvent) error {
+			return ListInvoicesHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "List past invoices"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/payment/upcoming-invoice", Handler: func(e *core.RequestEvent) error {
+			return UpcomingInvoiceHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Preview the next invoice"}},
+		// Refund requests - refunded at once within the policy, otherwise queued for review
+		routes.Route{Method: http.MethodPost, Path: "/api/payment/refund-request", RateLimited: true, Handler: func(e *core.RequestEvent) error {
+			return RefundRequestHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Request a refund of an invoice", Response: RefundInfo{}, Status: http.StatusCreated}},
+		routes.Route{Method: http.MethodGet, Path: "/api/payment/refunds", Handler: func(e *core.RequestEvent) error {
+			return ListRefundsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List the caller's refund requests"}},
+
+		// Business profile for VAT/GST compliant invoices
+		routes.Route{Method: http.MethodGet, Path: "/api/billing/profile", Handler: func(e *core.RequestEvent) error {
+			return GetBusinessProfileHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Get the business profile printed on invoices"}},
+		routes.Route{Method: http.MethodPut, Path: "/api/billing/profile", Handler: func(e *core.RequestEvent) error {
+			return UpdateBusinessProfileHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Update the business profile", Request: BusinessProfileRequest{}}},
+		// Billing address and tax IDs from checkout or the business profile, as invoices use them
+		routes.Route{Method: http.MethodGet, Path: "/api/billing/tax-details", Handler: func(e *core.RequestEvent) error {
+			return GetTaxDetailsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Get the billing address and tax IDs invoices use"}},
+
+		// Payment webhook routes - verified by their signature in the handler
+		// IMPORTANT: When adding/removing webhook endpoints, update README.md payment provider section
+		routes.Route{Method: http.MethodPost, Path: "/api/webhooks/stripe", Unversioned: true, Handler: func(e *core.RequestEvent) error {
+			return paymentService.HandleWebhook(e, app)
+		}},
+
+		// Dead-lettered webhook events and rejected deliveries (superusers only)
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/webhook-events/rejections", Auth: routes.AuthSuperuser, Handler: WebhookRejectionsHandler, Doc: &routes.Doc{Summary: "Recently rejected webhook deliveries"}},
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/webhook-events", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ListWebhookEventsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List dead-lettered webhook events"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/webhook-events/{eventId}/retry", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return RetryWebhookEventHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Retry a dead-lettered webhook event", Response: WebhookEventInfo{}}},
+
+		// Refund review queue (superusers only)
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/refunds", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AdminListRefundsHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List refund requests awaiting review"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/refunds/{refundId}/approve", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AdminApproveRefundHandler(e, app, paymentService)
+		}, Doc: &routes.Doc{Summary: "Approve and pay out a refund", Response: RefundInfo{}}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/refunds/{refundId}/deny", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AdminDenyRefundHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Deny a refund", Response: RefundInfo{}}},
+	)
+
+	// Synthetic Stripe events for local development, processed like real webhooks
+	if os.Getenv("DEVELOPMENT") == "true" {
+		r.Add(routes.Route{Method: http.MethodPost, Path: "/api/dev/simulate-webhook", Handler: func(e *core.RequestEvent) error {
+			return SimulateWebhookHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "Process a synthetic Stripe event (development only)", Request: SimulateWebhookRequest{}}})
+	}
+}
@@ -0,0 +1,37 @@
+package payment
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+	"pocketbase/internal/subscription"
+)
+
+// sandboxBillingPeriod is how long a plan "bought" by a sandbox tenant stays active
+const sandboxBillingPeriod = 30 * 24 * time.Hour
+
+// activateSandboxPlan stands in for checkout for sandbox tenants: the plan is assigned directly with
+// the sandbox payment provider and the response points at the same success page a real checkout would
+func activateSandboxPlan(e *core.RequestEvent, app core.App, user *core.Record, plan *core.Record) error {
+	logger := logging.FromRequest(e)
+	service := subscription.NewService(subscription.NewRepository(app)).WithLogger(logger)
+	if _, err := service.AssignPlan(user.Id, plan.Id, time.Now().Add(sandboxBillingPeriod), "sandbox"); err != nil {
+		logger.Error("Failed to activate sandbox plan", "plan_id", plan.Id, "user_id", user.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to activate sandbox plan"})
+	}
+
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"url":     frontendURL + "/pricing?success=true&sandbox=true",
+		"reused":  false,
+		"sandbox": true,
+	})
+}
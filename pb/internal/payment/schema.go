@@ -0,0 +1,106 @@
+package payment
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the payment handlers read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "payment",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "payment_customers",
+				Fields: []schemacheck.Field{
+					{Name: "provider_customer_id", Type: "text"},
+					{Name: "user_id", Type: "relation"},
+					{Name: "tax_country", Type: "text"},
+					{Name: "billing_address", Type: "json"},
+					{Name: "tax_ids", Type: "json"},
+					{Name: "tax_exempt", Type: "text"},
+					{Name: "tax_details_updated", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_customers_user_id", Unique: true, Columns: "user_id"},
+				},
+			},
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "provider_price_id", Type: "text"},
+					{Name: "align_billing_to_month_start", Type: "bool"},
+					{Name: "trial_days", Type: "number"},
+				},
+			},
+			{
+				Collection: "payment_webhook_events",
+				Fields: []schemacheck.Field{
+					{Name: "event_id", Type: "text"},
+					{Name: "provider", Type: "text"},
+					{Name: "event_type", Type: "text"},
+					{Name: "status", Type: "select", Values: []string{"processing", "processed", "failed"}},
+					{Name: "event", Type: "json"},
+					{Name: "error", Type: "text"},
+					{Name: "attempts", Type: "number"},
+					{Name: "last_attempt_at", Type: "date"},
+					{Name: "processed_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_payment_webhook_events_event_id", Unique: true, Columns: "event_id"},
+				},
+			},
+			{
+				Collection: "refunds",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "provider_invoice_id", Type: "text"},
+					{Name: "amount_cents", Type: "number"},
+					{Name: "currency", Type: "text"},
+					{Name: "reason", Type: "text"},
+					{Name: "status", Type: "select", Values: []string{RefundPending, RefundRefunded, RefundDenied, RefundFailed}},
+					{Name: "hours_used", Type: "number"},
+					{Name: "review_reason", Type: "text"},
+					{Name: "ends_subscription", Type: "bool"},
+					{Name: "decided_by", Type: "text"},
+					{Name: "decision_note", Type: "text"},
+					{Name: "provider_refund_id", Type: "text"},
+					{Name: "error", Type: "text"},
+					{Name: "decided_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_refunds_user", Columns: "user_id, created"},
+					{Name: "idx_refunds_invoice", Columns: "provider_invoice_id"},
+					{Name: "idx_refunds_status", Columns: "status, created"},
+				},
+			},
+			{
+				Collection: "checkout_sessions",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "plan_id", Type: "relation"},
+					{Name: "provider_session_id", Type: "text"},
+					{Name: "url", Type: "text"},
+					{Name: "status", Type: "select", Values: []string{"open", "completed", "expired"}},
+					{Name: "expires_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_checkout_sessions_provider_session_id", Unique: true, Columns: "provider_session_id"},
+				},
+			},
+			{
+				Collection: "business_profiles",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "company_name", Type: "text"},
+					{Name: "vat_id", Type: "text"},
+					{Name: "tax_id_type", Type: "text"},
+					{Name: "provider_tax_id", Type: "text"},
+					{Name: "address_line1", Type: "text"},
+					{Name: "address_line2", Type: "text"},
+					{Name: "city", Type: "text"},
+					{Name: "postal_code", Type: "text"},
+					{Name: "state", Type: "text"},
+					{Name: "country", Type: "text"},
+				},
+			},
+		},
+	}
+}
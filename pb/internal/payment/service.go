@@ -2,7 +2,7 @@ package payment
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
@@ -10,8 +10,13 @@ import (
 	billingportal "github.com/stripe/stripe-go/v79/billingportal/session"
 	checkoutsession "github.com/stripe/stripe-go/v79/checkout/session"
 	"github.com/stripe/stripe-go/v79/customer"
+	"github.com/stripe/stripe-go/v79/invoice"
 	"github.com/stripe/stripe-go/v79/paymentmethod"
+	"github.com/stripe/stripe-go/v79/promotioncode"
+	"github.com/stripe/stripe-go/v79/refund"
 	"github.com/stripe/stripe-go/v79/subscription"
+	"github.com/stripe/stripe-go/v79/subscriptionschedule"
+	"github.com/stripe/stripe-go/v79/taxid"
 	"github.com/stripe/stripe-go/v79/webhook"
 )
 
@@ -25,7 +30,7 @@ func NewStripeService() (*Service, error) {
 	}
 	
 	if webhookSecret == "" {
-		log.Printf("Warning: STRIPE_SECRET_WHSEC not set - webhook verification will be disabled")
+		slog.Warn("STRIPE_SECRET_WHSEC not set - webhooks will be rejected until it is")
 	}
 
 	// Create Stripe provider using a factory function approach
@@ -83,6 +88,44 @@ func (p *stripeProviderImpl) CreateCheckoutSession(params CheckoutSessionParams)
 		stripeParams.AllowPromotionCodes = stripe.Bool(true)
 	}
 
+	// Trials start without a card; Stripe cancels the subscription at trial end if none was added
+	if params.TrialDays > 0 {
+		stripeParams.PaymentMethodCollection = stripe.String(string(stripe.CheckoutSessionPaymentMethodCollectionIfRequired))
+		stripeParams.SubscriptionData = &stripe.CheckoutSessionSubscriptionDataParams{
+			TrialPeriodDays: stripe.Int64(params.TrialDays),
+			Metadata:        map[string]string{trialMetadataKey: "true"},
+			TrialSettings: &stripe.CheckoutSessionSubscriptionDataTrialSettingsParams{
+				EndBehavior: &stripe.CheckoutSessionSubscriptionDataTrialSettingsEndBehaviorParams{
+					MissingPaymentMethod: stripe.String("cancel"),
+				},
+			},
+		}
+	}
+
+	// Anchored subscriptions charge a prorated amount up to the anchor, then renew on it
+	if params.BillingCycleAnchor > 0 {
+		stripeParams.SubscriptionData = &stripe.CheckoutSessionSubscriptionDataParams{
+			BillingCycleAnchor: stripe.Int64(params.BillingCycleAnchor),
+			ProrationBehavior:  stripe.String("create_prorations"),
+		}
+	}
+
+	if params.ExpiresAt > 0 {
+		stripeParams.ExpiresAt = stripe.Int64(params.ExpiresAt)
+	}
+
+	// Stripe Tax needs the customer's address; what checkout collects is saved on the customer so
+	// renewals are taxed and invoiced the same way
+	if params.CollectTax {
+		stripeParams.AutomaticTax = &stripe.CheckoutSessionAutomaticTaxParams{Enabled: stripe.Bool(true)}
+		stripeParams.BillingAddressCollection = stripe.String(string(stripe.CheckoutSessionBillingAddressCollectionRequired))
+		stripeParams.TaxIDCollection = &stripe.CheckoutSessionTaxIDCollectionParams{Enabled: stripe.Bool(true)}
+		stripeParams.CustomerUpdate = &stripe.CheckoutSessionCustomerUpdateParams{
+			Address: stripe.String("auto"),
+			Name:    stripe.String("auto"),
+		}
+	}
+
 	// Add metadata
 	stripeParams.Metadata = map[string]string{
 		"user_id": params.UserID,
@@ -99,10 +142,19 @@ func (p *stripeProviderImpl) CreateCheckoutSession(params CheckoutSessionParams)
 		URL:        session.URL,
 		CustomerID: session.Customer.ID,
 		Status:     string(session.Status),
+		ExpiresAt:  time.Unix(session.ExpiresAt, 0),
 		Metadata:   session.Metadata,
 	}, nil
 }
 
+// ExpireCheckoutSession closes an open checkout session so it can no longer be paid
+func (p *stripeProviderImpl) ExpireCheckoutSession(sessionID string) error {
+	if _, err := checkoutsession.Expire(sessionID, nil); err != nil {
+		return fmt.Errorf("failed to expire checkout session: %w", err)
+	}
+	return nil
+}
+
 func (p *stripeProviderImpl) CreateBillingPortalLink(customerID string, returnURL string) (*PortalLink, error) {
 	params := &stripe.BillingPortalSessionParams{
 		Customer:  stripe.String(customerID),
@@ -119,6 +171,17 @@ func (p *stripeProviderImpl) CreateBillingPortalLink(customerID string, returnUR
 	}, nil
 }
 
+// GetSubscription retrieves a Stripe subscription
+func (p *stripeProviderImpl) GetSubscription(subscriptionID string) (*Subscription, error) {
+	sub, err := subscription.Get(subscriptionID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+	return p.convertStripeSubscription(sub), nil
+}
+
+// ChangeSubscriptionPlan moves the subscription to a new price. With "always_invoice" Stripe
+// raises the proration invoice as part of the update, and its total is returned as ProrationAmount
 func (p *stripeProviderImpl) ChangeSubscriptionPlan(subscriptionID string, newPriceID string, prorationBehavior string) (*Subscription, error) {
 	// Get current subscription to modify items
 	sub, err := subscription.Get(subscriptionID, nil)
@@ -140,12 +203,107 @@ func (p *stripeProviderImpl) ChangeSubscriptionPlan(subscriptionID string, newPr
 		},
 		ProrationBehavior: stripe.String(prorationBehavior),
 	}
+	params.AddExpand("latest_invoice")
 
 	updatedSub, err := subscription.Update(subscriptionID, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update subscription: %w", err)
 	}
 
+	result := p.convertStripeSubscription(updatedSub)
+	if invoice := updatedSub.LatestInvoice; invoice != nil && invoice.BillingReason == stripe.InvoiceBillingReasonSubscriptionUpdate {
+		result.ProrationAmount = &invoice.Total
+	}
+	return result, nil
+}
+
+// SchedulePlanChange switches the subscription to a new price when the current period ends
+// A subscription schedule keeps the current price until the period end, then releases the
+// subscription on the new price; the renewal webhook syncs the local plan.
+func (p *stripeProviderImpl) SchedulePlanChange(subscriptionID string, newPriceID string) (*Subscription, error) {
+	sub, err := subscription.Get(subscriptionID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	if len(sub.Items.Data) == 0 {
+		return nil, fmt.Errorf("subscription has no items")
+	}
+	item := sub.Items.Data[0]
+
+	// Reuse an existing schedule - a subscription can only be attached to one
+	var schedule *stripe.SubscriptionSchedule
+	if sub.Schedule != nil && sub.Schedule.ID != "" {
+		schedule, err = subscriptionschedule.Get(sub.Schedule.ID, nil)
+	} else {
+		schedule, err = subscriptionschedule.New(&stripe.SubscriptionScheduleParams{
+			FromSubscription: stripe.String(subscriptionID),
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscription schedule: %w", err)
+	}
+	if schedule.CurrentPhase == nil {
+		return nil, fmt.Errorf("subscription schedule has no current phase")
+	}
+
+	params := &stripe.SubscriptionScheduleParams{
+		EndBehavior: stripe.String("release"),
+		Phases: []*stripe.SubscriptionSchedulePhaseParams{
+			{
+				Items: []*stripe.SubscriptionSchedulePhaseItemParams{
+					{Price: stripe.String(item.Price.ID), Quantity: stripe.Int64(item.Quantity)},
+				},
+				StartDate: stripe.Int64(schedule.CurrentPhase.StartDate),
+				EndDate:   stripe.Int64(schedule.CurrentPhase.EndDate),
+			},
+			{
+				Items: []*stripe.SubscriptionSchedulePhaseItemParams{
+					{Price: stripe.String(newPriceID), Quantity: stripe.Int64(item.Quantity)},
+				},
+				Iterations: stripe.Int64(1),
+			},
+		},
+	}
+
+	if _, err := subscriptionschedule.Update(schedule.ID, params); err != nil {
+		return nil, fmt.Errorf("failed to schedule plan change: %w", err)
+	}
+
+	return p.convertStripeSubscription(sub), nil
+}
+
+// PauseSubscription stops invoicing a subscription without cancelling it
+// Invoices created while paused are voided, so the customer isn't charged for the paused time
+func (p *stripeProviderImpl) PauseSubscription(subscriptionID string, resumesAt time.Time) (*Subscription, error) {
+	params := &stripe.SubscriptionParams{
+		PauseCollection: &stripe.SubscriptionPauseCollectionParams{
+			Behavior: stripe.String("void"),
+		},
+	}
+	if !resumesAt.IsZero() {
+		params.PauseCollection.ResumesAt = stripe.Int64(resumesAt.Unix())
+	}
+
+	updatedSub, err := subscription.Update(subscriptionID, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause subscription: %w", err)
+	}
+
+	return p.convertStripeSubscription(updatedSub), nil
+}
+
+// ResumeSubscription resumes invoicing of a paused subscription
+func (p *stripeProviderImpl) ResumeSubscription(subscriptionID string) (*Subscription, error) {
+	params := &stripe.SubscriptionParams{}
+	// Stripe clears pause_collection when it is sent as an empty string
+	params.AddExtra("pause_collection", "")
+
+	updatedSub, err := subscription.Update(subscriptionID, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume subscription: %w", err)
+	}
+
 	return p.convertStripeSubscription(updatedSub), nil
 }
 
@@ -153,15 +311,18 @@ func (p *stripeProviderImpl) CancelSubscription(subscriptionID string, cancelAtP
 	if cancelAtPeriodEnd {
 		// Set to cancel at period end
 		params := &stripe.SubscriptionParams{
-			}
+			CancelAtPeriodEnd: stripe.Bool(true),
+		}
 		updatedSub, err := subscription.Update(subscriptionID, params)
 		if err != nil {
 			return nil, fmt.Errorf("failed to schedule cancellation: %w", err)
 		}
 		return p.convertStripeSubscription(updatedSub), nil
 	} else {
-		// Cancel immediately
-		canceledSub, err := subscription.Cancel(subscriptionID, nil)
+		// Cancel immediately, crediting the unused time
+		canceledSub, err := subscription.Cancel(subscriptionID, &stripe.SubscriptionCancelParams{
+			Prorate: stripe.Bool(true),
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to cancel subscription: %w", err)
 		}
@@ -169,6 +330,20 @@ func (p *stripeProviderImpl) CancelSubscription(subscriptionID string, cancelAtP
 	}
 }
 
+// ExtendSubscription pushes the next renewal out to until without charging for the extra time.
+// Stripe models this as a trial that ends at the new renewal date
+func (p *stripeProviderImpl) ExtendSubscription(subscriptionID string, until time.Time) (*Subscription, error) {
+	params := &stripe.SubscriptionParams{
+		TrialEnd:          stripe.Int64(until.Unix()),
+		ProrationBehavior: stripe.String("none"),
+	}
+	updatedSub, err := subscription.Update(subscriptionID, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend subscription: %w", err)
+	}
+	return p.convertStripeSubscription(updatedSub), nil
+}
+
 func (p *stripeProviderImpl) CreateCustomer(params CustomerParams) (*Customer, error) {
 	stripeParams := &stripe.CustomerParams{
 		Email: stripe.String(params.Email),
@@ -212,6 +387,59 @@ func (p *stripeProviderImpl) GetCustomer(customerID string) (*Customer, error) {
 	}, nil
 }
 
+func (p *stripeProviderImpl) UpdateCustomerBusinessProfile(customerID string, params BusinessProfileParams) (*BusinessProfileResult, error) {
+	// Stripe renders the customer name and address on every invoice it generates
+	customerParams := &stripe.CustomerParams{
+		Name: stripe.String(params.CompanyName),
+		Address: &stripe.AddressParams{
+			Line1:      stripe.String(params.AddressLine1),
+			Line2:      stripe.String(params.AddressLine2),
+			City:       stripe.String(params.City),
+			PostalCode: stripe.String(params.PostalCode),
+			State:      stripe.String(params.State),
+			Country:    stripe.String(params.Country),
+		},
+	}
+	if _, err := customer.Update(customerID, customerParams); err != nil {
+		return nil, fmt.Errorf("failed to update customer: %w", err)
+	}
+
+	result := &BusinessProfileResult{}
+
+	// Tax IDs are immutable in Stripe, so the previous one is removed and replaced
+	if params.PreviousTaxID != "" {
+		if _, err := taxid.Del(params.PreviousTaxID, &stripe.TaxIDParams{Customer: stripe.String(customerID)}); err != nil {
+			slog.Warn("Failed to delete previous tax ID", "tax_id", params.PreviousTaxID, "error", err)
+		}
+	}
+	if params.TaxIDValue == "" {
+		return result, nil
+	}
+
+	taxID, err := taxid.New(&stripe.TaxIDParams{
+		Customer: stripe.String(customerID),
+		Type:     stripe.String(params.TaxIDType),
+		Value:    stripe.String(params.TaxIDValue),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tax ID: %w", err)
+	}
+	result.TaxID = taxID.ID
+
+	return result, nil
+}
+
+func (p *stripeProviderImpl) UpdateCustomerMetadata(customerID string, metadata map[string]string) error {
+	params := &stripe.CustomerParams{}
+	for key, value := range metadata {
+		params.AddMetadata(key, value)
+	}
+	if _, err := customer.Update(customerID, params); err != nil {
+		return fmt.Errorf("failed to update customer metadata: %w", err)
+	}
+	return nil
+}
+
 func (p *stripeProviderImpl) HasValidPaymentMethod(customerID string) (*PaymentMethodStatus, error) {
 	// List all payment methods for the customer
 	params := &stripe.PaymentMethodListParams{
@@ -276,13 +504,149 @@ func (p *stripeProviderImpl) HasValidPaymentMethod(customerID string) (*PaymentM
 	}, nil
 }
 
+// ListInvoices returns the customer's most recent invoices, newest first
+func (p *stripeProviderImpl) ListInvoices(customerID string, limit int64) ([]*InvoiceSummary, error) {
+	params := &stripe.InvoiceListParams{
+		Customer: stripe.String(customerID),
+	}
+	params.Limit = stripe.Int64(limit)
+	params.Single = true // one page - the newest invoices only
+
+	iter := invoice.List(params)
+	invoices := []*InvoiceSummary{}
+	for iter.Next() {
+		invoices = append(invoices, convertStripeInvoiceSummary(iter.Invoice()))
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list invoices: %w", err)
+	}
+	return invoices, nil
+}
+
+// GetUpcomingInvoice previews the subscription's next invoice. With NewPriceID it previews
+// switching the first subscription item to that price, including the proration lines
+func (p *stripeProviderImpl) GetUpcomingInvoice(params UpcomingInvoiceParams) (*UpcomingInvoice, error) {
+	upcomingParams := &stripe.InvoiceUpcomingParams{
+		Customer:     stripe.String(params.CustomerID),
+		Subscription: stripe.String(params.SubscriptionID),
+	}
+
+	if params.NewPriceID != "" {
+		sub, err := subscription.Get(params.SubscriptionID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get subscription: %w", err)
+		}
+		if len(sub.Items.Data) == 0 {
+			return nil, fmt.Errorf("subscription has no items")
+		}
+		upcomingParams.SubscriptionItems = []*stripe.SubscriptionItemsParams{
+			{
+				ID:    stripe.String(sub.Items.Data[0].ID),
+				Price: stripe.String(params.NewPriceID),
+			},
+		}
+		upcomingParams.SubscriptionProrationBehavior = stripe.String("create_prorations")
+		if params.ProrationDate > 0 {
+			upcomingParams.SubscriptionProrationDate = stripe.Int64(params.ProrationDate)
+		}
+	}
+
+	inv, err := invoice.Upcoming(upcomingParams)
+	if err != nil {
+		if stripeErr, ok := err.(*stripe.Error); ok && stripeErr.Code == stripe.ErrorCodeInvoiceUpcomingNone {
+			return nil, ErrNoUpcomingInvoice
+		}
+		return nil, fmt.Errorf("failed to preview upcoming invoice: %w", err)
+	}
+	return convertStripeUpcomingInvoice(inv), nil
+}
+
+// RefundInvoice refunds the payment of a paid invoice in full. Ending the subscription after
+// the refund is best effort: the refund is returned along with the cancellation error
+func (p *stripeProviderImpl) RefundInvoice(params RefundParams) (*Refund, error) {
+	inv, err := invoice.Get(params.InvoiceID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	refundParams := &stripe.RefundParams{
+		Reason:   stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+		Metadata: params.Metadata,
+	}
+	switch {
+	case inv.PaymentIntent != nil:
+		refundParams.PaymentIntent = stripe.String(inv.PaymentIntent.ID)
+	case inv.Charge != nil:
+		refundParams.Charge = stripe.String(inv.Charge.ID)
+	default:
+		return nil, fmt.Errorf("invoice %s has no payment to refund", params.InvoiceID)
+	}
+
+	r, err := refund.New(refundParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund invoice: %w", err)
+	}
+	result := &Refund{
+		ID:        r.ID,
+		InvoiceID: inv.ID,
+		Amount:    r.Amount,
+		Currency:  string(r.Currency),
+		Status:    string(r.Status),
+	}
+
+	if params.EndSubscription && inv.Subscription != nil {
+		sub, err := subscription.Get(inv.Subscription.ID, nil)
+		if err != nil {
+			return result, fmt.Errorf("refunded, but failed to get the subscription to end: %w", err)
+		}
+		if sub.Status != stripe.SubscriptionStatusCanceled {
+			if _, err := subscription.Cancel(sub.ID, &stripe.SubscriptionCancelParams{Prorate: stripe.Bool(false)}); err != nil {
+				return result, fmt.Errorf("refunded, but failed to end the subscription: %w", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetPromotionCode looks up a promotion code by the code customers type. Codes are only unique
+// among active codes, so an active match wins over inactive ones with the same code
+func (p *stripeProviderImpl) GetPromotionCode(code string) (*PromotionCode, error) {
+	params := &stripe.PromotionCodeListParams{
+		Code: stripe.String(code),
+	}
+	params.AddExpand("data.coupon.applies_to")
+	params.Limit = stripe.Int64(10)
+	params.Single = true
+
+	iter := promotioncode.List(params)
+	var found *stripe.PromotionCode
+	for iter.Next() {
+		promo := iter.PromotionCode()
+		if found == nil || (promo.Active && !found.Active) {
+			found = promo
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up promotion code: %w", err)
+	}
+	if found == nil {
+		return nil, ErrPromotionCodeNotFound
+	}
+	return convertStripePromotionCode(found), nil
+}
+
 func (p *stripeProviderImpl) ParseWebhookEvent(payload []byte, signature string) (*WebhookEvent, error) {
-	// Verify webhook signature
+	// Verify webhook signature and timestamp - an empty secret would accept anything signed with ""
+	tolerance := WebhookTolerance()
+	if err := verifyStripeSignature(payload, signature, p.webhookSecret, tolerance, time.Now()); err != nil {
+		return nil, err
+	}
 	event, err := webhook.ConstructEventWithOptions(payload, signature, p.webhookSecret, webhook.ConstructEventOptions{
+		Tolerance:                tolerance,
 		IgnoreAPIVersionMismatch: true,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("webhook signature verification failed: %w", err)
+		return nil, &WebhookRejection{Reason: RejectMalformedEvent, Err: err}
 	}
 
 	// Create the payment webhook event
@@ -323,6 +687,17 @@ func (p *stripeProviderImpl) ParseWebhookEvent(payload []byte, signature string)
 				t := time.Unix(canceledAt, 0)
 				webhookEvent.Data.Subscription.CanceledAt = &t
 			}
+			if trialEnd := getInt64FromMap(data, "trial_end"); trialEnd > 0 {
+				t := time.Unix(trialEnd, 0)
+				webhookEvent.Data.Subscription.TrialEnd = &t
+			}
+			if pause := getMapFromMap(data, "pause_collection"); pause != nil {
+				webhookEvent.Data.Subscription.Paused = true
+				if resumesAt := getInt64FromMap(pause, "resumes_at"); resumesAt > 0 {
+					t := time.Unix(resumesAt, 0)
+					webhookEvent.Data.Subscription.ResumesAt = &t
+				}
+			}
 			
 
 			// Get price ID from items
@@ -337,14 +712,15 @@ func (p *stripeProviderImpl) ParseWebhookEvent(payload []byte, signature string)
 			}
 		}
 
-	case "checkout.session.completed":
+	case "checkout.session.completed", "checkout.session.expired":
 		if data := event.Data.Object; data != nil {
 			webhookEvent.Data.CheckoutSession = &CheckoutSession{
-				ID:         getStringFromMap(data, "id"),
-				URL:        getStringFromMap(data, "url"),
-				CustomerID: getStringFromMap(data, "customer"),
-				Status:     getStringFromMap(data, "status"),
-				Metadata:   getStringMapFromMap(data, "metadata"),
+				ID:              getStringFromMap(data, "id"),
+				URL:             getStringFromMap(data, "url"),
+				CustomerID:      getStringFromMap(data, "customer"),
+				Status:          getStringFromMap(data, "status"),
+				Metadata:        getStringMapFromMap(data, "metadata"),
+				CustomerDetails: customerTaxDetailsFromMap(data),
 			}
 		}
 
@@ -404,10 +780,29 @@ func (p *stripeProviderImpl) convertStripeSubscription(stripeSub *stripe.Subscri
 		sub.CanceledAt = &canceledAt
 	}
 
+	if stripeSub.TrialEnd > 0 {
+		trialEnd := time.Unix(stripeSub.TrialEnd, 0)
+		sub.TrialEnd = &trialEnd
+	}
+
+	if stripeSub.PauseCollection != nil {
+		sub.Paused = true
+		if stripeSub.PauseCollection.ResumesAt > 0 {
+			resumesAt := time.Unix(stripeSub.PauseCollection.ResumesAt, 0)
+			sub.ResumesAt = &resumesAt
+		}
+	}
 
 	// Extract price ID from subscription items
 	if stripeSub.Items != nil && len(stripeSub.Items.Data) > 0 {
-		sub.PriceID = stripeSub.Items.Data[0].Price.ID
+		price := stripeSub.Items.Data[0].Price
+		sub.PriceID = price.ID
+		if price.Product != nil {
+			sub.ProductID = price.Product.ID
+		}
+		if price.Recurring != nil {
+			sub.Interval = string(price.Recurring.Interval)
+		}
 	}
 
 	return sub
@@ -431,7 +826,7 @@ func (p *stripeProviderImpl) convertSubscriptionStatus(stripeStatus stripe.Subsc
 	case stripe.SubscriptionStatusUnpaid:
 		return SubscriptionStatusUnpaid
 	default:
-		log.Printf("Unknown Stripe subscription status: %s, defaulting to active", stripeStatus)
+		slog.Warn("Unknown Stripe subscription status, defaulting to active", "status", stripeStatus)
 		return SubscriptionStatusActive
 	}
 }
@@ -0,0 +1,222 @@
+package payment
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"pocketbase/internal/logging"
+)
+
+// Event types the webhook simulator can emit
+var simulatedEventTypes = map[string]bool{
+	"customer.subscription.created": true,
+	"customer.subscription.updated": true,
+	"customer.subscription.deleted": true,
+	"invoice.payment_succeeded":     true,
+	"invoice.payment_failed":        true,
+}
+
+// simulatedIDPrefix marks the IDs of customers, subscriptions, invoices and events made up by
+// the simulator, so they're never mistaken for Stripe's
+const simulatedIDPrefix = "sim_"
+
+// SimulateWebhookRequest describes a synthetic Stripe event
+type SimulateWebhookRequest struct {
+	Type           string `json:"type"`
+	UserID         string `json:"user_id"`         // defaults to the signed-in user
+	PlanID         string `json:"plan_id"`         // plan the subscription bills for; defaults to the current one
+	SubscriptionID string `json:"subscription_id"` // defaults to the user's current subscription, or a new ID on created
+	Status         string `json:"status"`          // subscription status; active by default, canceled on deleted
+	TrialDays      int    `json:"trial_days"`      // puts the subscription in trial until then
+	PeriodStart    string `json:"period_start"`    // RFC3339, defaults to now
+}
+
+// simulatedEvent builds the WebhookEvent Stripe would send for a request. current is the
+// user's subscription record, if any, and plan the plan it should bill for
+func simulatedEvent(req SimulateWebhookRequest, customerID string, current, plan *core.Record, now time.Time) (*WebhookEvent, error) {
+	if !simulatedEventTypes[req.Type] {
+		return nil, fmt.Errorf("unsupported event type %q", req.Type)
+	}
+
+	subscriptionID := req.SubscriptionID
+	if subscriptionID == "" && current != nil && req.Type != "customer.subscription.created" {
+		subscriptionID = current.GetString("provider_subscription_id")
+	}
+	if subscriptionID == "" {
+		if req.Type != "customer.subscription.created" {
+			return nil, fmt.Errorf("the user has no subscription - simulate customer.subscription.created first or pass subscription_id")
+		}
+		subscriptionID = "sub_" + simulatedIDPrefix + security.RandomString(14)
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("plan_id is required when the user has no subscription")
+	}
+
+	start := now
+	if req.PeriodStart != "" {
+		parsed, err := time.Parse(time.RFC3339, req.PeriodStart)
+		if err != nil {
+			return nil, fmt.Errorf("period_start must be RFC3339: %w", err)
+		}
+		start = parsed
+	}
+	interval := plan.GetString("billing_interval")
+	end := start.AddDate(0, 1, 0)
+	if interval == "year" {
+		end = start.AddDate(1, 0, 0)
+	}
+
+	event := &WebhookEvent{
+		ID:           "evt_" + simulatedIDPrefix + security.RandomString(20),
+		Type:         req.Type,
+		Created:      now,
+		ProviderType: ProviderStripe,
+	}
+
+	switch req.Type {
+	case "invoice.payment_succeeded", "invoice.payment_failed":
+		invoice := &Invoice{
+			ID:             "in_" + simulatedIDPrefix + security.RandomString(14),
+			CustomerID:     customerID,
+			SubscriptionID: &subscriptionID,
+			Status:         "open",
+			Total:          int64(plan.GetInt("price_cents")),
+			Currency:       plan.GetString("currency"),
+			Metadata:       map[string]string{"simulated": "true"},
+		}
+		if req.Type == "invoice.payment_succeeded" {
+			invoice.Status = "paid"
+			invoice.PaidAt = &now
+		}
+		event.Data.Invoice = invoice
+		return event, nil
+	}
+
+	status := SubscriptionStatus(req.Status)
+	switch {
+	case req.Type == "customer.subscription.deleted":
+		status = SubscriptionStatusCanceled
+	case status == "" && req.TrialDays > 0:
+		status = SubscriptionStatusTrialing
+	case status == "":
+		status = SubscriptionStatusActive
+	}
+	sub := &Subscription{
+		ID:                 subscriptionID,
+		CustomerID:         customerID,
+		Status:             status,
+		CurrentPeriodStart: start,
+		CurrentPeriodEnd:   end,
+		PriceID:            plan.GetString("provider_price_id"),
+		ProductID:          plan.GetString("provider_product_id"),
+		Interval:           interval,
+		Metadata:           map[string]string{"simulated": "true"},
+	}
+	if req.TrialDays > 0 {
+		trialEnd := start.AddDate(0, 0, req.TrialDays)
+		sub.TrialEnd = &trialEnd
+	}
+	if status == SubscriptionStatusCanceled {
+		sub.CanceledAt = &now
+	}
+	event.Data.Subscription = sub
+	return event, nil
+}
+
+// simulatedCustomerID returns the user's payment customer, mapping a made-up one if they
+// never went through checkout
+func simulatedCustomerID(app core.App, userID string) (string, error) {
+	if customer, err := app.FindFirstRecordByFilter("payment_customers", "user_id = {:user_id}", map[string]any{"user_id": userID}); err == nil {
+		return customer.GetString("provider_customer_id"), nil
+	}
+
+	collection, err := app.FindCollectionByNameOrId("payment_customers")
+	if err != nil {
+		return "", err
+	}
+	record := core.NewRecord(collection)
+	record.Set("user_id", userID)
+	record.Set("provider_customer_id", "cus_"+simulatedIDPrefix+userID)
+	if err := app.Save(record); err != nil {
+		return "", err
+	}
+	return record.GetString("provider_customer_id"), nil
+}
+
+// SimulateWebhookHandler emits a synthetic Stripe subscription or invoice event through the same
+// processing as real webhooks, minus the signature, so subscription flows (downgrades,
+// cancellations, failed payments) can be exercised without the Stripe CLI. Development only
+// POST /api/dev/simulate-webhook
+func SimulateWebhookHandler(e *core.RequestEvent, app core.App) error {
+	if os.Getenv("DEVELOPMENT") != "true" {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Not found"})
+	}
+
+	var req SimulateWebhookRequest
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.UserID == "" && e.Auth != nil && e.Auth.Collection().Name == "users" {
+		req.UserID = e.Auth.Id
+	}
+	if req.UserID == "" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "user_id is required"})
+	}
+	if _, err := app.FindRecordById("users", req.UserID); err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "User not found"})
+	}
+
+	current, _ := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user_id}", map[string]any{"user_id": req.UserID})
+	var plan *core.Record
+	switch {
+	case req.PlanID != "":
+		found, err := app.FindRecordById("subscription_plans", req.PlanID)
+		if err != nil {
+			return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
+		}
+		plan = found
+	case current != nil:
+		plan, _ = app.FindRecordById("subscription_plans", current.GetString("plan_id"))
+	}
+
+	customerID, err := simulatedCustomerID(app, req.UserID)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to map a payment customer"})
+	}
+
+	event, err := simulatedEvent(req, customerID, current, plan, time.Now())
+	if err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	logger := logging.FromRequest(e).With("provider", "simulator", "event_id", event.ID, "event_type", event.Type, "user_id", req.UserID)
+	logger.Info("Processing simulated webhook event")
+	result, processErr := processWebhookEvent(app, logger, event)
+
+	response := map[string]any{
+		"event_id": event.ID,
+		"type":     event.Type,
+		"status":   result,
+		"event":    event,
+	}
+	if processErr != nil {
+		response["error"] = processErr.Error()
+	}
+	if sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user_id}", map[string]any{"user_id": req.UserID}); err == nil {
+		response["subscription"] = map[string]any{
+			"id":                       sub.Id,
+			"plan_id":                  sub.GetString("plan_id"),
+			"status":                   sub.GetString("status"),
+			"provider_subscription_id": sub.GetString("provider_subscription_id"),
+			"current_period_end":       sub.GetDateTime("current_period_end"),
+		}
+	} else {
+		response["subscription"] = nil
+	}
+	return e.JSON(http.StatusOK, response)
+}
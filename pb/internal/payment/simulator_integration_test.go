@@ -0,0 +1,75 @@
+//go:build integration
+
+package payment_test
+
+import (
+	"net/http"
+	"testing"
+
+	"pocketbase/internal/payment"
+	"pocketbase/internal/routes"
+	"pocketbase/internal/testharness"
+)
+
+func TestSimulatedSubscriptionLifecycle(t *testing.T) {
+	t.Setenv("DEVELOPMENT", "true")
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	user := testharness.CreateUser(t, app, "subscriber@test.com")
+	token := testharness.AuthToken(t, user)
+
+	server := testharness.Serve(t, app, func(r *routes.Registry) {
+		payment.RegisterRoutes(r, nil)
+	})
+	// simulate returns the user's current subscription after the event, nil for none
+	simulate := func(body map[string]any) map[string]any {
+		t.Helper()
+		response := server.Do(t, http.MethodPost, "/api/dev/simulate-webhook", token, body).Expect(t, http.StatusOK).JSON(t)
+		if response["status"] != "processed" {
+			t.Fatalf("%v: status %v (%v), want processed", body["type"], response["status"], response["error"])
+		}
+		subscription, _ := response["subscription"].(map[string]any)
+		return subscription
+	}
+
+	created := simulate(map[string]any{"type": "customer.subscription.created", "plan_id": plans["pro"].Id})
+	if created["plan_id"] != plans["pro"].Id || created["status"] != "active" {
+		t.Errorf("after created: %v, want an active pro subscription", created)
+	}
+
+	upgraded := simulate(map[string]any{"type": "customer.subscription.updated", "plan_id": plans["pro_yearly"].Id})
+	if upgraded["plan_id"] != plans["pro_yearly"].Id || upgraded["provider_subscription_id"] != created["provider_subscription_id"] {
+		t.Errorf("after updated: %v, want the same subscription on pro_yearly", upgraded)
+	}
+
+	pastDue := simulate(map[string]any{"type": "customer.subscription.updated", "status": "past_due"})
+	if pastDue["status"] != "past_due" {
+		t.Errorf("after payment trouble: %v, want past_due", pastDue)
+	}
+
+	simulate(map[string]any{"type": "invoice.payment_succeeded"})
+	if total, _ := app.CountRecords("payment_webhook_events"); total != 4 {
+		t.Errorf("recorded %d webhook events, want 4", total)
+	}
+
+	// Cancelled subscriptions go to history, leaving the user on the free plan
+	if canceled := simulate(map[string]any{"type": "customer.subscription.deleted"}); canceled != nil {
+		t.Errorf("after deleted: %v, want no current subscription", canceled)
+	}
+	history, err := app.FindRecordsByFilter("subscription_history", "user_id = {:user}", "", 0, 0, map[string]any{"user": user.Id})
+	if err != nil || len(history) != 2 {
+		t.Errorf("history has %d entries (%v), want the pro and pro_yearly periods", len(history), err)
+	}
+}
+
+func TestSimulatorRequiresDevelopment(t *testing.T) {
+	t.Setenv("DEVELOPMENT", "false")
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "subscriber@test.com")
+
+	server := testharness.Serve(t, app, func(r *routes.Registry) {
+		payment.RegisterRoutes(r, nil)
+	})
+	server.Do(t, http.MethodPost, "/api/dev/simulate-webhook", testharness.AuthToken(t, user), map[string]any{"type": "customer.subscription.created"}).
+		Expect(t, http.StatusNotFound)
+}
@@ -0,0 +1,113 @@
+package payment
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func simulatorPlan(interval string) *core.Record {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(
+		&core.TextField{Name: "billing_interval"},
+		&core.TextField{Name: "provider_price_id"},
+		&core.TextField{Name: "provider_product_id"},
+		&core.TextField{Name: "currency"},
+		&core.NumberField{Name: "price_cents"},
+	)
+	plan := core.NewRecord(collection)
+	plan.Set("billing_interval", interval)
+	plan.Set("provider_price_id", "price_pro_"+interval)
+	plan.Set("provider_product_id", "prod_pro")
+	plan.Set("currency", "usd")
+	plan.Set("price_cents", 1500)
+	return plan
+}
+
+func simulatorSubscription(providerID string) *core.Record {
+	collection := core.NewBaseCollection("current_user_subscriptions")
+	collection.Fields.Add(&core.TextField{Name: "provider_subscription_id"})
+	sub := core.NewRecord(collection)
+	sub.Set("provider_subscription_id", providerID)
+	return sub
+}
+
+func TestSimulatedSubscriptionEvents(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	current := simulatorSubscription("sub_existing")
+
+	created, err := simulatedEvent(SimulateWebhookRequest{Type: "customer.subscription.created", TrialDays: 7}, "cus_1", current, simulatorPlan("year"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := created.Data.Subscription
+	if sub == nil || !strings.HasPrefix(sub.ID, "sub_"+simulatedIDPrefix) {
+		t.Fatalf("created subscription = %+v, want a new simulated ID", sub)
+	}
+	if sub.Status != SubscriptionStatusTrialing || sub.TrialEnd == nil || !sub.TrialEnd.Equal(now.AddDate(0, 0, 7)) {
+		t.Errorf("created status = %s, trial end %v; want trialing for 7 days", sub.Status, sub.TrialEnd)
+	}
+	if !sub.CurrentPeriodEnd.Equal(now.AddDate(1, 0, 0)) || sub.PriceID != "price_pro_year" || sub.CustomerID != "cus_1" {
+		t.Errorf("created = %+v, want a yearly period on the plan's price", sub)
+	}
+	if !strings.HasPrefix(created.ID, "evt_"+simulatedIDPrefix) || created.ProviderType != ProviderStripe {
+		t.Errorf("event ID %s / provider %s, want a simulated Stripe event", created.ID, created.ProviderType)
+	}
+
+	deleted, err := simulatedEvent(SimulateWebhookRequest{Type: "customer.subscription.deleted"}, "cus_1", current, simulatorPlan("month"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub := deleted.Data.Subscription; sub.ID != "sub_existing" || sub.Status != SubscriptionStatusCanceled || sub.CanceledAt == nil {
+		t.Errorf("deleted = %+v, want the current subscription cancelled", sub)
+	}
+
+	updated, err := simulatedEvent(SimulateWebhookRequest{Type: "customer.subscription.updated", Status: "past_due"}, "cus_1", current, simulatorPlan("month"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub := updated.Data.Subscription; sub.Status != SubscriptionStatusPastDue || !sub.CurrentPeriodEnd.Equal(now.AddDate(0, 1, 0)) {
+		t.Errorf("updated = %+v, want past_due for a month", sub)
+	}
+}
+
+func TestSimulatedInvoiceEvents(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	current := simulatorSubscription("sub_existing")
+
+	paid, err := simulatedEvent(SimulateWebhookRequest{Type: "invoice.payment_succeeded"}, "cus_1", current, simulatorPlan("month"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice := paid.Data.Invoice; invoice == nil || invoice.Status != "paid" || invoice.PaidAt == nil || *invoice.SubscriptionID != "sub_existing" || invoice.Total != 1500 {
+		t.Errorf("paid invoice = %+v", invoice)
+	}
+
+	failed, err := simulatedEvent(SimulateWebhookRequest{Type: "invoice.payment_failed"}, "cus_1", current, simulatorPlan("month"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invoice := failed.Data.Invoice; invoice.Status != "open" || invoice.PaidAt != nil {
+		t.Errorf("failed invoice = %+v, want an open unpaid invoice", invoice)
+	}
+}
+
+func TestSimulatedEventErrors(t *testing.T) {
+	now := time.Now()
+	tests := map[string]SimulateWebhookRequest{
+		"unsupported type":        {Type: "charge.refunded"},
+		"no subscription":         {Type: "customer.subscription.updated"},
+		"invalid period start":    {Type: "customer.subscription.created", PeriodStart: "yesterday"},
+		"invoice without current": {Type: "invoice.payment_failed"},
+	}
+	for name, req := range tests {
+		if _, err := simulatedEvent(req, "cus_1", nil, simulatorPlan("month"), now); err == nil {
+			t.Errorf("%s: simulatedEvent() succeeded, want an error", name)
+		}
+	}
+	if _, err := simulatedEvent(SimulateWebhookRequest{Type: "customer.subscription.created"}, "cus_1", nil, nil, now); err == nil {
+		t.Error("created without a plan: simulatedEvent() succeeded, want an error")
+	}
+}
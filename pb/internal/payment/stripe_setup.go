@@ -2,7 +2,7 @@ package payment
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/stripe/stripe-go/v79"
 	"github.com/stripe/stripe-go/v79/price"
@@ -41,11 +41,23 @@ func (s *StripeSetup) CreateProductAndPrice(name string, priceCents int64, inter
 		return nil, fmt.Errorf("failed to create product %s: %w", name, err)
 	}
 
-	log.Printf("Created Stripe product: %s (ID: %s)", name, stripeProduct.ID)
+	slog.Info("Created Stripe product", "name", name, "product_id", stripeProduct.ID)
 
-	// Create the price
+	priceID, err := s.CreatePrice(stripeProduct.ID, name, priceCents, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProductAndPriceResult{
+		ProductID: stripeProduct.ID,
+		PriceID:   priceID,
+	}, nil
+}
+
+// CreatePrice adds a price billed every interval to an existing Stripe product
+func (s *StripeSetup) CreatePrice(productID string, name string, priceCents int64, interval string) (string, error) {
 	priceParams := &stripe.PriceParams{
-		Product:    stripe.String(stripeProduct.ID),
+		Product:    stripe.String(productID),
 		UnitAmount: stripe.Int64(priceCents),
 		Currency:   stripe.String("usd"),
 		Metadata: map[string]string{
@@ -62,39 +74,11 @@ func (s *StripeSetup) CreateProductAndPrice(name string, priceCents int64, inter
 
 	stripePrice, err := price.New(priceParams)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create price for product %s: %w", name, err)
+		return "", fmt.Errorf("failed to create price for product %s: %w", name, err)
 	}
 
-	log.Printf("Created Stripe price: %s for %s (ID: %s)", 
-		fmt.Sprintf("$%.2f/%s", float64(priceCents)/100, interval), name, stripePrice.ID)
+	slog.Info("Created Stripe price", "name", name, "price_id", stripePrice.ID,
+		"price", fmt.Sprintf("$%.2f/%s", float64(priceCents)/100, interval))
 
-	return &ProductAndPriceResult{
-		ProductID: stripeProduct.ID,
-		PriceID:   stripePrice.ID,
-	}, nil
+	return stripePrice.ID, nil
 }
-
-// SetupDefaultProductsAndPrices creates the default products and prices needed by the application
-func (s *StripeSetup) SetupDefaultProductsAndPrices() (map[string]*ProductAndPriceResult, error) {
-	results := make(map[string]*ProductAndPriceResult)
-
-	plans := []struct {
-		Key      string
-		Name     string
-		Price    int64
-		Interval string
-	}{
-		{"basic", "Basic Plan", 700, "month"},   // $7/month
-		{"pro", "Pro Plan", 1500, "month"},     // $15/month
-	}
-
-	for _, plan := range plans {
-		result, err := s.CreateProductAndPrice(plan.Name, plan.Price, plan.Interval)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create plan %s: %w", plan.Key, err)
-		}
-		results[plan.Key] = result
-	}
-
-	return results, nil
-}
\ No newline at end of file
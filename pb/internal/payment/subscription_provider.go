@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"time"
+
+	"pocketbase/internal/subscription"
+)
+
+// SubscriptionProvider adapts the service's provider to what the subscription service needs, so
+// plan changes, cancellations and support actions go through whichever provider is configured
+func (s *Service) SubscriptionProvider() subscription.PaymentProvider {
+	return subscriptionProvider{provider: s.provider}
+}
+
+type subscriptionProvider struct {
+	provider Provider
+}
+
+func (p subscriptionProvider) CancelSubscription(subID string) error {
+	_, err := p.provider.CancelSubscription(subID, false)
+	return err
+}
+
+func (p subscriptionProvider) ChangeSubscriptionPrice(subID string, priceID string) (*int64, error) {
+	sub, err := p.provider.ChangeSubscriptionPlan(subID, priceID, "always_invoice")
+	if err != nil {
+		return nil, err
+	}
+	return sub.ProrationAmount, nil
+}
+
+func (p subscriptionProvider) GetSubscription(subID string) (*subscription.ProviderSubscription, error) {
+	sub, err := p.provider.GetSubscription(subID)
+	if err != nil {
+		return nil, err
+	}
+	return toProviderSubscription(sub), nil
+}
+
+func (p subscriptionProvider) ExtendSubscription(subID string, until time.Time) (*subscription.ProviderSubscription, error) {
+	sub, err := p.provider.ExtendSubscription(subID, until)
+	if err != nil {
+		return nil, err
+	}
+	return toProviderSubscription(sub), nil
+}
+
+func (p subscriptionProvider) PauseSubscription(subID string, resumesAt time.Time) (*subscription.ProviderSubscription, error) {
+	sub, err := p.provider.PauseSubscription(subID, resumesAt)
+	if err != nil {
+		return nil, err
+	}
+	return toProviderSubscription(sub), nil
+}
+
+func (p subscriptionProvider) ResumeSubscription(subID string) (*subscription.ProviderSubscription, error) {
+	sub, err := p.provider.ResumeSubscription(subID)
+	if err != nil {
+		return nil, err
+	}
+	return toProviderSubscription(sub), nil
+}
+
+func toProviderSubscription(sub *Subscription) *subscription.ProviderSubscription {
+	return &subscription.ProviderSubscription{
+		ID:                 sub.ID,
+		CustomerID:         sub.CustomerID,
+		Status:             string(sub.Status),
+		PriceID:            sub.PriceID,
+		ProductID:          sub.ProductID,
+		Interval:           sub.Interval,
+		CurrentPeriodStart: sub.CurrentPeriodStart,
+		CurrentPeriodEnd:   sub.CurrentPeriodEnd,
+		CanceledAt:         sub.CanceledAt,
+		TrialEnd:           sub.TrialEnd,
+		Paused:             sub.Paused,
+		ResumesAt:          sub.ResumesAt,
+		Metadata:           sub.Metadata,
+	}
+}
@@ -0,0 +1,115 @@
+package payment
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// stripeTaxEnabled reports whether STRIPE_TAX_ENABLED=true: checkout then calculates tax with
+// Stripe Tax and asks for a billing address and, optionally, a VAT/GST ID. Stripe Tax must be
+// set up (origin address, registrations) in the dashboard first
+func stripeTaxEnabled() bool {
+	return os.Getenv("STRIPE_TAX_ENABLED") == "true"
+}
+
+// TaxID is a customer's tax identifier, e.g. an EU VAT number
+type TaxID struct {
+	Type  string `json:"type"` // provider tax ID type, e.g. "eu_vat"
+	Value string `json:"value"`
+}
+
+// BillingAddress is the address tax is calculated and invoices are addressed to
+type BillingAddress struct {
+	Line1      string `json:"line1,omitempty"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city,omitempty"`
+	PostalCode string `json:"postal_code,omitempty"`
+	State      string `json:"state,omitempty"`
+	Country    string `json:"country,omitempty"` // ISO 3166-1 alpha-2
+}
+
+// CustomerTaxDetails are the billing details a customer gave at checkout
+type CustomerTaxDetails struct {
+	Name      string         `json:"name,omitempty"`
+	Address   BillingAddress `json:"address"`
+	TaxExempt string         `json:"tax_exempt,omitempty"` // none, exempt or reverse (reverse charge)
+	TaxIDs    []TaxID        `json:"tax_ids"`
+}
+
+// customerTaxDetailsFromMap reads a checkout session's customer_details, nil if it has none
+func customerTaxDetailsFromMap(data map[string]interface{}) *CustomerTaxDetails {
+	details, ok := data["customer_details"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	result := &CustomerTaxDetails{
+		Name:      getStringFromMap(details, "name"),
+		TaxExempt: getStringFromMap(details, "tax_exempt"),
+		TaxIDs:    []TaxID{},
+	}
+	if address, ok := details["address"].(map[string]interface{}); ok {
+		result.Address = BillingAddress{
+			Line1:      getStringFromMap(address, "line1"),
+			Line2:      getStringFromMap(address, "line2"),
+			City:       getStringFromMap(address, "city"),
+			PostalCode: getStringFromMap(address, "postal_code"),
+			State:      getStringFromMap(address, "state"),
+			Country:    getStringFromMap(address, "country"),
+		}
+	}
+	if taxIDs, ok := details["tax_ids"].([]interface{}); ok {
+		for _, item := range taxIDs {
+			if taxID, ok := item.(map[string]interface{}); ok && getStringFromMap(taxID, "value") != "" {
+				result.TaxIDs = append(result.TaxIDs, TaxID{
+					Type:  getStringFromMap(taxID, "type"),
+					Value: getStringFromMap(taxID, "value"),
+				})
+			}
+		}
+	}
+	return result
+}
+
+// saveCustomerTaxDetails stores a customer's billing address and tax IDs on payment_customers
+func saveCustomerTaxDetails(app core.App, customerID string, details *CustomerTaxDetails) error {
+	customer, err := app.FindFirstRecordByFilter("payment_customers", "provider_customer_id = {:id}", map[string]any{"id": customerID})
+	if err != nil {
+		return fmt.Errorf("payment customer %s not found: %w", customerID, err)
+	}
+	customer.Set("tax_country", details.Address.Country)
+	customer.Set("billing_address", details.Address)
+	customer.Set("tax_ids", details.TaxIDs)
+	if details.TaxExempt != "" {
+		customer.Set("tax_exempt", details.TaxExempt)
+	}
+	customer.Set("tax_details_updated", time.Now())
+	return app.Save(customer)
+}
+
+// GetTaxDetailsHandler returns the billing address and tax IDs stored for the caller, as invoices
+// are generated with them
+// GET /api/billing/tax-details
+func GetTaxDetailsHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	response := map[string]any{"tax_enabled": stripeTaxEnabled(), "details": nil}
+	customer, err := app.FindFirstRecordByFilter("payment_customers", "user_id = {:user_id}", map[string]any{"user_id": user.Id})
+	if err != nil || customer.GetDateTime("tax_details_updated").IsZero() {
+		return e.JSON(http.StatusOK, response)
+	}
+
+	details := CustomerTaxDetails{TaxExempt: customer.GetString("tax_exempt"), TaxIDs: []TaxID{}}
+	_ = customer.UnmarshalJSONField("billing_address", &details.Address)
+	_ = customer.UnmarshalJSONField("tax_ids", &details.TaxIDs)
+	response["details"] = details
+	response["updated"] = customer.GetDateTime("tax_details_updated").Time().UTC().Format(time.RFC3339)
+	return e.JSON(http.StatusOK, response)
+}
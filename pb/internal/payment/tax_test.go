@@ -0,0 +1,38 @@
+package payment
+
+import "testing"
+
+func TestCustomerTaxDetailsFromMap(t *testing.T) {
+	session := map[string]interface{}{
+		"id": "cs_1",
+		"customer_details": map[string]interface{}{
+			"name":       "Acme GmbH",
+			"tax_exempt": "reverse",
+			"address": map[string]interface{}{
+				"line1":       "Hauptstr. 1",
+				"city":        "Berlin",
+				"postal_code": "10115",
+				"country":     "DE",
+			},
+			"tax_ids": []interface{}{
+				map[string]interface{}{"type": "eu_vat", "value": "DE123456789"},
+				map[string]interface{}{"type": "eu_vat", "value": ""},
+			},
+		},
+	}
+
+	details := customerTaxDetailsFromMap(session)
+	if details == nil {
+		t.Fatal("customerTaxDetailsFromMap() = nil, want the customer details")
+	}
+	if details.Address.Country != "DE" || details.Address.City != "Berlin" || details.TaxExempt != "reverse" {
+		t.Errorf("details = %+v, want a reverse-charge German address", details)
+	}
+	if len(details.TaxIDs) != 1 || details.TaxIDs[0] != (TaxID{Type: "eu_vat", Value: "DE123456789"}) {
+		t.Errorf("tax IDs = %+v, want the one VAT ID with a value", details.TaxIDs)
+	}
+
+	if details := customerTaxDetailsFromMap(map[string]interface{}{"id": "cs_2"}); details != nil {
+		t.Errorf("customerTaxDetailsFromMap() = %+v for a session without customer details, want nil", details)
+	}
+}
@@ -0,0 +1,32 @@
+package payment
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// trialMetadataKey is set on subscriptions created with a trial so webhooks record trial_end
+const trialMetadataKey = subscription.TrialMetadataKey
+
+// TrialDays returns the free trial a checkout for plan should start with: the plan's trial_days,
+// as long as the user has never had a Stripe subscription - trials are once per account
+func TrialDays(app core.App, plan *core.Record, userID string) int64 {
+	days := int64(plan.GetInt("trial_days"))
+	if days <= 0 || hadStripeSubscription(app, userID) {
+		return 0
+	}
+	return days
+}
+
+// hadStripeSubscription reports whether the user has, or ever had, a Stripe subscription
+func hadStripeSubscription(app core.App, userID string) bool {
+	params := map[string]any{"user": userID}
+	for _, collection := range []string{"current_user_subscriptions", "subscription_history"} {
+		if _, err := app.FindFirstRecordByFilter(collection,
+			"user_id = {:user} && payment_provider = 'stripe' && provider_subscription_id != ''", params); err == nil {
+			return true
+		}
+	}
+	return false
+}
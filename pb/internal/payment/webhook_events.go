@@ -0,0 +1,178 @@
+package payment
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+)
+
+// Webhook event states in payment_webhook_events
+const (
+	WebhookEventProcessing = "processing"
+	WebhookEventProcessed  = "processed"
+	WebhookEventFailed     = "failed" // dead-lettered until Stripe redelivers it or an admin retries it
+)
+
+// webhookProcessingTimeout is how long a delivery may hold an event before a redelivery takes over
+const webhookProcessingTimeout = 5 * time.Minute
+
+// claimWebhookEvent records a delivery and reports whether it's a duplicate: the event was already
+// processed, or another delivery is processing it right now. Failed events are attempted again
+func claimWebhookEvent(app core.App, event *WebhookEvent) (*core.Record, bool, error) {
+	record, err := app.FindFirstRecordByFilter("payment_webhook_events", "event_id = {:id}", map[string]any{"id": event.ID})
+	if err == nil {
+		if webhookEventInProgress(record, time.Now()) {
+			return record, true, nil
+		}
+		return record, false, startWebhookAttempt(app, record)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	collection, err := app.FindCollectionByNameOrId("payment_webhook_events")
+	if err != nil {
+		return nil, false, err
+	}
+	record = core.NewRecord(collection)
+	record.Set("event_id", event.ID)
+	record.Set("provider", string(event.ProviderType))
+	record.Set("event_type", event.Type)
+	record.Set("event", event)
+	if err := startWebhookAttempt(app, record); err != nil {
+		// The unique event_id index rejects a concurrent delivery of the same event
+		if _, findErr := app.FindFirstRecordByFilter("payment_webhook_events", "event_id = {:id}", map[string]any{"id": event.ID}); findErr == nil {
+			return nil, true, nil
+		}
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+// webhookEventInProgress reports whether an event is processed, or being processed by a live delivery
+func webhookEventInProgress(record *core.Record, now time.Time) bool {
+	switch record.GetString("status") {
+	case WebhookEventProcessed:
+		return true
+	case WebhookEventProcessing:
+		return now.Sub(record.GetDateTime("last_attempt_at").Time()) < webhookProcessingTimeout
+	default:
+		return false
+	}
+}
+
+func startWebhookAttempt(app core.App, record *core.Record) error {
+	record.Set("status", WebhookEventProcessing)
+	record.Set("attempts", record.GetInt("attempts")+1)
+	record.Set("last_attempt_at", time.Now())
+	return app.Save(record)
+}
+
+// finishWebhookEvent stores the outcome of an attempt. Failed events keep their error for the retry endpoint
+func finishWebhookEvent(app core.App, record *core.Record, processErr error) {
+	if record == nil {
+		return
+	}
+	if processErr != nil {
+		record.Set("status", WebhookEventFailed)
+		record.Set("error", processErr.Error())
+	} else {
+		record.Set("status", WebhookEventProcessed)
+		record.Set("error", "")
+		record.Set("processed_at", time.Now())
+	}
+	if err := app.Save(record); err != nil {
+		slog.Error("Failed to save webhook event status", "event_id", record.GetString("event_id"), "error", err)
+	}
+}
+
+// WebhookEventInfo is a stored webhook event as listed to admins
+type WebhookEventInfo struct {
+	EventID       string `json:"event_id"`
+	Provider      string `json:"provider"`
+	EventType     string `json:"event_type"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	Attempts      int    `json:"attempts"`
+	LastAttemptAt string `json:"last_attempt_at,omitempty"`
+	ProcessedAt   string `json:"processed_at,omitempty"`
+	Created       string `json:"created"`
+}
+
+func webhookEventInfo(record *core.Record) WebhookEventInfo {
+	info := WebhookEventInfo{
+		EventID:   record.GetString("event_id"),
+		Provider:  record.GetString("provider"),
+		EventType: record.GetString("event_type"),
+		Status:    record.GetString("status"),
+		Error:     record.GetString("error"),
+		Attempts:  record.GetInt("attempts"),
+		Created:   record.GetDateTime("created").Time().UTC().Format(time.RFC3339),
+	}
+	if t := record.GetDateTime("last_attempt_at"); !t.IsZero() {
+		info.LastAttemptAt = t.Time().UTC().Format(time.RFC3339)
+	}
+	if t := record.GetDateTime("processed_at"); !t.IsZero() {
+		info.ProcessedAt = t.Time().UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// ListWebhookEventsHandler lists the 100 most recent webhook events, dead-lettered ones by default (superusers only)
+// GET /api/admin/webhook-events?status=failed|processing|processed
+func ListWebhookEventsHandler(e *core.RequestEvent, app core.App) error {
+	status := e.Request.URL.Query().Get("status")
+	if status == "" {
+		status = WebhookEventFailed
+	}
+	switch status {
+	case WebhookEventFailed, WebhookEventProcessing, WebhookEventProcessed:
+	default:
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "status must be failed, processing or processed"})
+	}
+
+	records, err := app.FindRecordsByFilter("payment_webhook_events", "status = {:status}", "-created", 100, 0, map[string]any{"status": status})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load webhook events"})
+	}
+
+	events := make([]WebhookEventInfo, 0, len(records))
+	for _, record := range records {
+		events = append(events, webhookEventInfo(record))
+	}
+	return e.JSON(http.StatusOK, map[string]any{"events": events})
+}
+
+// RetryWebhookEventHandler processes a dead-lettered event again from its stored payload (superusers only)
+// POST /api/admin/webhook-events/{eventId}/retry
+func RetryWebhookEventHandler(e *core.RequestEvent, app core.App) error {
+	eventID := e.Request.PathValue("eventId")
+	record, err := app.FindFirstRecordByFilter("payment_webhook_events", "event_id = {:id}", map[string]any{"id": eventID})
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Webhook event not found"})
+	}
+	if webhookEventInProgress(record, time.Now()) {
+		return e.JSON(http.StatusConflict, map[string]string{"error": "Webhook event is already " + record.GetString("status")})
+	}
+
+	var event WebhookEvent
+	if err := record.UnmarshalJSONField("event", &event); err != nil || event.ID == "" {
+		return e.JSON(http.StatusUnprocessableEntity, map[string]string{"error": "Stored event payload can't be read"})
+	}
+
+	if err := startWebhookAttempt(app, record); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record retry"})
+	}
+	logger := logging.FromRequest(e).With("provider", record.GetString("provider"), "event_id", eventID, "event_type", event.Type)
+	processErr := dispatchWebhookEvent(app, logger, &event)
+	finishWebhookEvent(app, record, processErr)
+
+	logger.Info("Admin retried webhook event", "attempt", record.GetInt("attempts"), "status", record.GetString("status"))
+	return e.JSON(http.StatusOK, webhookEventInfo(record))
+}
@@ -0,0 +1,75 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+func webhookEventsCollection() *core.Collection {
+	collection := core.NewBaseCollection("payment_webhook_events")
+	collection.Fields.Add(
+		&core.TextField{Name: "status"},
+		&core.DateField{Name: "last_attempt_at"},
+		&core.JSONField{Name: "event"},
+	)
+	return collection
+}
+
+func TestWebhookEventInProgress(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		status      string
+		lastAttempt time.Time
+		want        bool
+	}{
+		{"processed", WebhookEventProcessed, now.Add(-time.Hour), true},
+		{"processing by a live delivery", WebhookEventProcessing, now.Add(-time.Minute), true},
+		{"processing but abandoned", WebhookEventProcessing, now.Add(-10 * time.Minute), false},
+		{"failed", WebhookEventFailed, now.Add(-time.Minute), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := core.NewRecord(webhookEventsCollection())
+			record.Set("status", tt.status)
+			lastAttempt, _ := types.ParseDateTime(tt.lastAttempt)
+			record.Set("last_attempt_at", lastAttempt)
+
+			if got := webhookEventInProgress(record, now); got != tt.want {
+				t.Errorf("webhookEventInProgress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredWebhookEventRoundTrip(t *testing.T) {
+	subscriptionID := "sub_123"
+	event := &WebhookEvent{
+		ID:           "evt_123",
+		Type:         "invoice.payment_failed",
+		Created:      time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		ProviderType: ProviderStripe,
+		Data: WebhookEventData{
+			Invoice: &Invoice{ID: "in_123", CustomerID: "cus_123", SubscriptionID: &subscriptionID, Total: 1200},
+		},
+	}
+
+	record := core.NewRecord(webhookEventsCollection())
+	record.Set("event", event)
+
+	var stored WebhookEvent
+	if err := record.UnmarshalJSONField("event", &stored); err != nil {
+		t.Fatalf("UnmarshalJSONField: %v", err)
+	}
+	if stored.ID != event.ID || stored.Type != event.Type || !stored.Created.Equal(event.Created) {
+		t.Errorf("stored event = %+v", stored)
+	}
+	if stored.Data.Invoice == nil || stored.Data.Invoice.Total != 1200 || *stored.Data.Invoice.SubscriptionID != subscriptionID {
+		t.Errorf("stored invoice = %+v", stored.Data.Invoice)
+	}
+}
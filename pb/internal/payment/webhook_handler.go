@@ -1,46 +1,125 @@
 package payment
 
 import (
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"pocketbase/internal/logging"
+	"pocketbase/internal/metrics"
 	"pocketbase/internal/subscription"
 
-	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/stripe/stripe-go/v79"
 )
 
+// webhookEvents counts verified deliveries by how their processing ended
+var webhookEvents = metrics.NewCounter("ramble_webhook_events_total",
+	"Verified webhook deliveries, by provider, event type and result (processed, failed, duplicate, invalid, error)",
+	"provider", "type", "result")
+
 // HandleWebhook processes payment provider webhooks and routes them to the subscription service
-func (s *Service) HandleWebhook(e *core.RequestEvent, app *pocketbase.PocketBase) error {
+// Deliveries that fail verification are counted per reason (see WebhookRejectionsHandler)
+func (s *Service) HandleWebhook(e *core.RequestEvent, app core.App) error {
+	if s == nil {
+		// The payment service failed to start (no STRIPE_SECRET_KEY)
+		return rejectWebhook(e, app, ProviderStripe, RejectNotConfigured, errors.New("payment service is not configured"))
+	}
+
 	// Read the request body
 	payload, err := io.ReadAll(e.Request.Body)
 	if err != nil {
-		log.Printf("Error reading webhook payload: %v", err)
-		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read request body"})
+		return rejectWebhook(e, app, s.config.ProviderType, RejectUnreadableBody, err)
 	}
 
 	// Get webhook signature from headers
 	signature := e.Request.Header.Get("Stripe-Signature")
 	if signature == "" {
-		log.Printf("Missing webhook signature")
-		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Missing webhook signature"})
+		return rejectWebhook(e, app, s.config.ProviderType, RejectMissingSignature, errors.New("missing Stripe-Signature header"))
 	}
 
 	// Parse webhook event using the payment provider
 	webhookEvent, err := s.ParseWebhookEvent(payload, signature)
 	if err != nil {
-		log.Printf("Webhook signature verification failed: %v", err)
+		reason := RejectInvalidSignature
+		var rejection *WebhookRejection
+		if errors.As(err, &rejection) {
+			reason = rejection.Reason
+		}
+		return rejectWebhook(e, app, s.config.ProviderType, reason, err)
+	}
+
+	logger := logging.FromRequest(e).With("provider", string(s.config.ProviderType),
+		"event_id", webhookEvent.ID, "event_type", webhookEvent.Type)
+	logger.Info("Processing webhook event")
+
+	switch result, err := processWebhookEvent(app, logger, webhookEvent); result {
+	case webhookResultError:
+		logger.Error("Failed to record webhook event", "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to record webhook event"})
+	case webhookResultDuplicate:
+		logger.Info("Skipping duplicate webhook event")
+		return e.JSON(http.StatusOK, map[string]string{"status": "duplicate"})
+	case webhookResultInvalid:
 		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case webhookResultFailed:
+		// Don't return error to Stripe - we've received the event, and it's kept for an admin retry
+		logger.Error("Failed to process webhook event, stored for retry", "error", err)
+		return e.JSON(http.StatusOK, map[string]string{"status": "failed"})
+	}
+	return e.JSON(http.StatusOK, map[string]string{"status": "success"})
+}
+
+// How processing a verified webhook event ended, as counted in ramble_webhook_events_total
+const (
+	webhookResultProcessed = "processed"
+	webhookResultFailed    = "failed"
+	webhookResultDuplicate = "duplicate"
+	webhookResultInvalid   = "invalid"
+	webhookResultError     = "error"
+)
+
+// processWebhookEvent runs a verified event through the subscription service exactly once: it's
+// claimed in payment_webhook_events, dispatched, and its outcome stored for admin retries
+func processWebhookEvent(app core.App, logger *slog.Logger, webhookEvent *WebhookEvent) (string, error) {
+	provider := string(webhookEvent.ProviderType)
+
+	// Stripe delivers events at least once - skip events that were already handled
+	record, duplicate, err := claimWebhookEvent(app, webhookEvent)
+	if err != nil {
+		webhookEvents.Inc(provider, webhookEvent.Type, webhookResultError)
+		return webhookResultError, err
+	}
+	if duplicate {
+		webhookEvents.Inc(provider, webhookEvent.Type, webhookResultDuplicate)
+		return webhookResultDuplicate, nil
 	}
 
-	log.Printf("Processing webhook event: %s (ID: %s)", webhookEvent.Type, webhookEvent.ID)
+	err = dispatchWebhookEvent(app, logger, webhookEvent)
+	finishWebhookEvent(app, record, err)
+	result := webhookResultProcessed
+	switch {
+	case errors.Is(err, errMissingEventData):
+		result = webhookResultInvalid
+	case err != nil:
+		result = webhookResultFailed
+	}
+	webhookEvents.Inc(provider, webhookEvent.Type, result)
+	return result, err
+}
+
+// errMissingEventData is returned for events that arrive without the object they're about
+var errMissingEventData = errors.New("missing event data")
 
+// dispatchWebhookEvent routes a verified webhook event to the subscription service, which logs
+// through logger so its lines carry the event ID
+func dispatchWebhookEvent(app core.App, logger *slog.Logger, webhookEvent *WebhookEvent) error {
 	// Create subscription service to handle the business logic
 	repo := subscription.NewRepository(app)
-	subscriptionService := subscription.NewService(repo)
+	subscriptionService := subscription.NewService(repo).WithLogger(logger)
 
 	// Route webhook events to appropriate handlers
 	switch webhookEvent.Type {
@@ -48,15 +127,15 @@ func (s *Service) HandleWebhook(e *core.RequestEvent, app *pocketbase.PocketBase
 		// Customer creation is handled automatically by payment service
 		// This webhook is mostly for logging and potential future processing
 		if webhookEvent.Data.Customer != nil {
-			log.Printf("Customer created: %s", webhookEvent.Data.Customer.ID)
+			logger.Info("Customer created", "customer_id", webhookEvent.Data.Customer.ID)
 		} else {
-			log.Printf("Customer created but no customer data provided")
+			logger.Warn("Customer created but no customer data provided")
 		}
 		
 	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
 		if webhookEvent.Data.Subscription == nil {
-			log.Printf("No subscription data in webhook")
-			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Missing subscription data"})
+			logger.Warn("No subscription data in webhook")
+			return fmt.Errorf("%w: subscription", errMissingEventData)
 		}
 		
 		// Convert payment.Subscription back to webhook event data format for subscription service
@@ -71,14 +150,13 @@ func (s *Service) HandleWebhook(e *core.RequestEvent, app *pocketbase.PocketBase
 		}
 		
 		if err := subscriptionService.ProcessWebhookEvent(eventData); err != nil {
-			log.Printf("Error processing subscription webhook: %v", err)
-			// Don't return error to Stripe - we've received the event
+			return fmt.Errorf("subscription webhook: %w", err)
 		}
 
 	case "invoice.payment_succeeded", "invoice.payment_failed":
 		if webhookEvent.Data.Invoice == nil {
-			log.Printf("No invoice data in webhook")
-			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Missing invoice data"})
+			logger.Warn("No invoice data in webhook")
+			return fmt.Errorf("%w: invoice", errMissingEventData)
 		}
 		
 		// Handle invoice events
@@ -88,14 +166,19 @@ func (s *Service) HandleWebhook(e *core.RequestEvent, app *pocketbase.PocketBase
 		}
 		
 		if err := subscriptionService.ProcessWebhookEvent(eventData); err != nil {
-			log.Printf("Error processing invoice webhook: %v", err)
-			// Don't return error to Stripe - we've received the event
+			return fmt.Errorf("invoice webhook: %w", err)
 		}
 
 	case "checkout.session.completed":
 		// Process checkout session completion - this often triggers subscription creation
 		if webhookEvent.Data.CheckoutSession != nil {
-			log.Printf("Checkout session completed: %s", webhookEvent.Data.CheckoutSession.ID)
+			logger.Info("Checkout session completed", "checkout_session_id", webhookEvent.Data.CheckoutSession.ID)
+			UpdateCheckoutSessionStatus(app, webhookEvent.Data.CheckoutSession.ID, CheckoutSessionCompleted)
+			if session := webhookEvent.Data.CheckoutSession; session.CustomerDetails != nil && session.CustomerID != "" {
+				if err := saveCustomerTaxDetails(app, session.CustomerID, session.CustomerDetails); err != nil {
+					logger.Warn("Failed to save customer tax details", "customer_id", session.CustomerID, "error", err)
+				}
+			}
 			
 			// Send checkout session data to subscription service for processing
 			eventData := subscription.WebhookEventData{
@@ -104,18 +187,23 @@ func (s *Service) HandleWebhook(e *core.RequestEvent, app *pocketbase.PocketBase
 			}
 			
 			if err := subscriptionService.ProcessWebhookEvent(eventData); err != nil {
-				log.Printf("Error processing checkout session webhook: %v", err)
-				// Don't return error to Stripe - we've received the event
+				return fmt.Errorf("checkout session webhook: %w", err)
 			}
 		} else {
-			log.Printf("Checkout session completed but no session data provided")
+			logger.Warn("Checkout session completed but no session data provided")
+		}
+
+	case "checkout.session.expired":
+		if webhookEvent.Data.CheckoutSession != nil {
+			logger.Info("Checkout session expired", "checkout_session_id", webhookEvent.Data.CheckoutSession.ID)
+			UpdateCheckoutSessionStatus(app, webhookEvent.Data.CheckoutSession.ID, CheckoutSessionExpired)
 		}
 
 	default:
-		log.Printf("Unhandled webhook event type: %s", webhookEvent.Type)
+		logger.Info("Unhandled webhook event type")
 	}
 
-	return e.JSON(http.StatusOK, map[string]string{"status": "success"})
+	return nil
 }
 
 // Helper function to convert payment.Subscription to stripe.Subscription format expected by subscription service
@@ -134,6 +222,15 @@ func convertPaymentSubscriptionToStripe(sub *Subscription) *stripe.Subscription
 	if sub.CanceledAt != nil {
 		stripeSub.CanceledAt = sub.CanceledAt.Unix()
 	}
+	if sub.TrialEnd != nil {
+		stripeSub.TrialEnd = sub.TrialEnd.Unix()
+	}
+	if sub.Paused {
+		stripeSub.PauseCollection = &stripe.SubscriptionPauseCollection{Behavior: stripe.SubscriptionPauseCollectionBehaviorVoid}
+		if sub.ResumesAt != nil {
+			stripeSub.PauseCollection.ResumesAt = sub.ResumesAt.Unix()
+		}
+	}
 
 	// Create subscription items with price
 	if sub.PriceID != "" {
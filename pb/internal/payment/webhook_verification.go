@@ -0,0 +1,185 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79/webhook"
+
+	"pocketbase/internal/metrics"
+)
+
+// Reasons a webhook delivery is rejected before it reaches the subscription service
+const (
+	RejectNotConfigured    = "not_configured"    // no webhook signing secret
+	RejectUnreadableBody   = "unreadable_body"   // the request body couldn't be read
+	RejectMissingSignature = "missing_signature" // no Stripe-Signature header
+	RejectInvalidHeader    = "invalid_header"    // Stripe-Signature header can't be parsed
+	RejectInvalidSignature = "invalid_signature" // no signature matches the secret
+	RejectStaleTimestamp   = "stale_timestamp"   // signed outside the tolerance window - a possible replay
+	RejectMalformedEvent   = "malformed_event"   // signed, but not an event we can parse
+)
+
+// defaultWebhookTolerance matches Stripe's own default for signed timestamps
+const defaultWebhookTolerance = 5 * time.Minute
+
+// ErrWebhookSecretMissing means STRIPE_SECRET_WHSEC isn't set, so no delivery can be verified
+var ErrWebhookSecretMissing = errors.New("STRIPE_SECRET_WHSEC is not set - webhooks can't be verified")
+
+// WebhookRejection is returned by ParseWebhookEvent when a delivery fails verification
+type WebhookRejection struct {
+	Reason string
+	Err    error
+}
+
+func (r *WebhookRejection) Error() string {
+	return fmt.Sprintf("webhook rejected (%s): %v", r.Reason, r.Err)
+}
+
+func (r *WebhookRejection) Unwrap() error {
+	return r.Err
+}
+
+// RequireWebhookSecret fails outside development when STRIPE_SECRET_WHSEC is missing, so a
+// production server never starts with webhooks it can't verify
+func RequireWebhookSecret() error {
+	if os.Getenv("DEVELOPMENT") == "true" || os.Getenv("STRIPE_SECRET_WHSEC") != "" {
+		return nil
+	}
+	return ErrWebhookSecretMissing
+}
+
+// WebhookTolerance reads STRIPE_WEBHOOK_TOLERANCE_SECONDS, how far a delivery's signed
+// timestamp may be from now before it's treated as a replay (default 300)
+func WebhookTolerance() time.Duration {
+	if value := os.Getenv("STRIPE_WEBHOOK_TOLERANCE_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultWebhookTolerance
+}
+
+// verifyStripeSignature checks a delivery's signature and that it was signed within the
+// tolerance window in either direction - Stripe's library only rejects old timestamps
+func verifyStripeSignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	if secret == "" {
+		return &WebhookRejection{Reason: RejectNotConfigured, Err: ErrWebhookSecretMissing}
+	}
+
+	err := webhook.ValidatePayloadWithTolerance(payload, header, secret, tolerance)
+	switch {
+	case errors.Is(err, webhook.ErrNotSigned):
+		return &WebhookRejection{Reason: RejectMissingSignature, Err: err}
+	case errors.Is(err, webhook.ErrInvalidHeader):
+		return &WebhookRejection{Reason: RejectInvalidHeader, Err: err}
+	case errors.Is(err, webhook.ErrTooOld):
+		return &WebhookRejection{Reason: RejectStaleTimestamp, Err: err}
+	case err != nil:
+		return &WebhookRejection{Reason: RejectInvalidSignature, Err: err}
+	}
+
+	if signedAt, ok := signatureTimestamp(header); ok && signedAt.Sub(now) > tolerance {
+		return &WebhookRejection{Reason: RejectStaleTimestamp, Err: fmt.Errorf("timestamp %s is in the future", signedAt.UTC().Format(time.RFC3339))}
+	}
+	return nil
+}
+
+// signatureTimestamp reads t= from a Stripe-Signature header
+func signatureTimestamp(header string) (time.Time, bool) {
+	for _, pair := range strings.Split(header, ",") {
+		if value, ok := strings.CutPrefix(pair, "t="); ok {
+			if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return time.Unix(unix, 0), true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// webhookRejections counts rejected deliveries per provider and reason since startup
+var webhookRejections = struct {
+	sync.Mutex
+	counts map[string]map[string]int
+	last   map[string]time.Time
+}{counts: map[string]map[string]int{}, last: map[string]time.Time{}}
+
+var webhookRejectionsTotal = metrics.NewCounter("ramble_webhook_rejections_total",
+	"Webhook deliveries rejected before processing, by provider and reason",
+	"provider", "reason")
+
+// recordWebhookRejection counts and logs a rejected delivery as a structured warning
+func recordWebhookRejection(app core.App, e *core.RequestEvent, provider ProviderType, reason string, err error) {
+	webhookRejections.Lock()
+	if webhookRejections.counts[string(provider)] == nil {
+		webhookRejections.counts[string(provider)] = map[string]int{}
+	}
+	webhookRejections.counts[string(provider)][reason]++
+	webhookRejections.last[string(provider)] = time.Now()
+	webhookRejections.Unlock()
+	webhookRejectionsTotal.Inc(string(provider), reason)
+
+	app.Logger().Warn("Webhook rejected",
+		"provider", string(provider),
+		"reason", reason,
+		"error", err,
+		"remote_ip", e.RealIP(),
+		"user_agent", e.Request.UserAgent(),
+	)
+}
+
+// WebhookRejectionStats is the rejection counters for one provider
+type WebhookRejectionStats struct {
+	Provider   string         `json:"provider"`
+	Total      int            `json:"total"`
+	ByReason   map[string]int `json:"by_reason"`
+	LastReject string         `json:"last_rejected_at,omitempty"`
+}
+
+// webhookRejectionStats snapshots the counters, sorted by provider
+func webhookRejectionStats() []WebhookRejectionStats {
+	webhookRejections.Lock()
+	defer webhookRejections.Unlock()
+
+	stats := make([]WebhookRejectionStats, 0, len(webhookRejections.counts))
+	for provider, reasons := range webhookRejections.counts {
+		entry := WebhookRejectionStats{Provider: provider, ByReason: map[string]int{}}
+		for reason, count := range reasons {
+			entry.ByReason[reason] = count
+			entry.Total += count
+		}
+		if last, ok := webhookRejections.last[provider]; ok {
+			entry.LastReject = last.UTC().Format(time.RFC3339)
+		}
+		stats = append(stats, entry)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+// WebhookRejectionsHandler reports rejected webhook deliveries since startup (superusers only)
+// GET /api/admin/webhook-events/rejections
+func WebhookRejectionsHandler(e *core.RequestEvent) error {
+	return e.JSON(http.StatusOK, map[string]any{
+		"tolerance_seconds": int(WebhookTolerance().Seconds()),
+		"providers":         webhookRejectionStats(),
+	})
+}
+
+// rejectWebhook answers a rejected delivery: 503 while the secret is missing (Stripe retries
+// once it's configured), 400 for deliveries that failed verification
+func rejectWebhook(e *core.RequestEvent, app core.App, provider ProviderType, reason string, err error) error {
+	recordWebhookRejection(app, e, provider, reason, err)
+	if reason == RejectNotConfigured {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Webhook verification is not configured", "code": reason})
+	}
+	return e.JSON(http.StatusBadRequest, map[string]string{"error": "Webhook verification failed", "code": reason})
+}
@@ -0,0 +1,85 @@
+package payment
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v79/webhook"
+)
+
+func signedHeader(payload []byte, secret string, at time.Time) string {
+	return webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{Payload: payload, Secret: secret, Timestamp: at}).Header
+}
+
+func TestVerifyStripeSignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"invoice.paid"}`)
+	secret := "whsec_test_secret"
+	now := time.Now()
+	tolerance := 5 * time.Minute
+
+	tests := []struct {
+		name   string
+		header string
+		secret string
+		reason string
+	}{
+		{"valid", signedHeader(payload, secret, now), secret, ""},
+		{"no secret configured", signedHeader(payload, "", now), "", RejectNotConfigured},
+		{"missing header", "", secret, RejectMissingSignature},
+		{"garbled header", "t=abc,v1", secret, RejectInvalidHeader},
+		{"wrong secret", signedHeader(payload, "whsec_other", now), secret, RejectInvalidSignature},
+		{"replayed", signedHeader(payload, secret, now.Add(-10*time.Minute)), secret, RejectStaleTimestamp},
+		{"from the future", signedHeader(payload, secret, now.Add(10*time.Minute)), secret, RejectStaleTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyStripeSignature(payload, tt.header, tt.secret, tolerance, now)
+			if tt.reason == "" {
+				if err != nil {
+					t.Fatalf("expected the delivery to verify, got %v", err)
+				}
+				return
+			}
+			var rejection *WebhookRejection
+			if !errors.As(err, &rejection) || rejection.Reason != tt.reason {
+				t.Errorf("expected rejection %q, got %v", tt.reason, err)
+			}
+		})
+	}
+}
+
+func TestRequireWebhookSecret(t *testing.T) {
+	t.Setenv("DEVELOPMENT", "")
+	t.Setenv("STRIPE_SECRET_WHSEC", "")
+	if err := RequireWebhookSecret(); !errors.Is(err, ErrWebhookSecretMissing) {
+		t.Errorf("expected production without a secret to fail, got %v", err)
+	}
+
+	t.Setenv("DEVELOPMENT", "true")
+	if err := RequireWebhookSecret(); err != nil {
+		t.Errorf("expected development to start without a secret, got %v", err)
+	}
+
+	t.Setenv("DEVELOPMENT", "")
+	t.Setenv("STRIPE_SECRET_WHSEC", "whsec_0123456789abcdef")
+	if err := RequireWebhookSecret(); err != nil {
+		t.Errorf("expected a configured secret to pass, got %v", err)
+	}
+}
+
+func TestWebhookTolerance(t *testing.T) {
+	t.Setenv("STRIPE_WEBHOOK_TOLERANCE_SECONDS", "")
+	if got := WebhookTolerance(); got != defaultWebhookTolerance {
+		t.Errorf("expected the default tolerance, got %v", got)
+	}
+	t.Setenv("STRIPE_WEBHOOK_TOLERANCE_SECONDS", "60")
+	if got := WebhookTolerance(); got != time.Minute {
+		t.Errorf("expected 1m, got %v", got)
+	}
+	t.Setenv("STRIPE_WEBHOOK_TOLERANCE_SECONDS", "-5")
+	if got := WebhookTolerance(); got != defaultWebhookTolerance {
+		t.Errorf("expected invalid values to fall back to the default, got %v", got)
+	}
+}
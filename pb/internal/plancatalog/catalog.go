@@ -0,0 +1,185 @@
+// Package plancatalog manages the subscription plans from a versioned JSON catalog instead of
+// Go code. Sync diffs the catalog against the subscription_plans collection and the Stripe
+// products and prices, and creates, updates or archives what differs (`pocketbase sync-plans`)
+package plancatalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultCatalog is the catalog compiled into the binary, used unless PLAN_CATALOG_FILE is set
+//
+//go:embed catalog.json
+var defaultCatalog []byte
+
+// Billing intervals a catalog plan can have, matching subscription_plans.billing_interval
+const (
+	IntervalFree  = "free"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// Catalog is the plan catalog file
+type Catalog struct {
+	Version  int       `json:"version"`  // bumped with every change, reported by sync
+	Currency string    `json:"currency"` // default for plans that don't set one
+	Products []Product `json:"products"`
+	Plans    []Plan    `json:"plans"`
+}
+
+// Product is a Stripe product, shared by a plan's monthly and yearly prices
+type Product struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+// Plan is one subscription_plans record and, when it's paid, one Stripe price. Optional limits
+// left out of the catalog aren't touched on the record, so they can still be set by hand
+type Plan struct {
+	Key                string   `json:"key"` // stable identifier, kept in subscription_plans.catalog_key
+	Name               string   `json:"name"`
+	Product            string   `json:"product,omitempty"` // Products key, required for paid plans
+	PriceCents         int64    `json:"price_cents"`
+	Currency           string   `json:"currency,omitempty"`
+	BillingInterval    string   `json:"billing_interval"`
+	HoursPerMonth      float64  `json:"hours_per_month"`
+	TokensPerMonth     int      `json:"tokens_per_month"` // 0 = unlimited
+	Features           []string `json:"features"`
+	SpeakerDiarization bool     `json:"speaker_diarization,omitempty"`
+	PairedWith         string   `json:"paired_with,omitempty"` // key of the same plan billed at the other interval
+	Inactive           bool     `json:"inactive,omitempty"`    // kept for existing subscribers, hidden from new ones
+
+	TrialDays             *int      `json:"trial_days,omitempty"`
+	MaxUploadMB           *int      `json:"max_upload_mb,omitempty"`
+	MaxProcessingAttempts *int      `json:"max_processing_attempts,omitempty"`
+	GracePeriodSeconds    *int      `json:"grace_period_seconds,omitempty"`
+	BurstPercent          *float64  `json:"burst_percent,omitempty"`
+	BurstsPerYear         *int      `json:"bursts_per_year,omitempty"`
+	RateLimitPerMinute    *int      `json:"rate_limit_per_minute,omitempty"`
+	RateLimitBurst        *int      `json:"rate_limit_burst,omitempty"`
+	RetentionDays         *int      `json:"retention_days,omitempty"`
+	AllowedModels         *[]string `json:"allowed_models,omitempty"`
+}
+
+// Paid reports whether the plan has a Stripe price
+func (p Plan) Paid() bool {
+	return p.BillingInterval != IntervalFree && p.PriceCents > 0
+}
+
+// Load reads the catalog from PLAN_CATALOG_FILE, or the one compiled into the binary
+func Load() (*Catalog, error) {
+	data, source := defaultCatalog, "built-in catalog"
+	if path := os.Getenv("PLAN_CATALOG_FILE"); path != "" {
+		file, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read plan catalog: %w", err)
+		}
+		data, source = file, path
+	}
+
+	catalog, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return catalog, nil
+}
+
+// Parse decodes and validates a catalog, filling in plan currencies
+func Parse(data []byte) (*Catalog, error) {
+	var catalog Catalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("invalid plan catalog: %w", err)
+	}
+	if catalog.Currency == "" {
+		catalog.Currency = "usd"
+	}
+	for i := range catalog.Plans {
+		if catalog.Plans[i].Currency == "" {
+			catalog.Plans[i].Currency = catalog.Currency
+		}
+		catalog.Plans[i].Currency = strings.ToLower(catalog.Plans[i].Currency)
+	}
+	if err := catalog.Validate(); err != nil {
+		return nil, err
+	}
+	return &catalog, nil
+}
+
+// Validate checks keys are unique and every reference resolves
+func (c *Catalog) Validate() error {
+	if c.Version <= 0 {
+		return fmt.Errorf("plan catalog needs a positive version")
+	}
+
+	products := map[string]bool{}
+	for _, product := range c.Products {
+		if product.Key == "" || product.Name == "" {
+			return fmt.Errorf("product %q needs a key and a name", product.Key)
+		}
+		if products[product.Key] {
+			return fmt.Errorf("duplicate product %q", product.Key)
+		}
+		products[product.Key] = true
+	}
+
+	plans := map[string]Plan{}
+	names := map[string]bool{}
+	for _, plan := range c.Plans {
+		switch {
+		case plan.Key == "" || plan.Name == "":
+			return fmt.Errorf("plan %q needs a key and a name", plan.Key)
+		case plans[plan.Key].Key != "":
+			return fmt.Errorf("duplicate plan %q", plan.Key)
+		case names[plan.Name]:
+			return fmt.Errorf("duplicate plan name %q", plan.Name)
+		case plan.BillingInterval != IntervalFree && plan.BillingInterval != IntervalMonth && plan.BillingInterval != IntervalYear:
+			return fmt.Errorf("plan %q has unknown billing_interval %q", plan.Key, plan.BillingInterval)
+		case plan.PriceCents < 0 || plan.HoursPerMonth < 0 || plan.TokensPerMonth < 0:
+			return fmt.Errorf("plan %q has a negative price or allowance", plan.Key)
+		case plan.BillingInterval == IntervalFree && plan.PriceCents != 0:
+			return fmt.Errorf("free plan %q can't have a price", plan.Key)
+		case plan.Paid() && !products[plan.Product]:
+			return fmt.Errorf("paid plan %q needs a product from the products list", plan.Key)
+		}
+		plans[plan.Key] = plan
+		names[plan.Name] = true
+	}
+
+	for _, plan := range c.Plans {
+		if plan.PairedWith == "" {
+			continue
+		}
+		paired, ok := plans[plan.PairedWith]
+		if !ok {
+			return fmt.Errorf("plan %q is paired with unknown plan %q", plan.Key, plan.PairedWith)
+		}
+		if paired.BillingInterval == plan.BillingInterval || paired.BillingInterval == IntervalFree {
+			return fmt.Errorf("plan %q must be paired with a plan billed at the other interval", plan.Key)
+		}
+	}
+	return nil
+}
+
+// Plan returns the plan with a key
+func (c *Catalog) Plan(key string) (Plan, bool) {
+	for _, plan := range c.Plans {
+		if plan.Key == key {
+			return plan, true
+		}
+	}
+	return Plan{}, false
+}
+
+// Product returns the product with a key
+func (c *Catalog) Product(key string) (Product, bool) {
+	for _, product := range c.Products {
+		if product.Key == key {
+			return product, true
+		}
+	}
+	return Product{}, false
+}
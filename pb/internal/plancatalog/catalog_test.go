@@ -0,0 +1,146 @@
+package plancatalog
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79"
+)
+
+func TestDefaultCatalogIsValid(t *testing.T) {
+	catalog, err := Parse(defaultCatalog)
+	if err != nil {
+		t.Fatalf("built-in catalog: %v", err)
+	}
+	for _, plan := range catalog.Plans {
+		if plan.Currency != "usd" {
+			t.Errorf("plan %s currency = %q, want the catalog default", plan.Key, plan.Currency)
+		}
+	}
+	if pro, ok := catalog.Plan("pro_yearly"); !ok || pro.PairedWith != "pro" || !pro.Paid() {
+		t.Errorf("pro_yearly = %+v, want a paid plan paired with pro", pro)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]string{
+		"no version":       `{"plans": []}`,
+		"duplicate key":    `{"version": 1, "plans": [{"key": "a", "name": "A", "billing_interval": "free"}, {"key": "a", "name": "B", "billing_interval": "free"}]}`,
+		"unknown interval": `{"version": 1, "plans": [{"key": "a", "name": "A", "billing_interval": "week"}]}`,
+		"priced free plan": `{"version": 1, "plans": [{"key": "a", "name": "A", "billing_interval": "free", "price_cents": 100}]}`,
+		"missing product":  `{"version": 1, "plans": [{"key": "a", "name": "A", "billing_interval": "month", "price_cents": 100, "product": "x"}]}`,
+		"unknown pair":     `{"version": 1, "plans": [{"key": "a", "name": "A", "billing_interval": "free", "paired_with": "b"}]}`,
+		"same-interval pair": `{"version": 1, "products": [{"key": "p", "name": "P"}], "plans": [
+			{"key": "a", "name": "A", "billing_interval": "month", "price_cents": 100, "product": "p", "paired_with": "b"},
+			{"key": "b", "name": "B", "billing_interval": "month", "price_cents": 200, "product": "p"}]}`,
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: Parse() succeeded, want an error", name)
+		}
+	}
+}
+
+func TestChangedFields(t *testing.T) {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.NumberField{Name: "price_cents"},
+		&core.NumberField{Name: "hours_per_month"},
+		&core.BoolField{Name: "is_active"},
+		&core.JSONField{Name: "features"},
+	)
+	record := core.NewRecord(collection)
+	record.Set("name", "Pro")
+	record.Set("price_cents", 1500)
+	record.Set("hours_per_month", 25)
+	record.Set("is_active", true)
+	record.Set("features", []string{"a", "b"})
+
+	same := map[string]any{"name": "Pro", "price_cents": int64(1500), "hours_per_month": 25.0, "is_active": true, "features": []string{"a", "b"}}
+	if changed := changedFields(record, same); len(changed) != 0 {
+		t.Errorf("changedFields() = %v, want none", changed)
+	}
+
+	different := map[string]any{"name": "Pro", "price_cents": int64(1800), "hours_per_month": 25.0, "is_active": false, "features": []string{"a"}}
+	if got := strings.Join(changedFields(record, different), ","); got != "features,is_active,price_cents" {
+		t.Errorf("changedFields() = %s, want features,is_active,price_cents", got)
+	}
+}
+
+type fakeStripe struct {
+	created []string
+}
+
+func (f *fakeStripe) Products(context.Context) ([]*stripe.Product, error) { return nil, nil }
+func (f *fakeStripe) Prices(context.Context) ([]*stripe.Price, error)     { return nil, nil }
+func (f *fakeStripe) CreateProduct(_ context.Context, key, _ string) (string, error) {
+	f.created = append(f.created, "prod:"+key)
+	return "prod_" + key, nil
+}
+func (f *fakeStripe) RenameProduct(context.Context, string, string) error { return nil }
+func (f *fakeStripe) ArchiveProduct(context.Context, string) error        { return nil }
+func (f *fakeStripe) CreatePrice(_ context.Context, _ string, plan Plan) (string, error) {
+	f.created = append(f.created, "price:"+plan.Key)
+	return "price_new_" + plan.Key, nil
+}
+func (f *fakeStripe) ArchivePrice(context.Context, string) error { return nil }
+
+func TestEnsurePrice(t *testing.T) {
+	catalog, err := Parse(defaultCatalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pro, _ := catalog.Plan("pro")
+
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(&core.TextField{Name: "provider_price_id"}, &core.TextField{Name: "provider_product_id"})
+	record := core.NewRecord(collection)
+	record.Set("provider_product_id", "prod_existing")
+	record.Set("provider_price_id", "price_old")
+
+	newSyncer := func(unitAmount int64) (*syncer, *fakeStripe) {
+		fake := &fakeStripe{}
+		return &syncer{
+			ctx:      context.Background(),
+			catalog:  catalog,
+			opts:     Options{Stripe: fake},
+			report:   &Report{},
+			resolved: map[string]string{},
+			products: map[string]*stripe.Product{"prod_existing": {ID: "prod_existing", Active: true, Name: "Pro Plan"}},
+			prices: map[string]*stripe.Price{"price_old": {ID: "price_old", Active: true, UnitAmount: unitAmount, Currency: "usd",
+				Product: &stripe.Product{ID: "prod_existing"}, Recurring: &stripe.PriceRecurring{Interval: "month"}}},
+		}, fake
+	}
+
+	// Unchanged price: reused, nothing created
+	s, fake := newSyncer(1500)
+	if productID, priceID, ok := s.ensurePrice(pro, record); !ok || productID != "prod_existing" || priceID != "price_old" {
+		t.Errorf("ensurePrice() = %s, %s, %v; want the existing product and price", productID, priceID, ok)
+	}
+	if len(fake.created) != 0 {
+		t.Errorf("created %v, want nothing", fake.created)
+	}
+
+	// Changed amount: new price on the existing product
+	s, fake = newSyncer(1200)
+	if productID, priceID, ok := s.ensurePrice(pro, record); !ok || productID != "prod_existing" || priceID != "price_new_pro" {
+		t.Errorf("ensurePrice() = %s, %s, %v; want a new price on the existing product", productID, priceID, ok)
+	}
+	if strings.Join(fake.created, ",") != "price:pro" {
+		t.Errorf("created %v, want only the pro price", fake.created)
+	}
+
+	// New plan and no product: both created
+	s, fake = newSyncer(1500)
+	s.products = map[string]*stripe.Product{}
+	basic, _ := catalog.Plan("basic")
+	if _, priceID, ok := s.ensurePrice(basic, nil); !ok || priceID != "price_new_basic" {
+		t.Errorf("ensurePrice() = %s, %v; want a new price", priceID, ok)
+	}
+	if strings.Join(fake.created, ",") != "prod:basic,price:basic" {
+		t.Errorf("created %v, want the basic product then its price", fake.created)
+	}
+}
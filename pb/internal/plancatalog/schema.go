@@ -0,0 +1,26 @@
+package plancatalog
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the subscription_plans fields Sync writes besides the ones the
+// subscription service needs
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "plancatalog",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "catalog_key", Type: "text"},
+					{Name: "features", Type: "json"},
+					{Name: "tokens_per_month", Type: "number"},
+					{Name: "speaker_diarization", Type: "bool"},
+					{Name: "payment_provider", Type: "select"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_subscription_plans_catalog_key", Columns: "catalog_key"},
+				},
+			},
+		},
+	}
+}
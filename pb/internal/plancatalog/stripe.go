@@ -0,0 +1,129 @@
+package plancatalog
+
+import (
+	"context"
+
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/price"
+	"github.com/stripe/stripe-go/v79/product"
+)
+
+// Metadata keys Sync sets on the Stripe objects it creates, so later runs find them again
+const (
+	metadataProduct   = "catalog_product"
+	metadataPlan      = "catalog_plan"
+	metadataCreatedBy = "created_by"
+	createdBy         = "plan_catalog"
+)
+
+// StripeClient is the part of the Stripe API Sync uses
+type StripeClient interface {
+	Products(ctx context.Context) ([]*stripe.Product, error)
+	Prices(ctx context.Context) ([]*stripe.Price, error)
+	CreateProduct(ctx context.Context, key, name string) (string, error)
+	RenameProduct(ctx context.Context, id, name string) error
+	ArchiveProduct(ctx context.Context, id string) error
+	CreatePrice(ctx context.Context, productID string, plan Plan) (string, error)
+	ArchivePrice(ctx context.Context, id string) error
+}
+
+// StripeAPI calls the Stripe API using the key in stripe.Key
+type StripeAPI struct{}
+
+// Products implements StripeClient, archived products included
+func (StripeAPI) Products(ctx context.Context) ([]*stripe.Product, error) {
+	params := &stripe.ProductListParams{}
+	params.Context = ctx
+	params.Limit = stripe.Int64(100)
+
+	var products []*stripe.Product
+	iter := product.List(params)
+	for iter.Next() {
+		products = append(products, iter.Product())
+	}
+	return products, iter.Err()
+}
+
+// Prices implements StripeClient, archived prices included
+func (StripeAPI) Prices(ctx context.Context) ([]*stripe.Price, error) {
+	params := &stripe.PriceListParams{}
+	params.Context = ctx
+	params.Limit = stripe.Int64(100)
+
+	var prices []*stripe.Price
+	iter := price.List(params)
+	for iter.Next() {
+		prices = append(prices, iter.Price())
+	}
+	return prices, iter.Err()
+}
+
+// CreateProduct implements StripeClient
+func (StripeAPI) CreateProduct(ctx context.Context, key, name string) (string, error) {
+	params := &stripe.ProductParams{
+		Name:     stripe.String(name),
+		Metadata: map[string]string{metadataProduct: key, metadataCreatedBy: createdBy},
+	}
+	params.Context = ctx
+	created, err := product.New(params)
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// RenameProduct implements StripeClient
+func (StripeAPI) RenameProduct(ctx context.Context, id, name string) error {
+	params := &stripe.ProductParams{Name: stripe.String(name)}
+	params.Context = ctx
+	_, err := product.Update(id, params)
+	return err
+}
+
+// ArchiveProduct implements StripeClient
+func (StripeAPI) ArchiveProduct(ctx context.Context, id string) error {
+	params := &stripe.ProductParams{Active: stripe.Bool(false)}
+	params.Context = ctx
+	_, err := product.Update(id, params)
+	return err
+}
+
+// CreatePrice implements StripeClient
+func (StripeAPI) CreatePrice(ctx context.Context, productID string, plan Plan) (string, error) {
+	params := &stripe.PriceParams{
+		Product:    stripe.String(productID),
+		UnitAmount: stripe.Int64(plan.PriceCents),
+		Currency:   stripe.String(plan.Currency),
+		Recurring:  &stripe.PriceRecurringParams{Interval: stripe.String(plan.BillingInterval)},
+		Metadata:   map[string]string{metadataPlan: plan.Key, metadataCreatedBy: createdBy},
+	}
+	params.Context = ctx
+	created, err := price.New(params)
+	if err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// ArchivePrice implements StripeClient. Existing subscriptions keep the price, new checkouts
+// can't use it
+func (StripeAPI) ArchivePrice(ctx context.Context, id string) error {
+	params := &stripe.PriceParams{Active: stripe.Bool(false)}
+	params.Context = ctx
+	_, err := price.Update(id, params)
+	return err
+}
+
+// priceMatches reports whether a Stripe price bills what a plan asks for
+func priceMatches(p *stripe.Price, plan Plan) bool {
+	return p != nil && p.UnitAmount == plan.PriceCents && string(p.Currency) == plan.Currency &&
+		p.Recurring != nil && string(p.Recurring.Interval) == plan.BillingInterval
+}
+
+// priceProductID returns the ID of a price's product
+func priceProductID(p *stripe.Price) string {
+	if p.Product == nil {
+		return ""
+	}
+	return p.Product.ID
+}
@@ -0,0 +1,534 @@
+package plancatalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79"
+
+	"pocketbase/internal/subscription"
+)
+
+// What a change applies to
+const (
+	TargetPlan    = "plan"
+	TargetProduct = "stripe_product"
+	TargetPrice   = "stripe_price"
+)
+
+// Change actions
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionArchive = "archive"
+)
+
+// Change is one difference between the catalog and the database or Stripe
+type Change struct {
+	Target  string `json:"target"`
+	Key     string `json:"key"`
+	Action  string `json:"action"`
+	Detail  string `json:"detail,omitempty"`
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Report is the outcome of Sync
+type Report struct {
+	Version   int      `json:"catalog_version"`
+	DryRun    bool     `json:"dry_run"`
+	Stripe    bool     `json:"stripe"`
+	Unchanged int      `json:"unchanged"` // catalog plans already in sync
+	Changes   []Change `json:"changes"`
+}
+
+// Failed returns the number of changes that couldn't be applied
+func (r Report) Failed() int {
+	n := 0
+	for _, change := range r.Changes {
+		if change.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes one line per change followed by a summary
+func (r Report) Print(w io.Writer) {
+	for _, change := range r.Changes {
+		label := "PLAN"
+		switch {
+		case change.Error != "":
+			label = "ERROR"
+		case change.Applied:
+			label = "DONE"
+		}
+		fmt.Fprintf(w, "[%-5s] %-7s %-14s %s", label, change.Action, change.Target, change.Key)
+		if change.Detail != "" {
+			fmt.Fprintf(w, " - %s", change.Detail)
+		}
+		fmt.Fprintln(w)
+		if change.Error != "" {
+			fmt.Fprintf(w, "        error: %s\n", change.Error)
+		}
+	}
+
+	scope := "database and Stripe"
+	if !r.Stripe {
+		scope = "database only"
+	}
+	if r.DryRun {
+		fmt.Fprintf(w, "\nCatalog version %d (%s, dry run): %d changes planned, %d plans in sync\n", r.Version, scope, len(r.Changes), r.Unchanged)
+		return
+	}
+	fmt.Fprintf(w, "\nCatalog version %d (%s): %d changes, %d failed, %d plans in sync\n", r.Version, scope, len(r.Changes), r.Failed(), r.Unchanged)
+}
+
+// PrintJSON writes the report as JSON
+func (r Report) PrintJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Options controls Sync
+type Options struct {
+	DryRun bool // report the changes without applying them
+
+	// Stripe syncs products and prices too. Without it Stripe is left alone, existing price IDs
+	// are kept and new paid plans get placeholder ones
+	Stripe StripeClient
+
+	// CreateOnly only creates missing plans and leaves existing ones untouched, as seeding does
+	CreateOnly bool
+}
+
+type syncer struct {
+	ctx        context.Context
+	app        core.App
+	catalog    *Catalog
+	opts       Options
+	report     *Report
+	collection *core.Collection
+
+	records  map[string]*core.Record // by catalog key
+	products map[string]*stripe.Product
+	prices   map[string]*stripe.Price
+	resolved map[string]string // catalog product key -> Stripe product ID
+}
+
+// Sync brings the subscription_plans collection (and Stripe, with opts.Stripe) in line with the
+// catalog: missing plans, products and prices are created, changed plans updated, and plans
+// removed from the catalog archived. Stripe prices can't change, so a new price replaces an
+// edited one and the old price is archived; existing subscriptions keep being billed at it
+func Sync(ctx context.Context, app core.App, catalog *Catalog, opts Options) (*Report, error) {
+	s := &syncer{
+		ctx:      ctx,
+		app:      app,
+		catalog:  catalog,
+		opts:     opts,
+		report:   &Report{Version: catalog.Version, DryRun: opts.DryRun, Stripe: opts.Stripe != nil},
+		records:  map[string]*core.Record{},
+		resolved: map[string]string{},
+	}
+
+	collection, err := app.FindCollectionByNameOrId("subscription_plans")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find subscription_plans collection: %w", err)
+	}
+	s.collection = collection
+
+	if err := s.loadRecords(); err != nil {
+		return nil, err
+	}
+	if opts.Stripe != nil {
+		if err := s.loadStripe(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Plan records may be cached by ID and price, and this can run without the plan hooks bound
+	defer subscription.InvalidatePlanCache()
+
+	for _, plan := range catalog.Plans {
+		s.syncPlan(plan)
+	}
+	s.syncPairs()
+	if !opts.CreateOnly {
+		s.archiveRemoved()
+	}
+	return s.report, nil
+}
+
+// loadRecords finds the catalog plans' records by catalog_key, falling back to the name for
+// plans created before the catalog
+func (s *syncer) loadRecords() error {
+	records, err := s.app.FindRecordsByFilter(s.collection, "is_custom = false", "", 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to load subscription plans: %w", err)
+	}
+
+	byName := map[string]*core.Record{}
+	for _, record := range records {
+		if key := record.GetString("catalog_key"); key != "" {
+			s.records[key] = record
+		} else {
+			byName[record.GetString("name")] = record
+		}
+	}
+	for _, plan := range s.catalog.Plans {
+		if s.records[plan.Key] == nil && byName[plan.Name] != nil {
+			s.records[plan.Key] = byName[plan.Name]
+		}
+	}
+	return nil
+}
+
+func (s *syncer) loadStripe() error {
+	products, err := s.opts.Stripe.Products(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list Stripe products: %w", err)
+	}
+	prices, err := s.opts.Stripe.Prices(s.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list Stripe prices: %w", err)
+	}
+
+	s.products = make(map[string]*stripe.Product, len(products))
+	for _, p := range products {
+		s.products[p.ID] = p
+	}
+	s.prices = make(map[string]*stripe.Price, len(prices))
+	for _, p := range prices {
+		s.prices[p.ID] = p
+	}
+	return nil
+}
+
+// apply runs fn unless it's a dry run, and records the change either way
+func (s *syncer) apply(change Change, fn func() error) bool {
+	if !s.opts.DryRun {
+		if err := fn(); err != nil {
+			change.Error = err.Error()
+		} else {
+			change.Applied = true
+		}
+	}
+	s.report.Changes = append(s.report.Changes, change)
+	return change.Applied
+}
+
+func (s *syncer) syncPlan(plan Plan) {
+	record := s.records[plan.Key]
+	if record != nil && s.opts.CreateOnly {
+		s.report.Unchanged++
+		return
+	}
+
+	desired := planFields(plan)
+	var replacedPrice string
+	switch {
+	case s.opts.Stripe != nil && plan.Paid():
+		productID, priceID, ok := s.ensurePrice(plan, record)
+		if !ok {
+			return
+		}
+		desired["provider_product_id"] = productID
+		desired["provider_price_id"] = priceID
+		if record != nil && record.GetString("provider_price_id") != priceID {
+			replacedPrice = record.GetString("provider_price_id")
+		}
+	case !plan.Paid():
+		desired["provider_product_id"] = ""
+		desired["provider_price_id"] = ""
+	case record == nil:
+		// Stripe isn't synced: placeholders until `sync-plans` creates the real price
+		desired["provider_product_id"] = "prod_" + plan.Product
+		desired["provider_price_id"] = "price_" + plan.Key
+	}
+
+	if record == nil {
+		record = core.NewRecord(s.collection)
+		for field, value := range desired {
+			record.Set(field, value)
+		}
+		if s.apply(Change{Target: TargetPlan, Key: plan.Key, Action: ActionCreate, Detail: planSummary(plan)}, func() error {
+			return s.app.Save(record)
+		}) {
+			s.records[plan.Key] = record
+		}
+		return
+	}
+
+	changed := changedFields(record, desired)
+	if len(changed) == 0 {
+		s.report.Unchanged++
+		return
+	}
+	for _, field := range changed {
+		record.Set(field, desired[field])
+	}
+	updated := s.apply(Change{Target: TargetPlan, Key: plan.Key, Action: ActionUpdate, Detail: strings.Join(changed, ", ")}, func() error {
+		return s.app.Save(record)
+	})
+
+	// Only retire the old price once the plan points at the new one
+	if replacedPrice != "" && (updated || s.opts.DryRun) {
+		s.archivePrice(replacedPrice, plan.Key+" (replaced)")
+	}
+}
+
+// ensurePrice finds or creates the plan's Stripe product and a price billing what the plan
+// asks for, reusing the record's current price when it still matches
+func (s *syncer) ensurePrice(plan Plan, record *core.Record) (productID, priceID string, ok bool) {
+	productID, ok = s.ensureProduct(plan.Product, record)
+	if !ok {
+		return "", "", false
+	}
+
+	if record != nil {
+		if current := s.prices[record.GetString("provider_price_id")]; current != nil && current.Active &&
+			priceMatches(current, plan) && priceProductID(current) == productID {
+			return productID, current.ID, true
+		}
+	}
+	for _, p := range s.prices {
+		if p.Active && p.Metadata[metadataPlan] == plan.Key && priceMatches(p, plan) && priceProductID(p) == productID {
+			return productID, p.ID, true
+		}
+	}
+
+	detail := fmt.Sprintf("%s %d/%s", strings.ToUpper(plan.Currency), plan.PriceCents, plan.BillingInterval)
+	if productID == "" {
+		// Dry run with a product still to be created
+		s.apply(Change{Target: TargetPrice, Key: plan.Key, Action: ActionCreate, Detail: detail}, nil)
+		return "", "", s.opts.DryRun
+	}
+	var created string
+	if !s.apply(Change{Target: TargetPrice, Key: plan.Key, Action: ActionCreate, Detail: detail}, func() (err error) {
+		created, err = s.opts.Stripe.CreatePrice(s.ctx, productID, plan)
+		return err
+	}) {
+		// A dry run goes on to report the plan changes
+		return productID, "(new price)", s.opts.DryRun
+	}
+	s.prices[created] = &stripe.Price{ID: created, Active: true, Currency: stripe.Currency(plan.Currency), UnitAmount: plan.PriceCents,
+		Product: &stripe.Product{ID: productID}, Recurring: &stripe.PriceRecurring{Interval: stripe.PriceRecurringInterval(plan.BillingInterval)},
+		Metadata: map[string]string{metadataPlan: plan.Key}}
+	return productID, created, true
+}
+
+// ensureProduct finds the Stripe product for a catalog product, by metadata or through the
+// product the plan's record already uses, creating it when there's none. It returns "" with ok
+// on a dry run that would create it
+func (s *syncer) ensureProduct(key string, record *core.Record) (string, bool) {
+	if id, ok := s.resolved[key]; ok {
+		return id, true
+	}
+	product, _ := s.catalog.Product(key)
+
+	var found *stripe.Product
+	for _, p := range s.products {
+		if p.Active && p.Metadata[metadataProduct] == key {
+			found = p
+			break
+		}
+	}
+	if found == nil && record != nil {
+		if p := s.products[record.GetString("provider_product_id")]; p != nil && p.Active {
+			found = p
+		}
+	}
+
+	if found != nil {
+		s.resolved[key] = found.ID
+		if found.Name != product.Name && !s.opts.CreateOnly {
+			s.apply(Change{Target: TargetProduct, Key: key, Action: ActionUpdate, Detail: fmt.Sprintf("name %q -> %q", found.Name, product.Name)}, func() error {
+				return s.opts.Stripe.RenameProduct(s.ctx, found.ID, product.Name)
+			})
+		}
+		return found.ID, true
+	}
+
+	var created string
+	if !s.apply(Change{Target: TargetProduct, Key: key, Action: ActionCreate, Detail: product.Name}, func() (err error) {
+		created, err = s.opts.Stripe.CreateProduct(s.ctx, key, product.Name)
+		return err
+	}) {
+		if s.opts.DryRun {
+			s.resolved[key] = ""
+			return "", true
+		}
+		return "", false
+	}
+	s.products[created] = &stripe.Product{ID: created, Active: true, Name: product.Name, Metadata: map[string]string{metadataProduct: key}}
+	s.resolved[key] = created
+	return created, true
+}
+
+// archivePrice deactivates a Stripe price unless it's already inactive or unknown
+func (s *syncer) archivePrice(id, key string) {
+	if s.opts.Stripe == nil || s.prices[id] == nil || !s.prices[id].Active {
+		return
+	}
+	if s.apply(Change{Target: TargetPrice, Key: key, Action: ActionArchive, Detail: id}, func() error {
+		return s.opts.Stripe.ArchivePrice(s.ctx, id)
+	}) {
+		s.prices[id].Active = false
+	}
+}
+
+// syncPairs links monthly and yearly plans both ways so the pricing page can toggle between them
+func (s *syncer) syncPairs() {
+	for _, plan := range s.catalog.Plans {
+		if plan.PairedWith == "" {
+			continue
+		}
+		for _, pair := range [][2]string{{plan.Key, plan.PairedWith}, {plan.PairedWith, plan.Key}} {
+			record, other := s.records[pair[0]], s.records[pair[1]]
+			if record == nil || other == nil || record.GetString("paired_plan_id") == other.Id {
+				continue
+			}
+			record.Set("paired_plan_id", other.Id)
+			s.apply(Change{Target: TargetPlan, Key: pair[0], Action: ActionUpdate, Detail: "paired with " + pair[1]}, func() error {
+				return s.app.Save(record)
+			})
+		}
+	}
+}
+
+// archiveRemoved deactivates plans, prices and products whose key left the catalog. Plans
+// created outside the catalog (no catalog_key) are never touched
+func (s *syncer) archiveRemoved() {
+	for key, record := range s.records {
+		if _, ok := s.catalog.Plan(key); ok {
+			continue
+		}
+		if record.GetBool("is_active") {
+			record.Set("is_active", false)
+			s.apply(Change{Target: TargetPlan, Key: key, Action: ActionArchive, Detail: "removed from the catalog"}, func() error {
+				return s.app.Save(record)
+			})
+		}
+		s.archivePrice(record.GetString("provider_price_id"), key)
+	}
+
+	for _, p := range s.products {
+		key := p.Metadata[metadataProduct]
+		if key == "" || !p.Active {
+			continue
+		}
+		if _, ok := s.catalog.Product(key); ok {
+			continue
+		}
+		s.apply(Change{Target: TargetProduct, Key: key, Action: ActionArchive, Detail: p.ID}, func() error {
+			return s.opts.Stripe.ArchiveProduct(s.ctx, p.ID)
+		})
+	}
+}
+
+// planFields returns the subscription_plans values a catalog plan sets
+func planFields(plan Plan) map[string]any {
+	features := plan.Features
+	if features == nil {
+		features = []string{}
+	}
+	fields := map[string]any{
+		"catalog_key":         plan.Key,
+		"name":                plan.Name,
+		"price_cents":         plan.PriceCents,
+		"currency":            plan.Currency,
+		"billing_interval":    plan.BillingInterval,
+		"hours_per_month":     plan.HoursPerMonth,
+		"tokens_per_month":    plan.TokensPerMonth,
+		"features":            features,
+		"speaker_diarization": plan.SpeakerDiarization,
+		"is_active":           !plan.Inactive,
+		"payment_provider":    "stripe",
+	}
+
+	optional := map[string]*int{
+		"trial_days":              plan.TrialDays,
+		"max_upload_mb":           plan.MaxUploadMB,
+		"max_processing_attempts": plan.MaxProcessingAttempts,
+		"grace_period_seconds":    plan.GracePeriodSeconds,
+		"bursts_per_year":         plan.BurstsPerYear,
+		"rate_limit_per_minute":   plan.RateLimitPerMinute,
+		"rate_limit_burst":        plan.RateLimitBurst,
+		"retention_days":          plan.RetentionDays,
+	}
+	for field, value := range optional {
+		if value != nil {
+			fields[field] = *value
+		}
+	}
+	if plan.BurstPercent != nil {
+		fields["burst_percent"] = *plan.BurstPercent
+	}
+	if plan.AllowedModels != nil {
+		fields["allowed_models"] = *plan.AllowedModels
+	}
+	return fields
+}
+
+// changedFields returns the fields whose record value differs from desired, sorted
+func changedFields(record *core.Record, desired map[string]any) []string {
+	var changed []string
+	for field, want := range desired {
+		var same bool
+		switch want := want.(type) {
+		case string:
+			same = record.GetString(field) == want
+		case bool:
+			same = record.GetBool(field) == want
+		case int:
+			same = record.GetFloat(field) == float64(want)
+		case int64:
+			same = record.GetFloat(field) == float64(want)
+		case float64:
+			same = record.GetFloat(field) == want
+		case []string:
+			var current []string
+			record.UnmarshalJSONField(field, &current)
+			same = (len(current) == 0 && len(want) == 0) || reflect.DeepEqual(current, want)
+		}
+		if !same {
+			changed = append(changed, field)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func planSummary(plan Plan) string {
+	if !plan.Paid() {
+		return fmt.Sprintf("%s, free, %.1f hours", plan.Name, plan.HoursPerMonth)
+	}
+	return fmt.Sprintf("%s, %s %d/%s, %.1f hours", plan.Name, strings.ToUpper(plan.Currency), plan.PriceCents, plan.BillingInterval, plan.HoursPerMonth)
+}
+
+// CheckAtStartup loads the catalog and warns when the plans have drifted from it, without
+// changing anything
+func CheckAtStartup(app core.App) error {
+	catalog, err := Load()
+	if err != nil {
+		return err
+	}
+	report, err := Sync(context.Background(), app, catalog, Options{DryRun: true})
+	if err != nil {
+		return err
+	}
+	if len(report.Changes) > 0 {
+		log.Printf("[PLANS] Subscription plans differ from catalog version %d (%d changes) - run `pocketbase sync-plans --dry-run` to review them", catalog.Version, len(report.Changes))
+		return nil
+	}
+	log.Printf("[PLANS] Subscription plans match catalog version %d (%d plans)", catalog.Version, len(catalog.Plans))
+	return nil
+}
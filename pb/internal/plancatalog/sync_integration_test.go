@@ -0,0 +1,58 @@
+//go:build integration
+
+package plancatalog_test
+
+import (
+	"context"
+	"testing"
+
+	"pocketbase/internal/plancatalog"
+	"pocketbase/internal/testharness"
+)
+
+func TestSyncAgainstRealSchema(t *testing.T) {
+	app := testharness.New(t)
+	catalog, err := plancatalog.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	dryRun, err := plancatalog.Sync(ctx, app, catalog, plancatalog.Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dryRun.Changes) == 0 {
+		t.Fatal("dry run on an empty database reported no changes")
+	}
+	if total, _ := app.CountRecords("subscription_plans"); total != 0 {
+		t.Fatalf("dry run created %d plans", total)
+	}
+
+	if _, err := plancatalog.Sync(ctx, app, catalog, plancatalog.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if total, _ := app.CountRecords("subscription_plans"); total != int64(len(catalog.Plans)) {
+		t.Fatalf("created %d plans, want %d", total, len(catalog.Plans))
+	}
+
+	again, err := plancatalog.Sync(ctx, app, catalog, plancatalog.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Changes) != 0 {
+		t.Errorf("second sync = %+v, want no changes", again.Changes)
+	}
+
+	pro, err := app.FindFirstRecordByData("subscription_plans", "catalog_key", "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yearly, err := app.FindFirstRecordByData("subscription_plans", "catalog_key", "pro_yearly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pro.GetString("paired_plan_id") != yearly.Id {
+		t.Errorf("pro paired with %q, want pro_yearly %s", pro.GetString("paired_plan_id"), yearly.Id)
+	}
+}
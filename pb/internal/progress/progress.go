@@ -0,0 +1,244 @@
+// Package progress records how far uploads and transcriptions have got on processing_jobs
+// records. Their owner can read them, so clients follow progress over a PocketBase realtime
+// subscription instead of polling
+package progress
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Stages, in the order a file goes through them
+const (
+	StageUploading      = "uploading"
+	StageQueued         = "queued"
+	StageTranscribing   = "transcribing"
+	StagePostProcessing = "post_processing"
+	StageCompleted      = "completed"
+	StageFailed         = "failed"
+)
+
+// Sources of a processing job - source_id is the TUS upload id or the transcription_jobs id
+const (
+	SourceUpload           = "upload"
+	SourceTranscriptionJob = "transcription_job"
+)
+
+// stageBands is the range of overall progress each stage covers
+var stageBands = map[string][2]int{
+	StageUploading:      {0, 40},
+	StageQueued:         {40, 40},
+	StageTranscribing:   {40, 90},
+	StagePostProcessing: {90, 99},
+	StageCompleted:      {100, 100},
+}
+
+// saveInterval throttles writes within a stage - every save is a realtime event to the client
+const saveInterval = time.Second
+
+// streamedShare is the part of the transcribing stage covered by sending the audio to the
+// provider; the rest is waiting for its answer
+const streamedShare = 80
+
+// Overall maps progress within a stage to progress of the whole job
+func Overall(stage string, stagePercent int) int {
+	band, ok := stageBands[stage]
+	if !ok {
+		return 0
+	}
+	stagePercent = min(max(stagePercent, 0), 100)
+	return band[0] + (band[1]-band[0])*stagePercent/100
+}
+
+// Tracker updates one processing_jobs record. A nil Tracker ignores every call, so callers
+// don't need to check whether tracking could start
+type Tracker struct {
+	app      core.App
+	mu       sync.Mutex
+	record   *core.Record
+	lastSave time.Time
+}
+
+// Track resumes the processing job of a source, or starts one at the given stage
+func Track(app core.App, userID, source, sourceID, filename, stage string, bytesTotal int64) *Tracker {
+	record, err := app.FindFirstRecordByFilter("processing_jobs", "source = {:source} && source_id = {:id}",
+		map[string]any{"source": source, "id": sourceID})
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("processing_jobs")
+		if err != nil {
+			return nil
+		}
+		record = core.NewRecord(collection)
+		record.Set("user_id", userID)
+		record.Set("source", source)
+		record.Set("source_id", sourceID)
+		record.Set("filename", filename)
+		record.Set("stage", stage)
+		record.Set("percent", Overall(stage, 0))
+		record.Set("bytes_total", bytesTotal)
+		if err := app.Save(record); err != nil {
+			slog.Warn("Failed to start processing job", "source", source, "source_id", sourceID, "error", err)
+			return nil
+		}
+	}
+	return &Tracker{app: app, record: record, lastSave: time.Now()}
+}
+
+// ID is the processing_jobs record id
+func (t *Tracker) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.record.Id
+}
+
+// Update reports progress within a stage. Moving to another stage is saved straight away,
+// progress within one at most every saveInterval. Overall progress never goes backwards, and
+// a completed or failed job ignores late updates
+func (t *Tracker) Update(stage string, stagePercent int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.set(stage, stagePercent, false)
+}
+
+// Bytes reports how much of the file has been uploaded. total is 0 while the client hasn't
+// declared the size yet
+func (t *Tracker) Bytes(done, total int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record.Set("bytes_done", done)
+	percent := 0
+	if total > 0 {
+		t.record.Set("bytes_total", total)
+		percent = int(min(done, total) * 100 / total)
+	}
+	t.set(StageUploading, percent, false)
+}
+
+// Reader reports transcribing progress from the bytes of r sent to the provider, mapped to
+// from..to percent of the stage (0..100 for a whole file, a slice of it for one chunk of several)
+func (t *Tracker) Reader(r io.Reader, size int64, from, to int) io.Reader {
+	if t == nil || size <= 0 {
+		return r
+	}
+	return &trackedReader{Reader: r, tracker: t, size: size, from: from, to: to}
+}
+
+// Complete marks the job finished
+func (t *Tracker) Complete() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.finished() {
+		return
+	}
+	t.record.Set("completed_at", time.Now())
+	t.set(StageCompleted, 100, true)
+}
+
+// Fail marks the job failed, keeping the progress it had reached
+func (t *Tracker) Fail(message string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.finished() {
+		return
+	}
+	t.record.Set("stage", StageFailed)
+	t.record.Set("error", message)
+	t.record.Set("completed_at", time.Now())
+	t.save()
+}
+
+func (t *Tracker) set(stage string, stagePercent int, force bool) {
+	if t.finished() {
+		return
+	}
+	stagePercent = min(max(stagePercent, 0), 100)
+	changed := stage != t.record.GetString("stage")
+	if !changed && stagePercent == t.record.GetInt("stage_percent") {
+		return
+	}
+
+	t.record.Set("stage", stage)
+	t.record.Set("stage_percent", stagePercent)
+	t.record.Set("percent", max(t.record.GetInt("percent"), Overall(stage, stagePercent)))
+	if force || changed || time.Since(t.lastSave) >= saveInterval {
+		t.save()
+	}
+}
+
+func (t *Tracker) finished() bool {
+	stage := t.record.GetString("stage")
+	return stage == StageCompleted || stage == StageFailed
+}
+
+func (t *Tracker) save() {
+	t.lastSave = time.Now()
+	if err := t.app.Save(t.record); err != nil {
+		slog.Warn("Failed to save processing progress", "processing_job_id", t.record.Id, "error", err)
+	}
+}
+
+type trackedReader struct {
+	io.Reader
+	tracker  *Tracker
+	size     int64
+	read     int64
+	from, to int
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += int64(n)
+	sent := min(r.read, r.size) * streamedShare / r.size
+	r.tracker.Update(StageTranscribing, r.from+(r.to-r.from)*int(sent)/100)
+	return n, err
+}
+
+// finishedRetention is how long finished processing jobs are kept
+const finishedRetention = 7 * 24 * time.Hour
+
+// PurgeFinished deletes processing jobs that completed or failed over a week ago - run daily
+func PurgeFinished(app core.App) {
+	cutoff := time.Now().Add(-finishedRetention).UTC().Format("2006-01-02 15:04:05.000Z")
+	purged := 0
+	for {
+		records, err := app.FindRecordsByFilter("processing_jobs", "completed_at != '' && completed_at < {:cutoff}", "", 500, 0,
+			map[string]any{"cutoff": cutoff})
+		if err != nil {
+			slog.Error("Failed to load finished processing jobs", "error", err)
+			return
+		}
+		for _, record := range records {
+			if err := app.Delete(record); err != nil {
+				slog.Error("Failed to delete processing job", "processing_job_id", record.Id, "error", err)
+				return
+			}
+			purged++
+		}
+		if len(records) < 500 {
+			if purged > 0 {
+				slog.Info("Purged finished processing jobs", "count", purged)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package progress
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOverallFollowsStageOrder(t *testing.T) {
+	cases := []struct {
+		stage        string
+		stagePercent int
+		want         int
+	}{
+		{StageUploading, 0, 0},
+		{StageUploading, 50, 20},
+		{StageUploading, 100, 40},
+		{StageQueued, 0, 40},
+		{StageTranscribing, 50, 65},
+		{StagePostProcessing, 0, 90},
+		{StageCompleted, 0, 100},
+		{StageUploading, 250, 40}, // clamped
+		{"unknown", 50, 0},
+	}
+	for _, c := range cases {
+		if got := Overall(c.stage, c.stagePercent); got != c.want {
+			t.Errorf("Overall(%q, %d) = %d, want %d", c.stage, c.stagePercent, got, c.want)
+		}
+	}
+}
+
+func TestNilTrackerIsANoop(t *testing.T) {
+	var tracker *Tracker
+	tracker.Update(StageTranscribing, 10)
+	tracker.Bytes(10, 100)
+	tracker.Complete()
+	tracker.Fail("boom")
+	if tracker.ID() != "" {
+		t.Fatal("nil tracker should have no id")
+	}
+
+	// Without a tracker the reader is passed through untouched
+	src := strings.NewReader("audio")
+	if r := tracker.Reader(src, 5, 0, 100); r != io.Reader(src) {
+		t.Fatal("nil tracker should not wrap the reader")
+	}
+}
@@ -0,0 +1,31 @@
+package progress
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields progress tracking reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "progress",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "processing_jobs",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "source", Type: "select", Values: []string{SourceUpload, SourceTranscriptionJob}},
+					{Name: "source_id", Type: "text"},
+					{Name: "filename", Type: "text"},
+					{Name: "stage", Type: "select", Values: []string{StageUploading, StageQueued, StageTranscribing, StagePostProcessing, StageCompleted, StageFailed}},
+					{Name: "percent", Type: "number"},
+					{Name: "stage_percent", Type: "number"},
+					{Name: "bytes_total", Type: "number"},
+					{Name: "bytes_done", Type: "number"},
+					{Name: "error", Type: "text"},
+					{Name: "completed_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_processing_jobs_source", Columns: "source, source_id"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,264 @@
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79"
+
+	"pocketbase/internal/subscription"
+)
+
+// localCustomer is a payment_customers row
+type localCustomer struct {
+	UserID     string
+	CustomerID string
+}
+
+// localSubscription is a Stripe-managed current_user_subscriptions row
+type localSubscription struct {
+	UserID      string
+	ProviderID  string
+	PriceID     string
+	Status      string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+}
+
+// snapshot is the billing state stored locally
+type snapshot struct {
+	Customers     []localCustomer
+	Subscriptions []localSubscription
+}
+
+// loadLocal reads every customer mapping and Stripe-managed subscription
+func loadLocal(app core.App) (snapshot, error) {
+	var local snapshot
+
+	customers, err := app.FindRecordsByFilter("payment_customers", "provider_customer_id != ''", "", 0, 0)
+	if err != nil {
+		return local, fmt.Errorf("failed to load payment customers: %w", err)
+	}
+	for _, record := range customers {
+		local.Customers = append(local.Customers, localCustomer{
+			UserID:     record.GetString("user_id"),
+			CustomerID: record.GetString("provider_customer_id"),
+		})
+	}
+
+	subscriptions, err := app.FindRecordsByFilter("current_user_subscriptions",
+		"payment_provider = 'stripe' && provider_subscription_id != ''", "", 0, 0)
+	if err != nil {
+		return local, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+	for _, record := range subscriptions {
+		local.Subscriptions = append(local.Subscriptions, localSubscription{
+			UserID:      record.GetString("user_id"),
+			ProviderID:  record.GetString("provider_subscription_id"),
+			PriceID:     record.GetString("provider_price_id"),
+			Status:      record.GetString("status"),
+			PeriodStart: record.GetDateTime("current_period_start").Time(),
+			PeriodEnd:   record.GetDateTime("current_period_end").Time(),
+		})
+	}
+
+	return local, nil
+}
+
+// userLookup resolves a Stripe customer to a user: the user_id set in its metadata at checkout
+// when that user still exists, otherwise the user with the customer's email
+func userLookup(app core.App) func(userID, email string) string {
+	return func(userID, email string) string {
+		if userID != "" {
+			if _, err := app.FindRecordById("users", userID); err == nil {
+				return userID
+			}
+		}
+		if email != "" {
+			if user, err := app.FindAuthRecordByEmail("users", email); err == nil {
+				return user.Id
+			}
+		}
+		return ""
+	}
+}
+
+// liveStatus reports whether a Stripe subscription should have a local record. Incomplete
+// subscriptions are checkouts that never finished
+func liveStatus(status stripe.SubscriptionStatus) bool {
+	switch status {
+	case stripe.SubscriptionStatusCanceled, stripe.SubscriptionStatusIncomplete, stripe.SubscriptionStatusIncompleteExpired:
+		return false
+	}
+	return true
+}
+
+// compare lists the differences between Stripe and the local records, customers first
+func compare(customers []*stripe.Customer, subscriptions []*stripe.Subscription, local snapshot, lookup func(userID, email string) string) []Drift {
+	var drift []Drift
+
+	mappingByCustomer := map[string]localCustomer{}
+	mappingByUser := map[string]localCustomer{}
+	for _, mapping := range local.Customers {
+		mappingByCustomer[mapping.CustomerID] = mapping
+		mappingByUser[mapping.UserID] = mapping
+	}
+
+	paying := map[string]bool{}
+	for _, sub := range subscriptions {
+		if sub.Customer != nil && liveStatus(sub.Status) {
+			paying[sub.Customer.ID] = true
+		}
+	}
+
+	// Users each customer resolves to, including mappings created by a repair
+	userByCustomer := map[string]string{}
+	stripeCustomers := map[string]bool{}
+
+	for _, cust := range customers {
+		if cust.Deleted {
+			continue
+		}
+		stripeCustomers[cust.ID] = true
+		metadataUserID := cust.Metadata["user_id"]
+
+		if mapping, ok := mappingByCustomer[cust.ID]; ok {
+			userByCustomer[cust.ID] = mapping.UserID
+			if metadataUserID != "" && metadataUserID != mapping.UserID {
+				drift = append(drift, Drift{
+					Kind: KindCustomerConflict, UserID: mapping.UserID, CustomerID: cust.ID,
+					Message: fmt.Sprintf("mapped to user %s but Stripe metadata names user %s", mapping.UserID, metadataUserID),
+				})
+			}
+			continue
+		}
+
+		userID := lookup(metadataUserID, cust.Email)
+		if userID == "" {
+			if paying[cust.ID] {
+				drift = append(drift, Drift{
+					Kind: KindUnknownCustomer, CustomerID: cust.ID,
+					Message: fmt.Sprintf("customer %s has a live subscription but matches no user", cust.Email),
+				})
+			}
+			continue
+		}
+
+		if existing, ok := mappingByUser[userID]; ok {
+			drift = append(drift, Drift{
+				Kind: KindCustomerConflict, UserID: userID, CustomerID: cust.ID,
+				Message: fmt.Sprintf("user is already mapped to customer %s", existing.CustomerID),
+			})
+			continue
+		}
+
+		userByCustomer[cust.ID] = userID
+		mappingByUser[userID] = localCustomer{UserID: userID, CustomerID: cust.ID}
+		drift = append(drift, Drift{
+			Kind: KindMissingCustomer, UserID: userID, CustomerID: cust.ID, Repairable: true,
+			Message: "no payment_customers row for this customer",
+		})
+	}
+
+	for _, mapping := range local.Customers {
+		if !stripeCustomers[mapping.CustomerID] {
+			drift = append(drift, Drift{
+				Kind: KindOrphanCustomer, UserID: mapping.UserID, CustomerID: mapping.CustomerID,
+				Message: "customer not found in Stripe - it may have been deleted",
+			})
+		}
+	}
+
+	localByProvider := map[string]localSubscription{}
+	for _, sub := range local.Subscriptions {
+		localByProvider[sub.ProviderID] = sub
+	}
+
+	validator := subscription.NewValidator(nil)
+	stripeSubscriptions := map[string]bool{}
+
+	for _, sub := range subscriptions {
+		stripeSubscriptions[sub.ID] = true
+		customerID := ""
+		if sub.Customer != nil {
+			customerID = sub.Customer.ID
+		}
+		userID, mapped := userByCustomer[customerID]
+		existing, ok := localByProvider[sub.ID]
+
+		if !liveStatus(sub.Status) {
+			if ok && existing.Status != string(subscription.StatusCanceled) {
+				drift = append(drift, Drift{
+					Kind: KindStaleSubscription, UserID: existing.UserID, CustomerID: customerID, SubscriptionID: sub.ID,
+					Message:    fmt.Sprintf("%s locally but %s in Stripe", existing.Status, sub.Status),
+					Repairable: mapped, stripeSub: sub,
+				})
+			}
+			continue
+		}
+
+		if !ok {
+			message := fmt.Sprintf("%s subscription has no local record", sub.Status)
+			if !mapped {
+				message += " and its customer isn't mapped to a user"
+			}
+			drift = append(drift, Drift{
+				Kind: KindMissingSubscription, UserID: userID, CustomerID: customerID, SubscriptionID: sub.ID,
+				Message: message, Repairable: mapped, stripeSub: sub,
+			})
+			continue
+		}
+
+		if differences := subscriptionDifferences(existing, sub, validator); len(differences) > 0 {
+			drift = append(drift, Drift{
+				Kind: KindSubscriptionDrift, UserID: existing.UserID, CustomerID: customerID, SubscriptionID: sub.ID,
+				Message: strings.Join(differences, ", "), Repairable: mapped, stripeSub: sub,
+			})
+		}
+	}
+
+	for _, sub := range local.Subscriptions {
+		if !stripeSubscriptions[sub.ProviderID] && sub.Status != string(subscription.StatusCanceled) {
+			drift = append(drift, Drift{
+				Kind: KindStaleSubscription, UserID: sub.UserID, SubscriptionID: sub.ProviderID,
+				Message: fmt.Sprintf("%s locally but not found in Stripe", sub.Status),
+			})
+		}
+	}
+
+	return drift
+}
+
+// subscriptionDifferences describes how a local subscription differs from Stripe's copy
+func subscriptionDifferences(local localSubscription, sub *stripe.Subscription, validator *subscription.Validator) []string {
+	var differences []string
+
+	if status := string(validator.MapStripeStatus(sub.Status)); status != local.Status {
+		differences = append(differences, fmt.Sprintf("status %s → %s", local.Status, status))
+	}
+	if priceID, err := validator.ExtractPriceFromSubscription(sub); err == nil && priceID != local.PriceID {
+		differences = append(differences, fmt.Sprintf("price %s → %s", local.PriceID, priceID))
+	}
+	if start := time.Unix(sub.CurrentPeriodStart, 0); !sameSecond(start, local.PeriodStart) {
+		differences = append(differences, fmt.Sprintf("period start %s → %s", formatDate(local.PeriodStart), formatDate(start)))
+	}
+	if end := time.Unix(sub.CurrentPeriodEnd, 0); !sameSecond(end, local.PeriodEnd) {
+		differences = append(differences, fmt.Sprintf("period end %s → %s", formatDate(local.PeriodEnd), formatDate(end)))
+	}
+
+	return differences
+}
+
+// sameSecond compares timestamps at the precision Stripe reports them
+func sameSecond(a, b time.Time) bool {
+	return a.Unix() == b.Unix()
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "unset"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,96 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stripe/stripe-go/v79"
+)
+
+func stripeSubscription(id, customerID, priceID string, status stripe.SubscriptionStatus, start, end time.Time) *stripe.Subscription {
+	return &stripe.Subscription{
+		ID:                 id,
+		Customer:           &stripe.Customer{ID: customerID},
+		Status:             status,
+		CurrentPeriodStart: start.Unix(),
+		CurrentPeriodEnd:   end.Unix(),
+		Items: &stripe.SubscriptionItemList{Data: []*stripe.SubscriptionItem{
+			{Price: &stripe.Price{ID: priceID}},
+		}},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	start := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	customers := []*stripe.Customer{
+		{ID: "cus_synced", Metadata: map[string]string{"user_id": "user_synced"}},
+		{ID: "cus_unmapped", Email: "new@example.com"},
+		{ID: "cus_stranger", Email: "stranger@example.com"},
+		{ID: "cus_deleted", Deleted: true},
+	}
+	subscriptions := []*stripe.Subscription{
+		stripeSubscription("sub_synced", "cus_synced", "price_pro", stripe.SubscriptionStatusActive, start, end),
+		stripeSubscription("sub_drifted", "cus_synced", "price_pro", stripe.SubscriptionStatusPastDue, start, end),
+		stripeSubscription("sub_missing", "cus_unmapped", "price_pro", stripe.SubscriptionStatusActive, start, end),
+		stripeSubscription("sub_cancelled", "cus_synced", "price_pro", stripe.SubscriptionStatusCanceled, start, end),
+		stripeSubscription("sub_stranger", "cus_stranger", "price_pro", stripe.SubscriptionStatusActive, start, end),
+		stripeSubscription("sub_incomplete", "cus_synced", "price_pro", stripe.SubscriptionStatusIncomplete, start, end),
+	}
+	local := snapshot{
+		Customers: []localCustomer{
+			{UserID: "user_synced", CustomerID: "cus_synced"},
+			{UserID: "user_gone", CustomerID: "cus_gone"},
+		},
+		Subscriptions: []localSubscription{
+			{UserID: "user_synced", ProviderID: "sub_synced", PriceID: "price_pro", Status: "active", PeriodStart: start, PeriodEnd: end},
+			{UserID: "user_synced", ProviderID: "sub_drifted", PriceID: "price_pro", Status: "active", PeriodStart: start, PeriodEnd: start},
+			{UserID: "user_synced", ProviderID: "sub_cancelled", PriceID: "price_pro", Status: "active", PeriodStart: start, PeriodEnd: end},
+			{UserID: "user_gone", ProviderID: "sub_vanished", PriceID: "price_pro", Status: "active", PeriodStart: start, PeriodEnd: end},
+		},
+	}
+	lookup := func(userID, email string) string {
+		if email == "new@example.com" {
+			return "user_new"
+		}
+		return ""
+	}
+
+	drift := compare(customers, subscriptions, local, lookup)
+
+	want := []struct {
+		kind, id   string
+		repairable bool
+	}{
+		{KindMissingCustomer, "cus_unmapped", true},
+		{KindUnknownCustomer, "cus_stranger", false},
+		{KindOrphanCustomer, "cus_gone", false},
+		{KindSubscriptionDrift, "sub_drifted", true},
+		{KindMissingSubscription, "sub_missing", true},
+		{KindStaleSubscription, "sub_cancelled", true},
+		{KindMissingSubscription, "sub_stranger", false},
+		{KindStaleSubscription, "sub_vanished", false},
+	}
+	if len(drift) != len(want) {
+		t.Fatalf("expected %d differences, got %d: %+v", len(want), len(drift), drift)
+	}
+	for i, w := range want {
+		got := drift[i]
+		id := got.SubscriptionID
+		if id == "" {
+			id = got.CustomerID
+		}
+		if got.Kind != w.kind || id != w.id || got.Repairable != w.repairable {
+			t.Errorf("difference %d = %s %s repairable=%v, want %s %s repairable=%v",
+				i, got.Kind, id, got.Repairable, w.kind, w.id, w.repairable)
+		}
+	}
+
+	if drift[0].UserID != "user_new" || drift[4].UserID != "user_new" {
+		t.Errorf("unmapped customer should resolve to user_new, got %q and %q", drift[0].UserID, drift[4].UserID)
+	}
+	if drift[3].Message != "status active → past_due, period end 2026-09-01T00:00:00Z → 2026-10-01T00:00:00Z" {
+		t.Errorf("unexpected drift message %q", drift[3].Message)
+	}
+}
@@ -0,0 +1,202 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stripe/stripe-go/v79"
+	"github.com/stripe/stripe-go/v79/customer"
+	stripesubscription "github.com/stripe/stripe-go/v79/subscription"
+
+	"pocketbase/internal/subscription"
+)
+
+// Kinds of drift between Stripe and the local billing records
+const (
+	KindMissingCustomer     = "missing_customer_mapping"  // Stripe customer for a known user has no payment_customers row
+	KindCustomerConflict    = "customer_mapping_conflict" // the mapping points at a different user than Stripe does
+	KindUnknownCustomer     = "unknown_customer"          // paying Stripe customer that matches no user
+	KindOrphanCustomer      = "orphan_customer_mapping"   // payment_customers row for a customer Stripe doesn't have
+	KindMissingSubscription = "missing_subscription"      // live Stripe subscription with no local record
+	KindSubscriptionDrift   = "subscription_drift"        // status, price or period differs from Stripe
+	KindStaleSubscription   = "stale_subscription"        // local subscription that Stripe has cancelled or doesn't have
+)
+
+// Drift is one difference between Stripe and the database
+type Drift struct {
+	Kind           string `json:"kind"`
+	UserID         string `json:"user_id,omitempty"`
+	CustomerID     string `json:"customer_id,omitempty"`
+	SubscriptionID string `json:"subscription_id,omitempty"`
+	Message        string `json:"message"`
+	Repairable     bool   `json:"repairable"`
+	Repaired       bool   `json:"repaired"`
+	Error          string `json:"error,omitempty"`
+
+	stripeSub *stripe.Subscription
+}
+
+// Report is the outcome of a reconciliation run
+type Report struct {
+	Customers     int     `json:"stripe_customers"`
+	Subscriptions int     `json:"stripe_subscriptions"`
+	Drift         []Drift `json:"drift"`
+}
+
+// Outstanding returns how many differences are left after the run
+func (r Report) Outstanding() int {
+	n := 0
+	for _, drift := range r.Drift {
+		if !drift.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Print writes one line per difference followed by a summary
+func (r Report) Print(w io.Writer) {
+	for _, drift := range r.Drift {
+		label := "DRIFT"
+		switch {
+		case drift.Repaired:
+			label = "FIXED"
+		case drift.Error != "":
+			label = "ERROR"
+		case !drift.Repairable:
+			label = "MANUAL"
+		}
+		ids := strings.TrimSpace(strings.Join([]string{drift.UserID, drift.CustomerID, drift.SubscriptionID}, " "))
+		fmt.Fprintf(w, "[%-6s] %-26s %s - %s\n", label, drift.Kind, ids, drift.Message)
+		if drift.Error != "" {
+			fmt.Fprintf(w, "         %-26s error: %s\n", "", drift.Error)
+		}
+	}
+	fmt.Fprintf(w, "\nChecked %d Stripe customers and %d subscriptions: %d differences, %d repaired, %d outstanding\n",
+		r.Customers, r.Subscriptions, len(r.Drift), len(r.Drift)-r.Outstanding(), r.Outstanding())
+}
+
+// PrintJSON writes the report as JSON
+func (r Report) PrintJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Source lists everything in the Stripe account
+type Source interface {
+	Customers(ctx context.Context) ([]*stripe.Customer, error)
+	Subscriptions(ctx context.Context) ([]*stripe.Subscription, error)
+}
+
+// StripeSource reads from the Stripe API using the key in stripe.Key
+type StripeSource struct{}
+
+// Customers implements Source
+func (StripeSource) Customers(ctx context.Context) ([]*stripe.Customer, error) {
+	params := &stripe.CustomerListParams{}
+	params.Context = ctx
+	params.Limit = stripe.Int64(100)
+
+	var customers []*stripe.Customer
+	iter := customer.List(params)
+	for iter.Next() {
+		customers = append(customers, iter.Customer())
+	}
+	return customers, iter.Err()
+}
+
+// Subscriptions implements Source. Cancelled subscriptions are included so stale local
+// records can be closed
+func (StripeSource) Subscriptions(ctx context.Context) ([]*stripe.Subscription, error) {
+	params := &stripe.SubscriptionListParams{Status: stripe.String("all")}
+	params.Context = ctx
+	params.Limit = stripe.Int64(100)
+
+	var subscriptions []*stripe.Subscription
+	iter := stripesubscription.List(params)
+	for iter.Next() {
+		subscriptions = append(subscriptions, iter.Subscription())
+	}
+	return subscriptions, iter.Err()
+}
+
+// Options configures a reconciliation run
+type Options struct {
+	Repair  bool // fix what can be fixed safely; everything else is only reported
+	Source  Source
+	Service subscription.Service // applies subscription repairs the way webhooks do
+}
+
+// Run compares Stripe with payment_customers and current_user_subscriptions and, with
+// Repair, creates missing customer mappings and resyncs subscriptions from Stripe
+func Run(ctx context.Context, app core.App, opts Options) (*Report, error) {
+	if opts.Source == nil {
+		opts.Source = StripeSource{}
+	}
+	if opts.Service == nil {
+		opts.Service = subscription.NewService(subscription.NewRepository(app))
+	}
+
+	customers, err := opts.Source.Customers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Stripe customers: %w", err)
+	}
+	subscriptions, err := opts.Source.Subscriptions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Stripe subscriptions: %w", err)
+	}
+
+	local, err := loadLocal(app)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Customers:     len(customers),
+		Subscriptions: len(subscriptions),
+		Drift:         compare(customers, subscriptions, local, userLookup(app)),
+	}
+
+	if opts.Repair {
+		for i := range report.Drift {
+			drift := &report.Drift[i]
+			if !drift.Repairable {
+				continue
+			}
+			if err := repair(app, opts.Service, drift); err != nil {
+				drift.Error = err.Error()
+				continue
+			}
+			drift.Repaired = true
+		}
+	}
+
+	return report, nil
+}
+
+// repair fixes one difference. Customer mappings come first in the report, so subscriptions
+// that depended on a missing mapping can be resynced afterwards
+func repair(app core.App, service subscription.Service, drift *Drift) error {
+	switch drift.Kind {
+	case KindMissingCustomer:
+		collection, err := app.FindCollectionByNameOrId("payment_customers")
+		if err != nil {
+			return err
+		}
+		record := core.NewRecord(collection)
+		record.Set("user_id", drift.UserID)
+		record.Set("provider_customer_id", drift.CustomerID)
+		return app.Save(record)
+	case KindMissingSubscription, KindSubscriptionDrift:
+		return service.HandleSubscriptionEvent(drift.stripeSub, "customer.subscription.updated")
+	case KindStaleSubscription:
+		return service.HandleSubscriptionEvent(drift.stripeSub, "customer.subscription.deleted")
+	default:
+		return fmt.Errorf("%s can't be repaired automatically", drift.Kind)
+	}
+}
@@ -0,0 +1,31 @@
+package retention
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RunRequest represents an admin request to apply the retention policy now
+type RunRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+// RunHandler applies the configured retention policy on demand (superusers only)
+// POST /api/admin/retention/run - with dry_run the report lists what would be pruned
+func RunHandler(e *core.RequestEvent, app core.App) error {
+	var req RunRequest
+	if e.Request.ContentLength > 0 {
+		if err := e.BindBody(&req); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+
+	report, err := Run(app, PolicyFromEnv(), req.DryRun)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Retention run failed: %v", err)})
+	}
+
+	return e.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,287 @@
+package retention
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Retention modes selected with the PROCESSED_FILES_RETENTION_MODE env variable
+const (
+	ModeArchive = "archive" // fold expired records into processed_file_summaries, then delete them (default)
+	ModeDelete  = "delete"  // delete expired records without keeping a summary
+)
+
+// Policy is the global retention configuration
+// A plan's retention_days overrides GlobalDays for its subscribers; 0 keeps records forever
+type Policy struct {
+	GlobalDays int
+	Mode       string
+}
+
+// PolicyFromEnv reads PROCESSED_FILES_RETENTION_DAYS and PROCESSED_FILES_RETENTION_MODE
+func PolicyFromEnv() Policy {
+	policy := Policy{Mode: ModeArchive}
+	if v := os.Getenv("PROCESSED_FILES_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil && days > 0 {
+			policy.GlobalDays = days
+		}
+	}
+	if strings.ToLower(os.Getenv("PROCESSED_FILES_RETENTION_MODE")) == ModeDelete {
+		policy.Mode = ModeDelete
+	}
+	return policy
+}
+
+// MonthSummary holds the usage totals of one user's processed files for one month
+type MonthSummary struct {
+	YearMonth            string  `json:"year_month"`
+	TotalFiles           int     `json:"total_files"`
+	TotalDurationSeconds float64 `json:"total_duration_seconds"`
+	TotalFileSizeBytes   int64   `json:"total_file_size_bytes"`
+	TotalProcessingMs    int64   `json:"total_processing_time_ms"`
+	CompletedCount       int     `json:"completed_count"`
+	ProcessingCount      int     `json:"processing_count"`
+	FailedCount          int     `json:"failed_count"`
+}
+
+// Add counts one processed file in the summary
+func (s *MonthSummary) Add(durationSeconds float64, fileSizeBytes, processingMs int64, status string) {
+	s.TotalFiles++
+	s.TotalDurationSeconds += durationSeconds
+	s.TotalFileSizeBytes += fileSizeBytes
+	s.TotalProcessingMs += processingMs
+
+	switch status {
+	case "completed":
+		s.CompletedCount++
+	case "processing":
+		s.ProcessingCount++
+	case "failed":
+		s.FailedCount++
+	}
+}
+
+// Report describes what a retention run pruned (or would prune in dry-run mode)
+type Report struct {
+	DryRun          bool     `json:"dry_run"`
+	Mode            string   `json:"mode"`
+	UsersProcessed  int      `json:"users_processed"`
+	RecordsDeleted  int      `json:"records_deleted"`
+	RecordsArchived int      `json:"records_archived"`
+	MonthsArchived  int      `json:"months_archived"`
+	Errors          []string `json:"errors"`
+}
+
+// Run applies the retention policy to processed_files
+// Each user is pruned in its own transaction so one failure doesn't block the others
+func Run(app core.App, policy Policy, dryRun bool) (*Report, error) {
+	report := &Report{DryRun: dryRun, Mode: policy.Mode, Errors: []string{}}
+
+	planDays, err := loadPlanRetention(app)
+	if err != nil {
+		return nil, err
+	}
+
+	minDays := shortestRetention(policy.GlobalDays, planDays)
+	if minDays == 0 {
+		return report, nil
+	}
+
+	// Only users with records older than the shortest policy can have anything to prune
+	var rows []struct {
+		UserID string `db:"user_id"`
+	}
+	err = app.DB().NewQuery("SELECT DISTINCT user_id FROM processed_files WHERE created < {:cutoff}").
+		Bind(dbx.Params{"cutoff": cutoffFor(minDays).String()}).
+		All(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find users with expired records: %w", err)
+	}
+
+	for _, row := range rows {
+		days := policy.GlobalDays
+		if planID := currentPlanID(app, row.UserID); planID != "" {
+			if d, ok := planDays[planID]; ok && d > 0 {
+				days = d
+			}
+		}
+		if days == 0 {
+			continue
+		}
+
+		var pruneErr error
+		if dryRun {
+			pruneErr = pruneUser(app, row.UserID, cutoffFor(days), policy.Mode, false, report)
+		} else {
+			pruneErr = app.RunInTransaction(func(txApp core.App) error {
+				return pruneUser(txApp, row.UserID, cutoffFor(days), policy.Mode, true, report)
+			})
+		}
+		if pruneErr != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("user %s: %v", row.UserID, pruneErr))
+			continue
+		}
+		report.UsersProcessed++
+	}
+
+	return report, nil
+}
+
+// RunScheduled runs the retention policy from the environment and logs the outcome
+func RunScheduled(app core.App) {
+	policy := PolicyFromEnv()
+
+	report, err := Run(app, policy, false)
+	if err != nil {
+		log.Printf("[RETENTION] ERROR: Retention run failed: %v", err)
+		return
+	}
+
+	log.Printf("[RETENTION] Pruned %d processed_files records for %d users (mode=%s, archived=%d, months=%d)",
+		report.RecordsDeleted, report.UsersProcessed, report.Mode, report.RecordsArchived, report.MonthsArchived)
+	for _, e := range report.Errors {
+		log.Printf("[RETENTION] ERROR: %s", e)
+	}
+}
+
+// pruneUser archives and deletes one user's records created before cutoff
+func pruneUser(app core.App, userID string, cutoff types.DateTime, mode string, apply bool, report *Report) error {
+	records, err := app.FindRecordsByFilter("processed_files", "user_id = {:user} && created < {:cutoff}", "+created", 0, 0, map[string]any{
+		"user":   userID,
+		"cutoff": cutoff.String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load expired records: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if mode == ModeArchive {
+		summaries := summarize(records)
+		for _, summary := range summaries {
+			if apply {
+				if err := mergeSummary(app, userID, summary); err != nil {
+					return err
+				}
+			}
+			report.RecordsArchived += summary.TotalFiles
+		}
+		report.MonthsArchived += len(summaries)
+	}
+
+	for _, record := range records {
+		if apply {
+			if err := app.Delete(record); err != nil {
+				return fmt.Errorf("failed to delete record %s: %w", record.Id, err)
+			}
+		}
+		report.RecordsDeleted++
+	}
+
+	return nil
+}
+
+// summarize groups processed files by month
+// Chunk records and deleted files are deleted but not counted - usage endpoints only report
+// flattened files that haven't been deleted
+func summarize(records []*core.Record) []*MonthSummary {
+	byMonth := map[string]*MonthSummary{}
+	for _, record := range records {
+		if record.GetBool("is_chunk") || !record.GetDateTime("deleted_at").IsZero() {
+			continue
+		}
+		month := record.GetDateTime("created").Time().UTC().Format("2006-01")
+		summary, ok := byMonth[month]
+		if !ok {
+			summary = &MonthSummary{YearMonth: month}
+			byMonth[month] = summary
+		}
+		summary.Add(record.GetFloat("duration_seconds"), int64(record.GetInt("file_size_bytes")),
+			int64(record.GetInt("processing_time_ms")), record.GetString("status"))
+	}
+
+	summaries := make([]*MonthSummary, 0, len(byMonth))
+	for _, summary := range byMonth {
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].YearMonth < summaries[j].YearMonth })
+	return summaries
+}
+
+// mergeSummary adds a monthly summary to the user's archived totals for that month
+func mergeSummary(app core.App, userID string, summary *MonthSummary) error {
+	record, err := app.FindFirstRecordByFilter("processed_file_summaries", "user_id = {:user} && year_month = {:month}", map[string]any{
+		"user":  userID,
+		"month": summary.YearMonth,
+	})
+	if err != nil {
+		collection, err := app.FindCollectionByNameOrId("processed_file_summaries")
+		if err != nil {
+			return fmt.Errorf("failed to find processed_file_summaries collection: %w", err)
+		}
+		record = core.NewRecord(collection)
+		record.Set("user_id", userID)
+		record.Set("year_month", summary.YearMonth)
+	}
+
+	record.Set("total_files", record.GetInt("total_files")+summary.TotalFiles)
+	record.Set("total_duration_seconds", record.GetFloat("total_duration_seconds")+summary.TotalDurationSeconds)
+	record.Set("total_file_size_bytes", int64(record.GetInt("total_file_size_bytes"))+summary.TotalFileSizeBytes)
+	record.Set("total_processing_time_ms", int64(record.GetInt("total_processing_time_ms"))+summary.TotalProcessingMs)
+	record.Set("completed_count", record.GetInt("completed_count")+summary.CompletedCount)
+	record.Set("processing_count", record.GetInt("processing_count")+summary.ProcessingCount)
+	record.Set("failed_count", record.GetInt("failed_count")+summary.FailedCount)
+
+	if err := app.Save(record); err != nil {
+		return fmt.Errorf("failed to save summary for %s: %w", summary.YearMonth, err)
+	}
+	return nil
+}
+
+// loadPlanRetention returns the retention_days override of every plan that sets one
+func loadPlanRetention(app core.App) (map[string]int, error) {
+	plans, err := app.FindRecordsByFilter("subscription_plans", "retention_days > 0", "", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plan retention policies: %w", err)
+	}
+
+	days := map[string]int{}
+	for _, plan := range plans {
+		days[plan.Id] = plan.GetInt("retention_days")
+	}
+	return days, nil
+}
+
+// currentPlanID returns the plan of the user's current subscription, if any
+func currentPlanID(app core.App, userID string) string {
+	sub, err := app.FindFirstRecordByFilter("current_user_subscriptions", "user_id = {:user}", map[string]any{"user": userID})
+	if err != nil {
+		return ""
+	}
+	return sub.GetString("plan_id")
+}
+
+// shortestRetention returns the smallest non-zero retention across the global and plan policies
+func shortestRetention(globalDays int, planDays map[string]int) int {
+	shortest := globalDays
+	for _, days := range planDays {
+		if days > 0 && (shortest == 0 || days < shortest) {
+			shortest = days
+		}
+	}
+	return shortest
+}
+
+func cutoffFor(days int) types.DateTime {
+	return types.NowDateTime().Add(-time.Duration(days) * 24 * time.Hour)
+}
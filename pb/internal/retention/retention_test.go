@@ -0,0 +1,43 @@
+package retention
+
+import "testing"
+
+func TestShortestRetention(t *testing.T) {
+	testCases := []struct {
+		global   int
+		plans    map[string]int
+		expected int
+	}{
+		{0, map[string]int{}, 0},
+		{365, map[string]int{}, 365},
+		{365, map[string]int{"free": 90, "pro": 730}, 90},
+		{0, map[string]int{"pro": 730}, 730},
+		{30, map[string]int{"pro": 730}, 30},
+	}
+
+	for _, tc := range testCases {
+		if got := shortestRetention(tc.global, tc.plans); got != tc.expected {
+			t.Errorf("Expected shortestRetention(%d, %v) to be %d, got %d", tc.global, tc.plans, tc.expected, got)
+		}
+	}
+}
+
+func TestMonthSummaryAdd(t *testing.T) {
+	summary := &MonthSummary{YearMonth: "2025-01"}
+	summary.Add(120, 1024, 500, "completed")
+	summary.Add(60.5, 2048, 300, "failed")
+	summary.Add(0, 0, 0, "unknown")
+
+	if summary.TotalFiles != 3 {
+		t.Errorf("Expected 3 files, got %d", summary.TotalFiles)
+	}
+	if summary.TotalDurationSeconds != 180.5 {
+		t.Errorf("Expected 180.5 seconds, got %v", summary.TotalDurationSeconds)
+	}
+	if summary.TotalFileSizeBytes != 3072 || summary.TotalProcessingMs != 800 {
+		t.Errorf("Unexpected size/processing totals: %+v", summary)
+	}
+	if summary.CompletedCount != 1 || summary.FailedCount != 1 || summary.ProcessingCount != 0 {
+		t.Errorf("Unexpected status counts: %+v", summary)
+	}
+}
@@ -0,0 +1,20 @@
+package retention
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the manual retention run (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/retention/run", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return RunHandler(e, app)
+		}},
+	)
+}
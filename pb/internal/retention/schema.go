@@ -0,0 +1,35 @@
+package retention
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the retention job reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "retention",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "retention_days", Type: "number"},
+				},
+			},
+			{
+				Collection: "processed_file_summaries",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "year_month", Type: "text"},
+					{Name: "total_files", Type: "number"},
+					{Name: "total_duration_seconds", Type: "number"},
+					{Name: "total_file_size_bytes", Type: "number"},
+					{Name: "total_processing_time_ms", Type: "number"},
+					{Name: "completed_count", Type: "number"},
+					{Name: "processing_count", Type: "number"},
+					{Name: "failed_count", Type: "number"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_processed_file_summaries_user_month", Unique: true, Columns: "user_id, year_month"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,268 @@
+package rls
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Audit modes selected with the RLS_AUDIT_MODE env variable
+const (
+	ModeWarn = "warn" // log findings only (default)
+	ModeFix  = "fix"  // restore the expected rules
+	ModeFail = "fail" // refuse to start when findings exist
+)
+
+// Severity of an audit finding
+const (
+	SeverityCritical = "critical" // rule grants public access
+	SeverityWarning  = "warning"  // rule differs from the expected rule
+)
+
+// ownerRule is the standard "only the owning user" rule used by per-user collections
+const ownerRule = "@request.auth.id != '' && user_id = @request.auth.id"
+
+// superuserRule restricts writes to superusers (Go handlers bypass rules via the app)
+const superuserRule = `@request.auth.collectionName = "_superusers"`
+
+// ExpectedRules holds the API rules a collection must have
+// nil means "superusers only", matching PocketBase's locked rule
+type ExpectedRules struct {
+	ListRule   *string
+	ViewRule   *string
+	CreateRule *string
+	UpdateRule *string
+	DeleteRule *string
+}
+
+// Finding describes a single rule that doesn't match expectations
+type Finding struct {
+	Collection string  `json:"collection"`
+	Rule       string  `json:"rule"`
+	Severity   string  `json:"severity"`
+	Expected   *string `json:"expected"`
+	Actual     *string `json:"actual"`
+	Message    string  `json:"message"`
+}
+
+func rule(r string) *string {
+	return &r
+}
+
+// CriticalCollections maps the collections whose access control must never drift
+// to the rules declared in the schema migrations
+var CriticalCollections = map[string]ExpectedRules{
+	// Counts towards the processing attempt limit - only the server writes to it, owners delete
+	// and restore through /api/usage/files
+	"processed_files": {
+		ListRule: rule(ownerRule),
+		ViewRule: rule(ownerRule),
+	},
+	"api_keys": {
+		ListRule:   rule(ownerRule),
+		ViewRule:   rule(ownerRule),
+		CreateRule: rule(ownerRule),
+		UpdateRule: rule(ownerRule),
+		DeleteRule: rule(ownerRule),
+	},
+	// Holds uploaded audio and transcripts - only the worker writes to it
+	"transcription_jobs": {
+		ListRule: rule(ownerRule),
+		ViewRule: rule(ownerRule),
+	},
+	// Where each API key was used from, and alerts on suspicious use - only the server writes to them
+	"api_key_fingerprints": {
+		ListRule: rule(ownerRule),
+		ViewRule: rule(ownerRule),
+	},
+	"api_key_alerts": {
+		ListRule: rule(ownerRule),
+		ViewRule: rule(ownerRule),
+	},
+	// Upload and transcription progress, followed over realtime - only the server writes to it
+	"processing_jobs": {
+		ListRule: rule(ownerRule),
+		ViewRule: rule(ownerRule),
+	},
+	// Chunked upload sessions - created and updated through /api/ai/audio-sessions only
+	"audio_upload_sessions": {
+		ListRule: rule(ownerRule),
+		ViewRule: rule(ownerRule),
+	},
+	// Uploaded audio chunks and their transcripts - superusers only
+	"audio_upload_chunks": {},
+	// Estimated upstream cost of every AI call - superusers only
+	"cost_ledger": {},
+	// Raw payment provider events and their errors - superusers only
+	"payment_webhook_events": {},
+	"current_user_subscriptions": {
+		ListRule:   rule(`@request.auth.id != "" && user_id = @request.auth.id`),
+		ViewRule:   rule(`@request.auth.id != "" && user_id = @request.auth.id`),
+		CreateRule: rule(superuserRule),
+		UpdateRule: rule(superuserRule),
+		DeleteRule: rule(superuserRule),
+	},
+}
+
+// Run audits the critical collections and applies the configured RLS_AUDIT_MODE
+// An error is only returned in fail mode, or when auto-fixing fails
+func Run(app core.App) error {
+	mode := strings.ToLower(os.Getenv("RLS_AUDIT_MODE"))
+	if mode == "" {
+		mode = ModeWarn
+	}
+
+	findings, err := Audit(app)
+	if err != nil {
+		return err
+	}
+
+	if len(findings) == 0 {
+		log.Printf("[RLS] Audit passed for %d critical collections", len(CriticalCollections))
+		return nil
+	}
+
+	for _, f := range findings {
+		log.Printf("[RLS] %s: %s.%s - %s (expected %s, got %s)",
+			strings.ToUpper(f.Severity), f.Collection, f.Rule, f.Message, describe(f.Expected), describe(f.Actual))
+	}
+
+	switch mode {
+	case ModeFix:
+		if err := Fix(app, findings); err != nil {
+			return err
+		}
+		log.Printf("[RLS] Restored %d API rules to their expected values", len(findings))
+		return nil
+	case ModeFail:
+		return fmt.Errorf("RLS audit found %d problems on critical collections", len(findings))
+	default:
+		log.Printf("[RLS] WARNING: %d API rule problems found - set RLS_AUDIT_MODE=fix to restore them", len(findings))
+		return nil
+	}
+}
+
+// Audit compares the API rules of every critical collection with the expected rules
+func Audit(app core.App) ([]Finding, error) {
+	findings := []Finding{}
+
+	names := make([]string, 0, len(CriticalCollections))
+	for name := range CriticalCollections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		expected := CriticalCollections[name]
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			findings = append(findings, Finding{
+				Collection: name,
+				Severity:   SeverityCritical,
+				Message:    "collection is missing",
+			})
+			continue
+		}
+
+		checks := []struct {
+			name     string
+			expected *string
+			actual   *string
+		}{
+			{"listRule", expected.ListRule, collection.ListRule},
+			{"viewRule", expected.ViewRule, collection.ViewRule},
+			{"createRule", expected.CreateRule, collection.CreateRule},
+			{"updateRule", expected.UpdateRule, collection.UpdateRule},
+			{"deleteRule", expected.DeleteRule, collection.DeleteRule},
+		}
+
+		for _, c := range checks {
+			if finding := checkRule(name, c.name, c.expected, c.actual); finding != nil {
+				findings = append(findings, *finding)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// checkRule returns a finding when a rule differs from the expected one
+func checkRule(collection, ruleName string, expected, actual *string) *Finding {
+	if sameRule(expected, actual) {
+		return nil
+	}
+
+	finding := &Finding{
+		Collection: collection,
+		Rule:       ruleName,
+		Severity:   SeverityWarning,
+		Expected:   expected,
+		Actual:     actual,
+		Message:    "rule differs from the expected rule",
+	}
+
+	// An empty (non-nil) rule lets anyone, including guests, perform the action
+	if actual != nil && strings.TrimSpace(*actual) == "" {
+		finding.Severity = SeverityCritical
+		finding.Message = "rule allows public access"
+	} else if actual != nil && !strings.Contains(*actual, "@request.auth") {
+		finding.Severity = SeverityCritical
+		finding.Message = "rule does not check the authenticated user"
+	}
+
+	return finding
+}
+
+// Fix restores the expected rules for every finding on an existing collection
+func Fix(app core.App, findings []Finding) error {
+	for _, f := range findings {
+		if f.Rule == "" {
+			continue // missing collections can't be fixed here
+		}
+
+		collection, err := app.FindCollectionByNameOrId(f.Collection)
+		if err != nil {
+			return fmt.Errorf("failed to load collection %s: %w", f.Collection, err)
+		}
+
+		switch f.Rule {
+		case "listRule":
+			collection.ListRule = f.Expected
+		case "viewRule":
+			collection.ViewRule = f.Expected
+		case "createRule":
+			collection.CreateRule = f.Expected
+		case "updateRule":
+			collection.UpdateRule = f.Expected
+		case "deleteRule":
+			collection.DeleteRule = f.Expected
+		}
+
+		if err := app.Save(collection); err != nil {
+			return fmt.Errorf("failed to fix %s.%s: %w", f.Collection, f.Rule, err)
+		}
+	}
+
+	return nil
+}
+
+func sameRule(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return strings.TrimSpace(*a) == strings.TrimSpace(*b)
+}
+
+func describe(r *string) string {
+	if r == nil {
+		return "superusers only"
+	}
+	if *r == "" {
+		return "public"
+	}
+	return fmt.Sprintf("%q", *r)
+}
@@ -0,0 +1,32 @@
+//go:build integration
+
+package rls_test
+
+import (
+	"testing"
+
+	"pocketbase/internal/rls"
+	"pocketbase/internal/testharness"
+)
+
+// The expected rules must match what the migrations leave, otherwise fix mode would undo them
+func TestAuditPassesOnMigratedSchema(t *testing.T) {
+	app := testharness.New(t)
+
+	findings, err := rls.Audit(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range findings {
+		t.Errorf("%s.%s: %s", f.Collection, f.Rule, f.Message)
+	}
+}
+
+func TestRunFailModeStartsOnMigratedSchema(t *testing.T) {
+	t.Setenv("RLS_AUDIT_MODE", rls.ModeFail)
+	app := testharness.New(t)
+
+	if err := rls.Run(app); err != nil {
+		t.Errorf("Run() = %v", err)
+	}
+}
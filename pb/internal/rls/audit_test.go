@@ -0,0 +1,31 @@
+package rls
+
+import "testing"
+
+func TestCheckRule(t *testing.T) {
+	expected := rule(ownerRule)
+
+	if finding := checkRule("api_keys", "listRule", expected, rule(ownerRule)); finding != nil {
+		t.Errorf("Expected matching rules to pass, got %+v", finding)
+	}
+
+	finding := checkRule("api_keys", "listRule", expected, rule(""))
+	if finding == nil || finding.Severity != SeverityCritical {
+		t.Errorf("Expected public rule to be critical, got %+v", finding)
+	}
+
+	finding = checkRule("api_keys", "listRule", expected, rule("active = true"))
+	if finding == nil || finding.Severity != SeverityCritical {
+		t.Errorf("Expected rule without auth check to be critical, got %+v", finding)
+	}
+
+	finding = checkRule("api_keys", "listRule", expected, nil)
+	if finding == nil || finding.Severity != SeverityWarning {
+		t.Errorf("Expected locked rule to be a warning, got %+v", finding)
+	}
+
+	finding = checkRule("api_keys", "listRule", expected, rule("@request.auth.id != ''"))
+	if finding == nil || finding.Severity != SeverityWarning {
+		t.Errorf("Expected differing auth rule to be a warning, got %+v", finding)
+	}
+}
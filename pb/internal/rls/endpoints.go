@@ -0,0 +1,37 @@
+package rls
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// AuditHandler reports API rule problems on critical collections (superusers only)
+func AuditHandler(e *core.RequestEvent, app core.App) error {
+	findings, err := Audit(app)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Audit failed: %v", err)})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"passed":   len(findings) == 0,
+		"findings": findings,
+	})
+}
+
+// FixHandler restores the expected API rules on critical collections (superusers only)
+func FixHandler(e *core.RequestEvent, app core.App) error {
+	findings, err := Audit(app)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Audit failed: %v", err)})
+	}
+
+	if err := Fix(app, findings); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": fmt.Sprintf("Fix failed: %v", err)})
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"fixed": findings,
+	})
+}
@@ -0,0 +1,23 @@
+package rls
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the API rule audit routes (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/rls-audit", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return AuditHandler(e, app)
+		}},
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/rls-audit/fix", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return FixHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,149 @@
+package routes
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+	"github.com/pocketbase/pocketbase/tools/router"
+
+	"pocketbase/internal/cors"
+)
+
+// Auth is the authentication a route requires before its handler runs
+type Auth int
+
+const (
+	// AuthHandler leaves authentication to the handler - user sessions, API keys
+	// and webhook signatures are all checked there
+	AuthHandler Auth = iota
+	// AuthSuperuser rejects anything but a superuser session
+	AuthSuperuser
+)
+
+// Middleware runs before a route's handler and calls e.Next() to continue
+type Middleware = func(e *core.RequestEvent) error
+
+// Route declares an endpoint and the middleware it's wrapped in
+type Route struct {
+	Method  string
+	Path    string
+	Handler func(e *core.RequestEvent) error
+
+	Auth        Auth
+	BodyLimit   int64          // bytes; 0 keeps PocketBase's 32MB default
+	BodyLimiter Middleware     // replaces BodyLimit with a limit picked per request, e.g. by the caller's plan
+	CORS        *cors.Override // adjusts the global CORS policy for this route, e.g. extra headers
+	Middlewares []Middleware   // route-specific checks such as SLA tracking or organization abilities, in order
+	RateLimited bool           // per API key and per IP limits, applied after Middlewares
+	Unversioned bool           // served only at Path, e.g. webhooks and protocols whose URL is configured elsewhere
+	Doc         *Doc           // summary and JSON bodies for the OpenAPI spec
+}
+
+// Doc describes a route in the OpenAPI spec. Request and Response are values of the JSON bodies'
+// types, e.g. TextProcessingRequest{}, which the spec's schemas are generated from
+type Doc struct {
+	Summary  string
+	Request  any
+	Response any
+	Status   int // success status; 0 means 200
+}
+
+// Options holds the cross-cutting middleware routes opt into
+type Options struct {
+	// CORS is the global policy, which route overrides are applied to
+	CORS      cors.Policy
+	RateLimit Middleware
+	// Instrument builds a per-route middleware bound before everything else, so request
+	// metrics see rejections by the body limit, auth and rate limiter too
+	Instrument func(route Route) Middleware
+}
+
+// Registry registers declared routes on the PocketBase router
+type Registry struct {
+	App core.App
+
+	router  *router.Router[*core.RequestEvent]
+	options Options
+	routes  []Route
+}
+
+// New creates a registry for the router of the serve event
+func New(app core.App, r *router.Router[*core.RequestEvent], options Options) *Registry {
+	return &Registry{App: app, router: r, options: options}
+}
+
+// Add registers routes, composing their middleware in a fixed order:
+// instrumentation, API version, body limit, auth, route middlewares, rate limit.
+// /api routes are served under /api/v{n} for every supported version, and at their
+// unversioned path as a deprecated alias, unless they're Unversioned
+func (r *Registry) Add(routes ...Route) {
+	sunset := legacySunset()
+	for _, route := range routes {
+		if route.Unversioned || VersionedPath(route.Path, CurrentVersion) == route.Path {
+			r.register(route, route.Path)
+		} else {
+			for _, version := range SupportedVersions {
+				r.register(route, VersionedPath(route.Path, version), versionMiddleware(version))
+			}
+			r.register(route, route.Path, versionMiddleware(0), deprecationMiddleware(sunset))
+		}
+
+		r.routes = append(r.routes, route)
+	}
+}
+
+// register adds the route at path, with the versioning middleware right after instrumentation
+func (r *Registry) register(route Route, path string, versioning ...Middleware) {
+	registered := r.router.Route(route.Method, path, route.Handler)
+
+	if route.CORS != nil {
+		policy := r.options.CORS.Apply(*route.CORS).Middleware()
+		registered.Bind(policy)
+
+		// Preflights of single-method routes would otherwise get the global policy
+		if route.Method != "" && route.Method != http.MethodOptions && !r.router.HasRoute(http.MethodOptions, path) {
+			r.router.Route(http.MethodOptions, path, func(e *core.RequestEvent) error {
+				return e.NoContent(http.StatusNoContent)
+			}).Bind(policy)
+		}
+	}
+
+	if r.options.Instrument != nil {
+		registered.BindFunc(r.options.Instrument(route))
+	}
+	for _, middleware := range versioning {
+		registered.BindFunc(middleware)
+	}
+
+	if route.BodyLimiter != nil {
+		// Same id as PocketBase's limit, so it replaces the default instead of stacking on it
+		registered.Bind(&hook.Handler[*core.RequestEvent]{
+			Id:       apis.DefaultBodyLimitMiddlewareId,
+			Priority: apis.DefaultBodyLimitMiddlewarePriority,
+			Func:     route.BodyLimiter,
+		})
+	} else if route.BodyLimit > 0 {
+		registered.Bind(apis.BodyLimit(route.BodyLimit))
+	}
+	if route.Auth == AuthSuperuser {
+		registered.Bind(apis.RequireSuperuserAuth())
+	}
+	for _, middleware := range route.Middlewares {
+		registered.BindFunc(middleware)
+	}
+	if route.RateLimited {
+		if r.options.RateLimit == nil {
+			log.Printf("Warning: %s %s is rate limited but no rate limiter is configured", route.Method, path)
+		} else {
+			registered.BindFunc(r.options.RateLimit)
+		}
+	}
+}
+
+// Routes returns every route added so far, in registration order
+func (r *Registry) Routes() []Route {
+	return r.routes
+}
@@ -0,0 +1,136 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+
+	"pocketbase/internal/cors"
+)
+
+func newTestRouter() *router.Router[*core.RequestEvent] {
+	return router.NewRouter(func(w http.ResponseWriter, r *http.Request) (*core.RequestEvent, router.EventCleanupFunc) {
+		return &core.RequestEvent{Event: router.Event{Response: w, Request: r}}, nil
+	})
+}
+
+func serve(t *testing.T, r *router.Router[*core.RequestEvent], req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	mux, err := r.BuildMux()
+	if err != nil {
+		t.Fatalf("BuildMux: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var calls []string
+	track := func(name string) Middleware {
+		return func(e *core.RequestEvent) error {
+			calls = append(calls, name)
+			return e.Next()
+		}
+	}
+
+	r := newTestRouter()
+	registry := New(nil, r, Options{
+		RateLimit: track("rate_limit"),
+		Instrument: func(route Route) Middleware {
+			return track("instrument " + route.Path)
+		},
+	})
+	registry.Add(Route{
+		Method:      http.MethodPost,
+		Path:        "/api/test",
+		Middlewares: []Middleware{track("first"), track("second")},
+		RateLimited: true,
+		Handler: func(e *core.RequestEvent) error {
+			calls = append(calls, "handler")
+			return e.NoContent(http.StatusNoContent)
+		},
+	})
+
+	rec := serve(t, r, httptest.NewRequest(http.MethodPost, "/api/test", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if want := []string{"instrument /api/test", "first", "second", "rate_limit", "handler"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if len(registry.Routes()) != 1 {
+		t.Errorf("Routes() = %d routes, want 1", len(registry.Routes()))
+	}
+}
+
+func TestSuperuserAuthRejectsAnonymous(t *testing.T) {
+	r := newTestRouter()
+	handled := false
+	New(nil, r, Options{}).Add(Route{
+		Method: http.MethodGet,
+		Path:   "/api/admin/test",
+		Auth:   AuthSuperuser,
+		Handler: func(e *core.RequestEvent) error {
+			handled = true
+			return e.NoContent(http.StatusNoContent)
+		},
+	})
+
+	rec := serve(t, r, httptest.NewRequest(http.MethodGet, "/api/admin/test", nil))
+	if rec.Code != http.StatusUnauthorized || handled {
+		t.Errorf("anonymous request: status = %d, handled = %v", rec.Code, handled)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	r := newTestRouter()
+	New(nil, r, Options{}).Add(Route{
+		Method:    http.MethodPost,
+		Path:      "/api/upload",
+		BodyLimit: 8,
+		Handler: func(e *core.RequestEvent) error {
+			return e.NoContent(http.StatusNoContent)
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", strings.NewReader("more than eight bytes"))
+	if rec := serve(t, r, req); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status = %d, want 413", rec.Code)
+	}
+}
+
+func TestCORSOverride(t *testing.T) {
+	r := newTestRouter()
+	registry := New(nil, r, Options{CORS: cors.Policy{AllowedOrigins: []string{"https://app.example.com"}}})
+	registry.Add(Route{
+		Method: http.MethodPost,
+		Path:   "/api/upload",
+		CORS:   &cors.Override{AllowHeaders: []string{"Upload-Offset"}},
+		Handler: func(e *core.RequestEvent) error {
+			return e.NoContent(http.StatusNoContent)
+		},
+	})
+
+	preflight := httptest.NewRequest(http.MethodOptions, "/api/upload", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := serve(t, r, preflight)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("preflight Access-Control-Allow-Origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Upload-Offset") {
+		t.Errorf("preflight Access-Control-Allow-Headers = %q, want the override's headers", got)
+	}
+
+	other := httptest.NewRequest(http.MethodPost, "/api/upload", nil)
+	other.Header.Set("Origin", "https://evil.example.com")
+	if got := serve(t, r, other).Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("disallowed origin got Access-Control-Allow-Origin = %q", got)
+	}
+}
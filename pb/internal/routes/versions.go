@@ -0,0 +1,99 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Versioning: every /api route is served under /api/v{n} for each supported version, with the
+// unversioned path kept as a deprecated alias of the current version. Clients can also ask for
+// a version with the API-Version header; handlers that change between versions branch on
+// Version(e). Shipping v2 means adding it to SupportedVersions and branching where it differs
+
+// CurrentVersion is the version unversioned paths and requests without API-Version get
+const CurrentVersion = 1
+
+// SupportedVersions are the API versions routes are registered under, oldest first
+var SupportedVersions = []int{1}
+
+// VersionHeader names the API version requested by the client and the one that answered
+const VersionHeader = "API-Version"
+
+const versionContextKey = "apiVersion"
+
+// VersionedPath returns a route path under /api/v{version}, or path itself for paths outside
+// /api/ that aren't versioned
+func VersionedPath(path string, version int) string {
+	if !strings.HasPrefix(path, "/api/") {
+		return path
+	}
+	return fmt.Sprintf("/api/v%d/%s", version, strings.TrimPrefix(path, "/api/"))
+}
+
+// Version returns the API version a request is served as: the path's version, otherwise the
+// API-Version header, otherwise CurrentVersion
+func Version(e *core.RequestEvent) int {
+	if version, ok := e.Get(versionContextKey).(int); ok {
+		return version
+	}
+	return CurrentVersion
+}
+
+// legacyDeprecated is when unversioned paths were deprecated: the day /api/v1 shipped
+var legacyDeprecated = time.Date(2026, time.October, 16, 0, 0, 0, 0, time.UTC)
+
+// legacySunset reads API_LEGACY_SUNSET: the date (YYYY-MM-DD) unversioned paths stop working,
+// sent in their Sunset header. Without it they're only marked deprecated
+func legacySunset() time.Time {
+	sunset, _ := time.Parse(time.DateOnly, os.Getenv("API_LEGACY_SUNSET"))
+	return sunset
+}
+
+// versionMiddleware serves a request as pathVersion, or on unversioned paths (pathVersion 0) as
+// the version in its API-Version header
+func versionMiddleware(pathVersion int) Middleware {
+	return func(e *core.RequestEvent) error {
+		version := pathVersion
+		if requested := e.Request.Header.Get(VersionHeader); requested != "" && version == 0 {
+			v, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(requested), "v"))
+			if err != nil || !slices.Contains(SupportedVersions, v) {
+				return e.JSON(http.StatusBadRequest, map[string]any{
+					"error":     fmt.Sprintf("Unsupported API version %q", requested),
+					"code":      "UNSUPPORTED_API_VERSION",
+					"supported": SupportedVersions,
+				})
+			}
+			version = v
+		}
+		if version == 0 {
+			version = CurrentVersion
+		}
+		e.Set(versionContextKey, version)
+		e.Response.Header().Set(VersionHeader, strconv.Itoa(version))
+		return e.Next()
+	}
+}
+
+// deprecationMiddleware marks a response from an unversioned path as deprecated since
+// legacyDeprecated (RFC 9745 wants a structured date, @ and Unix seconds) and points at the
+// versioned path that replaces it
+func deprecationMiddleware(sunset time.Time) Middleware {
+	deprecation := fmt.Sprintf("@%d", legacyDeprecated.Unix())
+	return func(e *core.RequestEvent) error {
+		header := e.Response.Header()
+		header.Set("Deprecation", deprecation)
+		if !sunset.IsZero() {
+			header.Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		successor := VersionedPath(e.Request.URL.Path, Version(e))
+		header.Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		return e.Next()
+	}
+}
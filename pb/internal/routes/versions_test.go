@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestVersionedPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/usage/summary": "/api/v1/usage/summary",
+		"/api/ai/jobs/{id}":  "/api/v1/ai/jobs/{id}",
+		"/metrics":           "/metrics",
+		"/send-otp":          "/send-otp",
+		"/api":               "/api",
+	}
+	for path, want := range tests {
+		if got := VersionedPath(path, 1); got != want {
+			t.Errorf("VersionedPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	t.Setenv("API_LEGACY_SUNSET", "2027-06-30")
+	r := newTestRouter()
+	var served []int
+	handler := func(e *core.RequestEvent) error {
+		served = append(served, Version(e))
+		return e.NoContent(http.StatusNoContent)
+	}
+	New(nil, r, Options{}).Add(
+		Route{Method: http.MethodGet, Path: "/api/usage/{id}", Handler: handler},
+		Route{Method: http.MethodPost, Path: "/api/webhooks/stripe", Handler: handler, Unversioned: true},
+	)
+
+	rec := serve(t, r, httptest.NewRequest(http.MethodGet, "/api/v1/usage/abc", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get(VersionHeader) != "1" {
+		t.Fatalf("versioned path: status = %d, %s = %q", rec.Code, VersionHeader, rec.Header().Get(VersionHeader))
+	}
+	if rec.Header().Get("Deprecation") != "" {
+		t.Error("versioned path marked deprecated")
+	}
+
+	rec = serve(t, r, httptest.NewRequest(http.MethodGet, "/api/usage/abc", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Deprecation") != "@1792108800" {
+		t.Fatalf("legacy path: status = %d, Deprecation = %q", rec.Code, rec.Header().Get("Deprecation"))
+	}
+	if got := rec.Header().Get("Link"); got != `</api/v1/usage/abc>; rel="successor-version"` {
+		t.Errorf("legacy path Link = %q", got)
+	}
+	if got := rec.Header().Get("Sunset"); got != "Wed, 30 Jun 2027 00:00:00 GMT" {
+		t.Errorf("legacy path Sunset = %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/usage/abc", nil)
+	req.Header.Set(VersionHeader, "v1")
+	if rec := serve(t, r, req); rec.Code != http.StatusNoContent {
+		t.Errorf("API-Version v1: status = %d", rec.Code)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/api/usage/abc", nil)
+	req.Header.Set(VersionHeader, "2")
+	if rec := serve(t, r, req); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsupported API-Version: status = %d, want 400", rec.Code)
+	}
+	if len(served) != 3 || served[0] != 1 || served[2] != 1 {
+		t.Errorf("handler saw versions %v, want [1 1 1]", served)
+	}
+
+	if rec := serve(t, r, httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/stripe", nil)); rec.Code != http.StatusNotFound {
+		t.Errorf("unversioned route under /api/v1: status = %d, want 404", rec.Code)
+	}
+	rec = serve(t, r, httptest.NewRequest(http.MethodPost, "/api/webhooks/stripe", nil))
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Deprecation") != "" {
+		t.Errorf("unversioned route: status = %d, Deprecation = %q", rec.Code, rec.Header().Get("Deprecation"))
+	}
+}
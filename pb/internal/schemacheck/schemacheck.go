@@ -0,0 +1,201 @@
+package schemacheck
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Field describes a collection field a module reads or writes
+// Type uses PocketBase field type names: text, number, bool, date, json, select, relation, file
+type Field struct {
+	Name   string
+	Type   string
+	Values []string // required select values, if Type is select
+}
+
+// Index describes an index a module relies on for correctness or performance
+type Index struct {
+	Name    string
+	Unique  bool
+	Columns string
+}
+
+// Requirement lists what a module needs from one collection
+type Requirement struct {
+	Collection string
+	Fields     []Field
+	Indexes    []Index
+}
+
+// Module groups the schema requirements declared by one internal package
+type Module struct {
+	Name         string
+	Requirements []Requirement
+}
+
+// Problem kinds reported by Check
+const (
+	MissingCollection  = "missing_collection"
+	MissingField       = "missing_field"
+	WrongFieldType     = "wrong_field_type"
+	MissingSelectValue = "missing_select_value"
+	MissingIndex       = "missing_index"
+)
+
+// Problem describes a single difference between a module's requirements and the deployed schema
+type Problem struct {
+	Module     string
+	Collection string
+	Kind       string
+	Detail     string
+	Suggestion string
+}
+
+// Check compares the deployed collections with the requirements of every module
+func Check(app core.App, modules ...Module) []Problem {
+	problems := []Problem{}
+
+	for _, module := range modules {
+		for _, req := range module.Requirements {
+			collection, err := app.FindCollectionByNameOrId(req.Collection)
+			if err != nil {
+				problems = append(problems, Problem{
+					Module:     module.Name,
+					Collection: req.Collection,
+					Kind:       MissingCollection,
+					Detail:     fmt.Sprintf("collection %q does not exist", req.Collection),
+					Suggestion: fmt.Sprintf("run `pocketbase migrate up` to create the %q collection", req.Collection),
+				})
+				continue
+			}
+
+			for _, field := range req.Fields {
+				problems = append(problems, checkField(module.Name, collection, field)...)
+			}
+
+			for _, index := range req.Indexes {
+				if collection.GetIndex(index.Name) != "" {
+					continue
+				}
+				problems = append(problems, Problem{
+					Module:     module.Name,
+					Collection: req.Collection,
+					Kind:       MissingIndex,
+					Detail:     fmt.Sprintf("index %q is missing", index.Name),
+					Suggestion: fmt.Sprintf("collection.AddIndex(%q, %v, %q, \"\")", index.Name, index.Unique, index.Columns),
+				})
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkField verifies that a field exists with the expected type and select values
+func checkField(module string, collection *core.Collection, field Field) []Problem {
+	existing := collection.Fields.GetByName(field.Name)
+	if existing == nil {
+		return []Problem{{
+			Module:     module,
+			Collection: collection.Name,
+			Kind:       MissingField,
+			Detail:     fmt.Sprintf("field %q (%s) is missing", field.Name, field.Type),
+			Suggestion: fmt.Sprintf("collection.Fields.Add(%s)", fieldConstructor(field)),
+		}}
+	}
+
+	if existing.Type() != field.Type {
+		return []Problem{{
+			Module:     module,
+			Collection: collection.Name,
+			Kind:       WrongFieldType,
+			Detail:     fmt.Sprintf("field %q is %s, expected %s", field.Name, existing.Type(), field.Type),
+			Suggestion: fmt.Sprintf("rename the existing field and add collection.Fields.Add(%s), then migrate the data", fieldConstructor(field)),
+		}}
+	}
+
+	selectField, ok := existing.(*core.SelectField)
+	if !ok || len(field.Values) == 0 {
+		return nil
+	}
+
+	missing := []string{}
+	for _, value := range field.Values {
+		if !contains(selectField.Values, value) {
+			missing = append(missing, value)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return []Problem{{
+		Module:     module,
+		Collection: collection.Name,
+		Kind:       MissingSelectValue,
+		Detail:     fmt.Sprintf("select field %q is missing values %v", field.Name, missing),
+		Suggestion: fmt.Sprintf("collection.Fields.GetByName(%q).(*core.SelectField).Values = append(<existing values>, %s)", field.Name, quoteAll(missing)),
+	}}
+}
+
+// Run checks the schema and logs every problem with its migration suggestion
+// Drift is reported rather than fatal, so a partially migrated deployment still starts
+func Run(app core.App, modules ...Module) []Problem {
+	problems := Check(app, modules...)
+	if len(problems) == 0 {
+		log.Printf("[SCHEMA] Schema matches the requirements of %d modules", len(modules))
+		return problems
+	}
+
+	log.Printf("[SCHEMA] WARNING: Found %d schema drift problems - writes to these fields may fail silently", len(problems))
+	for _, p := range problems {
+		log.Printf("[SCHEMA] [%s] %s: %s", p.Module, p.Collection, p.Detail)
+		log.Printf("[SCHEMA]     migration: %s", p.Suggestion)
+	}
+
+	return problems
+}
+
+// fieldConstructor renders the Go migration expression that creates a field
+func fieldConstructor(field Field) string {
+	switch field.Type {
+	case "text":
+		return fmt.Sprintf("&core.TextField{Name: %q}", field.Name)
+	case "number":
+		return fmt.Sprintf("&core.NumberField{Name: %q}", field.Name)
+	case "bool":
+		return fmt.Sprintf("&core.BoolField{Name: %q}", field.Name)
+	case "date":
+		return fmt.Sprintf("&core.DateField{Name: %q}", field.Name)
+	case "json":
+		return fmt.Sprintf("&core.JSONField{Name: %q}", field.Name)
+	case "select":
+		return fmt.Sprintf("&core.SelectField{Name: %q, MaxSelect: 1, Values: []string{%s}}", field.Name, quoteAll(field.Values))
+	case "relation":
+		return fmt.Sprintf("&core.RelationField{Name: %q, CollectionId: <target collection id>, MaxSelect: 1}", field.Name)
+	case "file":
+		return fmt.Sprintf("&core.FileField{Name: %q, MaxSelect: 1}", field.Name)
+	default:
+		return fmt.Sprintf("<%s field %q>", field.Type, field.Name)
+	}
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
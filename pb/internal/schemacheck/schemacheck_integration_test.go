@@ -0,0 +1,62 @@
+//go:build integration
+
+package schemacheck_test
+
+import (
+	"testing"
+
+	"pocketbase/internal/schemacheck"
+	"pocketbase/internal/testharness"
+)
+
+func TestCheck(t *testing.T) {
+	app := testharness.New(t)
+
+	tests := []struct {
+		name        string
+		requirement schemacheck.Requirement
+		wantKinds   []string
+	}{
+		{"matching schema", schemacheck.Requirement{
+			Collection: "processed_files",
+			Fields:     []schemacheck.Field{{Name: "status", Type: "select", Values: []string{"processing", "completed", "failed"}}},
+		}, nil},
+		{"missing collection", schemacheck.Requirement{
+			Collection: "does_not_exist",
+			Fields:     []schemacheck.Field{{Name: "name", Type: "text"}},
+		}, []string{schemacheck.MissingCollection}},
+		{"missing field", schemacheck.Requirement{
+			Collection: "processed_files",
+			Fields:     []schemacheck.Field{{Name: "not_a_field", Type: "text"}},
+		}, []string{schemacheck.MissingField}},
+		{"wrong type", schemacheck.Requirement{
+			Collection: "processed_files",
+			Fields:     []schemacheck.Field{{Name: "status", Type: "text"}},
+		}, []string{schemacheck.WrongFieldType}},
+		{"missing select value", schemacheck.Requirement{
+			Collection: "processed_files",
+			Fields:     []schemacheck.Field{{Name: "status", Type: "select", Values: []string{"completed", "archived"}}},
+		}, []string{schemacheck.MissingSelectValue}},
+		{"missing index", schemacheck.Requirement{
+			Collection: "processed_files",
+			Indexes:    []schemacheck.Index{{Name: "idx_not_there", Columns: "user_id"}},
+		}, []string{schemacheck.MissingIndex}},
+		{"several problems", schemacheck.Requirement{
+			Collection: "processed_files",
+			Fields:     []schemacheck.Field{{Name: "not_a_field", Type: "text"}, {Name: "status", Type: "number"}},
+		}, []string{schemacheck.MissingField, schemacheck.WrongFieldType}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := schemacheck.Check(app, schemacheck.Module{Name: "test", Requirements: []schemacheck.Requirement{tt.requirement}})
+			if len(problems) != len(tt.wantKinds) {
+				t.Fatalf("problems = %+v, want kinds %v", problems, tt.wantKinds)
+			}
+			for i, p := range problems {
+				if p.Kind != tt.wantKinds[i] || p.Module != "test" || p.Collection != tt.requirement.Collection {
+					t.Errorf("problem %d = %+v, want kind %s", i, p, tt.wantKinds[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package schemacheck
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCheckField(t *testing.T) {
+	collection := core.NewBaseCollection("jobs")
+	collection.Fields.Add(
+		&core.TextField{Name: "name"},
+		&core.NumberField{Name: "attempts"},
+		&core.SelectField{Name: "status", MaxSelect: 1, Values: []string{"queued", "done"}},
+	)
+
+	tests := []struct {
+		name       string
+		field      Field
+		wantKind   string
+		suggestion string
+	}{
+		{"matching field", Field{Name: "name", Type: "text"}, "", ""},
+		{"matching select", Field{Name: "status", Type: "select", Values: []string{"done"}}, "", ""},
+		{"select without required values", Field{Name: "status", Type: "select"}, "", ""},
+		{"missing field", Field{Name: "error", Type: "text"}, MissingField, `&core.TextField{Name: "error"}`},
+		{"missing select field", Field{Name: "kind", Type: "select", Values: []string{"a", "b"}}, MissingField, `Values: []string{"a", "b"}`},
+		{"wrong type", Field{Name: "attempts", Type: "text"}, WrongFieldType, "rename the existing field"},
+		{"missing select value", Field{Name: "status", Type: "select", Values: []string{"queued", "failed"}}, MissingSelectValue, `"failed"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := checkField("jobs", collection, tt.field)
+			if tt.wantKind == "" {
+				if len(problems) != 0 {
+					t.Fatalf("problems = %+v, want none", problems)
+				}
+				return
+			}
+			if len(problems) != 1 {
+				t.Fatalf("problems = %+v, want one %s", problems, tt.wantKind)
+			}
+			p := problems[0]
+			if p.Kind != tt.wantKind || p.Module != "jobs" || p.Collection != "jobs" {
+				t.Errorf("problem = %+v, want kind %s", p, tt.wantKind)
+			}
+			if !strings.Contains(p.Suggestion, tt.suggestion) {
+				t.Errorf("suggestion = %q, want it to contain %q", p.Suggestion, tt.suggestion)
+			}
+		})
+	}
+}
+
+func TestMissingSelectValuesListed(t *testing.T) {
+	collection := core.NewBaseCollection("jobs")
+	collection.Fields.Add(&core.SelectField{Name: "status", MaxSelect: 1, Values: []string{"queued"}})
+
+	problems := checkField("jobs", collection, Field{Name: "status", Type: "select", Values: []string{"queued", "done", "failed"}})
+	if len(problems) != 1 || !strings.Contains(problems[0].Detail, "[done failed]") {
+		t.Errorf("problems = %+v, want done and failed listed as missing", problems)
+	}
+}
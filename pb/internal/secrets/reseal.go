@@ -0,0 +1,83 @@
+package secrets
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Field is a collection field whose values are stored sealed
+type Field struct {
+	Collection string
+	Name       string
+}
+
+// ResealReport summarizes a reseal pass
+type ResealReport struct {
+	PrimaryKeyID string `json:"primary_key_id"`
+	Checked      int    `json:"checked"`
+	Encrypted    int    `json:"encrypted"` // plaintext values that are now sealed
+	Rotated      int    `json:"rotated"`   // sealed with an older key, now sealed with the primary
+	Failed       int    `json:"failed"`
+}
+
+// Reseal encrypts plaintext values and moves values sealed with older keys onto the primary key
+// Once it reports no failures, old keys can be dropped from SECRETS_ENCRYPTION_KEYS
+func Reseal(app core.App, fields []Field) (*ResealReport, error) {
+	ring, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	report := &ResealReport{PrimaryKeyID: ring.PrimaryKeyID()}
+
+	for _, field := range fields {
+		records, err := app.FindRecordsByFilter(field.Collection, field.Name+" != ''", "", 0, 0)
+		if err != nil {
+			log.Printf("[SECRETS] Failed to load %s.%s: %v", field.Collection, field.Name, err)
+			continue
+		}
+
+		for _, record := range records {
+			report.Checked++
+			value := record.GetString(field.Name)
+			if !ring.NeedsReseal(value) {
+				continue
+			}
+
+			plaintext, err := ring.Open(value)
+			if err == nil {
+				var sealed string
+				if sealed, err = ring.Seal(plaintext); err == nil {
+					record.Set(field.Name, sealed)
+					err = app.Save(record)
+				}
+			}
+			if err != nil {
+				report.Failed++
+				log.Printf("[SECRETS] Failed to reseal %s.%s on %s: %v", field.Collection, field.Name, record.Id, err)
+				continue
+			}
+
+			if IsSealed(value) {
+				report.Rotated++
+			} else {
+				report.Encrypted++
+			}
+		}
+	}
+
+	log.Printf("[SECRETS] Reseal with key %s: checked=%d encrypted=%d rotated=%d failed=%d",
+		report.PrimaryKeyID, report.Checked, report.Encrypted, report.Rotated, report.Failed)
+	return report, nil
+}
+
+// ResealHandler runs a reseal pass after a new primary key is deployed (superusers only)
+// POST /api/admin/secrets/reseal
+func ResealHandler(e *core.RequestEvent, app core.App, fields []Field) error {
+	report, err := Reseal(app, fields)
+	if err != nil {
+		return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+	}
+	return e.JSON(http.StatusOK, report)
+}
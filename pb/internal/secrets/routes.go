@@ -0,0 +1,20 @@
+package secrets
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the reseal route, used after prepending a new key to SECRETS_ENCRYPTION_KEYS (superusers only)
+func RegisterRoutes(r *routes.Registry, fields []Field) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/secrets/reseal", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ResealHandler(e, app, fields)
+		}},
+	)
+}
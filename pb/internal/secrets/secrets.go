@@ -0,0 +1,161 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// sealedPrefix marks values encrypted by this package: "enc:<key id>:<base64 nonce+ciphertext>"
+const sealedPrefix = "enc:"
+
+// ErrNotConfigured is returned when a secret has to be sealed or opened but no keys are set
+var ErrNotConfigured = errors.New("secret encryption keys are not configured (SECRETS_ENCRYPTION_KEYS)")
+
+// Keyring holds the encryption keys. New values are sealed with the primary key; older keys
+// are kept only to open values that haven't been resealed yet
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring reads a comma-separated list of "<id>:<base64 32-byte key>" entries, primary first
+// e.g. "k2:3q2+7w...,k1:AAEC..." - rotating means prepending a new key and resealing
+func ParseKeyring(spec string) (*Keyring, error) {
+	ring := &Keyring{keys: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || strings.Contains(id, " ") {
+			return nil, fmt.Errorf("invalid key entry %q: expected <id>:<base64 key>", entry)
+		}
+		if _, exists := ring.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, base64 encoded", id)
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = aead
+		if ring.primary == "" {
+			ring.primary = id
+		}
+	}
+	if ring.primary == "" {
+		return nil, ErrNotConfigured
+	}
+	return ring, nil
+}
+
+// PrimaryKeyID is the id of the key new values are sealed with
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primary
+}
+
+// Seal encrypts a value with the primary key
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	aead := k.keys[k.primary]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// The key id is authenticated too, so a value can't be relabelled onto another key
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.primary))
+	return sealedPrefix + k.primary + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a sealed value. Values stored before encryption was introduced are returned
+// unchanged so they keep working until resealed
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	id, encoded, _ := strings.Cut(strings.TrimPrefix(value, sealedPrefix), ":")
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("value was sealed with unknown key %q", id)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("malformed sealed value")
+	}
+	plaintext, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value sealed with key %q", id)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReseal reports whether a stored value is plaintext or sealed with a non-primary key
+func (k *Keyring) NeedsReseal(value string) bool {
+	if value == "" {
+		return false
+	}
+	return !IsSealed(value) || !strings.HasPrefix(value, sealedPrefix+k.primary+":")
+}
+
+// IsSealed reports whether a stored value was encrypted by this package
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// Mask returns a preview safe to show in the UI, e.g. "rs-a…9xQ2"
+func Mask(plaintext string) string {
+	if len(plaintext) < 12 {
+		return "…"
+	}
+	return plaintext[:4] + "…" + plaintext[len(plaintext)-4:]
+}
+
+var (
+	defaultOnce sync.Once
+	defaultRing *Keyring
+	defaultErr  error
+)
+
+// Default returns the keyring configured by SECRETS_ENCRYPTION_KEYS, parsed once
+// The variable is normally injected at deploy time from the hosting provider's secret store or KMS
+func Default() (*Keyring, error) {
+	defaultOnce.Do(func() {
+		defaultRing, defaultErr = ParseKeyring(os.Getenv("SECRETS_ENCRYPTION_KEYS"))
+	})
+	return defaultRing, defaultErr
+}
+
+// Seal encrypts a value with the default keyring
+func Seal(plaintext string) (string, error) {
+	ring, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return ring.Seal(plaintext)
+}
+
+// Open decrypts a stored value with the default keyring. Plaintext values open without keys
+func Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	ring, err := Default()
+	if err != nil {
+		return "", err
+	}
+	return ring.Open(value)
+}
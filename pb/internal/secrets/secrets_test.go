@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	keyOne = "k1:AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	keyTwo = "k2:ICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8="
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	ring, err := ParseKeyring(keyOne)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := ring.Seal("rs-supersecret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "enc:k1:") || strings.Contains(sealed, "supersecret") {
+		t.Fatalf("unexpected sealed value %q", sealed)
+	}
+
+	plaintext, err := ring.Open(sealed)
+	if err != nil || plaintext != "rs-supersecret" {
+		t.Fatalf("Open = %q, %v", plaintext, err)
+	}
+}
+
+func TestOpenPassesPlaintextThrough(t *testing.T) {
+	ring, _ := ParseKeyring(keyOne)
+	if got, err := ring.Open("rs-legacy"); err != nil || got != "rs-legacy" {
+		t.Fatalf("Open(plaintext) = %q, %v", got, err)
+	}
+	if !ring.NeedsReseal("rs-legacy") {
+		t.Error("plaintext values should need resealing")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old, _ := ParseKeyring(keyOne)
+	sealed, _ := old.Seal("rs-rotate-me")
+
+	rotated, err := ParseKeyring(keyTwo + "," + keyOne)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.PrimaryKeyID() != "k2" {
+		t.Fatalf("primary = %s, want k2", rotated.PrimaryKeyID())
+	}
+	if !rotated.NeedsReseal(sealed) {
+		t.Error("value sealed with the old key should need resealing")
+	}
+	if got, err := rotated.Open(sealed); err != nil || got != "rs-rotate-me" {
+		t.Fatalf("old key should still open its values: %q, %v", got, err)
+	}
+
+	resealed, _ := rotated.Seal("rs-rotate-me")
+	if rotated.NeedsReseal(resealed) {
+		t.Error("value sealed with the primary key shouldn't need resealing")
+	}
+
+	withoutOld, _ := ParseKeyring(keyTwo)
+	if _, err := withoutOld.Open(sealed); err == nil {
+		t.Error("expected an error opening a value whose key was dropped")
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	one, _ := ParseKeyring(keyOne)
+	sealed, _ := one.Seal("rs-value")
+
+	// Relabelling the value onto another key must fail authentication
+	both, _ := ParseKeyring(keyOne + "," + strings.Replace(keyTwo, "k2", "k3", 1))
+	relabelled := strings.Replace(sealed, "enc:k1:", "enc:k3:", 1)
+	if _, err := both.Open(relabelled); err == nil {
+		t.Error("expected relabelled value to fail")
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	for _, spec := range []string{"", "k1", "k1:notbase64!", "k1:AAEC", keyOne + "," + keyOne} {
+		if _, err := ParseKeyring(spec); err == nil {
+			t.Errorf("ParseKeyring(%q) should fail", spec)
+		}
+	}
+}
+
+func TestMask(t *testing.T) {
+	if got := Mask("rs-abcdefghijklmnop"); got != "rs-a…mnop" {
+		t.Errorf("Mask = %q", got)
+	}
+	if got := Mask("short"); got != "…" {
+		t.Errorf("short values shouldn't leak characters, got %q", got)
+	}
+}
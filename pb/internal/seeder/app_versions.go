@@ -3,7 +3,6 @@ package seeder
 import (
 	"crypto/sha256"
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
@@ -33,18 +32,8 @@ func generateChecksum(platform, version, arch string) string {
 	return fmt.Sprintf("%x", hash)
 }
 
-// SeedAppVersions creates sample app versions for development testing
-// This function should ONLY be called in development environments
-func SeedAppVersions(app core.App) error {
-	log.Println("🌱 Seeding app versions...")
-
-	// Check if versions already exist
-	existingVersions, err := app.FindRecordsByFilter("app_versions", "", "", 1, 0)
-	if err == nil && len(existingVersions) > 0 {
-		log.Println("App versions already exist, skipping seeding")
-		return nil
-	}
-
+// seedAppVersions creates the sample app versions missing by version, platform and architecture
+func seedAppVersions(app core.App, opts Options, rec recorder) error {
 	// Define version history with multiple versions per platform
 	versions := []AppVersionConfig{
 		// Windows versions
@@ -228,6 +217,13 @@ func SeedAppVersions(app core.App) error {
 	// Create each version record
 	now := time.Now()
 	for _, version := range versions {
+		name := fmt.Sprintf("%s %s (%s)", version.Version, version.Platform, version.Architecture)
+		if _, err := app.FindFirstRecordByFilter(collection, "version = {:version} && platform = {:platform} && architecture = {:arch}",
+			map[string]any{"version": version.Version, "platform": version.Platform, "arch": version.Architecture}); err == nil {
+			rec.record(name, OutcomeExists, "")
+			continue
+		}
+
 		record := core.NewRecord(collection)
 		
 		// Set the data
@@ -251,21 +247,7 @@ func SeedAppVersions(app core.App) error {
 		record.Set("created", createdTime)
 		record.Set("updated", createdTime)
 
-		// Save the record
-		if err := app.Save(record); err != nil {
-			log.Printf("Failed to create version %s for %s: %v", version.Version, version.Platform, err)
-			continue
-		}
-
-		log.Printf("✓ Created version %s for %s (%s) - Latest: %v", 
-			version.Version, version.Platform, version.Architecture, version.IsLatest)
+		rec.create(app, opts, name, record)
 	}
-
-	log.Printf("🎉 Successfully seeded %d app versions", len(versions))
-	log.Println("📱 Latest versions that should appear in UI:")
-	log.Println("   - Windows 1.2.0 (amd64)")
-	log.Println("   - macOS 1.2.0 (universal)")
-	log.Println("   - Linux 1.2.0 (amd64, arm64)")
-	
 	return nil
-}
\ No newline at end of file
+}
@@ -2,7 +2,6 @@ package seeder
 
 import (
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
@@ -21,18 +20,8 @@ type BannerConfig struct {
 	CreatedOffset time.Duration // Offset from now for created timestamp
 }
 
-// SeedBanners creates sample banners for development testing
-// This function should ONLY be called in development environments
-func SeedBanners(app core.App) error {
-	log.Println("🌱 Seeding banners...")
-
-	// Check if banners already exist
-	existingBanners, err := app.FindRecordsByFilter("banners", "", "", 1, 0)
-	if err == nil && len(existingBanners) > 0 {
-		log.Println("Banners already exist, skipping seeding")
-		return nil
-	}
-
+// seedBanners creates the sample banners missing by title
+func seedBanners(app core.App, opts Options, rec recorder) error {
 	// Get future and past times for testing
 	now := time.Now()
 	futureExpiry := now.Add(30 * 24 * time.Hour) // 30 days from now
@@ -165,8 +154,12 @@ func SeedBanners(app core.App) error {
 	}
 
 	// Create each banner record
-	successCount := 0
 	for _, banner := range banners {
+		if _, err := app.FindFirstRecordByData(collection, "title", banner.Title); err == nil {
+			rec.record(banner.Title, OutcomeExists, "")
+			continue
+		}
+
 		record := core.NewRecord(collection)
 		
 		// Set the data
@@ -188,48 +181,7 @@ func SeedBanners(app core.App) error {
 		record.Set("created", createdTime)
 		record.Set("updated", createdTime)
 
-		// Save the record
-		if err := app.Save(record); err != nil {
-			log.Printf("Failed to create banner '%s': %v", banner.Title, err)
-			continue
-		}
-
-		// Log creation with key details
-		authStatus := "public"
-		if banner.RequiresAuth {
-			authStatus = "auth-required"
-		}
-		
-		expiryStatus := "no expiry"
-		if banner.ExpiresAt != nil {
-			if banner.ExpiresAt.Before(now) {
-				expiryStatus = "expired"
-			} else {
-				expiryStatus = "expires " + banner.ExpiresAt.Format("Jan 2")
-			}
-		}
-
-		log.Printf("✓ Created banner: %s [%s] (%s, %s)", 
-			banner.Title, banner.Type, authStatus, expiryStatus)
-		successCount++
+		rec.create(app, opts, banner.Title, record)
 	}
-
-	log.Printf("🎉 Successfully seeded %d/%d banners", successCount, len(banners))
-	log.Println("📢 Banner types created:")
-	log.Println("   - App update banners (download links)")
-	log.Println("   - Info & tip banners")
-	log.Println("   - Maintenance warnings")
-	log.Println("   - Feature announcements")
-	log.Println("   - Security alerts")
-	log.Println("   - Premium user content (auth required)")
-	log.Println("   - Expired banners (for testing)")
-	log.Println("")
-	log.Println("🔍 Test scenarios available:")
-	log.Println("   - Public banners (visible to all)")
-	log.Println("   - Auth-required banners (API key needed)")
-	log.Println("   - Action buttons with external links")
-	log.Println("   - Expiration handling (some expired)")
-	log.Println("   - Different priority levels (by creation time)")
-	
 	return nil
-}
\ No newline at end of file
+}
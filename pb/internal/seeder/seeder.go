@@ -1,37 +1,258 @@
+// Package seeder fills the database with seed data, grouped in profiles: base (the
+// subscription plans every environment needs), dev (local users, the development API key and
+// sample app versions and banners) and loadtest (synthetic users with API keys). Profiles run
+// with `pocketbase seed` or at startup through SEED_PROFILES. Every step only creates what's
+// missing, so a profile can run any number of times, and a dry run reports what it would create
 package seeder
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/pocketbase/pocketbase/core"
 )
 
-// SeedAll runs all seeding functions in development mode
-func SeedAll(app core.App) error {
-	// Safety check - only run in development
-	if os.Getenv("DEVELOPMENT") != "true" {
-		log.Println("Skipping seeding - not in development mode")
-		return nil
+// Seed profiles
+const (
+	ProfileBase     = "base"
+	ProfileDev      = "dev"
+	ProfileLoadTest = "loadtest"
+)
+
+// profileOrder is the order profiles run in, whatever order they were asked for in
+var profileOrder = []string{ProfileBase, ProfileDev, ProfileLoadTest}
+
+// Outcomes of a seeded item
+const (
+	OutcomeCreated     = "created"
+	OutcomeUpdated     = "updated"
+	OutcomeExists      = "exists"
+	OutcomeWouldCreate = "would_create"
+	OutcomeWouldUpdate = "would_update"
+	OutcomeFailed      = "failed"
+)
+
+// defaultLoadTestUsers is how many users the loadtest profile creates unless
+// SEED_LOADTEST_USERS says otherwise
+const defaultLoadTestUsers = 50
+
+// Options controls a seeding run
+type Options struct {
+	DryRun        bool // report what would be created without writing anything
+	LoadTestUsers int  // users created by the loadtest profile
+}
+
+// OptionsFromEnv reads SEED_LOADTEST_USERS
+func OptionsFromEnv() Options {
+	opts := Options{LoadTestUsers: defaultLoadTestUsers}
+	if v, err := strconv.Atoi(os.Getenv("SEED_LOADTEST_USERS")); err == nil && v > 0 {
+		opts.LoadTestUsers = v
+	}
+	return opts
+}
+
+// step seeds one kind of record. It records an item per record it looks at and returns an
+// error only when it can't continue at all
+type step struct {
+	name string
+	run  func(app core.App, opts Options, rec recorder) error
+}
+
+// profiles lists the steps of each profile, in order
+var profiles = map[string][]step{
+	ProfileBase: {
+		{name: "subscription_plans", run: seedSubscriptionPlans},
+	},
+	ProfileDev: {
+		{name: "dev_user", run: seedDevUser},
+		{name: "admin_user", run: seedAdminUser},
+		{name: "app_versions", run: seedAppVersions},
+		{name: "banners", run: seedBanners},
+	},
+	ProfileLoadTest: {
+		{name: "loadtest_users", run: seedLoadTestUsers},
+	},
+}
+
+// ParseProfiles parses a comma separated list of profiles ("all" selects every one) and
+// returns them in the order they run
+func ParseProfiles(value string) ([]string, error) {
+	selected := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "":
+			continue
+		case name == "all":
+			for _, profile := range profileOrder {
+				selected[profile] = true
+			}
+		case profiles[name] != nil:
+			selected[name] = true
+		default:
+			return nil, fmt.Errorf("unknown seed profile %q (use %s or all)", name, strings.Join(profileOrder, ", "))
+		}
+	}
+
+	var ordered []string
+	for _, profile := range profileOrder {
+		if selected[profile] {
+			ordered = append(ordered, profile)
+		}
+	}
+	return ordered, nil
+}
+
+// ProfilesFromEnv returns the profiles to seed at startup: SEED_PROFILES when set (e.g. "base"
+// or "none"), otherwise base and dev with DEVELOPMENT=true and nothing in production
+func ProfilesFromEnv() ([]string, error) {
+	value, ok := os.LookupEnv("SEED_PROFILES")
+	if !ok {
+		if os.Getenv("DEVELOPMENT") != "true" {
+			return nil, nil
+		}
+		value = ProfileBase + "," + ProfileDev
+	}
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return nil, nil
 	}
+	return ParseProfiles(value)
+}
 
-	log.Println("🌱 Starting development seeding...")
+// Item is one record a step looked at
+type Item struct {
+	Profile string `json:"profile"`
+	Step    string `json:"step"`
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Detail  string `json:"detail,omitempty"`
+}
 
-	// Seed subscription plans
-	if err := SeedSubscriptionPlans(app); err != nil {
-		log.Printf("Warning: Failed to seed subscription plans: %v", err)
+// Report is the outcome of a seeding run
+type Report struct {
+	DryRun bool   `json:"dry_run"`
+	Items  []Item `json:"items"`
+}
+
+// Count returns the number of items with an outcome
+func (r Report) Count(outcome string) int {
+	n := 0
+	for _, item := range r.Items {
+		if item.Outcome == outcome {
+			n++
+		}
 	}
+	return n
+}
 
-	// Seed app versions
-	if err := SeedAppVersions(app); err != nil {
-		log.Printf("Warning: Failed to seed app versions: %v", err)
+// Print writes one line per item followed by a summary
+func (r Report) Print(w io.Writer) {
+	for _, item := range r.Items {
+		fmt.Fprintf(w, "[%-12s] %-8s %-18s %s", item.Outcome, item.Profile, item.Step, item.Name)
+		if item.Detail != "" {
+			fmt.Fprintf(w, " - %s", item.Detail)
+		}
+		fmt.Fprintln(w)
+	}
+	if r.DryRun {
+		fmt.Fprintf(w, "\nDry run: %d to create, %d to update, %d already present, %d failed\n",
+			r.Count(OutcomeWouldCreate), r.Count(OutcomeWouldUpdate), r.Count(OutcomeExists), r.Count(OutcomeFailed))
+		return
 	}
+	fmt.Fprintf(w, "\nSeeded: %d created, %d updated, %d already present, %d failed\n",
+		r.Count(OutcomeCreated), r.Count(OutcomeUpdated), r.Count(OutcomeExists), r.Count(OutcomeFailed))
+}
+
+// PrintJSON writes the report as JSON
+func (r Report) PrintJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// recorder adds a step's items to the report
+type recorder struct {
+	report  *Report
+	profile string
+	step    string
+}
+
+func (rec recorder) record(name, outcome, detail string) {
+	rec.report.Items = append(rec.report.Items, Item{Profile: rec.profile, Step: rec.step, Name: name, Outcome: outcome, Detail: detail})
+}
 
-	// Seed banners
-	if err := SeedBanners(app); err != nil {
-		log.Printf("Warning: Failed to seed banners: %v", err)
+// create saves a new record, or only reports it on a dry run
+func (rec recorder) create(app core.App, opts Options, name string, record *core.Record) bool {
+	if opts.DryRun {
+		rec.record(name, OutcomeWouldCreate, "")
+		return false
+	}
+	if err := app.Save(record); err != nil {
+		rec.record(name, OutcomeFailed, err.Error())
+		return false
+	}
+	rec.record(name, OutcomeCreated, "")
+	return true
+}
+
+// update saves changes to an existing record, or only reports them on a dry run
+func (rec recorder) update(app core.App, opts Options, name string, record *core.Record, detail string) {
+	if opts.DryRun {
+		rec.record(name, OutcomeWouldUpdate, detail)
+		return
+	}
+	if err := app.Save(record); err != nil {
+		rec.record(name, OutcomeFailed, err.Error())
+		return
 	}
+	rec.record(name, OutcomeUpdated, detail)
+}
 
-	log.Println("🎉 Seeding completed")
+// Run seeds the given profiles. A failing step is recorded in the report and the run
+// continues with the next one
+func Run(app core.App, names []string, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun}
+	for _, profile := range names {
+		steps, ok := profiles[profile]
+		if !ok {
+			return report, fmt.Errorf("unknown seed profile %q", profile)
+		}
+		for _, s := range steps {
+			rec := recorder{report: report, profile: profile, step: s.name}
+			if err := s.run(app, opts, rec); err != nil {
+				rec.record(s.name, OutcomeFailed, err.Error())
+			}
+		}
+	}
+	return report, nil
+}
+
+// RunAtStartup seeds the profiles from SEED_PROFILES when the server starts
+func RunAtStartup(app core.App) error {
+	names, err := ProfilesFromEnv()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	log.Printf("🌱 Seeding profiles: %s", strings.Join(names, ", "))
+	report, err := Run(app, names, OptionsFromEnv())
+	if err != nil {
+		return err
+	}
+	for _, item := range report.Items {
+		if item.Outcome == OutcomeFailed {
+			log.Printf("Warning: Failed to seed %s %s: %s", item.Step, item.Name, item.Detail)
+		}
+	}
+	log.Printf("🎉 Seeding completed: %d created, %d updated, %d already present, %d failed",
+		report.Count(OutcomeCreated), report.Count(OutcomeUpdated), report.Count(OutcomeExists), report.Count(OutcomeFailed))
 	return nil
-}
\ No newline at end of file
+}
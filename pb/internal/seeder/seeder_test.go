@@ -0,0 +1,94 @@
+package seeder
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseProfiles(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   bool
+	}{
+		{"base", []string{ProfileBase}, false},
+		{"loadtest, dev ,base", []string{ProfileBase, ProfileDev, ProfileLoadTest}, false},
+		{"DEV,dev", []string{ProfileDev}, false},
+		{"all", []string{ProfileBase, ProfileDev, ProfileLoadTest}, false},
+		{"", nil, false},
+		{"base,prod", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseProfiles(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseProfiles(%q) error = %v, want error %v", tt.value, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseProfiles(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestProfilesFromEnv(t *testing.T) {
+	tests := []struct {
+		development string
+		profiles    *string
+		want        []string
+	}{
+		{"true", nil, []string{ProfileBase, ProfileDev}},
+		{"false", nil, nil},
+		{"false", ptr("base"), []string{ProfileBase}},
+		{"true", ptr("none"), nil},
+		{"true", ptr(""), nil},
+	}
+	for _, tt := range tests {
+		t.Setenv("DEVELOPMENT", tt.development)
+		if tt.profiles != nil {
+			t.Setenv("SEED_PROFILES", *tt.profiles)
+		}
+		got, err := ProfilesFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("DEVELOPMENT=%s SEED_PROFILES=%v: got %v, want %v", tt.development, tt.profiles, got, tt.want)
+		}
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestLoadTestAPIKey(t *testing.T) {
+	key := LoadTestAPIKey(7)
+	if !strings.HasPrefix(key, "ra-") || !strings.HasSuffix(key, "07") || len(key) != len("ra-load-")+32 {
+		t.Errorf("LoadTestAPIKey(7) = %q", key)
+	}
+	if LoadTestAPIKey(1) == LoadTestAPIKey(2) {
+		t.Error("load test keys must differ per user")
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	report := Report{DryRun: true}
+	rec := recorder{report: &report, profile: ProfileBase, step: "subscription_plans"}
+	rec.record("Free", OutcomeExists, "")
+	rec.record("Pro", OutcomeWouldCreate, "")
+	rec.record("Pro Yearly", OutcomeWouldUpdate, "paired with Pro")
+
+	if got := report.Count(OutcomeWouldCreate); got != 1 {
+		t.Errorf("Count(would_create) = %d, want 1", got)
+	}
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.Contains(out.String(), "Dry run: 1 to create, 1 to update, 1 already present, 0 failed") {
+		t.Errorf("unexpected summary:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "paired with Pro") {
+		t.Errorf("item detail missing:\n%s", out.String())
+	}
+}
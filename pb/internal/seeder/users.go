@@ -0,0 +1,142 @@
+package seeder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Development seed constants
+const (
+	// Development API key - only seeded by the dev profile
+	DEV_API_KEY    = "ra-dev-12345678901234567890123456789012"
+	DEV_USER_EMAIL = "bob@test.com"
+	DEV_USER_NAME  = "Bob"
+
+	// Admin user for testing
+	ADMIN_USER_EMAIL = "alice@test.com"
+	ADMIN_USER_NAME  = "Alice Admin"
+	ADMIN_PASSWORD   = "password"
+
+	// Load test users are loadtest-001@test.com and up, with API keys ra-load-<32 digit index>
+	loadTestEmailFormat  = "loadtest-%03d@test.com"
+	loadTestAPIKeyFormat = "ra-load-%032d"
+	loadTestPassword     = "password"
+)
+
+func hashAPIKey(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// LoadTestAPIKey returns the API key of the nth load test user (from 1), for load test scripts
+func LoadTestAPIKey(n int) string {
+	return fmt.Sprintf(loadTestAPIKeyFormat, n)
+}
+
+// ensureUser finds a user by email or creates a verified one, returning nil on a dry run
+// when the user doesn't exist yet
+func ensureUser(app core.App, opts Options, rec recorder, email, name, password string) (*core.Record, error) {
+	if user, err := app.FindAuthRecordByEmail("users", email); err == nil {
+		rec.record(email, OutcomeExists, "")
+		return user, nil
+	}
+
+	usersCollection, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find users collection: %w", err)
+	}
+
+	user := core.NewRecord(usersCollection)
+	user.Set("email", email)
+	user.Set("name", name)
+	user.SetPassword(password)
+	user.Set("verified", true)
+	if !rec.create(app, opts, email, user) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// ensureAPIKey finds an API key by its hash or creates it for the user, and makes sure it's
+// active and owned by the user
+func ensureAPIKey(app core.App, opts Options, rec recorder, user *core.Record, apiKey, name string) error {
+	keyHash := hashAPIKey(apiKey)
+	label := name + " (" + keyHash[:16] + "...)"
+
+	existing, err := app.FindFirstRecordByData("api_keys", "key_hash", keyHash)
+	if err == nil {
+		if user != nil && (existing.GetString("user_id") != user.Id || !existing.GetBool("active")) {
+			existing.Set("user_id", user.Id)
+			existing.Set("active", true)
+			rec.update(app, opts, label, existing, "reassigned to "+user.Email()+" and activated")
+			return nil
+		}
+		rec.record(label, OutcomeExists, "")
+		return nil
+	}
+
+	if user == nil {
+		// Dry run for a user that doesn't exist yet
+		rec.record(label, OutcomeWouldCreate, "")
+		return nil
+	}
+
+	apiKeysCollection, err := app.FindCollectionByNameOrId("api_keys")
+	if err != nil {
+		return fmt.Errorf("failed to find api_keys collection: %w", err)
+	}
+	record := core.NewRecord(apiKeysCollection)
+	record.Set("key_hash", keyHash)
+	record.Set("user_id", user.Id)
+	record.Set("active", true)
+	record.Set("name", name)
+	rec.create(app, opts, label, record)
+	return nil
+}
+
+// seedDevUser creates the development user and its API key (DEV_API_KEY) for the desktop app
+func seedDevUser(app core.App, opts Options, rec recorder) error {
+	user, err := ensureUser(app, opts, rec, DEV_USER_EMAIL, DEV_USER_NAME, "password")
+	if err != nil {
+		return err
+	}
+	if err := ensureAPIKey(app, opts, rec, user, DEV_API_KEY, "Development API Key"); err != nil {
+		return err
+	}
+	if !opts.DryRun {
+		log.Printf("   Development API key for the desktop app: %s (user %s)", DEV_API_KEY, DEV_USER_EMAIL)
+	}
+	return nil
+}
+
+// seedAdminUser creates an admin user for testing, with the admin role when users have one
+func seedAdminUser(app core.App, opts Options, rec recorder) error {
+	admin, err := ensureUser(app, opts, rec, ADMIN_USER_EMAIL, ADMIN_USER_NAME, ADMIN_PASSWORD)
+	if err != nil || admin == nil {
+		return err
+	}
+
+	if admin.Collection().Fields.GetByName("role") != nil && admin.GetString("role") != "admin" {
+		admin.Set("role", "admin")
+		rec.update(app, opts, ADMIN_USER_EMAIL, admin, "role set to admin")
+	}
+	return nil
+}
+
+// seedLoadTestUsers creates opts.LoadTestUsers users, each with an API key from LoadTestAPIKey
+func seedLoadTestUsers(app core.App, opts Options, rec recorder) error {
+	for n := 1; n <= opts.LoadTestUsers; n++ {
+		user, err := ensureUser(app, opts, rec, fmt.Sprintf(loadTestEmailFormat, n), fmt.Sprintf("Load Test %d", n), loadTestPassword)
+		if err != nil {
+			return err
+		}
+		if err := ensureAPIKey(app, opts, rec, user, LoadTestAPIKey(n), fmt.Sprintf("Load Test Key %d", n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package sla
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DailyMetrics represents one persisted rollup day for an endpoint
+type DailyMetrics struct {
+	Date               string  `json:"date"`
+	TotalRequests      int     `json:"total_requests"`
+	SuccessfulRequests int     `json:"successful_requests"`
+	SuccessRate        float64 `json:"success_rate"`
+	AvgLatencyMs       float64 `json:"avg_latency_ms"`
+	P95LatencyMs       float64 `json:"p95_latency_ms"`
+}
+
+// EndpointReport summarizes one endpoint over a month
+type EndpointReport struct {
+	Endpoint           string         `json:"endpoint"`
+	TotalRequests      int            `json:"total_requests"`
+	SuccessfulRequests int            `json:"successful_requests"`
+	SuccessRate        float64        `json:"success_rate"`
+	WorstDailyP95Ms    float64        `json:"worst_daily_p95_ms"`
+	Days               []DailyMetrics `json:"days"`
+}
+
+// SLAReportHandler returns monthly SLA metrics for enterprise reporting (superusers only)
+// GET /api/admin/sla?month=YYYY-MM - defaults to the current month
+func SLAReportHandler(e *core.RequestEvent, app core.App) error {
+	month := e.Request.URL.Query().Get("month")
+	if month == "" {
+		month = time.Now().UTC().Format("2006-01")
+	}
+	if _, err := time.Parse("2006-01", month); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "month must be in YYYY-MM format"})
+	}
+
+	// Persist in-flight counters so the current day is up to date
+	FlushRollups(app)
+
+	records, err := app.FindRecordsByFilter("sla_daily_rollups", "date ~ {:month}", "+date", 0, 0, map[string]any{
+		"month": month + "-%",
+	})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load SLA metrics"})
+	}
+
+	reports := map[string]*EndpointReport{}
+	order := []string{}
+	for _, record := range records {
+		endpoint := record.GetString("endpoint")
+		report, ok := reports[endpoint]
+		if !ok {
+			report = &EndpointReport{Endpoint: endpoint, Days: []DailyMetrics{}}
+			reports[endpoint] = report
+			order = append(order, endpoint)
+		}
+
+		day := DailyMetrics{
+			Date:               record.GetString("date"),
+			TotalRequests:      record.GetInt("total_requests"),
+			SuccessfulRequests: record.GetInt("successful_requests"),
+			SuccessRate:        record.GetFloat("success_rate"),
+			AvgLatencyMs:       record.GetFloat("avg_latency_ms"),
+			P95LatencyMs:       record.GetFloat("p95_latency_ms"),
+		}
+		report.Days = append(report.Days, day)
+		report.TotalRequests += day.TotalRequests
+		report.SuccessfulRequests += day.SuccessfulRequests
+		report.WorstDailyP95Ms = math.Max(report.WorstDailyP95Ms, day.P95LatencyMs)
+	}
+
+	endpoints := make([]*EndpointReport, 0, len(order))
+	for _, endpoint := range order {
+		report := reports[endpoint]
+		report.SuccessRate = SuccessRate(report.SuccessfulRequests, report.TotalRequests)
+		endpoints = append(endpoints, report)
+	}
+
+	return e.JSON(http.StatusOK, map[string]interface{}{
+		"month":     month,
+		"endpoints": endpoints,
+	})
+}
@@ -0,0 +1,240 @@
+package sla
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// Tracked endpoint names used as the "endpoint" column of sla_daily_rollups
+const (
+	EndpointTranscription = "transcription"
+	EndpointAIText        = "ai_text"
+)
+
+// maxSamplesPerDay bounds memory used for latency samples per endpoint per day
+const maxSamplesPerDay = 100000
+
+// dayKey identifies the stats bucket for one endpoint on one UTC day
+type dayKey struct {
+	Date     string
+	Endpoint string
+}
+
+// dayStats holds the in-memory counters for one bucket since the process started
+type dayStats struct {
+	Total      int
+	Successful int
+	LatencySum float64
+	Latencies  []float64
+
+	// Baseline from a rollup persisted by a previous process for the same day
+	BaseTotal      int
+	BaseSuccessful int
+	BaseLatencySum float64
+	BaseP95        float64
+	baseLoaded     bool
+}
+
+// Recorder collects request outcomes and latencies in memory until they are flushed
+type Recorder struct {
+	mu    sync.Mutex
+	stats map[dayKey]*dayStats
+	now   func() time.Time
+
+	// flushMu serializes flushes (the cron job and shutdown), which write without holding mu
+	flushMu sync.Mutex
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{
+		stats: make(map[dayKey]*dayStats),
+		now:   time.Now,
+	}
+}
+
+var defaultRecorder = NewRecorder()
+
+// Record adds a single request outcome for an endpoint
+func (r *Recorder) Record(endpoint string, success bool, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := dayKey{Date: r.now().UTC().Format("2006-01-02"), Endpoint: endpoint}
+	stats, ok := r.stats[key]
+	if !ok {
+		stats = &dayStats{}
+		r.stats[key] = stats
+	}
+
+	ms := float64(latency.Microseconds()) / 1000
+	stats.Total++
+	if success {
+		stats.Successful++
+	}
+	stats.LatencySum += ms
+	if len(stats.Latencies) < maxSamplesPerDay {
+		stats.Latencies = append(stats.Latencies, ms)
+	}
+}
+
+// Track returns a middleware that records the outcome and latency of every request to an endpoint
+// Only server-side failures (5xx or unhandled errors) count against the SLA - client errors do not
+func Track(endpoint string) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		start := time.Now()
+		err := e.Next()
+
+		status := e.Status()
+		success := err == nil && status < http.StatusInternalServerError
+		var apiErr *router.ApiError
+		if errors.As(err, &apiErr) && apiErr.Status < http.StatusInternalServerError {
+			success = true
+		}
+
+		defaultRecorder.Record(endpoint, success, time.Since(start))
+		return err
+	}
+}
+
+// FlushRollups persists the current daily rollups of the default recorder
+func FlushRollups(app core.App) {
+	defaultRecorder.Flush(app)
+}
+
+// Flush upserts a rollup record for every in-memory bucket and drops buckets from previous days
+// The buckets are swapped out under the lock and written without it, so requests aren't blocked
+// on the database. Today's buckets, and any that failed to save, are merged back afterwards
+func (r *Recorder) Flush(app core.App) {
+	r.flushMu.Lock()
+	defer r.flushMu.Unlock()
+
+	r.mu.Lock()
+	pending := r.stats
+	r.stats = make(map[dayKey]*dayStats)
+	today := r.now().UTC().Format("2006-01-02")
+	r.mu.Unlock()
+
+	keep := make(map[dayKey]*dayStats)
+	defer r.restore(keep)
+
+	collection, err := app.FindCollectionByNameOrId("sla_daily_rollups")
+	if err != nil {
+		log.Printf("[SLA] ERROR: Failed to find sla_daily_rollups collection: %v", err)
+		for key, stats := range pending {
+			keep[key] = stats
+		}
+		return
+	}
+
+	for key, stats := range pending {
+		if err := saveRollup(app, collection, key, stats); err != nil {
+			log.Printf("[SLA] ERROR: Failed to save rollup for %s/%s: %v", key.Date, key.Endpoint, err)
+			keep[key] = stats
+			continue
+		}
+		if key.Date >= today {
+			keep[key] = stats
+		}
+	}
+}
+
+// saveRollup upserts the rollup record of one bucket
+func saveRollup(app core.App, collection *core.Collection, key dayKey, stats *dayStats) error {
+	record, err := app.FindFirstRecordByFilter("sla_daily_rollups", "date = {:date} && endpoint = {:endpoint}", map[string]any{
+		"date":     key.Date,
+		"endpoint": key.Endpoint,
+	})
+	if err != nil {
+		record = core.NewRecord(collection)
+		record.Set("date", key.Date)
+		record.Set("endpoint", key.Endpoint)
+	} else if !stats.baseLoaded {
+		// A previous process already persisted counts for this day - add to them instead of overwriting
+		stats.BaseTotal = record.GetInt("total_requests")
+		stats.BaseSuccessful = record.GetInt("successful_requests")
+		stats.BaseLatencySum = record.GetFloat("avg_latency_ms") * float64(stats.BaseTotal)
+		stats.BaseP95 = record.GetFloat("p95_latency_ms")
+	}
+	stats.baseLoaded = true
+
+	total, successful, avg, p95 := stats.rollup()
+	record.Set("total_requests", total)
+	record.Set("successful_requests", successful)
+	record.Set("success_rate", SuccessRate(successful, total))
+	record.Set("avg_latency_ms", avg)
+	record.Set("p95_latency_ms", p95)
+	return app.Save(record)
+}
+
+// restore puts flushed buckets back, adding what was recorded while they were being written
+func (r *Recorder) restore(buckets map[dayKey]*dayStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, stats := range buckets {
+		if recorded, ok := r.stats[key]; ok {
+			stats.merge(recorded)
+		}
+		r.stats[key] = stats
+	}
+}
+
+// merge adds the requests recorded in newer to s, keeping s's baseline
+func (s *dayStats) merge(newer *dayStats) {
+	s.Total += newer.Total
+	s.Successful += newer.Successful
+	s.LatencySum += newer.LatencySum
+	if room := maxSamplesPerDay - len(s.Latencies); room > 0 {
+		s.Latencies = append(s.Latencies, newer.Latencies[:min(room, len(newer.Latencies))]...)
+	}
+}
+
+// rollup combines the persisted baseline with the in-memory samples
+// The p95 of two partial days can't be merged exactly, so the higher of the two is kept
+func (s *dayStats) rollup() (total int, successful int, avgMs float64, p95Ms float64) {
+	total = s.BaseTotal + s.Total
+	successful = s.BaseSuccessful + s.Successful
+	if total > 0 {
+		avgMs = roundMs((s.BaseLatencySum + s.LatencySum) / float64(total))
+	}
+	p95Ms = math.Max(s.BaseP95, Percentile(s.Latencies, 95))
+	return total, successful, avgMs, p95Ms
+}
+
+// Percentile returns the nearest-rank percentile of the given samples
+func Percentile(samples []float64, p float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return roundMs(sorted[rank-1])
+}
+
+// SuccessRate returns the percentage of successful requests, treating no traffic as 100%
+func SuccessRate(successful, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(successful)/float64(total)*100000) / 1000
+}
+
+func roundMs(ms float64) float64 {
+	return math.Round(ms*100) / 100
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+package sla_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/sla"
+	"pocketbase/internal/testharness"
+)
+
+func TestFlushDoesNotBlockRecording(t *testing.T) {
+	app := testharness.New(t)
+	recorder := sla.NewRecorder()
+	recorder.Record(sla.EndpointAIText, true, 100*time.Millisecond)
+
+	// A request finishing while the rollup is being saved must not wait for the flush
+	recorded := make(chan struct{})
+	app.OnRecordCreate("sla_daily_rollups").BindFunc(func(e *core.RecordEvent) error {
+		go func() {
+			recorder.Record(sla.EndpointAIText, false, 300*time.Millisecond)
+			close(recorded)
+		}()
+		select {
+		case <-recorded:
+		case <-time.After(2 * time.Second):
+			t.Error("Record blocked while the flush was writing")
+		}
+		return e.Next()
+	})
+
+	recorder.Flush(app)
+	<-recorded
+	recorder.Flush(app)
+
+	rollup, err := app.FindFirstRecordByFilter("sla_daily_rollups", "endpoint = {:endpoint}", map[string]any{"endpoint": sla.EndpointAIText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rollup.GetInt("total_requests") != 2 || rollup.GetInt("successful_requests") != 1 {
+		t.Errorf("rollup = %d/%d successful, want 1/2", rollup.GetInt("successful_requests"), rollup.GetInt("total_requests"))
+	}
+	if rollup.GetFloat("avg_latency_ms") != 200 || rollup.GetFloat("p95_latency_ms") != 300 {
+		t.Errorf("latency avg %v p95 %v, want 200 and 300", rollup.GetFloat("avg_latency_ms"), rollup.GetFloat("p95_latency_ms"))
+	}
+}
@@ -0,0 +1,86 @@
+package sla
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	samples := make([]float64, 0, 100)
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, float64(i))
+	}
+
+	if got := Percentile(samples, 95); got != 95 {
+		t.Errorf("Expected p95 of 1..100 to be 95, got %v", got)
+	}
+	if got := Percentile([]float64{42}, 95); got != 42 {
+		t.Errorf("Expected p95 of a single sample to be the sample, got %v", got)
+	}
+	if got := Percentile(nil, 95); got != 0 {
+		t.Errorf("Expected p95 of no samples to be 0, got %v", got)
+	}
+}
+
+func TestSuccessRate(t *testing.T) {
+	if got := SuccessRate(0, 0); got != 100 {
+		t.Errorf("Expected no traffic to report 100%%, got %v", got)
+	}
+	if got := SuccessRate(999, 1000); got != 99.9 {
+		t.Errorf("Expected 99.9%%, got %v", got)
+	}
+}
+
+func TestRecorderRollupMergesBaseline(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.now = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC) }
+
+	recorder.Record(EndpointAIText, true, 100*time.Millisecond)
+	recorder.Record(EndpointAIText, false, 300*time.Millisecond)
+
+	stats := recorder.stats[dayKey{Date: "2025-03-10", Endpoint: EndpointAIText}]
+	if stats == nil {
+		t.Fatal("Expected stats bucket for the recorded day")
+	}
+
+	// Simulate counts persisted by a previous process for the same day
+	stats.BaseTotal = 2
+	stats.BaseSuccessful = 2
+	stats.BaseLatencySum = 200
+	stats.BaseP95 = 500
+
+	total, successful, avg, p95 := stats.rollup()
+	if total != 4 || successful != 3 {
+		t.Errorf("Expected 3/4 successful requests, got %d/%d", successful, total)
+	}
+	if avg != 150 {
+		t.Errorf("Expected average latency 150ms, got %v", avg)
+	}
+	if p95 != 500 {
+		t.Errorf("Expected the higher baseline p95 to be kept, got %v", p95)
+	}
+}
+
+func TestRecorderRestoreAddsRequestsRecordedDuringFlush(t *testing.T) {
+	recorder := NewRecorder()
+	recorder.now = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC) }
+	key := dayKey{Date: "2025-03-10", Endpoint: EndpointAIText}
+
+	recorder.Record(EndpointAIText, true, 100*time.Millisecond)
+	flushed := recorder.stats
+	recorder.stats = make(map[dayKey]*dayStats)
+	flushed[key].BaseTotal = 5
+	flushed[key].baseLoaded = true
+
+	// Recorded while the flushed buckets were being written
+	recorder.Record(EndpointAIText, false, 300*time.Millisecond)
+	recorder.restore(flushed)
+
+	stats := recorder.stats[key]
+	if stats.Total != 2 || stats.Successful != 1 || stats.LatencySum != 400 || len(stats.Latencies) != 2 {
+		t.Errorf("Expected both requests in the restored bucket, got %+v", stats)
+	}
+	if stats.BaseTotal != 5 || !stats.baseLoaded {
+		t.Errorf("Expected the flushed bucket's baseline to be kept, got %+v", stats)
+	}
+}
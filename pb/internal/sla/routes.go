@@ -0,0 +1,20 @@
+package sla
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the SLA report (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/admin/sla", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return SLAReportHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,27 @@
+package sla
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the SLA rollups read and write
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "sla",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "sla_daily_rollups",
+				Fields: []schemacheck.Field{
+					{Name: "date", Type: "text"},
+					{Name: "endpoint", Type: "text"},
+					{Name: "total_requests", Type: "number"},
+					{Name: "successful_requests", Type: "number"},
+					{Name: "success_rate", Type: "number"},
+					{Name: "avg_latency_ms", Type: "number"},
+					{Name: "p95_latency_ms", Type: "number"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_sla_daily_rollups_date_endpoint", Unique: true, Columns: "date, endpoint"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,64 @@
+//go:build integration
+
+package subscription_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"pocketbase/internal/routes"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+func TestChangePlanAgainstRealRecords(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	user := testharness.CreateUser(t, app, "upgrader@test.com")
+	token := testharness.AuthToken(t, user)
+
+	provider := subscription.NewMockPaymentProvider()
+	service := subscription.NewServiceWithProvider(subscription.NewRepository(app), provider)
+	current, err := service.AssignPlan(user.Id, plans["basic"].Id, time.Now().AddDate(0, 1, 0), "stripe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current.Set("provider_subscription_id", "sub_integration")
+	if err := app.Save(current); err != nil {
+		t.Fatal(err)
+	}
+
+	server := testharness.Serve(t, app, func(r *routes.Registry) {
+		subscription.RegisterRoutes(r, service)
+	})
+
+	server.Do(t, http.MethodPost, "/api/payment/change-plan", "", map[string]string{"plan_id": plans["pro"].Id}).
+		Expect(t, http.StatusUnauthorized)
+	server.Do(t, http.MethodPost, "/api/payment/change-plan", token, map[string]string{"plan_id": "missing"}).
+		Expect(t, http.StatusNotFound)
+	server.Do(t, http.MethodPost, "/api/payment/change-plan", token, map[string]string{"plan_id": plans["free"].Id}).
+		Expect(t, http.StatusBadRequest)
+
+	server.Do(t, http.MethodPost, "/api/payment/change-plan", token, map[string]string{"plan_id": plans["pro"].Id}).
+		Expect(t, http.StatusOK)
+	if len(provider.UpdateCalls) != 1 || provider.UpdateCalls[0].SubID != "sub_integration" || provider.UpdateCalls[0].PriceID != "price_pro" {
+		t.Fatalf("provider calls = %+v, want sub_integration moved to price_pro", provider.UpdateCalls)
+	}
+
+	active, err := service.GetUserActiveSubscription(user.Id)
+	if err != nil || active.GetString("plan_id") != plans["pro"].Id {
+		t.Fatalf("active subscription = %v (%v), want pro", active, err)
+	}
+
+	// The basic period is in the history, superseded by pro
+	body := server.Do(t, http.MethodGet, "/api/subscription/history", token, nil).Expect(t, http.StatusOK).JSON(t)
+	entries, _ := body["history"].([]any)
+	if len(entries) != 1 {
+		t.Fatalf("history = %v, want one entry", body)
+	}
+	entry := entries[0].(map[string]any)
+	if entry["reason"] != "upgrade" || entry["plan"].(map[string]any)["id"] != plans["basic"].Id || entry["next_plan"].(map[string]any)["id"] != plans["pro"].Id {
+		t.Errorf("history entry = %v, want an upgrade from basic to pro", entry)
+	}
+}
@@ -0,0 +1,213 @@
+package subscription
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Plan change states reported to users
+const (
+	PlanChangeEffective = "effective"
+	PlanChangeScheduled = "scheduled" // accepted by the provider, takes effect at EffectiveAt
+)
+
+// PlanRef names a plan in the change log
+type PlanRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PlanChange is one entry in a user's plan change log
+type PlanChange struct {
+	ID                   string    `json:"id"`
+	Kind                 string    `json:"kind"` // the history replacement reason, e.g. "upgrade" or "subscription_cancelled"
+	Status               string    `json:"status"`
+	InitiatedBy          string    `json:"initiated_by"` // user, webhook, admin, system or unknown
+	FromPlan             *PlanRef  `json:"from_plan,omitempty"`
+	ToPlan               *PlanRef  `json:"to_plan,omitempty"`
+	ProrationAmountCents *int64    `json:"proration_amount_cents,omitempty"`
+	Currency             string    `json:"currency,omitempty"`
+	EffectiveAt          time.Time `json:"effective_at"`
+	PaymentProvider      string    `json:"payment_provider,omitempty"`
+
+	fromPlanID string
+	toPlanID   string
+	recordedAt time.Time // when an admin change was requested; zero for history entries
+}
+
+// planMigrationAudit is the part of a plan_migration audit entry the change log reads
+type planMigrationAudit struct {
+	Request struct {
+		SourcePlanID string `json:"source_plan_id"`
+		TargetPlanID string `json:"target_plan_id"`
+	} `json:"request"`
+	Items []struct {
+		UserID      string `json:"user_id"`
+		Status      string `json:"status"`
+		EffectiveAt string `json:"effective_at"`
+	} `json:"items"`
+}
+
+// PlanChangesHandler lists every plan change on the user's account, newest first
+// GET /api/subscription/changes
+func PlanChangesHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	changes, err := UserPlanChanges(app, user.Id)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load plan changes"})
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{"changes": changes})
+}
+
+// UserPlanChanges builds a user's plan change log, newest first, from subscription_history
+// plus admin plan migrations from admin_audit_logs
+func UserPlanChanges(app core.App, userID string) ([]PlanChange, error) {
+	repo := NewRepository(app)
+	history, err := repo.GetUserSubscriptionHistory(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]PlanChange, 0, len(history))
+	for _, record := range history {
+		changes = append(changes, historyPlanChange(record))
+	}
+
+	changes = mergeAdminMigrations(changes, adminMigrations(app, userID), time.Now())
+
+	plans := map[string]*core.Record{}
+	planRef := func(planID string) *PlanRef {
+		if planID == "" {
+			return nil
+		}
+		if _, ok := plans[planID]; !ok {
+			plans[planID], _ = repo.GetPlan(planID)
+		}
+		ref := &PlanRef{ID: planID}
+		if plan := plans[planID]; plan != nil {
+			ref.Name = plan.GetString("name")
+		}
+		return ref
+	}
+	for i := range changes {
+		changes[i].FromPlan = planRef(changes[i].fromPlanID)
+		changes[i].ToPlan = planRef(changes[i].toPlanID)
+		if changes[i].ProrationAmountCents != nil {
+			if plan := plans[changes[i].toPlanID]; plan != nil {
+				changes[i].Currency = plan.GetString("currency")
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// historyPlanChange turns a subscription_history record into a change log entry
+func historyPlanChange(record *core.Record) PlanChange {
+	change := PlanChange{
+		ID:              record.Id,
+		Kind:            record.GetString("replacement_reason"),
+		Status:          PlanChangeEffective,
+		InitiatedBy:     record.GetString("initiated_by"),
+		EffectiveAt:     record.GetDateTime("replaced_at").Time().UTC(),
+		PaymentProvider: record.GetString("payment_provider"),
+		fromPlanID:      record.GetString("plan_id"),
+		toPlanID:        record.GetString("new_plan_id"),
+	}
+	// Zero means no proration was recorded
+	if amount := int64(record.GetInt("proration_amount_cents")); amount != 0 {
+		change.ProrationAmountCents = &amount
+	}
+	if change.InitiatedBy == "" {
+		change.InitiatedBy = legacyInitiator(change.Kind)
+	}
+	return change
+}
+
+// legacyInitiator guesses the initiator for history written before initiators were recorded
+func legacyInitiator(reason string) string {
+	switch reason {
+	case "subscription_cancelled", "replaced_by_new_subscription":
+		return string(InitiatedByWebhook)
+	}
+	return "unknown"
+}
+
+// adminMigrations returns the user's part of non-dry-run admin plan migrations
+func adminMigrations(app core.App, userID string) []PlanChange {
+	// details is JSON text, so the user id match is narrowed down again below
+	records, err := app.FindRecordsByFilter("admin_audit_logs",
+		"action = 'plan_migration' && dry_run = false && details ~ {:user}", "-created", 100, 0,
+		map[string]any{"user": userID})
+	if err != nil {
+		slog.Warn("Failed to load plan migrations", "user_id", userID, "error", err)
+		return nil
+	}
+
+	var changes []PlanChange
+	for _, record := range records {
+		var audit planMigrationAudit
+		if err := record.UnmarshalJSONField("details", &audit); err != nil {
+			continue
+		}
+		for _, item := range audit.Items {
+			if item.UserID != userID || (item.Status != "migrated" && item.Status != "scheduled") {
+				continue
+			}
+			effectiveAt, err := time.Parse(time.RFC3339, item.EffectiveAt)
+			if err != nil {
+				effectiveAt = record.GetDateTime("created").Time()
+			}
+			changes = append(changes, PlanChange{
+				ID:          record.Id,
+				Kind:        "plan_change",
+				Status:      PlanChangeScheduled,
+				InitiatedBy: string(InitiatedByAdmin),
+				EffectiveAt: effectiveAt.UTC(),
+				fromPlanID:  audit.Request.SourcePlanID,
+				toPlanID:    audit.Request.TargetPlanID,
+				recordedAt:  record.GetDateTime("created").Time().UTC(),
+			})
+		}
+	}
+	return changes
+}
+
+// mergeAdminMigrations folds admin migrations into the history-based log. A migration that has
+// since taken effect already has a history entry - written by the provider webhook for scheduled
+// changes - so that entry is credited to the admin instead of listing the change twice.
+// Migrations still in the future are listed as scheduled
+func mergeAdminMigrations(history []PlanChange, migrations []PlanChange, now time.Time) []PlanChange {
+	changes := append([]PlanChange{}, history...)
+
+	for _, migration := range migrations {
+		matched := false
+		for i := range changes {
+			change := &changes[i]
+			if change.Status != PlanChangeEffective || change.toPlanID != migration.toPlanID ||
+				change.fromPlanID != migration.fromPlanID || change.EffectiveAt.Before(migration.recordedAt) {
+				continue
+			}
+			change.InitiatedBy = string(InitiatedByAdmin)
+			matched = true
+			break
+		}
+		if !matched && migration.EffectiveAt.After(now) {
+			changes = append(changes, migration)
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].EffectiveAt.After(changes[j].EffectiveAt)
+	})
+	return changes
+}
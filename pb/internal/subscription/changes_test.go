@@ -0,0 +1,57 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeAdminMigrations_CreditsWebhookEntryToAdmin(t *testing.T) {
+	requested := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	history := []PlanChange{
+		{ID: "h1", Status: PlanChangeEffective, InitiatedBy: "webhook", fromPlanID: "basic", toPlanID: "pro", EffectiveAt: requested.AddDate(0, 0, 14)},
+		{ID: "h0", Status: PlanChangeEffective, InitiatedBy: "user", fromPlanID: "free", toPlanID: "basic", EffectiveAt: requested.AddDate(0, -2, 0)},
+	}
+	migrations := []PlanChange{
+		{ID: "a1", Status: PlanChangeScheduled, InitiatedBy: "admin", fromPlanID: "basic", toPlanID: "pro", EffectiveAt: requested.AddDate(0, 0, 14), recordedAt: requested},
+	}
+
+	changes := mergeAdminMigrations(history, migrations, requested.AddDate(0, 1, 0))
+	if len(changes) != 2 {
+		t.Fatalf("expected the migration to merge into its history entry, got %d changes", len(changes))
+	}
+	if changes[0].ID != "h1" || changes[0].InitiatedBy != "admin" {
+		t.Errorf("expected h1 credited to admin, got %s by %s", changes[0].ID, changes[0].InitiatedBy)
+	}
+	if changes[1].InitiatedBy != "user" {
+		t.Errorf("unrelated change should keep its initiator, got %s", changes[1].InitiatedBy)
+	}
+}
+
+func TestMergeAdminMigrations_ListsFutureMigrationAsScheduled(t *testing.T) {
+	now := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	history := []PlanChange{
+		{ID: "h0", Status: PlanChangeEffective, fromPlanID: "free", toPlanID: "basic", EffectiveAt: now.AddDate(0, -1, 0)},
+	}
+	migrations := []PlanChange{
+		{ID: "a1", Status: PlanChangeScheduled, fromPlanID: "basic", toPlanID: "pro", EffectiveAt: now.AddDate(0, 0, 10), recordedAt: now.AddDate(0, 0, -1)},
+		// Took effect but its history entry is missing - nothing trustworthy to show
+		{ID: "a0", Status: PlanChangeScheduled, fromPlanID: "basic", toPlanID: "max", EffectiveAt: now.AddDate(0, 0, -2), recordedAt: now.AddDate(0, 0, -3)},
+	}
+
+	changes := mergeAdminMigrations(history, migrations, now)
+	if len(changes) != 2 {
+		t.Fatalf("expected history plus one scheduled change, got %d", len(changes))
+	}
+	if changes[0].ID != "a1" || changes[0].Status != PlanChangeScheduled {
+		t.Errorf("expected scheduled migration first, got %s (%s)", changes[0].ID, changes[0].Status)
+	}
+}
+
+func TestLegacyInitiator(t *testing.T) {
+	if got := legacyInitiator("subscription_cancelled"); got != "webhook" {
+		t.Errorf("subscription_cancelled: got %s", got)
+	}
+	if got := legacyInitiator("plan_change"); got != "unknown" {
+		t.Errorf("plan_change: got %s", got)
+	}
+}
@@ -1,6 +1,7 @@
 package subscription
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -33,6 +34,16 @@ func ChangePlanHandler(e *core.RequestEvent, app core.App, subscriptionService S
 		return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
 	}
 
+	// Custom plans are only available to the user they were built for
+	if plan.GetBool("is_custom") && plan.GetString("assigned_user_id") != userID {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Plan not found"})
+	}
+
+	// Invoice-billed custom plans are activated by an admin, not through Stripe
+	if plan.GetString("billing_method") == "invoice" {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "This plan is billed by invoice - contact support to activate it"})
+	}
+
 	// Check if this is a free plan (cancellation attempt)
 	if plan.GetInt("price_cents") == 0 {
 		return e.JSON(http.StatusBadRequest, map[string]string{
@@ -45,6 +56,17 @@ func ChangePlanHandler(e *core.RequestEvent, app core.App, subscriptionService S
 	// This will compare prices and route upgrades vs downgrades appropriately
 	result, err := subscriptionService.ChangePlan(userID, req.PlanID)
 	if err != nil {
+		var usageErr *DowngradeUsageError
+		if errors.As(err, &usageErr) {
+			return e.JSON(http.StatusConflict, map[string]any{
+				"error":              usageErr.Message,
+				"code":               usageErr.Code,
+				"hours_used":         usageErr.HoursUsed,
+				"target_plan_hours":  usageErr.TargetPlanHours,
+				"hours_over":         usageErr.HoursOver,
+				"earliest_safe_date": usageErr.EarliestSafeDate.Format("2006-01-02"),
+			})
+		}
 		return e.JSON(http.StatusInternalServerError, map[string]string{
 			"error": fmt.Sprintf("Failed to change plan: %v", err),
 		})
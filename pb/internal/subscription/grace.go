@@ -0,0 +1,81 @@
+package subscription
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// defaultPaymentGraceDays is how long a past_due subscription keeps its features
+const defaultPaymentGraceDays = 7
+
+// PaymentGracePeriod returns how long a past_due subscription keeps access, read from
+// PAYMENT_FAILURE_GRACE_DAYS (0 removes access as soon as a payment fails)
+func PaymentGracePeriod() time.Duration {
+	days := defaultPaymentGraceDays
+	if value := os.Getenv("PAYMENT_FAILURE_GRACE_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// PaymentWarning tells the client that billing needs attention before features are lost
+type PaymentWarning struct {
+	Code        string    `json:"code"`
+	Message     string    `json:"message"`
+	GraceEndsAt time.Time `json:"grace_ends_at"`
+}
+
+// Access describes whether a subscription currently grants paid features
+type Access struct {
+	Subscribed bool
+	Warning    *PaymentWarning // set while a past_due subscription is inside its grace window
+}
+
+// EvaluateAccess decides access from a subscription status and when it became past_due
+func EvaluateAccess(status string, pastDueSince, now time.Time, grace time.Duration) Access {
+	switch SubscriptionStatus(status) {
+	case StatusActive, StatusTrialing:
+		return Access{Subscribed: true}
+	case StatusPastDue:
+		graceEndsAt := pastDueSince.Add(grace)
+		if !now.Before(graceEndsAt) {
+			return Access{}
+		}
+		return Access{
+			Subscribed: true,
+			Warning: &PaymentWarning{
+				Code:        "PAYMENT_PAST_DUE",
+				Message:     fmt.Sprintf("Your last payment failed. Update your payment method before %s to keep your plan.", graceEndsAt.UTC().Format("January 2, 2006")),
+				GraceEndsAt: graceEndsAt,
+			},
+		}
+	default:
+		return Access{}
+	}
+}
+
+// AccessForSubscription evaluates access for a current_user_subscriptions record
+func AccessForSubscription(record *core.Record, now time.Time) Access {
+	return EvaluateAccess(record.GetString("status"), PastDueSince(record), now, PaymentGracePeriod())
+}
+
+// IsSandboxPlan reports whether a subscription was activated by a sandbox checkout. Nobody paid
+// for it, so it only covers the fake providers sandbox tenants use, never real ones
+func IsSandboxPlan(record *core.Record) bool {
+	return record != nil && record.GetString("payment_provider") == "sandbox"
+}
+
+// PastDueSince returns when a subscription became past_due
+// Records that went past_due before the timestamp was tracked fall back to their last update
+func PastDueSince(record *core.Record) time.Time {
+	if since := record.GetDateTime("past_due_since"); !since.IsZero() {
+		return since.Time()
+	}
+	return record.GetDateTime("updated").Time()
+}
@@ -0,0 +1,52 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluateAccess(t *testing.T) {
+	now := time.Date(2025, time.June, 10, 12, 0, 0, 0, time.UTC)
+	grace := 7 * 24 * time.Hour
+
+	t.Run("active subscription", func(t *testing.T) {
+		access := EvaluateAccess("active", time.Time{}, now, grace)
+		if !access.Subscribed || access.Warning != nil {
+			t.Errorf("expected subscribed without warning, got %+v", access)
+		}
+	})
+
+	t.Run("past due inside grace window", func(t *testing.T) {
+		since := now.Add(-3 * 24 * time.Hour)
+		access := EvaluateAccess("past_due", since, now, grace)
+		if !access.Subscribed {
+			t.Fatal("expected past_due subscription to keep access during grace")
+		}
+		if access.Warning == nil || access.Warning.Code != "PAYMENT_PAST_DUE" {
+			t.Fatalf("expected a PAYMENT_PAST_DUE warning, got %+v", access.Warning)
+		}
+		if !access.Warning.GraceEndsAt.Equal(since.Add(grace)) {
+			t.Errorf("expected grace to end at %v, got %v", since.Add(grace), access.Warning.GraceEndsAt)
+		}
+	})
+
+	t.Run("past due after grace window", func(t *testing.T) {
+		access := EvaluateAccess("past_due", now.Add(-8*24*time.Hour), now, grace)
+		if access.Subscribed {
+			t.Error("expected access to end after the grace window")
+		}
+	})
+
+	t.Run("zero grace removes access immediately", func(t *testing.T) {
+		access := EvaluateAccess("past_due", now, now, 0)
+		if access.Subscribed {
+			t.Error("expected no access with a zero grace window")
+		}
+	})
+
+	t.Run("cancelled subscription", func(t *testing.T) {
+		if access := EvaluateAccess("cancelled", time.Time{}, now, grace); access.Subscribed {
+			t.Error("expected cancelled subscription to have no access")
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package subscription
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// History page sizes
+const (
+	defaultHistoryPerPage = 20
+	maxHistoryPerPage     = 100
+)
+
+// HistoryEntry is a plan the user had in the past and why it ended
+type HistoryEntry struct {
+	ID                   string     `json:"id"`
+	Plan                 *PlanRef   `json:"plan"`
+	NextPlan             *PlanRef   `json:"next_plan,omitempty"`
+	Status               string     `json:"status"` // status when the plan ended
+	Reason               string     `json:"reason"` // e.g. "upgrade", "downgrade" or "subscription_cancelled"
+	InitiatedBy          string     `json:"initiated_by"`
+	PaymentProvider      string     `json:"payment_provider,omitempty"`
+	EffectiveFrom        *time.Time `json:"effective_from,omitempty"`
+	EffectiveUntil       time.Time  `json:"effective_until"`
+	CanceledAt           *time.Time `json:"canceled_at,omitempty"`
+	ProrationAmountCents *int64     `json:"proration_amount_cents,omitempty"`
+	Currency             string     `json:"currency,omitempty"`
+
+	planID     string
+	nextPlanID string
+}
+
+// historyPage reads page and per_page, defaulting to the first page of 20
+func historyPage(query func(string) string) (int, int) {
+	page, perPage := 1, defaultHistoryPerPage
+	if v, err := strconv.Atoi(query("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(query("per_page")); err == nil && v > 0 {
+		perPage = min(v, maxHistoryPerPage)
+	}
+	return page, perPage
+}
+
+// historyEntries turns subscription_history records, newest first, into entries. A plan took
+// effect when the one before it was replaced, so records holds one extra, older record when
+// there is one; without it the oldest entry falls back to the start of its last billing period
+func historyEntries(records []*core.Record, limit int) []HistoryEntry {
+	entries := make([]HistoryEntry, 0, min(len(records), limit))
+	for i, record := range records {
+		if i == limit {
+			break
+		}
+		entry := HistoryEntry{
+			ID:              record.Id,
+			Status:          record.GetString("status"),
+			Reason:          record.GetString("replacement_reason"),
+			InitiatedBy:     record.GetString("initiated_by"),
+			PaymentProvider: record.GetString("payment_provider"),
+			EffectiveUntil:  record.GetDateTime("replaced_at").Time().UTC(),
+			planID:          record.GetString("plan_id"),
+			nextPlanID:      record.GetString("new_plan_id"),
+		}
+		if entry.InitiatedBy == "" {
+			entry.InitiatedBy = legacyInitiator(entry.Reason)
+		}
+
+		from := record.GetDateTime("current_period_start").Time()
+		if i+1 < len(records) {
+			from = records[i+1].GetDateTime("replaced_at").Time()
+		}
+		if !from.IsZero() {
+			from = from.UTC()
+			entry.EffectiveFrom = &from
+		}
+		if canceledAt := record.GetDateTime("canceled_at").Time(); !canceledAt.IsZero() {
+			canceledAt = canceledAt.UTC()
+			entry.CanceledAt = &canceledAt
+		}
+		// Zero means no proration was recorded
+		if amount := int64(record.GetInt("proration_amount_cents")); amount != 0 {
+			entry.ProrationAmountCents = &amount
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// SubscriptionHistoryHandler lists the plans the user had before their current one, newest first
+// GET /api/subscription/history?page=1&per_page=20
+func SubscriptionHistoryHandler(e *core.RequestEvent, app core.App) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	page, perPage := historyPage(e.Request.URL.Query().Get)
+
+	// One extra record tells the last entry on the page when it took effect
+	records, err := app.FindRecordsByFilter("subscription_history", "user_id = {:user_id}", "-replaced_at,-created",
+		perPage+1, (page-1)*perPage, map[string]any{"user_id": user.Id})
+	if err != nil {
+		slog.Error("Failed to load subscription history", "user_id", user.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load subscription history"})
+	}
+	total, err := app.CountRecords("subscription_history", dbx.HashExp{"user_id": user.Id})
+	if err != nil {
+		slog.Error("Failed to count subscription history", "user_id", user.Id, "error", err)
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load subscription history"})
+	}
+
+	entries := historyEntries(records, perPage)
+
+	repo := NewRepository(app)
+	plans := map[string]*core.Record{}
+	planRef := func(planID string) *PlanRef {
+		if planID == "" {
+			return nil
+		}
+		if _, ok := plans[planID]; !ok {
+			plans[planID], _ = repo.GetPlan(planID)
+		}
+		ref := &PlanRef{ID: planID}
+		if plan := plans[planID]; plan != nil {
+			ref.Name = plan.GetString("name")
+		}
+		return ref
+	}
+	for i := range entries {
+		entries[i].Plan = planRef(entries[i].planID)
+		entries[i].NextPlan = planRef(entries[i].nextPlanID)
+		if entries[i].ProrationAmountCents != nil {
+			if plan := plans[entries[i].nextPlanID]; plan != nil {
+				entries[i].Currency = plan.GetString("currency")
+			}
+		}
+	}
+
+	return e.JSON(http.StatusOK, map[string]any{
+		"history":     entries,
+		"page":        page,
+		"per_page":    perPage,
+		"total":       total,
+		"total_pages": (total + int64(perPage) - 1) / int64(perPage),
+	})
+}
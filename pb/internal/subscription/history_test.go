@@ -0,0 +1,88 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func historyRecord(id, planID, newPlanID, reason string, periodStart, replacedAt time.Time) *core.Record {
+	collection := core.NewBaseCollection("subscription_history")
+	collection.Fields.Add(
+		&core.TextField{Name: "plan_id"},
+		&core.TextField{Name: "new_plan_id"},
+		&core.TextField{Name: "status"},
+		&core.TextField{Name: "replacement_reason"},
+		&core.TextField{Name: "initiated_by"},
+		&core.DateField{Name: "current_period_start"},
+		&core.DateField{Name: "replaced_at"},
+		&core.NumberField{Name: "proration_amount_cents"},
+	)
+	record := core.NewRecord(collection)
+	record.Id = id
+	record.Set("plan_id", planID)
+	record.Set("new_plan_id", newPlanID)
+	record.Set("status", "active")
+	record.Set("replacement_reason", reason)
+	record.Set("current_period_start", periodStart)
+	record.Set("replaced_at", replacedAt)
+	return record
+}
+
+func TestHistoryEntries(t *testing.T) {
+	jan := time.Date(2026, time.January, 10, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2026, time.March, 5, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2026, time.June, 20, 0, 0, 0, 0, time.UTC)
+
+	records := []*core.Record{
+		historyRecord("h3", "plan_pro", "plan_basic", "downgrade", jun.AddDate(0, 0, -10), jun),
+		historyRecord("h2", "plan_basic", "plan_pro", "upgrade", mar.AddDate(0, 0, -3), mar),
+		historyRecord("h1", "plan_free", "plan_basic", "", jan.AddDate(0, 0, -20), jan),
+	}
+	records[0].Set("proration_amount_cents", -450)
+	records[0].Set("initiated_by", "user")
+
+	// The extra record only dates the last entry on the page
+	entries := historyEntries(records, 2)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].EffectiveFrom == nil || !entries[0].EffectiveFrom.Equal(mar) || !entries[0].EffectiveUntil.Equal(jun) {
+		t.Errorf("h3 should run from %v to %v, got %v to %v", mar, jun, entries[0].EffectiveFrom, entries[0].EffectiveUntil)
+	}
+	if entries[1].EffectiveFrom == nil || !entries[1].EffectiveFrom.Equal(jan) {
+		t.Errorf("h2 should start when h1 was replaced, got %v", entries[1].EffectiveFrom)
+	}
+	if entries[0].ProrationAmountCents == nil || *entries[0].ProrationAmountCents != -450 || entries[0].InitiatedBy != "user" {
+		t.Errorf("unexpected h3 details %+v", entries[0])
+	}
+	if entries[1].ProrationAmountCents != nil || entries[1].InitiatedBy != "unknown" {
+		t.Errorf("unexpected h2 details %+v", entries[1])
+	}
+
+	// The oldest entry falls back to the start of its last period
+	last := historyEntries(records[2:], 2)
+	if len(last) != 1 || last[0].EffectiveFrom == nil || !last[0].EffectiveFrom.Equal(jan.AddDate(0, 0, -20)) {
+		t.Errorf("oldest entry should start at its period start, got %+v", last)
+	}
+}
+
+func TestHistoryPage(t *testing.T) {
+	tests := []struct {
+		page, perPage         string
+		wantPage, wantPerPage int
+	}{
+		{"", "", 1, defaultHistoryPerPage},
+		{"3", "10", 3, 10},
+		{"0", "-5", 1, defaultHistoryPerPage},
+		{"x", "500", 1, maxHistoryPerPage},
+	}
+	for _, tt := range tests {
+		query := map[string]string{"page": tt.page, "per_page": tt.perPage}
+		page, perPage := historyPage(func(key string) string { return query[key] })
+		if page != tt.wantPage || perPage != tt.wantPerPage {
+			t.Errorf("page=%q per_page=%q: got %d/%d, want %d/%d", tt.page, tt.perPage, page, perPage, tt.wantPage, tt.wantPerPage)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Billing intervals a paid plan can be billed at
+const (
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// MonthlyPriceCents is what the plan costs per month, so monthly and yearly plans can be
+// compared and displayed side by side
+func MonthlyPriceCents(plan *core.Record) int64 {
+	price := int64(plan.GetInt("price_cents"))
+	if plan.GetString("billing_interval") == IntervalYear {
+		return int64(math.Round(float64(price) / 12))
+	}
+	return price
+}
+
+// planForProviderSubscription finds the plan the subscription's price bills for. Prices added in
+// the provider after the plans were seeded (e.g. a yearly price on an existing product) fall back
+// to the plan for the same product and billing interval
+func (s *SubscriptionService) planForProviderSubscription(sub *ProviderSubscription) (*core.Record, string, error) {
+	if sub.PriceID == "" {
+		return nil, "", fmt.Errorf("failed to extract price from subscription: subscription has no price")
+	}
+
+	plan, err := s.repo.GetPlanByProviderPrice(sub.PriceID)
+	if err == nil {
+		return plan, sub.PriceID, nil
+	}
+
+	if sub.ProductID != "" && sub.Interval != "" {
+		if byProduct, productErr := s.repo.GetPlanByProviderProduct(sub.ProductID, sub.Interval); productErr == nil {
+			s.log().Warn("Matched unknown provider price to plan by product and interval", "price_id", sub.PriceID,
+				"product_id", sub.ProductID, "interval", sub.Interval, "plan_id", byProduct.Id)
+			return byProduct, sub.PriceID, nil
+		}
+	}
+	return nil, "", fmt.Errorf("failed to find subscription plan for price %s: %w", sub.PriceID, err)
+}
+
+// ChangeInterval moves the user's paid subscription to the same plan billed every interval
+// ("month" or "year"), using the plan's paired_plan_id. Stripe starts the new billing period
+// straight away and invoices the difference, crediting what's left of the current period
+func (s *SubscriptionService) ChangeInterval(userID string, interval string) (*ChangePlanResult, error) {
+	if interval != IntervalMonth && interval != IntervalYear {
+		return nil, BusinessRuleError{Rule: "billing_interval", Message: "interval must be 'month' or 'year'"}
+	}
+
+	currentSub, err := s.repo.FindActiveSubscription(userID)
+	if err != nil {
+		return nil, fmt.Errorf("no active subscription found for user %s: %w", userID, err)
+	}
+	stripeSubID := currentSub.GetString("provider_subscription_id")
+	if stripeSubID == "" {
+		return nil, BusinessRuleError{Rule: "provider_managed", Message: "subscription isn't managed by the payment provider"}
+	}
+
+	currentPlan, err := s.repo.GetPlan(currentSub.GetString("plan_id"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current plan: %w", err)
+	}
+	if currentPlan.GetString("billing_interval") == interval {
+		return nil, BusinessRuleError{Rule: "billing_interval", Message: fmt.Sprintf("subscription is already billed every %s", interval)}
+	}
+
+	pairedPlanID := currentPlan.GetString("paired_plan_id")
+	if pairedPlanID == "" {
+		return nil, BusinessRuleError{Rule: "billing_interval", Message: fmt.Sprintf("%s has no %sly billing option", currentPlan.GetString("name"), interval)}
+	}
+	targetPlan, err := s.repo.GetPlan(pairedPlanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paired plan: %w", err)
+	}
+	if targetPlan.GetString("billing_interval") != interval || !targetPlan.GetBool("is_active") {
+		return nil, BusinessRuleError{Rule: "billing_interval", Message: fmt.Sprintf("%s has no %sly billing option", currentPlan.GetString("name"), interval)}
+	}
+
+	stripePriceID := targetPlan.GetString("provider_price_id")
+	if stripePriceID == "" {
+		return nil, fmt.Errorf("target plan has no Stripe price ID")
+	}
+
+	s.log().Info("Changing billing interval", "user_id", userID, "plan", currentPlan.GetString("name"),
+		"from_interval", currentPlan.GetString("billing_interval"), "to_interval", interval)
+
+	prorationCents, err := s.changeProviderPrice(stripeSubID, stripePriceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update provider subscription: %w", err)
+	}
+
+	if _, err := s.repo.MoveSubscriptionToHistory(currentSub, HistoryParams{
+		Reason:               "interval_change",
+		InitiatedBy:          InitiatedByUser,
+		NewPlanID:            targetPlan.Id,
+		ProrationAmountCents: prorationCents,
+	}); err != nil {
+		s.log().Warn("Failed to record interval change in history", "user_id", userID, "error", err)
+	}
+
+	// The new period dates arrive with the customer.subscription.updated webhook
+	_, err = s.repo.UpdateSubscription(currentSub.Id, UpdateSubscriptionParams{
+		PlanID:          &targetPlan.Id,
+		ProviderPriceID: &stripePriceID,
+	})
+	if err != nil {
+		s.log().Error("Stripe updated but local database update failed", "user_id", userID, "error", err)
+	}
+
+	return &ChangePlanResult{
+		Success:       true,
+		Message:       fmt.Sprintf("%s is now billed every %s - changes take effect immediately", targetPlan.GetString("name"), interval),
+		ChangeType:    "interval_change",
+		NewPlan:       targetPlan.Id,
+		EffectiveDate: "immediately",
+	}, nil
+}
+
+// ChangeIntervalHandler switches the user's plan between monthly and yearly billing
+// POST /api/payment/change-interval {"interval": "year"}
+func ChangeIntervalHandler(e *core.RequestEvent, app core.App, subscriptionService Service) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		Interval string `json:"interval"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	result, err := subscriptionService.ChangeInterval(user.Id, req.Interval)
+	if err != nil {
+		var ruleErr BusinessRuleError
+		if errors.As(err, &ruleErr) {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": ruleErr.Message})
+		}
+		return e.JSON(http.StatusInternalServerError, map[string]string{
+			"error": fmt.Sprintf("Failed to change billing interval: %v", err),
+		})
+	}
+
+	return e.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,47 @@
+package subscription
+
+import (
+	"testing"
+)
+
+func TestMonthlyPriceCents(t *testing.T) {
+	if got := MonthlyPriceCents(testPlan("pro", 1500, 25, IntervalMonth, true, false)); got != 1500 {
+		t.Errorf("monthly plan = %d, want 1500", got)
+	}
+	if got := MonthlyPriceCents(testPlan("pro_yearly", 15000, 25, IntervalYear, true, false)); got != 1250 {
+		t.Errorf("yearly plan = %d, want 1250", got)
+	}
+}
+
+func TestPlanForProviderSubscription(t *testing.T) {
+	repo := NewMockRepository()
+	yearly := testPlan("pro_yearly", 15000, 25, IntervalYear, true, false)
+	yearly.Set("provider_product_id", "prod_pro")
+	repo.plans[yearly.Id] = yearly
+	repo.plansByPrice["price_pro_yearly"] = yearly
+	service := NewService(repo).(*SubscriptionService)
+
+	subscriptionFor := func(priceID string) *ProviderSubscription {
+		return &ProviderSubscription{PriceID: priceID, ProductID: "prod_pro", Interval: IntervalYear}
+	}
+
+	plan, priceID, err := service.planForProviderSubscription(subscriptionFor("price_pro_yearly"))
+	if err != nil || plan.Id != "pro_yearly" || priceID != "price_pro_yearly" {
+		t.Fatalf("known price: got %v %q %v", plan, priceID, err)
+	}
+
+	// A yearly price created in Stripe after seeding maps to the yearly plan for its product
+	plan, priceID, err = service.planForProviderSubscription(subscriptionFor("price_pro_yearly_2027"))
+	if err != nil || plan.Id != "pro_yearly" || priceID != "price_pro_yearly_2027" {
+		t.Fatalf("unknown price: got %v %q %v", plan, priceID, err)
+	}
+
+	sub := subscriptionFor("price_other")
+	sub.ProductID = "prod_other"
+	if _, _, err := service.planForProviderSubscription(sub); err == nil {
+		t.Fatal("expected an error for a price of an unknown product")
+	}
+	if _, _, err := service.planForProviderSubscription(&ProviderSubscription{}); err == nil {
+		t.Fatal("expected an error for a subscription without a price")
+	}
+}
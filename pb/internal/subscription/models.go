@@ -23,6 +23,7 @@ type SubscriptionInfo struct {
 	Plan           *core.Record     `json:"plan"`
 	Usage          *UsageInfo       `json:"usage"`
 	AvailablePlans []*core.Record   `json:"available_plans"`
+	PaymentWarning *PaymentWarning  `json:"payment_warning,omitempty"`
 }
 
 // UsageInfo represents user usage statistics
@@ -45,6 +46,7 @@ type CreateSubscriptionParams struct {
 	CurrentPeriodStart       time.Time
 	CurrentPeriodEnd         time.Time
 	CanceledAt               *time.Time
+	TrialEnd                 *time.Time
 }
 
 // UpdateSubscriptionParams represents parameters for updating a subscription
@@ -57,6 +59,30 @@ type UpdateSubscriptionParams struct {
 	CurrentPeriodStart       *time.Time
 	CurrentPeriodEnd         *time.Time
 	CanceledAt               *time.Time
+	TrialEnd                 *time.Time // a zero time clears it
+	OverridePlanID           *string // an empty plan clears the override
+	OverrideExpiresAt        *time.Time
+	OverrideReason           *string
+	PausedAt                 *time.Time // a zero time clears it, resuming the subscription
+	ResumesAt                *time.Time // a zero time clears it
+}
+
+// ChangeInitiator records who or what caused a subscription to move to history
+type ChangeInitiator string
+
+const (
+	InitiatedByUser    ChangeInitiator = "user"
+	InitiatedByWebhook ChangeInitiator = "webhook" // payment provider events
+	InitiatedByAdmin   ChangeInitiator = "admin"
+	InitiatedBySystem  ChangeInitiator = "system" // automatic repairs such as free plan fallbacks
+)
+
+// HistoryParams describes why a subscription is being moved to history
+type HistoryParams struct {
+	Reason               string
+	InitiatedBy          ChangeInitiator
+	NewPlanID            string // plan the user moved to, when known
+	ProrationAmountCents *int64 // amount invoiced (or credited, if negative) for the change, when known
 }
 
 // SubscriptionQuery represents query parameters for finding subscriptions
@@ -109,8 +135,23 @@ func (e BusinessRuleError) Error() string {
 type ChangePlanResult struct {
 	Success       bool   `json:"success"`
 	Message       string `json:"message"`
-	ChangeType    string `json:"change_type"`    // "upgrade" or "downgrade"
+	ChangeType    string `json:"change_type"`    // "upgrade", "downgrade" or "interval_change"
 	NewPlan       string `json:"new_plan"`
 	EffectiveDate string `json:"effective_date"` // "immediately" or formatted date
 	PendingChange bool   `json:"pending_change,omitempty"`
+	UsageWarning  *DowngradeUsageError `json:"usage_warning,omitempty"`
+}
+
+// DowngradeUsageError is returned when the current month's usage already exceeds the target plan's limit
+type DowngradeUsageError struct {
+	Code             string    `json:"code"`
+	Message          string    `json:"message"`
+	HoursUsed        float64   `json:"hours_used"`
+	TargetPlanHours  float64   `json:"target_plan_hours"`
+	HoursOver        float64   `json:"hours_over"`
+	EarliestSafeDate time.Time `json:"earliest_safe_date"`
+}
+
+func (e *DowngradeUsageError) Error() string {
+	return e.Message
 }
\ No newline at end of file
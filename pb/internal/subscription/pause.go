@@ -0,0 +1,220 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// defaultPauseDays is how long a pause lasts when the request doesn't say
+const defaultPauseDays = 30
+
+// maxPauseDays reads SUBSCRIPTION_PAUSE_MAX_DAYS: the longest a subscription can be paused
+// for (default 90). Every pause gets a resume date no later than this
+func maxPauseDays() int {
+	if v, err := strconv.Atoi(os.Getenv("SUBSCRIPTION_PAUSE_MAX_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return 90
+}
+
+// IsPaused reports whether the subscription is paused at now. A pause ends by itself at
+// resumes_at, even before the provider's webhook or the resume request clears it
+func IsPaused(subscription *core.Record, now time.Time) bool {
+	if subscription == nil || subscription.GetDateTime("paused_at").IsZero() {
+		return false
+	}
+	resumesAt := subscription.GetDateTime("resumes_at")
+	return resumesAt.IsZero() || now.Before(resumesAt.Time())
+}
+
+// PauseResumeDate checks a requested resume date, or picks one from days (the default pause
+// when both are zero). It must be in the future and within the longest allowed pause
+func PauseResumeDate(resumesAt time.Time, days int, now time.Time) (time.Time, error) {
+	latest := now.AddDate(0, 0, maxPauseDays())
+	if resumesAt.IsZero() {
+		if days == 0 {
+			days = defaultPauseDays
+		}
+		if days < 1 || days > maxPauseDays() {
+			return time.Time{}, ValidationError{Field: "days", Message: fmt.Sprintf("must be between 1 and %d", maxPauseDays())}
+		}
+		return now.AddDate(0, 0, days), nil
+	}
+	if !resumesAt.After(now) {
+		return time.Time{}, ValidationError{Field: "resumes_at", Message: "must be in the future"}
+	}
+	if resumesAt.After(latest) {
+		return time.Time{}, ValidationError{Field: "resumes_at", Message: fmt.Sprintf("must be within %d days", maxPauseDays())}
+	}
+	return resumesAt, nil
+}
+
+// providerPause copies the provider's pause state into params. A subscription that was already
+// paused keeps its paused_at
+func providerPause(subscription *core.Record, providerSub *ProviderSubscription, params *UpdateSubscriptionParams) {
+	var pausedAt, resumesAt time.Time
+	if providerSub.Paused {
+		pausedAt = subscription.GetDateTime("paused_at").Time()
+		if subscription.GetDateTime("paused_at").IsZero() {
+			pausedAt = time.Now()
+		}
+		if providerSub.ResumesAt != nil {
+			resumesAt = *providerSub.ResumesAt
+		}
+	}
+	params.PausedAt = &pausedAt
+	params.ResumesAt = &resumesAt
+}
+
+// PauseSubscription stops billing and quota on the user's paid subscription until resumesAt.
+// Provider subscriptions stop collecting payment with the provider; others are paused here
+func (s *SubscriptionService) PauseSubscription(userID string, resumesAt time.Time) (*core.Record, error) {
+	current, err := s.repo.FindActiveSubscription(userID)
+	if err != nil {
+		return nil, BusinessRuleError{Rule: "pause_active", Message: "only an active subscription can be paused"}
+	}
+	if IsPaused(current, time.Now()) {
+		return nil, BusinessRuleError{Rule: "pause_once", Message: "subscription is already paused"}
+	}
+	if current.GetString("status") != string(StatusActive) {
+		return nil, BusinessRuleError{Rule: "pause_active", Message: "only an active subscription can be paused"}
+	}
+	plan, err := s.repo.GetPlan(current.GetString("plan_id"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+	if plan.GetInt("price_cents") == 0 {
+		return nil, BusinessRuleError{Rule: "pause_paid", Message: "only paid plans can be paused"}
+	}
+
+	if providerSubID := current.GetString("provider_subscription_id"); providerSubID != "" {
+		if s.provider == nil {
+			return nil, ErrNoPaymentProvider
+		}
+		providerSub, err := s.provider.PauseSubscription(providerSubID, resumesAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pause provider subscription %s: %w", providerSubID, err)
+		}
+		if err := s.updateSubscriptionMetadataOnly(current, providerSub); err != nil {
+			return nil, err
+		}
+	} else {
+		pausedAt := time.Now()
+		if _, err := s.repo.UpdateSubscription(current.Id, UpdateSubscriptionParams{PausedAt: &pausedAt, ResumesAt: &resumesAt}); err != nil {
+			return nil, err
+		}
+	}
+
+	s.log().Info("Subscription paused", "user_id", userID, "resumes_at", resumesAt.Format("2006-01-02"))
+	return s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+}
+
+// ResumeSubscription ends a pause early, restoring the plan's quota straight away
+func (s *SubscriptionService) ResumeSubscription(userID string) (*core.Record, error) {
+	current, err := s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	if !IsPaused(current, time.Now()) {
+		return nil, BusinessRuleError{Rule: "resume_paused", Message: "subscription isn't paused"}
+	}
+
+	if providerSubID := current.GetString("provider_subscription_id"); providerSubID != "" {
+		if s.provider == nil {
+			return nil, ErrNoPaymentProvider
+		}
+		providerSub, err := s.provider.ResumeSubscription(providerSubID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume provider subscription %s: %w", providerSubID, err)
+		}
+		if err := s.updateSubscriptionMetadataOnly(current, providerSub); err != nil {
+			return nil, err
+		}
+	} else {
+		var cleared time.Time
+		if _, err := s.repo.UpdateSubscription(current.Id, UpdateSubscriptionParams{PausedAt: &cleared, ResumesAt: &cleared}); err != nil {
+			return nil, err
+		}
+	}
+
+	s.log().Info("Subscription resumed", "user_id", userID)
+	return s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+}
+
+// pauseResponse describes the subscription's pause state
+func pauseResponse(subscription *core.Record) map[string]any {
+	paused := IsPaused(subscription, time.Now())
+	response := map[string]any{"paused": paused, "paused_at": nil, "resumes_at": nil}
+	if paused {
+		response["paused_at"] = subscription.GetDateTime("paused_at").Time().UTC().Format(time.RFC3339)
+		if resumesAt := subscription.GetDateTime("resumes_at"); !resumesAt.IsZero() {
+			response["resumes_at"] = resumesAt.Time().UTC().Format(time.RFC3339)
+		}
+	}
+	return response
+}
+
+// pauseError answers a failed pause or resume
+func pauseError(e *core.RequestEvent, action string, err error) error {
+	var ruleErr BusinessRuleError
+	if errors.As(err, &ruleErr) {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": ruleErr.Message})
+	}
+	var validationErr ValidationError
+	if errors.As(err, &validationErr) {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": validationErr.Field + " " + validationErr.Message})
+	}
+	return e.JSON(http.StatusInternalServerError, map[string]string{
+		"error": fmt.Sprintf("Failed to %s subscription: %v", action, err),
+	})
+}
+
+// PauseSubscriptionHandler pauses billing for up to SUBSCRIPTION_PAUSE_MAX_DAYS. While paused
+// the plan's hours are frozen at zero; the subscription resumes by itself at resumes_at.
+// Body: {"resumes_at": RFC3339} or {"days": n}, 30 days by default
+// POST /api/subscription/pause
+func PauseSubscriptionHandler(e *core.RequestEvent, app core.App, subscriptionService Service) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		ResumesAt time.Time `json:"resumes_at"`
+		Days      int       `json:"days"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	resumesAt, err := PauseResumeDate(req.ResumesAt, req.Days, time.Now())
+	if err != nil {
+		return pauseError(e, "pause", err)
+	}
+
+	record, err := subscriptionService.PauseSubscription(user.Id, resumesAt)
+	if err != nil {
+		return pauseError(e, "pause", err)
+	}
+	return e.JSON(http.StatusOK, pauseResponse(record))
+}
+
+// ResumeSubscriptionHandler ends the caller's pause before its resume date
+// POST /api/subscription/resume
+func ResumeSubscriptionHandler(e *core.RequestEvent, app core.App, subscriptionService Service) error {
+	user := e.Auth
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	record, err := subscriptionService.ResumeSubscription(user.Id)
+	if err != nil {
+		return pauseError(e, "resume", err)
+	}
+	return e.JSON(http.StatusOK, pauseResponse(record))
+}
@@ -0,0 +1,56 @@
+//go:build integration
+
+package subscription_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"pocketbase/internal/routes"
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+func TestPauseAndResumeWithoutProvider(t *testing.T) {
+	app := testharness.New(t)
+	plans := testharness.SeedPlans(t, app)
+	user := testharness.CreateUser(t, app, "pauser@test.com")
+	token := testharness.AuthToken(t, user)
+
+	service := subscription.NewService(subscription.NewRepository(app))
+	if _, err := service.AssignPlan(user.Id, plans["basic"].Id, time.Now().AddDate(0, 1, 0), "invoice"); err != nil {
+		t.Fatal(err)
+	}
+	server := testharness.Serve(t, app, func(r *routes.Registry) {
+		subscription.RegisterRoutes(r, service)
+	})
+
+	server.Do(t, http.MethodPost, "/api/subscription/pause", token, map[string]int{"days": 365}).
+		Expect(t, http.StatusBadRequest)
+	body := server.Do(t, http.MethodPost, "/api/subscription/pause", token, map[string]int{"days": 14}).
+		Expect(t, http.StatusOK).JSON(t)
+	if body["paused"] != true || body["resumes_at"] == nil {
+		t.Fatalf("pause response = %v, want paused with a resume date", body)
+	}
+	server.Do(t, http.MethodPost, "/api/subscription/pause", token, nil).Expect(t, http.StatusBadRequest)
+
+	// No hours while paused
+	info, err := service.GetUserSubscriptionInfo(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Usage.HoursLimit != 0 {
+		t.Errorf("hours limit while paused = %v, want 0", info.Usage.HoursLimit)
+	}
+
+	body = server.Do(t, http.MethodPost, "/api/subscription/resume", token, nil).Expect(t, http.StatusOK).JSON(t)
+	if body["paused"] != false {
+		t.Fatalf("resume response = %v, want not paused", body)
+	}
+	info, err = service.GetUserSubscriptionInfo(user.Id)
+	if err != nil || info.Usage.HoursLimit != plans["basic"].GetFloat("hours_per_month") {
+		t.Errorf("hours limit after resuming = %v (%v), want the plan's", info.Usage.HoursLimit, err)
+	}
+	server.Do(t, http.MethodPost, "/api/subscription/resume", token, nil).Expect(t, http.StatusBadRequest)
+}
@@ -0,0 +1,181 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func pauseSubscription(providerSubID string) *core.Record {
+	collection := core.NewBaseCollection("current_user_subscriptions")
+	collection.Fields.Add(
+		&core.TextField{Name: "plan_id"},
+		&core.TextField{Name: "provider_subscription_id"},
+		&core.TextField{Name: "status"},
+		&core.DateField{Name: "paused_at"},
+		&core.DateField{Name: "resumes_at"},
+	)
+	record := core.NewRecord(collection)
+	record.Id = "sub_record"
+	record.Set("plan_id", "plan_pro")
+	record.Set("provider_subscription_id", providerSubID)
+	record.Set("status", string(StatusActive))
+	return record
+}
+
+func pausePlan(id string, priceCents int) *core.Record {
+	collection := core.NewBaseCollection("subscription_plans")
+	collection.Fields.Add(&core.NumberField{Name: "price_cents"})
+	plan := core.NewRecord(collection)
+	plan.Id = id
+	plan.Set("price_cents", priceCents)
+	return plan
+}
+
+func TestIsPaused(t *testing.T) {
+	now := time.Date(2026, time.May, 1, 12, 0, 0, 0, time.UTC)
+	record := pauseSubscription("")
+
+	if IsPaused(record, now) || IsPaused(nil, now) {
+		t.Error("subscription without paused_at reported as paused")
+	}
+
+	record.Set("paused_at", now.Add(-time.Hour))
+	if !IsPaused(record, now) {
+		t.Error("pause without a resume date not reported")
+	}
+
+	record.Set("resumes_at", now.Add(24*time.Hour))
+	if !IsPaused(record, now) {
+		t.Error("pause before its resume date not reported")
+	}
+	if IsPaused(record, now.Add(24*time.Hour)) {
+		t.Error("pause still reported at its resume date")
+	}
+}
+
+func TestPauseResumeDate(t *testing.T) {
+	now := time.Date(2026, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	if got, err := PauseResumeDate(time.Time{}, 0, now); err != nil || !got.Equal(now.AddDate(0, 0, defaultPauseDays)) {
+		t.Errorf("default pause = %v, %v; want %d days", got, err, defaultPauseDays)
+	}
+	if got, err := PauseResumeDate(time.Time{}, 7, now); err != nil || !got.Equal(now.AddDate(0, 0, 7)) {
+		t.Errorf("7 day pause = %v, %v", got, err)
+	}
+	resumesAt := now.Add(48 * time.Hour)
+	if got, err := PauseResumeDate(resumesAt, 0, now); err != nil || !got.Equal(resumesAt) {
+		t.Errorf("pause until %v = %v, %v", resumesAt, got, err)
+	}
+
+	t.Setenv("SUBSCRIPTION_PAUSE_MAX_DAYS", "60")
+	invalid := map[string]struct {
+		resumesAt time.Time
+		days      int
+		field     string
+	}{
+		"negative days":   {days: -1, field: "days"},
+		"too many days":   {days: 61, field: "days"},
+		"past date":       {resumesAt: now.Add(-time.Hour), field: "resumes_at"},
+		"too late a date": {resumesAt: now.AddDate(0, 0, 61), field: "resumes_at"},
+	}
+	for name, tc := range invalid {
+		_, err := PauseResumeDate(tc.resumesAt, tc.days, now)
+		var validationErr ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != tc.field {
+			t.Errorf("%s: expected a %s validation error, got %v", name, tc.field, err)
+		}
+	}
+}
+
+func TestPauseSubscription(t *testing.T) {
+	resumesAt := time.Now().Add(30 * 24 * time.Hour)
+
+	t.Run("only paid plans", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.plans["plan_pro"] = pausePlan("plan_pro", 0)
+		repo.activeSubscriptions["user_1"] = pauseSubscription("sub_stripe_1")
+		provider := NewMockPaymentProvider()
+		service := NewServiceWithProvider(repo, provider)
+
+		_, err := service.PauseSubscription("user_1", resumesAt)
+		var ruleErr BusinessRuleError
+		if !errors.As(err, &ruleErr) || ruleErr.Rule != "pause_paid" {
+			t.Fatalf("expected a pause_paid rule error, got %v", err)
+		}
+		if len(provider.PauseCalls) != 0 {
+			t.Errorf("PauseCalls = %v, want none", provider.PauseCalls)
+		}
+	})
+
+	t.Run("not twice", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.plans["plan_pro"] = pausePlan("plan_pro", 1500)
+		record := pauseSubscription("")
+		record.Set("paused_at", time.Now().Add(-time.Hour))
+		repo.activeSubscriptions["user_1"] = record
+		service := NewService(repo)
+
+		_, err := service.PauseSubscription("user_1", resumesAt)
+		var ruleErr BusinessRuleError
+		if !errors.As(err, &ruleErr) || ruleErr.Rule != "pause_once" {
+			t.Fatalf("expected a pause_once rule error, got %v", err)
+		}
+	})
+
+	t.Run("provider subscriptions pause with the provider", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.plans["plan_pro"] = pausePlan("plan_pro", 1500)
+		record := pauseSubscription("sub_stripe_1")
+		repo.activeSubscriptions["user_1"] = record
+		repo.subscriptions[record.Id] = record
+		provider := NewMockPaymentProvider()
+		service := NewServiceWithProvider(repo, provider)
+
+		if _, err := service.PauseSubscription("user_1", resumesAt); err != nil {
+			t.Fatalf("PauseSubscription: %v", err)
+		}
+		if len(provider.PauseCalls) != 1 || provider.PauseCalls[0] != "sub_stripe_1" {
+			t.Errorf("PauseCalls = %v, want [sub_stripe_1]", provider.PauseCalls)
+		}
+	})
+
+	t.Run("resume needs a pause", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.activeSubscriptions["user_1"] = pauseSubscription("sub_stripe_1")
+		provider := NewMockPaymentProvider()
+		service := NewServiceWithProvider(repo, provider)
+
+		_, err := service.ResumeSubscription("user_1")
+		var ruleErr BusinessRuleError
+		if !errors.As(err, &ruleErr) || ruleErr.Rule != "resume_paused" {
+			t.Fatalf("expected a resume_paused rule error, got %v", err)
+		}
+		if len(provider.ResumeCalls) != 0 {
+			t.Errorf("ResumeCalls = %v, want none", provider.ResumeCalls)
+		}
+	})
+}
+
+func TestProviderPause(t *testing.T) {
+	pausedAt := time.Now().Add(-24 * time.Hour).Truncate(time.Millisecond)
+	resumesAt := time.Now().Add(24 * time.Hour)
+	record := pauseSubscription("sub_stripe_1")
+	record.Set("paused_at", pausedAt)
+
+	var params UpdateSubscriptionParams
+	providerPause(record, &ProviderSubscription{Paused: true, ResumesAt: &resumesAt}, &params)
+	if params.PausedAt == nil || !params.PausedAt.Equal(pausedAt) {
+		t.Errorf("PausedAt = %v, want the existing %v", params.PausedAt, pausedAt)
+	}
+	if params.ResumesAt == nil || !params.ResumesAt.Equal(resumesAt) {
+		t.Errorf("ResumesAt = %v, want %v", params.ResumesAt, resumesAt)
+	}
+
+	providerPause(record, &ProviderSubscription{}, &params)
+	if !params.PausedAt.IsZero() || !params.ResumesAt.IsZero() {
+		t.Errorf("resumed subscription: PausedAt = %v, ResumesAt = %v, want both cleared", params.PausedAt, params.ResumesAt)
+	}
+}
@@ -0,0 +1,143 @@
+package subscription
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// planCacheTTL bounds staleness for plan edits that bypass record hooks (e.g. direct SQL)
+const planCacheTTL = 5 * time.Minute
+
+// planSnapshot holds every subscription_plans record, loaded in one query
+type planSnapshot struct {
+	plans    []*core.Record // ordered by price_cents
+	byID     map[string]*core.Record
+	loadedAt time.Time
+}
+
+func newPlanSnapshot(records []*core.Record, loadedAt time.Time) *planSnapshot {
+	plans := make([]*core.Record, len(records))
+	copy(plans, records)
+	sort.SliceStable(plans, func(a, b int) bool { return plans[a].GetInt("price_cents") < plans[b].GetInt("price_cents") })
+
+	byID := make(map[string]*core.Record, len(plans))
+	for _, plan := range plans {
+		byID[plan.Id] = plan
+	}
+	return &planSnapshot{plans: plans, byID: byID, loadedAt: loadedAt}
+}
+
+func (s *planSnapshot) plan(planID string) *core.Record {
+	return s.byID[planID]
+}
+
+func (s *planSnapshot) planByProviderPrice(providerPriceID string) *core.Record {
+	for _, plan := range s.plans {
+		if plan.GetString("provider_price_id") == providerPriceID {
+			return plan
+		}
+	}
+	return nil
+}
+
+// planByProduct returns the active plan for a provider product billed every interval
+func (s *planSnapshot) planByProduct(providerProductID, interval string) *core.Record {
+	for _, plan := range s.plans {
+		if plan.GetBool("is_active") && plan.GetString("provider_product_id") == providerProductID &&
+			plan.GetString("billing_interval") == interval {
+			return plan
+		}
+	}
+	return nil
+}
+
+func (s *planSnapshot) freePlan() *core.Record {
+	for _, plan := range s.plans {
+		if plan.GetString("billing_interval") == "free" {
+			return plan
+		}
+	}
+	return nil
+}
+
+// listed returns active, non-custom plans with more than minHours monthly hours, cheapest first
+// (minHours < 0 returns every listed plan)
+func (s *planSnapshot) listed(minHours float64) []*core.Record {
+	result := []*core.Record{}
+	for _, plan := range s.plans {
+		if !plan.GetBool("is_active") || plan.GetBool("is_custom") {
+			continue
+		}
+		if minHours >= 0 && plan.GetFloat("hours_per_month") <= minHours {
+			continue
+		}
+		result = append(result, plan)
+	}
+	return result
+}
+
+// planCache is shared by all repositories - they are created per request, plans rarely change
+var planCache struct {
+	mu         sync.RWMutex
+	snapshot   *planSnapshot
+	generation uint64 // bumped on invalidation so an in-flight load doesn't store stale plans
+}
+
+// InvalidatePlanCache drops cached plans so the next read reloads them from the database
+func InvalidatePlanCache() {
+	planCache.mu.Lock()
+	defer planCache.mu.Unlock()
+	planCache.snapshot = nil
+	planCache.generation++
+}
+
+// RegisterPlanCacheHooks invalidates the plan cache whenever a subscription plan is saved or deleted
+func RegisterPlanCacheHooks(app core.App) {
+	invalidate := func(e *core.RecordEvent) error {
+		InvalidatePlanCache()
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("subscription_plans").BindFunc(invalidate)
+	app.OnRecordAfterUpdateSuccess("subscription_plans").BindFunc(invalidate)
+	app.OnRecordAfterDeleteSuccess("subscription_plans").BindFunc(invalidate)
+}
+
+// cachedPlans returns the current plan snapshot, loading it when missing or expired
+// Returns nil if plans can't be loaded - callers fall back to querying directly
+func cachedPlans(app core.App) *planSnapshot {
+	planCache.mu.RLock()
+	snapshot, generation := planCache.snapshot, planCache.generation
+	planCache.mu.RUnlock()
+
+	if snapshot != nil && time.Since(snapshot.loadedAt) < planCacheTTL {
+		return snapshot
+	}
+
+	records, err := app.FindAllRecords("subscription_plans")
+	if err != nil {
+		slog.Error("Failed to load subscription plans into cache", "error", err)
+		return nil
+	}
+	snapshot = newPlanSnapshot(records, time.Now())
+
+	planCache.mu.Lock()
+	if planCache.generation == generation {
+		planCache.snapshot = snapshot
+	}
+	planCache.mu.Unlock()
+
+	return snapshot
+}
+
+// clonePlans copies cached records so callers can't modify the shared snapshot
+func clonePlans(plans []*core.Record) []*core.Record {
+	result := make([]*core.Record, len(plans))
+	for i, plan := range plans {
+		result[i] = plan.Clone()
+	}
+	return result
+}
@@ -0,0 +1,89 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func testPlan(id string, priceCents int, hours float64, interval string, active, custom bool) *core.Record {
+	record := core.NewRecord(core.NewBaseCollection("subscription_plans"))
+	record.Id = id
+	record.Set("price_cents", priceCents)
+	record.Set("hours_per_month", hours)
+	record.Set("billing_interval", interval)
+	record.Set("provider_price_id", "price_"+id)
+	record.Set("is_active", active)
+	record.Set("is_custom", custom)
+	return record
+}
+
+func planIDs(plans []*core.Record) []string {
+	ids := make([]string, len(plans))
+	for i, plan := range plans {
+		ids[i] = plan.Id
+	}
+	return ids
+}
+
+func TestPlanSnapshotLookups(t *testing.T) {
+	snapshot := newPlanSnapshot([]*core.Record{
+		testPlan("pro", 2000, 50, "month", true, false),
+		testPlan("free", 0, 1, "free", true, false),
+		testPlan("enterprise", 50000, 500, "month", true, true),
+		testPlan("legacy", 1000, 20, "month", false, false),
+		testPlan("basic", 1000, 10, "month", true, false),
+	}, time.Now())
+
+	if got := planIDs(snapshot.listed(-1)); len(got) != 3 || got[0] != "free" || got[1] != "basic" || got[2] != "pro" {
+		t.Fatalf("listed plans = %v, want [free basic pro]", got)
+	}
+	if got := planIDs(snapshot.listed(10)); len(got) != 1 || got[0] != "pro" {
+		t.Fatalf("upgrades from 10 hours = %v, want [pro]", got)
+	}
+	if plan := snapshot.freePlan(); plan == nil || plan.Id != "free" {
+		t.Fatalf("free plan = %v", plan)
+	}
+	// Custom and inactive plans are still found by ID and price for existing subscribers
+	if plan := snapshot.plan("enterprise"); plan == nil {
+		t.Fatal("expected custom plan by ID")
+	}
+	if plan := snapshot.planByProviderPrice("price_legacy"); plan == nil || plan.Id != "legacy" {
+		t.Fatalf("plan by price = %v", plan)
+	}
+	if snapshot.plan("missing") != nil || snapshot.planByProviderPrice("price_missing") != nil {
+		t.Fatal("expected no plan for unknown keys")
+	}
+}
+
+func TestPlanSnapshotByProduct(t *testing.T) {
+	monthly := testPlan("pro", 1500, 25, "month", true, false)
+	yearly := testPlan("pro_yearly", 15000, 25, "year", true, false)
+	retired := testPlan("pro_yearly_old", 12000, 25, "year", false, false)
+	for _, plan := range []*core.Record{monthly, yearly, retired} {
+		plan.Set("provider_product_id", "prod_pro")
+	}
+	snapshot := newPlanSnapshot([]*core.Record{retired, monthly, yearly}, time.Now())
+
+	if plan := snapshot.planByProduct("prod_pro", "year"); plan == nil || plan.Id != "pro_yearly" {
+		t.Fatalf("yearly plan by product = %v, want pro_yearly", plan)
+	}
+	if plan := snapshot.planByProduct("prod_pro", "month"); plan == nil || plan.Id != "pro" {
+		t.Fatalf("monthly plan by product = %v, want pro", plan)
+	}
+	if snapshot.planByProduct("prod_basic", "year") != nil {
+		t.Fatal("expected no plan for unknown keys")
+	}
+}
+
+func TestClonePlansDoesNotShareRecords(t *testing.T) {
+	snapshot := newPlanSnapshot([]*core.Record{testPlan("pro", 2000, 50, "month", true, false)}, time.Now())
+
+	clones := clonePlans(snapshot.listed(-1))
+	clones[0].Set("hours_per_month", 1)
+
+	if hours := snapshot.plan("pro").GetFloat("hours_per_month"); hours != 50 {
+		t.Fatalf("cached plan modified through clone: %v hours", hours)
+	}
+}
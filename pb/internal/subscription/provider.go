@@ -0,0 +1,200 @@
+package subscription
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stripe/stripe-go/v79"
+)
+
+// ErrNoPaymentProvider is returned by operations that need the payment provider when the
+// service was built without one
+var ErrNoPaymentProvider = errors.New("payment provider is not configured")
+
+// PaymentProvider is what the service needs from the payment provider to manage subscriptions.
+// payment.Service.SubscriptionProvider adapts the configured provider (Stripe, Paddle, ...)
+type PaymentProvider interface {
+	// CancelSubscription cancels a subscription immediately, crediting the unused time
+	CancelSubscription(subID string) error
+	// ChangeSubscriptionPrice moves a subscription to another price straight away and invoices
+	// the prorations. Returns the total of the proration invoice, or nil if there wasn't one
+	ChangeSubscriptionPrice(subID string, priceID string) (*int64, error)
+	GetSubscription(subID string) (*ProviderSubscription, error)
+	// ExtendSubscription pushes the next renewal out to until without charging for the extra time
+	ExtendSubscription(subID string, until time.Time) (*ProviderSubscription, error)
+	// PauseSubscription stops collecting payment until resumesAt (indefinitely when zero)
+	PauseSubscription(subID string, resumesAt time.Time) (*ProviderSubscription, error)
+	// ResumeSubscription collects payment again from the next invoice
+	ResumeSubscription(subID string) (*ProviderSubscription, error)
+}
+
+// ProviderSubscription is a subscription as the payment provider has it
+type ProviderSubscription struct {
+	ID                 string
+	CustomerID         string
+	Status             string // provider status: active, trialing, past_due, canceled, ...
+	PriceID            string
+	ProductID          string
+	Interval           string // billing interval of the price, "month" or "year"
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CanceledAt         *time.Time
+	TrialEnd           *time.Time
+	Paused             bool       // payment collection is paused
+	ResumesAt          *time.Time // when a paused subscription resumes by itself, if set
+	Metadata           map[string]string
+}
+
+// subscriptionFromStripe converts a Stripe subscription delivered by a webhook
+func subscriptionFromStripe(stripeSub *stripe.Subscription) *ProviderSubscription {
+	sub := &ProviderSubscription{
+		ID:                 stripeSub.ID,
+		Status:             string(stripeSub.Status),
+		CurrentPeriodStart: time.Unix(stripeSub.CurrentPeriodStart, 0),
+		CurrentPeriodEnd:   time.Unix(stripeSub.CurrentPeriodEnd, 0),
+		Metadata:           stripeSub.Metadata,
+	}
+	if stripeSub.Customer != nil {
+		sub.CustomerID = stripeSub.Customer.ID
+	}
+	if stripeSub.CanceledAt > 0 {
+		canceledAt := time.Unix(stripeSub.CanceledAt, 0)
+		sub.CanceledAt = &canceledAt
+	}
+	if stripeSub.TrialEnd > 0 {
+		trialEnd := time.Unix(stripeSub.TrialEnd, 0)
+		sub.TrialEnd = &trialEnd
+	}
+	if stripeSub.PauseCollection != nil {
+		sub.Paused = true
+		if stripeSub.PauseCollection.ResumesAt > 0 {
+			resumesAt := time.Unix(stripeSub.PauseCollection.ResumesAt, 0)
+			sub.ResumesAt = &resumesAt
+		}
+	}
+	if stripeSub.Items != nil && len(stripeSub.Items.Data) > 0 && stripeSub.Items.Data[0].Price != nil {
+		price := stripeSub.Items.Data[0].Price
+		sub.PriceID = price.ID
+		if price.Product != nil {
+			sub.ProductID = price.Product.ID
+		}
+		if price.Recurring != nil {
+			sub.Interval = string(price.Recurring.Interval)
+		}
+	}
+	return sub
+}
+
+// MockPaymentProvider implements PaymentProvider for testing
+type MockPaymentProvider struct {
+	// Track method calls for test assertions
+	CancelCalls []string
+	UpdateCalls []MockUpdateCall
+	GetCalls    []string
+	ExtendCalls []string
+	PauseCalls  []string
+	ResumeCalls []string
+	// Control return values
+	CancelError     error
+	UpdateError     error
+	UpdateProration *int64
+	GetError        error
+	GetResult       *ProviderSubscription
+	ExtendError     error
+	PauseError      error
+	ResumeError     error
+}
+
+// MockUpdateCall represents a call to ChangeSubscriptionPrice for testing
+type MockUpdateCall struct {
+	SubID   string
+	PriceID string
+}
+
+// NewMockPaymentProvider creates a new mock payment provider for testing
+func NewMockPaymentProvider() *MockPaymentProvider {
+	return &MockPaymentProvider{
+		UpdateCalls: []MockUpdateCall{},
+		GetCalls:    []string{},
+	}
+}
+
+// CancelSubscription mocks cancelling a subscription
+func (m *MockPaymentProvider) CancelSubscription(subID string) error {
+	m.CancelCalls = append(m.CancelCalls, subID)
+	return m.CancelError
+}
+
+// ChangeSubscriptionPrice mocks moving a subscription to another price
+func (m *MockPaymentProvider) ChangeSubscriptionPrice(subID string, priceID string) (*int64, error) {
+	// Record the call
+	m.UpdateCalls = append(m.UpdateCalls, MockUpdateCall{
+		SubID:   subID,
+		PriceID: priceID,
+	})
+
+	// Return configured error if any
+	if m.UpdateError != nil {
+		return nil, m.UpdateError
+	}
+	return m.UpdateProration, nil
+}
+
+// GetSubscription mocks retrieving a subscription
+func (m *MockPaymentProvider) GetSubscription(subID string) (*ProviderSubscription, error) {
+	// Record the call
+	m.GetCalls = append(m.GetCalls, subID)
+
+	// Return configured error if any
+	if m.GetError != nil {
+		return nil, m.GetError
+	}
+
+	// Return configured result or a default mock
+	if m.GetResult != nil {
+		return m.GetResult, nil
+	}
+
+	// Default mock subscription
+	return &ProviderSubscription{
+		ID:               subID,
+		Status:           string(StatusActive),
+		CurrentPeriodEnd: time.Unix(1725091200, 0), // Mock timestamp
+	}, nil
+}
+
+// ExtendSubscription mocks extending a subscription's current period
+func (m *MockPaymentProvider) ExtendSubscription(subID string, until time.Time) (*ProviderSubscription, error) {
+	m.ExtendCalls = append(m.ExtendCalls, subID)
+	if m.ExtendError != nil {
+		return nil, m.ExtendError
+	}
+	return &ProviderSubscription{
+		ID:                 subID,
+		Status:             string(StatusTrialing),
+		CurrentPeriodStart: time.Now(),
+		CurrentPeriodEnd:   until,
+	}, nil
+}
+
+// PauseSubscription mocks pausing payment collection
+func (m *MockPaymentProvider) PauseSubscription(subID string, resumesAt time.Time) (*ProviderSubscription, error) {
+	m.PauseCalls = append(m.PauseCalls, subID)
+	if m.PauseError != nil {
+		return nil, m.PauseError
+	}
+	sub := &ProviderSubscription{ID: subID, Status: string(StatusActive), Paused: true}
+	if !resumesAt.IsZero() {
+		sub.ResumesAt = &resumesAt
+	}
+	return sub, nil
+}
+
+// ResumeSubscription mocks resuming payment collection
+func (m *MockPaymentProvider) ResumeSubscription(subID string) (*ProviderSubscription, error) {
+	m.ResumeCalls = append(m.ResumeCalls, subID)
+	if m.ResumeError != nil {
+		return nil, m.ResumeError
+	}
+	return &ProviderSubscription{ID: subID, Status: string(StatusActive)}, nil
+}
@@ -2,7 +2,7 @@ package subscription
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
@@ -26,16 +26,20 @@ type Repository interface {
 	// Plan operations
 	GetPlan(planID string) (*core.Record, error)
 	GetPlanByProviderPrice(providerPriceID string) (*core.Record, error)
+	GetPlanByProviderProduct(providerProductID string, interval string) (*core.Record, error)
 	GetFreePlan() (*core.Record, error)
 	GetAllPlans() ([]*core.Record, error)
 	GetAvailableUpgrades(currentPlanID string) ([]*core.Record, error)
 
+	// Usage operations
+	GetPeriodHoursUsed(userID string, now time.Time) (float64, UsagePeriod, error)
+
 	// Bulk operations
 	DeactivateAllUserSubscriptions(userID string) error
 	CleanupDuplicateSubscriptions(userID string) error
 	
 	// Subscription history operations
-	MoveSubscriptionToHistory(subscriptionRecord *core.Record, reason string) (*core.Record, error)
+	MoveSubscriptionToHistory(subscriptionRecord *core.Record, params HistoryParams) (*core.Record, error)
 	GetUserSubscriptionHistory(userID string) ([]*core.Record, error)
 }
 
@@ -62,6 +66,9 @@ func (r *PocketBaseRepository) CreateSubscription(params CreateSubscriptionParam
 	record.Set("status", string(params.Status))
 	record.Set("current_period_start", params.CurrentPeriodStart)
 	record.Set("current_period_end", params.CurrentPeriodEnd)
+	if params.Status == StatusPastDue {
+		record.Set("past_due_since", time.Now())
+	}
 
 	if params.ProviderSubscriptionID != nil {
 		record.Set("provider_subscription_id", *params.ProviderSubscriptionID)
@@ -75,6 +82,9 @@ func (r *PocketBaseRepository) CreateSubscription(params CreateSubscriptionParam
 	if params.CanceledAt != nil {
 		record.Set("canceled_at", *params.CanceledAt)
 	}
+	if params.TrialEnd != nil {
+		record.Set("trial_end", *params.TrialEnd)
+	}
 
 	if err := r.app.Save(record); err != nil {
 		return nil, fmt.Errorf("failed to create subscription: %w", err)
@@ -104,6 +114,15 @@ func (r *PocketBaseRepository) UpdateSubscription(subscriptionID string, params
 	}
 	if params.Status != nil {
 		record.Set("status", string(*params.Status))
+
+		// Track when the subscription first went past_due - the payment grace window starts there
+		if *params.Status == StatusPastDue {
+			if record.GetDateTime("past_due_since").IsZero() {
+				record.Set("past_due_since", time.Now())
+			}
+		} else {
+			record.Set("past_due_since", "")
+		}
 	}
 	if params.CurrentPeriodStart != nil {
 		record.Set("current_period_start", *params.CurrentPeriodStart)
@@ -114,6 +133,40 @@ func (r *PocketBaseRepository) UpdateSubscription(subscriptionID string, params
 	if params.CanceledAt != nil {
 		record.Set("canceled_at", *params.CanceledAt)
 	}
+	if params.TrialEnd != nil {
+		if params.TrialEnd.IsZero() {
+			record.Set("trial_end", "")
+		} else {
+			record.Set("trial_end", *params.TrialEnd)
+		}
+	}
+	if params.OverridePlanID != nil {
+		record.Set("override_plan_id", *params.OverridePlanID)
+	}
+	if params.OverrideExpiresAt != nil {
+		if params.OverrideExpiresAt.IsZero() {
+			record.Set("override_expires_at", "")
+		} else {
+			record.Set("override_expires_at", *params.OverrideExpiresAt)
+		}
+	}
+	if params.OverrideReason != nil {
+		record.Set("override_reason", *params.OverrideReason)
+	}
+	if params.PausedAt != nil {
+		if params.PausedAt.IsZero() {
+			record.Set("paused_at", "")
+		} else {
+			record.Set("paused_at", *params.PausedAt)
+		}
+	}
+	if params.ResumesAt != nil {
+		if params.ResumesAt.IsZero() {
+			record.Set("resumes_at", "")
+		} else {
+			record.Set("resumes_at", *params.ResumesAt)
+		}
+	}
 
 	if err := r.app.Save(record); err != nil {
 		return nil, fmt.Errorf("failed to update subscription %s: %w", subscriptionID, err)
@@ -215,6 +268,12 @@ func (r *PocketBaseRepository) FindActiveSubscriptionsCount(userID string) (int,
 
 // GetPlan retrieves a subscription plan by ID
 func (r *PocketBaseRepository) GetPlan(planID string) (*core.Record, error) {
+	if plans := cachedPlans(r.app); plans != nil {
+		if plan := plans.plan(planID); plan != nil {
+			return plan.Clone(), nil
+		}
+	}
+
 	record, err := r.app.FindRecordById("subscription_plans", planID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get plan %s: %w", planID, err)
@@ -224,6 +283,12 @@ func (r *PocketBaseRepository) GetPlan(planID string) (*core.Record, error) {
 
 // GetPlanByProviderPrice retrieves a plan by Stripe price ID
 func (r *PocketBaseRepository) GetPlanByProviderPrice(stripePriceID string) (*core.Record, error) {
+	if plans := cachedPlans(r.app); plans != nil {
+		if plan := plans.planByProviderPrice(stripePriceID); plan != nil {
+			return plan.Clone(), nil
+		}
+	}
+
 	record, err := r.app.FindFirstRecordByFilter("subscription_plans", "provider_price_id = {:price_id}", map[string]any{
 		"price_id": stripePriceID,
 	})
@@ -233,8 +298,33 @@ func (r *PocketBaseRepository) GetPlanByProviderPrice(stripePriceID string) (*co
 	return record, nil
 }
 
+// GetPlanByProviderProduct retrieves the active plan for a Stripe product billed every interval
+func (r *PocketBaseRepository) GetPlanByProviderProduct(stripeProductID string, interval string) (*core.Record, error) {
+	if plans := cachedPlans(r.app); plans != nil {
+		if plan := plans.planByProduct(stripeProductID, interval); plan != nil {
+			return plan.Clone(), nil
+		}
+	}
+
+	record, err := r.app.FindFirstRecordByFilter("subscription_plans",
+		"is_active = true && provider_product_id = {:product_id} && billing_interval = {:interval}", map[string]any{
+			"product_id": stripeProductID,
+			"interval":   interval,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %sly plan for product %s: %w", interval, stripeProductID, err)
+	}
+	return record, nil
+}
+
 // GetFreePlan retrieves the free plan
 func (r *PocketBaseRepository) GetFreePlan() (*core.Record, error) {
+	if plans := cachedPlans(r.app); plans != nil {
+		if plan := plans.freePlan(); plan != nil {
+			return plan.Clone(), nil
+		}
+	}
+
 	record, err := r.app.FindFirstRecordByFilter("subscription_plans", "billing_interval = 'free'", map[string]any{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to find free plan: %w", err)
@@ -242,9 +332,14 @@ func (r *PocketBaseRepository) GetFreePlan() (*core.Record, error) {
 	return record, nil
 }
 
-// GetAllPlans retrieves all available subscription plans ordered by price (cheapest to most expensive)
+// GetAllPlans retrieves all publicly listed subscription plans ordered by price (cheapest to most expensive)
+// Custom enterprise plans are excluded - they are only visible to the user they are assigned to
 func (r *PocketBaseRepository) GetAllPlans() ([]*core.Record, error) {
-	records, err := r.app.FindRecordsByFilter("subscription_plans", "is_active = true", "+price_cents", 0, 0)
+	if plans := cachedPlans(r.app); plans != nil {
+		return clonePlans(plans.listed(-1)), nil
+	}
+
+	records, err := r.app.FindRecordsByFilter("subscription_plans", "is_active = true && is_custom != true", "+price_cents", 0, 0)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all plans: %w", err)
 	}
@@ -260,7 +355,11 @@ func (r *PocketBaseRepository) GetAvailableUpgrades(currentPlanID string) ([]*co
 
 	currentHoursLimit := currentPlan.GetFloat("hours_per_month")
 
-	records, err := r.app.FindRecordsByFilter("subscription_plans", "is_active = true && hours_per_month > {:current_hours}", "+price_cents", 0, 0, map[string]any{
+	if plans := cachedPlans(r.app); plans != nil {
+		return clonePlans(plans.listed(currentHoursLimit)), nil
+	}
+
+	records, err := r.app.FindRecordsByFilter("subscription_plans", "is_active = true && is_custom != true && hours_per_month > {:current_hours}", "+price_cents", 0, 0, map[string]any{
 		"current_hours": currentHoursLimit,
 	})
 	if err != nil {
@@ -269,6 +368,20 @@ func (r *PocketBaseRepository) GetAvailableUpgrades(currentPlanID string) ([]*co
 	return records, nil
 }
 
+// GetPeriodHoursUsed returns the hours a user has processed in their current usage period, 0 if
+// none. Hours charged to an organization's pool aren't included
+func (r *PocketBaseRepository) GetPeriodHoursUsed(userID string, now time.Time) (float64, UsagePeriod, error) {
+	period := CurrentUsagePeriod(r.app, userID, now)
+	record, err := FindUsageRecord(r.app, userID, "", period, now)
+	if err != nil {
+		return 0, period, fmt.Errorf("failed to get monthly usage: %w", err)
+	}
+	if record == nil {
+		return 0, period, nil
+	}
+	return record.GetFloat("hours_used"), period, nil
+}
+
 // DeactivateAllUserSubscriptions marks all user subscriptions as cancelled
 func (r *PocketBaseRepository) DeactivateAllUserSubscriptions(userID string) error {
 	subscriptions, err := r.app.FindRecordsByFilter("current_user_subscriptions", "user_id = {:user_id} && status = 'active'", "-created", 100, 0, map[string]any{
@@ -282,11 +395,11 @@ func (r *PocketBaseRepository) DeactivateAllUserSubscriptions(userID string) err
 		sub.Set("status", "cancelled")
 		sub.Set("canceled_at", time.Now())
 		if err := r.app.Save(sub); err != nil {
-			log.Printf("Failed to deactivate subscription %s: %v", sub.Id, err)
+			slog.Error("Failed to deactivate subscription", "subscription_id", sub.Id, "error", err)
 		}
 	}
 
-	log.Printf("Deactivated %d subscriptions for user %s", len(subscriptions), userID)
+	slog.Info("Deactivated subscriptions", "count", len(subscriptions), "user_id", userID)
 	return nil
 }
 
@@ -309,16 +422,16 @@ func (r *PocketBaseRepository) CleanupDuplicateSubscriptions(userID string) erro
 		sub.Set("status", "cancelled")
 		sub.Set("canceled_at", time.Now())
 		if err := r.app.Save(sub); err != nil {
-			log.Printf("Failed to deactivate duplicate subscription %s: %v", sub.Id, err)
+			slog.Error("Failed to deactivate duplicate subscription", "subscription_id", sub.Id, "error", err)
 		}
 	}
 
-	log.Printf("Cleaned up %d duplicate subscriptions for user %s", len(activeSubscriptions)-1, userID)
+	slog.Info("Cleaned up duplicate subscriptions", "count", len(activeSubscriptions)-1, "user_id", userID)
 	return nil
 }
 
 // MoveSubscriptionToHistory moves a current subscription to the history table
-func (r *PocketBaseRepository) MoveSubscriptionToHistory(subscriptionRecord *core.Record, reason string) (*core.Record, error) {
+func (r *PocketBaseRepository) MoveSubscriptionToHistory(subscriptionRecord *core.Record, params HistoryParams) (*core.Record, error) {
 	// Get subscription history collection
 	historyCollection, err := r.app.FindCollectionByNameOrId("subscription_history")
 	if err != nil {
@@ -338,17 +451,23 @@ func (r *PocketBaseRepository) MoveSubscriptionToHistory(subscriptionRecord *cor
 	historyRecord.Set("current_period_start", subscriptionRecord.Get("current_period_start"))
 	historyRecord.Set("current_period_end", subscriptionRecord.Get("current_period_end"))
 	historyRecord.Set("canceled_at", subscriptionRecord.Get("canceled_at"))
+	historyRecord.Set("trial_end", subscriptionRecord.Get("trial_end"))
 	
 	// Set history-specific fields
 	historyRecord.Set("replaced_at", time.Now())
-	historyRecord.Set("replacement_reason", reason)
+	historyRecord.Set("replacement_reason", params.Reason)
+	historyRecord.Set("initiated_by", string(params.InitiatedBy))
+	historyRecord.Set("new_plan_id", params.NewPlanID)
+	if params.ProrationAmountCents != nil {
+		historyRecord.Set("proration_amount_cents", *params.ProrationAmountCents)
+	}
 	
 	// Save to history
 	if err := r.app.Save(historyRecord); err != nil {
 		return nil, fmt.Errorf("failed to save subscription to history: %w", err)
 	}
 	
-	log.Printf("Moved subscription %s to history with reason: %s", subscriptionRecord.Id, reason)
+	slog.Info("Subscription moved to history", "subscription_id", subscriptionRecord.Id, "reason", params.Reason, "initiated_by", params.InitiatedBy)
 	return historyRecord, nil
 }
 
@@ -0,0 +1,46 @@
+package subscription
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/logging"
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the subscription management routes. Reads go through the PocketBase SDK and RLS
+func RegisterRoutes(r *routes.Registry, subscriptionService Service) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/payment/change-plan", Handler: func(e *core.RequestEvent) error {
+			return ChangePlanHandler(e, app, subscriptionService.WithLogger(logging.FromRequest(e)))
+		}, Doc: &routes.Doc{Summary: "Change to another plan", Response: ChangePlanResult{}}},
+		// Switch the current plan between monthly and yearly billing
+		routes.Route{Method: http.MethodPost, Path: "/api/payment/change-interval", Handler: func(e *core.RequestEvent) error {
+			return ChangeIntervalHandler(e, app, subscriptionService.WithLogger(logging.FromRequest(e)))
+		}, Doc: &routes.Doc{Summary: "Switch between monthly and yearly billing", Response: ChangePlanResult{}}},
+		// Plan change log so users can check billing changes themselves
+		routes.Route{Method: http.MethodGet, Path: "/api/subscription/changes", Handler: func(e *core.RequestEvent) error {
+			return PlanChangesHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List plan changes"}},
+		// Past plans with the dates they applied, paginated for support links
+		routes.Route{Method: http.MethodGet, Path: "/api/subscription/history", Handler: func(e *core.RequestEvent) error {
+			return SubscriptionHistoryHandler(e, app)
+		}, Doc: &routes.Doc{Summary: "List past plans and the dates they applied"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/subscription/cancel", Handler: func(e *core.RequestEvent) error {
+			return CancelSubscriptionHandler(e, app, subscriptionService.WithLogger(logging.FromRequest(e)))
+		}, Doc: &routes.Doc{Summary: "Cancel at the end of the billing period", Response: CancelSubscriptionResult{}}},
+		// Pause billing and quota for a while, or resume early
+		routes.Route{Method: http.MethodPost, Path: "/api/subscription/pause", Handler: func(e *core.RequestEvent) error {
+			return PauseSubscriptionHandler(e, app, subscriptionService.WithLogger(logging.FromRequest(e)))
+		}, Doc: &routes.Doc{Summary: "Pause billing and quota until a resume date"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/subscription/resume", Handler: func(e *core.RequestEvent) error {
+			return ResumeSubscriptionHandler(e, app, subscriptionService.WithLogger(logging.FromRequest(e)))
+		}, Doc: &routes.Doc{Summary: "Resume a paused subscription"}},
+		routes.Route{Method: http.MethodPost, Path: "/api/subscription/switch-to-free", Handler: func(e *core.RequestEvent) error {
+			return SwitchToFreePlanHandler(e, app, subscriptionService.WithLogger(logging.FromRequest(e)))
+		}, Doc: &routes.Doc{Summary: "Switch to the free plan"}},
+	)
+}
@@ -0,0 +1,71 @@
+package subscription
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the subscription service reads and writes
+func SchemaRequirements() schemacheck.Module {
+	subscriptionFields := []schemacheck.Field{
+		{Name: "user_id", Type: "relation"},
+		{Name: "plan_id", Type: "relation"},
+		{Name: "provider_subscription_id", Type: "text"},
+		{Name: "provider_price_id", Type: "text"},
+		{Name: "payment_provider", Type: "select", Values: []string{"stripe", "invoice", "sandbox"}},
+		{Name: "status", Type: "select", Values: []string{"active", "cancelled", "past_due", "trialing"}},
+		{Name: "current_period_start", Type: "date"},
+		{Name: "current_period_end", Type: "date"},
+		{Name: "canceled_at", Type: "date"},
+		{Name: "trial_end", Type: "date"},
+	}
+
+	historyFields := append([]schemacheck.Field{
+		{Name: "replaced_at", Type: "date"},
+		{Name: "replacement_reason", Type: "select", Values: []string{
+			"plan_change", "upgrade", "downgrade", "switched_to_free_plan", "subscription_cancelled", "replaced_by_new_subscription",
+			"interval_change",
+		}},
+		{Name: "initiated_by", Type: "select", Values: []string{"user", "webhook", "admin", "system"}},
+		{Name: "new_plan_id", Type: "relation"},
+		{Name: "proration_amount_cents", Type: "number"},
+	}, subscriptionFields...)
+
+	return schemacheck.Module{
+		Name: "subscription",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "subscription_plans",
+				Fields: []schemacheck.Field{
+					{Name: "name", Type: "text"},
+					{Name: "price_cents", Type: "number"},
+					{Name: "currency", Type: "text"},
+					{Name: "billing_interval", Type: "select", Values: []string{"free", "month", "year"}},
+					{Name: "hours_per_month", Type: "number"},
+					{Name: "provider_price_id", Type: "text"},
+					{Name: "provider_product_id", Type: "text"},
+					{Name: "is_active", Type: "bool"},
+					{Name: "is_custom", Type: "bool"},
+					{Name: "assigned_user_id", Type: "relation"},
+					{Name: "billing_method", Type: "select", Values: []string{"stripe", "invoice"}},
+					{Name: "paired_plan_id", Type: "relation"},
+				},
+			},
+			{
+				Collection: "current_user_subscriptions",
+				Fields: append([]schemacheck.Field{
+					{Name: "past_due_since", Type: "date"},
+					{Name: "override_plan_id", Type: "relation"},
+					{Name: "override_expires_at", Type: "date"},
+					{Name: "override_reason", Type: "text"},
+					{Name: "paused_at", Type: "date"},
+					{Name: "resumes_at", Type: "date"},
+				}, subscriptionFields...),
+				Indexes: []schemacheck.Index{
+					{Name: "idx_current_user_subscriptions_user_id", Unique: true, Columns: "user_id"},
+				},
+			},
+			{
+				Collection: "subscription_history",
+				Fields:     historyFields,
+			},
+		},
+	}
+}
@@ -2,12 +2,11 @@ package subscription
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/stripe/stripe-go/v79"
-	"github.com/stripe/stripe-go/v79/subscription"
 )
 
 // CancelSubscriptionResult represents the result of a subscription cancellation
@@ -27,6 +26,7 @@ type Service interface {
 	GetSubscription(subscriptionID string) (*core.Record, error)
 	CancelSubscription(userID string) (*CancelSubscriptionResult, error)
 	SwitchToFreePlan(userID string) (*core.Record, error)
+	AssignPlan(userID string, planID string, periodEnd time.Time, paymentProvider string) (*core.Record, error)
 
 	// Query operations
 	GetUserSubscriptionInfo(userID string) (*SubscriptionInfo, error)
@@ -42,38 +42,65 @@ type Service interface {
 
 	// Plan management
 	ChangePlan(userID string, newPlanID string) (*ChangePlanResult, error)
+	ChangeInterval(userID string, interval string) (*ChangePlanResult, error)
 	CreateFreePlanSubscription(userID string) error
 
+	// Support operations
+	ResyncFromProvider(userID string) (*core.Record, error)
+	ExtendPeriod(userID string, until time.Time) (*core.Record, error)
+	SetPlanOverride(userID string, override PlanOverride) (*core.Record, error)
+
+	// Pausing
+	PauseSubscription(userID string, resumesAt time.Time) (*core.Record, error)
+	ResumeSubscription(userID string) (*core.Record, error)
+
 	// Utility operations
 	CleanupDuplicateSubscriptions(userID string) error
 	ValidateAndFixSubscriptionTimestamps(subscription *core.Record) (*core.Record, error)
+
+	// WithLogger returns a copy of the service that logs through logger, so log lines carry the
+	// caller's request or webhook attributes
+	WithLogger(logger *slog.Logger) Service
 }
 
 // SubscriptionService implements the Service interface
 type SubscriptionService struct {
 	repo      Repository
 	validator *Validator
-	stripe    StripeService
+	provider  PaymentProvider
+	logger    *slog.Logger
 }
 
-// NewService creates a new subscription service with real Stripe integration
+// NewService creates a new subscription service without a payment provider. It can read and sync
+// subscriptions; cancelling, changing plans and support actions return ErrNoPaymentProvider
 func NewService(repo Repository) Service {
+	return NewServiceWithProvider(repo, nil)
+}
+
+// NewServiceWithProvider creates a new subscription service that manages subscriptions through
+// the payment provider
+func NewServiceWithProvider(repo Repository, provider PaymentProvider) Service {
 	validator := NewValidator(repo)
 	return &SubscriptionService{
 		repo:      repo,
 		validator: validator,
-		stripe:    NewRealStripeService(),
+		provider:  provider,
 	}
 }
 
-// NewServiceWithStripe creates a new subscription service with custom Stripe service (for testing)
-func NewServiceWithStripe(repo Repository, stripeService StripeService) Service {
-	validator := NewValidator(repo)
-	return &SubscriptionService{
-		repo:      repo,
-		validator: validator,
-		stripe:    stripeService,
+// WithLogger returns a shallow copy of the service logging through logger
+func (s *SubscriptionService) WithLogger(logger *slog.Logger) Service {
+	clone := *s
+	clone.logger = logger
+	return &clone
+}
+
+// log returns the service logger, or the default one when none was set
+func (s *SubscriptionService) log() *slog.Logger {
+	if s.logger != nil {
+		return s.logger
 	}
+	return slog.Default()
 }
 
 // CreateSubscription creates a new subscription with validation
@@ -85,10 +112,10 @@ func (s *SubscriptionService) CreateSubscription(params CreateSubscriptionParams
 
 	// Check business rules
 	if businessErrors := s.validator.ValidateBusinessRules(params.UserID, "create_active"); len(businessErrors) > 0 {
-		log.Printf("Business rule violation: %s", businessErrors[0].Message)
+		s.log().Warn("Business rule violation", "rule", businessErrors[0].Message)
 		// Clean up existing active subscriptions before creating new one
 		if err := s.repo.DeactivateAllUserSubscriptions(params.UserID); err != nil {
-			log.Printf("Failed to deactivate existing subscriptions: %v", err)
+			s.log().Error("Failed to deactivate existing subscriptions", "user_id", params.UserID, "error", err)
 		}
 	}
 
@@ -101,7 +128,7 @@ func (s *SubscriptionService) CreateSubscription(params CreateSubscriptionParams
 		return nil, fmt.Errorf("failed to create subscription: %w", err)
 	}
 
-	log.Printf("Created subscription %s for user %s", subscription.Id, params.UserID)
+	s.log().Info("Subscription created", "subscription_id", subscription.Id, "user_id", params.UserID)
 	return subscription, nil
 }
 
@@ -125,7 +152,7 @@ func (s *SubscriptionService) UpdateSubscription(subscriptionID string, params U
 		return nil, fmt.Errorf("failed to update subscription: %w", err)
 	}
 
-	log.Printf("Updated subscription %s", subscriptionID)
+	s.log().Info("Subscription updated", "subscription_id", subscriptionID)
 	return subscription, nil
 }
 
@@ -134,39 +161,39 @@ func (s *SubscriptionService) GetSubscription(subscriptionID string) (*core.Reco
 	return s.repo.GetSubscription(subscriptionID)
 }
 
-// CancelSubscription cancels a user's active subscription
 // CancelSubscription immediately cancels a user's active subscription
-// User is moved to free plan with prorated refunds handled by Stripe
+// User is moved to free plan with prorated refunds handled by the payment provider
 func (s *SubscriptionService) CancelSubscription(userID string) (*CancelSubscriptionResult, error) {
+	if s.provider == nil {
+		return nil, ErrNoPaymentProvider
+	}
+
 	// Find user's active subscription
 	activeSubscription, err := s.repo.FindActiveSubscription(userID)
 	if err != nil {
 		return nil, fmt.Errorf("no active subscription found for user %s: %w", userID, err)
 	}
 
-	// Get Stripe subscription ID
-	stripeSubID := activeSubscription.GetString("provider_subscription_id")
-	if stripeSubID == "" {
-		return nil, fmt.Errorf("subscription %s has no Stripe subscription ID", activeSubscription.Id)
+	// Get provider subscription ID
+	providerSubID := activeSubscription.GetString("provider_subscription_id")
+	if providerSubID == "" {
+		return nil, fmt.Errorf("subscription %s has no provider subscription ID", activeSubscription.Id)
 	}
 
-	log.Printf("Cancelling Stripe subscription %s for user %s immediately", stripeSubID, userID)
+	s.log().Info("Cancelling provider subscription immediately", "provider_subscription_id", providerSubID, "user_id", userID)
 
-	// Cancel subscription immediately in Stripe - Stripe handles prorated refunds
-	_, err = subscription.Cancel(stripeSubID, &stripe.SubscriptionCancelParams{
-		Prorate: stripe.Bool(true), // Ensure user gets prorated refund
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to cancel Stripe subscription: %w", err)
+	// Cancel immediately - the provider credits the unused time
+	if err := s.provider.CancelSubscription(providerSubID); err != nil {
+		return nil, fmt.Errorf("failed to cancel provider subscription: %w", err)
 	}
 
 	// Immediately switch user to free plan
-	_, err = s.SwitchToFreePlan(userID)
+	_, err = s.switchToFreePlan(userID, InitiatedByUser)
 	if err != nil {
 		return nil, fmt.Errorf("failed to switch user to free plan: %w", err)
 	}
 
-	log.Printf("Successfully cancelled subscription for user %s - switched to free plan with prorated refund", userID)
+	s.log().Info("Subscription cancelled, switched to free plan with prorated refund", "user_id", userID)
 
 	return &CancelSubscriptionResult{
 		Success:               true,
@@ -179,31 +206,40 @@ func (s *SubscriptionService) CancelSubscription(userID string) (*CancelSubscrip
 
 // SwitchToFreePlan moves a user to the free plan
 func (s *SubscriptionService) SwitchToFreePlan(userID string) (*core.Record, error) {
+	return s.switchToFreePlan(userID, InitiatedBySystem)
+}
+
+// switchToFreePlan moves a user to the free plan, recording who asked for it in their history
+func (s *SubscriptionService) switchToFreePlan(userID string, initiatedBy ChangeInitiator) (*core.Record, error) {
+	// Get the free plan
+	freePlan, err := s.repo.GetFreePlan()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get free plan: %w", err)
+	}
+
 	// Move any existing active subscriptions to history first
 	existingSubscriptions, err := s.repo.FindAllUserSubscriptions(userID)
 	if err != nil {
-		log.Printf("Warning: Failed to find existing subscriptions: %v", err)
+		s.log().Warn("Failed to find existing subscriptions", "user_id", userID, "error", err)
 	} else {
 		for _, existingSub := range existingSubscriptions {
-			if existingSub.GetString("status") == "active" {
-				_, err := s.repo.MoveSubscriptionToHistory(existingSub, "switched_to_free_plan")
+			if replaceableStatus(existingSub.GetString("status")) {
+				_, err := s.repo.MoveSubscriptionToHistory(existingSub, HistoryParams{
+					Reason:      "switched_to_free_plan",
+					InitiatedBy: initiatedBy,
+					NewPlanID:   freePlan.Id,
+				})
 				if err != nil {
-					log.Printf("Warning: Failed to move subscription %s to history: %v", existingSub.Id, err)
+					s.log().Warn("Failed to move subscription to history", "subscription_id", existingSub.Id, "error", err)
 				}
 				// Delete the current subscription after moving to history
 				if err := s.repo.DeleteSubscription(existingSub.Id); err != nil {
-					log.Printf("Warning: Failed to delete subscription during free plan switch: %v", err)
+					s.log().Warn("Failed to delete subscription during free plan switch", "subscription_id", existingSub.Id, "error", err)
 				}
 			}
 		}
 	}
 
-	// Get the free plan
-	freePlan, err := s.repo.GetFreePlan()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get free plan: %w", err)
-	}
-
 	// Create a new subscription record for the free plan
 	now := time.Now()
 	paymentProvider := "stripe"
@@ -223,7 +259,60 @@ func (s *SubscriptionService) SwitchToFreePlan(userID string) (*core.Record, err
 		return nil, fmt.Errorf("failed to create free plan subscription: %w", err)
 	}
 
-	log.Printf("User %s switched to free plan", userID)
+	s.log().Info("Switched to free plan", "user_id", userID)
+	return record, nil
+}
+
+// AssignPlan activates a plan for a user without going through a payment provider checkout
+// Used for invoice-billed custom plans where billing happens outside of Stripe
+func (s *SubscriptionService) AssignPlan(userID string, planID string, periodEnd time.Time, paymentProvider string) (*core.Record, error) {
+	plan, err := s.repo.GetPlan(planID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plan: %w", err)
+	}
+
+	// Invoice plans are assigned by admins; sandbox tenants activate their own plans
+	initiatedBy := InitiatedByAdmin
+	if paymentProvider == "sandbox" {
+		initiatedBy = InitiatedByUser
+	}
+
+	// Move any existing active subscription to history before activating the new plan
+	existingSubscriptions, err := s.repo.FindAllUserSubscriptions(userID)
+	if err != nil {
+		s.log().Warn("Failed to find existing subscriptions", "user_id", userID, "error", err)
+	} else {
+		for _, existingSub := range existingSubscriptions {
+			if replaceableStatus(existingSub.GetString("status")) {
+				if _, err := s.repo.MoveSubscriptionToHistory(existingSub, HistoryParams{
+					Reason:      "plan_change",
+					InitiatedBy: initiatedBy,
+					NewPlanID:   plan.Id,
+				}); err != nil {
+					s.log().Warn("Failed to move subscription to history", "subscription_id", existingSub.Id, "error", err)
+				}
+				if err := s.repo.DeleteSubscription(existingSub.Id); err != nil {
+					s.log().Warn("Failed to delete subscription during plan assignment", "subscription_id", existingSub.Id, "error", err)
+				}
+			}
+		}
+	}
+
+	params := CreateSubscriptionParams{
+		UserID:             userID,
+		PlanID:             plan.Id,
+		Status:             StatusActive,
+		CurrentPeriodStart: time.Now(),
+		CurrentPeriodEnd:   periodEnd,
+		PaymentProvider:    &paymentProvider,
+	}
+
+	record, err := s.CreateSubscription(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign plan: %w", err)
+	}
+
+	s.log().Info("Plan assigned", "user_id", userID, "plan", plan.GetString("name"), "provider", paymentProvider, "until", periodEnd.Format("2006-01-02"))
 	return record, nil
 }
 
@@ -231,9 +320,21 @@ func (s *SubscriptionService) SwitchToFreePlan(userID string) (*core.Record, err
 func (s *SubscriptionService) GetUserSubscriptionInfo(userID string) (*SubscriptionInfo, error) {
 	// Get user's active subscription
 	subscription, err := s.repo.FindActiveSubscription(userID)
+
+	// A failed payment keeps the plan's benefits until the grace window ends, and a
+	// trialing subscription (e.g. a period extended by support) keeps them throughout
+	var paymentWarning *PaymentWarning
+	if err != nil {
+		if current, findErr := s.repo.FindSubscription(SubscriptionQuery{UserID: userID}); findErr == nil {
+			if access := AccessForSubscription(current, time.Now()); access.Subscribed {
+				subscription, paymentWarning, err = current, access.Warning, nil
+			}
+		}
+	}
+
 	if err != nil {
 		// No active subscription found - user should be on free plan
-		log.Printf("No subscription found for user %s, assigning to free plan", userID)
+		s.log().Info("No subscription found, assigning free plan", "user_id", userID)
 		
 		// Automatically assign user to free plan
 		freeSubscription, freeErr := s.SwitchToFreePlan(userID)
@@ -245,7 +346,8 @@ func (s *SubscriptionService) GetUserSubscriptionInfo(userID string) (*Subscript
 
 	// Determine which plan to use for benefits/limits
 	// CRITICAL FIX: For downgrades, user keeps current plan until period ends
-	planID := subscription.GetString("plan_id")
+	// A support override grants another plan's limits until it expires
+	planID := EffectivePlanID(subscription, time.Now())
 	
 	// With immediate plan changes, planID is always the current active plan
 	// No complex pending logic needed
@@ -264,6 +366,10 @@ func (s *SubscriptionService) GetUserSubscriptionInfo(userID string) (*Subscript
 		IsOverLimit:        false,
 		DaysUntilReset:     0,
 	}
+	// No hours while the subscription is paused
+	if IsPaused(subscription, time.Now()) {
+		usage.HoursLimit = 0
+	}
 
 	// Get all available plans
 	availablePlans, err := s.repo.GetAllPlans()
@@ -276,6 +382,7 @@ func (s *SubscriptionService) GetUserSubscriptionInfo(userID string) (*Subscript
 		Plan:          plan,
 		Usage:         usage,
 		AvailablePlans: availablePlans,
+		PaymentWarning: paymentWarning,
 	}, nil
 }
 
@@ -317,10 +424,10 @@ func (s *SubscriptionService) ProcessWebhookEvent(eventData WebhookEventData) er
 		return s.HandlePaymentFailed(eventData.Invoice)
 	case "checkout.session.completed":
 		// Log but don't process - wait for payment confirmation
-		log.Printf("Checkout session completed: %s", eventData.CheckoutSession.ID)
+		s.log().Info("Checkout session completed", "checkout_session_id", eventData.CheckoutSession.ID)
 		return nil
 	default:
-		log.Printf("Unhandled event type: %s", eventData.EventType)
+		s.log().Info("Unhandled subscription event type", "event_type", eventData.EventType)
 		return nil
 	}
 }
@@ -331,39 +438,35 @@ func (s *SubscriptionService) HandleSubscriptionEvent(stripeSub *stripe.Subscrip
 		return fmt.Errorf("stripe subscription data is nil")
 	}
 
-	log.Printf("Processing subscription event: %s for subscription %s", eventType, stripeSub.ID)
+	s.log().Info("Processing subscription event", "event_type", eventType, "stripe_subscription_id", stripeSub.ID)
+	sub := subscriptionFromStripe(stripeSub)
 
 	// Get user ID from customer (implement this based on your customer mapping)
-	userID, err := s.getUserIDFromCustomer(stripeSub.Customer.ID)
+	userID, err := s.getUserIDFromCustomer(sub.CustomerID)
 	if err != nil {
-		return fmt.Errorf("failed to get user ID from customer %s: %w", stripeSub.Customer.ID, err)
+		return fmt.Errorf("failed to get user ID from customer %s: %w", sub.CustomerID, err)
 	}
 
 	// Handle deletion separately
 	if eventType == "customer.subscription.deleted" {
-		return s.handleSubscriptionCancellation(userID, stripeSub)
+		return s.handleSubscriptionCancellation(userID, sub)
 	}
 
 	// Find the subscription plan that matches this Stripe price
-	stripePriceID, err := s.validator.ExtractPriceFromSubscription(stripeSub)
+	plan, stripePriceID, err := s.planForProviderSubscription(sub)
 	if err != nil {
-		return fmt.Errorf("failed to extract price from subscription: %w", err)
-	}
-
-	plan, err := s.repo.GetPlanByProviderPrice(stripePriceID)
-	if err != nil {
-		return fmt.Errorf("failed to find subscription plan for price %s: %w", stripePriceID, err)
+		return err
 	}
 
 	// Check if this is a plan change
-	existingSubscription, err := s.repo.FindSubscriptionByProviderID(stripeSub.ID)
+	existingSubscription, err := s.repo.FindSubscriptionByProviderID(sub.ID)
 	if err != nil {
 		// No existing subscription - create new one
-		return s.createSubscriptionFromStripe(userID, plan.Id, stripeSub, stripePriceID)
+		return s.createSubscriptionFromProvider(userID, plan.Id, sub, stripePriceID)
 	}
 
 	// Simplified: Just sync whatever Stripe tells us - all plan changes are immediate
-	return s.updateSubscriptionFromStripe(existingSubscription, plan.Id, stripeSub, stripePriceID)
+	return s.updateSubscriptionFromProvider(existingSubscription, plan.Id, sub, stripePriceID)
 }
 
 // HandlePaymentSucceeded handles successful payment events
@@ -372,7 +475,7 @@ func (s *SubscriptionService) HandlePaymentSucceeded(invoice *stripe.Invoice) er
 		return nil // Not a subscription invoice
 	}
 
-	log.Printf("Payment succeeded for subscription: %s", invoice.Subscription.ID)
+	s.log().Info("Payment succeeded", "stripe_subscription_id", invoice.Subscription.ID)
 
 	// This will trigger a subscription.updated event, so we don't need to do much here
 	// Just ensure the subscription exists and is properly updated via the subscription webhook
@@ -460,27 +563,30 @@ func (s *SubscriptionService) getUserIDFromCustomer(customerID string) (string,
 }
 
 // handleSubscriptionCancellation handles subscription deletion
-func (s *SubscriptionService) handleSubscriptionCancellation(userID string, stripeSub *stripe.Subscription) error {
-	log.Printf("Handling subscription cancellation for user %s", userID)
+func (s *SubscriptionService) handleSubscriptionCancellation(userID string, providerSub *ProviderSubscription) error {
+	s.log().Info("Handling subscription cancellation", "user_id", userID)
 
 	// Find the subscription to cancel
-	subscription, err := s.repo.FindSubscriptionByProviderID(stripeSub.ID)
+	subscription, err := s.repo.FindSubscriptionByProviderID(providerSub.ID)
 	if err != nil {
-		log.Printf("Warning: Could not find subscription to cancel: %v", err)
+		s.log().Warn("Could not find subscription to cancel", "user_id", userID, "error", err)
 		// Still continue to ensure user is on free plan
 	} else {
 		// Move subscription to history and delete it
-		_, err := s.repo.MoveSubscriptionToHistory(subscription, "subscription_cancelled")
+		_, err := s.repo.MoveSubscriptionToHistory(subscription, HistoryParams{
+			Reason:      "subscription_cancelled",
+			InitiatedBy: InitiatedByWebhook,
+		})
 		if err != nil {
-			log.Printf("Warning: Failed to move cancelled subscription to history: %v", err)
+			s.log().Warn("Failed to move cancelled subscription to history", "user_id", userID, "error", err)
 		}
 		
 		// Delete the current subscription
 		if err := s.repo.DeleteSubscription(subscription.Id); err != nil {
-			log.Printf("Warning: Failed to delete cancelled subscription: %v", err)
+			s.log().Warn("Failed to delete cancelled subscription", "user_id", userID, "error", err)
 		}
 		
-		log.Printf("User %s moved to free plan after subscription cancellation", userID)
+		s.log().Info("Moved to free plan after subscription cancellation", "user_id", userID)
 	}
 
 	return nil
@@ -494,107 +600,106 @@ func (s *SubscriptionService) getPlanByStripePrice(stripePriceID string) (*core.
 	return nil, fmt.Errorf("unsupported repository type for plan lookup")
 }
 
-// extractPriceFromSubscription safely extracts the price ID from a Stripe subscription
-func (s *SubscriptionService) extractPriceFromSubscription(sub *stripe.Subscription) (string, error) {
-	return s.validator.ExtractPriceFromSubscription(sub)
+// createSubscriptionFromProvider creates a new subscription from the provider's data
+func (s *SubscriptionService) createSubscriptionFromProvider(userID, planID string, providerSub *ProviderSubscription, providerPriceID string) error {
+	return s.createSubscriptionFromProviderInternal(userID, planID, providerSub, providerPriceID, true)
 }
 
-// createSubscriptionFromStripe creates a new subscription from Stripe data
-func (s *SubscriptionService) createSubscriptionFromStripe(userID, planID string, stripeSub *stripe.Subscription, stripePriceID string) error {
-	return s.createSubscriptionFromStripeInternal(userID, planID, stripeSub, stripePriceID, true)
-}
-
-// createSubscriptionFromStripeInternal creates a new subscription with option to move existing to history
-func (s *SubscriptionService) createSubscriptionFromStripeInternal(userID, planID string, stripeSub *stripe.Subscription, stripePriceID string, moveExistingToHistory bool) error {
+// createSubscriptionFromProviderInternal creates a new subscription with option to move existing to history
+func (s *SubscriptionService) createSubscriptionFromProviderInternal(userID, planID string, providerSub *ProviderSubscription, providerPriceID string, moveExistingToHistory bool) error {
 	if moveExistingToHistory {
 		// Move any existing active subscriptions to history instead of just deactivating
 		existingSubscriptions, err := s.repo.FindAllUserSubscriptions(userID)
 		if err != nil {
-			log.Printf("Warning: Failed to find existing subscriptions: %v", err)
+			s.log().Warn("Failed to find existing subscriptions", "user_id", userID, "error", err)
 		} else {
 			for _, existingSub := range existingSubscriptions {
-				if existingSub.GetString("status") == "active" {
-					_, err := s.repo.MoveSubscriptionToHistory(existingSub, "replaced_by_new_subscription")
+				if replaceableStatus(existingSub.GetString("status")) {
+					_, err := s.repo.MoveSubscriptionToHistory(existingSub, HistoryParams{
+						Reason:      "replaced_by_new_subscription",
+						InitiatedBy: InitiatedByWebhook,
+						NewPlanID:   planID,
+					})
 					if err != nil {
-						log.Printf("Warning: Failed to move subscription %s to history: %v", existingSub.Id, err)
+						s.log().Warn("Failed to move subscription to history", "subscription_id", existingSub.Id, "error", err)
 					}
 					// Delete the current subscription after moving to history
 					if err := s.repo.DeleteSubscription(existingSub.Id); err != nil {
-						log.Printf("Warning: Failed to delete replaced subscription: %v", err)
+						s.log().Warn("Failed to delete replaced subscription", "subscription_id", existingSub.Id, "error", err)
 					}
 				}
 			}
 		}
 	}
 
-	status := s.validator.MapStripeStatus(stripeSub.Status)
-	start := time.Unix(stripeSub.CurrentPeriodStart, 0)
-	end := time.Unix(stripeSub.CurrentPeriodEnd, 0)
+	status := s.validator.MapProviderStatus(providerSub.Status)
 
 	// Fix invalid timestamps
-	start, end = s.validator.FixInvalidTimestamps(start, end)
+	start, end := s.validator.FixInvalidTimestamps(providerSub.CurrentPeriodStart, providerSub.CurrentPeriodEnd)
 
 	params := CreateSubscriptionParams{
 		UserID:               userID,
 		PlanID:               planID,
-		ProviderSubscriptionID: &stripeSub.ID,
-		ProviderPriceID:        &stripePriceID,
+		ProviderSubscriptionID: &providerSub.ID,
+		ProviderPriceID:        &providerPriceID,
 		Status:               status,
 		CurrentPeriodStart:   start,
 		CurrentPeriodEnd:     end,
+		CanceledAt:           providerSub.CanceledAt,
 	}
 
-	if stripeSub.CanceledAt > 0 {
-		canceledAt := time.Unix(stripeSub.CanceledAt, 0)
-		params.CanceledAt = &canceledAt
+	if trialEnd := checkoutTrialEnd(providerSub); !trialEnd.IsZero() {
+		params.TrialEnd = &trialEnd
 	}
 
 	_, err := s.CreateSubscription(params)
 	return err
 }
 
-// updateSubscriptionFromStripe updates an existing subscription with Stripe data
-func (s *SubscriptionService) updateSubscriptionFromStripe(subscription *core.Record, planID string, stripeSub *stripe.Subscription, stripePriceID string) error {
+// updateSubscriptionFromProvider updates an existing subscription with the provider's data
+func (s *SubscriptionService) updateSubscriptionFromProvider(subscription *core.Record, planID string, providerSub *ProviderSubscription, providerPriceID string) error {
 	// Check if this is a significant change that requires moving to history
 	currentPlanID := subscription.GetString("plan_id")
 	if currentPlanID != planID {
-		log.Printf("Plan change detected: moving subscription %s to history (plan %s -> %s)", subscription.Id, currentPlanID, planID)
+		s.log().Info("Plan change detected, moving subscription to history", "subscription_id", subscription.Id, "from_plan", currentPlanID, "to_plan", planID)
 		// Move current subscription to history before creating/updating with new plan
-		_, err := s.repo.MoveSubscriptionToHistory(subscription, "plan_change")
+		_, err := s.repo.MoveSubscriptionToHistory(subscription, HistoryParams{
+			Reason:      "plan_change",
+			InitiatedBy: InitiatedByWebhook,
+			NewPlanID:   planID,
+		})
 		if err != nil {
-			log.Printf("Warning: Failed to move subscription to history: %v", err)
+			s.log().Warn("Failed to move subscription to history", "subscription_id", subscription.Id, "error", err)
 			// Continue with update even if history move fails
 		}
 		
 		// Delete the current subscription record
 		if err := s.repo.DeleteSubscription(subscription.Id); err != nil {
-			log.Printf("Warning: Failed to delete current subscription: %v", err)
+			s.log().Warn("Failed to delete current subscription", "subscription_id", subscription.Id, "error", err)
 		}
 		
 		// Create new subscription record with the new plan
-		return s.createSubscriptionFromStripeInternal(subscription.GetString("user_id"), planID, stripeSub, stripePriceID, false)
+		return s.createSubscriptionFromProviderInternal(subscription.GetString("user_id"), planID, providerSub, providerPriceID, false)
 	}
 
 	// If no plan change, just update the existing record
-	status := s.validator.MapStripeStatus(stripeSub.Status)
-	start := time.Unix(stripeSub.CurrentPeriodStart, 0)
-	end := time.Unix(stripeSub.CurrentPeriodEnd, 0)
+	status := s.validator.MapProviderStatus(providerSub.Status)
 
 	// Fix invalid timestamps
-	start, end = s.validator.FixInvalidTimestamps(start, end)
+	start, end := s.validator.FixInvalidTimestamps(providerSub.CurrentPeriodStart, providerSub.CurrentPeriodEnd)
 
 	params := UpdateSubscriptionParams{
 		PlanID:             &planID,
-		ProviderPriceID:      &stripePriceID,
+		ProviderPriceID:      &providerPriceID,
 		Status:             &status,
 		CurrentPeriodStart: &start,
 		CurrentPeriodEnd:   &end,
+		CanceledAt:         providerSub.CanceledAt,
 	}
 
-	if stripeSub.CanceledAt > 0 {
-		canceledAt := time.Unix(stripeSub.CanceledAt, 0)
-		params.CanceledAt = &canceledAt
-	}
+	trialEnd := checkoutTrialEnd(providerSub)
+	params.TrialEnd = &trialEnd
+	providerPause(subscription, providerSub, &params)
 
 	_, err := s.repo.UpdateSubscription(subscription.Id, params)
 	return err
@@ -603,13 +708,11 @@ func (s *SubscriptionService) updateSubscriptionFromStripe(subscription *core.Re
 // updateSubscriptionMetadataOnly updates subscription metadata without changing the plan
 // This is used when a subscription is set to cancel at period end - we preserve the current plan
 // until the billing period actually ends
-func (s *SubscriptionService) updateSubscriptionMetadataOnly(subscription *core.Record, stripeSub *stripe.Subscription) error {
-	status := s.validator.MapStripeStatus(stripeSub.Status)
-	start := time.Unix(stripeSub.CurrentPeriodStart, 0)
-	end := time.Unix(stripeSub.CurrentPeriodEnd, 0)
+func (s *SubscriptionService) updateSubscriptionMetadataOnly(subscription *core.Record, providerSub *ProviderSubscription) error {
+	status := s.validator.MapProviderStatus(providerSub.Status)
 
 	// Fix invalid timestamps
-	start, end = s.validator.FixInvalidTimestamps(start, end)
+	start, end := s.validator.FixInvalidTimestamps(providerSub.CurrentPeriodStart, providerSub.CurrentPeriodEnd)
 
 	// CRITICAL: Do NOT update PlanID or ProviderPriceID when cancel_at_period_end is true
 	// The user should keep their current plan benefits until the period ends
@@ -618,21 +721,21 @@ func (s *SubscriptionService) updateSubscriptionMetadataOnly(subscription *core.
 		Status:             &status,
 		CurrentPeriodStart: &start,
 		CurrentPeriodEnd:   &end,
+		CanceledAt:         providerSub.CanceledAt,
 	}
 
-	if stripeSub.CanceledAt > 0 {
-		canceledAt := time.Unix(stripeSub.CanceledAt, 0)
-		params.CanceledAt = &canceledAt
-	}
+	trialEnd := checkoutTrialEnd(providerSub)
+	params.TrialEnd = &trialEnd
+	providerPause(subscription, providerSub, &params)
 
-	log.Printf("Updating subscription metadata only (preserving current plan) for subscription %s", subscription.Id)
+	s.log().Info("Updating subscription metadata only, preserving current plan", "subscription_id", subscription.Id)
 	_, err := s.repo.UpdateSubscription(subscription.Id, params)
 	return err
 }
 
 // ChangePlan handles plan changes through the service layer (SINGLE ENTRY POINT)
 func (s *SubscriptionService) ChangePlan(userID string, newPlanID string) (*ChangePlanResult, error) {
-	log.Printf("Processing plan change for user %s to plan %s", userID, newPlanID)
+	s.log().Info("Processing plan change", "user_id", userID, "plan_id", newPlanID)
 
 	// Get user's current active subscription
 	currentSub, err := s.repo.FindActiveSubscription(userID)
@@ -656,14 +759,26 @@ func (s *SubscriptionService) ChangePlan(userID string, newPlanID string) (*Chan
 		return nil, fmt.Errorf("plan change validation failed: %s", validationErrors[0].Message)
 	}
 
-	// Determine if this is an upgrade or downgrade
-	currentPrice := int64(currentPlan.GetInt("price_cents"))
-	targetPrice := int64(targetPlan.GetInt("price_cents"))
+	// Determine if this is an upgrade or downgrade - per month, so yearly plans compare fairly
+	currentPrice := MonthlyPriceCents(currentPlan)
+	targetPrice := MonthlyPriceCents(targetPlan)
 	isUpgrade := targetPrice > currentPrice
 
-	log.Printf("Plan change: %s (%d cents) -> %s (%d cents), isUpgrade: %v",
-		currentPlan.GetString("name"), currentPrice,
-		targetPlan.GetString("name"), targetPrice, isUpgrade)
+	s.log().Info("Plan change", "user_id", userID,
+		"from_plan", currentPlan.GetString("name"), "from_cents", currentPrice,
+		"to_plan", targetPlan.GetString("name"), "to_cents", targetPrice, "upgrade", isUpgrade)
+
+	// Moving to fewer hours than already used this month would leave the user over their limit
+	var usageWarning *DowngradeUsageError
+	if targetPlan.GetFloat("hours_per_month") < currentPlan.GetFloat("hours_per_month") {
+		if usageErr := s.validator.ValidateDowngradeUsage(userID, targetPlan, time.Now()); usageErr != nil {
+			if DowngradeUsageGuardMode() == DowngradeGuardBlock {
+				return nil, usageErr
+			}
+			s.log().Warn("Downgrading with usage over the target plan limit", "user_id", userID, "hours_over", usageErr.HoursOver)
+			usageWarning = usageErr
+		}
+	}
 
 	// Get Stripe subscription ID
 	stripeSubID := currentSub.GetString("provider_subscription_id")
@@ -677,12 +792,26 @@ func (s *SubscriptionService) ChangePlan(userID string, newPlanID string) (*Chan
 		return nil, fmt.Errorf("target plan has no Stripe price ID")
 	}
 
-	log.Printf("Processing immediate plan change: %s -> %s", currentPlan.GetString("name"), targetPlan.GetString("name"))
+	s.log().Info("Processing immediate plan change", "from_plan", currentPlan.GetString("name"), "to_plan", targetPlan.GetString("name"))
 
-	// Update Stripe subscription immediately - Stripe handles prorations
-	err = s.updateStripeSubscription(stripeSubID, stripePriceID)
+	// Update the provider subscription immediately - the provider handles prorations
+	prorationCents, err := s.changeProviderPrice(stripeSubID, stripePriceID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update Stripe subscription: %w", err)
+		return nil, fmt.Errorf("failed to update provider subscription: %w", err)
+	}
+	changeType := "upgrade"
+	if !isUpgrade {
+		changeType = "downgrade"
+	}
+
+	// Keep the old plan in history so the change shows up in the user's plan change log
+	if _, err := s.repo.MoveSubscriptionToHistory(currentSub, HistoryParams{
+		Reason:               changeType,
+		InitiatedBy:          InitiatedByUser,
+		NewPlanID:            targetPlan.Id,
+		ProrationAmountCents: prorationCents,
+	}); err != nil {
+		s.log().Warn("Failed to record plan change in history", "user_id", userID, "error", err)
 	}
 
 	// Update local database immediately to match the Stripe change
@@ -691,13 +820,9 @@ func (s *SubscriptionService) ChangePlan(userID string, newPlanID string) (*Chan
 		ProviderPriceID: &stripePriceID,
 	})
 	if err != nil {
-		log.Printf("Warning: Stripe updated successfully but local database update failed: %v", err)
+		s.log().Error("Stripe updated but local database update failed", "user_id", userID, "error", err)
 		// Don't fail the request since Stripe succeeded - webhook will eventually sync
 	}
-	changeType := "upgrade"
-	if !isUpgrade {
-		changeType = "downgrade"
-	}
 
 	return &ChangePlanResult{
 		Success:       true,
@@ -706,19 +831,20 @@ func (s *SubscriptionService) ChangePlan(userID string, newPlanID string) (*Chan
 		NewPlan:       targetPlan.Id,
 		EffectiveDate: "immediately",
 		PendingChange: false,
+		UsageWarning:  usageWarning,
 	}, nil
 }
 
 
 
-// updateStripeSubscription immediately updates a Stripe subscription price with prorations
-func (s *SubscriptionService) updateStripeSubscription(subID string, priceID string) error {
-	log.Printf("Updating Stripe subscription %s to priceID=%s (immediate with prorations)", subID, priceID)
-	return s.stripe.UpdateSubscription(subID, priceID)
-}
-
-func (s *SubscriptionService) getStripeSubscription(subID string) (*stripe.Subscription, error) {
-	return s.stripe.GetSubscription(subID)
+// changeProviderPrice immediately moves the provider subscription to another price with
+// prorations and returns the prorated amount invoiced for the change, if the provider reported one
+func (s *SubscriptionService) changeProviderPrice(subID string, priceID string) (*int64, error) {
+	if s.provider == nil {
+		return nil, ErrNoPaymentProvider
+	}
+	s.log().Info("Updating provider subscription with prorations", "provider_subscription_id", subID, "price_id", priceID)
+	return s.provider.ChangeSubscriptionPrice(subID, priceID)
 }
 
 
@@ -17,6 +17,7 @@ type MockRepository struct {
 	activeSubscriptions map[string]*core.Record
 	customerMapping     map[string]string        // Map Stripe customer ID -> user ID
 	freePlan            *core.Record             // Default free plan
+	hoursUsed           map[string]float64       // Map user ID -> hours used this month
 	createError         error
 	updateError         error
 	findError           error
@@ -32,6 +33,7 @@ func NewMockRepository() *MockRepository {
 		plansByPrice:       make(map[string]*core.Record),
 		activeSubscriptions: make(map[string]*core.Record),
 		customerMapping:    make(map[string]string),
+		hoursUsed:          make(map[string]float64),
 		historyRecords:     []*core.Record{},
 		historyOperations:  []string{},
 	}
@@ -131,6 +133,15 @@ func (m *MockRepository) GetPlanByProviderPrice(stripePriceID string) (*core.Rec
 	return record, nil
 }
 
+func (m *MockRepository) GetPlanByProviderProduct(stripeProductID string, interval string) (*core.Record, error) {
+	for _, record := range m.plans {
+		if record.GetString("provider_product_id") == stripeProductID && record.GetString("billing_interval") == interval {
+			return record, nil
+		}
+	}
+	return nil, errors.New("plan not found for product ID: " + stripeProductID)
+}
+
 func (m *MockRepository) GetFreePlan() (*core.Record, error) {
 	if m.freePlan != nil {
 		return m.freePlan, nil
@@ -163,16 +174,16 @@ func (m *MockRepository) CleanupDuplicateSubscriptions(userID string) error {
 }
 
 // MoveSubscriptionToHistory moves a subscription to history (new method for audit trail)
-func (m *MockRepository) MoveSubscriptionToHistory(subscriptionRecord *core.Record, reason string) (*core.Record, error) {
+func (m *MockRepository) MoveSubscriptionToHistory(subscriptionRecord *core.Record, params HistoryParams) (*core.Record, error) {
 	// Track the operation for testing
-	m.historyOperations = append(m.historyOperations, reason)
+	m.historyOperations = append(m.historyOperations, params.Reason)
 	
 	// Mock implementation - create and store history record
 	historyRecord := &core.Record{}
 	historyRecord.Id = "history_" + subscriptionRecord.Id
 	historyRecord.Set("user_id", subscriptionRecord.GetString("user_id"))
 	historyRecord.Set("plan_id", subscriptionRecord.GetString("plan_id"))
-	historyRecord.Set("replacement_reason", reason)
+	historyRecord.Set("replacement_reason", params.Reason)
 	
 	m.historyRecords = append(m.historyRecords, historyRecord)
 	return historyRecord, nil
@@ -199,6 +210,11 @@ func (m *MockRepository) SetupTestPlans() {
 	m.freePlan = freePlan
 }
 
+// GetPeriodHoursUsed returns the hours set in hoursUsed, over the calendar month
+func (m *MockRepository) GetPeriodHoursUsed(userID string, now time.Time) (float64, UsagePeriod, error) {
+	return m.hoursUsed[userID], CalendarUsagePeriod(now), nil
+}
+
 // Helper to create test subscription
 func (m *MockRepository) CreateTestSubscription(userID, planID string) *core.Record {
 	sub := &core.Record{}
@@ -834,7 +850,7 @@ func TestUpdateSubscriptionMetadataOnly_PreservesPlanAndPriceID(t *testing.T) {
 	
 	// Call the method directly
 	service_impl := service.(*SubscriptionService)
-	err := service_impl.updateSubscriptionMetadataOnly(subscription, stripeSub)
+	err := service_impl.updateSubscriptionMetadataOnly(subscription, subscriptionFromStripe(stripeSub))
 	
 	if err != nil {
 		t.Fatalf("updateSubscriptionMetadataOnly should not fail: %v", err)
@@ -0,0 +1,138 @@
+package subscription
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// PlanOverride grants a plan's limits on top of the subscription until it expires,
+// without touching billing. A zero PlanID clears the override
+type PlanOverride struct {
+	PlanID    string    `json:"plan_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Reason    string    `json:"reason"`
+}
+
+// ActivePlanOverride returns the subscription's override while it's in effect
+func ActivePlanOverride(subscription *core.Record, now time.Time) *PlanOverride {
+	planID := subscription.GetString("override_plan_id")
+	expiresAt := subscription.GetDateTime("override_expires_at").Time()
+	if planID == "" || !now.Before(expiresAt) {
+		return nil
+	}
+	return &PlanOverride{PlanID: planID, ExpiresAt: expiresAt, Reason: subscription.GetString("override_reason")}
+}
+
+// EffectivePlanID returns the plan whose limits apply: the override while it's in effect,
+// the subscribed plan otherwise
+func EffectivePlanID(subscription *core.Record, now time.Time) string {
+	if override := ActivePlanOverride(subscription, now); override != nil {
+		return override.PlanID
+	}
+	return subscription.GetString("plan_id")
+}
+
+// ResyncFromProvider overwrites the user's current subscription with the state the payment
+// provider has for it
+func (s *SubscriptionService) ResyncFromProvider(userID string) (*core.Record, error) {
+	if s.provider == nil {
+		return nil, ErrNoPaymentProvider
+	}
+	current, err := s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	providerSubID := current.GetString("provider_subscription_id")
+	if providerSubID == "" {
+		return nil, BusinessRuleError{Rule: "provider_managed", Message: "subscription isn't managed by the payment provider"}
+	}
+
+	providerSub, err := s.provider.GetSubscription(providerSubID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch provider subscription %s: %w", providerSubID, err)
+	}
+
+	if s.validator.MapProviderStatus(providerSub.Status) == StatusCanceled {
+		if err := s.handleSubscriptionCancellation(userID, providerSub); err != nil {
+			return nil, err
+		}
+	} else {
+		plan, providerPriceID, err := s.planForProviderSubscription(providerSub)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.updateSubscriptionFromProvider(current, plan.Id, providerSub, providerPriceID); err != nil {
+			return nil, err
+		}
+	}
+
+	s.log().Info("Subscription resynced from provider", "user_id", userID, "provider_subscription_id", providerSubID, "status", providerSub.Status)
+	return s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+}
+
+// ExtendPeriod moves the end of the user's current period out to until at no charge.
+// Provider subscriptions are extended with the provider, so the next renewal moves with it
+func (s *SubscriptionService) ExtendPeriod(userID string, until time.Time) (*core.Record, error) {
+	current, err := s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	if periodEnd := current.GetDateTime("current_period_end").Time(); !until.After(periodEnd) {
+		return nil, ValidationError{Field: "until", Message: fmt.Sprintf("must be after the current period end (%s)", periodEnd.UTC().Format(time.RFC3339))}
+	}
+
+	if providerSubID := current.GetString("provider_subscription_id"); providerSubID != "" {
+		if s.provider == nil {
+			return nil, ErrNoPaymentProvider
+		}
+		providerSub, err := s.provider.ExtendSubscription(providerSubID, until)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extend provider subscription %s: %w", providerSubID, err)
+		}
+		if err := s.updateSubscriptionMetadataOnly(current, providerSub); err != nil {
+			return nil, err
+		}
+	} else if _, err := s.repo.UpdateSubscription(current.Id, UpdateSubscriptionParams{CurrentPeriodEnd: &until}); err != nil {
+		return nil, err
+	}
+
+	s.log().Info("Subscription extended", "user_id", userID, "until", until.Format("2006-01-02"))
+	return s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+}
+
+// SetPlanOverride grants, replaces or clears the plan override on the user's current subscription
+func (s *SubscriptionService) SetPlanOverride(userID string, override PlanOverride) (*core.Record, error) {
+	current, err := s.repo.FindSubscription(SubscriptionQuery{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	if override.PlanID != "" {
+		if _, err := s.repo.GetPlan(override.PlanID); err != nil {
+			return nil, ValidationError{Field: "plan_id", Message: "plan not found"}
+		}
+		if !override.ExpiresAt.After(time.Now()) {
+			return nil, ValidationError{Field: "expires_at", Message: "must be in the future"}
+		}
+	} else {
+		override = PlanOverride{}
+	}
+
+	record, err := s.repo.UpdateSubscription(current.Id, UpdateSubscriptionParams{
+		OverridePlanID:    &override.PlanID,
+		OverrideExpiresAt: &override.ExpiresAt,
+		OverrideReason:    &override.Reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if override.PlanID == "" {
+		s.log().Info("Plan override cleared", "user_id", userID)
+	} else {
+		s.log().Info("Plan override granted", "user_id", userID, "plan_id", override.PlanID, "until", override.ExpiresAt.Format("2006-01-02"), "reason", override.Reason)
+	}
+	return record, nil
+}
@@ -0,0 +1,128 @@
+package subscription
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func supportSubscription(providerSubID string, periodEnd time.Time) *core.Record {
+	collection := core.NewBaseCollection("current_user_subscriptions")
+	collection.Fields.Add(
+		&core.TextField{Name: "plan_id"},
+		&core.TextField{Name: "provider_subscription_id"},
+		&core.DateField{Name: "current_period_end"},
+		&core.TextField{Name: "override_plan_id"},
+		&core.DateField{Name: "override_expires_at"},
+		&core.TextField{Name: "override_reason"},
+	)
+	record := core.NewRecord(collection)
+	record.Id = "sub_record"
+	record.Set("plan_id", "plan_basic")
+	record.Set("provider_subscription_id", providerSubID)
+	record.Set("current_period_end", periodEnd)
+	return record
+}
+
+func TestEffectivePlanID(t *testing.T) {
+	now := time.Date(2026, time.May, 1, 12, 0, 0, 0, time.UTC)
+	record := supportSubscription("", now)
+
+	if got := EffectivePlanID(record, now); got != "plan_basic" {
+		t.Errorf("without override: got %s, want plan_basic", got)
+	}
+
+	record.Set("override_plan_id", "plan_pro")
+	record.Set("override_expires_at", now.Add(24*time.Hour))
+	record.Set("override_reason", "outage credit")
+	if got := EffectivePlanID(record, now); got != "plan_pro" {
+		t.Errorf("active override: got %s, want plan_pro", got)
+	}
+	if override := ActivePlanOverride(record, now); override == nil || override.Reason != "outage credit" {
+		t.Errorf("active override = %+v", override)
+	}
+
+	if got := EffectivePlanID(record, now.Add(25*time.Hour)); got != "plan_basic" {
+		t.Errorf("expired override: got %s, want plan_basic", got)
+	}
+}
+
+func TestExtendPeriod(t *testing.T) {
+	periodEnd := time.Now().Add(10 * 24 * time.Hour).Truncate(time.Second)
+
+	t.Run("rejects a date before the current period end", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.activeSubscriptions["user_1"] = supportSubscription("", periodEnd)
+		service := NewServiceWithProvider(repo, NewMockPaymentProvider())
+
+		_, err := service.ExtendPeriod("user_1", periodEnd.Add(-time.Hour))
+		var validationErr ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != "until" {
+			t.Fatalf("expected an until validation error, got %v", err)
+		}
+	})
+
+	t.Run("extends provider subscriptions with the provider", func(t *testing.T) {
+		repo := NewMockRepository()
+		record := supportSubscription("sub_stripe_1", periodEnd)
+		repo.activeSubscriptions["user_1"] = record
+		repo.subscriptions[record.Id] = record
+		provider := NewMockPaymentProvider()
+		service := NewServiceWithProvider(repo, provider)
+
+		if _, err := service.ExtendPeriod("user_1", periodEnd.Add(7*24*time.Hour)); err != nil {
+			t.Fatalf("ExtendPeriod: %v", err)
+		}
+		if len(provider.ExtendCalls) != 1 || provider.ExtendCalls[0] != "sub_stripe_1" {
+			t.Errorf("ExtendCalls = %v, want [sub_stripe_1]", provider.ExtendCalls)
+		}
+	})
+}
+
+func TestProviderOperations(t *testing.T) {
+	t.Run("need a payment provider", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.activeSubscriptions["user_1"] = supportSubscription("sub_stripe_1", time.Now())
+		service := NewService(repo)
+
+		if _, err := service.CancelSubscription("user_1"); !errors.Is(err, ErrNoPaymentProvider) {
+			t.Errorf("CancelSubscription: expected ErrNoPaymentProvider, got %v", err)
+		}
+		if _, err := service.ResyncFromProvider("user_1"); !errors.Is(err, ErrNoPaymentProvider) {
+			t.Errorf("ResyncFromProvider: expected ErrNoPaymentProvider, got %v", err)
+		}
+	})
+
+	t.Run("cancel through the provider", func(t *testing.T) {
+		repo := NewMockRepository()
+		repo.SetupTestPlans()
+		repo.activeSubscriptions["user_1"] = supportSubscription("sub_stripe_1", time.Now())
+		provider := NewMockPaymentProvider()
+		service := NewServiceWithProvider(repo, provider)
+
+		_, _ = service.CancelSubscription("user_1")
+		if len(provider.CancelCalls) != 1 || provider.CancelCalls[0] != "sub_stripe_1" {
+			t.Errorf("CancelCalls = %v, want [sub_stripe_1]", provider.CancelCalls)
+		}
+
+		repo.activeSubscriptions["user_1"] = supportSubscription("sub_stripe_1", time.Now())
+		provider.CancelError = errors.New("provider unavailable")
+		if _, err := service.CancelSubscription("user_1"); err == nil {
+			t.Error("expected the provider error to fail the cancellation")
+		}
+	})
+}
+
+func TestSetPlanOverrideValidation(t *testing.T) {
+	repo := NewMockRepository()
+	repo.activeSubscriptions["user_1"] = supportSubscription("", time.Now())
+	service := NewServiceWithProvider(repo, NewMockPaymentProvider())
+
+	_, err := service.SetPlanOverride("user_1", PlanOverride{PlanID: "missing", ExpiresAt: time.Now().Add(time.Hour)})
+	var validationErr ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "plan_id" {
+		t.Errorf("expected a plan_id validation error, got %v", err)
+	}
+}
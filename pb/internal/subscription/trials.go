@@ -0,0 +1,24 @@
+package subscription
+
+import (
+	"time"
+)
+
+// TrialMetadataKey marks provider subscriptions that began with a free trial at checkout. Support
+// extensions (ExtendPeriod) also use Stripe's trial_end, so it alone doesn't mean a trial
+const TrialMetadataKey = "checkout_trial"
+
+// replaceableStatus reports whether a current subscription is moved to history when the user
+// gets another one. Trials count: the user is on the plan until the trial ends
+func replaceableStatus(status string) bool {
+	return status == string(StatusActive) || status == string(StatusTrialing)
+}
+
+// checkoutTrialEnd returns when the subscription's checkout trial ends, or a zero time when it
+// didn't start with one
+func checkoutTrialEnd(sub *ProviderSubscription) time.Time {
+	if sub.TrialEnd == nil || sub.Metadata[TrialMetadataKey] != "true" {
+		return time.Time{}
+	}
+	return *sub.TrialEnd
+}
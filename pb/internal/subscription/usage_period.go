@@ -0,0 +1,158 @@
+package subscription
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// UsageThresholds are the percentages of the plan's hours users are notified at unless they
+// choose their own
+var UsageThresholds = []int{80, 100}
+
+// CrossedUsageThresholds returns the thresholds (ascending percentages) usage went past between
+// before and after hours
+func CrossedUsageThresholds(before, after, limitHours float64, thresholds []int) []int {
+	if limitHours <= 0 {
+		return nil
+	}
+	var crossed []int
+	for _, percent := range thresholds {
+		threshold := limitHours * float64(percent) / 100
+		if before < threshold && after >= threshold {
+			crossed = append(crossed, percent)
+		}
+	}
+	return crossed
+}
+
+// UsagePeriod is the window a monthly quota is counted over. Subscriptions billed by a payment
+// provider count from their billing day, so someone who subscribes on the 20th isn't reset on
+// the 1st; everyone else (free and invoice plans, organization pools) counts calendar months
+type UsagePeriod struct {
+	Key      string    `json:"key"` // monthly_usage.year_month: the month the period starts in
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Anchored bool      `json:"anchored"` // follows the billing period rather than the calendar
+}
+
+// CalendarUsagePeriod returns the calendar month containing now
+func CalendarUsagePeriod(now time.Time) UsagePeriod {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return UsagePeriod{Key: start.Format("2006-01"), Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+// AnchoredUsagePeriod returns the month-long period containing now that starts on the anchor's
+// day of the month. Months are stepped from the anchor, so a yearly subscription still resets
+// monthly and a renewal webhook arriving late doesn't leave a gap
+func AnchoredUsagePeriod(anchor, now time.Time) UsagePeriod {
+	anchor = anchor.UTC()
+	months := (now.UTC().Year()-anchor.Year())*12 + int(now.UTC().Month()-anchor.Month())
+	start := addMonths(anchor, months)
+	if start.After(now) {
+		months--
+		start = addMonths(anchor, months)
+	}
+	return UsagePeriod{Key: start.Format("2006-01"), Start: start, End: addMonths(anchor, months+1), Anchored: true}
+}
+
+// addMonths moves t by whole months, clamping the day to the end of shorter months the way
+// billing providers do (Jan 31 -> Feb 28 -> Mar 31)
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
+
+// UsagePeriodForSubscription anchors usage to a provider-billed subscription's billing period,
+// and falls back to the calendar month for subscriptions without one
+func UsagePeriodForSubscription(subscription *core.Record, now time.Time) UsagePeriod {
+	if subscription == nil || subscription.GetString("provider_subscription_id") == "" {
+		return CalendarUsagePeriod(now)
+	}
+	anchor := subscription.GetDateTime("current_period_start")
+	if anchor.IsZero() {
+		return CalendarUsagePeriod(now)
+	}
+	return AnchoredUsagePeriod(anchor.Time(), now)
+}
+
+// CurrentUsagePeriod returns the usage period the user's personal usage is counted in
+func CurrentUsagePeriod(app core.App, userID string, now time.Time) UsagePeriod {
+	current, err := NewRepository(app).FindSubscription(SubscriptionQuery{UserID: userID})
+	if err != nil {
+		return CalendarUsagePeriod(now)
+	}
+	return UsagePeriodForSubscription(current, now)
+}
+
+// FindUsageRecord returns the monthly_usage record counting the period, or nil if nothing was
+// recorded in it yet
+// Records written before usage followed billing periods have no period_start; the one for the
+// current calendar month is moved onto the period the first time it's looked up (unless it was
+// last used before the period began), so nobody gets a fresh quota when this ships
+func FindUsageRecord(app core.App, userID, organizationID string, period UsagePeriod, now time.Time) (*core.Record, error) {
+	record, err := app.FindFirstRecordByFilter("monthly_usage",
+		"user_id = {:user} && "+organizationFilter(organizationID)+" && period_start = {:start}",
+		map[string]any{"user": userID, "org": organizationID, "start": dbTime(period.Start)})
+	if err == nil {
+		return record, nil
+	}
+
+	legacy, err := app.FindFirstRecordByFilter("monthly_usage",
+		"user_id = {:user} && "+organizationFilter(organizationID)+" && year_month = {:month} && period_start = ''",
+		map[string]any{"user": userID, "org": organizationID, "month": CalendarUsagePeriod(now).Key})
+	if err != nil {
+		return nil, nil
+	}
+
+	lastUsed := legacy.GetDateTime("last_processing_date")
+	if !lastUsed.IsZero() && lastUsed.Time().Before(period.Start) {
+		// Belongs to an earlier period - close it on its calendar month
+		calendar := CalendarUsagePeriod(now)
+		legacy.Set("period_start", calendar.Start)
+		legacy.Set("period_end", calendar.End)
+		if err := app.Save(legacy); err != nil {
+			return nil, fmt.Errorf("failed to close legacy monthly usage record: %w", err)
+		}
+		return nil, nil
+	}
+
+	legacy.Set("year_month", period.Key)
+	legacy.Set("period_start", period.Start)
+	legacy.Set("period_end", period.End)
+	if err := app.Save(legacy); err != nil {
+		return nil, fmt.Errorf("failed to move monthly usage record onto its billing period: %w", err)
+	}
+	return legacy, nil
+}
+
+// organizationFilter matches usage charged to the organization, or personal usage when it's
+// empty - a bound empty string never matches an unset relation, so that case uses a literal
+func organizationFilter(organizationID string) string {
+	if organizationID == "" {
+		return "organization_id = ''"
+	}
+	return "organization_id = {:org}"
+}
+
+// NewUsageRecord returns an unsaved monthly_usage record for the period
+func NewUsageRecord(app core.App, userID, organizationID string, period UsagePeriod) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("monthly_usage")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find monthly_usage collection: %w", err)
+	}
+	record := core.NewRecord(collection)
+	record.Set("user_id", userID)
+	record.Set("organization_id", organizationID)
+	record.Set("year_month", period.Key)
+	record.Set("period_start", period.Start)
+	record.Set("period_end", period.End)
+	return record, nil
+}
+
+func dbTime(t time.Time) string {
+	return t.UTC().Format(types.DefaultDateLayout)
+}
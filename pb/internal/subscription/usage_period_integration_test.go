@@ -0,0 +1,88 @@
+//go:build integration
+
+package subscription_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+	"pocketbase/internal/testharness"
+)
+
+// addHours records processed hours the way the transcription endpoints do: onto the period's
+// usage record, creating it on first use
+func addHours(t *testing.T, app core.App, userID, organizationID string, hours float64, now time.Time) {
+	t.Helper()
+	period := subscription.CurrentUsagePeriod(app, userID, now)
+	if organizationID != "" {
+		period = subscription.CalendarUsagePeriod(now)
+	}
+	record, err := subscription.FindUsageRecord(app, userID, organizationID, period, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil {
+		if record, err = subscription.NewUsageRecord(app, userID, organizationID, period); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record.Set("hours_used", record.GetFloat("hours_used")+hours)
+	record.Set("last_processing_date", now)
+	if err := app.Save(record); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersonalUsageAccumulatesOnOneRecord(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "personal@test.com")
+	now := time.Now()
+
+	addHours(t, app, user.Id, "", 1.5, now)
+	addHours(t, app, user.Id, "", 0.5, now)
+
+	records, err := app.FindRecordsByFilter("monthly_usage", "user_id = {:user}", "", 0, 0, map[string]any{"user": user.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].GetFloat("hours_used") != 2 {
+		t.Fatalf("got %d usage records, want one with 2 hours", len(records))
+	}
+
+	hours, _, err := subscription.NewRepository(app).GetPeriodHoursUsed(user.Id, now)
+	if err != nil || hours != 2 {
+		t.Errorf("GetPeriodHoursUsed = %v, %v, want 2", hours, err)
+	}
+}
+
+func TestLegacyPersonalUsageIsPickedUp(t *testing.T) {
+	app := testharness.New(t)
+	user := testharness.CreateUser(t, app, "legacy@test.com")
+	now := time.Now()
+
+	// Written before usage followed billing periods: no period_start
+	collection, err := app.FindCollectionByNameOrId("monthly_usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := core.NewRecord(collection)
+	legacy.Set("user_id", user.Id)
+	legacy.Set("year_month", subscription.CalendarUsagePeriod(now).Key)
+	legacy.Set("hours_used", 3)
+	legacy.Set("last_processing_date", now)
+	if err := app.Save(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	hours, period, err := subscription.NewRepository(app).GetPeriodHoursUsed(user.Id, now)
+	if err != nil || hours != 3 {
+		t.Fatalf("GetPeriodHoursUsed = %v, %v, want the legacy 3 hours", hours, err)
+	}
+	moved, err := app.FindRecordById("monthly_usage", legacy.Id)
+	if err != nil || moved.GetDateTime("period_start").Time().Unix() != period.Start.Unix() {
+		t.Errorf("legacy record not moved onto the period: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package subscription
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func TestCrossedUsageThresholds(t *testing.T) {
+	cases := []struct {
+		before, after, limit float64
+		want                 []int
+	}{
+		{0, 5, 10, nil},
+		{7, 8, 10, []int{80}},
+		{7, 12, 10, []int{80, 100}},
+		{8, 9, 10, nil},
+		{9, 10, 10, []int{100}},
+		{10, 11, 10, nil},
+		{0, 50, 0, nil}, // no plan hours to measure against
+	}
+	for _, c := range cases {
+		if got := CrossedUsageThresholds(c.before, c.after, c.limit, UsageThresholds); !slices.Equal(got, c.want) {
+			t.Errorf("CrossedUsageThresholds(%v, %v, %v) = %v, want %v", c.before, c.after, c.limit, got, c.want)
+		}
+	}
+
+	if got := CrossedUsageThresholds(4, 9.6, 10, []int{50, 80, 95}); !slices.Equal(got, []int{50, 80, 95}) {
+		t.Errorf("custom thresholds crossed = %v, want [50 80 95]", got)
+	}
+	if got := CrossedUsageThresholds(4, 9.6, 10, []int{}); got != nil {
+		t.Errorf("no thresholds crossed = %v, want none", got)
+	}
+}
+
+func TestCalendarUsagePeriod(t *testing.T) {
+	period := CalendarUsagePeriod(time.Date(2025, time.December, 14, 9, 30, 0, 0, time.UTC))
+	if period.Key != "2025-12" || period.Anchored {
+		t.Errorf("unexpected period: %+v", period)
+	}
+	if !period.Start.Equal(time.Date(2025, time.December, 1, 0, 0, 0, 0, time.UTC)) ||
+		!period.End.Equal(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected bounds: %v - %v", period.Start, period.End)
+	}
+}
+
+func TestAnchoredUsagePeriod(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 12, 0, 0, 0, time.UTC) }
+	anchor := day(2025, time.January, 20)
+
+	tests := []struct {
+		name       string
+		anchor     time.Time
+		now        time.Time
+		key        string
+		start, end time.Time
+	}{
+		{"inside the first period", anchor, day(2025, time.February, 5), "2025-01", anchor, day(2025, time.February, 20)},
+		{"not reset on the 1st", anchor, day(2025, time.March, 1), "2025-02", day(2025, time.February, 20), day(2025, time.March, 20)},
+		{"renewal day", anchor, day(2025, time.March, 20), "2025-03", day(2025, time.March, 20), day(2025, time.April, 20)},
+		{"stale period keeps stepping monthly", anchor, day(2025, time.November, 2), "2025-10", day(2025, time.October, 20), day(2025, time.November, 20)},
+		{"month end is clamped", day(2025, time.January, 31), day(2025, time.March, 10), "2025-02", day(2025, time.February, 28), day(2025, time.March, 31)},
+	}
+	for _, tt := range tests {
+		period := AnchoredUsagePeriod(tt.anchor, tt.now)
+		if period.Key != tt.key || !period.Start.Equal(tt.start) || !period.End.Equal(tt.end) || !period.Anchored {
+			t.Errorf("%s: got %s %v - %v, want %s %v - %v", tt.name, period.Key, period.Start, period.End, tt.key, tt.start, tt.end)
+		}
+	}
+}
+
+func TestUsagePeriodForSubscription(t *testing.T) {
+	collection := core.NewBaseCollection("current_user_subscriptions")
+	collection.Fields.Add(
+		&core.TextField{Name: "provider_subscription_id"},
+		&core.DateField{Name: "current_period_start"},
+	)
+	now := time.Date(2025, time.March, 5, 0, 0, 0, 0, time.UTC)
+
+	free := core.NewRecord(collection)
+	free.Set("current_period_start", time.Date(2025, time.February, 20, 0, 0, 0, 0, time.UTC))
+	if period := UsagePeriodForSubscription(free, now); period.Anchored || period.Key != "2025-03" {
+		t.Errorf("subscriptions without a provider should use the calendar month, got %+v", period)
+	}
+
+	paid := core.NewRecord(collection)
+	paid.Set("provider_subscription_id", "sub_123")
+	paid.Set("current_period_start", time.Date(2025, time.February, 20, 0, 0, 0, 0, time.UTC))
+	if period := UsagePeriodForSubscription(paid, now); !period.Anchored || period.Key != "2025-02" {
+		t.Errorf("paid subscriptions should follow the billing period, got %+v", period)
+	}
+
+	if period := UsagePeriodForSubscription(nil, now); period.Anchored {
+		t.Errorf("no subscription should use the calendar month, got %+v", period)
+	}
+}
@@ -2,8 +2,11 @@ package subscription
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/pocketbase/pocketbase/core"
 	"github.com/stripe/stripe-go/v79"
 )
 
@@ -175,6 +178,48 @@ func (v *Validator) ValidatePlanChange(userID string, newPlanID string) []Valida
 	return errors
 }
 
+// Downgrade usage guard modes selected with the DOWNGRADE_USAGE_GUARD env variable
+const (
+	DowngradeGuardBlock = "block" // reject the downgrade (default)
+	DowngradeGuardWarn  = "warn"  // allow it and return the usage warning with the result
+)
+
+// DowngradeUsageGuardMode returns the configured downgrade usage guard mode
+func DowngradeUsageGuardMode() string {
+	if strings.ToLower(os.Getenv("DOWNGRADE_USAGE_GUARD")) == DowngradeGuardWarn {
+		return DowngradeGuardWarn
+	}
+	return DowngradeGuardBlock
+}
+
+// ValidateDowngradeUsage checks the user's usage this period against the target plan's hour limit
+// Returns nil when the change is safe or usage can't be determined
+func (v *Validator) ValidateDowngradeUsage(userID string, targetPlan *core.Record, now time.Time) *DowngradeUsageError {
+	hoursUsed, period, err := v.repo.GetPeriodHoursUsed(userID, now)
+	if err != nil {
+		return nil
+	}
+	return CheckDowngradeUsage(hoursUsed, targetPlan.GetFloat("hours_per_month"), period)
+}
+
+// CheckDowngradeUsage returns an error when hoursUsed already exceeds the target plan's monthly hours
+// Usage resets when the usage period ends, so that is the earliest safe date
+func CheckDowngradeUsage(hoursUsed, targetHours float64, period UsagePeriod) *DowngradeUsageError {
+	if hoursUsed <= targetHours {
+		return nil
+	}
+
+	hoursOver := hoursUsed - targetHours
+	return &DowngradeUsageError{
+		Code:             "DOWNGRADE_USAGE_EXCEEDED",
+		Message:          fmt.Sprintf("You have used %.2f hours this period, %.2f more than the %.2f hours included in the new plan", hoursUsed, hoursOver, targetHours),
+		HoursUsed:        hoursUsed,
+		TargetPlanHours:  targetHours,
+		HoursOver:        hoursOver,
+		EarliestSafeDate: period.End,
+	}
+}
+
 // ValidateStripeWebhookData validates Stripe webhook event data
 func (v *Validator) ValidateStripeWebhookData(data WebhookEventData) []ValidationError {
 	var errors []ValidationError
@@ -246,14 +291,19 @@ func (v *Validator) FixInvalidTimestamps(start, end time.Time) (time.Time, time.
 
 // MapStripeStatus maps Stripe subscription status to internal status
 func (v *Validator) MapStripeStatus(stripeStatus stripe.SubscriptionStatus) SubscriptionStatus {
-	switch stripeStatus {
-	case stripe.SubscriptionStatusActive:
+	return v.MapProviderStatus(string(stripeStatus))
+}
+
+// MapProviderStatus maps a payment provider's subscription status to internal status
+func (v *Validator) MapProviderStatus(status string) SubscriptionStatus {
+	switch status {
+	case "active":
 		return StatusActive
-	case stripe.SubscriptionStatusCanceled:
+	case "canceled":
 		return StatusCanceled
-	case stripe.SubscriptionStatusPastDue:
+	case "past_due":
 		return StatusPastDue
-	case stripe.SubscriptionStatusTrialing:
+	case "trialing":
 		return StatusTrialing
 	default:
 		return StatusActive // Default fallback
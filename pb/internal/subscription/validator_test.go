@@ -0,0 +1,39 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckDowngradeUsage(t *testing.T) {
+	period := CalendarUsagePeriod(time.Date(2025, time.December, 14, 9, 30, 0, 0, time.UTC))
+
+	t.Run("usage within target plan", func(t *testing.T) {
+		if err := CheckDowngradeUsage(4.5, 5, period); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("usage equal to target plan", func(t *testing.T) {
+		if err := CheckDowngradeUsage(5, 5, period); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("usage over target plan", func(t *testing.T) {
+		err := CheckDowngradeUsage(7.5, 5, period)
+		if err == nil {
+			t.Fatal("expected a usage error")
+		}
+		if err.Code != "DOWNGRADE_USAGE_EXCEEDED" {
+			t.Errorf("expected code DOWNGRADE_USAGE_EXCEEDED, got %s", err.Code)
+		}
+		if err.HoursOver != 2.5 {
+			t.Errorf("expected 2.5 hours over, got %v", err.HoursOver)
+		}
+		expected := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+		if !err.EarliestSafeDate.Equal(expected) {
+			t.Errorf("expected earliest safe date %v, got %v", expected, err.EarliestSafeDate)
+		}
+	})
+}
@@ -0,0 +1,237 @@
+// Package testharness runs tests against a real PocketBase app: a temp-dir database with every
+// migration applied, so handlers see the same collections, fields and rules as production.
+// Only imported from _test.go files
+package testharness
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tests"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"pocketbase/internal/plancatalog"
+	"pocketbase/internal/routes"
+	_ "pocketbase/migrations"
+)
+
+// New creates an app on an empty temp dir and runs the PocketBase and app migrations on it.
+// It's cleaned up when the test ends
+func New(t testing.TB) *tests.TestApp {
+	t.Helper()
+	app, err := tests.NewTestAppWithConfig(core.BaseAppConfig{
+		DataDir:       t.TempDir(),
+		EncryptionEnv: "pb_test_env",
+	})
+	if err != nil {
+		t.Fatalf("failed to start the test app: %v", err)
+	}
+	t.Cleanup(app.Cleanup)
+	return app
+}
+
+// SeedPlans creates the built-in catalog's subscription plans, without Stripe, and returns
+// them by catalog key. Paid plans get placeholder price IDs (price_<key>)
+func SeedPlans(t testing.TB, app core.App) map[string]*core.Record {
+	t.Helper()
+	catalog, err := plancatalog.Load()
+	if err != nil {
+		t.Fatalf("failed to load the plan catalog: %v", err)
+	}
+	report, err := plancatalog.Sync(context.Background(), app, catalog, plancatalog.Options{CreateOnly: true})
+	if err != nil {
+		t.Fatalf("failed to seed plans: %v", err)
+	}
+	if report.Failed() > 0 {
+		t.Fatalf("failed to seed %d plans", report.Failed())
+	}
+
+	plans := map[string]*core.Record{}
+	for _, plan := range catalog.Plans {
+		record, err := app.FindFirstRecordByData("subscription_plans", "catalog_key", plan.Key)
+		if err != nil {
+			t.Fatalf("seeded plan %s not found: %v", plan.Key, err)
+		}
+		plans[plan.Key] = record
+	}
+	return plans
+}
+
+// CreateUser creates a verified user
+func CreateUser(t testing.TB, app core.App, email string) *core.Record {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := core.NewRecord(collection)
+	user.Set("email", email)
+	user.Set("name", strings.Split(email, "@")[0])
+	user.SetPassword("password123")
+	user.Set("verified", true)
+	if err := app.Save(user); err != nil {
+		t.Fatalf("failed to create user %s: %v", email, err)
+	}
+	return user
+}
+
+// CreateSuperuser creates a superuser, for admin routes
+func CreateSuperuser(t testing.TB, app core.App, email string) *core.Record {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	superuser := core.NewRecord(collection)
+	superuser.Set("email", email)
+	superuser.SetPassword("password123")
+	if err := app.Save(superuser); err != nil {
+		t.Fatalf("failed to create superuser %s: %v", email, err)
+	}
+	return superuser
+}
+
+// CreateAPIKey gives the user an active API key with scopes (full access without any) and
+// returns the plaintext key
+func CreateAPIKey(t testing.TB, app core.App, user *core.Record, scopes ...string) string {
+	t.Helper()
+	collection, err := app.FindCollectionByNameOrId("api_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey := "ra-" + security.RandomStringWithAlphabet(32, "0123456789abcdef")
+	hash := sha256.Sum256([]byte(apiKey))
+
+	record := core.NewRecord(collection)
+	record.Set("key_hash", hex.EncodeToString(hash[:]))
+	record.Set("key_prefix", apiKey[:8])
+	record.Set("user_id", user.Id)
+	record.Set("active", true)
+	record.Set("name", "test key")
+	record.Set("scopes", scopes)
+	if err := app.Save(record); err != nil {
+		t.Fatalf("failed to create API key: %v", err)
+	}
+	return apiKey
+}
+
+// AuthToken returns a session token for a user or superuser, for the Authorization header
+func AuthToken(t testing.TB, record *core.Record) string {
+	t.Helper()
+	token, err := record.NewAuthToken()
+	if err != nil {
+		t.Fatalf("failed to create an auth token: %v", err)
+	}
+	return token
+}
+
+// Server serves the routes registered on it through PocketBase's own router, so auth tokens
+// are loaded and middleware runs as in production
+type Server struct {
+	handler http.Handler
+}
+
+// Serve builds a router with the routes added by each register function, e.g.
+//
+//	server := testharness.Serve(t, app, ai.RegisterRoutes)
+//
+// Rate limits and request metrics aren't applied
+func Serve(t testing.TB, app *tests.TestApp, register ...func(r *routes.Registry)) *Server {
+	t.Helper()
+	router, err := apis.NewRouter(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &core.ServeEvent{App: app, Router: router}
+	err = app.OnServe().Trigger(event, func(se *core.ServeEvent) error {
+		registry := routes.New(app, se.Router, routes.Options{RateLimit: func(e *core.RequestEvent) error {
+			return e.Next()
+		}})
+		for _, fn := range register {
+			fn(registry)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to register routes: %v", err)
+	}
+
+	mux, err := router.BuildMux()
+	if err != nil {
+		t.Fatalf("failed to build the router: %v", err)
+	}
+	return &Server{handler: mux}
+}
+
+// Response is a recorded response
+type Response struct {
+	*httptest.ResponseRecorder
+}
+
+// Do sends a request with a JSON body (nil for none). token, if set, goes in the
+// Authorization header as is for sessions or as a Bearer API key or delegated token (ra- or rd- prefix)
+func (s *Server) Do(t testing.TB, method, path, token string, body any) Response {
+	t.Helper()
+	return s.Send(NewRequest(t, method, path, token, body))
+}
+
+// NewRequest builds the request Do sends, for tests that need to change it, e.g. its headers,
+// before passing it to Send
+func NewRequest(t testing.TB, method, path, token string, body any) *http.Request {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	switch {
+	case strings.HasPrefix(token, "ra-"), strings.HasPrefix(token, "rd-"):
+		req.Header.Set("Authorization", "Bearer "+token)
+	case token != "":
+		req.Header.Set("Authorization", token)
+	}
+	return req
+}
+
+// Send serves a request
+func (s *Server) Send(req *http.Request) Response {
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return Response{rec}
+}
+
+// JSON decodes the body into a map, failing the test if it isn't a JSON object
+func (r Response) JSON(t testing.TB) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(r.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not a JSON object (%v): %s", err, r.Body.String())
+	}
+	return body
+}
+
+// Expect fails the test unless the response has status
+func (r Response) Expect(t testing.TB, status int) Response {
+	t.Helper()
+	if r.Code != status {
+		t.Fatalf("status = %d, want %d: %s", r.Code, status, r.Body.String())
+	}
+	return r
+}
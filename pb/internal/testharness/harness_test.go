@@ -0,0 +1,49 @@
+//go:build integration
+
+package testharness
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+func TestNewAppliesMigrations(t *testing.T) {
+	app := New(t)
+	for _, name := range []string{"users", "api_keys", "subscription_plans", "current_user_subscriptions", "sent_emails"} {
+		if _, err := app.FindCollectionByNameOrId(name); err != nil {
+			t.Errorf("collection %s missing: %v", name, err)
+		}
+	}
+
+	plans := SeedPlans(t, app)
+	if len(plans) != 5 {
+		t.Fatalf("seeded %d plans, want the 5 in the catalog", len(plans))
+	}
+	if price := plans["pro"].GetString("provider_price_id"); price != "price_pro" {
+		t.Errorf("pro price ID = %q, want the placeholder price_pro", price)
+	}
+}
+
+func TestServe(t *testing.T) {
+	app := New(t)
+	user := CreateUser(t, app, "harness@test.com")
+
+	server := Serve(t, app, func(r *routes.Registry) {
+		r.Add(routes.Route{Method: http.MethodGet, Path: "/api/whoami", Handler: func(e *core.RequestEvent) error {
+			if e.Auth == nil {
+				return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+			}
+			return e.JSON(http.StatusOK, map[string]string{"id": e.Auth.Id})
+		}})
+	})
+
+	server.Do(t, http.MethodGet, "/api/whoami", "", nil).Expect(t, http.StatusUnauthorized)
+	body := server.Do(t, http.MethodGet, "/api/whoami", AuthToken(t, user), nil).Expect(t, http.StatusOK).JSON(t)
+	if body["id"] != user.Id {
+		t.Errorf("authenticated as %v, want %s", body["id"], user.Id)
+	}
+}
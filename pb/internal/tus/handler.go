@@ -1,66 +1,34 @@
 package tus
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
+	"sync"
 
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/filesystem"
 	"github.com/tus/tusd/v2/pkg/handler"
+	"pocketbase/internal/accounts"
+	"pocketbase/internal/ai"
+	"pocketbase/internal/progress"
 )
 
+// maxUploadSize caps a single resumable upload; file_uploads.file allows the same size
+const maxUploadSize = 1024 * 1024 * 1024 // 1GB
+
 // TUSHandler wraps the TUS handler with PocketBase integration
 type TUSHandler struct {
 	handler *handler.Handler
+	store   *PocketBaseStore
 	app     core.App
-}
 
-// AudioProcessingResult represents the result of audio processing
-type AudioProcessingResult struct {
-	Transcript string    `json:"transcript"`
-	Duration   float64   `json:"duration,omitempty"`
-	Language   string    `json:"language,omitempty"`
-	Words      []Word    `json:"words,omitempty"`
-	Segments   []Segment `json:"segments,omitempty"`
-}
-
-// Word represents a word with timestamps
-type Word struct {
-	Word  string  `json:"word"`
-	Start float64 `json:"start"`
-	End   float64 `json:"end"`
-}
-
-// Segment represents a segment with timestamps
-type Segment struct {
-	ID               int     `json:"id"`
-	Seek             int     `json:"seek"`
-	Start            float64 `json:"start"`
-	End              float64 `json:"end"`
-	Text             string  `json:"text"`
-	Tokens           []int   `json:"tokens"`
-	Temperature      float64 `json:"temperature"`
-	AvgLogprob       float64 `json:"avg_logprob"`
-	CompressionRatio float64 `json:"compression_ratio"`
-	NoSpeechProb     float64 `json:"no_speech_prob"`
-	Words            []Word  `json:"words"`
-}
-
-// OpenAITranscriptionResponse represents the response from OpenAI transcription API
-type OpenAITranscriptionResponse struct {
-	Task     string    `json:"task"`
-	Language string    `json:"language"`
-	Duration float64   `json:"duration"`
-	Text     string    `json:"text"`
-	Segments []Segment `json:"segments"`
-	Words    []Word    `json:"words"`
+	// trackers follows each upload from its first byte to the end of post-processing, by upload id
+	trackers sync.Map
 }
 
 // NewTUSHandler creates a new TUS handler with PocketBase integration
@@ -83,9 +51,12 @@ func NewTUSHandler(app core.App) (*TUSHandler, error) {
 		StoreComposer:          composer,
 		NotifyCompleteUploads:  true,
 		NotifyTerminatedUploads: true,
-		NotifyUploadProgress:   true,
 		NotifyCreatedUploads:   true,
-		MaxSize:                1024 * 1024 * 1024, // 1GB max file size
+		NotifyUploadProgress:   true,
+		MaxSize:                maxUploadSize,
+		PreUploadCreateCallback: func(hook handler.HookEvent) (handler.HTTPResponse, handler.FileInfoChanges, error) {
+			return authorizeUploadCreate(store, hook)
+		},
 	}
 
 	tusHandler, err := handler.NewHandler(config)
@@ -99,6 +70,7 @@ func NewTUSHandler(app core.App) (*TUSHandler, error) {
 
 	h := &TUSHandler{
 		handler: tusHandler,
+		store:   store,
 		app:     app,
 	}
 
@@ -115,11 +87,14 @@ func (h *TUSHandler) setupHooks() {
 		for {
 			select {
 			case info := <-h.handler.CompleteUploads:
-				h.handleUploadComplete(info)
+				// Post-processing can take minutes, so it mustn't hold up other uploads' hooks
+				go h.handleUploadComplete(info)
 			case info := <-h.handler.TerminatedUploads:
 				h.handleUploadTerminated(info)
 			case info := <-h.handler.CreatedUploads:
 				h.handleUploadCreated(info)
+			case info := <-h.handler.UploadProgress:
+				h.tracker(info.Upload).Bytes(info.Upload.Offset, uploadSize(info.Upload))
 			}
 		}
 	}()
@@ -166,10 +141,46 @@ func (h *TUSHandler) handleUploadCreated(info handler.HookEvent) {
 	if err := h.app.Save(record); err != nil {
 		h.app.Logger().Error("Failed to create file upload record", "error", err)
 	}
+
+	h.tracker(info.Upload)
+}
+
+// tracker returns the progress tracker of an upload, resuming it after a restart. Partial
+// uploads are pieces of a final upload and aren't tracked on their own
+func (h *TUSHandler) tracker(upload handler.FileInfo) *progress.Tracker {
+	if upload.IsPartial {
+		return nil
+	}
+	if tracker, ok := h.trackers.Load(upload.ID); ok {
+		return tracker.(*progress.Tracker)
+	}
+	tracker := progress.Track(h.app, upload.MetaData["userId"], progress.SourceUpload, upload.ID,
+		upload.MetaData["filename"], progress.StageUploading, uploadSize(upload))
+	if tracker != nil {
+		h.trackers.Store(upload.ID, tracker)
+	}
+	return tracker
+}
+
+// uploadSize is the declared size of an upload, or 0 while it's deferred
+func uploadSize(upload handler.FileInfo) int64 {
+	if upload.SizeIsDeferred {
+		return 0
+	}
+	return upload.Size
 }
 
 // handleUploadComplete handles when an upload is completed
 func (h *TUSHandler) handleUploadComplete(info handler.HookEvent) {
+	// Partial uploads are only pieces of a final concatenated upload
+	if info.Upload.IsPartial {
+		return
+	}
+
+	tracker := h.tracker(info.Upload)
+	defer h.trackers.Delete(info.Upload.ID)
+	tracker.Bytes(info.Upload.Size, info.Upload.Size)
+
 	// Find the record by upload_id
 	record, err := h.app.FindFirstRecordByFilter(
 		"file_uploads",
@@ -178,6 +189,7 @@ func (h *TUSHandler) handleUploadComplete(info handler.HookEvent) {
 	)
 	if err != nil {
 		h.app.Logger().Error("Failed to find upload record", "error", err)
+		tracker.Fail("upload record is missing")
 		return
 	}
 
@@ -185,20 +197,24 @@ func (h *TUSHandler) handleUploadComplete(info handler.HookEvent) {
 	if err := h.moveFileToStorage(record, info.Upload); err != nil {
 		h.app.Logger().Error("Failed to move file to storage", "error", err)
 		record.Set("processing_status", "failed")
-	} else {
-		record.Set("processing_status", "completed")
-	}
-
-	if err := h.app.Save(record); err != nil {
-		h.app.Logger().Error("Failed to update upload record", "error", err)
+		record.Set("error_message", err.Error())
+		if err := h.app.Save(record); err != nil {
+			h.app.Logger().Error("Failed to update upload record", "error", err)
+		}
+		tracker.Fail(err.Error())
+		return
 	}
 
 	// Trigger post-processing if needed
-	h.triggerPostProcessing(record)
+	h.triggerPostProcessing(record, clientIP(info.HTTPRequest), tracker)
 }
 
 // handleUploadTerminated handles when an upload is terminated
 func (h *TUSHandler) handleUploadTerminated(info handler.HookEvent) {
+	if tracker, ok := h.trackers.LoadAndDelete(info.Upload.ID); ok {
+		tracker.(*progress.Tracker).Fail("upload was cancelled")
+	}
+
 	// Find and delete the record
 	record, err := h.app.FindFirstRecordByFilter(
 		"file_uploads",
@@ -212,67 +228,103 @@ func (h *TUSHandler) handleUploadTerminated(info handler.HookEvent) {
 	h.app.Delete(record)
 }
 
-// moveFileToStorage moves the completed upload to PocketBase file storage
+// moveFileToStorage attaches the completed upload to the record's file field, which copies it
+// into PocketBase file storage (local or S3), then removes the temporary TUS files
 func (h *TUSHandler) moveFileToStorage(record *core.Record, upload handler.FileInfo) error {
-	// Get upload file path
-	uploadPath := filepath.Join(h.app.DataDir(), "tus_uploads", upload.ID+".bin")
-	
-	// Open the upload file
-	file, err := os.Open(uploadPath)
+	uploadPath := h.store.getUploadPath(upload.ID)
+
+	file, err := filesystem.NewFileFromPath(uploadPath)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to read upload: %w", err)
 	}
-	defer file.Close()
-
-	// Get original filename from metadata
-	filename := "upload"
-	if upload.MetaData["filename"] != "" {
-		filename = upload.MetaData["filename"]
+	if name := record.GetString("original_name"); name != "" {
+		file.OriginalName = name
+	} else if name := upload.MetaData["filename"]; name != "" {
+		file.OriginalName = name
 	}
 
-	// For now, just store the filename - proper file storage integration
-	// would require more complex handling of the PocketBase filesystem
-	record.Set("file", filename)
+	record.Set("file", file)
+	record.Set("processing_status", "completed")
+	record.Set("error_message", "")
+	if err := h.app.Save(record); err != nil {
+		return fmt.Errorf("failed to store upload: %w", err)
+	}
 
-	// Clean up temp file
+	// The record owns the file now, so the temporary copy can go
 	os.Remove(uploadPath)
-	os.Remove(filepath.Join(h.app.DataDir(), "tus_uploads", upload.ID+".info"))
+	os.Remove(h.store.getInfoPath(upload.ID))
 
 	return nil
 }
 
 // triggerPostProcessing triggers any post-upload processing
-func (h *TUSHandler) triggerPostProcessing(record *core.Record) {
+func (h *TUSHandler) triggerPostProcessing(record *core.Record, clientIP string, tracker *progress.Tracker) {
 	// Parse metadata to check for processing instructions
-	metadataStr := record.GetString("metadata")
-	if metadataStr == "" {
-		return
+	var metadata map[string]string
+	if metadataStr := record.GetString("metadata"); metadataStr != "" {
+		json.Unmarshal([]byte(metadataStr), &metadata)
 	}
 
-	var metadata map[string]interface{}
-	if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
+	// Check for processing instructions
+	instructions := processingInstructions(metadata["processAfterUpload"])
+	if len(instructions) == 0 {
+		tracker.Complete()
 		return
 	}
 
-	// Check for processing instructions
-	if processAfterUpload, ok := metadata["processAfterUpload"].([]interface{}); ok {
-		record.Set("processing_status", "processing")
-		h.app.Save(record)
-
-		// Process each instruction
-		for _, instruction := range processAfterUpload {
-			if instructionStr, ok := instruction.(string); ok {
-				h.processFile(record, instructionStr)
-			}
+	record.Set("processing_status", "processing")
+	h.app.Save(record)
+
+	// Process each instruction; a failed instruction leaves the record failed
+	for i, instruction := range instructions {
+		if instruction == "transcribe_audio" {
+			tracker.Update(progress.StageTranscribing, 0)
+		} else {
+			tracker.Update(progress.StagePostProcessing, i*100/len(instructions))
+		}
+		if err := h.processFile(record, instruction, clientIP, tracker); err != nil {
+			h.app.Logger().Error("Post-processing failed", "instruction", instruction, "record_id", record.Id, "error", err)
+			record.Set("processing_status", "failed")
+			record.Set("error_message", err.Error())
+			h.app.Save(record)
+			tracker.Fail(err.Error())
+			return
+		}
+	}
+
+	record.Set("processing_status", "completed")
+	h.app.Save(record)
+	tracker.Complete()
+}
+
+// processingInstructions parses the processAfterUpload metadata value. TUS metadata values are
+// strings, so it's either a JSON array (["transcribe_audio"]) or a comma separated list
+func processingInstructions(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	var instructions []string
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &instructions); err != nil {
+			return nil
 		}
+	} else {
+		instructions = strings.Split(value, ",")
+	}
 
-		record.Set("processing_status", "completed")
-		h.app.Save(record)
+	result := make([]string, 0, len(instructions))
+	for _, instruction := range instructions {
+		if instruction = strings.TrimSpace(instruction); instruction != "" {
+			result = append(result, instruction)
+		}
 	}
+	return result
 }
 
 // processFile handles individual file processing instructions
-func (h *TUSHandler) processFile(record *core.Record, instruction string) error {
+func (h *TUSHandler) processFile(record *core.Record, instruction string, clientIP string, tracker *progress.Tracker) error {
 	// Get file from record
 	fileField := record.GetString("file")
 	if fileField == "" {
@@ -295,7 +347,7 @@ func (h *TUSHandler) processFile(record *core.Record, instruction string) error
 	case instruction == "extract_text":
 		return h.processTextExtraction(record, fileSystem)
 	case instruction == "transcribe_audio":
-		return h.processAudioTranscription(record)
+		return h.processAudioTranscription(record, fileSystem, clientIP, tracker)
 	default:
 		h.app.Logger().Warn("Unknown processing instruction", "instruction", instruction)
 	}
@@ -323,208 +375,178 @@ func (h *TUSHandler) processTextExtraction(record *core.Record, fs *filesystem.S
 	return nil
 }
 
-// processAudioTranscription transcribes audio files using OpenAI Whisper
-func (h *TUSHandler) processAudioTranscription(record *core.Record) error {
+// processAudioTranscription transcribes the stored audio through the AI usage pipeline, so the
+// upload is checked against the owner's monthly limit and counted like /api/ai/process-audio
+func (h *TUSHandler) processAudioTranscription(record *core.Record, fs *filesystem.System, clientIP string, tracker *progress.Tracker) error {
 	h.app.Logger().Info("Starting audio transcription", "record_id", record.Id)
-	
-	// Get upload ID and file path
-	uploadID := record.GetString("upload_id")
-	if uploadID == "" {
-		return fmt.Errorf("no upload ID found in record")
+
+	user, err := h.app.FindRecordById("users", record.GetString("user"))
+	if err != nil {
+		return fmt.Errorf("upload has no owner to bill the transcription to")
 	}
-	
-	// Get the uploaded file path
-	uploadPath := filepath.Join(h.app.DataDir(), "tus_uploads", uploadID+".bin")
-	
-	// Open the uploaded file
-	file, err := os.Open(uploadPath)
+
+	file, err := fs.GetReader(record.BaseFilesPath() + "/" + record.GetString("file"))
 	if err != nil {
 		return fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer file.Close()
-	
+
 	// Get filename from metadata
 	filename := record.GetString("original_name")
 	if filename == "" {
 		filename = "audio.mp3"
 	}
-	
-	// Call OpenAI Whisper API
-	result, err := h.transcribeWithOpenAI(file, filename)
+
+	// Uploads made with an organization's API key are charged to its pool
+	var metadata map[string]string
+	json.Unmarshal([]byte(record.GetString("metadata")), &metadata)
+
+	result, err := ai.TranscribeStoredAudio(context.Background(), h.app, user, ai.StoredAudio{
+		Reader:         file,
+		Filename:       filename,
+		SizeBytes:      file.Size(),
+		ClientIP:       clientIP,
+		OrganizationID: metadata["organizationId"],
+		Progress:       tracker,
+	})
 	if err != nil {
 		h.app.Logger().Error("Transcription failed", "error", err, "record_id", record.Id)
-		record.Set("processing_status", "failed")
-		record.Set("error_message", err.Error())
-		h.app.Save(record)
 		return err
 	}
-	
+
 	// Store transcription results in record
 	transcriptionJSON, _ := json.Marshal(result)
 	record.Set("transcription_result", string(transcriptionJSON))
 	record.Set("processing_status", "completed")
 	record.Set("transcript", result.Transcript)
-	
+
 	// Save updated record
 	if err := h.app.Save(record); err != nil {
 		h.app.Logger().Error("Failed to save transcription result", "error", err)
 		return err
 	}
-	
+
 	h.app.Logger().Info("Audio transcription completed", "record_id", record.Id, "transcript_length", len(result.Transcript))
-	
-	// Clean up uploaded file
-	os.Remove(uploadPath)
-	os.Remove(filepath.Join(h.app.DataDir(), "tus_uploads", uploadID+".info"))
-	
+
 	return nil
 }
 
-// transcribeWithOpenAI sends audio to OpenAI Whisper API
-func (h *TUSHandler) transcribeWithOpenAI(audioFile *os.File, filename string) (*AudioProcessingResult, error) {
-	// Get OpenAI API key from environment
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("OpenAI API key not configured")
-	}
-
-	// Create a pipe for streaming multipart data to OpenAI
-	pipeReader, pipeWriter := io.Pipe()
-	multipartWriter := multipart.NewWriter(pipeWriter)
-
-	// Start goroutine to write multipart data
-	go func() {
-		defer pipeWriter.Close()
-		defer multipartWriter.Close()
-
-		// Add file field - stream directly from input
-		fileWriter, err := multipartWriter.CreateFormFile("file", filepath.Base(filename))
-		if err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to create form file: %w", err))
-			return
-		}
+// ServeHTTP implements http.Handler
+func (h *TUSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.app.Logger().Info("TUS request", "method", r.Method, "path", r.URL.Path)
 
-		// Stream file contents directly from input to OpenAI
-		_, err = io.Copy(fileWriter, audioFile)
-		if err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to copy file: %w", err))
-			return
-		}
+	// Allow OPTIONS requests without authentication (needed for TUS protocol capabilities)
+	if r.Method == "OPTIONS" {
+		// Delegate to TUS handler for capability checks
+		h.handler.ServeHTTP(w, r)
+		return
+	}
 
-		// Add model field
-		if err := multipartWriter.WriteField("model", "whisper-1"); err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to write model field: %w", err))
-			return
-		}
+	// Authenticate request using PocketBase auth for other methods
+	user, orgID, err := h.authenticateRequest(r)
+	if err != nil {
+		h.app.Logger().Warn("TUS request rejected", "method", r.Method, "path", r.URL.Path, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Authentication required"))
+		return
+	}
 
-		// Add response format for verbose JSON with timestamps
-		if err := multipartWriter.WriteField("response_format", "verbose_json"); err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to write response_format field: %w", err))
+	// Uploads can only be resumed, read or terminated by their owner. Unknown ids fall
+	// through so tusd answers with its own 404
+	if id := uploadIDFromPath(r.URL.Path); id != "" {
+		if owner, ok := h.store.uploadOwner(id); ok && owner != user.Id {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("upload not found"))
 			return
 		}
+	}
 
-		// Add timestamp granularities for word-level timestamps
-		if err := multipartWriter.WriteField("timestamp_granularities[]", "word"); err != nil {
-			pipeWriter.CloseWithError(fmt.Errorf("failed to write timestamp_granularities field: %w", err))
-			return
-		}
-	}()
+	// Delegate to TUS handler with the user available to the creation callback
+	ctx := context.WithValue(r.Context(), uploadUserKey{}, user)
+	ctx = context.WithValue(ctx, uploadOrganizationKey{}, orgID)
+	h.handler.ServeHTTP(w, r.WithContext(ctx))
+}
 
-	// Create request with streaming body
-	req, err := http.NewRequest("POST", "https://api.openai.com/v1/audio/transcriptions", pipeReader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+// uploadUserKey keys the authenticated user in the request context
+type uploadUserKey struct{}
 
-	// Set headers
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", multipartWriter.FormDataContentType())
+// uploadOrganizationKey keys the organization charged by the request's API key, if any
+type uploadOrganizationKey struct{}
 
-	// Make request
-	client := &http.Client{Timeout: 120 * time.Second} // Longer timeout for large files
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+// authorizeUploadCreate runs before tusd creates an upload. The owner comes from the
+// authenticated request, never from the client's metadata, and a final upload can only
+// concatenate the caller's own partial uploads
+func authorizeUploadCreate(store *PocketBaseStore, hook handler.HookEvent) (handler.HTTPResponse, handler.FileInfoChanges, error) {
+	user, _ := hook.Context.Value(uploadUserKey{}).(*core.Record)
+	if user == nil {
+		return handler.HTTPResponse{}, handler.FileInfoChanges{}, handler.NewError("ERR_UNAUTHORIZED", "authentication required", http.StatusUnauthorized)
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	for _, partialID := range hook.Upload.PartialUploads {
+		if owner, ok := store.uploadOwner(partialID); !ok || owner != user.Id {
+			return handler.HTTPResponse{}, handler.FileInfoChanges{}, handler.ErrNotFound
+		}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI API error (status %d): %s", resp.StatusCode, string(body))
+	metadata := make(handler.MetaData, len(hook.Upload.MetaData)+1)
+	for key, value := range hook.Upload.MetaData {
+		metadata[key] = value
 	}
-
-	// Parse response
-	var transcriptionResp OpenAITranscriptionResponse
-	if err := json.Unmarshal(body, &transcriptionResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	metadata["userId"] = user.Id
+	delete(metadata, "organizationId")
+	if orgID, _ := hook.Context.Value(uploadOrganizationKey{}).(string); orgID != "" {
+		metadata["organizationId"] = orgID
 	}
 
-	return &AudioProcessingResult{
-		Transcript: transcriptionResp.Text,
-		Duration:   transcriptionResp.Duration,
-		Language:   transcriptionResp.Language,
-		Words:      transcriptionResp.Words,
-		Segments:   transcriptionResp.Segments,
-	}, nil
+	return handler.HTTPResponse{}, handler.FileInfoChanges{MetaData: metadata}, nil
 }
 
-// ServeHTTP implements http.Handler
-func (h *TUSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Log TUS requests for debugging
-	h.app.Logger().Info("TUS request", "method", r.Method, "path", r.URL.Path, "headers", r.Header)
-	
-	// Add CORS headers
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE, HEAD, PATCH")
-	w.Header().Set("Access-Control-Allow-Headers", "Authorization, Origin, X-Requested-With, Content-Type, Upload-Length, Upload-Offset, Tus-Resumable, Upload-Metadata, Upload-Defer-Length, Upload-Concat")
-	w.Header().Set("Access-Control-Expose-Headers", "Upload-Offset, Location, Upload-Length, Tus-Version, Tus-Resumable, Tus-Max-Size, Tus-Extension, Upload-Metadata, Upload-Defer-Length, Upload-Concat")
-
-	// Allow OPTIONS requests without authentication (needed for TUS protocol capabilities)
-	if r.Method == "OPTIONS" {
-		// Delegate to TUS handler for capability checks
-		h.handler.ServeHTTP(w, r)
-		return
+// uploadIDFromPath returns the upload id addressed by a path under /api/tus, or "" for the
+// creation endpoint
+func uploadIDFromPath(path string) string {
+	path = strings.Trim(strings.TrimPrefix(path, "/api/tus"), "/")
+	if path == "" {
+		return ""
 	}
+	return path[strings.LastIndex(path, "/")+1:]
+}
 
-	// Authenticate request using PocketBase auth for other methods
-	if !h.authenticateRequest(r) {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Authentication required"))
-		return
+// clientIP returns the caller's address from the request that completed the upload
+func clientIP(r handler.HTTPRequest) string {
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		return realIP
 	}
-
-	// Delegate to TUS handler
-	h.handler.ServeHTTP(w, r)
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return r.RemoteAddr
 }
 
-// authenticateRequest validates the request has valid PocketBase authentication
-func (h *TUSHandler) authenticateRequest(r *http.Request) bool {
-	// Extract auth token from Authorization header
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return false
+// authenticateRequest resolves the user behind the Authorization header, which carries either
+// a PocketBase auth token for a user (with or without the "Bearer " prefix), an API key or a delegated token.
+// The second value is the organization an API key charges, if any
+func (h *TUSHandler) authenticateRequest(r *http.Request) (*core.Record, string, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" {
+		return nil, "", fmt.Errorf("missing Authorization header")
 	}
 
-	// Remove "Bearer " prefix
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == authHeader {
-		return false
+	if strings.HasPrefix(token, apiKeyPrefix) || strings.HasPrefix(token, ai.DelegatedTokenPrefix) {
+		return ai.UserForAPIKey(h.app, token, r, ai.ScopeAIAudio)
 	}
 
-	// Validate token with PocketBase - simple validation for now
-	// In a real implementation, you'd want to properly validate the JWT token
-	if len(token) < 10 {
-		return false
+	user, err := h.app.FindAuthRecordByToken(token, core.TokenTypeAuth)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid auth token: %w", err)
 	}
-	
-	// For now, we'll assume the token is valid if it's present
-	// You should implement proper JWT validation here
-
-	return true
+	if user.Collection().Name != "users" {
+		return nil, "", fmt.Errorf("uploads need a user token, not %s", user.Collection().Name)
+	}
+	if accounts.IsDeactivated(user) {
+		return nil, "", fmt.Errorf("account is deactivated")
+	}
+	return user, "", nil
 }
 
+// apiKeyPrefix marks the API keys generated in internal/ai
+const apiKeyPrefix = "ra-"
@@ -0,0 +1,120 @@
+package tus
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/tus/tusd/v2/pkg/handler"
+)
+
+func TestUploadInfoRoundTrip(t *testing.T) {
+	store := &PocketBaseStore{}
+	path := filepath.Join(t.TempDir(), "upload.info")
+	info := handler.FileInfo{
+		ID:       "abc123",
+		Size:     4096,
+		Offset:   1024,
+		MetaData: handler.MetaData{"filename": "talk \"final\".mp3", "processAfterUpload": "transcribe_audio"},
+	}
+
+	if err := store.writeInfo(path, info); err != nil {
+		t.Fatalf("writeInfo: %v", err)
+	}
+	got, err := store.readInfo(path)
+	if err != nil {
+		t.Fatalf("readInfo: %v", err)
+	}
+	if !reflect.DeepEqual(got, info) {
+		t.Errorf("readInfo = %+v, want %+v", got, info)
+	}
+
+	if _, err := store.readInfo(filepath.Join(t.TempDir(), "missing.info")); err == nil || err.Error() != handler.ErrNotFound.Error() {
+		t.Errorf("missing info: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestProcessingInstructions(t *testing.T) {
+	tests := map[string][]string{
+		"":                                nil,
+		"transcribe_audio":                {"transcribe_audio"},
+		"thumbnail, transcribe_audio":     {"thumbnail", "transcribe_audio"},
+		`["resize:200x200", "thumbnail"]`: {"resize:200x200", "thumbnail"},
+		`["broken"`:                       nil,
+	}
+	for value, want := range tests {
+		got := processingInstructions(value)
+		if len(got) == 0 && len(want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("processingInstructions(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	forwarded := handler.HTTPRequest{RemoteAddr: "10.0.0.1:5000", Header: http.Header{"X-Forwarded-For": {"203.0.113.9, 10.0.0.1"}}}
+	if got := clientIP(forwarded); got != "203.0.113.9" {
+		t.Errorf("clientIP = %s, want the first forwarded address", got)
+	}
+	direct := handler.HTTPRequest{RemoteAddr: "10.0.0.1:5000", Header: http.Header{}}
+	if got := clientIP(direct); got != "10.0.0.1:5000" {
+		t.Errorf("clientIP = %s, want the remote address", got)
+	}
+}
+
+func TestUploadIDFromPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/tus":         "",
+		"/api/tus/":        "",
+		"/api/tus/abc123":  "abc123",
+		"/api/tus/abc123/": "abc123",
+		"/abc123":          "abc123",
+	}
+	for path, want := range tests {
+		if got := uploadIDFromPath(path); got != want {
+			t.Errorf("uploadIDFromPath(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestAuthorizeUploadCreate(t *testing.T) {
+	store := &PocketBaseStore{}
+	upload := handler.FileInfo{MetaData: handler.MetaData{"filename": "talk.mp3", "userId": "someone-else"}}
+
+	if _, _, err := authorizeUploadCreate(store, handler.HookEvent{Context: context.Background(), Upload: upload}); err == nil {
+		t.Fatal("expected unauthenticated uploads to be rejected")
+	}
+
+	user := core.NewRecord(core.NewAuthCollection("users"))
+	user.Id = "user123"
+	ctx := context.WithValue(context.Background(), uploadUserKey{}, user)
+	_, changes, err := authorizeUploadCreate(store, handler.HookEvent{Context: ctx, Upload: upload})
+	if err != nil {
+		t.Fatalf("authorizeUploadCreate: %v", err)
+	}
+	if changes.MetaData["userId"] != "user123" || changes.MetaData["filename"] != "talk.mp3" {
+		t.Errorf("metadata = %v, want the authenticated user as owner", changes.MetaData)
+	}
+	if upload.MetaData["userId"] != "someone-else" {
+		t.Error("the client's metadata shouldn't be modified in place")
+	}
+	if _, ok := changes.MetaData["organizationId"]; ok {
+		t.Errorf("metadata = %v, want no organization without an organization key", changes.MetaData)
+	}
+
+	// The organization comes from the API key, never from the client
+	upload.MetaData["organizationId"] = "spoofed"
+	ctx = context.WithValue(ctx, uploadOrganizationKey{}, "org123")
+	_, changes, err = authorizeUploadCreate(store, handler.HookEvent{Context: ctx, Upload: upload})
+	if err != nil {
+		t.Fatalf("authorizeUploadCreate: %v", err)
+	}
+	if changes.MetaData["organizationId"] != "org123" {
+		t.Errorf("metadata = %v, want the key's organization", changes.MetaData)
+	}
+}
@@ -2,12 +2,13 @@ package tus
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
 	"github.com/tus/tusd/v2/pkg/handler"
 )
 
@@ -25,6 +26,10 @@ func NewPocketBaseStore(app core.App) *PocketBaseStore {
 
 // NewUpload creates a new upload and returns its upload id
 func (store *PocketBaseStore) NewUpload(ctx context.Context, info handler.FileInfo) (handler.Upload, error) {
+	// tusd leaves choosing the id to the store
+	if info.ID == "" {
+		info.ID = security.RandomString(32)
+	}
 	id := info.ID
 	
 	// Log the creation for debugging
@@ -93,6 +98,15 @@ func (store *PocketBaseStore) AsConcatableUpload(upload handler.Upload) handler.
 	return upload.(*PocketBaseUpload)
 }
 
+// uploadOwner returns the id of the user who created an upload that's still in progress
+func (store *PocketBaseStore) uploadOwner(id string) (string, bool) {
+	info, err := store.readInfo(store.getInfoPath(id))
+	if err != nil {
+		return "", false
+	}
+	return info.MetaData["userId"], true
+}
+
 // getUploadPath returns the file path for storing the upload data
 func (store *PocketBaseStore) getUploadPath(id string) string {
 	// Store uploads in pb_data/tus_uploads/
@@ -106,84 +120,29 @@ func (store *PocketBaseStore) getInfoPath(id string) string {
 
 // writeInfo writes upload info to file
 func (store *PocketBaseStore) writeInfo(path string, info handler.FileInfo) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	data, err := json.Marshal(info)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	
-	// Simple JSON-like format for storing file info
-	content := fmt.Sprintf(`{
-		"ID": "%s",
-		"Size": %d,
-		"Offset": %d,
-		"MetaData": %q,
-		"IsPartial": %t,
-		"IsFinal": %t,
-		"PartialUploads": %q
-	}`, info.ID, info.Size, info.Offset, formatMetadata(info.MetaData), 
-		info.IsPartial, info.IsFinal, formatPartialUploads(info.PartialUploads))
-	
-	_, err = file.WriteString(content)
-	return err
+	return os.WriteFile(path, data, 0644)
 }
 
 // readInfo reads upload info from file
 func (store *PocketBaseStore) readInfo(path string) (handler.FileInfo, error) {
 	var info handler.FileInfo
-	
-	// Check if file exists
-	if _, err := os.Stat(path); os.IsNotExist(err) {
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
 		return info, handler.ErrNotFound
 	}
-	
-	file, err := os.Open(path)
 	if err != nil {
 		return info, err
 	}
-	defer file.Close()
-	
-	// Read and parse the info (simplified parsing)
-	content, err := io.ReadAll(file)
-	if err != nil {
-		return info, err
-	}
-	
-	// For simplicity, we'll parse basic info
-	// In production, you might want to use proper JSON parsing
-	info.ID = extractValue(string(content), "ID")
-	
-	return info, nil
-}
-
-// Helper functions for formatting metadata
-func formatMetadata(meta map[string]string) string {
-	result := "{"
-	for k, v := range meta {
-		result += fmt.Sprintf(`"%s":"%s",`, k, v)
-	}
-	if len(meta) > 0 {
-		result = result[:len(result)-1] // Remove trailing comma
-	}
-	result += "}"
-	return result
-}
 
-func formatPartialUploads(uploads []string) string {
-	result := "["
-	for i, upload := range uploads {
-		if i > 0 {
-			result += ","
-		}
-		result += fmt.Sprintf(`"%s"`, upload)
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("failed to parse upload info: %w", err)
 	}
-	result += "]"
-	return result
-}
-
-func extractValue(content, key string) string {
-	// Simplified extraction - in production use proper JSON parsing
-	return ""
+	return info, nil
 }
 
 // UseIn implements the store interface for TUS composer
@@ -0,0 +1,39 @@
+package tus
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/cors"
+	"pocketbase/internal/routes"
+)
+
+// corsOverride lets browsers send and read the TUS protocol headers
+var corsOverride = &cors.Override{
+	AllowHeaders:  []string{"Origin", "Upload-Length", "Upload-Offset", "Tus-Resumable", "Upload-Metadata", "Upload-Defer-Length", "Upload-Concat"},
+	ExposeHeaders: []string{"Upload-Offset", "Location", "Upload-Length", "Tus-Version", "Tus-Resumable", "Tus-Max-Size", "Tus-Extension", "Upload-Metadata", "Upload-Defer-Length", "Upload-Concat"},
+}
+
+// RegisterRoutes mounts the TUS resumable upload protocol under /api/tus. The handler
+// authenticates requests itself, and the body limit leaves room for a whole upload in one PATCH
+func RegisterRoutes(r *routes.Registry) {
+	h, err := NewTUSHandler(r.App)
+	if err != nil {
+		log.Printf("Warning: TUS uploads disabled: %v", err)
+		return
+	}
+
+	// tusd routes relative to its base path: "" creates uploads, "/{id}" addresses one
+	serve := http.StripPrefix("/api/tus", h)
+	handle := func(e *core.RequestEvent) error {
+		serve.ServeHTTP(e.Response, e.Request)
+		return nil
+	}
+
+	r.Add(
+		routes.Route{Path: "/api/tus", Handler: handle, BodyLimit: maxUploadSize, CORS: corsOverride, Unversioned: true},
+		routes.Route{Path: "/api/tus/{path...}", Handler: handle, BodyLimit: maxUploadSize, CORS: corsOverride, Unversioned: true},
+	)
+}
@@ -0,0 +1,29 @@
+package tus
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the TUS upload handler reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "tus",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "file_uploads",
+				Fields: []schemacheck.Field{
+					{Name: "file", Type: "file"},
+					{Name: "upload_id", Type: "text"},
+					{Name: "processing_status", Type: "select", Values: []string{"pending", "processing", "completed", "failed"}},
+					{Name: "original_name", Type: "text"},
+					{Name: "file_type", Type: "text"},
+					{Name: "category", Type: "text"},
+					{Name: "user", Type: "relation"},
+					{Name: "visibility", Type: "select", Values: []string{"public", "private"}},
+					{Name: "metadata", Type: "json"},
+					{Name: "error_message", Type: "text"},
+					{Name: "transcript", Type: "text"},
+					{Name: "transcription_result", Type: "json"},
+				},
+			},
+		},
+	}
+}
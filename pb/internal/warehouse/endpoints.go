@@ -0,0 +1,49 @@
+package warehouse
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ExportRequest represents an admin request to export (or backfill) one day
+type ExportRequest struct {
+	Date string `json:"date"` // YYYY-MM-DD, defaults to yesterday (UTC)
+}
+
+// ExportHandler runs the warehouse export on demand (superusers only)
+// POST /api/admin/warehouse/export
+func ExportHandler(e *core.RequestEvent, app core.App) error {
+	var req ExportRequest
+	if e.Request.ContentLength > 0 {
+		if err := e.BindBody(&req); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		}
+	}
+
+	day := time.Now().UTC().AddDate(0, 0, -1)
+	if req.Date != "" {
+		parsed, err := time.Parse("2006-01-02", req.Date)
+		if err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "date must be formatted as YYYY-MM-DD"})
+		}
+		day = parsed
+	}
+
+	sink, err := SinkFromEnv(app)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if sink == nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "No warehouse sink configured (set WAREHOUSE_SINK)"})
+	}
+
+	report, err := Export(e.Request.Context(), app, sink, day)
+	if err != nil {
+		return e.JSON(http.StatusBadGateway, map[string]any{"error": fmt.Sprintf("Warehouse export failed: %v", err), "report": report})
+	}
+
+	return e.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,210 @@
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// Exported datasets - each becomes a table (or partitioned directory) in the warehouse
+const (
+	DatasetSubscriptions = "subscriptions"
+	DatasetMonthlyUsage  = "monthly_usage"
+	DatasetAIProcessing  = "ai_processing_logs"
+)
+
+// exportBatchSize bounds how many records are loaded per query
+const exportBatchSize = 500
+
+// dbTimeLayout matches how PocketBase stores datetimes, for filter comparisons
+const dbTimeLayout = "2006-01-02 15:04:05.000Z"
+
+// Report summarizes one export run
+type Report struct {
+	Day      string         `json:"day"`
+	Sink     string         `json:"sink"`
+	Datasets map[string]int `json:"datasets"` // rows written per dataset
+	Errors   []string       `json:"errors,omitempty"`
+	Duration string         `json:"duration"`
+}
+
+// Export writes the snapshot for one UTC day to the sink:
+//   - subscriptions: every current subscription as of the export, with its plan's name and price
+//   - monthly_usage: usage totals for the month the day falls in
+//   - ai_processing_logs: audio processing records created that day (without filenames or IPs)
+//
+// A failing dataset doesn't stop the others; the returned error lists every failure
+func Export(ctx context.Context, app core.App, sink Sink, day time.Time) (Report, error) {
+	startTime := time.Now()
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	report := Report{Day: day.Format("2006-01-02"), Sink: sink.Name(), Datasets: map[string]int{}}
+
+	exporters := []struct {
+		dataset string
+		rows    func(core.App, time.Time) ([]Row, error)
+	}{
+		{DatasetSubscriptions, subscriptionRows},
+		{DatasetMonthlyUsage, monthlyUsageRows},
+		{DatasetAIProcessing, aiProcessingRows},
+	}
+
+	for _, exporter := range exporters {
+		rows, err := exporter.rows(app, day)
+		if err == nil {
+			for _, row := range rows {
+				row["snapshot_date"] = report.Day
+			}
+			err = sink.Write(ctx, exporter.dataset, day, rows)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", exporter.dataset, err))
+			continue
+		}
+		report.Datasets[exporter.dataset] = len(rows)
+	}
+
+	report.Duration = time.Since(startTime).String()
+	if len(report.Errors) > 0 {
+		return report, fmt.Errorf("warehouse export failed for %s", strings.Join(report.Errors, "; "))
+	}
+	return report, nil
+}
+
+// RunScheduled exports yesterday's snapshot when a sink is configured
+func RunScheduled(app core.App) {
+	sink, err := SinkFromEnv(app)
+	if err != nil {
+		log.Printf("[WAREHOUSE] ERROR: %v", err)
+		return
+	}
+	if sink == nil {
+		return
+	}
+
+	day := time.Now().UTC().AddDate(0, 0, -1)
+	report, err := Export(context.Background(), app, sink, day)
+	if err != nil {
+		log.Printf("[WAREHOUSE] ERROR: %v", err)
+	}
+	log.Printf("[WAREHOUSE] Exported %s to %s: %v (took %s)", report.Day, report.Sink, report.Datasets, report.Duration)
+}
+
+func subscriptionRows(app core.App, day time.Time) ([]Row, error) {
+	records, err := findAllRecords(app, "current_user_subscriptions", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := subscription.NewRepository(app)
+	rows := make([]Row, 0, len(records))
+	for _, record := range records {
+		row := Row{
+			"id":                       record.Id,
+			"user_id":                  record.GetString("user_id"),
+			"plan_id":                  record.GetString("plan_id"),
+			"status":                   record.GetString("status"),
+			"payment_provider":         record.GetString("payment_provider"),
+			"provider_subscription_id": record.GetString("provider_subscription_id"),
+			"current_period_start":     dateValue(record, "current_period_start"),
+			"current_period_end":       dateValue(record, "current_period_end"),
+			"canceled_at":              dateValue(record, "canceled_at"),
+			"past_due_since":           dateValue(record, "past_due_since"),
+			"created":                  dateValue(record, "created"),
+			"updated":                  dateValue(record, "updated"),
+		}
+		if plan, err := repo.GetPlan(record.GetString("plan_id")); err == nil {
+			row["plan_name"] = plan.GetString("name")
+			row["price_cents"] = plan.GetInt("price_cents")
+			row["currency"] = plan.GetString("currency")
+			row["billing_interval"] = plan.GetString("billing_interval")
+			row["hours_per_month"] = plan.GetFloat("hours_per_month")
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func monthlyUsageRows(app core.App, day time.Time) ([]Row, error) {
+	records, err := findAllRecords(app, "monthly_usage", "year_month = {:month}", map[string]any{"month": day.Format("2006-01")})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, Row{
+			"id":                   record.Id,
+			"user_id":              record.GetString("user_id"),
+			"year_month":           record.GetString("year_month"),
+			"period_start":         dateValue(record, "period_start"),
+			"period_end":           dateValue(record, "period_end"),
+			"hours_used":           record.GetFloat("hours_used"),
+			"files_processed":      record.GetInt("files_processed"),
+			"last_processing_date": dateValue(record, "last_processing_date"),
+			"burst_used":           record.GetBool("burst_used"),
+			"burst_hours":          record.GetFloat("burst_hours"),
+			"tokens_used":          record.GetInt("tokens_used"),
+		})
+	}
+	return rows, nil
+}
+
+func aiProcessingRows(app core.App, day time.Time) ([]Row, error) {
+	records, err := findAllRecords(app, "processed_files", "created >= {:start} && created < {:end}", map[string]any{
+		"start": day.Format(dbTimeLayout),
+		"end":   day.AddDate(0, 0, 1).Format(dbTimeLayout),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, 0, len(records))
+	for _, record := range records {
+		rows = append(rows, Row{
+			"id":                 record.Id,
+			"user_id":            record.GetString("user_id"),
+			"status":             record.GetString("status"),
+			"model_used":         record.GetString("model_used"),
+			"duration_seconds":   record.GetFloat("duration_seconds"),
+			"file_size_bytes":    record.GetInt("file_size_bytes"),
+			"processing_time_ms": record.GetInt("processing_time_ms"),
+			"transcript_length":  record.GetInt("transcript_length"),
+			"words_count":        record.GetInt("words_count"),
+			"is_chunk":           record.GetBool("is_chunk"),
+			"chunk_index":        record.GetInt("chunk_index"),
+			"processing_count":   record.GetInt("processing_count"),
+			"created":            dateValue(record, "created"),
+		})
+	}
+	return rows, nil
+}
+
+// findAllRecords loads every matching record in batches, ordered by id
+func findAllRecords(app core.App, collection, filter string, params map[string]any) ([]*core.Record, error) {
+	var result []*core.Record
+	for offset := 0; ; offset += exportBatchSize {
+		batch, err := app.FindRecordsByFilter(collection, filter, "id", exportBatchSize, offset, params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", collection, err)
+		}
+		result = append(result, batch...)
+		if len(batch) < exportBatchSize {
+			return result, nil
+		}
+	}
+}
+
+// dateValue returns a datetime field as RFC 3339, or nil when unset
+func dateValue(record *core.Record, field string) any {
+	value := record.GetDateTime(field)
+	if value.IsZero() {
+		return nil
+	}
+	return value.Time().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,20 @@
+package warehouse
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds the route that runs or backfills the daily data warehouse export (superusers only)
+func RegisterRoutes(r *routes.Registry) {
+	app := r.App
+
+	r.Add(
+		routes.Route{Method: http.MethodPost, Path: "/api/admin/warehouse/export", Auth: routes.AuthSuperuser, Handler: func(e *core.RequestEvent) error {
+			return ExportHandler(e, app)
+		}},
+	)
+}
@@ -0,0 +1,40 @@
+package warehouse
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields the warehouse export reads
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "warehouse",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "current_user_subscriptions",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "plan_id", Type: "relation"},
+					{Name: "status", Type: "select"},
+					{Name: "current_period_start", Type: "date"},
+					{Name: "current_period_end", Type: "date"},
+					{Name: "canceled_at", Type: "date"},
+					{Name: "past_due_since", Type: "date"},
+				},
+			},
+			{
+				Collection: "monthly_usage",
+				Fields: []schemacheck.Field{
+					{Name: "year_month", Type: "text"},
+					{Name: "hours_used", Type: "number"},
+					{Name: "files_processed", Type: "number"},
+				},
+			},
+			{
+				Collection: "processed_files",
+				Fields: []schemacheck.Field{
+					{Name: "duration_seconds", Type: "number"},
+					{Name: "processing_time_ms", Type: "number"},
+					{Name: "model_used", Type: "text"},
+				},
+			},
+		},
+	}
+}
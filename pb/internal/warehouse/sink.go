@@ -0,0 +1,165 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Row is one exported record, keyed by column name
+type Row map[string]any
+
+// Sink receives one day's snapshot of a dataset
+// Writes for the same dataset and day may be repeated (re-runs, backfills) and should replace
+// or deduplicate the earlier copy - rows carry snapshot_date and id for that
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, dataset string, day time.Time, rows []Row) error
+}
+
+// SinkFromEnv builds the sink selected by WAREHOUSE_SINK, or nil when exports are disabled
+//   - filesystem: gzipped NDJSON in the app's file storage (local or the configured S3 bucket),
+//     partitioned as warehouse/<dataset>/dt=YYYY-MM-DD/ for BigQuery external tables, Athena or ClickHouse s3()
+//   - clickhouse: inserts over the ClickHouse HTTP interface (CLICKHOUSE_URL, CLICKHOUSE_DATABASE,
+//     CLICKHOUSE_USER, CLICKHOUSE_PASSWORD)
+func SinkFromEnv(app core.App) (Sink, error) {
+	switch strings.ToLower(os.Getenv("WAREHOUSE_SINK")) {
+	case "":
+		return nil, nil
+	case "filesystem":
+		return &FilesystemSink{app: app, prefix: "warehouse"}, nil
+	case "clickhouse":
+		endpoint := os.Getenv("CLICKHOUSE_URL")
+		if endpoint == "" {
+			return nil, fmt.Errorf("CLICKHOUSE_URL is required for the clickhouse sink")
+		}
+		database := os.Getenv("CLICKHOUSE_DATABASE")
+		if database == "" {
+			database = "default"
+		}
+		return &ClickHouseSink{
+			Endpoint: strings.TrimRight(endpoint, "/"),
+			Database: database,
+			User:     os.Getenv("CLICKHOUSE_USER"),
+			Password: os.Getenv("CLICKHOUSE_PASSWORD"),
+			Client:   &http.Client{Timeout: 2 * time.Minute},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown WAREHOUSE_SINK %q (expected filesystem or clickhouse)", os.Getenv("WAREHOUSE_SINK"))
+	}
+}
+
+// FilesystemSink writes gzipped NDJSON files through the app's filesystem
+type FilesystemSink struct {
+	app    core.App
+	prefix string
+}
+
+func (s *FilesystemSink) Name() string { return "filesystem" }
+
+// Write stores the snapshot at a fixed key per dataset and day, so re-runs overwrite it
+func (s *FilesystemSink) Write(ctx context.Context, dataset string, day time.Time, rows []Row) error {
+	content, err := encodeNDJSON(rows, true)
+	if err != nil {
+		return err
+	}
+
+	fsys, err := s.app.NewFilesystem()
+	if err != nil {
+		return fmt.Errorf("failed to open file storage: %w", err)
+	}
+	defer fsys.Close()
+
+	return fsys.Upload(content, partitionKey(s.prefix, dataset, day))
+}
+
+// ClickHouseSink inserts rows into <database>.<dataset> with FORMAT JSONEachRow
+// Tables are expected to use ReplacingMergeTree ordered by (snapshot_date, id) so re-runs deduplicate
+type ClickHouseSink struct {
+	Endpoint string
+	Database string
+	User     string
+	Password string
+	Client   *http.Client
+}
+
+func (s *ClickHouseSink) Name() string { return "clickhouse" }
+
+func (s *ClickHouseSink) Write(ctx context.Context, dataset string, day time.Time, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	content, err := encodeNDJSON(rows, true)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.insertURL(dataset), bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	if s.User != "" {
+		req.Header.Set("X-ClickHouse-User", s.User)
+		req.Header.Set("X-ClickHouse-Key", s.Password)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("clickhouse insert into %s failed: %w", dataset, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse insert into %s failed with status %d: %s", dataset, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *ClickHouseSink) insertURL(dataset string) string {
+	query := fmt.Sprintf("INSERT INTO `%s`.`%s` FORMAT JSONEachRow", s.Database, dataset)
+	return s.Endpoint + "/?" + url.Values{"query": {query}}.Encode()
+}
+
+// partitionKey returns the Hive-style object key for a dataset's daily snapshot
+func partitionKey(prefix, dataset string, day time.Time) string {
+	return fmt.Sprintf("%s/%s/dt=%s/part-0.ndjson.gz", prefix, dataset, day.Format("2006-01-02"))
+}
+
+// encodeNDJSON writes one JSON object per line, optionally gzipped
+func encodeNDJSON(rows []Row, compress bool) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(&buf)
+		w = zw
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return nil, fmt.Errorf("failed to encode row: %w", err)
+		}
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
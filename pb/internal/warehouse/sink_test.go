@@ -0,0 +1,81 @@
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeNDJSON(t *testing.T) {
+	content, err := encodeNDJSON([]Row{{"id": "a", "hours_used": 1.5}, {"id": "b", "canceled_at": nil}}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := io.ReadAll(zr)
+
+	want := "{\"hours_used\":1.5,\"id\":\"a\"}\n{\"canceled_at\":null,\"id\":\"b\"}\n"
+	if string(plain) != want {
+		t.Fatalf("got %q, want %q", plain, want)
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	day := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	if got := partitionKey("warehouse", DatasetMonthlyUsage, day); got != "warehouse/monthly_usage/dt=2026-03-09/part-0.ndjson.gz" {
+		t.Fatalf("unexpected key %q", got)
+	}
+}
+
+func TestClickHouseSinkWrite(t *testing.T) {
+	var gotQuery, gotUser string
+	var gotRows []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.Query().Get("query")
+		gotUser = r.Header.Get("X-ClickHouse-User")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body not gzipped: %v", err)
+			return
+		}
+		plain, _ := io.ReadAll(zr)
+		gotRows = strings.Split(strings.TrimSpace(string(plain)), "\n")
+	}))
+	defer server.Close()
+
+	sink := &ClickHouseSink{Endpoint: server.URL, Database: "analytics", User: "exporter", Client: server.Client()}
+	err := sink.Write(context.Background(), DatasetSubscriptions, time.Now(), []Row{{"id": "a"}, {"id": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotQuery != "INSERT INTO `analytics`.`subscriptions` FORMAT JSONEachRow" {
+		t.Fatalf("unexpected query %q", gotQuery)
+	}
+	if gotUser != "exporter" || len(gotRows) != 2 {
+		t.Fatalf("unexpected request: user %q, rows %v", gotUser, gotRows)
+	}
+}
+
+func TestClickHouseSinkReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Code: 60. Table analytics.subscriptions does not exist", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	sink := &ClickHouseSink{Endpoint: server.URL, Database: "analytics", Client: server.Client()}
+	err := sink.Write(context.Background(), DatasetSubscriptions, time.Now(), []Row{{"id": "a"}})
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("expected table error, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/apisign"
+	"pocketbase/internal/communications"
+	"pocketbase/internal/secrets"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEventID   = "X-Webhook-Id"        // the event id - the same on every retry, for deduplication
+	HeaderEvent     = "X-Webhook-Event"     // the event type
+	HeaderTimestamp = "X-Webhook-Timestamp" // unix seconds
+	HeaderSignature = "X-Webhook-Signature" // hex HMAC-SHA256 of "<timestamp>.<body>" with the endpoint secret
+)
+
+// retrySchedule is the wait after each failed attempt - a delivery is failed once it runs out
+var retrySchedule = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute, 2 * time.Hour, 8 * time.Hour}
+
+// maxConsecutiveFailures disables an endpoint after this many deliveries in a row exhausted their retries
+const maxConsecutiveFailures = 10
+
+// deliveryTimeout bounds one attempt, including reading the response
+const deliveryTimeout = 10 * time.Second
+
+// Signature returns the hex HMAC-SHA256 receivers compare against X-Webhook-Signature
+func Signature(secret, timestamp string, body []byte) string {
+	return apisign.Sign(secret, timestamp+"."+string(body))
+}
+
+// retryDelay is the wait before the next attempt after attempts failed ones, or false when the
+// delivery has run out of attempts
+func retryDelay(attempts int) (time.Duration, bool) {
+	if attempts < 1 || attempts > len(retrySchedule) {
+		return 0, false
+	}
+	return retrySchedule[attempts-1], true
+}
+
+var client = &http.Client{
+	Timeout: deliveryTimeout,
+	Transport: &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: refusePrivateAddresses}).DialContext,
+	},
+	// A redirect is answered like any other non-2xx response
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// errPrivateAddress keeps callbacks from reaching the server's own network
+var errPrivateAddress = errors.New("webhook URLs can't point at private or loopback addresses")
+
+func refusePrivateAddresses(network, address string, _ syscall.RawConn) error {
+	if os.Getenv("DEVELOPMENT") == "true" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast())
+}
+
+// Deliver makes the next attempt of a pending delivery. The retry is scheduled before sending, so
+// an attempt cut short by a restart is picked up again by ProcessDue
+func Deliver(app core.App, deliveryID string) {
+	delivery, err := app.FindRecordById("webhook_deliveries", deliveryID)
+	if err != nil || delivery.GetString("status") != StatusPending {
+		return
+	}
+	endpoint, err := app.FindRecordById("webhook_endpoints", delivery.GetString("endpoint_id"))
+	if err != nil {
+		return
+	}
+
+	attempts := delivery.GetInt("attempts") + 1
+	delivery.Set("attempts", attempts)
+	if delay, ok := retryDelay(attempts); ok {
+		delivery.Set("next_attempt_at", time.Now().Add(delay))
+	}
+	if err := app.Save(delivery); err != nil {
+		log.Printf("[WEBHOOKS] Failed to claim delivery %s: %v", delivery.Id, err)
+		return
+	}
+
+	status, sendErr := send(endpoint, delivery)
+	delivery.Set("response_status", status)
+	if sendErr == nil {
+		delivery.Set("status", StatusSucceeded)
+		delivery.Set("delivered_at", time.Now())
+		delivery.Set("last_error", "")
+	} else {
+		delivery.Set("last_error", truncate(sendErr.Error(), 500))
+		if _, ok := retryDelay(attempts); !ok {
+			delivery.Set("status", StatusFailed)
+		}
+	}
+	if err := app.Save(delivery); err != nil {
+		log.Printf("[WEBHOOKS] Failed to record delivery %s: %v", delivery.Id, err)
+	}
+
+	switch delivery.GetString("status") {
+	case StatusSucceeded:
+		recordOutcome(app, endpoint, delivery, true)
+	case StatusFailed:
+		log.Printf("[WEBHOOKS] Delivery %s of %s to %s failed after %d attempts: %v",
+			delivery.Id, delivery.GetString("event"), endpoint.GetString("url"), attempts, sendErr)
+		recordOutcome(app, endpoint, delivery, false)
+	}
+}
+
+// send posts the signed event and returns the response status
+func send(endpoint, delivery *core.Record) (int, error) {
+	secret, err := secrets.Open(endpoint.GetString("secret"))
+	if err != nil {
+		return 0, fmt.Errorf("can't open the endpoint secret: %w", err)
+	}
+	body, err := json.Marshal(delivery.Get("payload"))
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.GetString("url"), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Ramble-Webhooks/1.0")
+	req.Header.Set(HeaderEventID, delivery.GetString("event_id"))
+	req.Header.Set(HeaderEvent, delivery.GetString("event"))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Signature(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordOutcome tracks the endpoint's failure streak and logs the final outcome in the user's timeline
+func recordOutcome(app core.App, endpoint, delivery *core.Record, succeeded bool) {
+	status := communications.StatusSent
+	if succeeded {
+		endpoint.Set("consecutive_failures", 0)
+	} else {
+		status = communications.StatusFailed
+		failures := endpoint.GetInt("consecutive_failures") + 1
+		endpoint.Set("consecutive_failures", failures)
+		if failures >= maxConsecutiveFailures {
+			endpoint.Set("is_active", false)
+			endpoint.Set("disabled_reason", fmt.Sprintf("disabled after %d failed deliveries in a row", failures))
+			log.Printf("[WEBHOOKS] Disabled endpoint %s after %d failed deliveries", endpoint.Id, failures)
+		}
+	}
+	if err := app.Save(endpoint); err != nil {
+		log.Printf("[WEBHOOKS] Failed to update endpoint %s: %v", endpoint.Id, err)
+	}
+
+	communications.Log(app, communications.Entry{
+		UserID:    delivery.GetString("user_id"),
+		Channel:   communications.ChannelWebhook,
+		Kind:      delivery.GetString("event"),
+		Recipient: endpoint.GetString("url"),
+		Status:    status,
+		Reference: delivery.Id,
+		Details:   map[string]interface{}{"attempts": delivery.GetInt("attempts"), "response_status": delivery.GetInt("response_status")},
+	})
+}
+
+// ProcessDue retries pending deliveries whose next attempt is due - run every minute
+func ProcessDue(app core.App) {
+	due, err := app.FindRecordsByFilter("webhook_deliveries", "status = {:pending} && next_attempt_at <= {:now}",
+		"next_attempt_at", 100, 0, map[string]any{"pending": StatusPending, "now": time.Now().UTC().Format("2006-01-02 15:04:05.000Z")})
+	if err != nil {
+		log.Printf("[WEBHOOKS] Failed to load due deliveries: %v", err)
+		return
+	}
+	for _, delivery := range due {
+		Deliver(app, delivery.Id)
+	}
+	if len(due) > 0 {
+		log.Printf("[WEBHOOKS] Retried %d deliveries", len(due))
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,437 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+
+	"pocketbase/internal/ai"
+	"pocketbase/internal/secrets"
+)
+
+// maxEndpointsPerUser keeps one account from fanning every event out to a long list of URLs
+const maxEndpointsPerUser = 10
+
+// Delivery log page sizes
+const (
+	defaultDeliveriesPerPage = 20
+	maxDeliveriesPerPage     = 100
+)
+
+// apiKeyPrefix marks API keys, which the desktop app sends instead of a user auth token
+const apiKeyPrefix = "ra-"
+
+// EndpointView is an endpoint as shown to its owner - the secret is only returned on creation
+type EndpointView struct {
+	ID                  string    `json:"id"`
+	Kind                string    `json:"kind"`
+	URL                 string    `json:"url,omitempty"`
+	Events              []string  `json:"events"` // empty means every event
+	Description         string    `json:"description,omitempty"`
+	IsActive            bool      `json:"is_active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledReason      string    `json:"disabled_reason,omitempty"`
+	Created             time.Time `json:"created"`
+}
+
+func endpointView(endpoint *core.Record) EndpointView {
+	events := endpointEvents(endpoint)
+	if events == nil {
+		events = []string{}
+	}
+	return EndpointView{
+		ID:                  endpoint.Id,
+		Kind:                endpoint.GetString("kind"),
+		URL:                 endpoint.GetString("url"),
+		Events:              events,
+		Description:         endpoint.GetString("description"),
+		IsActive:            endpoint.GetBool("is_active"),
+		ConsecutiveFailures: endpoint.GetInt("consecutive_failures"),
+		DisabledReason:      endpoint.GetString("disabled_reason"),
+		Created:             endpoint.GetDateTime("created").Time(),
+	}
+}
+
+// requestUser resolves the caller from a user auth token or, for the desktop app, an API key
+func requestUser(e *core.RequestEvent) *core.Record {
+	if e.Auth != nil && e.Auth.Collection().Name == "users" {
+		return e.Auth
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(e.Request.Header.Get("Authorization"), "Bearer "))
+	if !strings.HasPrefix(token, apiKeyPrefix) && !strings.HasPrefix(token, ai.DelegatedTokenPrefix) {
+		return nil
+	}
+	user, _, err := ai.UserForAPIKey(e.App, token, e.Request, ai.ScopeWebhooksManage)
+	if err != nil {
+		return nil
+	}
+	return user
+}
+
+// validateURL accepts https URLs with a public host (http and local hosts in development). The
+// dialer checks resolved addresses too, so DNS pointing at a private address is refused as well
+func validateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("url must be an absolute URL")
+	}
+	if os.Getenv("DEVELOPMENT") == "true" {
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return errors.New("url must use http or https")
+		}
+		return nil
+	}
+	if u.Scheme != "https" {
+		return errors.New("url must use https")
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return errPrivateAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+func validateEvents(events []string) error {
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("unknown event %q - expected one of %s", event, strings.Join(Events, ", "))
+		}
+	}
+	return nil
+}
+
+func findOwnEndpoint(e *core.RequestEvent, user *core.Record) (*core.Record, error) {
+	endpoint, err := e.App.FindRecordById("webhook_endpoints", e.Request.PathValue("id"))
+	if err != nil || endpoint.GetString("user_id") != user.Id {
+		return nil, errors.New("webhook endpoint not found")
+	}
+	return endpoint, nil
+}
+
+// ListEndpointsHandler returns the caller's endpoints and the events they can subscribe to
+// GET /api/webhooks/endpoints
+func ListEndpointsHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	records, err := e.App.FindRecordsByFilter("webhook_endpoints", "user_id = {:user}", "created", 0, 0, map[string]any{"user": user.Id})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load webhook endpoints"})
+	}
+	views := make([]EndpointView, 0, len(records))
+	for _, record := range records {
+		views = append(views, endpointView(record))
+	}
+	return e.JSON(http.StatusOK, map[string]any{"endpoints": views, "events": Events})
+}
+
+// CreateEndpointHandler registers a callback URL, or a realtime channel for the desktop app.
+// The signing secret of an http endpoint is only returned here
+// POST /api/webhooks/endpoints {"kind": "http", "url": "https://...", "events": ["transcription.completed"]}
+func CreateEndpointHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var req struct {
+		Kind        string   `json:"kind"`
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description string   `json:"description"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Kind == "" {
+		req.Kind = KindHTTP
+	}
+	if req.Kind != KindHTTP && req.Kind != KindRealtime {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "kind must be 'http' or 'realtime'"})
+	}
+	if err := validateEvents(req.Events); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if req.Kind == KindHTTP {
+		if err := validateURL(req.URL); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	} else {
+		req.URL = ""
+	}
+
+	count, err := e.App.CountRecords("webhook_endpoints", dbx.HashExp{"user_id": user.Id})
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create webhook endpoint"})
+	}
+	if count >= maxEndpointsPerUser {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("accounts can have at most %d webhook endpoints", maxEndpointsPerUser)})
+	}
+
+	collection, err := e.App.FindCollectionByNameOrId("webhook_endpoints")
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create webhook endpoint"})
+	}
+	endpoint := core.NewRecord(collection)
+	endpoint.Set("user_id", user.Id)
+	endpoint.Set("kind", req.Kind)
+	endpoint.Set("url", req.URL)
+	endpoint.Set("events", req.Events)
+	endpoint.Set("description", req.Description)
+	endpoint.Set("is_active", true)
+
+	secret := ""
+	if req.Kind == KindHTTP {
+		secret = "rwh_" + security.RandomString(40)
+		sealed, err := secrets.Seal(secret)
+		if err != nil {
+			log.Printf("[WEBHOOKS] Cannot encrypt endpoint secret for user %s: %v", user.Id, err)
+			return e.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Webhooks are not available right now"})
+		}
+		endpoint.Set("secret", sealed)
+	}
+
+	if err := e.App.Save(endpoint); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to create webhook endpoint"})
+	}
+
+	response := map[string]any{"endpoint": endpointView(endpoint)}
+	if secret != "" {
+		// The only time the secret is shown
+		response["secret"] = secret
+	}
+	return e.JSON(http.StatusCreated, response)
+}
+
+// UpdateEndpointHandler changes an endpoint's URL, events, description or active state.
+// Re-activating an endpoint disabled by failures clears its failure streak
+// PATCH /api/webhooks/endpoints/{id}
+func UpdateEndpointHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+	endpoint, err := findOwnEndpoint(e, user)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+
+	var req struct {
+		URL         *string   `json:"url"`
+		Events      *[]string `json:"events"`
+		Description *string   `json:"description"`
+		IsActive    *bool     `json:"is_active"`
+	}
+	if err := e.BindBody(&req); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if req.URL != nil {
+		if endpoint.GetString("kind") != KindHTTP {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": "realtime endpoints have no url"})
+		}
+		if err := validateURL(*req.URL); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		endpoint.Set("url", *req.URL)
+	}
+	if req.Events != nil {
+		if err := validateEvents(*req.Events); err != nil {
+			return e.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		endpoint.Set("events", *req.Events)
+	}
+	if req.Description != nil {
+		endpoint.Set("description", *req.Description)
+	}
+	if req.IsActive != nil {
+		endpoint.Set("is_active", *req.IsActive)
+		if *req.IsActive {
+			endpoint.Set("consecutive_failures", 0)
+			endpoint.Set("disabled_reason", "")
+		}
+	}
+
+	if err := e.App.Save(endpoint); err != nil {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to update webhook endpoint"})
+	}
+	return e.JSON(http.StatusOK, map[string]any{"endpoint": endpointView(endpoint)})
+}
+
+// DeleteEndpointHandler removes an endpoint and its delivery log
+// DELETE /api/webhooks/endpoints/{id}
+func DeleteEndpointHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+	endpoint, err := findOwnEndpoint(e, user)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err := e.App.Delete(endpoint); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete webhook endpoint"})
+	}
+	return e.NoContent(http.StatusNoContent)
+}
+
+// TestEndpointHandler sends a ping event to the endpoint straight away and returns the delivery
+// POST /api/webhooks/endpoints/{id}/test
+func TestEndpointHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+	endpoint, err := findOwnEndpoint(e, user)
+	if err != nil {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+
+	event := Event{ID: "evt_" + security.RandomString(20), Type: EventPing, Created: time.Now().UTC(),
+		Data: map[string]any{"endpoint_id": endpoint.Id}}
+	delivery, err := enqueue(e.App, endpoint, event)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to queue test event"})
+	}
+	if delivery.GetString("status") == StatusPending {
+		Deliver(e.App, delivery.Id)
+		if refreshed, err := e.App.FindRecordById("webhook_deliveries", delivery.Id); err == nil {
+			delivery = refreshed
+		}
+	}
+	return e.JSON(http.StatusOK, map[string]any{"delivery": deliveryView(delivery)})
+}
+
+// DeliveryView is a delivery log entry
+type DeliveryView struct {
+	ID             string         `json:"id"`
+	EndpointID     string         `json:"endpoint_id"`
+	EventID        string         `json:"event_id"`
+	Event          string         `json:"event"`
+	Status         string         `json:"status"`
+	Attempts       int            `json:"attempts"`
+	ResponseStatus int            `json:"response_status,omitempty"`
+	LastError      string         `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time     `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	Payload        map[string]any `json:"payload"`
+	Created        time.Time      `json:"created"`
+}
+
+func deliveryView(delivery *core.Record) DeliveryView {
+	view := DeliveryView{
+		ID:             delivery.Id,
+		EndpointID:     delivery.GetString("endpoint_id"),
+		EventID:        delivery.GetString("event_id"),
+		Event:          delivery.GetString("event"),
+		Status:         delivery.GetString("status"),
+		Attempts:       delivery.GetInt("attempts"),
+		ResponseStatus: delivery.GetInt("response_status"),
+		LastError:      delivery.GetString("last_error"),
+		Created:        delivery.GetDateTime("created").Time(),
+	}
+	_ = delivery.UnmarshalJSONField("payload", &view.Payload)
+	if at := delivery.GetDateTime("next_attempt_at"); !at.IsZero() && view.Status == StatusPending {
+		t := at.Time()
+		view.NextAttemptAt = &t
+	}
+	if at := delivery.GetDateTime("delivered_at"); !at.IsZero() {
+		t := at.Time()
+		view.DeliveredAt = &t
+	}
+	return view
+}
+
+// ListDeliveriesHandler pages through the caller's delivery log, newest first
+// GET /api/webhooks/deliveries?endpoint_id=...&status=failed&page=1&per_page=20
+func ListDeliveriesHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	query := e.Request.URL.Query()
+	page, perPage := 1, defaultDeliveriesPerPage
+	if v, err := strconv.Atoi(query.Get("page")); err == nil && v > 0 {
+		page = v
+	}
+	if v, err := strconv.Atoi(query.Get("per_page")); err == nil && v > 0 {
+		perPage = min(v, maxDeliveriesPerPage)
+	}
+
+	filter := "user_id = {:user_id}"
+	params := map[string]any{"user_id": user.Id}
+	where := dbx.HashExp{"user_id": user.Id}
+	for _, key := range []string{"endpoint_id", "status"} {
+		if value := query.Get(key); value != "" {
+			filter += " && " + key + " = {:" + key + "}"
+			params[key] = value
+			where[key] = value
+		}
+	}
+
+	records, err := e.App.FindRecordsByFilter("webhook_deliveries", filter, "-created", perPage, (page-1)*perPage, params)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load webhook deliveries"})
+	}
+	total, err := e.App.CountRecords("webhook_deliveries", where)
+	if err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load webhook deliveries"})
+	}
+
+	views := make([]DeliveryView, 0, len(records))
+	for _, record := range records {
+		views = append(views, deliveryView(record))
+	}
+	return e.JSON(http.StatusOK, map[string]any{
+		"deliveries":  views,
+		"page":        page,
+		"per_page":    perPage,
+		"total":       total,
+		"total_pages": (total + int64(perPage) - 1) / int64(perPage),
+	})
+}
+
+// RetryDeliveryHandler sends a failed delivery again, with a fresh set of retries
+// POST /api/webhooks/deliveries/{id}/retry
+func RetryDeliveryHandler(e *core.RequestEvent) error {
+	user := requestUser(e)
+	if user == nil {
+		return e.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+	delivery, err := e.App.FindRecordById("webhook_deliveries", e.Request.PathValue("id"))
+	if err != nil || delivery.GetString("user_id") != user.Id {
+		return e.JSON(http.StatusNotFound, map[string]string{"error": "Webhook delivery not found"})
+	}
+	if delivery.GetString("status") != StatusFailed {
+		return e.JSON(http.StatusBadRequest, map[string]string{"error": "Only failed deliveries can be retried"})
+	}
+
+	delivery.Set("status", StatusPending)
+	delivery.Set("attempts", 0)
+	if err := e.App.Save(delivery); err != nil {
+		return e.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retry webhook delivery"})
+	}
+	Deliver(e.App, delivery.Id)
+	if refreshed, err := e.App.FindRecordById("webhook_deliveries", delivery.Id); err == nil {
+		delivery = refreshed
+	}
+	return e.JSON(http.StatusOK, map[string]any{"delivery": deliveryView(delivery)})
+}
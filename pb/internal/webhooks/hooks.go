@@ -0,0 +1,104 @@
+package webhooks
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+
+	"pocketbase/internal/subscription"
+)
+
+// RegisterHooks publishes events when transcription jobs finish, usage crosses a threshold and
+// subscriptions change status
+func RegisterHooks(app core.App) {
+	app.OnRecordAfterUpdateSuccess("transcription_jobs").BindFunc(func(e *core.RecordEvent) error {
+		publishJobFinished(e.App, e.Record)
+		return e.Next()
+	})
+
+	usageChanged := func(e *core.RecordEvent) error {
+		publishUsageThresholds(e.App, e.Record)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("monthly_usage").BindFunc(usageChanged)
+	app.OnRecordAfterUpdateSuccess("monthly_usage").BindFunc(usageChanged)
+
+	subscriptionChanged := func(e *core.RecordEvent) error {
+		publishSubscriptionStatus(e.App, e.Record)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("current_user_subscriptions").BindFunc(subscriptionChanged)
+	app.OnRecordAfterUpdateSuccess("current_user_subscriptions").BindFunc(subscriptionChanged)
+}
+
+func publishJobFinished(app core.App, job *core.Record) {
+	status := job.GetString("status")
+	if status == job.Original().GetString("status") {
+		return
+	}
+
+	data := map[string]any{
+		"job_id":            job.Id,
+		"filename":          job.GetString("filename"),
+		"processed_file_id": job.GetString("processed_file_id"),
+		"session_id":        job.GetString("session_id"),
+		"completed_at":      job.GetDateTime("completed_at"),
+	}
+	switch status {
+	case "completed":
+		Publish(app, job.GetString("user_id"), EventTranscriptionCompleted, data)
+	case "failed":
+		data["error"] = job.GetString("error")
+		Publish(app, job.GetString("user_id"), EventTranscriptionFailed, data)
+	}
+}
+
+// publishUsageThresholds notifies a user whose personal usage crossed 80% or 100% of their
+// plan's hours. Organization pools aren't tied to one user and are skipped
+func publishUsageThresholds(app core.App, usage *core.Record) {
+	if usage.GetString("organization_id") != "" {
+		return
+	}
+	// A new record's original is blank, so its usage starts from 0
+	before := usage.Original().GetFloat("hours_used")
+	after := usage.GetFloat("hours_used")
+	if after <= before {
+		return
+	}
+
+	userID := usage.GetString("user_id")
+	if len(activeEndpoints(app, userID)) == 0 {
+		return // spare the plan lookup on every usage update
+	}
+	info, err := subscription.NewService(subscription.NewRepository(app)).GetUserSubscriptionInfo(userID)
+	if err != nil {
+		return
+	}
+	limit := info.Plan.GetFloat("hours_per_month")
+
+	for _, percent := range subscription.CrossedUsageThresholds(before, after, limit, subscription.UsageThresholds) {
+		Publish(app, userID, EventUsageThreshold, map[string]any{
+			"percent":      percent,
+			"hours_used":   after,
+			"hours_limit":  limit,
+			"plan_id":      info.Plan.Id,
+			"period":       usage.GetString("year_month"),
+			"period_start": usage.GetDateTime("period_start"),
+			"period_end":   usage.GetDateTime("period_end"),
+		})
+	}
+}
+
+func publishSubscriptionStatus(app core.App, sub *core.Record) {
+	previous := sub.Original().GetString("status")
+	status := sub.GetString("status")
+	if status == previous {
+		return
+	}
+
+	Publish(app, sub.GetString("user_id"), EventSubscriptionStatus, map[string]any{
+		"subscription_id":    sub.Id,
+		"previous_status":    previous,
+		"status":             status,
+		"plan_id":            sub.GetString("plan_id"),
+		"current_period_end": sub.GetDateTime("current_period_end"),
+	})
+}
@@ -0,0 +1,21 @@
+package webhooks
+
+import (
+	"net/http"
+
+	"pocketbase/internal/routes"
+)
+
+// RegisterRoutes adds endpoint management and the delivery log. Handlers accept a user token or an API key
+func RegisterRoutes(r *routes.Registry) {
+	r.Add(
+		routes.Route{Method: http.MethodGet, Path: "/api/webhooks/endpoints", Handler: ListEndpointsHandler},
+		routes.Route{Method: http.MethodPost, Path: "/api/webhooks/endpoints", Handler: CreateEndpointHandler},
+		routes.Route{Method: http.MethodPatch, Path: "/api/webhooks/endpoints/{id}", Handler: UpdateEndpointHandler},
+		routes.Route{Method: http.MethodDelete, Path: "/api/webhooks/endpoints/{id}", Handler: DeleteEndpointHandler},
+		// Send a ping right away to check the receiver and its signature verification
+		routes.Route{Method: http.MethodPost, Path: "/api/webhooks/endpoints/{id}/test", Handler: TestEndpointHandler},
+		routes.Route{Method: http.MethodGet, Path: "/api/webhooks/deliveries", Handler: ListDeliveriesHandler},
+		routes.Route{Method: http.MethodPost, Path: "/api/webhooks/deliveries/{id}/retry", Handler: RetryDeliveryHandler},
+	)
+}
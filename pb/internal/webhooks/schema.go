@@ -0,0 +1,48 @@
+package webhooks
+
+import "pocketbase/internal/schemacheck"
+
+// SchemaRequirements lists the collections and fields webhook delivery reads and writes
+func SchemaRequirements() schemacheck.Module {
+	return schemacheck.Module{
+		Name: "webhooks",
+		Requirements: []schemacheck.Requirement{
+			{
+				Collection: "webhook_endpoints",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "kind", Type: "select", Values: []string{KindHTTP, KindRealtime}},
+					{Name: "url", Type: "url"},
+					{Name: "secret", Type: "text"},
+					{Name: "events", Type: "json"},
+					{Name: "description", Type: "text"},
+					{Name: "is_active", Type: "bool"},
+					{Name: "consecutive_failures", Type: "number"},
+					{Name: "disabled_reason", Type: "text"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_webhook_endpoints_user", Columns: "user_id, is_active"},
+				},
+			},
+			{
+				Collection: "webhook_deliveries",
+				Fields: []schemacheck.Field{
+					{Name: "user_id", Type: "relation"},
+					{Name: "endpoint_id", Type: "relation"},
+					{Name: "event_id", Type: "text"},
+					{Name: "event", Type: "text"},
+					{Name: "payload", Type: "json"},
+					{Name: "status", Type: "select", Values: []string{StatusPending, StatusSucceeded, StatusFailed}},
+					{Name: "attempts", Type: "number"},
+					{Name: "next_attempt_at", Type: "date"},
+					{Name: "response_status", Type: "number"},
+					{Name: "last_error", Type: "text"},
+					{Name: "delivered_at", Type: "date"},
+				},
+				Indexes: []schemacheck.Index{
+					{Name: "idx_webhook_deliveries_due", Columns: "status, next_attempt_at"},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,124 @@
+// Package webhooks notifies users of events on their account through callback URLs they register,
+// or through a realtime channel the desktop app subscribes to
+package webhooks
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/pocketbase/pocketbase/tools/types"
+
+	"pocketbase/internal/secrets"
+)
+
+// Events an endpoint can subscribe to
+const (
+	EventTranscriptionCompleted = "transcription.completed"
+	EventTranscriptionFailed    = "transcription.failed"
+	EventUsageThreshold         = "usage.threshold_reached"
+	EventSubscriptionStatus     = "subscription.status_changed"
+	EventPing                   = "ping" // sent by POST /api/webhooks/endpoints/{id}/test only
+)
+
+// Events lists the events endpoints can subscribe to
+var Events = []string{EventTranscriptionCompleted, EventTranscriptionFailed, EventUsageThreshold, EventSubscriptionStatus}
+
+// Endpoint kinds
+const (
+	KindHTTP     = "http"     // signed POST to the endpoint's URL
+	KindRealtime = "realtime" // a webhook_deliveries record the desktop app receives over a realtime subscription
+)
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// SecretField is stored encrypted - see the secrets package
+var SecretField = secrets.Field{Collection: "webhook_endpoints", Name: "secret"}
+
+// Event is the JSON body of every delivery
+type Event struct {
+	ID      string         `json:"id"`
+	Type    string         `json:"type"`
+	Created time.Time      `json:"created"`
+	Data    map[string]any `json:"data"`
+}
+
+// subscribed reports whether an endpoint's event list includes eventType - an empty list means all events
+func subscribed(events []string, eventType string) bool {
+	return eventType == EventPing || len(events) == 0 || slices.Contains(events, eventType)
+}
+
+func endpointEvents(endpoint *core.Record) []string {
+	var events []string
+	if raw, ok := endpoint.GetRaw("events").(types.JSONRaw); ok && len(raw) > 0 {
+		_ = json.Unmarshal(raw, &events)
+	}
+	return events
+}
+
+// Publish records a delivery of the event to every active endpoint of the user subscribed to it,
+// and sends the HTTP ones in the background. Failures are logged and never fail the caller
+func Publish(app core.App, userID, eventType string, data map[string]any) {
+	endpoints := activeEndpoints(app, userID)
+	if len(endpoints) == 0 {
+		return
+	}
+
+	event := Event{ID: "evt_" + security.RandomString(20), Type: eventType, Created: time.Now().UTC(), Data: data}
+	for _, endpoint := range endpoints {
+		if !subscribed(endpointEvents(endpoint), eventType) {
+			continue
+		}
+		delivery, err := enqueue(app, endpoint, event)
+		if err != nil {
+			log.Printf("[WEBHOOKS] Failed to queue %s for endpoint %s: %v", eventType, endpoint.Id, err)
+			continue
+		}
+		if delivery.GetString("status") == StatusPending {
+			go Deliver(app, delivery.Id)
+		}
+	}
+}
+
+func activeEndpoints(app core.App, userID string) []*core.Record {
+	endpoints, err := app.FindRecordsByFilter("webhook_endpoints", "user_id = {:user} && is_active = true", "", 0, 0,
+		map[string]any{"user": userID})
+	if err != nil {
+		return nil
+	}
+	return endpoints
+}
+
+// enqueue creates the delivery record. Realtime deliveries are complete once the record exists
+func enqueue(app core.App, endpoint *core.Record, event Event) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("webhook_deliveries")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := core.NewRecord(collection)
+	delivery.Set("user_id", endpoint.GetString("user_id"))
+	delivery.Set("endpoint_id", endpoint.Id)
+	delivery.Set("event_id", event.ID)
+	delivery.Set("event", event.Type)
+	delivery.Set("payload", event)
+	if endpoint.GetString("kind") == KindRealtime {
+		delivery.Set("status", StatusSucceeded)
+		delivery.Set("delivered_at", now)
+	} else {
+		// The first attempt is made straight away - this is when ProcessDue picks the delivery up
+		// if that attempt never gets to run
+		delivery.Set("status", StatusPending)
+		delivery.Set("next_attempt_at", now.Add(retrySchedule[0]))
+	}
+	return delivery, app.Save(delivery)
+}
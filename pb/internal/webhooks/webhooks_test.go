@@ -0,0 +1,71 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSubscribed(t *testing.T) {
+	if !subscribed(nil, EventUsageThreshold) {
+		t.Error("an empty event list should receive every event")
+	}
+	if !subscribed([]string{EventTranscriptionCompleted}, EventTranscriptionCompleted) {
+		t.Error("a listed event should be delivered")
+	}
+	if subscribed([]string{EventTranscriptionCompleted}, EventTranscriptionFailed) {
+		t.Error("an unlisted event should not be delivered")
+	}
+	if !subscribed([]string{EventTranscriptionCompleted}, EventPing) {
+		t.Error("pings should reach every endpoint")
+	}
+}
+
+func TestRetryDelayRunsOut(t *testing.T) {
+	if delay, ok := retryDelay(1); !ok || delay != time.Minute {
+		t.Fatalf("retryDelay(1) = %v, %v", delay, ok)
+	}
+	if _, ok := retryDelay(len(retrySchedule)); !ok {
+		t.Fatal("the last scheduled retry should still be allowed")
+	}
+	if _, ok := retryDelay(len(retrySchedule) + 1); ok {
+		t.Fatal("a delivery past the schedule should be failed")
+	}
+}
+
+func TestSignatureMatchesReceiverVerification(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"ping"}`)
+	mac := hmac.New(sha256.New, []byte("rwh_secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	if want := hex.EncodeToString(mac.Sum(nil)); Signature("rwh_secret", "1700000000", body) != want {
+		t.Fatal("signature should be the hex HMAC-SHA256 of <timestamp>.<body>")
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.0.0.5", "192.168.1.1", "169.254.169.254", "::1", "fd00::1", "0.0.0.0"} {
+		if publicIP(net.ParseIP(addr)) {
+			t.Errorf("%s should be refused", addr)
+		}
+	}
+	for _, addr := range []string{"8.8.8.8", "2606:4700:4700::1111"} {
+		if !publicIP(net.ParseIP(addr)) {
+			t.Errorf("%s should be allowed", addr)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	t.Setenv("DEVELOPMENT", "")
+	for _, raw := range []string{"http://example.com/hook", "https://localhost/hook", "https://10.1.2.3/hook", "not a url"} {
+		if validateURL(raw) == nil {
+			t.Errorf("%q should be rejected", raw)
+		}
+	}
+	if err := validateURL("https://example.com/hook"); err != nil {
+		t.Errorf("public https URL rejected: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"pocketbase/internal/circuit"
+)
+
+// DefaultAssemblyAIEndpoint is the base URL of AssemblyAI's v2 API
+const DefaultAssemblyAIEndpoint = "https://api.assemblyai.com/v2"
+
+// AssemblyAI transcribes with AssemblyAI, which works asynchronously: the audio is uploaded,
+// a transcript is requested, and the transcript is polled until it's done
+type AssemblyAI struct {
+	APIKey       string
+	Endpoint     string
+	PollInterval time.Duration
+	HTTPClient   *http.Client
+}
+
+// NewAssemblyAIFromEnv creates an AssemblyAI provider from ASSEMBLYAI_API_KEY
+func NewAssemblyAIFromEnv() (*AssemblyAI, error) {
+	apiKey := os.Getenv("ASSEMBLYAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("AssemblyAI API key not configured")
+	}
+
+	return &AssemblyAI{
+		APIKey:       apiKey,
+		Endpoint:     DefaultAssemblyAIEndpoint,
+		PollInterval: 3 * time.Second,
+		HTTPClient:   &http.Client{Timeout: 120 * time.Second},
+	}, nil
+}
+
+// Name implements Provider
+func (a *AssemblyAI) Name() string {
+	return ProviderAssemblyAI
+}
+
+// assemblyAIWord is a word in an AssemblyAI response, with times in milliseconds
+type assemblyAIWord struct {
+	Text  string `json:"text"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+}
+
+type assemblyAITranscript struct {
+	ID            string           `json:"id"`
+	Status        string           `json:"status"` // queued, processing, completed or error
+	Error         string           `json:"error"`
+	Text          string           `json:"text"`
+	LanguageCode  string           `json:"language_code"`
+	AudioDuration float64          `json:"audio_duration"`
+	Words         []assemblyAIWord `json:"words"`
+}
+
+type assemblyAISentences struct {
+	Sentences []struct {
+		Text  string           `json:"text"`
+		Start int64            `json:"start"`
+		End   int64            `json:"end"`
+		Words []assemblyAIWord `json:"words"`
+	} `json:"sentences"`
+}
+
+// Transcribe implements Provider
+func (a *AssemblyAI) Transcribe(ctx context.Context, src io.Reader, filename string) (*Result, error) {
+	// AssemblyAI takes a language but has no prompt, temperature or translation
+	opts := OptionsFrom(ctx)
+	if opts.Translate {
+		return nil, fmt.Errorf("AssemblyAI doesn't support translation")
+	}
+
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+	}
+	if err := a.do(ctx, http.MethodPost, "/upload", src, "application/octet-stream", &upload); err != nil {
+		return nil, fmt.Errorf("failed to upload audio: %w", err)
+	}
+
+	params := map[string]any{"audio_url": upload.UploadURL, "language_detection": true}
+	if opts.Language != "" {
+		params = map[string]any{"audio_url": upload.UploadURL, "language_code": opts.Language}
+	}
+	request, _ := json.Marshal(params)
+	var transcript assemblyAITranscript
+	if err := a.do(ctx, http.MethodPost, "/transcript", bytes.NewReader(request), "application/json", &transcript); err != nil {
+		return nil, fmt.Errorf("failed to request transcript: %w", err)
+	}
+
+	for transcript.Status != "completed" {
+		if transcript.Status == "error" {
+			return nil, fmt.Errorf("AssemblyAI transcription failed: %s", transcript.Error)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(a.PollInterval):
+		}
+		if err := a.do(ctx, http.MethodGet, "/transcript/"+transcript.ID, nil, "", &transcript); err != nil {
+			return nil, fmt.Errorf("failed to poll transcript: %w", err)
+		}
+	}
+
+	result := &Result{
+		Transcript: transcript.Text,
+		Duration:   transcript.AudioDuration,
+		Language:   transcript.LanguageCode,
+		Words:      assemblyAIWords(transcript.Words),
+		Provider:   ProviderAssemblyAI,
+	}
+
+	// Sentences stand in for Whisper's segments; the transcript is still usable without them
+	var sentences assemblyAISentences
+	if err := a.do(ctx, http.MethodGet, "/transcript/"+transcript.ID+"/sentences", nil, "", &sentences); err == nil {
+		for i, sentence := range sentences.Sentences {
+			result.Segments = append(result.Segments, Segment{
+				ID:    i,
+				Start: float64(sentence.Start) / 1000,
+				End:   float64(sentence.End) / 1000,
+				Text:  sentence.Text,
+				Words: assemblyAIWords(sentence.Words),
+			})
+		}
+	}
+	return result, nil
+}
+
+// do sends a request to the AssemblyAI API and decodes the JSON response into out
+func (a *AssemblyAI) do(ctx context.Context, method, path string, body io.Reader, contentType string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, a.Endpoint+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", a.APIKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return &circuit.StatusError{Provider: "AssemblyAI", StatusCode: resp.StatusCode, Body: string(errorBody)}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+func assemblyAIWords(words []assemblyAIWord) []Word {
+	mapped := make([]Word, 0, len(words))
+	for _, word := range words {
+		mapped = append(mapped, Word{Word: word.Text, Start: float64(word.Start) / 1000, End: float64(word.End) / 1000})
+	}
+	return mapped
+}
@@ -0,0 +1,166 @@
+package whisper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"pocketbase/internal/circuit"
+)
+
+// DefaultDeepgramEndpoint is Deepgram's pre-recorded audio API
+const DefaultDeepgramEndpoint = "https://api.deepgram.com/v1/listen"
+
+// DefaultDeepgramModel is the Deepgram model used when DEEPGRAM_MODEL isn't set
+const DefaultDeepgramModel = "nova-2"
+
+// Deepgram transcribes with Deepgram's pre-recorded API. The audio is sent as the raw
+// request body, so it streams like the Whisper multipart upload
+type Deepgram struct {
+	APIKey     string
+	Endpoint   string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewDeepgramFromEnv creates a Deepgram provider from DEEPGRAM_API_KEY and DEEPGRAM_MODEL
+func NewDeepgramFromEnv() (*Deepgram, error) {
+	apiKey := os.Getenv("DEEPGRAM_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("Deepgram API key not configured")
+	}
+
+	model := os.Getenv("DEEPGRAM_MODEL")
+	if model == "" {
+		model = DefaultDeepgramModel
+	}
+
+	return &Deepgram{
+		APIKey:     apiKey,
+		Endpoint:   DefaultDeepgramEndpoint,
+		Model:      model,
+		HTTPClient: &http.Client{Timeout: 120 * time.Second},
+	}, nil
+}
+
+// Name implements Provider
+func (d *Deepgram) Name() string {
+	return ProviderDeepgram
+}
+
+// deepgramWord is a word in a Deepgram response; punctuated_word is set with smart_format
+type deepgramWord struct {
+	Word           string  `json:"word"`
+	PunctuatedWord string  `json:"punctuated_word"`
+	Start          float64 `json:"start"`
+	End            float64 `json:"end"`
+}
+
+type deepgramResponse struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
+	Results struct {
+		Channels []struct {
+			DetectedLanguage string `json:"detected_language"`
+			Alternatives     []struct {
+				Transcript string         `json:"transcript"`
+				Words      []deepgramWord `json:"words"`
+			} `json:"alternatives"`
+		} `json:"channels"`
+		Utterances []struct {
+			Start      float64        `json:"start"`
+			End        float64        `json:"end"`
+			Transcript string         `json:"transcript"`
+			Words      []deepgramWord `json:"words"`
+		} `json:"utterances"`
+	} `json:"results"`
+}
+
+// Transcribe implements Provider
+func (d *Deepgram) Transcribe(ctx context.Context, src io.Reader, filename string) (*Result, error) {
+	// Deepgram takes a language but has no prompt, temperature or translation
+	opts := OptionsFrom(ctx)
+	if opts.Translate {
+		return nil, fmt.Errorf("Deepgram doesn't support translation")
+	}
+	query := url.Values{
+		"model":           {d.Model},
+		"smart_format":    {"true"},
+		"utterances":      {"true"},
+		"detect_language": {"true"},
+	}
+	if opts.Language != "" {
+		query.Del("detect_language")
+		query.Set("language", opts.Language)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Endpoint+"?"+query.Encode(), src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+d.APIKey)
+	req.Header.Set("Content-Type", audioContentType(filename))
+
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &circuit.StatusError{Provider: "Deepgram", StatusCode: resp.StatusCode, Body: string(errorBody)}
+	}
+
+	var response deepgramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	result := &Result{Duration: response.Metadata.Duration, Provider: ProviderDeepgram, Model: d.Model}
+	if len(response.Results.Channels) > 0 {
+		channel := response.Results.Channels[0]
+		result.Language = channel.DetectedLanguage
+		if len(channel.Alternatives) > 0 {
+			result.Transcript = channel.Alternatives[0].Transcript
+			result.Words = deepgramWords(channel.Alternatives[0].Words)
+		}
+	}
+	for i, utterance := range response.Results.Utterances {
+		result.Segments = append(result.Segments, Segment{
+			ID:    i,
+			Start: utterance.Start,
+			End:   utterance.End,
+			Text:  utterance.Transcript,
+			Words: deepgramWords(utterance.Words),
+		})
+	}
+	return result, nil
+}
+
+func deepgramWords(words []deepgramWord) []Word {
+	mapped := make([]Word, 0, len(words))
+	for _, word := range words {
+		text := word.PunctuatedWord
+		if text == "" {
+			text = word.Word
+		}
+		mapped = append(mapped, Word{Word: text, Start: word.Start, End: word.End})
+	}
+	return mapped
+}
+
+// audioContentType guesses the audio MIME type from the filename for providers that take raw bodies
+func audioContentType(filename string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(filename)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
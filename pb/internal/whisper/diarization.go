@@ -0,0 +1,210 @@
+package whisper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"pocketbase/internal/circuit"
+)
+
+// Diarization provider names, used in DIARIZATION_PROVIDER
+const (
+	DiarizerDeepgram = "deepgram"
+	DiarizerHTTP     = "http" // any service answering with speaker turns, e.g. a pyannote server
+)
+
+// SpeakerTurn is a stretch of audio attributed to one speaker
+type SpeakerTurn struct {
+	Speaker string  `json:"speaker"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+}
+
+// Diarizer labels who speaks when. It runs on the same audio as the transcription, and its
+// turns are merged onto the transcript's segments with AssignSpeakers
+type Diarizer interface {
+	Name() string
+	Diarize(ctx context.Context, src io.Reader, filename string) ([]SpeakerTurn, error)
+}
+
+// DiarizerFromEnv creates the diarizer named by DIARIZATION_PROVIDER, or returns nil when
+// diarization isn't configured
+func DiarizerFromEnv() (Diarizer, error) {
+	switch name := strings.TrimSpace(os.Getenv("DIARIZATION_PROVIDER")); name {
+	case "":
+		return nil, nil
+	case DiarizerDeepgram:
+		deepgram, err := NewDeepgramFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return &DeepgramDiarizer{Deepgram: deepgram}, nil
+	case DiarizerHTTP:
+		return NewHTTPDiarizerFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown diarization provider %q", name)
+	}
+}
+
+// DeepgramDiarizer diarizes with Deepgram's pre-recorded API, reading the speaker of each word
+type DeepgramDiarizer struct {
+	*Deepgram
+}
+
+// Name implements Diarizer
+func (d *DeepgramDiarizer) Name() string {
+	return DiarizerDeepgram
+}
+
+// Diarize implements Diarizer
+func (d *DeepgramDiarizer) Diarize(ctx context.Context, src io.Reader, filename string) ([]SpeakerTurn, error) {
+	query := url.Values{"model": {d.Model}, "diarize": {"true"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Endpoint+"?"+query.Encode(), src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+d.APIKey)
+	req.Header.Set("Content-Type", audioContentType(filename))
+
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &circuit.StatusError{Provider: "Deepgram", StatusCode: resp.StatusCode, Body: string(errorBody)}
+	}
+
+	var response struct {
+		Results struct {
+			Channels []struct {
+				Alternatives []struct {
+					Words []struct {
+						Start   float64 `json:"start"`
+						End     float64 `json:"end"`
+						Speaker *int    `json:"speaker"`
+					} `json:"words"`
+				} `json:"alternatives"`
+			} `json:"channels"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Consecutive words from the same speaker make one turn
+	var turns []SpeakerTurn
+	if len(response.Results.Channels) == 0 || len(response.Results.Channels[0].Alternatives) == 0 {
+		return turns, nil
+	}
+	for _, word := range response.Results.Channels[0].Alternatives[0].Words {
+		if word.Speaker == nil {
+			continue
+		}
+		speaker := fmt.Sprintf("speaker_%d", *word.Speaker)
+		if n := len(turns); n > 0 && turns[n-1].Speaker == speaker {
+			turns[n-1].End = word.End
+			continue
+		}
+		turns = append(turns, SpeakerTurn{Speaker: speaker, Start: word.Start, End: word.End})
+	}
+	return turns, nil
+}
+
+// HTTPDiarizer posts the audio as a multipart "file" upload to a self-hosted diarization
+// service, which answers with {"turns": [{"speaker", "start", "end"}]}
+type HTTPDiarizer struct {
+	Endpoint   string
+	APIKey     string // optional bearer token
+	HTTPClient *http.Client
+}
+
+// NewHTTPDiarizerFromEnv creates an HTTP diarizer from DIARIZATION_ENDPOINT and the optional
+// DIARIZATION_API_KEY
+func NewHTTPDiarizerFromEnv() (*HTTPDiarizer, error) {
+	endpoint := os.Getenv("DIARIZATION_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("diarization endpoint not configured")
+	}
+
+	return &HTTPDiarizer{
+		Endpoint: endpoint,
+		APIKey:   os.Getenv("DIARIZATION_API_KEY"),
+		// Diarization models run slower than real time on CPU
+		HTTPClient: &http.Client{Timeout: 30 * time.Minute},
+	}, nil
+}
+
+// Name implements Diarizer
+func (h *HTTPDiarizer) Name() string {
+	return DiarizerHTTP
+}
+
+// Diarize implements Diarizer
+func (h *HTTPDiarizer) Diarize(ctx context.Context, src io.Reader, filename string) ([]SpeakerTurn, error) {
+	body, contentType := newMultipartStream(src, filename, nil)
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.APIKey)
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &circuit.StatusError{Provider: "diarization", StatusCode: resp.StatusCode, Body: string(errorBody)}
+	}
+
+	var response struct {
+		Turns []SpeakerTurn `json:"turns"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return response.Turns, nil
+}
+
+// AssignSpeakers labels each segment with the speaker whose turns overlap it the most, and
+// returns the speakers in order of first appearance. Segments no turn overlaps stay unlabelled
+func AssignSpeakers(segments []Segment, turns []SpeakerTurn) []string {
+	speakers := []string{}
+	for i := range segments {
+		overlaps := map[string]float64{}
+		best := ""
+		for _, turn := range turns {
+			overlap := min(segments[i].End, turn.End) - max(segments[i].Start, turn.Start)
+			if overlap <= 0 {
+				continue
+			}
+			overlaps[turn.Speaker] += overlap
+			if best == "" || overlaps[turn.Speaker] > overlaps[best] {
+				best = turn.Speaker
+			}
+		}
+		segments[i].Speaker = best
+		if best != "" && !slices.Contains(speakers, best) {
+			speakers = append(speakers, best)
+		}
+	}
+	return speakers
+}
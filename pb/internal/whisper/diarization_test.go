@@ -0,0 +1,78 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAssignSpeakers(t *testing.T) {
+	segments := []Segment{
+		{Start: 0, End: 4},
+		{Start: 4, End: 10}, // mostly speaker_1
+		{Start: 10, End: 12},
+		{Start: 30, End: 31}, // silence in the diarization
+	}
+	turns := []SpeakerTurn{
+		{Speaker: "speaker_0", Start: 0, End: 5},
+		{Speaker: "speaker_1", Start: 5, End: 9},
+		{Speaker: "speaker_0", Start: 9, End: 12},
+	}
+
+	speakers := AssignSpeakers(segments, turns)
+
+	want := []string{"speaker_0", "speaker_1", "speaker_0", ""}
+	for i, segment := range segments {
+		if segment.Speaker != want[i] {
+			t.Errorf("segment %d: speaker = %q, want %q", i, segment.Speaker, want[i])
+		}
+	}
+	if !reflect.DeepEqual(speakers, []string{"speaker_0", "speaker_1"}) {
+		t.Errorf("speakers = %v", speakers)
+	}
+}
+
+func TestHTTPDiarizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer diarize-key" {
+			t.Errorf("Expected bearer auth header, got %q", got)
+		}
+		if _, _, err := r.FormFile("file"); err != nil {
+			t.Errorf("Missing file part: %v", err)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"turns": []SpeakerTurn{{Speaker: "A", Start: 0, End: 1.5}}})
+	}))
+	defer server.Close()
+
+	diarizer := &HTTPDiarizer{Endpoint: server.URL, APIKey: "diarize-key", HTTPClient: &http.Client{Timeout: 5 * time.Second}}
+	turns, err := diarizer.Diarize(context.Background(), bytes.NewReader([]byte("audio")), "clip.mp3")
+	if err != nil {
+		t.Fatalf("Diarize failed: %v", err)
+	}
+	if len(turns) != 1 || turns[0].Speaker != "A" || turns[0].End != 1.5 {
+		t.Errorf("Unexpected turns: %+v", turns)
+	}
+}
+
+func TestDiarizerFromEnv(t *testing.T) {
+	t.Setenv("DIARIZATION_PROVIDER", "")
+	if diarizer, err := DiarizerFromEnv(); diarizer != nil || err != nil {
+		t.Errorf("Expected diarization to be disabled, got %v (%v)", diarizer, err)
+	}
+
+	t.Setenv("DIARIZATION_PROVIDER", DiarizerHTTP)
+	t.Setenv("DIARIZATION_ENDPOINT", "")
+	if _, err := DiarizerFromEnv(); err == nil {
+		t.Error("Expected an error without DIARIZATION_ENDPOINT")
+	}
+
+	t.Setenv("DIARIZATION_PROVIDER", "nope")
+	if _, err := DiarizerFromEnv(); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
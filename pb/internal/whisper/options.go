@@ -0,0 +1,120 @@
+package whisper
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaxPromptLength caps the prompt clients may send - Whisper only reads its last 224 tokens
+const MaxPromptLength = 1000
+
+var languagePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// Options are the per-request Whisper settings clients can pass through. The zero value keeps
+// the provider defaults (language detection, no prompt, temperature 0, transcription)
+type Options struct {
+	Language    string   `json:"language,omitempty"`    // ISO-639-1 code of the spoken language, e.g. "de"
+	Prompt      string   `json:"prompt,omitempty"`      // spelling and style hint
+	Temperature *float64 `json:"temperature,omitempty"` // sampling temperature, 0 to 1
+	Translate   bool     `json:"translate,omitempty"`   // translate the speech to English
+	Diarize     bool     `json:"diarize,omitempty"`     // label segments with speakers, see Diarizer
+	Preprocess  *bool    `json:"preprocess,omitempty"`  // compress before sending (audioprep), nil to decide by size
+}
+
+// IsZero reports whether the options keep every provider default
+func (o Options) IsZero() bool {
+	return o.Language == "" && o.Prompt == "" && o.Temperature == nil && !o.Translate && !o.Diarize && o.Preprocess == nil
+}
+
+// ParseOptions reads the language, prompt, temperature, translate, diarize and preprocess form values and validates them
+func ParseOptions(value func(string) string) (Options, error) {
+	opts := Options{
+		Language: strings.ToLower(strings.TrimSpace(value("language"))),
+		Prompt:   strings.TrimSpace(value("prompt")),
+	}
+
+	if raw := strings.TrimSpace(value("temperature")); raw != "" {
+		temperature, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return Options{}, fmt.Errorf("temperature must be a number between 0 and 1")
+		}
+		opts.Temperature = &temperature
+	}
+
+	if raw := strings.TrimSpace(value("translate")); raw != "" {
+		translate, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Options{}, fmt.Errorf("translate must be true or false")
+		}
+		opts.Translate = translate
+	}
+
+	if raw := strings.TrimSpace(value("diarize")); raw != "" {
+		diarize, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Options{}, fmt.Errorf("diarize must be true or false")
+		}
+		opts.Diarize = diarize
+	}
+
+	if raw := strings.TrimSpace(value("preprocess")); raw != "" {
+		preprocess, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Options{}, fmt.Errorf("preprocess must be true or false")
+		}
+		opts.Preprocess = &preprocess
+	}
+
+	return opts, opts.Validate()
+}
+
+// Validate checks the options are ones every Whisper API accepts
+func (o Options) Validate() error {
+	if o.Language != "" && !languagePattern.MatchString(o.Language) {
+		return fmt.Errorf("language must be an ISO-639-1 code such as \"en\" or \"de\"")
+	}
+	if o.Language != "" && o.Translate {
+		return fmt.Errorf("language can't be combined with translate - translations always detect the spoken language")
+	}
+	if len(o.Prompt) > MaxPromptLength {
+		return fmt.Errorf("prompt must be at most %d characters", MaxPromptLength)
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 1) {
+		return fmt.Errorf("temperature must be a number between 0 and 1")
+	}
+	return nil
+}
+
+// fields returns the multipart fields for the options, on top of the model and response format
+func (o Options) fields() [][2]string {
+	var fields [][2]string
+	if o.Language != "" {
+		fields = append(fields, [2]string{"language", o.Language})
+	}
+	if o.Prompt != "" {
+		fields = append(fields, [2]string{"prompt", o.Prompt})
+	}
+	if o.Temperature != nil {
+		fields = append(fields, [2]string{"temperature", strconv.FormatFloat(*o.Temperature, 'f', -1, 64)})
+	}
+	return fields
+}
+
+type optionsKey struct{}
+
+// WithOptions attaches per-request Whisper options to the context Transcribe is called with
+func WithOptions(ctx context.Context, opts Options) context.Context {
+	if opts.IsZero() {
+		return ctx
+	}
+	return context.WithValue(ctx, optionsKey{}, opts)
+}
+
+// OptionsFrom returns the options attached with WithOptions, or the zero value
+func OptionsFrom(ctx context.Context) Options {
+	opts, _ := ctx.Value(optionsKey{}).(Options)
+	return opts
+}
@@ -0,0 +1,94 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func formValues(values map[string]string) func(string) string {
+	return func(key string) string { return values[key] }
+}
+
+func TestParseOptions(t *testing.T) {
+	opts, err := ParseOptions(formValues(map[string]string{
+		"language":    " DE ",
+		"prompt":      "Ramble, PocketBase",
+		"temperature": "0.2",
+	}))
+	if err != nil {
+		t.Fatalf("ParseOptions failed: %v", err)
+	}
+	if opts.Language != "de" || opts.Prompt != "Ramble, PocketBase" || opts.Temperature == nil || *opts.Temperature != 0.2 || opts.Translate {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	if opts, err := ParseOptions(formValues(nil)); err != nil || !opts.IsZero() {
+		t.Errorf("Expected zero options without form values, got %+v (%v)", opts, err)
+	}
+}
+
+func TestParseOptionsRejectsInvalidValues(t *testing.T) {
+	tests := map[string]map[string]string{
+		"language not a code":    {"language": "english"},
+		"language and translate": {"language": "fr", "translate": "true"},
+		"prompt too long":        {"prompt": strings.Repeat("a", MaxPromptLength+1)},
+		"temperature not number": {"temperature": "warm"},
+		"temperature too high":   {"temperature": "1.5"},
+		"temperature negative":   {"temperature": "-0.1"},
+		"translate not a bool":   {"translate": "maybe"},
+		"preprocess not a bool":  {"preprocess": "smaller"},
+	}
+	for name, values := range tests {
+		if _, err := ParseOptions(formValues(values)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTranslationEndpoint(t *testing.T) {
+	got, err := translationEndpoint(DefaultEndpoint)
+	if err != nil || got != "https://api.openai.com/v1/audio/translations" {
+		t.Errorf("Expected the translations endpoint, got %q (%v)", got, err)
+	}
+	azure := "https://example.openai.azure.com/openai/deployments/whisper/audio/transcriptions?api-version=2024-06-01"
+	got, err = translationEndpoint(azure)
+	if err != nil || got != "https://example.openai.azure.com/openai/deployments/whisper/audio/translations?api-version=2024-06-01" {
+		t.Errorf("Expected the query to be kept, got %q (%v)", got, err)
+	}
+	if _, err := translationEndpoint("http://localhost:8080/inference"); err == nil {
+		t.Error("Expected an error for an endpoint without a translations counterpart")
+	}
+}
+
+func TestTranscribePassesOptions(t *testing.T) {
+	server := newTestServer(t, func(r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Failed to parse multipart body: %v", err)
+			return
+		}
+		if r.URL.Path != "/v1/audio/translations" {
+			t.Errorf("Expected the translations endpoint, got %s", r.URL.Path)
+		}
+		for field, want := range map[string]string{
+			"prompt":                    "Ramble",
+			"temperature":               "0.4",
+			"language":                  "",
+			"timestamp_granularities[]": "",
+		} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("Expected %s=%q, got %q", field, want, got)
+			}
+		}
+	})
+	client := newTestClient(server)
+	client.Endpoint = server.URL + "/v1/audio/transcriptions"
+
+	temperature := 0.4
+	ctx := WithOptions(context.Background(), Options{Prompt: "Ramble", Temperature: &temperature, Translate: true})
+	if _, err := client.Transcribe(ctx, bytes.NewReader([]byte("audio")), "clip.mp3"); err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+}
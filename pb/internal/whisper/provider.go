@@ -0,0 +1,164 @@
+package whisper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/metrics"
+)
+
+// Provider names, used in TRANSCRIPTION_PROVIDER, per-request overrides and as circuit breaker names
+const (
+	ProviderOpenAI     = "openai"
+	ProviderFallback   = "fallback" // any Whisper-compatible API, see NewFallbackClientFromEnv
+	ProviderDeepgram   = "deepgram"
+	ProviderAssemblyAI = "assemblyai"
+	ProviderWhisperCpp = "whispercpp"
+)
+
+// Provider is a transcription backend. Implementations map their own word and segment
+// format onto Result, so callers never see provider-specific responses
+type Provider interface {
+	Name() string
+	Transcribe(ctx context.Context, src io.Reader, filename string) (*Result, error)
+}
+
+// ProviderFromEnv creates a provider by name from its environment variables
+func ProviderFromEnv(name string) (Provider, error) {
+	switch name {
+	case ProviderOpenAI:
+		return NewClientFromEnv()
+	case ProviderFallback:
+		if client := NewFallbackClientFromEnv(); client != nil {
+			return client, nil
+		}
+		return nil, fmt.Errorf("fallback transcription provider not configured")
+	case ProviderDeepgram:
+		return NewDeepgramFromEnv()
+	case ProviderAssemblyAI:
+		return NewAssemblyAIFromEnv()
+	case ProviderWhisperCpp:
+		return NewWhisperCppFromEnv()
+	default:
+		return nil, fmt.Errorf("unknown transcription provider %q", name)
+	}
+}
+
+// ProviderChain returns the providers tried in order: TRANSCRIPTION_PROVIDER (default openai)
+// followed by TRANSCRIPTION_FALLBACK_PROVIDERS, a comma separated list that defaults to the
+// Whisper-compatible fallback when WHISPER_FALLBACK_ENDPOINT is set. Unconfigured fallbacks are skipped
+func ProviderChain() []string {
+	primary := strings.TrimSpace(os.Getenv("TRANSCRIPTION_PROVIDER"))
+	if primary == "" {
+		primary = ProviderOpenAI
+	}
+
+	fallbacks := os.Getenv("TRANSCRIPTION_FALLBACK_PROVIDERS")
+	if fallbacks == "" && NewFallbackClientFromEnv() != nil {
+		fallbacks = ProviderFallback
+	}
+
+	chain := []string{primary}
+	for _, name := range strings.Split(fallbacks, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || containsProvider(chain, name) {
+			continue
+		}
+		if _, err := ProviderFromEnv(name); err != nil {
+			log.Printf("⚠️  [TRANSCRIPTION] Skipping fallback provider %s: %v", name, err)
+			continue
+		}
+		chain = append(chain, name)
+	}
+	return chain
+}
+
+// HasFailover reports whether another provider takes over while the given one is down
+func HasFailover(name string) bool {
+	chain := ProviderChain()
+	return containsProvider(chain, name) && len(chain) > 1
+}
+
+// ValidateProvider checks a per-request provider override names a configured provider
+func ValidateProvider(name string) error {
+	if name == "" {
+		return nil
+	}
+	_, err := ProviderFromEnv(name)
+	return err
+}
+
+type providerKey struct{}
+
+// WithProvider asks Transcribe to try the named provider first for this request, before the
+// configured chain. An empty name keeps the configured order
+func WithProvider(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, providerKey{}, name)
+}
+
+// providersFor returns the provider names to try for a request, the override first
+func providersFor(ctx context.Context) []string {
+	chain := ProviderChain()
+	override, _ := ctx.Value(providerKey{}).(string)
+	if override == "" {
+		return chain
+	}
+
+	names := []string{override}
+	for _, name := range chain {
+		if name != override {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Transcribe sends audio to the first provider whose circuit breaker allows it. The audio
+// is streamed, so a failed request isn't retried elsewhere - providers whose breaker is open
+// are skipped instead, and with every breaker open it fails fast with a *circuit.OutageError
+// without touching the upload
+func Transcribe(ctx context.Context, src io.Reader, filename string) (*Result, error) {
+	names := providersFor(ctx)
+
+	var outage *circuit.OutageError
+	for _, name := range names {
+		provider, err := ProviderFromEnv(name)
+		if err != nil {
+			return nil, err
+		}
+
+		breaker := circuit.For(provider.Name())
+		if !breaker.Allow() {
+			if outage == nil {
+				outage = &circuit.OutageError{Provider: provider.Name(), RetryAfter: breaker.RetryAfter()}
+			}
+			continue
+		}
+
+		start := time.Now()
+		result, err := provider.Transcribe(ctx, src, filename)
+		breaker.Record(err)
+		metrics.ObserveUpstream(provider.Name(), start, err)
+		return result, err
+	}
+
+	return nil, outage
+}
+
+func containsProvider(names []string, name string) bool {
+	for _, existing := range names {
+		if existing == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderChain(t *testing.T) {
+	t.Setenv("TRANSCRIPTION_PROVIDER", "")
+	t.Setenv("TRANSCRIPTION_FALLBACK_PROVIDERS", "")
+	t.Setenv("WHISPER_FALLBACK_ENDPOINT", "")
+	t.Setenv("WHISPER_FALLBACK_API_KEY", "")
+	t.Setenv("DEEPGRAM_API_KEY", "")
+	t.Setenv("WHISPER_CPP_ENDPOINT", "")
+
+	if got := ProviderChain(); !reflect.DeepEqual(got, []string{ProviderOpenAI}) {
+		t.Errorf("default chain = %v", got)
+	}
+
+	t.Setenv("WHISPER_FALLBACK_ENDPOINT", "https://fallback.example/v1/audio/transcriptions")
+	t.Setenv("WHISPER_FALLBACK_API_KEY", "key")
+	if got := ProviderChain(); !reflect.DeepEqual(got, []string{ProviderOpenAI, ProviderFallback}) {
+		t.Errorf("chain with Whisper fallback = %v", got)
+	}
+
+	// Unconfigured fallbacks and duplicates are skipped
+	t.Setenv("TRANSCRIPTION_PROVIDER", ProviderDeepgram)
+	t.Setenv("TRANSCRIPTION_FALLBACK_PROVIDERS", "whispercpp, deepgram, openai")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	if got := ProviderChain(); !reflect.DeepEqual(got, []string{ProviderDeepgram, ProviderOpenAI}) {
+		t.Errorf("configured chain = %v", got)
+	}
+	if !HasFailover(ProviderDeepgram) || HasFailover(ProviderAssemblyAI) {
+		t.Error("HasFailover should only cover providers in a chain with a fallback")
+	}
+
+	ctx := WithProvider(context.Background(), ProviderOpenAI)
+	if got := providersFor(ctx); !reflect.DeepEqual(got, []string{ProviderOpenAI, ProviderDeepgram}) {
+		t.Errorf("providers with override = %v", got)
+	}
+}
+
+func TestValidateProvider(t *testing.T) {
+	t.Setenv("DEEPGRAM_API_KEY", "")
+	if err := ValidateProvider(""); err != nil {
+		t.Errorf("empty override: %v", err)
+	}
+	if err := ValidateProvider("nope"); err == nil {
+		t.Error("expected an unknown provider to be rejected")
+	}
+	if err := ValidateProvider(ProviderDeepgram); err == nil {
+		t.Error("expected an unconfigured provider to be rejected")
+	}
+}
+
+func TestDeepgramMapsWordsAndUtterances(t *testing.T) {
+	audio := []byte("fake audio")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Token dg-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.URL.Query().Get("model"); got != "nova-2" {
+			t.Errorf("model = %q", got)
+		}
+		if received, _ := io.ReadAll(r.Body); !bytes.Equal(received, audio) {
+			t.Errorf("body = %q, want the raw audio", received)
+		}
+		io.WriteString(w, `{
+			"metadata": {"duration": 2.5},
+			"results": {
+				"channels": [{"detected_language": "en", "alternatives": [{"transcript": "hello world",
+					"words": [{"word": "hello", "punctuated_word": "Hello", "start": 0.1, "end": 0.4}, {"word": "world", "start": 0.5, "end": 0.9}]}]}],
+				"utterances": [{"start": 0.1, "end": 0.9, "transcript": "Hello world.", "words": [{"word": "hello", "start": 0.1, "end": 0.4}]}]
+			}
+		}`)
+	}))
+	defer server.Close()
+
+	deepgram := &Deepgram{APIKey: "dg-key", Endpoint: server.URL, Model: "nova-2", HTTPClient: server.Client()}
+	result, err := deepgram.Transcribe(context.Background(), bytes.NewReader(audio), "clip.mp3")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if result.Transcript != "hello world" || result.Duration != 2.5 || result.Language != "en" || result.Provider != ProviderDeepgram {
+		t.Errorf("result = %+v", result)
+	}
+	wantWords := []Word{{Word: "Hello", Start: 0.1, End: 0.4}, {Word: "world", Start: 0.5, End: 0.9}}
+	if !reflect.DeepEqual(result.Words, wantWords) {
+		t.Errorf("words = %+v", result.Words)
+	}
+	if len(result.Segments) != 1 || result.Segments[0].Text != "Hello world." || result.Segments[0].End != 0.9 {
+		t.Errorf("segments = %+v", result.Segments)
+	}
+}
+
+func TestAssemblyAIPollsUntilCompleted(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "aai-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.URL.Path {
+		case "/upload":
+			io.Copy(io.Discard, r.Body)
+			io.WriteString(w, `{"upload_url": "https://cdn.example/audio"}`)
+		case "/transcript":
+			var request map[string]any
+			json.NewDecoder(r.Body).Decode(&request)
+			if request["audio_url"] != "https://cdn.example/audio" {
+				t.Errorf("audio_url = %v", request["audio_url"])
+			}
+			io.WriteString(w, `{"id": "t1", "status": "queued"}`)
+		case "/transcript/t1":
+			polls++
+			if polls < 2 {
+				io.WriteString(w, `{"id": "t1", "status": "processing"}`)
+				return
+			}
+			io.WriteString(w, `{"id": "t1", "status": "completed", "text": "Hi there.", "language_code": "en", "audio_duration": 3,
+				"words": [{"text": "Hi", "start": 100, "end": 400}, {"text": "there.", "start": 500, "end": 900}]}`)
+		case "/transcript/t1/sentences":
+			io.WriteString(w, `{"sentences": [{"text": "Hi there.", "start": 100, "end": 900, "words": []}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	assembly := &AssemblyAI{APIKey: "aai-key", Endpoint: server.URL, PollInterval: time.Millisecond, HTTPClient: server.Client()}
+	result, err := assembly.Transcribe(context.Background(), bytes.NewReader([]byte("audio")), "clip.mp3")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if result.Transcript != "Hi there." || result.Duration != 3 || polls != 2 {
+		t.Errorf("result = %+v after %d polls", result, polls)
+	}
+	if len(result.Words) != 2 || result.Words[1].Start != 0.5 || result.Words[1].End != 0.9 {
+		t.Errorf("words = %+v, want seconds", result.Words)
+	}
+	if len(result.Segments) != 1 || result.Segments[0].Start != 0.1 {
+		t.Errorf("segments = %+v", result.Segments)
+	}
+}
+
+func TestAssemblyAIReportsTranscriptErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		switch r.URL.Path {
+		case "/upload":
+			io.WriteString(w, `{"upload_url": "https://cdn.example/audio"}`)
+		default:
+			io.WriteString(w, `{"id": "t1", "status": "error", "error": "audio too short"}`)
+		}
+	}))
+	defer server.Close()
+
+	assembly := &AssemblyAI{APIKey: "aai-key", Endpoint: server.URL, PollInterval: time.Millisecond, HTTPClient: server.Client()}
+	if _, err := assembly.Transcribe(context.Background(), bytes.NewReader([]byte("audio")), "clip.mp3"); err == nil {
+		t.Fatal("expected the transcript error to be returned")
+	}
+}
+
+func TestWhisperCppCollectsSegmentWords(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("multipart: %v", err)
+		}
+		if got := r.FormValue("response_format"); got != "verbose_json" {
+			t.Errorf("response_format = %q", got)
+		}
+		io.WriteString(w, `{"text": "one two", "language": "english", "duration": 1.2, "segments": [
+			{"id": 0, "start": 0, "end": 0.6, "text": "one", "words": [{"word": "one", "start": 0, "end": 0.5}]},
+			{"id": 1, "start": 0.6, "end": 1.2, "text": "two", "words": [{"word": "two", "start": 0.6, "end": 1.1}]}]}`)
+	}))
+	defer server.Close()
+
+	local := &WhisperCpp{Endpoint: server.URL, HTTPClient: server.Client()}
+	result, err := local.Transcribe(context.Background(), bytes.NewReader([]byte("audio")), "clip.wav")
+	if err != nil {
+		t.Fatalf("Transcribe: %v", err)
+	}
+	if len(result.Words) != 2 || result.Words[1].Word != "two" || len(result.Segments) != 2 || result.Provider != ProviderWhisperCpp {
+		t.Errorf("result = %+v", result)
+	}
+}
@@ -0,0 +1,214 @@
+package whisper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/gateway"
+)
+
+// DefaultEndpoint is the OpenAI transcription API, used unless OPENAI_BASE_URL overrides it
+const DefaultEndpoint = gateway.DefaultOpenAIBaseURL + "/audio/transcriptions"
+
+// DefaultModel is the Whisper model used for transcriptions
+const DefaultModel = "whisper-1"
+
+// BreakerName identifies the OpenAI transcription circuit breaker
+const BreakerName = ProviderOpenAI
+
+// copyBufferSize is the size of the pooled buffers used to move audio bytes to the provider
+const copyBufferSize = 32 * 1024
+
+// copyBuffers reuses copy buffers across concurrent transcriptions
+var copyBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+// Client streams audio from any reader to the Whisper API
+type Client struct {
+	Key        string // provider name, see ProviderFromEnv
+	Provider   string // name used in errors and logs
+	APIKey     string
+	AuthHeader string // header carrying APIKey, see gateway.Auth
+	Endpoint   string
+	Model      string
+	HTTPClient *http.Client
+}
+
+// NewClientFromEnv creates a client using OPENAI_API_KEY, sent to OPENAI_BASE_URL when set - an
+// Azure OpenAI deployment or a self-hosted Whisper-compatible gateway
+func NewClientFromEnv() (*Client, error) {
+	openAI := gateway.OpenAIFromEnv()
+	if openAI.APIKey == "" {
+		return nil, fmt.Errorf("OpenAI API key not configured")
+	}
+
+	return &Client{
+		Key:        ProviderOpenAI,
+		Provider:   "OpenAI",
+		APIKey:     openAI.APIKey,
+		AuthHeader: openAI.Header,
+		Endpoint:   openAI.URL("/audio/transcriptions"),
+		Model:      DefaultModel,
+		HTTPClient: &http.Client{Timeout: 120 * time.Second}, // Longer timeout for large files
+	}, nil
+}
+
+// NewFallbackClientFromEnv creates a client for a Whisper-compatible fallback provider
+// from WHISPER_FALLBACK_ENDPOINT, WHISPER_FALLBACK_API_KEY, WHISPER_FALLBACK_AUTH_HEADER and
+// WHISPER_FALLBACK_MODEL, returning nil when no fallback is configured
+func NewFallbackClientFromEnv() *Client {
+	endpoint := os.Getenv("WHISPER_FALLBACK_ENDPOINT")
+	apiKey := os.Getenv("WHISPER_FALLBACK_API_KEY")
+	if endpoint == "" || apiKey == "" {
+		return nil
+	}
+
+	model := os.Getenv("WHISPER_FALLBACK_MODEL")
+	if model == "" {
+		model = DefaultModel
+	}
+
+	return &Client{
+		Key:        ProviderFallback,
+		Provider:   "Fallback transcription",
+		APIKey:     apiKey,
+		AuthHeader: os.Getenv("WHISPER_FALLBACK_AUTH_HEADER"),
+		Endpoint:   endpoint,
+		Model:      model,
+		HTTPClient: &http.Client{Timeout: 120 * time.Second},
+	}
+}
+
+// Name implements Provider
+func (c *Client) Name() string {
+	return c.Key
+}
+
+// Transcribe streams audio from src to the provider and parses the verbose JSON response.
+// The upload source is piped straight into the request body: bytes are only read from src
+// as fast as the provider accepts them, so memory use stays at one copy buffer per request
+// regardless of the audio size.
+// Options attached with WithOptions are passed through; Translate sends the audio to the
+// translations endpoint, which doesn't return word timestamps
+func (c *Client) Transcribe(ctx context.Context, src io.Reader, filename string) (*Result, error) {
+	opts := OptionsFrom(ctx)
+	endpoint := c.Endpoint
+	fields := [][2]string{
+		{"model", c.Model},
+		// Verbose JSON with word-level timestamps
+		{"response_format", "verbose_json"},
+	}
+	if opts.Translate {
+		var err error
+		if endpoint, err = translationEndpoint(c.Endpoint); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Provider, err)
+		}
+	} else {
+		fields = append(fields, [2]string{"timestamp_granularities[]", "word"})
+	}
+	fields = append(fields, opts.fields()...)
+
+	body, contentType := newMultipartStream(src, filename, fields)
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	gateway.Auth{APIKey: c.APIKey, Header: c.AuthHeader}.Apply(req.Header)
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &circuit.StatusError{Provider: c.Provider, StatusCode: resp.StatusCode, Body: string(errorBody)}
+	}
+
+	var transcriptionResp TranscriptionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&transcriptionResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &Result{
+		Transcript: transcriptionResp.Text,
+		Duration:   transcriptionResp.Duration,
+		Language:   transcriptionResp.Language,
+		Words:      transcriptionResp.Words,
+		Segments:   transcriptionResp.Segments,
+		Provider:   c.Key,
+		Model:      c.Model,
+	}, nil
+}
+
+// translationEndpoint returns the translations endpoint next to a transcriptions endpoint
+func translationEndpoint(endpoint string) (string, error) {
+	path, query, hasQuery := strings.Cut(endpoint, "?")
+	if !strings.HasSuffix(path, "/audio/transcriptions") {
+		return "", fmt.Errorf("translation is not supported by %s", endpoint)
+	}
+	translations := strings.TrimSuffix(path, "transcriptions") + "translations"
+	if hasQuery {
+		translations += "?" + query
+	}
+	return translations, nil
+}
+
+// newMultipartStream returns a reader producing the multipart request body for src.
+// io.Pipe is unbuffered, so the writer goroutine blocks until the HTTP client reads,
+// which gives natural backpressure between the upload source and the provider.
+func newMultipartStream(src io.Reader, filename string, fields [][2]string) (io.ReadCloser, string) {
+	pipeReader, pipeWriter := io.Pipe()
+	multipartWriter := multipart.NewWriter(pipeWriter)
+
+	go func() {
+		err := writeMultipart(multipartWriter, src, filename, fields)
+		if err == nil {
+			err = multipartWriter.Close()
+		}
+		pipeWriter.CloseWithError(err)
+	}()
+
+	return pipeReader, multipartWriter.FormDataContentType()
+}
+
+// writeMultipart writes the audio file part followed by the transcription options
+func writeMultipart(w *multipart.Writer, src io.Reader, filename string, fields [][2]string) error {
+	fileWriter, err := w.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	if _, err := io.CopyBuffer(fileWriter, src, *buf); err != nil {
+		return fmt.Errorf("failed to copy file: %w", err)
+	}
+
+	for _, field := range fields {
+		if err := w.WriteField(field[0], field[1]); err != nil {
+			return fmt.Errorf("failed to write %s field: %w", field[0], err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package whisper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// audioSize is the size of the fake audio file used by the benchmarks (~10 minutes of 128kbps MP3)
+const audioSize = 10 << 20
+
+// newTestServer returns a fake transcription API that drains the request body
+func newTestServer(t testing.TB, inspect func(r *http.Request)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inspect != nil {
+			inspect(r)
+		} else {
+			io.Copy(io.Discard, r.Body)
+		}
+		json.NewEncoder(w).Encode(TranscriptionResponse{Text: "hello world", Duration: 1.5, Language: "english"})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(server *httptest.Server) *Client {
+	return &Client{
+		APIKey:     "test-key",
+		Endpoint:   server.URL,
+		Model:      DefaultModel,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func TestTranscribeStreamsMultipartForm(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xFF, 0xFB}, 100*1024)
+
+	server := newTestServer(t, func(r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Expected bearer auth header, got %q", got)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("Failed to parse multipart body: %v", err)
+			return
+		}
+		for field, want := range map[string]string{
+			"model":                     DefaultModel,
+			"response_format":           "verbose_json",
+			"timestamp_granularities[]": "word",
+		} {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("Expected %s=%q, got %q", field, want, got)
+			}
+		}
+
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("Missing file part: %v", err)
+			return
+		}
+		defer file.Close()
+		if header.Filename != "clip.mp3" {
+			t.Errorf("Expected filename clip.mp3, got %q", header.Filename)
+		}
+		received, _ := io.ReadAll(file)
+		if !bytes.Equal(received, audio) {
+			t.Errorf("Expected %d audio bytes, got %d", len(audio), len(received))
+		}
+	})
+
+	result, err := newTestClient(server).Transcribe(context.Background(), bytes.NewReader(audio), "/tmp/uploads/clip.mp3")
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+	if result.Transcript != "hello world" || result.Duration != 1.5 || result.Language != "english" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
+
+func TestTranscribeReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		http.Error(w, "invalid file format", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server).Transcribe(context.Background(), bytes.NewReader([]byte("not audio")), "clip.mp3")
+	if err == nil {
+		t.Fatal("Expected an error for a non-200 response")
+	}
+}
+
+// zeroReader produces size bytes without holding them in memory, like a file on disk
+type zeroReader struct{ remaining int64 }
+
+func (z *zeroReader) Read(p []byte) (int, error) {
+	if z.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > z.remaining {
+		p = p[:z.remaining]
+	}
+	clear(p)
+	z.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+// transcribeBuffered is the non-streaming baseline: the whole multipart body is built in memory first
+func transcribeBuffered(c *Client, src io.Reader, filename string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writeMultipart(writer, src, filename, [][2]string{{"model", c.Model}}); err != nil {
+		return err
+	}
+	writer.Close()
+
+	req, err := http.NewRequest(http.MethodPost, c.Endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// runConcurrent runs fn from 8 goroutines at once to model concurrent transcriptions
+func runConcurrent(b *testing.B, fn func() error) {
+	b.SetBytes(audioSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := fn(); err != nil {
+					b.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkTranscribeStreaming measures the piped upload: bytes/op stays near one copy buffer per request
+func BenchmarkTranscribeStreaming(b *testing.B) {
+	client := newTestClient(newTestServer(b, nil))
+	runConcurrent(b, func() error {
+		_, err := client.Transcribe(context.Background(), &zeroReader{remaining: audioSize}, "clip.mp3")
+		return err
+	})
+}
+
+// BenchmarkTranscribeBuffered measures the previous approach of holding the full body in memory
+func BenchmarkTranscribeBuffered(b *testing.B) {
+	client := newTestClient(newTestServer(b, nil))
+	runConcurrent(b, func() error {
+		return transcribeBuffered(client, &zeroReader{remaining: audioSize}, "clip.mp3")
+	})
+}
@@ -0,0 +1,49 @@
+// Package whisper is the shared transcription layer: the Result, Word and Segment types every
+// backend maps its response onto, and the streaming multipart client for Whisper-compatible APIs.
+// The audio endpoints, resumable uploads and background jobs all transcribe through it
+package whisper
+
+// Result represents the result of audio processing
+type Result struct {
+	Transcript string    `json:"transcript"`
+	Duration   float64   `json:"duration,omitempty"`
+	Language   string    `json:"language,omitempty"`
+	Words      []Word    `json:"words,omitempty"`
+	Segments   []Segment `json:"segments,omitempty"`
+	Provider   string    `json:"provider,omitempty"` // backend that produced the transcript
+	Model      string    `json:"model,omitempty"`
+	Speakers   []string  `json:"speakers,omitempty"` // diarized speakers, in order of first appearance
+}
+
+// Word represents a word with timestamps
+type Word struct {
+	Word  string  `json:"word"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// Segment represents a segment with timestamps
+type Segment struct {
+	ID               int     `json:"id"`
+	Seek             int     `json:"seek"`
+	Start            float64 `json:"start"`
+	End              float64 `json:"end"`
+	Text             string  `json:"text"`
+	Tokens           []int   `json:"tokens"`
+	Temperature      float64 `json:"temperature"`
+	AvgLogprob       float64 `json:"avg_logprob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+	Words            []Word  `json:"words"`
+	Speaker          string  `json:"speaker,omitempty"` // set when the audio was diarized
+}
+
+// TranscriptionResponse represents the response from OpenAI transcription API
+type TranscriptionResponse struct {
+	Task     string    `json:"task"`
+	Language string    `json:"language"`
+	Duration float64   `json:"duration"`
+	Text     string    `json:"text"`
+	Segments []Segment `json:"segments"`
+	Words    []Word    `json:"words"`
+}
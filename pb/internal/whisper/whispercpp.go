@@ -0,0 +1,98 @@
+package whisper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"pocketbase/internal/circuit"
+)
+
+// WhisperCpp transcribes with a self-hosted whisper.cpp server (examples/server), which takes
+// the same multipart upload as OpenAI on its /inference endpoint
+type WhisperCpp struct {
+	Endpoint   string
+	APIKey     string // optional, for servers behind an authenticating proxy
+	HTTPClient *http.Client
+}
+
+// NewWhisperCppFromEnv creates a whisper.cpp provider from WHISPER_CPP_ENDPOINT
+// (e.g. http://whisper:8080/inference) and the optional WHISPER_CPP_API_KEY
+func NewWhisperCppFromEnv() (*WhisperCpp, error) {
+	endpoint := os.Getenv("WHISPER_CPP_ENDPOINT")
+	if endpoint == "" {
+		return nil, fmt.Errorf("whisper.cpp endpoint not configured")
+	}
+
+	return &WhisperCpp{
+		Endpoint: endpoint,
+		APIKey:   os.Getenv("WHISPER_CPP_API_KEY"),
+		// Local CPU inference is much slower than the hosted APIs
+		HTTPClient: &http.Client{Timeout: 30 * time.Minute},
+	}, nil
+}
+
+// Name implements Provider
+func (w *WhisperCpp) Name() string {
+	return ProviderWhisperCpp
+}
+
+// Transcribe implements Provider
+func (w *WhisperCpp) Transcribe(ctx context.Context, src io.Reader, filename string) (*Result, error) {
+	opts := OptionsFrom(ctx)
+	fields := [][2]string{{"response_format", "verbose_json"}}
+	if opts.Temperature == nil {
+		fields = append(fields, [2]string{"temperature", "0.0"})
+	}
+	if opts.Translate {
+		fields = append(fields, [2]string{"translate", "true"})
+	}
+	body, contentType := newMultipartStream(src, filename, append(fields, opts.fields()...))
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.Endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.APIKey)
+	}
+
+	resp, err := w.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, &circuit.StatusError{Provider: "whisper.cpp", StatusCode: resp.StatusCode, Body: string(errorBody)}
+	}
+
+	var transcriptionResp TranscriptionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&transcriptionResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// whisper.cpp only reports words inside segments
+	words := transcriptionResp.Words
+	if len(words) == 0 {
+		for _, segment := range transcriptionResp.Segments {
+			words = append(words, segment.Words...)
+		}
+	}
+
+	return &Result{
+		Transcript: transcriptionResp.Text,
+		Duration:   transcriptionResp.Duration,
+		Language:   transcriptionResp.Language,
+		Words:      words,
+		Segments:   transcriptionResp.Segments,
+		Provider:   ProviderWhisperCpp,
+	}, nil
+}
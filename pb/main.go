@@ -1,67 +1,268 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/pocketbase/pocketbase"
-	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/plugins/migratecmd"
+	"github.com/spf13/cobra"
 	"github.com/stripe/stripe-go/v79"
 
+	"pocketbase/internal/accounts"
+	adminhandlers "pocketbase/internal/admin"
 	aihandlers "pocketbase/internal/ai"
+	"pocketbase/internal/analytics"
+	"pocketbase/internal/apisign"
 	bannerhandlers "pocketbase/internal/banners"
+	"pocketbase/internal/circuit"
+	"pocketbase/internal/communications"
+	"pocketbase/internal/costs"
+	"pocketbase/internal/cors"
+	"pocketbase/internal/diagnostics"
+	"pocketbase/internal/doctor"
+	"pocketbase/internal/email"
+	"pocketbase/internal/health"
 	"pocketbase/internal/jobs"
+	"pocketbase/internal/keyguard"
+	"pocketbase/internal/logging"
+	"pocketbase/internal/metrics"
+	"pocketbase/internal/moderation"
+	"pocketbase/internal/notifications"
+	"pocketbase/internal/openapi"
+	orghandlers "pocketbase/internal/organizations"
 	otphandlers "pocketbase/internal/otp"
 	"pocketbase/internal/payment"
+	"pocketbase/internal/plancatalog"
 	paymenthandlers "pocketbase/internal/payment"
+	"pocketbase/internal/progress"
+	"pocketbase/internal/reconcile"
+	"pocketbase/internal/rls"
+	"pocketbase/internal/retention"
+	"pocketbase/internal/routes"
+	"pocketbase/internal/schemacheck"
+	"pocketbase/internal/secrets"
 	"pocketbase/internal/seeder"
+	"pocketbase/internal/sla"
 	"pocketbase/internal/subscription"
+	"pocketbase/internal/tus"
+	"pocketbase/internal/warehouse"
+	"pocketbase/internal/webhooks"
+	"pocketbase/internal/whisper"
 	subscriptionhandlers "pocketbase/internal/subscription"
+	"pocketbase/migrations"
 	"pocketbase/webauthn"
 )
 
+// secretFields are credential fields stored encrypted with SECRETS_ENCRYPTION_KEYS
+var secretFields = []secrets.Field{
+	apisign.SigningSecretField,
+	webhooks.SecretField,
+}
+
+// schemaModules lists the schema requirements of every module, checked at bootstrap and by doctor
+func schemaModules() []schemacheck.Module {
+	return []schemacheck.Module{
+		aihandlers.SchemaRequirements(),
+		subscription.SchemaRequirements(),
+		payment.SchemaRequirements(),
+		otphandlers.SchemaRequirements(),
+		orghandlers.SchemaRequirements(),
+		sla.SchemaRequirements(),
+		adminhandlers.SchemaRequirements(),
+		tus.SchemaRequirements(),
+		retention.SchemaRequirements(),
+		communications.SchemaRequirements(),
+		accounts.SchemaRequirements(),
+		jobs.SchemaRequirements(),
+		bannerhandlers.SchemaRequirements(),
+		warehouse.SchemaRequirements(),
+		webhooks.SchemaRequirements(),
+		progress.SchemaRequirements(),
+		costs.SchemaRequirements(),
+		analytics.SchemaRequirements(),
+		keyguard.SchemaRequirements(),
+		notifications.SchemaRequirements(),
+		moderation.SchemaRequirements(),
+		email.SchemaRequirements(),
+		plancatalog.SchemaRequirements(),
+	}
+}
+
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
+	// Load environment variables, then set up JSON logging (LOG_LEVEL, LOG_FORMAT) from them
+	envErr := godotenv.Load()
+	logging.Setup()
+	if envErr != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
 	app := pocketbase.New()
 
-	// Load schema after the app is fully bootstrapped
-	app.OnBootstrap().BindFunc(func(be *core.BootstrapEvent) error {
-		// Call Next first to ensure the database is fully initialized
-		if err := be.Next(); err != nil {
-			return err
-		}
-		
-		// Now load the schema
-		if err := loadSchemaFromJSON(app); err != nil {
-			log.Printf("Warning: Failed to load schema: %v", err)
-		}
-		
-		// Ensure database constraints for subscription integrity
-		if err := ensureSubscriptionConstraints(app); err != nil {
-			log.Printf("Warning: Failed to create subscription constraints: %v", err)
-		}
-		
-		// Note: Subscription user seeding moved to OnServe to run after development user creation
-		
-		return nil
+	// Schema changes are Go migrations in ./migrations, applied by `serve` before it starts and
+	// managed with `pocketbase migrate up|down|history-sync`. In development, collection changes
+	// made in the Admin UI are written out as new migration files
+	migratecmd.MustRegister(app, app.RootCmd, migratecmd.Config{
+		Automigrate: os.Getenv("DEVELOPMENT") == "true",
 	})
 
 	// Configure Stripe
 	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
 
+	// `pocketbase doctor` validates credentials, provider connectivity, schema and data dirs
+	app.RootCmd.AddCommand(&cobra.Command{
+		Use:   "doctor",
+		Short: "Check configuration, provider credentials and schema before serving traffic",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report := doctor.Run(cmd.Context(), app, doctor.Options{
+				Development:   os.Getenv("DEVELOPMENT") == "true",
+				SchemaModules: schemaModules(),
+			})
+			report.Print(cmd.OutOrStdout())
+			if failures := report.Count(doctor.StatusFail); failures > 0 {
+				return fmt.Errorf("%d checks failed", failures)
+			}
+			return nil
+		},
+	})
+
+	// `pocketbase reconcile-stripe` reports (and with --repair fixes) drift between Stripe and
+	// payment_customers/current_user_subscriptions, e.g. after missed webhooks
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile-stripe",
+		Short: "Compare Stripe customers and subscriptions with the database and optionally repair drift",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if os.Getenv("STRIPE_SECRET_KEY") == "" {
+				return fmt.Errorf("STRIPE_SECRET_KEY is required")
+			}
+			repair, _ := cmd.Flags().GetBool("repair")
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			report, err := reconcile.Run(cmd.Context(), app, reconcile.Options{Repair: repair})
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				if err := report.PrintJSON(cmd.OutOrStdout()); err != nil {
+					return err
+				}
+			} else {
+				report.Print(cmd.OutOrStdout())
+			}
+			if outstanding := report.Outstanding(); outstanding > 0 {
+				return fmt.Errorf("%d differences outstanding", outstanding)
+			}
+			return nil
+		},
+	}
+	reconcileCmd.Flags().Bool("repair", false, "create missing customer mappings and resync subscriptions from Stripe")
+	reconcileCmd.Flags().Bool("json", false, "print the report as JSON")
+	app.RootCmd.AddCommand(reconcileCmd)
+
+	// `pocketbase seed` creates the missing records of seed profiles: base (subscription plans),
+	// dev (local users, API key, sample app versions and banners) and loadtest
+	seedCmd := &cobra.Command{
+		Use:   "seed",
+		Short: "Create missing seed data for the given profiles (base, dev, loadtest or all)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value, _ := cmd.Flags().GetString("profile")
+			profiles, err := seeder.ParseProfiles(value)
+			if err != nil {
+				return err
+			}
+			if len(profiles) == 0 {
+				return fmt.Errorf("no seed profile given")
+			}
+
+			opts := seeder.OptionsFromEnv()
+			opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+			if users, _ := cmd.Flags().GetInt("users"); users > 0 {
+				opts.LoadTestUsers = users
+			}
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			report, err := seeder.Run(app, profiles, opts)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				if err := report.PrintJSON(cmd.OutOrStdout()); err != nil {
+					return err
+				}
+			} else {
+				report.Print(cmd.OutOrStdout())
+			}
+			if failed := report.Count(seeder.OutcomeFailed); failed > 0 {
+				return fmt.Errorf("%d records failed to seed", failed)
+			}
+			return nil
+		},
+	}
+	seedCmd.Flags().String("profile", seeder.ProfileBase, "comma separated profiles to seed: base, dev, loadtest or all")
+	seedCmd.Flags().Bool("dry-run", false, "report what would be created without writing anything")
+	seedCmd.Flags().Int("users", 0, "users created by the loadtest profile (default SEED_LOADTEST_USERS or 50)")
+	seedCmd.Flags().Bool("json", false, "print the report as JSON")
+	app.RootCmd.AddCommand(seedCmd)
+
+	// `pocketbase sync-plans` brings subscription_plans and the Stripe products and prices in line
+	// with the plan catalog (PLAN_CATALOG_FILE, or the one built into the binary)
+	syncPlansCmd := &cobra.Command{
+		Use:   "sync-plans",
+		Short: "Create, update and archive subscription plans and Stripe prices to match the plan catalog",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			catalog, err := plancatalog.Load()
+			if err != nil {
+				return err
+			}
+
+			opts := plancatalog.Options{}
+			opts.DryRun, _ = cmd.Flags().GetBool("dry-run")
+			if noStripe, _ := cmd.Flags().GetBool("no-stripe"); !noStripe {
+				if os.Getenv("STRIPE_SECRET_KEY") == "" {
+					return fmt.Errorf("STRIPE_SECRET_KEY is required (or pass --no-stripe to sync the database only)")
+				}
+				opts.Stripe = plancatalog.StripeAPI{}
+			}
+			asJSON, _ := cmd.Flags().GetBool("json")
+
+			report, err := plancatalog.Sync(cmd.Context(), app, catalog, opts)
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				if err := report.PrintJSON(cmd.OutOrStdout()); err != nil {
+					return err
+				}
+			} else {
+				report.Print(cmd.OutOrStdout())
+			}
+			if failed := report.Failed(); failed > 0 {
+				return fmt.Errorf("%d changes failed", failed)
+			}
+			return nil
+		},
+	}
+	syncPlansCmd.Flags().Bool("dry-run", false, "report the changes without applying them")
+	syncPlansCmd.Flags().Bool("no-stripe", false, "only sync subscription_plans, leaving Stripe products and prices alone")
+	syncPlansCmd.Flags().Bool("json", false, "print the report as JSON")
+	app.RootCmd.AddCommand(syncPlansCmd)
+
 	// Register WebAuthn
 	webauthn.Register(app)
 
+	// Notify users' webhook endpoints of finished jobs, usage thresholds and subscription changes
+	webhooks.RegisterHooks(app)
+
+	// Email users about cancellations, plan changes and usage limits they haven't opted out of
+	notifications.RegisterHooks(app)
+
 	// Configure email settings on app initialization
 	if err := configureEmailSettings(app); err != nil {
 		log.Printf("[EMAIL] Failed to configure email settings: %v", err)
@@ -80,6 +281,30 @@ func main() {
 
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 
+		// Production never starts with Stripe webhooks it can't verify
+		if err := payment.RequireWebhookSecret(); err != nil {
+			return fmt.Errorf("refusing to start: %w", err)
+		}
+
+		// Serve applies the migrations before this hook - never run against a database migrated
+		// by a build this one predates
+		if err := migrations.Verify(app); err != nil {
+			return fmt.Errorf("refusing to start: %w", err)
+		}
+
+		// Ensure database constraints for subscription integrity
+		if err := ensureSubscriptionConstraints(app); err != nil {
+			log.Printf("Warning: Failed to create subscription constraints: %v", err)
+		}
+
+		// Report fields/indexes the handlers depend on that are missing from the deployed schema
+		schemacheck.Run(app, schemaModules()...)
+
+		// Verify API rules on critical collections (RLS_AUDIT_MODE=warn|fix|fail)
+		if err := rls.Run(app); err != nil {
+			return err
+		}
+
 		// Initialize services for route handlers
 		paymentService, err := payment.NewStripeService()
 		if err != nil {
@@ -87,11 +312,11 @@ func main() {
 		}
 		subscriptionRepo := subscription.NewRepository(app)
 		subscriptionService := subscription.NewService(subscriptionRepo)
+		if paymentService != nil {
+			// Plan changes, cancellations and support actions go through the payment provider
+			subscriptionService = subscription.NewServiceWithProvider(subscriptionRepo, paymentService.SubscriptionProvider())
+		}
 		
-		// Avoid unused variable errors
-		_ = paymentService
-		_ = subscriptionService
-
 		// Configure request body size limit for large audio files
 		se.Server.MaxHeaderBytes = 1 << 20  // 1MB for headers
 		se.Server.ReadTimeout = 300 * time.Second // 5 minutes for large files
@@ -104,27 +329,43 @@ func main() {
 		log.Printf("Server configured: ReadTimeout=%v, WriteTimeout=%v", 
 			se.Server.ReadTimeout, se.Server.WriteTimeout)
 
+		// Self-test before accepting traffic (STARTUP_DOCTOR=warn|fail|off, skipped in development)
+		if mode := doctor.StartupMode(); os.Getenv("DEVELOPMENT") != "true" && mode != doctor.ModeOff {
+			report := doctor.Run(context.Background(), app, doctor.Options{SchemaModules: schemaModules()})
+			var out strings.Builder
+			report.Print(&out)
+			log.Printf("[DOCTOR] Startup self-test:\n%s", out.String())
+			if failures := report.Count(doctor.StatusFail); failures > 0 && mode == doctor.ModeFail {
+				return fmt.Errorf("startup self-test failed: %d checks failed (run `pocketbase doctor` for details)", failures)
+			}
+		}
+
+		// Tag every request with an X-Request-ID that its log lines carry
+		se.Router.BindFunc(logging.Middleware())
+
+		// Verify HMAC-signed API requests; unsigned requests pass through to Bearer key checks
+		se.Router.BindFunc(apisign.Middleware(app))
+
 		// Log Whisper configuration for audio processing
 		logWhisperConfiguration()
 
-		// Seed development data if in development mode
 		isDevelopment := os.Getenv("DEVELOPMENT") == "true"
-		if isDevelopment {
-			if err := aihandlers.SeedDevelopmentData(app); err != nil {
-				log.Printf("Warning: Failed to seed development data: %v", err)
-			}
-		}
 		
 		// Validate email configuration
 		if err := validateEmailConfiguration(app); err != nil {
 			log.Printf("[EMAIL] Email configuration validation failed: %v", err)
 		}
 		
-		// Run all seeding functions through centralized seeder
-		if err := seeder.SeedAll(app); err != nil {
+		// Seed the SEED_PROFILES profiles (base and dev plans and users in development)
+		if err := seeder.RunAtStartup(app); err != nil {
 			log.Printf("Warning: Failed to run seeding: %v", err)
 		}
 
+		// Warn when the subscription plans have drifted from the plan catalog
+		if err := plancatalog.CheckAtStartup(app); err != nil {
+			log.Printf("Warning: Plan catalog check failed: %v", err)
+		}
+
 		if !isDevelopment {
 			// Production mode: Create superuser if none exists
 			if err := createSuperuserIfNeeded(app); err != nil {
@@ -137,102 +378,73 @@ func main() {
 			log.Printf("Warning: Failed to register scheduled jobs: %v", err)
 		}
 
-		// Payment routes (provider-agnostic)
-		se.Router.POST("/api/payment/checkout", func(e *core.RequestEvent) error {
-			// Default to Stripe for now, but can be extended to support multiple providers
-			return paymenthandlers.CreateCheckoutSessionHandler(e, app, paymentService)
-		})
-
-		se.Router.POST("/api/payment/portal", func(e *core.RequestEvent) error {
-			// Default to Stripe for now, but can be extended to support multiple providers
-			return paymenthandlers.CreatePortalLinkHandler(e, app, paymentService)
-		})
-
-		se.Router.POST("/api/payment/change-plan", func(e *core.RequestEvent) error {
-			// Default to Stripe for now, but can be extended to support multiple providers
-			return subscriptionhandlers.ChangePlanHandler(e, app, subscriptionService)
-		})
-
-		se.Router.GET("/api/payment/check-method", func(e *core.RequestEvent) error {
-			// Check if user has valid payment methods for direct plan changes
-			return paymenthandlers.CheckPaymentMethodHandler(e, app, paymentService)
-		})
-
-		// Payment webhook routes
-		// IMPORTANT: When adding/removing webhook endpoints, update README.md payment provider section
-		se.Router.POST("/api/webhooks/stripe", func(e *core.RequestEvent) error {
-			return paymentService.HandleWebhook(e, app)
-		})
-
-
-		// Note: Using PocketBase's built-in /api/health endpoint for Kamal health checks
-		// No custom health endpoint needed as PocketBase provides one out of the box
-
-		// Subscription management routes (use PocketBase SDK + RLS for GET operations)
-		se.Router.POST("/api/subscription/cancel", func(e *core.RequestEvent) error {
-			return subscriptionhandlers.CancelSubscriptionHandler(e, app, subscriptionService)
-		})
-		
-		se.Router.POST("/api/subscription/switch-to-free", func(e *core.RequestEvent) error {
-			return subscriptionhandlers.SwitchToFreePlanHandler(e, app, subscriptionService)
-		})
-
-		// OTP routes
-		se.Router.POST("/send-otp", func(e *core.RequestEvent) error {
-			return otphandlers.SendOTPHandler(e, app)
-		})
-
-		se.Router.OPTIONS("/send-otp", func(e *core.RequestEvent) error {
-			return otphandlers.SendOTPHandler(e, app)
-		})
-
-		se.Router.POST("/verify-otp", func(e *core.RequestEvent) error {
-			return otphandlers.VerifyOTPHandler(e, app)
-		})
-
-		se.Router.OPTIONS("/verify-otp", func(e *core.RequestEvent) error {
-			return otphandlers.VerifyOTPHandler(e, app)
-		})
-
-		// AI routes
-		se.Router.POST("/api/ai/process-text", func(e *core.RequestEvent) error {
-			return aihandlers.ProcessTextHandler(e, app)
-		})
-
-		// Audio processing route with streaming support and increased body limit
-		// Override the default 32MB body limit to allow up to 2GB audio files
-		se.Router.POST("/api/ai/process-audio", func(e *core.RequestEvent) error {
-			log.Printf("🎵 Processing audio upload with 2GB body limit")
-			return aihandlers.ProcessAudioHandler(e, app)
-		}).Bind(apis.BodyLimit(2 << 30)) // 2GB body limit for audio uploads
-
-		se.Router.POST("/api/generate-api-key", func(e *core.RequestEvent) error {
-			return aihandlers.GenerateAPIKeyHandler(e, app)
-		})
-
-		// Usage tracking routes for Wails app (requires API key)
-		se.Router.GET("/api/usage/summary", func(e *core.RequestEvent) error {
-			return aihandlers.UsageSummaryHandler(e, app)
-		})
-
-		se.Router.GET("/api/usage/files", func(e *core.RequestEvent) error {
-			return aihandlers.UsageFilesHandler(e, app)
-		})
+		// Encrypt credentials still stored in plaintext and move old ones onto the primary key
+		if _, err := secrets.Reseal(app, secretFields); err != nil {
+			log.Printf("Warning: Credential fields can't be encrypted: %v", err)
+		}
 
-		se.Router.GET("/api/usage/stats", func(e *core.RequestEvent) error {
-			return aihandlers.UsageStatsHandler(e, app)
+		// Transcribe audio queued with async=true in the background
+		aihandlers.StartTranscriptionWorkers(app)
+		// Fail or hand back to their jobs the processed files a restart left processing
+		aihandlers.RecoverStuckProcessing(app)
+		// Plan migrations run in the background, so a restart cuts them off
+		adminhandlers.FailInterruptedMigrations(app)
+		aihandlers.RegisterMetrics(app)
+
+		// Publish an incident banner while an AI provider is down and no fallback serves its requests
+		circuit.OnStateChange(func(provider string, state circuit.State) {
+			switch state {
+			case circuit.StateOpen:
+				if whisper.HasFailover(provider) ||
+					(provider == aihandlers.TextBreakerName && aihandlers.TextFallbackConfigured()) {
+					return
+				}
+				bannerhandlers.SyncIncident(app, provider, true)
+			case circuit.StateClosed:
+				bannerhandlers.SyncIncident(app, provider, false)
+			}
 		})
 
-		// Banner routes
-		se.Router.GET("/api/banners", func(e *core.RequestEvent) error {
-			return bannerhandlers.GetBannersHandler(e, app)
-		})
+		// The CORS policy from the environment replaces PocketBase's default (and the --origins flag)
+		// for every route, including the built-in ones
+		corsPolicy := cors.PolicyFromEnv()
+		se.Router.Bind(corsPolicy.Middleware())
 
-		se.Router.POST("/api/banners/dismiss/{id}", func(e *core.RequestEvent) error {
-			return bannerhandlers.DismissBannerHandler(e, app)
+		// Routes are declared by each module - cross-cutting middleware is opted into per route
+		registry := routes.New(app, se.Router, routes.Options{
+			CORS:       corsPolicy,
+			RateLimit:  aihandlers.RateLimitMiddleware(app),
+			Instrument: metrics.Instrument,
 		})
-
-
+		paymenthandlers.RegisterRoutes(registry, paymentService)
+		subscriptionhandlers.RegisterRoutes(registry, subscriptionService)
+		otphandlers.RegisterRoutes(registry)
+		aihandlers.RegisterRoutes(registry)
+		apisign.RegisterRoutes(registry)
+		bannerhandlers.RegisterRoutes(registry)
+		orghandlers.RegisterRoutes(registry)
+		adminhandlers.RegisterRoutes(registry, paymentService, subscriptionService)
+		communications.RegisterRoutes(registry)
+		email.RegisterRoutes(registry)
+		rls.RegisterRoutes(registry)
+		diagnostics.RegisterRoutes(registry)
+		sla.RegisterRoutes(registry)
+		retention.RegisterRoutes(registry)
+		secrets.RegisterRoutes(registry, secretFields)
+		warehouse.RegisterRoutes(registry)
+		webhooks.RegisterRoutes(registry)
+		tus.RegisterRoutes(registry)
+		metrics.RegisterRoutes(registry)
+		health.RegisterRoutes(registry)
+		accounts.RegisterRoutes(registry, paymentService)
+		costs.RegisterRoutes(registry)
+		analytics.RegisterRoutes(registry)
+		notifications.RegisterRoutes(registry)
+		// Last, so the OpenAPI document covers every route declared above
+		openapi.RegisterRoutes(registry)
+		log.Printf("Registered %d API routes", len(registry.Routes()))
+
+		diagnostics.StartProfileExporter()
 
 		// PocketBase is backend-only - no static file serving
 		// Frontend will be deployed separately
@@ -240,6 +452,9 @@ func main() {
 		return se.Next()
 	})
 
+	// Plan listings are cached in memory - drop them whenever a plan changes
+	subscription.RegisterPlanCacheHooks(app)
+
 	// Add hook to assign free plan to new users
 	app.OnRecordCreate("users").BindFunc(func(e *core.RecordEvent) error {
 		log.Printf("New user created: %s, assigning free plan...", e.Record.Id)
@@ -259,73 +474,18 @@ func main() {
 	})
 
 
-	if err := app.Start(); err != nil {
-		log.Fatal(err)
-	}
-}
+	// Deleting a user through the API deactivates the account instead, so it can be restored
+	app.OnRecordDeleteRequest("users").BindFunc(accounts.SoftDeleteRequest)
 
-// loadSchemaFromJSON loads database schema from JSON file on first run
-func loadSchemaFromJSON(app *pocketbase.PocketBase) error {
-	// Check if collections already exist (skip if database is not empty)
-	collections, err := app.FindAllCollections()
-	if err != nil {
-		return err
-	}
-	
-	// Skip loading if we already have non-system collections
-	nonSystemCount := 0
-	for _, collection := range collections {
-		if !collection.IsAuth() && !collection.System {
-			nonSystemCount++
-		}
-	}
-	
-	if nonSystemCount > 0 {
-		log.Printf("Database already contains %d collections, skipping schema import", len(collections))
-		return nil
-	}
-
-	// Try multiple possible schema file locations
-	schemaFiles := []string{
-		"./pb_bootstrap/pb_schema.json",
-		"./pb_schema.json",
-		"../pb_schema.json",
-	}
-	
-	var schemaPath string
-	for _, path := range schemaFiles {
-		if _, err := os.Stat(path); err == nil {
-			schemaPath = path
-			break
-		}
-	}
-	
-	if schemaPath == "" {
-		log.Println("No schema file found, starting with empty database")
-		return nil
-	}
-
-	log.Printf("Loading schema from: %s", schemaPath)
-	
-	// Read schema file
-	schemaData, err := os.ReadFile(schemaPath)
-	if err != nil {
-		return err
-	}
-
-	// Parse JSON to the format expected by ImportCollections
-	var collectionsData []map[string]any
-	if err := json.Unmarshal(schemaData, &collectionsData); err != nil {
-		return err
-	}
+	// Persist in-memory SLA counters before shutting down
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		sla.FlushRollups(app)
+		return e.Next()
+	})
 
-	// Import collections using PocketBase's sync functionality
-	if err := app.ImportCollections(collectionsData, true); err != nil {
-		return err
+	if err := app.Start(); err != nil {
+		log.Fatal(err)
 	}
-
-	log.Printf("Schema import completed from: %s", schemaPath)
-	return nil
 }
 
 // configureEmailSettings sets up email configuration for email verification
@@ -363,20 +523,21 @@ func configureEmailSettings(app *pocketbase.PocketBase) error {
 	}
 	app.Settings().Meta.AppURL = frontendURL
 
+	// SMTP is Mailpit in development, and the fallback behind Resend in production when set
+	if err := configureEmailSMTP(app); err != nil {
+		return err
+	}
 	if isDevelopment {
-		// Development: Use SMTP with Mailpit
-		return configureEmailSMTP(app)
-	} else {
-		// Production: Use Resend HTTP API (disable SMTP)
-		return configureEmailResend(app)
+		return nil
 	}
+	return configureEmailResend(app)
 }
 
-// configureEmailSMTP sets up SMTP configuration for development with Mailpit
+// configureEmailSMTP sets up SMTP: Mailpit in development, a fallback relay in production
 func configureEmailSMTP(app *pocketbase.PocketBase) error {
 	smtpHost := os.Getenv("SMTP_HOST")
 	if smtpHost == "" {
-		log.Println("SMTP_HOST not set, email verification disabled")
+		log.Println("SMTP_HOST not set, SMTP email delivery disabled")
 		return nil
 	}
 
@@ -396,7 +557,7 @@ func configureEmailSMTP(app *pocketbase.PocketBase) error {
 	app.Settings().SMTP.TLS = smtpTLS
 	app.Settings().SMTP.AuthMethod = "PLAIN"
 	
-	log.Printf("SMTP configured for development: %s:%d (TLS: %v)", smtpHost, smtpPort, smtpTLS)
+	log.Printf("SMTP configured: %s:%d (TLS: %v)", smtpHost, smtpPort, smtpTLS)
 	return nil
 }
 
@@ -415,10 +576,7 @@ func configureEmailResend(app *pocketbase.PocketBase) error {
 		log.Printf("[EMAIL] WARNING: RESEND_API_KEY appears to be too short (%d chars)", len(resendAPIKey))
 	}
 
-	// Disable SMTP for production - we'll use Resend HTTP API directly
-	app.Settings().SMTP.Enabled = false
-	
-	log.Printf("[EMAIL] Resend configured for production using HTTP API (SMTP disabled)")
+	log.Printf("[EMAIL] Resend configured for production using HTTP API, provider chain: %v", email.ProviderNames(app))
 	log.Printf("[EMAIL] Email sender configured - From: %s <%s>", 
 		app.Settings().Meta.SenderName, app.Settings().Meta.SenderAddress)
 	
@@ -452,49 +610,33 @@ func validateEmailConfiguration(app *pocketbase.PocketBase) error {
 		}
 		log.Printf("[EMAIL] Development SMTP configuration validated")
 	} else {
-		// Production: Check Resend API key
-		resendAPIKey := os.Getenv("RESEND_API_KEY")
-		if resendAPIKey == "" {
-			log.Printf("[EMAIL] ERROR: RESEND_API_KEY not set in production")
-			return fmt.Errorf("RESEND_API_KEY not configured for production")
+		// Production: Resend, SMTP or both need to be configured
+		providers := email.ProviderNames(app)
+		if len(providers) == 0 {
+			log.Printf("[EMAIL] ERROR: no email provider configured in production")
+			return email.ErrNotConfigured
 		}
-		
-		if len(resendAPIKey) < 10 {
+
+		if resendAPIKey := os.Getenv("RESEND_API_KEY"); resendAPIKey != "" && len(resendAPIKey) < 10 {
 			log.Printf("[EMAIL] WARNING: RESEND_API_KEY appears invalid (too short)")
 			return fmt.Errorf("RESEND_API_KEY appears invalid")
 		}
-		
-		log.Printf("[EMAIL] Production Resend configuration validated")
+		if len(providers) == 1 {
+			log.Printf("[EMAIL] WARNING: only %s is configured - emails are lost while it's down", providers[0])
+		}
+
+		log.Printf("[EMAIL] Production email configuration validated, provider chain: %v", providers)
 	}
 	
 	return nil
 }
 
-// logWhisperConfiguration logs the Whisper API configuration for audio processing
+// logWhisperConfiguration logs the audio upload limits and the provider's file size limit
 func logWhisperConfiguration() {
-	var maxSize int64
-	var source string
-	
-	if maxSizeStr := os.Getenv("WHISPER_MAX_FILE_SIZE"); maxSizeStr != "" {
-		if parsedSize, err := strconv.ParseInt(maxSizeStr, 10, 64); err == nil {
-			maxSize = parsedSize
-			source = "environment variable"
-		} else {
-			log.Printf("Warning: Invalid WHISPER_MAX_FILE_SIZE value '%s', using default", maxSizeStr)
-			maxSize = 25 * 1024 * 1024 // 25MB default
-			source = "default (invalid env var)"
-		}
-	} else {
-		maxSize = 25 * 1024 * 1024 // 25MB default
-		source = "default"
-	}
-	
-	sizeMB := float64(maxSize) / (1024 * 1024)
-	log.Printf("[WHISPER_CONFIG] Max file size: %d bytes (%.1f MB) - source: %s", maxSize, sizeMB, source)
-	
-	// Also log the PocketBase body limit for comparison
-	bodyLimitGB := float64(2<<30) / (1024 * 1024 * 1024)
-	log.Printf("[WHISPER_CONFIG] PocketBase body limit: %.0f GB for audio uploads", bodyLimitGB)
+	limits := aihandlers.UploadLimitsFromEnv()
+	log.Printf("[WHISPER_CONFIG] Max file size sent to the provider: %.1f MB", float64(limits.ProviderFile)/(1<<20))
+	log.Printf("[WHISPER_CONFIG] Audio upload limits: free plans %d MB, paid plans %d MB, ceiling %d MB",
+		limits.Free>>20, limits.Paid>>20, limits.Max>>20)
 }
 
 // createSuperuserIfNeeded creates a superuser account if none exists (for production deployment)
@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// baselineSchema is the schema as it was when migrations were introduced. It's never edited -
+// later changes are migrations of their own
+//
+//go:embed baseline_schema.json
+var baselineSchema []byte
+
+func init() {
+	m.Register(func(app core.App) error {
+		return applyBaseline(app, baselineSchema)
+	}, func(app core.App) error {
+		return errors.New("the baseline schema can't be reverted - restore a backup instead")
+	})
+}
+
+// applyBaseline imports the baseline into an empty database. Databases set up before migrations
+// existed (by the old first-run schema import or through the Admin UI) only get the collections,
+// fields and indexes they're missing, so rules and field options tuned since are kept
+func applyBaseline(app core.App, raw []byte) error {
+	var snapshot []map[string]any
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return fmt.Errorf("invalid baseline schema: %w", err)
+	}
+
+	collections, err := app.FindAllCollections()
+	if err != nil {
+		return err
+	}
+	appCollections := 0
+	for _, collection := range collections {
+		if !collection.IsAuth() && !collection.System {
+			appCollections++
+		}
+	}
+	if appCollections == 0 {
+		log.Printf("[MIGRATIONS] Importing the baseline schema (%d collections)", len(snapshot))
+		return app.ImportCollections(snapshot, false)
+	}
+
+	// Collections are created first, since new fields on existing ones may relate to them
+	missing := []map[string]any{}
+	existing := map[*core.Collection]map[string]any{}
+	for _, data := range snapshot {
+		name, _ := data["name"].(string)
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			missing = append(missing, data)
+			continue
+		}
+		existing[collection] = data
+	}
+	if len(missing) > 0 {
+		if err := app.ImportCollections(missing, false); err != nil {
+			return fmt.Errorf("failed to create missing collections: %w", err)
+		}
+	}
+
+	added := 0
+	for collection, data := range existing {
+		n, err := extendCollection(app, collection, data)
+		if err != nil {
+			return fmt.Errorf("failed to update %s collection: %w", collection.Name, err)
+		}
+		added += n
+	}
+
+	log.Printf("[MIGRATIONS] Baseline applied to an existing database: %d collections created, %d fields and indexes added",
+		len(missing), added)
+	return nil
+}
+
+// extendCollection adds the fields and indexes of the baseline collection that collection lacks,
+// and returns how many it added
+func extendCollection(app core.App, collection *core.Collection, data map[string]any) (int, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return 0, err
+	}
+	var baseline struct {
+		Fields  core.FieldsList `json:"fields"`
+		Indexes []string        `json:"indexes"`
+	}
+	if err := json.Unmarshal(raw, &baseline); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, field := range baseline.Fields {
+		if collection.Fields.GetByName(field.GetName()) == nil {
+			collection.Fields.Add(field)
+			added++
+		}
+	}
+	before := len(collection.Indexes)
+	addIndexesTo(collection, baseline.Indexes...)
+	added += len(collection.Indexes) - before
+
+	if added == 0 {
+		return 0, nil
+	}
+	return added, app.Save(collection)
+}
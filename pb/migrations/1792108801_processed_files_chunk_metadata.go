@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Chunked uploads store each chunk as its own processed_files record, grouped by base_filename
+func init() {
+	m.Register(func(app core.App) error {
+		if err := addFields(app, "processed_files",
+			&core.TextField{Id: "text1994410653", Name: "base_filename"},
+			&core.BoolField{Id: "bool3657077342", Name: "is_chunk"},
+			&core.BoolField{Id: "bool709346193", Name: "is_last_chunk"},
+			&core.NumberField{Id: "number770442083", Name: "chunk_index"},
+		); err != nil {
+			return err
+		}
+		return addIndexes(app, "processed_files",
+			"CREATE INDEX `idx_processed_files_chunks` ON `processed_files` (user_id) WHERE base_filename",
+			"CREATE INDEX `idx_processed_files_is_chunk` ON `processed_files` (is_chunk)",
+		)
+	}, func(app core.App) error {
+		if err := removeIndexes(app, "processed_files", "idx_processed_files_chunks", "idx_processed_files_is_chunk"); err != nil {
+			return err
+		}
+		return removeFields(app, "processed_files", "base_filename", "is_chunk", "is_last_chunk", "chunk_index")
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Free trials: how long a plan's trial is, and when a subscription's trial ends
+func init() {
+	m.Register(func(app core.App) error {
+		if err := addFields(app, "subscription_plans",
+			&core.NumberField{Id: "number3924355226", Name: "trial_days", Min: types.Pointer(0.0), OnlyInt: true},
+		); err != nil {
+			return err
+		}
+		if err := addFields(app, "current_user_subscriptions", &core.DateField{Id: "date3261698147", Name: "trial_end"}); err != nil {
+			return err
+		}
+		return addFields(app, "subscription_history", &core.DateField{Id: "date767561728", Name: "trial_end"})
+	}, func(app core.App) error {
+		if err := removeFields(app, "subscription_history", "trial_end"); err != nil {
+			return err
+		}
+		if err := removeFields(app, "current_user_subscriptions", "trial_end"); err != nil {
+			return err
+		}
+		return removeFields(app, "subscription_plans", "trial_days")
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Yearly plans link to their monthly twin, and switching between them is recorded in history
+func init() {
+	m.Register(func(app core.App) error {
+		plans, err := app.FindCollectionByNameOrId("subscription_plans")
+		if err != nil {
+			return err
+		}
+		if err := addFields(app, "subscription_plans",
+			&core.RelationField{Id: "relation2129788277", Name: "paired_plan_id", CollectionId: plans.Id, MaxSelect: 1},
+		); err != nil {
+			return err
+		}
+		return addSelectValues(app, "subscription_history", "replacement_reason", "interval_change")
+	}, func(app core.App) error {
+		if err := removeSelectValues(app, "subscription_history", "replacement_reason", "interval_change"); err != nil {
+			return err
+		}
+		return removeFields(app, "subscription_plans", "paired_plan_id")
+	})
+}
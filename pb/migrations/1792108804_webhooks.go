@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Outbound webhooks: the callback endpoints users register, and the log of every delivery to them
+func init() {
+	m.Register(func(app core.App) error {
+		ownerRule := types.Pointer("@request.auth.id != '' && user_id = @request.auth.id")
+
+		endpoints := core.NewBaseCollection("webhook_endpoints", "pbc_webhook_endpoints")
+		endpoints.ListRule = ownerRule
+		endpoints.ViewRule = ownerRule
+		endpoints.Fields.Add(
+			&core.RelationField{Id: "relation1557314451", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.SelectField{Id: "select2363381546", Name: "kind", Values: []string{"http", "realtime"}, MaxSelect: 1, Required: true},
+			&core.URLField{Id: "url4101391790", Name: "url"},
+			&core.TextField{Id: "text2480367541", Name: "secret", Hidden: true},
+			&core.JSONField{Id: "json2175934476", Name: "events", MaxSize: 10000},
+			&core.TextField{Id: "text1843675174", Name: "description", Max: 200},
+			&core.BoolField{Id: "bool458715613", Name: "is_active"},
+			&core.NumberField{Id: "number2915341322", Name: "consecutive_failures", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text3862212412", Name: "disabled_reason"},
+			&core.AutodateField{Id: "autodate2990389176", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085495", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		endpoints.AddIndex("idx_webhook_endpoints_user", false, "user_id, is_active", "")
+		if err := app.Save(endpoints); err != nil {
+			return err
+		}
+
+		deliveries := core.NewBaseCollection("webhook_deliveries", "pbc_webhook_deliveries")
+		deliveries.ListRule = ownerRule
+		deliveries.ViewRule = ownerRule
+		deliveries.Fields.Add(
+			&core.RelationField{Id: "relation1557314452", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.RelationField{Id: "relation2462348188", Name: "endpoint_id", CollectionId: endpoints.Id, MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.TextField{Id: "text2524000531", Name: "event_id", Required: true},
+			&core.TextField{Id: "text1001261735", Name: "event", Required: true},
+			&core.JSONField{Id: "json1110206997", Name: "payload", MaxSize: 200000},
+			&core.SelectField{Id: "select2063623452", Name: "status", Values: []string{"pending", "succeeded", "failed"}, MaxSelect: 1, Required: true},
+			&core.NumberField{Id: "number4155165225", Name: "attempts", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.DateField{Id: "date2386719975", Name: "next_attempt_at"},
+			&core.NumberField{Id: "number1418374315", Name: "response_status", OnlyInt: true},
+			&core.TextField{Id: "text3845364624", Name: "last_error"},
+			&core.DateField{Id: "date3587863287", Name: "delivered_at"},
+			&core.AutodateField{Id: "autodate2990389177", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085496", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		deliveries.AddIndex("idx_webhook_deliveries_due", false, "status, next_attempt_at", "")
+		deliveries.AddIndex("idx_webhook_deliveries_user_created", false, "user_id, created", "")
+		deliveries.AddIndex("idx_webhook_deliveries_endpoint", false, "endpoint_id, created", "")
+		return app.Save(deliveries)
+	}, func(app core.App) error {
+		for _, name := range []string{"webhook_deliveries", "webhook_endpoints"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				continue
+			}
+			if err := app.Delete(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
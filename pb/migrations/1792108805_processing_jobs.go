@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Progress of uploads and transcriptions, readable by their owner so clients can follow it over realtime
+func init() {
+	m.Register(func(app core.App) error {
+		ownerRule := types.Pointer("@request.auth.id != '' && user_id = @request.auth.id")
+
+		jobs := core.NewBaseCollection("processing_jobs", "pbc_processing_jobs")
+		jobs.ListRule = ownerRule
+		jobs.ViewRule = ownerRule
+		jobs.Fields.Add(
+			&core.RelationField{Id: "relation1557314453", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.SelectField{Id: "select1602912115", Name: "source", Values: []string{"upload", "transcription_job"}, MaxSelect: 1, Required: true},
+			&core.TextField{Id: "text1745389221", Name: "source_id", Required: true},
+			&core.TextField{Id: "text2105053228", Name: "filename"},
+			&core.SelectField{Id: "select3924539001", Name: "stage", Values: []string{"uploading", "queued", "transcribing", "post_processing", "completed", "failed"}, MaxSelect: 1, Required: true},
+			&core.NumberField{Id: "number1606297314", Name: "percent", Min: types.Pointer(0.0), Max: types.Pointer(100.0), OnlyInt: true},
+			&core.NumberField{Id: "number2542104398", Name: "stage_percent", Min: types.Pointer(0.0), Max: types.Pointer(100.0), OnlyInt: true},
+			&core.NumberField{Id: "number3117489034", Name: "bytes_total", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.NumberField{Id: "number1279034811", Name: "bytes_done", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text3845364625", Name: "error"},
+			&core.DateField{Id: "date2862495610", Name: "completed_at"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		jobs.AddIndex("idx_processing_jobs_source", true, "source, source_id", "")
+		jobs.AddIndex("idx_processing_jobs_user", false, "user_id, created", "")
+		return app.Save(jobs)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("processing_jobs")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Declared start of a chunk in the original file, so chunk timestamps can be shifted onto its timeline
+func init() {
+	m.Register(func(app core.App) error {
+		if err := addFields(app, "transcription_jobs",
+			&core.NumberField{Id: "number2675529103", Name: "chunk_start_seconds", Min: types.Pointer(0.0)},
+		); err != nil {
+			return err
+		}
+		return addFields(app, "audio_upload_chunks",
+			&core.NumberField{Id: "number1870358116", Name: "start_seconds", Min: types.Pointer(0.0)},
+		)
+	}, func(app core.App) error {
+		if err := removeFields(app, "audio_upload_chunks", "start_seconds"); err != nil {
+			return err
+		}
+		return removeFields(app, "transcription_jobs", "chunk_start_seconds")
+	})
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Estimated upstream cost of every AI call, per user, plan and month - superusers only
+func init() {
+	m.Register(func(app core.App) error {
+		ledger := core.NewBaseCollection("cost_ledger", "pbc_cost_ledger")
+		ledger.Fields.Add(
+			&core.RelationField{Id: "relation2809058197", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.TextField{Id: "text2571232815", Name: "organization_id"},
+			&core.TextField{Id: "text2366146245", Name: "plan_id"},
+			&core.TextField{Id: "text1458245306", Name: "year_month", Required: true, Pattern: `^\d{4}-\d{2}$`},
+			&core.SelectField{Id: "select2363381545", Name: "kind", Values: []string{"text", "transcription"}, MaxSelect: 1, Required: true},
+			&core.TextField{Id: "text1786264312", Name: "task_type"},
+			&core.TextField{Id: "text2462348188", Name: "provider"},
+			&core.TextField{Id: "text2359244304", Name: "model"},
+			&core.NumberField{Id: "number1936014587", Name: "prompt_tokens", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.NumberField{Id: "number3012498112", Name: "completion_tokens", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.BoolField{Id: "bool2076390853", Name: "tokens_estimated"},
+			&core.NumberField{Id: "number1108932201", Name: "audio_seconds", Min: types.Pointer(0.0)},
+			&core.NumberField{Id: "number3403548721", Name: "cost_usd", Min: types.Pointer(0.0)},
+			&core.BoolField{Id: "bool1387526014", Name: "priced"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		ledger.AddIndex("idx_cost_ledger_month_user", false, "year_month, user_id", "")
+		ledger.AddIndex("idx_cost_ledger_month_plan", false, "year_month, plan_id", "")
+		return app.Save(ledger)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("cost_ledger")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
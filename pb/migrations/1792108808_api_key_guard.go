@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Per-key IP and country restrictions, usage fingerprints and alerts on suspicious key use
+func init() {
+	m.Register(func(app core.App) error {
+		if err := addFields(app, "api_keys",
+			&core.JSONField{Id: "json1793624501", Name: "allowed_cidrs", MaxSize: 10000},
+			&core.JSONField{Id: "json2420613374", Name: "allowed_countries", MaxSize: 2000},
+		); err != nil {
+			return err
+		}
+
+		apiKeys, err := app.FindCollectionByNameOrId("api_keys")
+		if err != nil {
+			return err
+		}
+		ownerRule := types.Pointer("@request.auth.id != '' && user_id = @request.auth.id")
+
+		fingerprints := core.NewBaseCollection("api_key_fingerprints", "pbc_api_key_fingerprints")
+		fingerprints.ListRule = ownerRule
+		fingerprints.ViewRule = ownerRule
+		fingerprints.Fields.Add(
+			&core.RelationField{Id: "relation3124765408", Name: "key_id", CollectionId: apiKeys.Id, MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.RelationField{Id: "relation1557314453", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.TextField{Id: "text587191692", Name: "ip", Required: true},
+			&core.TextField{Id: "text1400097126", Name: "country"},
+			&core.TextField{Id: "text2744374011", Name: "user_agent"},
+			&core.DateField{Id: "date1203442096", Name: "first_seen"},
+			&core.DateField{Id: "date2372553541", Name: "last_seen"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		fingerprints.AddIndex("idx_api_key_fingerprints_key_ip", true, "key_id, ip", "")
+		if err := app.Save(fingerprints); err != nil {
+			return err
+		}
+
+		alerts := core.NewBaseCollection("api_key_alerts", "pbc_api_key_alerts")
+		alerts.ListRule = ownerRule
+		alerts.ViewRule = ownerRule
+		alerts.Fields.Add(
+			&core.RelationField{Id: "relation3124765408", Name: "key_id", CollectionId: apiKeys.Id, MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.RelationField{Id: "relation1557314453", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.SelectField{Id: "select2363381545", Name: "kind", Values: []string{"new_country", "blocked_ip", "blocked_country"}, MaxSelect: 1, Required: true},
+			&core.TextField{Id: "text587191692", Name: "ip"},
+			&core.TextField{Id: "text1400097126", Name: "country"},
+			&core.JSONField{Id: "json1136210587", Name: "known_countries", MaxSize: 2000},
+			&core.TextField{Id: "text2744374011", Name: "user_agent"},
+			&core.BoolField{Id: "bool2702813440", Name: "notified"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		alerts.AddIndex("idx_api_key_alerts_key", false, "key_id, created", "")
+		return app.Save(alerts)
+	}, func(app core.App) error {
+		for _, name := range []string{"api_key_alerts", "api_key_fingerprints"} {
+			if collection, err := app.FindCollectionByNameOrId(name); err == nil {
+				if err := app.Delete(collection); err != nil {
+					return err
+				}
+			}
+		}
+		return removeFields(app, "api_keys", "allowed_cidrs", "allowed_countries")
+	})
+}
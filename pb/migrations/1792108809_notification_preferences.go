@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Per-user notification email preferences
+func init() {
+	m.Register(func(app core.App) error {
+		return addFields(app, "users",
+			&core.JSONField{Id: "json3201846617", Name: "notification_preferences", MaxSize: 2000},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "users", "notification_preferences")
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Per-user in-app notification center, written by the server and read by the clients
+func init() {
+	m.Register(func(app core.App) error {
+		ownerRule := types.Pointer("@request.auth.id != '' && user_id = @request.auth.id")
+
+		notifications := core.NewBaseCollection("notifications", "pbc_notifications")
+		notifications.ListRule = ownerRule
+		notifications.ViewRule = ownerRule
+		notifications.Fields.Add(
+			&core.RelationField{Id: "relation1557314453", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.SelectField{Id: "select2363381545", Name: "type", MaxSelect: 1, Required: true,
+				Values: []string{"payment_failed", "subscription_canceled", "plan_changed", "usage_80", "usage_100", "trial_ending"}},
+			&core.TextField{Id: "text724990059", Name: "title", Required: true},
+			&core.TextField{Id: "text3685223346", Name: "body"},
+			&core.TextField{Id: "text2910482745", Name: "link"},
+			&core.TextField{Id: "text1479155208", Name: "reference"},
+			&core.BoolField{Id: "bool2555855207", Name: "read"},
+			&core.DateField{Id: "date2370512338", Name: "read_at"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		notifications.AddIndex("idx_notifications_user_read", false, "user_id, read, created", "")
+		return app.Save(notifications)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("notifications")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Per-plan audio upload size limit, overriding the free and paid tier limits
+func init() {
+	m.Register(func(app core.App) error {
+		return addFields(app, "subscription_plans",
+			&core.NumberField{Id: "number2936014871", Name: "max_upload_mb", Min: types.Pointer(0.0), OnlyInt: true},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "subscription_plans", "max_upload_mb")
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Prompts and transcripts flagged by content moderation, for review - superusers only
+func init() {
+	m.Register(func(app core.App) error {
+		flags := core.NewBaseCollection("moderation_flags", "pbc_moderation_flags")
+		flags.Fields.Add(
+			&core.RelationField{Id: "relation2809058197", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.SelectField{Id: "select1602912115", Name: "source", Values: []string{"prompt", "transcript"}, MaxSelect: 1, Required: true},
+			&core.SelectField{Id: "select1204587666", Name: "action", Values: []string{"block", "flag"}, MaxSelect: 1, Required: true},
+			&core.TextField{Id: "text2462348188", Name: "provider"},
+			&core.JSONField{Id: "json3037040523", Name: "categories"},
+			&core.JSONField{Id: "json2401535574", Name: "scores"},
+			&core.TextField{Id: "text1843675174", Name: "excerpt"},
+			&core.TextField{Id: "text1786264312", Name: "task_type"},
+			&core.TextField{Id: "text3196113032", Name: "processed_file_id"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		flags.AddIndex("idx_moderation_flags_user", false, "user_id, created", "")
+		return app.Save(flags)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("moderation_flags")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
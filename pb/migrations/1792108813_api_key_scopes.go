@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// What each API key may do. Keys without scopes, including every existing key, keep full access
+func init() {
+	m.Register(func(app core.App) error {
+		return addFields(app, "api_keys",
+			&core.SelectField{Id: "select3176398547", Name: "scopes", MaxSelect: 6,
+				Values: []string{"ai:text", "ai:audio", "usage:read", "usage:write", "keys:manage", "webhooks:manage"}},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "api_keys", "scopes")
+	})
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Short-lived delegated tokens for the desktop app: one grant per sign-in, holding the refresh
+// token hash and the key access tokens are signed with - superusers only, users go through the API
+func init() {
+	m.Register(func(app core.App) error {
+		grants := core.NewBaseCollection("delegated_tokens", "pbc_delegated_tokens")
+		grants.Fields.Add(
+			&core.RelationField{Id: "relation2809058197", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true, Required: true},
+			&core.TextField{Id: "text1579384326", Name: "name", Max: 100},
+			&core.SelectField{Id: "select3176398547", Name: "scopes", MaxSelect: 6,
+				Values: []string{"ai:text", "ai:audio", "usage:read", "usage:write", "keys:manage", "webhooks:manage"}},
+			&core.TextField{Id: "text2504183744", Name: "token_key", Required: true, Hidden: true},
+			&core.TextField{Id: "text1436317196", Name: "refresh_hash", Hidden: true},
+			&core.DateField{Id: "date261981154", Name: "expires_at"},
+			&core.DateField{Id: "date2150961208", Name: "last_refreshed_at"},
+			&core.DateField{Id: "date1521837512", Name: "last_used_at"},
+			&core.BoolField{Id: "bool2889380040", Name: "revoked"},
+			&core.DateField{Id: "date3937271004", Name: "revoked_at"},
+			&core.TextField{Id: "text587191692", Name: "client_ip"},
+			&core.TextField{Id: "text2744374011", Name: "user_agent"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		grants.AddIndex("idx_delegated_tokens_refresh_hash", false, "refresh_hash", "")
+		grants.AddIndex("idx_delegated_tokens_user", false, "user_id, revoked", "")
+		return app.Save(grants)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("delegated_tokens")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Every transactional email sent, with the provider that took it and its delivery status from
+// provider webhooks - superusers only
+func init() {
+	m.Register(func(app core.App) error {
+		sent := core.NewBaseCollection("sent_emails", "pbc_sent_emails")
+		sent.Fields.Add(
+			&core.RelationField{Id: "relation2809058197", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, CascadeDelete: true},
+			&core.TextField{Id: "text3230180316", Name: "recipient", Required: true},
+			&core.TextField{Id: "text3065852031", Name: "subject"},
+			&core.TextField{Id: "text2363381545", Name: "kind"},
+			&core.TextField{Id: "text1508592268", Name: "reference"},
+			&core.TextField{Id: "text2462348188", Name: "provider"},
+			&core.TextField{Id: "text1207440931", Name: "provider_message_id"},
+			&core.SelectField{Id: "select2063623452", Name: "status", MaxSelect: 1, Required: true,
+				Values: []string{"sent", "failed", "delayed", "delivered", "bounced", "complained"}},
+			&core.JSONField{Id: "json3471521427", Name: "attempts"},
+			&core.JSONField{Id: "json1418462342", Name: "events"},
+			&core.TextField{Id: "text1574812785", Name: "error"},
+			&core.DateField{Id: "date3126937283", Name: "last_event_at"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085497", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		sent.AddIndex("idx_sent_emails_provider_message", false, "provider, provider_message_id", "")
+		sent.AddIndex("idx_sent_emails_status_created", false, "status, created", "")
+		return app.Save(sent)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sent_emails")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Plans managed by the plan catalog carry its key, so renamed plans are still recognized
+func init() {
+	m.Register(func(app core.App) error {
+		if err := addFields(app, "subscription_plans",
+			&core.TextField{Id: "text1862154305", Name: "catalog_key", Max: 100},
+		); err != nil {
+			return err
+		}
+		return addIndexes(app, "subscription_plans",
+			"CREATE INDEX `idx_subscription_plans_catalog_key` ON `subscription_plans` (`catalog_key`)",
+		)
+	}, func(app core.App) error {
+		if err := removeIndexes(app, "subscription_plans", "idx_subscription_plans_catalog_key"); err != nil {
+			return err
+		}
+		return removeFields(app, "subscription_plans", "catalog_key")
+	})
+}
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Users can have several API keys (named keys, rotation, sandbox keys), but the baseline
+// kept a unique index on user_id left over from when there was one key per user
+func init() {
+	m.Register(func(app core.App) error {
+		return removeIndexes(app, "api_keys", "idx_CIyBGXZcuz")
+	}, func(app core.App) error {
+		return addIndexes(app, "api_keys", "CREATE UNIQUE INDEX `idx_CIyBGXZcuz` ON `api_keys` (`user_id`)")
+	})
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Per-plan limit on how many times the same audio is transcribed, and the extra attempts
+// granted on top of it by a superuser or bought as a paid re-run - superusers only
+func init() {
+	m.Register(func(app core.App) error {
+		if err := addFields(app, "subscription_plans",
+			&core.NumberField{Id: "number1417906330", Name: "max_processing_attempts", Min: types.Pointer(0.0), OnlyInt: true},
+		); err != nil {
+			return err
+		}
+
+		grants := core.NewBaseCollection("reprocess_grants", "pbc_reprocess_grants")
+		grants.Fields.Add(
+			&core.RelationField{Id: "relation2809058197", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, Required: true, CascadeDelete: true},
+			&core.TextField{Id: "text2924409815", Name: "content_hash", Required: true},
+			&core.NumberField{Id: "number2650521430", Name: "attempts", Min: types.Pointer(1.0), OnlyInt: true, Required: true},
+			&core.SelectField{Id: "select1602912115", Name: "source", MaxSelect: 1, Required: true, Values: []string{"admin", "purchase"}},
+			&core.TextField{Id: "text3725765462", Name: "granted_by"},
+			&core.TextField{Id: "text1001949196", Name: "reason"},
+			&core.AutodateField{Id: "autodate2990389178", Name: "created", OnCreate: true},
+		)
+		grants.AddIndex("idx_reprocess_grants_user_hash", false, "user_id, content_hash", "")
+		return app.Save(grants)
+	}, func(app core.App) error {
+		if collection, err := app.FindCollectionByNameOrId("reprocess_grants"); err == nil {
+			if err := app.Delete(collection); err != nil {
+				return err
+			}
+		}
+		return removeFields(app, "subscription_plans", "max_processing_attempts")
+	})
+}
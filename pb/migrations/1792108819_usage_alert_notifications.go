@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Notifications for usage thresholds users choose besides 80% and 100%
+func init() {
+	m.Register(func(app core.App) error {
+		return addSelectValues(app, "notifications", "type", "usage_alert")
+	}, func(app core.App) error {
+		return removeSelectValues(app, "notifications", "type", "usage_alert")
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Refund cases: requested by users, refunded automatically within the refund policy or decided
+// by a superuser - read through the payment and admin endpoints only
+func init() {
+	m.Register(func(app core.App) error {
+		refunds := core.NewBaseCollection("refunds", "pbc_refunds")
+		refunds.Fields.Add(
+			&core.RelationField{Id: "relation3312054018", Name: "user_id", CollectionId: "_pb_users_auth_", MaxSelect: 1, Required: true, CascadeDelete: true},
+			&core.TextField{Id: "text1482270465", Name: "provider_invoice_id", Required: true},
+			&core.NumberField{Id: "number2587395271", Name: "amount_cents", Min: types.Pointer(0.0), OnlyInt: true},
+			&core.TextField{Id: "text1767278655", Name: "currency"},
+			&core.TextField{Id: "text1001949197", Name: "reason", Max: 1000},
+			&core.SelectField{Id: "select2063623452", Name: "status", MaxSelect: 1, Required: true, Values: []string{"pending", "refunded", "denied", "failed"}},
+			&core.NumberField{Id: "number3847520163", Name: "hours_used", Min: types.Pointer(0.0)},
+			&core.TextField{Id: "text2910437318", Name: "review_reason"},
+			&core.BoolField{Id: "bool1594823306", Name: "ends_subscription"},
+			&core.TextField{Id: "text3018829462", Name: "decided_by"},
+			&core.TextField{Id: "text1236092947", Name: "decision_note"},
+			&core.TextField{Id: "text4120985613", Name: "provider_refund_id"},
+			&core.TextField{Id: "text1574812785", Name: "error"},
+			&core.DateField{Id: "date2407615930", Name: "decided_at"},
+			&core.AutodateField{Id: "autodate2990389179", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate3332085496", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		refunds.AddIndex("idx_refunds_user", false, "user_id, created", "")
+		refunds.AddIndex("idx_refunds_invoice", false, "provider_invoice_id", "")
+		refunds.AddIndex("idx_refunds_status", false, "status, created", "")
+		return app.Save(refunds)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("refunds")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
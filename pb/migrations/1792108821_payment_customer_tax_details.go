@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Billing address and tax IDs customers give at checkout (or in their business profile), kept
+// for invoicing
+func init() {
+	m.Register(func(app core.App) error {
+		return addFields(app, "payment_customers",
+			&core.TextField{Id: "text2217653829", Name: "tax_country", Max: 2},
+			&core.JSONField{Id: "json3570184612", Name: "billing_address"},
+			&core.JSONField{Id: "json1843027465", Name: "tax_ids"},
+			&core.TextField{Id: "text2870351946", Name: "tax_exempt"},
+			&core.DateField{Id: "date1129835604", Name: "tax_details_updated"},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "payment_customers", "tax_country", "billing_address", "tax_ids", "tax_exempt", "tax_details_updated")
+	})
+}
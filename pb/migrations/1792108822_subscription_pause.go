@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// When a subscription was paused and when it resumes by itself (empty while it isn't paused, or
+// when it's paused until the user resumes it)
+func init() {
+	m.Register(func(app core.App) error {
+		return addFields(app, "current_user_subscriptions",
+			&core.DateField{Id: "date2871605394", Name: "paused_at"},
+			&core.DateField{Id: "date3958120467", Name: "resumes_at"},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "current_user_subscriptions", "paused_at", "resumes_at")
+	})
+}
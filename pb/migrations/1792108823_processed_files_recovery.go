@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Why a transcription failed and whether the user can simply upload the file again - set on
+// records left processing by a restart, which are failed at startup
+func init() {
+	m.Register(func(app core.App) error {
+		return addFields(app, "processed_files",
+			&core.TextField{Id: "text3198267405", Name: "failure_reason"},
+			&core.BoolField{Id: "bool2580716943", Name: "retriable"},
+		)
+	}, func(app core.App) error {
+		return removeFields(app, "processed_files", "failure_reason", "retriable")
+	})
+}
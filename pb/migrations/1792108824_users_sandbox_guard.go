@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// sandboxFieldGuard rejects requests setting the sandbox fields of a user
+const sandboxFieldGuard = "@request.body.sandbox:isset = false && @request.body.sandbox_owner:isset = false"
+
+// Sandbox tenants are only created server-side (accounts.EnsureSandboxTenant). Hide the
+// sandbox fields, which drops them from non-superuser request bodies, and reject them in the
+// users create and update rules too, so users can't turn their own account into a sandbox
+// tenant and check out paid plans without paying
+func init() {
+	m.Register(func(app core.App) error {
+		return guardSandboxFields(app, true, sandboxFieldGuard, "id = @request.auth.id && "+sandboxFieldGuard)
+	}, func(app core.App) error {
+		return guardSandboxFields(app, false, "", "id = @request.auth.id")
+	})
+}
+
+func guardSandboxFields(app core.App, hidden bool, createRule, updateRule string) error {
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"sandbox", "sandbox_owner"} {
+		if field := users.Fields.GetByName(name); field != nil {
+			field.SetHidden(hidden)
+		}
+	}
+	users.CreateRule = types.Pointer(createRule)
+	users.UpdateRule = types.Pointer(updateRule)
+	return app.Save(users)
+}
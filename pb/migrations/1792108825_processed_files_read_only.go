@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// processed_files are written by the server only: they count towards the processing attempt
+// limit, so owners editing or deleting them could reset it. Owners keep read access, and
+// delete and restore through /api/usage/files
+func init() {
+	m.Register(func(app core.App) error {
+		return setProcessedFilesWriteRule(app, nil)
+	}, func(app core.App) error {
+		return setProcessedFilesWriteRule(app, types.Pointer("@request.auth.id != '' && user_id = @request.auth.id"))
+	})
+}
+
+func setProcessedFilesWriteRule(app core.App, rule *string) error {
+	collection, err := app.FindCollectionByNameOrId("processed_files")
+	if err != nil {
+		return err
+	}
+	collection.CreateRule = rule
+	collection.UpdateRule = rule
+	collection.DeleteRule = rule
+	return app.Save(collection)
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Re-runs users request on paid plans aren't charged on their own, so their reprocess_grants
+// source is "rerun" rather than "purchase"
+func init() {
+	m.Register(func(app core.App) error {
+		return renameGrantSource(app, "purchase", "rerun")
+	}, func(app core.App) error {
+		return renameGrantSource(app, "rerun", "purchase")
+	})
+}
+
+// renameGrantSource renames a value of reprocess_grants.source, along with the grants using it
+func renameGrantSource(app core.App, from, to string) error {
+	grants, err := app.FindCollectionByNameOrId("reprocess_grants")
+	if err != nil {
+		return err
+	}
+	source, ok := grants.Fields.GetByName("source").(*core.SelectField)
+	if !ok {
+		return nil
+	}
+
+	source.Values = []string{"admin", from, to}
+	if err := app.Save(grants); err != nil {
+		return err
+	}
+	if _, err := app.DB().Update("reprocess_grants", dbx.Params{"source": to}, dbx.HashExp{"source": from}).Execute(); err != nil {
+		return err
+	}
+	source.Values = []string{"admin", to}
+	return app.Save(grants)
+}
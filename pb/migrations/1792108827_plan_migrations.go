@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Plan migrations run in the background; each run is kept with its report so its progress
+// and outcome can be read back - superusers only
+func init() {
+	m.Register(func(app core.App) error {
+		migrations := core.NewBaseCollection("plan_migrations", "pbc_plan_migrations")
+		migrations.Fields.Add(
+			&core.TextField{Id: "text1602912116", Name: "source_plan_id", Required: true},
+			&core.TextField{Id: "text1602912117", Name: "target_plan_id", Required: true},
+			&core.TextField{Id: "text1602912118", Name: "mode"},
+			&core.BoolField{Id: "bool1602912119", Name: "dry_run"},
+			&core.SelectField{Id: "select1602912120", Name: "status", MaxSelect: 1, Required: true, Values: []string{"running", "completed", "failed"}},
+			&core.JSONField{Id: "json1602912121", Name: "report", MaxSize: 10 << 20},
+			&core.TextField{Id: "text1602912122", Name: "error"},
+			&core.TextField{Id: "text1602912123", Name: "actor_id"},
+			&core.TextField{Id: "text1602912124", Name: "actor_email"},
+			&core.AutodateField{Id: "autodate1602912125", Name: "created", OnCreate: true},
+			&core.AutodateField{Id: "autodate1602912126", Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		migrations.AddIndex("idx_plan_migrations_status", false, "status, source_plan_id", "")
+		return app.Save(migrations)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("plan_migrations")
+		if err != nil {
+			return nil
+		}
+		return app.Delete(collection)
+	})
+}
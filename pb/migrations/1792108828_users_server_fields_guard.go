@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// serverOnlyUserFields are user fields only the server writes: the phone number is set when its
+// OTP is verified, so users setting phone_verified themselves would skip the verification, and
+// deactivation is done by admins (clearing deactivated_at would get past the users auth rule)
+var serverOnlyUserFields = []string{"phone", "phone_verified", "deactivated_at", "deactivation_reason", "subscription_paused"}
+
+// Reject the server-only fields in the users create and update rules. They stay visible to
+// their owner, so unlike the sandbox fields they aren't hidden
+func init() {
+	m.Register(func(app core.App) error {
+		guard := sandboxFieldGuard + " && " + issetGuard(serverOnlyUserFields)
+		return setUsersWriteRules(app, guard, "id = @request.auth.id && "+guard)
+	}, func(app core.App) error {
+		return setUsersWriteRules(app, sandboxFieldGuard, "id = @request.auth.id && "+sandboxFieldGuard)
+	})
+}
+
+// issetGuard is a rule rejecting requests whose body sets any of fields
+func issetGuard(fields []string) string {
+	conditions := make([]string, 0, len(fields))
+	for _, field := range fields {
+		conditions = append(conditions, "@request.body."+field+":isset = false")
+	}
+	return strings.Join(conditions, " && ")
+}
+
+func setUsersWriteRules(app core.App, createRule, updateRule string) error {
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		return err
+	}
+	users.CreateRule = types.Pointer(createRule)
+	users.UpdateRule = types.Pointer(updateRule)
+	return app.Save(users)
+}
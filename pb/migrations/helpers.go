@@ -0,0 +1,130 @@
+// Package migrations holds the versioned schema migrations, applied in file name order by
+// `pocketbase serve` and `pocketbase migrate up`. Schema changes go into a new migration here
+// instead of editing baseline_schema.json, so they reach existing deployments too
+package migrations
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/dbutils"
+)
+
+// addFields adds the fields the collection doesn't have yet. Fields are matched by name, so
+// deployments that already got them through the Admin UI migrate cleanly
+func addFields(app core.App, collectionName string, fields ...core.Field) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to find %s collection: %w", collectionName, err)
+	}
+
+	changed := false
+	for _, field := range fields {
+		if collection.Fields.GetByName(field.GetName()) != nil {
+			continue
+		}
+		collection.Fields.Add(field)
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return app.Save(collection)
+}
+
+// removeFields drops the named fields, and their data, if the collection has them
+func removeFields(app core.App, collectionName string, names ...string) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to find %s collection: %w", collectionName, err)
+	}
+
+	for _, name := range names {
+		collection.Fields.RemoveByName(name)
+	}
+	return app.Save(collection)
+}
+
+// addIndexes adds the CREATE INDEX statements whose index name the collection doesn't have yet
+func addIndexes(app core.App, collectionName string, indexes ...string) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to find %s collection: %w", collectionName, err)
+	}
+
+	before := len(collection.Indexes)
+	addIndexesTo(collection, indexes...)
+	if len(collection.Indexes) == before {
+		return nil
+	}
+	return app.Save(collection)
+}
+
+// addIndexesTo appends the CREATE INDEX statements whose index name collection doesn't have yet
+func addIndexesTo(collection *core.Collection, indexes ...string) {
+	for _, index := range indexes {
+		if collection.GetIndex(dbutils.ParseIndex(index).IndexName) == "" {
+			collection.Indexes = append(collection.Indexes, index)
+		}
+	}
+}
+
+// removeIndexes drops the named indexes if the collection has them
+func removeIndexes(app core.App, collectionName string, names ...string) error {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to find %s collection: %w", collectionName, err)
+	}
+
+	for _, name := range names {
+		collection.RemoveIndex(name)
+	}
+	return app.Save(collection)
+}
+
+// addSelectValues appends values a select field doesn't offer yet
+func addSelectValues(app core.App, collectionName, fieldName string, values ...string) error {
+	collection, field, err := findSelectField(app, collectionName, fieldName)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, value := range values {
+		if !slices.Contains(field.Values, value) {
+			field.Values = append(field.Values, value)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return app.Save(collection)
+}
+
+// removeSelectValues drops values from a select field. Records still holding them keep their
+// value until they're next saved
+func removeSelectValues(app core.App, collectionName, fieldName string, values ...string) error {
+	collection, field, err := findSelectField(app, collectionName, fieldName)
+	if err != nil {
+		return err
+	}
+
+	field.Values = slices.DeleteFunc(field.Values, func(value string) bool {
+		return slices.Contains(values, value)
+	})
+	return app.Save(collection)
+}
+
+func findSelectField(app core.App, collectionName, fieldName string) (*core.Collection, *core.SelectField, error) {
+	collection, err := app.FindCollectionByNameOrId(collectionName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find %s collection: %w", collectionName, err)
+	}
+	field, ok := collection.Fields.GetByName(fieldName).(*core.SelectField)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s.%s is not a select field", collectionName, fieldName)
+	}
+	return collection, field, nil
+}
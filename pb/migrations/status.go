@@ -0,0 +1,66 @@
+package migrations
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Status compares the migrations applied to the database with the ones built into this binary
+type Status struct {
+	Applied int
+	Pending []string // built in but not applied yet
+	Unknown []string // applied by another build - usually a newer one this binary predates
+}
+
+// CheckStatus reads the applied migrations from the _migrations table
+func CheckStatus(app core.App) (Status, error) {
+	applied := []string{}
+	if err := app.DB().Select("file").From(core.DefaultMigrationsTable).Column(&applied); err != nil {
+		return Status{}, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	known := []string{}
+	for _, list := range []core.MigrationsList{core.SystemMigrations, core.AppMigrations} {
+		for _, migration := range list.Items() {
+			known = append(known, migration.File)
+		}
+	}
+	return compareMigrations(known, applied), nil
+}
+
+func compareMigrations(known, applied []string) Status {
+	status := Status{Applied: len(applied), Pending: []string{}, Unknown: []string{}}
+	for _, file := range known {
+		if !slices.Contains(applied, file) {
+			status.Pending = append(status.Pending, file)
+		}
+	}
+	for _, file := range applied {
+		if !slices.Contains(known, file) {
+			status.Unknown = append(status.Unknown, file)
+		}
+	}
+	slices.Sort(status.Unknown)
+	return status
+}
+
+// Verify fails while migrations are pending or the database was migrated by a build that has
+// migrations this one doesn't, since the schema then isn't the one the handlers expect
+func Verify(app core.App) error {
+	status, err := CheckStatus(app)
+	if err != nil {
+		return err
+	}
+	if len(status.Unknown) > 0 {
+		return fmt.Errorf("database has %d migrations this build doesn't know (%s) - deploy the build that applied them, or revert them with `pocketbase migrate down`",
+			len(status.Unknown), strings.Join(status.Unknown, ", "))
+	}
+	if len(status.Pending) > 0 {
+		return fmt.Errorf("%d migrations are not applied (%s) - run `pocketbase migrate up`",
+			len(status.Pending), strings.Join(status.Pending, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompareMigrations(t *testing.T) {
+	known := []string{"1640988000_init.go", "1792108800_baseline_schema.go", "1792108801_processed_files_chunk_metadata.go"}
+	applied := []string{"1640988000_init.go", "1792108800_baseline_schema.go", "1799999999_from_newer_build.go"}
+
+	status := compareMigrations(known, applied)
+	if status.Applied != 3 {
+		t.Errorf("applied = %d, want 3", status.Applied)
+	}
+	if !slices.Equal(status.Pending, []string{"1792108801_processed_files_chunk_metadata.go"}) {
+		t.Errorf("pending = %v", status.Pending)
+	}
+	if !slices.Equal(status.Unknown, []string{"1799999999_from_newer_build.go"}) {
+		t.Errorf("unknown = %v", status.Unknown)
+	}
+
+	if status := compareMigrations(known, known); len(status.Pending) != 0 || len(status.Unknown) != 0 {
+		t.Errorf("expected an up to date database, got %+v", status)
+	}
+}